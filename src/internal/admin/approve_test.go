@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"src/internal/audit"
+	"src/internal/db"
+)
+
+// Компании в памяти. Компания и её пользователь должны создаваться в транзакции одобрения
+type memoryCompanies struct {
+	CompanyStorage
+	tx        *sql.Tx
+	created   *[]*Company
+	createErr error
+}
+
+func (s *memoryCompanies) WithTx(tx *sql.Tx) CompanyStorage {
+	copy := *s
+	copy.tx = tx
+	return &copy
+}
+
+func (s *memoryCompanies) Create(company *Company) error {
+	if s.tx == nil {
+		panic("company must be created in the approval transaction")
+	}
+	if s.createErr != nil {
+		return s.createErr
+	}
+	*s.created = append(*s.created, company)
+	return nil
+}
+
+type memoryPartnersUsers struct {
+	tx      *sql.Tx
+	members *[]string
+}
+
+func (s memoryPartnersUsers) WithTx(tx *sql.Tx) PartnersUsersStorage {
+	return memoryPartnersUsers{tx: tx, members: s.members}
+}
+
+func (s memoryPartnersUsers) Create(email, inn string) error {
+	if s.tx == nil {
+		panic("partner user must be created in the approval transaction")
+	}
+	*s.members = append(*s.members, inn+"/"+email)
+	return nil
+}
+
+type recordingRevoker struct {
+	revoked []string
+}
+
+func (r *recordingRevoker) Revoke(email string) error {
+	r.revoked = append(r.revoked, email)
+	return nil
+}
+
+type approveFixture struct {
+	*reviewFixture
+	companies *memoryCompanies
+	created   []*Company
+	members   []string
+	revoker   *recordingRevoker
+	mock      sqlmock.Sqlmock
+}
+
+// Заявка в работе у assignee, одобрение выполняется в транзакции на sqlmock
+func newApproveFixture(t *testing.T) *approveFixture {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	f := &approveFixture{reviewFixture: newReviewFixture(), companies: &memoryCompanies{}, revoker: &recordingRevoker{}, mock: mock}
+	f.companies.created = &f.created
+	f.manager.companyStorage = f.companies
+	f.manager.partnersUsersStorage = memoryPartnersUsers{members: &f.members}
+	f.manager.roleRevoker = f.revoker
+	f.manager.uow = db.NewTxManager(sqlDB)
+	return f
+}
+
+func (f *approveFixture) checkTransaction(t *testing.T) {
+	t.Helper()
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApproveInTransaction(t *testing.T) {
+	f := newApproveFixture(t)
+	f.mock.ExpectBegin()
+	f.mock.ExpectCommit()
+
+	if err := f.manager.ApprovePartnerRequest(assignee, f.req.ID, ""); err != nil {
+		t.Fatalf("ApprovePartnerRequest: %v", err)
+	}
+	if f.req.Status != "approved" || len(f.created) != 1 || f.created[0].INN != f.req.INN {
+		t.Errorf("status = %q, companies = %v", f.req.Status, f.created)
+	}
+	if len(f.members) != 1 || f.members[0] != "7700000000/applicant@example.com" {
+		t.Errorf("members = %v", f.members)
+	}
+	if got := f.audit.actions(); len(got) != 1 || got[0] != audit.ActionPartnerRequestApprove {
+		t.Errorf("audit = %v", got)
+	}
+	if len(f.sender.sent) != 1 || len(f.revoker.revoked) != 1 {
+		t.Errorf("sent = %d, revoked = %v", len(f.sender.sent), f.revoker.revoked)
+	}
+	f.checkTransaction(t)
+}
+
+// Ошибка любого шага откатывает одобрение целиком: токены заявителя не отзываются
+func TestApproveRollsBack(t *testing.T) {
+	errCompany := errors.New("duplicate inn")
+	errAudit := errors.New("audit unavailable")
+
+	tests := []struct {
+		name    string
+		setup   func(f *approveFixture)
+		wantErr error
+	}{
+		{"company create fails", func(f *approveFixture) { f.companies.createErr = errCompany }, errCompany},
+		{"audit fails", func(f *approveFixture) { f.audit.err = errAudit }, errAudit},
+		// Заявку рассмотрел другой администратор после чтения: компания не создаётся
+		{"request rejected meanwhile", func(f *approveFixture) {
+			f.requests.onGet = func(req *PartnerRequest) { req.Status = "rejected" }
+		}, ErrPartnerRequestChanged},
+		{"request reassigned meanwhile", func(f *approveFixture) {
+			f.requests.onGet = func(req *PartnerRequest) { req.AssignedTo = other.Email }
+		}, ErrPartnerRequestChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newApproveFixture(t)
+			tt.setup(f)
+			f.mock.ExpectBegin()
+			f.mock.ExpectRollback()
+
+			if err := f.manager.ApprovePartnerRequest(assignee, f.req.ID, ""); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ApprovePartnerRequest error = %v, want %v", err, tt.wantErr)
+			}
+			if len(f.revoker.revoked) != 0 {
+				t.Errorf("revoked = %v, want none", f.revoker.revoked)
+			}
+			if errors.Is(tt.wantErr, ErrPartnerRequestChanged) && (len(f.created) != 0 || len(f.audit.entries) != 0) {
+				t.Errorf("companies = %v, audit = %v", f.created, f.audit.actions())
+			}
+			f.checkTransaction(t)
+		})
+	}
+}
+
+// Одобрение чужой заявки с override: решение и причина записываются в журнал в той же транзакции
+func TestApproveOverride(t *testing.T) {
+	f := newApproveFixture(t)
+	f.mock.ExpectBegin()
+	f.mock.ExpectCommit()
+
+	if err := f.manager.ApprovePartnerRequest(other, f.req.ID, ""); !errors.Is(err, ErrNotAssignee) {
+		t.Fatalf("ApprovePartnerRequest without reason error = %v, want ErrNotAssignee", err)
+	}
+	if err := f.manager.ApprovePartnerRequest(other, f.req.ID, "Администратор в отпуске"); err != nil {
+		t.Fatalf("ApprovePartnerRequest: %v", err)
+	}
+	want := []string{audit.ActionPartnerRequestOverride, audit.ActionPartnerRequestApprove}
+	if got := f.audit.actions(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("audit = %v, want %v", got, want)
+	}
+	f.checkTransaction(t)
+}
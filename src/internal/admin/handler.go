@@ -2,8 +2,10 @@ package admin
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"src/internal/audit"
 	"src/internal/middleware"
 
 	"github.com/go-chi/chi/v5"
@@ -60,7 +62,8 @@ func (h *Handler) CreatePartnerRequest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// TakeRequestToWork обрабатывает POST /admin/partner-requests/take, меняет статус заявки
+// TakeRequestToWork обрабатывает POST /admin/partner-requests/take, назначает заявку себе
+// и переводит новую заявку в работу
 func (h *Handler) TakeRequestToWork(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ID uuid.UUID `json:"id" validate:"required"`
@@ -76,20 +79,104 @@ func (h *Handler) TakeRequestToWork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.admin.TakeRequestToWork(req.ID)
+	actor := audit.ActorFromRequest(r)
+	err := h.admin.TakeRequestToWork(actor, req.ID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeReviewError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":     "Request taken to work",
+		"id":          req.ID.String(),
+		"assigned_to": actor.Email,
+	})
+}
+
+// AssignRequest обрабатывает POST /admin/partner-requests/assign, передаёт заявку другому администратору
+func (h *Handler) AssignRequest(w http.ResponseWriter, r *http.Request) {
+	var req AssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	err := h.admin.ReassignRequest(audit.ActorFromRequest(r), req.ID, req.Admin, req.reason())
+	if err != nil {
+		writeReviewError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":     "Request assigned",
+		"id":          req.ID.String(),
+		"assigned_to": req.Admin,
+	})
+}
+
+// ReleaseRequest обрабатывает POST /admin/partner-requests/release, возвращает заявку в общую очередь
+func (h *Handler) ReleaseRequest(w http.ResponseWriter, r *http.Request) {
+	var req ReleaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	err := h.admin.ReleaseRequest(audit.ActorFromRequest(r), req.ID, req.reason())
+	if err != nil {
+		writeReviewError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Request taken to work",
+		"message": "Request released",
 		"id":      req.ID.String(),
-		"status":  "pending",
 	})
 }
 
+// GetMyRequests обрабатывает GET /admin/partner-requests/my, получает незакрытые заявки,
+// назначенные текущему администратору
+func (h *Handler) GetMyRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.admin.GetMyRequests(audit.ActorFromRequest(r).Email)
+	if err != nil {
+		http.Error(w, "Failed to get requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// Ошибки рассмотрения заявки в HTTP ответ. Прочие ошибки сервиса отдаются как 400
+func writeReviewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPartnerRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNotAssignee):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrPartnerRequestAssigned),
+		errors.Is(err, ErrPartnerRequestNotAssigned),
+		errors.Is(err, ErrPartnerRequestClosed),
+		errors.Is(err, ErrPartnerRequestChanged):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
 // GetAllRequests обрабатывает GET /admin/partner-requests/, получает все заявки
 func (h *Handler) GetAllRequests(w http.ResponseWriter, r *http.Request) {
 	requests, err := h.admin.GetAllRequests()
@@ -126,6 +213,19 @@ func (h *Handler) GetPendingRequests(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(requests)
 }
 
+// GetNeedsInfoRequests обрабатывает GET /admin/partner-requests/needs-info, получает заявки,
+// ожидающие сведений от заявителя
+func (h *Handler) GetNeedsInfoRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.admin.GetRequestsByStatus("needs_info")
+	if err != nil {
+		http.Error(w, "Failed to get requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
 // GetApprovedRequests обрабатывает GET /admin/partner-requests/approved, получает только принятые заявки
 func (h *Handler) GetApprovedRequests(w http.ResponseWriter, r *http.Request) {
 	requests, err := h.admin.GetRequestsByStatus("approved")
@@ -164,9 +264,9 @@ func (h *Handler) ApprovePartnerRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err := h.admin.ApprovePartnerRequest(req.ID)
+	err := h.admin.ApprovePartnerRequest(audit.ActorFromRequest(r), req.ID, req.reason())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeReviewError(w, err)
 		return
 	}
 
@@ -176,11 +276,9 @@ func (h *Handler) ApprovePartnerRequest(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-// RejectPartnerRequest обрабатывает POST /admin/partner-requests/reject, отклоняет заявку
+// RejectPartnerRequest обрабатывает POST /admin/partner-requests/reject, отклоняет заявку с указанием причины
 func (h *Handler) RejectPartnerRequest(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		ID uuid.UUID `json:"id" validate:"required"`
-	}
+	var req RejectPartnerRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -192,9 +290,9 @@ func (h *Handler) RejectPartnerRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.admin.RejectPartnerRequest(req.ID)
+	err := h.admin.RejectPartnerRequest(audit.ActorFromRequest(r), req.ID, req.Reason, req.reason())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeReviewError(w, err)
 		return
 	}
 
@@ -206,56 +304,121 @@ func (h *Handler) RejectPartnerRequest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetRequest обрабатывает GET /admin/partner-requests/{id}
-func (h *Handler) GetRequest(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	if idStr == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+// RequestMoreInfo обрабатывает POST /admin/partner-requests/request-info, запрашивает у заявителя
+// недостающие сведения
+func (h *Handler) RequestMoreInfo(w http.ResponseWriter, r *http.Request) {
+	var req RequestInfoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	id, err := uuid.Parse(idStr)
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	err := h.admin.RequestMoreInfo(audit.ActorFromRequest(r), req.ID, req.Message, req.reason())
+	if err != nil {
+		writeReviewError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Additional information requested",
+		"id":      req.ID.String(),
+		"status":  "needs_info",
+	})
+}
+
+// GetComments обрабатывает GET /admin/partner-requests/{id}/comments, возвращает переписку по заявке
+func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		http.Error(w, "ID must be UUID", http.StatusBadRequest)
 		return
 	}
 
-	req, err := h.admin.GetRequest(id)
+	comments, err := h.admin.GetComments(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeCommentError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(req)
+	json.NewEncoder(w).Encode(comments)
 }
 
-// CreateAdmin обрабатывает POST /admin/create-admin, создаёт нового администратора
-func (h *Handler) CreateAdmin(w http.ResponseWriter, r *http.Request) {
-	// Получение claims из токена
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
-	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+// AddComment обрабатывает POST /admin/partner-requests/{id}/comments, добавляет сообщение в переписку по заявке
+func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
 		return
 	}
 
-	// Проверка, что текущий пользователь — админ
-	adminEmail, ok := claims["email"].(string)
-	if !ok || adminEmail == "" {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	isAdmin, err := h.admin.IsAdmin(adminEmail)
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	comment, err := h.admin.AddComment(audit.ActorFromRequest(r), id, req.Text)
 	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Ошибки переписки по заявке в HTTP ответ
+func writeCommentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPartnerRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrPartnerRequestClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// GetRequest обрабатывает GET /admin/partner-requests/{id}
+func (h *Handler) GetRequest(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	if idStr == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
 		return
 	}
-	if !isAdmin {
-		http.Error(w, "Forbidden: only admins can create new admins", http.StatusForbidden)
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
 		return
 	}
 
+	req, err := h.admin.GetRequest(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// CreateAdmin обрабатывает POST /admin/create-admin, создаёт нового администратора
+func (h *Handler) CreateAdmin(w http.ResponseWriter, r *http.Request) {
+	// Право создавать администраторов проверяется RequirePermission(rbac.PermAdminUsers) в роутере
 	var req struct {
 		Email   string `json:"email" validate:"required,email"`
 		Name    string `json:"name" validate:"required"`
@@ -272,7 +435,7 @@ func (h *Handler) CreateAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.admin.CreateAdmin(req.Email, req.Name, req.Surname)
+	err := h.admin.CreateAdmin(audit.ActorFromRequest(r), req.Email, req.Name, req.Surname)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -284,3 +447,75 @@ func (h *Handler) CreateAdmin(w http.ResponseWriter, r *http.Request) {
 		"email":   req.Email,
 	})
 }
+
+// SuspendCompany обрабатывает POST /admin/companies/{inn}/suspend, приостанавливает работу компании
+// и возвращает её предстоящие заказы
+func (h *Handler) SuspendCompany(w http.ResponseWriter, r *http.Request) {
+	var req SuspendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	resp, err := h.admin.SuspendCompany(audit.ActorFromRequest(r), chi.URLParam(r, "inn"), req.Reason)
+	if err != nil {
+		writeSuspensionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ReinstateCompany обрабатывает POST /admin/companies/{inn}/reinstate, возобновляет работу компании
+func (h *Handler) ReinstateCompany(w http.ResponseWriter, r *http.Request) {
+	var req ReinstateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	company, err := h.admin.ReinstateCompany(audit.ActorFromRequest(r), chi.URLParam(r, "inn"), req.Comment)
+	if err != nil {
+		writeSuspensionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(company)
+}
+
+// GetUpcomingOrders обрабатывает GET /admin/companies/{inn}/orders/upcoming, возвращает
+// предстоящие заказы компании
+func (h *Handler) GetUpcomingOrders(w http.ResponseWriter, r *http.Request) {
+	orders, err := h.admin.GetUpcomingOrders(chi.URLParam(r, "inn"))
+	if err != nil {
+		writeSuspensionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orders)
+}
+
+// Ошибки приостановки компании в HTTP ответ
+func writeSuspensionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrCompanyNotFound):
+		http.Error(w, "Company not found", http.StatusNotFound)
+	case errors.Is(err, ErrCompanyAlreadySuspended), errors.Is(err, ErrCompanyNotSuspended):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
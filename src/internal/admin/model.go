@@ -1,6 +1,7 @@
 package admin
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,8 +11,9 @@ import (
 type PartnerRequest struct {
 	ID uuid.UUID `json:"id" db:"id"`
 
-	Status    string `json:"status" db:"status" example:"new"`
-	UserEmail string `json:"user_email" db:"user_email" example:"email@mail.ru"`
+	Status       string `json:"status" db:"status" example:"new"`                                                               // new | pending | needs_info | approved | rejected
+	StatusReason string `json:"status_reason,omitempty" db:"status_reason" example:"Не указан КПП обособленного подразделения"` // причина отклонения или запроса сведений
+	UserEmail    string `json:"user_email" db:"user_email" example:"email@mail.ru"`
 
 	INN          string `json:"inn" db:"inn" example:"123456789012"`
 	KPP          string `json:"kpp" db:"kpp" example:"123456789"`
@@ -27,7 +29,21 @@ type PartnerRequest struct {
 	Info       string `json:"info,omitempty" db:"info" example:"Дополнительная информация"`
 
 	CreatedAt time.Time  `json:"created_at" example:"2026-03-30T06:06:47.181805Z" db:"created_at"`
-	LastUsed  *time.Time `json:"last_used" example:"2026-03-30T06:07:27.657019Z" db:"last_used"`
+	LastUsed  *time.Time `json:"last_used" example:"2026-03-30T06:07:27.657019Z" db:"last_used"` // время последней смены статуса
+
+	AssignedTo string     `json:"assigned_to,omitempty" db:"assigned_to" example:"admin@example.com"` // администратор, который рассматривает заявку
+	AssignedAt *time.Time `json:"assigned_at,omitempty" db:"assigned_at" example:"2026-03-30T06:07:27.657019Z"`
+
+	SLA *SLA `json:"sla,omitempty"` // только для заявок, ожидающих администратора (new, pending)
+}
+
+// SLA - сколько заявка ждёт решения администратора. Отсчёт идёт с последней смены статуса
+// и останавливается, пока у заявителя запрошены сведения
+type SLA struct {
+	WaitingSince   time.Time `json:"waiting_since" example:"2026-03-30T06:07:27Z"`
+	WaitingSeconds int64     `json:"waiting_seconds" example:"93600"`
+	DueAt          time.Time `json:"due_at" example:"2026-04-01T06:07:27Z"`
+	Breached       bool      `json:"breached" example:"false"` // срок рассмотрения истёк
 }
 
 // Company - данные организации
@@ -37,6 +53,39 @@ type Company struct {
 	OGRN         string `json:"ogrn" db:"ogrn"`
 	OrgName      string `json:"org_name" db:"org_name"`
 	OrgShortName string `json:"org_short_name" db:"org_short_name"`
+
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty" db:"suspended_at"` // работа приостановлена администратором
+	SuspensionReason string     `json:"suspension_reason,omitempty" db:"suspension_reason"`
+}
+
+// UpcomingOrder - предстоящий заказ компании, который нужно обработать после приостановки
+type UpcomingOrder struct {
+	ID          uuid.UUID  `json:"id" example:"83817fd0-ffd0-478b-b1ae-b082e8581830"`
+	Client      string     `json:"client" example:"client@mail.ru"`
+	BranchID    uuid.UUID  `json:"branch_id" example:"917e77fa-1672-4dfb-8507-d5755b31ebb3"`
+	City        string     `json:"city" example:"Москва"`
+	Address     string     `json:"address" example:"ул. Тверская, д. 1"`
+	Service     string     `json:"service" example:"Автомойка"`
+	StartMoment time.Time  `json:"start_moment" example:"2026-04-16T05:00:00Z"`
+	EndMoment   *time.Time `json:"end_moment,omitempty" example:"2026-04-16T05:20:00Z"`
+	Status      string     `json:"status" example:"approve"`
+	Sum         float32    `json:"sum" example:"1500"`
+}
+
+// SuspendRequest - приостановка компании. Причина отправляется сотрудникам компании на почту
+type SuspendRequest struct {
+	Reason string `json:"reason" example:"Компания прекратила работу" validate:"required,max=1000"`
+}
+
+// ReinstateRequest - возобновление работы компании. Комментарий (необязательный) отправляется сотрудникам на почту
+type ReinstateRequest struct {
+	Comment string `json:"comment,omitempty" example:"Работа возобновлена по заявлению компании" validate:"max=1000"`
+}
+
+// SuspendCompanyResponse - результат приостановки: компания и её предстоящие заказы
+type SuspendCompanyResponse struct {
+	Company        *Company        `json:"company"`
+	UpcomingOrders []UpcomingOrder `json:"upcoming_orders"`
 }
 
 // PartnerRequestRequest - запрос на создание заявки партнера
@@ -55,11 +104,75 @@ type PartnerRequestRequest struct {
 	Info       string `json:"info"`
 }
 
+// OverrideRequest - решение по заявке, назначенной другому администратору или никому не назначенной.
+// Причина обязательна и записывается в журнал действий
+type OverrideRequest struct {
+	Override       bool   `json:"override,omitempty" example:"false"`
+	OverrideReason string `json:"override_reason,omitempty" example:"Администратор в отпуске" validate:"required_if=Override true,max=1000"`
+}
+
+// Причина override, пустая строка - без override
+func (r OverrideRequest) reason() string {
+	if !r.Override {
+		return ""
+	}
+	return strings.TrimSpace(r.OverrideReason)
+}
+
 // ApprovePartnerRequest - запрос на одобрение заявки
 type ApprovePartnerRequest struct {
 	ID uuid.UUID `json:"id" validate:"required"`
+	OverrideRequest
+}
+
+// RejectPartnerRequest - запрос на отклонение заявки. Причина отправляется заявителю на почту
+type RejectPartnerRequest struct {
+	ID     uuid.UUID `json:"id" validate:"required"`
+	Reason string    `json:"reason" example:"Организация не оказывает услуги из каталога" validate:"required,max=1000"`
+	OverrideRequest
+}
+
+// RequestInfoRequest - запрос недостающих сведений у заявителя (pending -> needs_info)
+type RequestInfoRequest struct {
+	ID      uuid.UUID `json:"id" validate:"required"`
+	Message string    `json:"message" example:"Не указан КПП обособленного подразделения" validate:"required,max=1000"`
+	OverrideRequest
+}
+
+// AssignRequest - передача заявки другому администратору
+type AssignRequest struct {
+	ID    uuid.UUID `json:"id" validate:"required"`
+	Admin string    `json:"admin" example:"admin2@example.com" validate:"required,email"`
+	OverrideRequest
+}
+
+// ReleaseRequest - снятие назначения: заявка возвращается в общую очередь
+type ReleaseRequest struct {
+	ID uuid.UUID `json:"id" validate:"required"`
+	OverrideRequest
+}
+
+// Comment - сообщение в переписке администратора и заявителя по заявке
+type Comment struct {
+	ID         uuid.UUID `json:"id" example:"5b7e2c1a-9d3f-4e8a-b6c0-1f2a3b4c5d6e"`
+	RequestID  uuid.UUID `json:"request_id" example:"b1c2d3e4-f5a6-4b7c-8d9e-0f1a2b3c4d5e"`
+	Author     string    `json:"author" example:"admin@example.com"`
+	AuthorRole string    `json:"author_role" example:"admin"` // admin | applicant
+	Text       string    `json:"text" example:"Уточните, пожалуйста, адрес филиала"`
+	CreatedAt  time.Time `json:"created_at" example:"2026-03-30T06:06:47Z"`
 }
 
+// CommentRequest - новое сообщение в переписке по заявке
+type CommentRequest struct {
+	Text string `json:"text" example:"Уточните, пожалуйста, адрес филиала" validate:"required,max=2000"`
+}
+
+// Авторы сообщений в переписке по заявке
+const (
+	CommentAuthorAdmin     = "admin"
+	CommentAuthorApplicant = "applicant"
+)
+
 // Ошибки
 var (
 	ErrUserNotFound        = "user not found"
@@ -1,16 +1,40 @@
 package admin
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"src/internal/audit"
 	configPkg "src/internal/config"
+	"src/internal/db"
+	"src/internal/mail"
 
 	"github.com/google/uuid"
 )
 
+// Ошибки рассмотрения заявок
+var (
+	ErrPartnerRequestNotFound = errors.New("partner request not found")
+	ErrPartnerRequestClosed   = errors.New("partner request is already approved or rejected")
+
+	ErrPartnerRequestAssigned    = errors.New("partner request is assigned to another admin")
+	ErrPartnerRequestNotAssigned = errors.New("partner request is not assigned")
+	ErrNotAssignee               = errors.New("only the assigned admin can process this partner request, take it to work or set override with override_reason")
+	ErrPartnerRequestChanged     = errors.New("partner request was changed by another admin, reload it")
+	ErrAssigneeNotAdmin          = errors.New("assignee is not an admin")
+)
+
+// Ошибки приостановки компаний
+var (
+	ErrCompanyNotFound         = errors.New("company not found")
+	ErrCompanyAlreadySuspended = errors.New("company is already suspended")
+	ErrCompanyNotSuspended     = errors.New("company is not suspended")
+)
+
 type Config struct {
-	JWTSecretKey    string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	VerificationTTL time.Duration
@@ -23,8 +47,17 @@ type AdminManager struct {
 	companyStorage        CompanyStorage
 	partnersUsersStorage  PartnersUsersStorage
 	adminStorage          AdminStorage
-	emailSender           configPkg.EmailSender
+	emailSender           configPkg.TxEmailSender
+	roleRevoker           RoleRevoker
+	auditLog              audit.Recorder
 	config                Config
+	reviewConfig          configPkg.PartnerRequestConfig
+	uow                   db.UnitOfWork
+}
+
+// RoleRevoker отзывает access токены пользователя после изменения его роли
+type RoleRevoker interface {
+	Revoke(email string) error
 }
 
 // Создает новый экземпляр сервиса
@@ -34,8 +67,12 @@ func NewAdminManager(
 	companyStorage CompanyStorage,
 	partnersUsersStorage PartnersUsersStorage,
 	adminStorage AdminStorage,
-	emailSender configPkg.EmailSender,
+	emailSender configPkg.TxEmailSender,
+	roleRevoker RoleRevoker,
+	auditLog audit.Recorder,
 	config Config,
+	reviewConfig configPkg.PartnerRequestConfig,
+	uow db.UnitOfWork,
 ) *AdminManager {
 	return &AdminManager{
 		userStorage:           userStorage,
@@ -44,7 +81,11 @@ func NewAdminManager(
 		partnersUsersStorage:  partnersUsersStorage,
 		adminStorage:          adminStorage,
 		emailSender:           emailSender,
+		roleRevoker:           roleRevoker,
+		auditLog:              auditLog,
 		config:                config,
+		reviewConfig:          reviewConfig,
+		uow:                   uow,
 	}
 }
 
@@ -94,8 +135,9 @@ func (s *AdminManager) CreatePartnerRequest(userEmail string, req *PartnerReques
 	return nil
 }
 
-// Смена статуса заявки с "новая" на "в работе" (new -> pending)
-func (s *AdminManager) TakeRequestToWork(id uuid.UUID) error {
+// Назначение заявки себе. Новая заявка переходит в статус "в работе" (new -> pending).
+// Заявку, которую рассматривает другой администратор, взять нельзя - её можно только передать
+func (s *AdminManager) TakeRequestToWork(actor audit.Actor, id uuid.UUID) error {
 	// Получение заявки по ID
 	req, err := s.partnerRequestStorage.GetByID(id)
 	if err != nil {
@@ -105,21 +147,75 @@ func (s *AdminManager) TakeRequestToWork(id uuid.UUID) error {
 		return fmt.Errorf("request with id %s not found", id)
 	}
 
-	// Проверка, что заявка в статусе "new"
-	if req.Status != "new" {
+	// Проверка, что заявка не закрыта и никому не назначена
+	if req.Status != "new" && req.Status != "pending" && req.Status != "needs_info" {
 		return fmt.Errorf("request cannot be taken to work: current status is %s", req.Status)
 	}
+	if req.AssignedTo == actor.Email {
+		return nil
+	}
+	if req.AssignedTo != "" {
+		return ErrPartnerRequestAssigned
+	}
 
-	// Обновление статус на "pending"
-	if err := s.partnerRequestStorage.UpdateStatus(id, "pending"); err != nil {
-		return fmt.Errorf("failed to update request status: %w", err)
+	return s.assign(actor, audit.ActionPartnerRequestTake, req, actor.Email, "")
+}
+
+// Передача заявки другому администратору. Передать может тот, кому заявка назначена,
+// любой администратор, если заявка никому не назначена, или с override (причина override)
+func (s *AdminManager) ReassignRequest(actor audit.Actor, id uuid.UUID, admin, override string) error {
+	req, err := s.getOpenRequest(id)
+	if err != nil {
+		return err
+	}
+	if req.AssignedTo == "" {
+		// Никому не назначенную заявку передаёт любой администратор, override не нужен
+		override = ""
+	} else if err := s.checkAssignee(actor, req, override); err != nil {
+		return err
 	}
 
-	return nil
+	isAdmin, err := s.adminStorage.IsAdmin(admin)
+	if err != nil {
+		return fmt.Errorf("failed to check admin status: %w", err)
+	}
+	if !isAdmin {
+		return ErrAssigneeNotAdmin
+	}
+	if req.AssignedTo == admin {
+		return nil
+	}
+
+	return s.assign(actor, audit.ActionPartnerRequestAssign, req, admin, override)
 }
 
-// Одобрение заявки (pending -> approved)
-func (s *AdminManager) ApprovePartnerRequest(id uuid.UUID) error {
+// Снятие назначения: заявка возвращается в общую очередь, статус не меняется
+func (s *AdminManager) ReleaseRequest(actor audit.Actor, id uuid.UUID, override string) error {
+	req, err := s.getOpenRequest(id)
+	if err != nil {
+		return err
+	}
+	if req.AssignedTo == "" {
+		return ErrPartnerRequestNotAssigned
+	}
+	if err := s.checkAssignee(actor, req, override); err != nil {
+		return err
+	}
+
+	return s.assign(actor, audit.ActionPartnerRequestRelease, req, "", override)
+}
+
+// Получение незакрытых заявок, назначенных администратору
+func (s *AdminManager) GetMyRequests(email string) ([]*PartnerRequest, error) {
+	requests, err := s.partnerRequestStorage.GetByAssignee(email)
+	if err != nil {
+		return nil, err
+	}
+	return s.withSLA(requests), nil
+}
+
+// Одобрение заявки (pending -> approved). Доступно администратору, которому назначена заявка, или с override
+func (s *AdminManager) ApprovePartnerRequest(actor audit.Actor, id uuid.UUID, override string) error {
 	// Получение заявки по ID
 	req, err := s.partnerRequestStorage.GetByID(id)
 	if err != nil {
@@ -131,6 +227,9 @@ func (s *AdminManager) ApprovePartnerRequest(id uuid.UUID) error {
 	if req.Status != "pending" {
 		return fmt.Errorf("request already processed")
 	}
+	if err := s.checkAssignee(actor, req, override); err != nil {
+		return err
+	}
 
 	// Создание компании
 	company := &Company{
@@ -141,25 +240,39 @@ func (s *AdminManager) ApprovePartnerRequest(id uuid.UUID) error {
 		OrgShortName: req.OrgShortName,
 	}
 
-	if err := s.companyStorage.Create(company); err != nil {
-		return fmt.Errorf("failed to create company: %w", err)
-	}
-
-	// Создание нулевого пользователя компании
-	if err := s.partnersUsersStorage.Create(req.UserEmail, req.INN); err != nil {
-		return fmt.Errorf("failed to create partner user record: %w", err)
+	// Компания, её нулевой пользователь, статус заявки и запись в журнал меняются в одной транзакции:
+	// не остаётся компании без владельца или одобренной заявки без компании
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		// Статус меняется первым: условный UPDATE повторно проверяет статус и назначение заявки
+		// и блокирует её строку, поэтому одновременное одобрение получает ErrPartnerRequestChanged,
+		// а не создаёт компанию повторно
+		if err := s.updateStatus(tx, actor, audit.ActionPartnerRequestApprove, req, "approved", "", override); err != nil {
+			return err
+		}
+
+		if err := s.companyStorage.WithTx(tx).Create(company); err != nil {
+			return fmt.Errorf("failed to create company: %w", err)
+		}
+
+		// Создание нулевого пользователя компании
+		if err := s.partnersUsersStorage.WithTx(tx).Create(req.UserEmail, req.INN); err != nil {
+			return fmt.Errorf("failed to create partner user record: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Обновление статуса заявки
-	if err := s.partnerRequestStorage.UpdateStatus(id, "approved"); err != nil {
-		return fmt.Errorf("failed to update request status: %w", err)
-	}
+	// Пользователь стал партнёром - токен с ролью client нужно обновить
+	s.revokeRole(req.UserEmail)
 
 	return nil
 }
 
-// Отклонение заявки (pending -> rejected)
-func (s *AdminManager) RejectPartnerRequest(id uuid.UUID) error {
+// Отклонение заявки (pending, needs_info -> rejected). Причина отправляется заявителю на почту.
+// Доступно администратору, которому назначена заявка, или с override
+func (s *AdminManager) RejectPartnerRequest(actor audit.Actor, id uuid.UUID, reason, override string) error {
 	// Получение заявки по ID
 	req, err := s.partnerRequestStorage.GetByID(id)
 	if err != nil {
@@ -169,32 +282,105 @@ func (s *AdminManager) RejectPartnerRequest(id uuid.UUID) error {
 		return fmt.Errorf("request with id %s not found", id)
 	}
 
-	// Проверка, что заявка в статусе "pending"
-	if req.Status != "pending" {
+	// Проверка, что заявка в статусе "pending" или "needs_info"
+	if req.Status != "pending" && req.Status != "needs_info" {
 		return fmt.Errorf("request cannot be rejected: current status is %s", req.Status)
 	}
+	if err := s.checkAssignee(actor, req, override); err != nil {
+		return err
+	}
 
 	// Обновление статуса на "rejected"
-	if err := s.partnerRequestStorage.UpdateStatus(id, "rejected"); err != nil {
-		return fmt.Errorf("failed to update request status: %w", err)
+	return s.uow.Do(func(tx *sql.Tx) error {
+		return s.updateStatus(tx, actor, audit.ActionPartnerRequestReject, req, "rejected", reason, override)
+	})
+}
+
+// Запрос недостающих сведений у заявителя (pending -> needs_info). Заявитель исправляет заявку
+// и отправляет её повторно через POST /partner/request. Сообщение также добавляется в переписку
+func (s *AdminManager) RequestMoreInfo(actor audit.Actor, id uuid.UUID, message, override string) error {
+	req, err := s.partnerRequestStorage.GetByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to get request: %w", err)
+	}
+	if req == nil {
+		return fmt.Errorf("request with id %s not found", id)
 	}
 
-	return nil
+	if req.Status != "pending" {
+		return fmt.Errorf("cannot request information: current status is %s", req.Status)
+	}
+	if err := s.checkAssignee(actor, req, override); err != nil {
+		return err
+	}
+
+	// Сообщение в переписке и смена статуса сохраняются вместе
+	return s.uow.Do(func(tx *sql.Tx) error {
+		storage := s.partnerRequestStorage.WithTx(tx)
+		comment := &Comment{RequestID: id, Author: actor.Email, AuthorRole: CommentAuthorAdmin, Text: message}
+		if err := storage.AddComment(comment); err != nil {
+			return err
+		}
+		return s.updateStatus(tx, actor, audit.ActionPartnerRequestRequestInfo, req, "needs_info", message, override)
+	})
+}
+
+// Получение переписки по заявке
+func (s *AdminManager) GetComments(id uuid.UUID) ([]Comment, error) {
+	if _, err := s.getRequest(id); err != nil {
+		return nil, err
+	}
+	return s.partnerRequestStorage.GetComments(id)
+}
+
+// Добавление сообщения администратора в переписку по заявке. По закрытой заявке писать нельзя
+func (s *AdminManager) AddComment(actor audit.Actor, id uuid.UUID, text string) (*Comment, error) {
+	req, err := s.getRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status == "approved" || req.Status == "rejected" {
+		return nil, ErrPartnerRequestClosed
+	}
+
+	comment := &Comment{RequestID: id, Author: actor.Email, AuthorRole: CommentAuthorAdmin, Text: text}
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		if err := s.partnerRequestStorage.WithTx(tx).AddComment(comment); err != nil {
+			return err
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionPartnerRequestComment, audit.TargetPartnerRequest, id.String(), nil, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
 }
 
 // Получение заявок по статусу
 func (s *AdminManager) GetRequestsByStatus(status string) ([]*PartnerRequest, error) {
-	return s.partnerRequestStorage.GetByStatus(status)
+	requests, err := s.partnerRequestStorage.GetByStatus(status)
+	if err != nil {
+		return nil, err
+	}
+	return s.withSLA(requests), nil
 }
 
 // Получение всех заявок
 func (s *AdminManager) GetAllRequests() ([]*PartnerRequest, error) {
-	return s.partnerRequestStorage.GetAll()
+	requests, err := s.partnerRequestStorage.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return s.withSLA(requests), nil
 }
 
 // Получение всех заявок в работе
 func (s *AdminManager) GetPendingRequests() ([]*PartnerRequest, error) {
-	return s.partnerRequestStorage.GetPending()
+	requests, err := s.partnerRequestStorage.GetPending()
+	if err != nil {
+		return nil, err
+	}
+	return s.withSLA(requests), nil
 }
 
 // Получение статуса заявки по ИНН
@@ -208,11 +394,12 @@ func (s *AdminManager) GetRequest(id uuid.UUID) (*PartnerRequest, error) {
 		return nil, fmt.Errorf("request with id %s not found", id)
 	}
 
+	s.withSLA([]*PartnerRequest{req})
 	return req, nil
 }
 
 // CreateAdmin создаёт нового администратора
-func (s *AdminManager) CreateAdmin(email, name, surname string) error {
+func (s *AdminManager) CreateAdmin(actor audit.Actor, email, name, surname string) error {
 	// Проверка, что поля не пустые
 	if email == "" {
 		return fmt.Errorf("email is required")
@@ -242,9 +429,299 @@ func (s *AdminManager) CreateAdmin(email, name, surname string) error {
 		return fmt.Errorf("user is already an admin")
 	}
 
-	if err := s.adminStorage.Create(email, name, surname); err != nil {
-		return fmt.Errorf("failed to create admin: %w", err)
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		if err := s.adminStorage.WithTx(tx).Create(email, name, surname); err != nil {
+			return fmt.Errorf("failed to create admin: %w", err)
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionAdminCreate, audit.TargetAdmin, email,
+			nil, Admin{Email: email, Name: name, Surname: surname})
+	})
+	if err != nil {
+		return err
 	}
 
+	s.revokeRole(email)
+
+	return nil
+}
+
+// Получение незакрытой заявки по ID
+func (s *AdminManager) getOpenRequest(id uuid.UUID) (*PartnerRequest, error) {
+	req, err := s.getRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status == "approved" || req.Status == "rejected" {
+		return nil, ErrPartnerRequestClosed
+	}
+	return req, nil
+}
+
+// Проверка, что заявка назначена администратору. Решение по чужой или никому не назначенной
+// заявке разрешено только с причиной override, она записывается в журнал действий (recordOverride)
+func (s *AdminManager) checkAssignee(actor audit.Actor, req *PartnerRequest, override string) error {
+	if req.AssignedTo == actor.Email {
+		return nil
+	}
+	if override == "" {
+		return ErrNotAssignee
+	}
+	return nil
+}
+
+// Снимок override в журнале действий: какое действие выполнено по чужой заявке и почему
+type overrideRecord struct {
+	Action     string `json:"action"`
+	AssignedTo string `json:"assigned_to"`
+	Reason     string `json:"reason"`
+}
+
+// Запись override в журнал действий в транзакции tx, в которой выполняется действие action.
+// Если заявка назначена самому администратору, override не записывается
+func (s *AdminManager) recordOverride(tx *sql.Tx, actor audit.Actor, action string, req *PartnerRequest, override string) error {
+	if override == "" || req.AssignedTo == actor.Email {
+		return nil
+	}
+	return s.auditLog.Record(tx, actor, audit.ActionPartnerRequestOverride, audit.TargetPartnerRequest, req.ID.String(),
+		nil, overrideRecord{Action: action, AssignedTo: req.AssignedTo, Reason: override})
+}
+
+// Назначение заявки администратору admin (пустая строка - снятие назначения).
+// Новая заявка при назначении переходит в "pending", заявитель получает письмо
+func (s *AdminManager) assign(actor audit.Actor, action string, req *PartnerRequest, admin, override string) error {
+	now := time.Now()
+	after := *req
+	after.AssignedTo = admin
+	after.AssignedAt = &now
+	if admin == "" {
+		after.AssignedAt = nil
+	}
+	if req.Status == "new" && admin != "" {
+		after.Status = "pending"
+		after.LastUsed = &now
+	}
+
+	return s.uow.Do(func(tx *sql.Tx) error {
+		assigned, err := s.partnerRequestStorage.WithTx(tx).Assign(req.ID, admin, req.AssignedTo)
+		if err != nil {
+			return err
+		}
+		if !assigned {
+			// Заявку успели взять, передать или закрыть
+			return ErrPartnerRequestAssigned
+		}
+		if err := s.recordOverride(tx, actor, action, req, override); err != nil {
+			return err
+		}
+		if after.Status != req.Status {
+			if err := s.notifyApplicant(tx, &after); err != nil {
+				return err
+			}
+		}
+		return s.auditLog.Record(tx, actor, action, audit.TargetPartnerRequest, req.ID.String(), req, after)
+	})
+}
+
+// Расчёт SLA для заявок, ожидающих решения администратора
+func (s *AdminManager) withSLA(requests []*PartnerRequest) []*PartnerRequest {
+	now := time.Now()
+	for _, req := range requests {
+		if req.Status != "new" && req.Status != "pending" {
+			continue
+		}
+
+		since := req.CreatedAt
+		if req.LastUsed != nil {
+			since = *req.LastUsed
+		}
+		due := since.Add(s.reviewConfig.SLA)
+		req.SLA = &SLA{
+			WaitingSince:   since,
+			WaitingSeconds: int64(now.Sub(since).Seconds()),
+			DueAt:          due,
+			Breached:       now.After(due),
+		}
+	}
+	return requests
+}
+
+// Получение заявки по ID. Если заявки нет, возвращает ErrPartnerRequestNotFound
+func (s *AdminManager) getRequest(id uuid.UUID) (*PartnerRequest, error) {
+	req, err := s.partnerRequestStorage.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+	if req == nil {
+		return nil, ErrPartnerRequestNotFound
+	}
+	return req, nil
+}
+
+// SuspendCompany приостанавливает работу компании: филиалы скрываются из поиска, новые заказы
+// и запросы по API ключам не принимаются. Уже оформленные заказы остаются в силе и возвращаются
+// для обработки. Причина отправляется сотрудникам компании на почту
+func (s *AdminManager) SuspendCompany(actor audit.Actor, inn, reason string) (*SuspendCompanyResponse, error) {
+	company, err := s.getCompany(inn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Приостановка и письма сотрудникам сохраняются в одной транзакции
+	var after *Company
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		storage := s.companyStorage.WithTx(tx)
+		suspended, err := storage.Suspend(inn, reason)
+		if err != nil {
+			return fmt.Errorf("failed to suspend company: %w", err)
+		}
+		if !suspended {
+			return ErrCompanyAlreadySuspended
+		}
+
+		after, err = storage.GetByINN(inn)
+		if err != nil {
+			return fmt.Errorf("failed to get company: %w", err)
+		}
+		data := mail.SuspensionData{CompanyName: after.OrgShortName, Reason: reason, At: *after.SuspendedAt}
+		if err := s.notifyMembers(tx, after, data, true); err != nil {
+			return err
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionCompanySuspend, audit.TargetCompany, inn, company, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("admin: company %s suspended by %s", inn, actor.Email)
+
+	orders, err := s.companyStorage.GetUpcomingOrders(inn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SuspendCompanyResponse{Company: after, UpcomingOrders: orders}, nil
+}
+
+// ReinstateCompany возобновляет работу компании. comment (необязательный) отправляется сотрудникам на почту
+func (s *AdminManager) ReinstateCompany(actor audit.Actor, inn, comment string) (*Company, error) {
+	company, err := s.getCompany(inn)
+	if err != nil {
+		return nil, err
+	}
+
+	after := *company
+	after.SuspendedAt = nil
+	after.SuspensionReason = ""
+
+	// Возобновление и письма сотрудникам сохраняются в одной транзакции
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		reinstated, err := s.companyStorage.WithTx(tx).Reinstate(inn)
+		if err != nil {
+			return fmt.Errorf("failed to reinstate company: %w", err)
+		}
+		if !reinstated {
+			return ErrCompanyNotSuspended
+		}
+		data := mail.SuspensionData{CompanyName: after.OrgShortName, Reason: comment, At: time.Now()}
+		if err := s.notifyMembers(tx, &after, data, false); err != nil {
+			return err
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionCompanyReinstate, audit.TargetCompany, inn, company, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("admin: company %s reinstated by %s", inn, actor.Email)
+
+	return &after, nil
+}
+
+// GetUpcomingOrders возвращает предстоящие заказы компании
+func (s *AdminManager) GetUpcomingOrders(inn string) ([]UpcomingOrder, error) {
+	if _, err := s.getCompany(inn); err != nil {
+		return nil, err
+	}
+	return s.companyStorage.GetUpcomingOrders(inn)
+}
+
+// Получение компании по ИНН. Если компании нет, возвращает ErrCompanyNotFound
+func (s *AdminManager) getCompany(inn string) (*Company, error) {
+	company, err := s.companyStorage.GetByINN(inn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company: %w", err)
+	}
+	if company == nil {
+		return nil, ErrCompanyNotFound
+	}
+	return company, nil
+}
+
+// Письмо о приостановке или возобновлении всем сотрудникам компании.
+// Письма ставятся в очередь в транзакции смены статуса компании
+func (s *AdminManager) notifyMembers(tx *sql.Tx, company *Company, data mail.SuspensionData, suspended bool) error {
+	emails, err := s.companyStorage.WithTx(tx).GetMemberEmails(company.INN)
+	if err != nil {
+		return fmt.Errorf("failed to get members of company: %w", err)
+	}
+
+	sender := s.emailSender.WithTx(tx)
+	send := sender.SendAccountReinstated
+	if suspended {
+		send = sender.SendAccountSuspended
+	}
+	for _, email := range emails {
+		if err := send(email, data); err != nil {
+			return fmt.Errorf("failed to queue company status email for %s: %w", email, err)
+		}
+	}
+	return nil
+}
+
+// Отзыв токенов после изменения роли. Роль уже изменена, поэтому ошибка только логируется:
+// старый токен перестанет действовать по истечении срока
+func (s *AdminManager) revokeRole(email string) {
+	if err := s.roleRevoker.Revoke(email); err != nil {
+		log.Printf("admin: failed to revoke tokens of %s after role change: %v", email, err)
+	}
+}
+
+// Смена статуса заявки в транзакции tx вместе с письмом заявителю и записью в журнал действий
+// (снимок заявки до и после, override - отдельной записью). Если статус или назначение заявки
+// изменились с чтения req, возвращает ErrPartnerRequestChanged
+func (s *AdminManager) updateStatus(tx *sql.Tx, actor audit.Actor, action string, req *PartnerRequest, status, reason, override string) error {
+	updated, err := s.partnerRequestStorage.WithTx(tx).UpdateStatus(req.ID, status, reason, req.Status, req.AssignedTo)
+	if err != nil {
+		return fmt.Errorf("failed to update request status: %w", err)
+	}
+	if !updated {
+		// Заявку успели рассмотреть, передать или заявитель отправил её повторно
+		return ErrPartnerRequestChanged
+	}
+	if err := s.recordOverride(tx, actor, action, req, override); err != nil {
+		return err
+	}
+
+	after := withStatus(req, status, reason)
+	if err := s.notifyApplicant(tx, after); err != nil {
+		return err
+	}
+	return s.auditLog.Record(tx, actor, action, audit.TargetPartnerRequest, req.ID.String(), req, after)
+}
+
+// Снимок заявки с новым статусом
+func withStatus(req *PartnerRequest, status, reason string) *PartnerRequest {
+	after := *req
+	after.Status = status
+	after.StatusReason = reason
+	return &after
+}
+
+// Письмо заявителю о новом статусе заявки. Ставится в очередь в транзакции смены статуса
+func (s *AdminManager) notifyApplicant(tx *sql.Tx, req *PartnerRequest) error {
+	data := mail.PartnerRequestData{OrgShortName: req.OrgShortName, Status: req.Status, Reason: req.StatusReason}
+	if err := s.emailSender.WithTx(tx).SendPartnerRequestStatus(req.UserEmail, data); err != nil {
+		return fmt.Errorf("failed to queue partner request status email: %w", err)
+	}
 	return nil
 }
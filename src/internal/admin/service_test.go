@@ -0,0 +1,307 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	configPkg "src/internal/config"
+	"src/internal/mail"
+	"src/internal/middleware"
+)
+
+// Заявки и переписка в памяти. onGet вызывается после чтения заявки и имитирует действия другого администратора
+type memoryRequests struct {
+	PartnerRequestStorage
+	requests map[uuid.UUID]*PartnerRequest
+	comments []Comment
+	onGet    func(req *PartnerRequest)
+}
+
+func (s *memoryRequests) WithTx(tx *sql.Tx) PartnerRequestStorage {
+	return s
+}
+
+func (s *memoryRequests) GetByID(id uuid.UUID) (*PartnerRequest, error) {
+	req, ok := s.requests[id]
+	if !ok {
+		return nil, nil
+	}
+	copy := *req
+	if s.onGet != nil {
+		s.onGet(req)
+	}
+	return &copy, nil
+}
+
+func (s *memoryRequests) UpdateStatus(id uuid.UUID, status, reason, currentStatus, currentAssignee string) (bool, error) {
+	req, ok := s.requests[id]
+	if !ok || req.Status != currentStatus || req.AssignedTo != currentAssignee {
+		return false, nil
+	}
+	req.Status, req.StatusReason = status, reason
+	return true, nil
+}
+
+func (s *memoryRequests) AddComment(comment *Comment) error {
+	s.comments = append(s.comments, *comment)
+	return nil
+}
+
+func (s *memoryRequests) Assign(id uuid.UUID, admin, current string) (bool, error) {
+	req, ok := s.requests[id]
+	if !ok || req.AssignedTo != current {
+		return false, nil
+	}
+	req.AssignedTo = admin
+	return true, nil
+}
+
+// Журнал действий в памяти. err - ошибка записи, которая должна отменить действие
+type recordingAudit struct {
+	entries []recordedEntry
+	err     error
+}
+
+type recordedEntry struct {
+	actor    string
+	action   string
+	targetID string
+	after    any
+}
+
+func (a *recordingAudit) Record(tx *sql.Tx, actor audit.Actor, action, targetType, targetID string, before, after any) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.entries = append(a.entries, recordedEntry{actor: actor.Email, action: action, targetID: targetID, after: after})
+	return nil
+}
+
+func (a *recordingAudit) actions() []string {
+	var actions []string
+	for _, entry := range a.entries {
+		actions = append(actions, entry.action)
+	}
+	return actions
+}
+
+// Письма заявителям в памяти
+type recordingSender struct {
+	configPkg.EmailSender
+	sent []mail.PartnerRequestData
+}
+
+func (s *recordingSender) WithTx(tx *sql.Tx) configPkg.EmailSender {
+	return s
+}
+
+func (s *recordingSender) SendPartnerRequestStatus(toEmail string, data mail.PartnerRequestData) error {
+	s.sent = append(s.sent, data)
+	return nil
+}
+
+// Выполняет fn без транзакции
+type directUnitOfWork struct{}
+
+func (directUnitOfWork) Do(fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+var (
+	assignee = audit.Actor{Email: "assignee@example.com", IP: "203.0.113.7"}
+	other    = audit.Actor{Email: "other@example.com", IP: "203.0.113.8"}
+)
+
+type reviewFixture struct {
+	manager  *AdminManager
+	requests *memoryRequests
+	audit    *recordingAudit
+	sender   *recordingSender
+	req      *PartnerRequest
+}
+
+// Заявка в работе у assignee
+func newReviewFixture() *reviewFixture {
+	req := &PartnerRequest{ID: uuid.New(), Status: "pending", UserEmail: "applicant@example.com", INN: "7700000000", OrgShortName: "ООО Ромашка", AssignedTo: assignee.Email}
+	f := &reviewFixture{
+		requests: &memoryRequests{requests: map[uuid.UUID]*PartnerRequest{req.ID: req}},
+		audit:    &recordingAudit{},
+		sender:   &recordingSender{},
+		req:      req,
+	}
+	f.manager = &AdminManager{partnerRequestStorage: f.requests, emailSender: f.sender, auditLog: f.audit, uow: directUnitOfWork{}}
+	return f
+}
+
+// Причина отклонения сохраняется в заявке и уходит заявителю в письме
+func TestRejectPartnerRequest(t *testing.T) {
+	f := newReviewFixture()
+
+	if err := f.manager.RejectPartnerRequest(assignee, f.req.ID, "Нет КПП", ""); err != nil {
+		t.Fatalf("RejectPartnerRequest: %v", err)
+	}
+	if f.req.Status != "rejected" || f.req.StatusReason != "Нет КПП" {
+		t.Errorf("request = %+v", f.req)
+	}
+	want := mail.PartnerRequestData{OrgShortName: "ООО Ромашка", Status: "rejected", Reason: "Нет КПП"}
+	if len(f.sender.sent) != 1 || f.sender.sent[0] != want {
+		t.Errorf("emails = %+v, want %+v", f.sender.sent, want)
+	}
+	if !slices.Equal(f.audit.actions(), []string{audit.ActionPartnerRequestReject}) {
+		t.Errorf("audit actions = %v", f.audit.actions())
+	}
+
+	if err := f.manager.RejectPartnerRequest(assignee, f.req.ID, "again", ""); err == nil {
+		t.Error("rejected request was rejected again")
+	}
+}
+
+// Запрос сведений переводит заявку в needs_info и добавляет сообщение в переписку
+func TestRequestMoreInfo(t *testing.T) {
+	f := newReviewFixture()
+
+	if err := f.manager.RequestMoreInfo(assignee, f.req.ID, "Приложите выписку ЕГРЮЛ", ""); err != nil {
+		t.Fatalf("RequestMoreInfo: %v", err)
+	}
+	if f.req.Status != "needs_info" || f.req.StatusReason != "Приложите выписку ЕГРЮЛ" {
+		t.Errorf("request = %+v", f.req)
+	}
+	if len(f.requests.comments) != 1 || f.requests.comments[0].AuthorRole != CommentAuthorAdmin || f.requests.comments[0].Author != assignee.Email {
+		t.Errorf("comments = %+v", f.requests.comments)
+	}
+	if len(f.sender.sent) != 1 || f.sender.sent[0].Status != "needs_info" {
+		t.Errorf("emails = %+v", f.sender.sent)
+	}
+
+	// По заявке в needs_info администратор может писать, по закрытой - нет
+	if _, err := f.manager.AddComment(assignee, f.req.ID, "Жду документы"); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if err := f.manager.RejectPartnerRequest(assignee, f.req.ID, "Документы не получены", ""); err != nil {
+		t.Fatalf("RejectPartnerRequest: %v", err)
+	}
+	if _, err := f.manager.AddComment(assignee, f.req.ID, "late"); !errors.Is(err, ErrPartnerRequestClosed) {
+		t.Errorf("AddComment to closed request error = %v, want ErrPartnerRequestClosed", err)
+	}
+
+	want := []string{audit.ActionPartnerRequestRequestInfo, audit.ActionPartnerRequestComment, audit.ActionPartnerRequestReject}
+	if !slices.Equal(f.audit.actions(), want) {
+		t.Errorf("audit actions = %v, want %v", f.audit.actions(), want)
+	}
+}
+
+func TestOverrideRequiresReason(t *testing.T) {
+	f := newReviewFixture()
+
+	if err := f.manager.RejectPartnerRequest(other, f.req.ID, "Нет КПП", ""); !errors.Is(err, ErrNotAssignee) {
+		t.Fatalf("RejectPartnerRequest error = %v, want ErrNotAssignee", err)
+	}
+	if err := f.manager.ReleaseRequest(other, f.req.ID, ""); !errors.Is(err, ErrNotAssignee) {
+		t.Fatalf("ReleaseRequest error = %v, want ErrNotAssignee", err)
+	}
+	if f.req.Status != "pending" || f.req.AssignedTo != assignee.Email || len(f.audit.entries) != 0 {
+		t.Errorf("request = %+v, audit = %v", f.req, f.audit.actions())
+	}
+}
+
+// Причина override записывается в журнал отдельной записью в транзакции действия
+func TestOverrideIsAudited(t *testing.T) {
+	f := newReviewFixture()
+
+	if err := f.manager.RejectPartnerRequest(other, f.req.ID, "Нет КПП", "Администратор в отпуске"); err != nil {
+		t.Fatalf("RejectPartnerRequest: %v", err)
+	}
+	if f.req.Status != "rejected" || len(f.sender.sent) != 1 {
+		t.Errorf("status = %q, sent = %d", f.req.Status, len(f.sender.sent))
+	}
+
+	want := []string{audit.ActionPartnerRequestOverride, audit.ActionPartnerRequestReject}
+	if got := f.audit.actions(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("audit = %v, want %v", got, want)
+	}
+	override := f.audit.entries[0]
+	record, ok := override.after.(overrideRecord)
+	if !ok || override.actor != other.Email || override.targetID != f.req.ID.String() ||
+		record != (overrideRecord{Action: audit.ActionPartnerRequestReject, AssignedTo: assignee.Email, Reason: "Администратор в отпуске"}) {
+		t.Errorf("override entry = %+v", override)
+	}
+}
+
+func TestOverrideReleaseIsAudited(t *testing.T) {
+	f := newReviewFixture()
+
+	if err := f.manager.ReleaseRequest(other, f.req.ID, "Заявка зависла"); err != nil {
+		t.Fatalf("ReleaseRequest: %v", err)
+	}
+	want := []string{audit.ActionPartnerRequestOverride, audit.ActionPartnerRequestRelease}
+	if got := f.audit.actions(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("audit = %v, want %v", got, want)
+	}
+}
+
+// Своя заявка рассматривается без записи override, даже если причина передана
+func TestAssigneeDoesNotRecordOverride(t *testing.T) {
+	f := newReviewFixture()
+
+	if err := f.manager.RejectPartnerRequest(assignee, f.req.ID, "Нет КПП", "не нужно"); err != nil {
+		t.Fatalf("RejectPartnerRequest: %v", err)
+	}
+	if got := f.audit.actions(); len(got) != 1 || got[0] != audit.ActionPartnerRequestReject {
+		t.Errorf("audit = %v", got)
+	}
+}
+
+// Если заявку изменили между чтением и сменой статуса, решение не применяется
+func TestUpdateStatusConflict(t *testing.T) {
+	tests := map[string]func(req *PartnerRequest){
+		"reassigned": func(req *PartnerRequest) { req.AssignedTo = other.Email },
+		"rejected":   func(req *PartnerRequest) { req.Status = "rejected" },
+		"needs info": func(req *PartnerRequest) { req.Status = "needs_info" },
+		"unassigned": func(req *PartnerRequest) { req.AssignedTo = "" },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newReviewFixture()
+			f.requests.onGet = change
+
+			err := f.manager.RejectPartnerRequest(assignee, f.req.ID, "Нет КПП", "")
+			if !errors.Is(err, ErrPartnerRequestChanged) {
+				t.Fatalf("RejectPartnerRequest error = %v, want ErrPartnerRequestChanged", err)
+			}
+			if len(f.audit.entries) != 0 || len(f.sender.sent) != 0 {
+				t.Errorf("audit = %v, sent = %d", f.audit.actions(), len(f.sender.sent))
+			}
+		})
+	}
+}
+
+func TestOverrideReasonValidation(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name   string
+		req    OverrideRequest
+		valid  bool
+		reason string
+	}{
+		{"no override", OverrideRequest{}, true, ""},
+		{"reason without override", OverrideRequest{OverrideReason: "в отпуске"}, true, ""},
+		{"override without reason", OverrideRequest{Override: true}, false, ""},
+		{"override", OverrideRequest{Override: true, OverrideReason: " в отпуске "}, true, "в отпуске"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := middleware.ValidateStruct(ReleaseRequest{ID: id, OverrideRequest: tt.req})
+			if (err == nil) != tt.valid {
+				t.Fatalf("ValidateStruct error = %v, want valid %v", err, tt.valid)
+			}
+			if tt.valid && tt.req.reason() != tt.reason {
+				t.Errorf("reason() = %q, want %q", tt.req.reason(), tt.reason)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"src/internal/auth"
+	"src/internal/db"
+	"src/internal/rbac"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -26,7 +28,18 @@ type PartnerRequestStorage interface {
 	GetByStatus(status string) ([]*PartnerRequest, error)
 	GetPending() ([]*PartnerRequest, error)
 	GetAll() ([]*PartnerRequest, error)
-	UpdateStatus(id uuid.UUID, status string) error
+	GetByAssignee(email string) ([]*PartnerRequest, error)
+	// Assign назначает заявку администратору admin (пустая строка - снимает назначение),
+	// если сейчас она назначена current. Возвращает false, если заявка закрыта или назначена другому
+	Assign(id uuid.UUID, admin, current string) (bool, error)
+	// UpdateStatus меняет статус заявки. reason - причина отклонения или запроса сведений,
+	// пустая строка очищает её. Заявка меняется, только если её статус и назначение не изменились
+	// с чтения (currentStatus, currentAssignee), иначе возвращает false
+	UpdateStatus(id uuid.UUID, status, reason, currentStatus, currentAssignee string) (bool, error)
+	AddComment(comment *Comment) error
+	GetComments(requestID uuid.UUID) ([]Comment, error)
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) PartnerRequestStorage
 	//Delete(inn string) error
 }
 
@@ -35,11 +48,19 @@ type CompanyStorage interface {
 	Create(company *Company) error
 	GetByINN(inn string) (*Company, error)
 	Exists(inn string) (bool, error)
+	// Suspend приостанавливает компанию, Reinstate возобновляет.
+	// Возвращают false, если компания уже в нужном состоянии
+	Suspend(inn, reason string) (bool, error)
+	Reinstate(inn string) (bool, error)
+	GetMemberEmails(inn string) ([]string, error)
+	GetUpcomingOrders(inn string) ([]UpcomingOrder, error)
+	WithTx(tx *sql.Tx) CompanyStorage
 }
 
 // PartnersUsersStorage интерфейс для работы с таблицей partners_users
 type PartnersUsersStorage interface {
 	Create(email, inn string) error
+	WithTx(tx *sql.Tx) PartnersUsersStorage
 }
 
 // AdminStorage интерфейс для работы с админами
@@ -47,6 +68,7 @@ type AdminStorage interface {
 	IsAdmin(email string) (bool, error)
 	GetByEmail(email string) (*Admin, error)
 	Create(email, name, surname string) error
+	WithTx(tx *sql.Tx) AdminStorage
 }
 
 // Admin структура для таблицы admin
@@ -74,13 +96,18 @@ func (s *PostgresAdminStorage) Create(email, name, surname string) error {
 
 // PostgresAdminStorage реализация для PostgreSQL
 type PostgresAdminStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresAdminStorage(db *sql.DB) *PostgresAdminStorage {
 	return &PostgresAdminStorage{db: db}
 }
 
+// WithTx возвращает AdminStorage, работающий внутри транзакции tx
+func (s *PostgresAdminStorage) WithTx(tx *sql.Tx) AdminStorage {
+	return &PostgresAdminStorage{db: tx}
+}
+
 // Проверка на админа
 func (s *PostgresAdminStorage) IsAdmin(email string) (bool, error) {
 	var exists bool
@@ -111,13 +138,18 @@ func (s *PostgresAdminStorage) GetByEmail(email string) (*Admin, error) {
 }
 
 type PostgresPartnerRequestStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresPartnerRequestStorage(db *sql.DB) *PostgresPartnerRequestStorage {
 	return &PostgresPartnerRequestStorage{db: db}
 }
 
+// WithTx возвращает PartnerRequestStorage, работающий внутри транзакции tx
+func (s *PostgresPartnerRequestStorage) WithTx(tx *sql.Tx) PartnerRequestStorage {
+	return &PostgresPartnerRequestStorage{db: tx}
+}
+
 // Создание заявки на подключение организации
 func (s *PostgresPartnerRequestStorage) Create(req *PartnerRequest) error {
 	query := `
@@ -139,101 +171,136 @@ func (s *PostgresPartnerRequestStorage) Create(req *PartnerRequest) error {
 
 // Получение информации из заявки по ID
 func (s *PostgresPartnerRequestStorage) GetByID(id uuid.UUID) (*PartnerRequest, error) {
-	var req PartnerRequest
-	query := `SELECT id, status, user_email, inn, kpp, ogrn, org_name, org_short_name,
-                     name, surname, patronymic, email, phone_number, info, created_at, last_used
-              FROM part_req WHERE id = $1`
-
-	err := s.db.QueryRow(query, id).Scan(
-		&req.ID, &req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
-		&req.OrgName, &req.OrgShortName,
-		&req.Name, &req.Surname, &req.Patronymic,
-		&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
-	)
+	query := `SELECT ` + partnerRequestColumns + ` FROM part_req WHERE id = $1`
 
+	req, err := scanPartnerRequest(s.db.QueryRow(query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &req, nil
+	return req, nil
 }
 
-// Обновление статуса у заявки
-func (s *PostgresPartnerRequestStorage) UpdateStatus(id uuid.UUID, status string) error {
-	query := `UPDATE part_req SET status = $1, last_used = NOW() WHERE id = $2`
-	result, err := s.db.Exec(query, status, id)
+// Обновление статуса у заявки. Статус меняется, только если заявка в статусе currentStatus
+// и назначена currentAssignee (пустая строка - никому), иначе возвращает false
+func (s *PostgresPartnerRequestStorage) UpdateStatus(id uuid.UUID, status, reason, currentStatus, currentAssignee string) (bool, error) {
+	query := `
+        UPDATE part_req SET status = $1, status_reason = NULLIF($2, ''), last_used = NOW()
+        WHERE id = $3 AND status = $4 AND assigned_to IS NOT DISTINCT FROM NULLIF($5::text, '')
+    `
+	result, err := s.db.Exec(query, status, reason, id, currentStatus, currentAssignee)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("failed to update partner request status: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("partner request with id %s not found", id)
+	return rowsAffected > 0, nil
+}
+
+// Добавление сообщения в переписку по заявке
+func (s *PostgresPartnerRequestStorage) AddComment(comment *Comment) error {
+	query := `INSERT INTO part_req_comments (request_id, author, author_role, text)
+              VALUES ($1, $2, $3, $4)
+              RETURNING id, created_at`
+
+	err := s.db.QueryRow(query, comment.RequestID, comment.Author, comment.AuthorRole, comment.Text).
+		Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add comment: %w", err)
 	}
 	return nil
 }
 
-// Получение заявок в работе
-func (s *PostgresPartnerRequestStorage) GetPending() ([]*PartnerRequest, error) {
-	rows, err := s.db.Query(`SELECT id, status, user_email, inn, kpp, ogrn, org_name, org_short_name,
-                                     name, surname, patronymic, email, phone_number, info, created_at, last_used 
-                              FROM part_req WHERE status = 'pending'`)
+// Получение переписки по заявке, начиная со старых сообщений
+func (s *PostgresPartnerRequestStorage) GetComments(requestID uuid.UUID) ([]Comment, error) {
+	rows, err := s.db.Query(`SELECT id, request_id, author, author_role, text, created_at
+                              FROM part_req_comments WHERE request_id = $1
+                              ORDER BY created_at`, requestID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get comments: %w", err)
 	}
 	defer rows.Close()
 
-	var requests []*PartnerRequest
+	comments := []Comment{}
 	for rows.Next() {
-		var req PartnerRequest
-		err := rows.Scan(
-			&req.ID, &req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
-			&req.OrgName, &req.OrgShortName,
-			&req.Name, &req.Surname, &req.Patronymic,
-			&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
-		)
-		if err != nil {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.RequestID, &c.Author, &c.AuthorRole, &c.Text, &c.CreatedAt); err != nil {
 			return nil, err
 		}
-		requests = append(requests, &req)
+		comments = append(comments, c)
 	}
-	return requests, nil
+	return comments, rows.Err()
+}
+
+// Получение заявок в работе
+func (s *PostgresPartnerRequestStorage) GetPending() ([]*PartnerRequest, error) {
+	return s.query(`SELECT ` + partnerRequestColumns + ` FROM part_req WHERE status = 'pending'`)
 }
 
 // Получение всех заявок
 func (s *PostgresPartnerRequestStorage) GetAll() ([]*PartnerRequest, error) {
-	rows, err := s.db.Query(`SELECT id, status, user_email, inn, kpp, ogrn, org_name, org_short_name,
-                                     name, surname, patronymic, email, phone_number, info, created_at, last_used  
-                              FROM part_req`)
+	return s.query(`SELECT ` + partnerRequestColumns + ` FROM part_req`)
+}
+
+// Получение заявок с определенным статусом
+func (s *PostgresPartnerRequestStorage) GetByStatus(status string) ([]*PartnerRequest, error) {
+	return s.query(`SELECT `+partnerRequestColumns+` FROM part_req WHERE status = $1`, status)
+}
+
+// Получение незакрытых заявок, назначенных администратору, начиная с дольше всех ожидающих
+func (s *PostgresPartnerRequestStorage) GetByAssignee(email string) ([]*PartnerRequest, error) {
+	return s.query(`SELECT `+partnerRequestColumns+` FROM part_req
+                    WHERE assigned_to = $1 AND status IN ('new', 'pending', 'needs_info')
+                    ORDER BY last_used`, email)
+}
+
+// Назначение заявки администратору (пустой admin - снятие назначения). Назначенная заявка
+// в статусе "new" переходит в "pending". Заявка меняется, только если она не закрыта и сейчас
+// назначена current (пустая строка - никому), иначе возвращает false
+func (s *PostgresPartnerRequestStorage) Assign(id uuid.UUID, admin, current string) (bool, error) {
+	query := `
+        UPDATE part_req SET
+            assigned_to = NULLIF($2::text, ''),
+            assigned_at = CASE WHEN $2::text = '' THEN NULL ELSE NOW() END,
+            status = CASE WHEN status = 'new' AND $2::text <> '' THEN 'pending' ELSE status END,
+            last_used = CASE WHEN status = 'new' AND $2::text <> '' THEN NOW() ELSE last_used END
+        WHERE id = $1 AND COALESCE(assigned_to, '') = $3 AND status IN ('new', 'pending', 'needs_info')
+    `
+	result, err := s.db.Exec(query, id, admin, current)
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("failed to assign partner request: %w", err)
 	}
-	defer rows.Close()
 
-	var requests []*PartnerRequest
-	for rows.Next() {
-		var req PartnerRequest
-		err := rows.Scan(
-			&req.ID, &req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
-			&req.OrgName, &req.OrgShortName,
-			&req.Name, &req.Surname, &req.Patronymic,
-			&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
-		)
-		if err != nil {
-			return nil, err
-		}
-		requests = append(requests, &req)
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// Колонки заявки в порядке сканирования scanPartnerRequest
+const partnerRequestColumns = `id, status, user_email, inn, kpp, ogrn, org_name, org_short_name,
+    name, surname, patronymic, email, phone_number, info, created_at, last_used,
+    COALESCE(status_reason, ''), COALESCE(assigned_to, ''), assigned_at`
+
+// Сканирование строки с колонками partnerRequestColumns
+func scanPartnerRequest(row interface{ Scan(dest ...any) error }) (*PartnerRequest, error) {
+	var req PartnerRequest
+	err := row.Scan(
+		&req.ID, &req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
+		&req.OrgName, &req.OrgShortName,
+		&req.Name, &req.Surname, &req.Patronymic,
+		&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
+		&req.StatusReason, &req.AssignedTo, &req.AssignedAt,
+	)
+	if err != nil {
+		return nil, err
 	}
-	return requests, nil
+	return &req, nil
 }
 
-// Получение заявок с определенным статусом
-func (s *PostgresPartnerRequestStorage) GetByStatus(status string) ([]*PartnerRequest, error) {
-	rows, err := s.db.Query(`SELECT id, status, user_email, inn, kpp, ogrn, org_name, org_short_name,
-                                     name, surname, patronymic, email, phone_number, info, created_at, last_used   
-                              FROM part_req WHERE status = $1`, status)
+// Выполнение запроса, возвращающего список заявок
+func (s *PostgresPartnerRequestStorage) query(query string, args ...any) ([]*PartnerRequest, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -241,19 +308,13 @@ func (s *PostgresPartnerRequestStorage) GetByStatus(status string) ([]*PartnerRe
 
 	var requests []*PartnerRequest
 	for rows.Next() {
-		var req PartnerRequest
-		err := rows.Scan(
-			&req.ID, &req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
-			&req.OrgName, &req.OrgShortName,
-			&req.Name, &req.Surname, &req.Patronymic,
-			&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
-		)
+		req, err := scanPartnerRequest(rows)
 		if err != nil {
 			return nil, err
 		}
-		requests = append(requests, &req)
+		requests = append(requests, req)
 	}
-	return requests, nil
+	return requests, rows.Err()
 }
 
 // Удаление заявки по ИНН (для отката)
@@ -272,13 +333,18 @@ func (s *PostgresPartnerRequestStorage) Delete(inn string) error {
 }
 
 type PostgresCompanyStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresCompanyStorage(db *sql.DB) *PostgresCompanyStorage {
 	return &PostgresCompanyStorage{db: db}
 }
 
+// WithTx возвращает CompanyStorage, работающий внутри транзакции tx
+func (s *PostgresCompanyStorage) WithTx(tx *sql.Tx) CompanyStorage {
+	return &PostgresCompanyStorage{db: tx}
+}
+
 // Создание компании
 func (s *PostgresCompanyStorage) Create(company *Company) error {
 	query := `
@@ -296,11 +362,13 @@ func (s *PostgresCompanyStorage) Create(company *Company) error {
 // Получение информации о компании по ИНН
 func (s *PostgresCompanyStorage) GetByINN(inn string) (*Company, error) {
 	var company Company
-	query := `SELECT inn, kpp, ogrn, org_name, org_short_name FROM companies WHERE inn = $1`
+	query := `SELECT inn, kpp, ogrn, org_name, org_short_name, suspended_at, COALESCE(suspension_reason, '')
+              FROM companies WHERE inn = $1`
 
 	err := s.db.QueryRow(query, inn).Scan(
 		&company.INN, &company.KPP, &company.OGRN,
 		&company.OrgName, &company.OrgShortName,
+		&company.SuspendedAt, &company.SuspensionReason,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -324,20 +392,95 @@ func (s *PostgresCompanyStorage) Exists(inn string) (bool, error) {
 	return exists, nil
 }
 
+// Приостановка компании администратором
+func (s *PostgresCompanyStorage) Suspend(inn, reason string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE companies SET suspended_at = NOW(), suspension_reason = $2
+                              WHERE inn = $1 AND suspended_at IS NULL`, inn, reason)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Возобновление работы компании
+func (s *PostgresCompanyStorage) Reinstate(inn string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE companies SET suspended_at = NULL, suspension_reason = NULL
+                              WHERE inn = $1 AND suspended_at IS NOT NULL`, inn)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Адреса почты сотрудников компании
+func (s *PostgresCompanyStorage) GetMemberEmails(inn string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT email FROM partners_users WHERE inn = $1 ORDER BY email`, inn)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+// Предстоящие (не начавшиеся и не отклонённые) заказы всех филиалов компании
+func (s *PostgresCompanyStorage) GetUpcomingOrders(inn string) ([]UpcomingOrder, error) {
+	rows, err := s.db.Query(`
+		SELECT o.id, o.users, b.id, b.city, b.address, s.name, o.start_moment, o.end_moment, o.status, o.sum
+		FROM orders o
+		JOIN branch_services bs ON o.service_by_branch = bs.id
+		JOIN branches b ON bs.branch = b.id
+		JOIN services s ON bs.service = s.id
+		WHERE b.inn_company = $1 AND o.start_moment >= NOW() AND o.status != 'reject'
+		ORDER BY o.start_moment
+	`, inn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upcoming orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []UpcomingOrder{}
+	for rows.Next() {
+		var o UpcomingOrder
+		if err := rows.Scan(&o.ID, &o.Client, &o.BranchID, &o.City, &o.Address, &o.Service,
+			&o.StartMoment, &o.EndMoment, &o.Status, &o.Sum); err != nil {
+			return nil, fmt.Errorf("failed to scan upcoming order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
 // PostgresPartnersUsersStorage реализация для PostgreSQL
 type PostgresPartnersUsersStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresPartnersUsersStorage(db *sql.DB) *PostgresPartnersUsersStorage {
 	return &PostgresPartnersUsersStorage{db: db}
 }
 
-// Создание нулевого пользователя от организации после одобрения заявки
+// WithTx возвращает PartnersUsersStorage, работающий внутри транзакции tx
+func (s *PostgresPartnersUsersStorage) WithTx(tx *sql.Tx) PartnersUsersStorage {
+	return &PostgresPartnersUsersStorage{db: tx}
+}
+
+// Создание нулевого пользователя от организации после одобрения заявки.
+// Автор заявки становится владельцем компании
 func (s *PostgresPartnersUsersStorage) Create(email, inn string) error {
-	query := `INSERT INTO partners_users (email, inn) VALUES ($1, $2)`
+	query := `INSERT INTO partners_users (email, inn, role) VALUES ($1, $2, $3)`
 
-	_, err := s.db.Exec(query, email, inn)
+	_, err := s.db.Exec(query, email, inn, rbac.CompanyRoleOwner)
 	if err != nil {
 		return fmt.Errorf("failed to insert into partners_users: %w", err)
 	}
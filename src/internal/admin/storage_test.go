@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// Статус меняется условным UPDATE: только если статус и назначение заявки не изменились с чтения
+func TestUpdateStatusIsConditional(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	storage := NewPostgresPartnerRequestStorage(sqlDB)
+	id := uuid.New()
+	query := regexp.QuoteMeta(`WHERE id = $3 AND status = $4 AND assigned_to IS NOT DISTINCT FROM NULLIF($5::text, '')`)
+
+	mock.ExpectExec(query).WithArgs("rejected", "Нет КПП", id, "pending", "admin@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("approved", "", id, "pending", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	updated, err := storage.UpdateStatus(id, "rejected", "Нет КПП", "pending", "admin@example.com")
+	if err != nil || !updated {
+		t.Fatalf("UpdateStatus = %v, %v, want true", updated, err)
+	}
+	updated, err = storage.UpdateStatus(id, "approved", "", "pending", "")
+	if err != nil || updated {
+		t.Fatalf("UpdateStatus = %v, %v, want false", updated, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,173 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"src/internal/audit"
+	configPkg "src/internal/config"
+	"src/internal/mail"
+)
+
+// Компании в памяти: приостановка, сотрудники и предстоящие заказы
+type suspensionCompanies struct {
+	CompanyStorage
+	companies map[string]*Company
+	members   []string
+	orders    []UpcomingOrder
+}
+
+func (s *suspensionCompanies) WithTx(tx *sql.Tx) CompanyStorage {
+	return s
+}
+
+func (s *suspensionCompanies) GetByINN(inn string) (*Company, error) {
+	company, ok := s.companies[inn]
+	if !ok {
+		return nil, nil
+	}
+	copied := *company
+	return &copied, nil
+}
+
+func (s *suspensionCompanies) Suspend(inn, reason string) (bool, error) {
+	company := s.companies[inn]
+	if company.SuspendedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	company.SuspendedAt = &now
+	company.SuspensionReason = reason
+	return true, nil
+}
+
+func (s *suspensionCompanies) Reinstate(inn string) (bool, error) {
+	company := s.companies[inn]
+	if company.SuspendedAt == nil {
+		return false, nil
+	}
+	company.SuspendedAt = nil
+	company.SuspensionReason = ""
+	return true, nil
+}
+
+func (s *suspensionCompanies) GetMemberEmails(inn string) ([]string, error) {
+	return s.members, nil
+}
+
+func (s *suspensionCompanies) GetUpcomingOrders(inn string) ([]UpcomingOrder, error) {
+	return s.orders, nil
+}
+
+// Письма о приостановке и возобновлении
+type suspensionEmails struct {
+	configPkg.TxEmailSender
+	suspended  []string
+	reinstated []string
+	reasons    []string
+}
+
+func (s *suspensionEmails) WithTx(tx *sql.Tx) configPkg.EmailSender {
+	return s
+}
+
+func (s *suspensionEmails) SendAccountSuspended(toEmail string, data mail.SuspensionData) error {
+	s.suspended = append(s.suspended, toEmail)
+	s.reasons = append(s.reasons, data.Reason)
+	return nil
+}
+
+func (s *suspensionEmails) SendAccountReinstated(toEmail string, data mail.SuspensionData) error {
+	s.reinstated = append(s.reinstated, toEmail)
+	return nil
+}
+
+// Действия, записанные в журнал. err - ошибка записи, которая должна отменить действие
+type suspensionAudit struct {
+	actions []string
+	err     error
+}
+
+func (a *suspensionAudit) Record(tx *sql.Tx, actor audit.Actor, action, targetType, targetID string, before, after any) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.actions = append(a.actions, action)
+	return nil
+}
+
+// Выполняет fn без транзакции
+type suspensionUnitOfWork struct{}
+
+func (suspensionUnitOfWork) Do(fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+func newSuspensionManager() (*AdminManager, *suspensionCompanies, *suspensionEmails, *suspensionAudit) {
+	companies := &suspensionCompanies{
+		companies: map[string]*Company{"7700000000": {INN: "7700000000", OrgShortName: "ООО Ромашка"}},
+		members:   []string{"owner@example.com", "operator@example.com"},
+		orders:    []UpcomingOrder{{Client: "client@example.com"}},
+	}
+	emails := &suspensionEmails{}
+	auditLog := &suspensionAudit{}
+	m := &AdminManager{companyStorage: companies, emailSender: emails, auditLog: auditLog, uow: suspensionUnitOfWork{}}
+	return m, companies, emails, auditLog
+}
+
+// Приостановка уведомляет сотрудников и возвращает заказы, которые нужно обработать; возобновление отменяет её
+func TestSuspendAndReinstateCompany(t *testing.T) {
+	m, companies, emails, auditLog := newSuspensionManager()
+	actor := audit.Actor{Email: "admin@example.com"}
+
+	resp, err := m.SuspendCompany(actor, "7700000000", "Жалобы клиентов")
+	if err != nil {
+		t.Fatalf("SuspendCompany: %v", err)
+	}
+	if resp.Company.SuspendedAt == nil || resp.Company.SuspensionReason != "Жалобы клиентов" || len(resp.UpcomingOrders) != 1 {
+		t.Errorf("SuspendCompany = %+v", resp)
+	}
+	if !slices.Equal(emails.suspended, companies.members) || emails.reasons[0] != "Жалобы клиентов" {
+		t.Errorf("suspension emails = %v, reasons = %v", emails.suspended, emails.reasons)
+	}
+	if _, err := m.SuspendCompany(actor, "7700000000", "again"); !errors.Is(err, ErrCompanyAlreadySuspended) {
+		t.Errorf("second SuspendCompany error = %v, want ErrCompanyAlreadySuspended", err)
+	}
+
+	company, err := m.ReinstateCompany(actor, "7700000000", "")
+	if err != nil {
+		t.Fatalf("ReinstateCompany: %v", err)
+	}
+	if company.SuspendedAt != nil || companies.companies["7700000000"].SuspendedAt != nil {
+		t.Errorf("company after reinstatement = %+v", company)
+	}
+	if !slices.Equal(emails.reinstated, companies.members) {
+		t.Errorf("reinstatement emails = %v", emails.reinstated)
+	}
+	if _, err := m.ReinstateCompany(actor, "7700000000", ""); !errors.Is(err, ErrCompanyNotSuspended) {
+		t.Errorf("second ReinstateCompany error = %v, want ErrCompanyNotSuspended", err)
+	}
+
+	want := []string{audit.ActionCompanySuspend, audit.ActionCompanyReinstate}
+	if !slices.Equal(auditLog.actions, want) {
+		t.Errorf("audit actions = %v, want %v", auditLog.actions, want)
+	}
+
+	if _, err := m.SuspendCompany(actor, "7711111111", ""); !errors.Is(err, ErrCompanyNotFound) {
+		t.Errorf("SuspendCompany of unknown company error = %v, want ErrCompanyNotFound", err)
+	}
+}
+
+// Без записи в журнале компания не приостанавливается
+func TestSuspendCompanyFailsWithoutAudit(t *testing.T) {
+	m, _, _, auditLog := newSuspensionManager()
+	errAudit := errors.New("audit unavailable")
+	auditLog.err = errAudit
+
+	if _, err := m.SuspendCompany(audit.Actor{Email: "admin@example.com"}, "7700000000", "reason"); !errors.Is(err, errAudit) {
+		t.Fatalf("SuspendCompany error = %v, want audit error", err)
+	}
+}
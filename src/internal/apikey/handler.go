@@ -0,0 +1,123 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"src/internal/audit"
+	"src/internal/middleware"
+	"src/internal/rbac"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Handler обрабатывает HTTP-запросы для API ключей компании
+type Handler struct {
+	apiKey *APIKeyManager
+}
+
+// NewHandler создаёт новый экземпляр Handler
+func NewHandler(apiKey *APIKeyManager) *Handler {
+	return &Handler{apiKey: apiKey}
+}
+
+// Получение пользователя, от имени которого выполняется запрос
+func principalFromRequest(w http.ResponseWriter, r *http.Request) (rbac.Principal, bool) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return rbac.Principal{}, false
+	}
+	return principal, true
+}
+
+// Общая обработка ошибок APIKeyManager
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotPartner):
+		http.Error(w, "User does not have a company", http.StatusForbidden)
+	case errors.Is(err, ErrPermissionDenied):
+		http.Error(w, ErrPermissionDenied.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrAPIKeyNotFound):
+		http.Error(w, "API key not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidScope), errors.Is(err, ErrNoScopes):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrTooManyKeys):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// CreateAPIKey обрабатывает POST /company/api-keys.
+// Ключ возвращается только в этом ответе
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	resp, err := h.apiKey.CreateKey(principal, audit.ActorFromRequest(r), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetAPIKeys обрабатывает GET /company/api-keys
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.apiKey.GetKeys(principal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []*APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey обрабатывает DELETE /company/api-keys/{id}
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apiKey.RevokeKey(principal, audit.ActorFromRequest(r), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
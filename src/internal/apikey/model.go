@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Начало каждого ключа, по нему ключ легко узнать в конфигурации интеграции
+const keyPrefix = "pk_"
+
+// APIKey соответствует таблице company_api_keys. Сам ключ не хранится
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" example:"6a0f5d2e-3b1c-4e8f-9a7d-2c5b8e1f0a3d"`
+	CompanyINN string     `json:"inn" example:"123456789012"`
+	Name       string     `json:"name" example:"Касса на Ленина"`
+	Prefix     string     `json:"prefix" example:"pk_3f9a1c7e"`
+	Scopes     []string   `json:"scopes" example:"orders:read,orders:write"`
+	CreatedBy  string     `json:"created_by" example:"owner@mail.ru"`
+	CreatedAt  time.Time  `json:"created_at" example:"2026-03-30T06:06:47.181805Z"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2026-03-31T09:12:03.512114Z"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty" example:"203.0.113.7"`
+}
+
+// CreateAPIKeyRequest - запрос на создание API ключа
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" example:"Касса на Ленина" validate:"required,max=100"`
+	Scopes []string `json:"scopes" example:"orders:read,orders:write" validate:"required,min=1,dive,required"`
+}
+
+// CreateAPIKeyResponse - ответ на создание API ключа.
+// Ключ показывается только один раз
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key" example:"pk_3f9a1c7e5b..."`
+}
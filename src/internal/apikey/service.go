@@ -0,0 +1,211 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	"src/internal/db"
+	"src/internal/rbac"
+)
+
+var (
+	ErrUserNotPartner   = errors.New("the user does not have a company")
+	ErrPermissionDenied = errors.New("not enough rights in the company")
+	ErrInvalidScope     = errors.New("unknown api key scope")
+	ErrNoScopes         = errors.New("at least one api key scope is required")
+	ErrTooManyKeys      = errors.New("api key limit reached, revoke unused keys")
+)
+
+const (
+	// Максимальное количество API ключей компании
+	maxKeysPerCompany = 20
+
+	// Время последнего использования обновляется не чаще раза в минуту,
+	// чтобы не писать в БД на каждый запрос
+	lastUsedPrecision = time.Minute
+
+	// Длина начала ключа, которое хранится открыто и показывается в списке ключей
+	displayPrefixLength = len(keyPrefix) + 8
+)
+
+// APIKeyManager содержит бизнес-логику API ключей компаний.
+// Реализует middleware.APIKeyAuthenticator
+type APIKeyManager struct {
+	storage  APIKeyStorage
+	auditLog audit.Recorder
+	uow      db.UnitOfWork
+}
+
+// NewAPIKeyManager создаёт новый экземпляр APIKeyManager
+func NewAPIKeyManager(storage APIKeyStorage, auditLog audit.Recorder, uow db.UnitOfWork) *APIKeyManager {
+	return &APIKeyManager{storage: storage, auditLog: auditLog, uow: uow}
+}
+
+// Получение ИНН компании пользователя с проверкой, что его роль позволяет управлять API ключами
+func (m *APIKeyManager) companyInn(principal rbac.Principal) (string, error) {
+	if principal.INN == "" {
+		return "", ErrUserNotPartner
+	}
+	if !principal.HasPermission(rbac.PermCompanyAPIKeys) {
+		return "", ErrPermissionDenied
+	}
+	return principal.INN, nil
+}
+
+// CreateKey создаёт API ключ компании пользователя и записывает его создание в журнал действий.
+// Ключ возвращается только здесь. Лимит ключей проверяется в одной транзакции с созданием
+// под блокировкой строки компании, поэтому параллельные запросы не превышают maxKeysPerCompany
+func (m *APIKeyManager) CreateKey(principal rbac.Principal, actor audit.Actor, req CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	// Удаление дублей и проверка прав
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !rbac.IsAPIKeyScope(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	// Ключ без прав не проходит ни на один маршрут
+	if len(scopes) == 0 {
+		return nil, ErrNoScopes
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate api key: %w", err)
+	}
+
+	var created *APIKey
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		storage := m.storage.WithTx(tx)
+		if err := storage.LockCompany(inn); err != nil {
+			return err
+		}
+		count, err := storage.CountByCompany(inn)
+		if err != nil {
+			return err
+		}
+		if count >= maxKeysPerCompany {
+			return ErrTooManyKeys
+		}
+
+		created, err = storage.Create(&APIKey{
+			CompanyINN: inn,
+			Name:       strings.TrimSpace(req.Name),
+			Prefix:     key[:displayPrefixLength],
+			Scopes:     scopes,
+			CreatedBy:  principal.Email,
+		}, hashKey(key))
+		if err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionAPIKeyCreate, audit.TargetAPIKey, created.ID.String(), nil, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("api key %s (%s) created for company %s by %s", created.ID, created.Prefix, inn, principal.Email)
+	return &CreateAPIKeyResponse{APIKey: *created, Key: key}, nil
+}
+
+// GetKeys возвращает API ключи компании пользователя
+func (m *APIKeyManager) GetKeys(principal rbac.Principal) ([]*APIKey, error) {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return nil, err
+	}
+	return m.storage.GetByCompany(inn)
+}
+
+// RevokeKey отзывает API ключ компании пользователя. Запросы с ключом отклоняются сразу
+func (m *APIKeyManager) RevokeKey(principal rbac.Principal, actor audit.Actor, id uuid.UUID) error {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return err
+	}
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		// Удалённый ключ остаётся в журнале: название, начало ключа и права
+		revoked, err := m.storage.WithTx(tx).Delete(id, inn)
+		if err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionAPIKeyRevoke, audit.TargetAPIKey, id.String(), revoked, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("api key %s of company %s revoked by %s", id, inn, principal.Email)
+	return nil
+}
+
+// AuthenticateAPIKey проверяет ключ из заголовка X-API-Key и запоминает его использование.
+// Для неизвестного ключа возвращает nil без ошибки, для ключа приостановленной компании - rbac.ErrCompanySuspended
+func (m *APIKeyManager) AuthenticateAPIKey(key, ip string) (*rbac.Principal, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, nil
+	}
+
+	apiKey, err := m.storage.GetByHash(hashKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, nil
+	}
+
+	suspended, err := m.storage.IsCompanySuspended(apiKey.CompanyINN)
+	if err != nil {
+		return nil, err
+	}
+	if suspended {
+		return nil, rbac.ErrCompanySuspended
+	}
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > lastUsedPrecision ||
+		apiKey.LastUsedIP == nil || *apiKey.LastUsedIP != ip {
+		if err := m.storage.UpdateLastUsed(apiKey.ID, ip); err != nil {
+			log.Printf("api key %s: %v", apiKey.ID, err)
+		}
+	}
+
+	return &rbac.Principal{
+		Role:     rbac.RolePartner,
+		INN:      apiKey.CompanyINN,
+		APIKeyID: apiKey.ID.String(),
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
+
+// Генерация ключа: префикс и 32 случайных байта в hex
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return keyPrefix + hex.EncodeToString(b), nil
+}
+
+// Хеш ключа для хранения в БД
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
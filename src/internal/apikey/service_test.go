@@ -0,0 +1,309 @@
+package apikey
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	"src/internal/middleware"
+	"src/internal/rbac"
+)
+
+// Хранилище ключей в памяти
+type memoryStorage struct {
+	APIKeyStorage
+	keys      map[string]*APIKey // по хешу ключа
+	locked    []string           // компании, заблокированные LockCompany
+	suspended bool
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{keys: make(map[string]*APIKey)}
+}
+
+func (s *memoryStorage) Create(key *APIKey, keyHash string) (*APIKey, error) {
+	created := *key
+	created.ID = uuid.New()
+	s.keys[keyHash] = &created
+	return &created, nil
+}
+
+func (s *memoryStorage) WithTx(tx *sql.Tx) APIKeyStorage {
+	return s
+}
+
+func (s *memoryStorage) Delete(id uuid.UUID, inn string) (*APIKey, error) {
+	for hash, key := range s.keys {
+		if key.ID == id && key.CompanyINN == inn {
+			delete(s.keys, hash)
+			return key, nil
+		}
+	}
+	return nil, ErrAPIKeyNotFound
+}
+
+func (s *memoryStorage) CountByCompany(inn string) (int, error) {
+	count := 0
+	for _, key := range s.keys {
+		if key.CompanyINN == inn {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memoryStorage) GetByHash(keyHash string) (*APIKey, error) {
+	return s.keys[keyHash], nil
+}
+
+func (s *memoryStorage) UpdateLastUsed(id uuid.UUID, ip string) error {
+	return nil
+}
+
+func (s *memoryStorage) IsCompanySuspended(inn string) (bool, error) {
+	return s.suspended, nil
+}
+
+func (s *memoryStorage) LockCompany(inn string) error {
+	s.locked = append(s.locked, inn)
+	return nil
+}
+
+// Журнал действий в памяти. err - ошибка записи, которая должна отменить действие
+type recordingAudit struct {
+	entries []audit.Entry
+	err     error
+}
+
+func (a *recordingAudit) Record(tx *sql.Tx, actor audit.Actor, action, targetType, targetID string, before, after any) error {
+	if a.err != nil {
+		return a.err
+	}
+	entry := audit.Entry{Actor: actor.Email, APIKeyID: actor.APIKeyID, IP: actor.IP,
+		Action: action, TargetType: targetType, TargetID: targetID}
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+// Выполняет fn без транзакции
+type directUnitOfWork struct{}
+
+func (directUnitOfWork) Do(fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+func newTestManager(storage *memoryStorage) (*APIKeyManager, *recordingAudit) {
+	auditLog := &recordingAudit{}
+	return NewAPIKeyManager(storage, auditLog, directUnitOfWork{}), auditLog
+}
+
+var owner = rbac.Principal{Email: "owner@mail.ru", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+
+func TestCreateKeyScopes(t *testing.T) {
+	m, _ := newTestManager(newMemoryStorage())
+
+	resp, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: " Касса ", Scopes: []string{rbac.ScopeOrdersRead, rbac.ScopeOrdersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if !strings.HasPrefix(resp.Key, keyPrefix) || resp.Prefix != resp.Key[:displayPrefixLength] {
+		t.Errorf("key = %q, prefix = %q", resp.Key, resp.Prefix)
+	}
+	if len(resp.Scopes) != 1 || resp.Name != "Касса" || resp.CreatedBy != owner.Email {
+		t.Errorf("created = %+v", resp.APIKey)
+	}
+
+	if _, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: "x", Scopes: []string{"admin:all"}}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("unknown scope error = %v, want ErrInvalidScope", err)
+	}
+
+	operator := rbac.Principal{Email: "op@mail.ru", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOperator}
+	if _, err := m.CreateKey(operator, audit.Actor{}, CreateAPIKeyRequest{Name: "x", Scopes: []string{rbac.ScopeOrdersRead}}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("operator error = %v, want ErrPermissionDenied", err)
+	}
+
+	// Ключ не может выпускать ключи, даже со всеми правами
+	key := rbac.Principal{Role: rbac.RolePartner, INN: "7700000000", APIKeyID: resp.ID.String(), Scopes: rbac.APIKeyScopes}
+	if _, err := m.CreateKey(key, audit.Actor{}, CreateAPIKeyRequest{Name: "x", Scopes: []string{rbac.ScopeOrdersRead}}); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("api key error = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	storage := newMemoryStorage()
+	m, _ := newTestManager(storage)
+	resp, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: "Касса", Scopes: []string{rbac.ScopeOrdersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	principal, err := m.AuthenticateAPIKey(resp.Key, "203.0.113.7")
+	if err != nil || principal == nil {
+		t.Fatalf("AuthenticateAPIKey = %v, %v", principal, err)
+	}
+	if principal.Email != "" || principal.APIKeyID != resp.ID.String() || principal.INN != owner.INN {
+		t.Errorf("principal = %+v", principal)
+	}
+
+	for _, key := range []string{"", "pk_unknown", "sk_" + resp.Key} {
+		if principal, err := m.AuthenticateAPIKey(key, "203.0.113.7"); principal != nil || err != nil {
+			t.Errorf("AuthenticateAPIKey(%q) = %v, %v, want nil", key, principal, err)
+		}
+	}
+
+	// Ключи приостановленной компании отклоняются, после возобновления снова принимаются
+	storage.suspended = true
+	if _, err := m.AuthenticateAPIKey(resp.Key, "203.0.113.7"); !errors.Is(err, rbac.ErrCompanySuspended) {
+		t.Errorf("suspended error = %v, want ErrCompanySuspended", err)
+	}
+	storage.suspended = false
+	if principal, err := m.AuthenticateAPIKey(resp.Key, "203.0.113.7"); err != nil || principal == nil {
+		t.Errorf("AuthenticateAPIKey after reinstatement = %v, %v", principal, err)
+	}
+}
+
+// Ключ проходит только на маршруты, где указано одно из его прав, и получает только разрешения своих прав
+func TestAPIKeyRouteScopes(t *testing.T) {
+	m, _ := newTestManager(newMemoryStorage())
+	resp, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: "Касса", Scopes: []string{rbac.ScopeOrdersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		permission string
+		scopes     []string
+		want       int
+	}{
+		{"orders read", resp.Key, rbac.PermCompanyOrders, []string{rbac.ScopeOrdersRead, rbac.ScopeOrdersWrite}, http.StatusOK},
+		{"orders write only", resp.Key, rbac.PermCompanyOrders, []string{rbac.ScopeOrdersWrite}, http.StatusForbidden},
+		{"catalog", resp.Key, rbac.PermCompanyManage, []string{rbac.ScopeCatalogManage}, http.StatusForbidden},
+		{"route without scopes", resp.Key, rbac.PermCompanyView, nil, http.StatusForbidden},
+		{"members", resp.Key, rbac.PermCompanyMembers, nil, http.StatusForbidden},
+		{"unknown key", "pk_unknown", rbac.PermCompanyOrders, []string{rbac.ScopeOrdersRead}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got rbac.Principal
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = middleware.PrincipalFromContext(r.Context())
+			})
+			handler := middleware.NewAPIKeyMiddleware(m, middleware.NewAuthMiddleware(nil, nil)).Authenticate(middleware.RequirePermission(tt.permission, tt.scopes...)(next))
+
+			req := httptest.NewRequest(http.MethodGet, "/company/orders", nil)
+			req.Header.Set(middleware.APIKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && got.APIKeyID != resp.ID.String() {
+				t.Errorf("principal = %+v", got)
+			}
+		})
+	}
+}
+
+// Ключ без прав не создаётся: он не прошёл бы ни на один маршрут
+func TestCreateKeyRequiresScopes(t *testing.T) {
+	storage := newMemoryStorage()
+	m, _ := newTestManager(storage)
+
+	for _, scopes := range [][]string{nil, {}} {
+		if _, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: "Касса", Scopes: scopes}); !errors.Is(err, ErrNoScopes) {
+			t.Errorf("CreateKey(%v) error = %v, want ErrNoScopes", scopes, err)
+		}
+	}
+	if len(storage.keys) != 0 {
+		t.Errorf("keys = %d, want 0", len(storage.keys))
+	}
+
+	rec := httptest.NewRecorder()
+	writeError(rec, ErrNoScopes)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// Лимит проверяется под блокировкой компании, сверх лимита ключ не создаётся
+func TestCreateKeyLimit(t *testing.T) {
+	storage := newMemoryStorage()
+	m, _ := newTestManager(storage)
+
+	for i := 0; i < maxKeysPerCompany; i++ {
+		if _, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: "Касса", Scopes: []string{rbac.ScopeOrdersRead}}); err != nil {
+			t.Fatalf("CreateKey #%d: %v", i+1, err)
+		}
+	}
+	if _, err := m.CreateKey(owner, audit.Actor{}, CreateAPIKeyRequest{Name: "Касса", Scopes: []string{rbac.ScopeOrdersRead}}); !errors.Is(err, ErrTooManyKeys) {
+		t.Fatalf("CreateKey over limit error = %v, want ErrTooManyKeys", err)
+	}
+	if len(storage.keys) != maxKeysPerCompany {
+		t.Errorf("keys = %d, want %d", len(storage.keys), maxKeysPerCompany)
+	}
+	if len(storage.locked) != maxKeysPerCompany+1 || storage.locked[0] != owner.INN {
+		t.Errorf("locked = %v", storage.locked)
+	}
+}
+
+func TestKeyManagementIsAudited(t *testing.T) {
+	actor := audit.Actor{Email: owner.Email, IP: "203.0.113.7"}
+	storage := newMemoryStorage()
+	m, auditLog := newTestManager(storage)
+
+	resp, err := m.CreateKey(owner, actor, CreateAPIKeyRequest{Name: "Касса", Scopes: []string{rbac.ScopeOrdersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if err := m.RevokeKey(owner, actor, resp.ID); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+
+	want := []string{audit.ActionAPIKeyCreate, audit.ActionAPIKeyRevoke}
+	if len(auditLog.entries) != len(want) {
+		t.Fatalf("entries = %+v, want %v", auditLog.entries, want)
+	}
+	for i, entry := range auditLog.entries {
+		if entry.Action != want[i] || entry.Actor != actor.Email || entry.IP != actor.IP ||
+			entry.TargetType != audit.TargetAPIKey || entry.TargetID != resp.ID.String() {
+			t.Errorf("entry %d = %+v", i, entry)
+		}
+	}
+	// В журнале остаётся, какой ключ был отозван
+	var revoked APIKey
+	if err := json.Unmarshal(auditLog.entries[1].Before, &revoked); err != nil {
+		t.Fatalf("revoke before = %s: %v", auditLog.entries[1].Before, err)
+	}
+	if revoked.Name != "Касса" || revoked.Prefix != resp.Prefix || !slices.Equal(revoked.Scopes, []string{rbac.ScopeOrdersRead}) {
+		t.Errorf("revoke before = %+v", revoked)
+	}
+
+	// Без записи в журнале ключ не отзывается
+	resp, err = m.CreateKey(owner, actor, CreateAPIKeyRequest{Name: "Склад", Scopes: []string{rbac.ScopeOrdersRead}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	errAudit := errors.New("audit unavailable")
+	auditLog.err = errAudit
+	if err := m.RevokeKey(owner, actor, resp.ID); !errors.Is(err, errAudit) {
+		t.Fatalf("RevokeKey error = %v, want audit error", err)
+	}
+	if _, err := m.CreateKey(owner, actor, CreateAPIKeyRequest{Name: "x", Scopes: []string{rbac.ScopeOrdersRead}}); !errors.Is(err, errAudit) {
+		t.Fatalf("CreateKey error = %v, want audit error", err)
+	}
+}
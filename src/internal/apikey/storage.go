@@ -0,0 +1,198 @@
+package apikey
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"src/internal/db"
+)
+
+// ошибки которые возвращает apikey/storage
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// APIKeyStorage определяет методы для работы с API ключами компаний
+type APIKeyStorage interface {
+	Create(key *APIKey, keyHash string) (*APIKey, error)
+
+	CountByCompany(inn string) (int, error)
+
+	GetByCompany(inn string) ([]*APIKey, error)
+
+	GetByHash(keyHash string) (*APIKey, error)
+
+	Delete(id uuid.UUID, inn string) (*APIKey, error)
+
+	UpdateLastUsed(id uuid.UUID, ip string) error
+
+	LockCompany(inn string) error
+
+	IsCompanySuspended(inn string) (bool, error)
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) APIKeyStorage
+}
+
+// PostgresAPIKeyStorage реализует APIKeyStorage для PostgreSQL
+type PostgresAPIKeyStorage struct {
+	*db.Storage
+}
+
+// NewPostgresAPIKeyStorage создаёт новый экземпляр PostgresAPIKeyStorage
+func NewPostgresAPIKeyStorage(sqlDB *sql.DB) *PostgresAPIKeyStorage {
+	return &PostgresAPIKeyStorage{Storage: db.NewStorage(sqlDB)}
+}
+
+// WithTx возвращает PostgresAPIKeyStorage, работающий внутри транзакции tx
+func (s *PostgresAPIKeyStorage) WithTx(tx *sql.Tx) APIKeyStorage {
+	return &PostgresAPIKeyStorage{Storage: s.Storage.WithTx(tx)}
+}
+
+// Create сохраняет новый API ключ. Хранится только хеш ключа
+func (s *PostgresAPIKeyStorage) Create(key *APIKey, keyHash string) (*APIKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("marshal api key scopes: %w", err)
+	}
+
+	created := *key
+	err = s.DB.QueryRow(`
+		INSERT INTO company_api_keys (company_inn, name, prefix, key_hash, scopes, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, key.CompanyINN, key.Name, key.Prefix, keyHash, scopes, key.CreatedBy).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert api key: %w", err)
+	}
+	return &created, nil
+}
+
+// LockCompany блокирует строку компании до конца транзакции, чтобы параллельные
+// создания ключей проверяли лимит по очереди. Используется внутри WithTx
+func (s *PostgresAPIKeyStorage) LockCompany(inn string) error {
+	var locked string
+	err := s.DB.QueryRow(`SELECT inn FROM companies WHERE inn = $1 FOR UPDATE`, inn).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("lock company %s: %w", inn, err)
+	}
+	return nil
+}
+
+// CountByCompany возвращает количество API ключей компании
+func (s *PostgresAPIKeyStorage) CountByCompany(inn string) (int, error) {
+	var count int
+	err := s.DB.QueryRow(`SELECT COUNT(*) FROM company_api_keys WHERE company_inn = $1`, inn).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count api keys of company %s: %w", inn, err)
+	}
+	return count, nil
+}
+
+// GetByCompany возвращает все API ключи компании
+func (s *PostgresAPIKeyStorage) GetByCompany(inn string) ([]*APIKey, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, company_inn, name, prefix, scopes, created_by, created_at, last_used_at, last_used_ip
+		FROM company_api_keys
+		WHERE company_inn = $1
+		ORDER BY created_at
+	`, inn)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys by company %s: %w", inn, err)
+	}
+	defer rows.Close()
+
+	return scanAPIKeys(rows)
+}
+
+// GetByHash возвращает API ключ по хешу. Если ключ не найден, возвращает nil без ошибки
+func (s *PostgresAPIKeyStorage) GetByHash(keyHash string) (*APIKey, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, company_inn, name, prefix, scopes, created_by, created_at, last_used_at, last_used_ip
+		FROM company_api_keys
+		WHERE key_hash = $1
+	`, keyHash)
+	if err != nil {
+		return nil, fmt.Errorf("query api key: %w", err)
+	}
+	defer rows.Close()
+
+	keys, err := scanAPIKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return keys[0], nil
+}
+
+// IsCompanySuspended проверяет, приостановлена ли компания администратором.
+// Ключ компании, которой нет, не принимается: она считается приостановленной
+func (s *PostgresAPIKeyStorage) IsCompanySuspended(inn string) (bool, error) {
+	var suspended bool
+	err := s.DB.QueryRow(`SELECT suspended_at IS NOT NULL FROM companies WHERE inn = $1`, inn).Scan(&suspended)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("query company status: %w", err)
+	}
+	return suspended, nil
+}
+
+// Delete удаляет (отзывает) API ключ компании и возвращает удалённый ключ.
+// Если ключ не найден или принадлежит другой компании, возвращает ErrAPIKeyNotFound
+func (s *PostgresAPIKeyStorage) Delete(id uuid.UUID, inn string) (*APIKey, error) {
+	rows, err := s.DB.Query(`
+		DELETE FROM company_api_keys
+		WHERE id = $1 AND company_inn = $2
+		RETURNING id, company_inn, name, prefix, scopes, created_by, created_at, last_used_at, last_used_ip
+	`, id, inn)
+	if err != nil {
+		return nil, fmt.Errorf("delete api key %v: %w", id, err)
+	}
+	defer rows.Close()
+
+	keys, err := scanAPIKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+	return keys[0], nil
+}
+
+// UpdateLastUsed запоминает время и IP адрес последнего запроса с ключом
+func (s *PostgresAPIKeyStorage) UpdateLastUsed(id uuid.UUID, ip string) error {
+	_, err := s.DB.Exec(`UPDATE company_api_keys SET last_used_at = NOW(), last_used_ip = $2 WHERE id = $1`, id, ip)
+	if err != nil {
+		return fmt.Errorf("update api key %v last use: %w", id, err)
+	}
+	return nil
+}
+
+func scanAPIKeys(rows *sql.Rows) ([]*APIKey, error) {
+	var keys []*APIKey
+	for rows.Next() {
+		var key APIKey
+		var scopes []byte
+		if err := rows.Scan(&key.ID, &key.CompanyINN, &key.Name, &key.Prefix, &scopes, &key.CreatedBy,
+			&key.CreatedAt, &key.LastUsedAt, &key.LastUsedIP); err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		if err := json.Unmarshal(scopes, &key.Scopes); err != nil {
+			return nil, fmt.Errorf("unmarshal api key scopes: %w", err)
+		}
+		keys = append(keys, &key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return keys, nil
+}
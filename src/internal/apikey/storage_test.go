@@ -0,0 +1,51 @@
+package apikey
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// Ключ компании, которой нет в БД, не принимается: проверка отказывает, а не пропускает
+func TestIsCompanySuspended(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT suspended_at IS NOT NULL FROM companies WHERE inn = $1`)
+
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{"active", sqlmock.NewRows([]string{"suspended"}).AddRow(false), nil, false, false},
+		{"suspended", sqlmock.NewRows([]string{"suspended"}).AddRow(true), nil, true, false},
+		{"missing company", sqlmock.NewRows([]string{"suspended"}), nil, true, false},
+		{"query error", nil, errors.New("connection refused"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer sqlDB.Close()
+
+			expect := mock.ExpectQuery(query).WithArgs("7700000000")
+			if tt.err != nil {
+				expect.WillReturnError(tt.err)
+			} else {
+				expect.WillReturnRows(tt.rows)
+			}
+
+			got, err := NewPostgresAPIKeyStorage(sqlDB).IsCompanySuspended("7700000000")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("IsCompanySuspended = %v, %v, want %v (error %v)", got, err, tt.want, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"src/internal/middleware"
+)
+
+// Handler обрабатывает HTTP-запросы к журналу действий
+type Handler struct {
+	audit *AuditLog
+}
+
+// NewHandler создаёт новый экземпляр Handler
+func NewHandler(audit *AuditLog) *Handler {
+	return &Handler{audit: audit}
+}
+
+// ActorFromRequest возвращает автора действия: email из токена или ID API ключа и IP адрес запроса
+func ActorFromRequest(r *http.Request) Actor {
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	return Actor{Email: principal.Email, APIKeyID: principal.APIKeyID, IP: middleware.ClientIP(r)}
+}
+
+// Разбор фильтра из query параметров: actor, action, target_type, target_id, from, to (RFC 3339)
+func filterFromRequest(w http.ResponseWriter, r *http.Request) (Filter, bool) {
+	query := r.URL.Query()
+	filter := Filter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, name+" must be in RFC 3339 format", http.StatusBadRequest)
+			return Filter{}, false
+		}
+		*dst = &parsed
+	}
+	return filter, true
+}
+
+// GetEntries обрабатывает GET /admin/audit, возвращает записи журнала по фильтру, начиная с новых
+func (h *Handler) GetEntries(w http.ResponseWriter, r *http.Request) {
+	filter, ok := filterFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	filter.Limit = defaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = parsed
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "offset must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		filter.Offset = parsed
+	}
+
+	entries, err := h.audit.List(filter)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// Export обрабатывает GET /admin/audit/export?format=csv|json, выгружает журнал файлом.
+// Фильтры как у GET /admin/audit, без постраничного вывода и ограничения количества записей:
+// файл передаётся по мере чтения журнала
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	filter, ok := filterFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	// Заголовки отправляются с первой записью, чтобы ошибка чтения до неё вернула 500
+	var writeEntry func(entry *Entry) error
+	var finish func()
+	started := false
+	start := func() {
+		started = true
+		filename := "audit-" + time.Now().UTC().Format("20060102-150405") + "." + format
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			writeEntry, finish = jsonExport(w)
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			writeEntry, finish = csvExport(w)
+		}
+	}
+
+	err := h.audit.Export(filter, func(entry *Entry) error {
+		if !started {
+			start()
+		}
+		return writeEntry(entry)
+	})
+	if err != nil {
+		log.Printf("audit: export failed: %v", err)
+		if !started {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Часть файла уже отправлена: соединение обрывается, чтобы неполный файл не приняли за полный
+		panic(http.ErrAbortHandler)
+	}
+	if !started {
+		start()
+	}
+	finish()
+}
+
+// Выгрузка в JSON массивом записей
+func jsonExport(w http.ResponseWriter) (func(entry *Entry) error, func()) {
+	first := true
+	io.WriteString(w, "[")
+	write := func(entry *Entry) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return json.NewEncoder(w).Encode(entry)
+	}
+	finish := func() {
+		io.WriteString(w, "]\n")
+	}
+	return write, finish
+}
+
+// Выгрузка в CSV. Ячейки, которые табличный редактор выполнит как формулу, экранируются
+func csvExport(w http.ResponseWriter) (func(entry *Entry) error, func()) {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "created_at", "actor", "api_key_id", "ip", "action", "target_type", "target_id", "before", "after"})
+	write := func(entry *Entry) error {
+		return writer.Write([]string{
+			entry.ID.String(),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			csvSafe(entry.Actor),
+			csvSafe(entry.APIKeyID),
+			csvSafe(entry.IP),
+			csvSafe(entry.Action),
+			csvSafe(entry.TargetType),
+			csvSafe(entry.TargetID),
+			csvSafe(string(entry.Before)),
+			csvSafe(string(entry.After)),
+		})
+	}
+	return write, writer.Flush
+}
+
+// Защита от CSV injection: значение, начинающееся с =, +, -, @, табуляции или перевода строки,
+// начинается с апострофа и показывается как текст
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
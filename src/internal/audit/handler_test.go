@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSVSafe(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"admin@example.com":        "admin@example.com",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+7 900 000-00-00":         "'+7 900 000-00-00",
+		"-1":                       "'-1",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+		"\r=1":                     "'\r=1",
+		"a=1":                      "a=1",
+	}
+	for value, want := range tests {
+		if got := csvSafe(value); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func export(t *testing.T, storage *memoryStorage, format string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit/export?format="+format, nil)
+	rec := httptest.NewRecorder()
+	NewHandler(NewAuditLog(storage)).Export(rec, req)
+	return rec
+}
+
+func TestExportCSV(t *testing.T) {
+	entries := newEntries(exportBatchSize + 1)
+	entries[0].Actor = "=cmd|' /C calc'!A0"
+	entries[0].APIKeyID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+
+	rec := export(t, &memoryStorage{entries: entries}, "csv")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("status = %d, content type = %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if len(rows) != len(entries)+1 {
+		t.Fatalf("rows = %d, want %d", len(rows), len(entries)+1)
+	}
+	if rows[0][3] != "api_key_id" {
+		t.Errorf("header = %v", rows[0])
+	}
+	if rows[1][2] != "'"+entries[0].Actor || rows[1][3] != entries[0].APIKeyID {
+		t.Errorf("first row = %v", rows[1])
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	rec := export(t, &memoryStorage{}, "json")
+	var got []Entry
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || len(got) != 0 {
+		t.Fatalf("empty export = %v, %v", got, err)
+	}
+
+	entries := newEntries(3)
+	rec = export(t, &memoryStorage{entries: entries}, "json")
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || len(got) != 3 || got[2].ID != entries[2].ID {
+		t.Fatalf("export = %v, %v", got, err)
+	}
+}
+
+// Ошибка чтения журнала до первой записи возвращает 500, а не пустой файл
+func TestExportStorageError(t *testing.T) {
+	rec := export(t, &memoryStorage{listErr: errors.New("db down")}, "csv")
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Disposition") != "" {
+		t.Errorf("status = %d, disposition = %q", rec.Code, rec.Header().Get("Content-Disposition"))
+	}
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry - запись журнала действий администраторов и управления доступом к компаниям
+type Entry struct {
+	ID         uuid.UUID       `json:"id" example:"0f8c3b8e-7a4d-4a8e-9c61-5a1d2b3c4d5e"`
+	Actor      string          `json:"actor" example:"admin@example.com"`                                   // пусто, если действие выполнено API ключом
+	APIKeyID   string          `json:"api_key_id,omitempty" example:"7c9e6679-7425-40de-944b-e07fc1f90ae7"` // ключ, которым выполнено действие
+	IP         string          `json:"ip,omitempty" example:"203.0.113.7"`
+	Action     string          `json:"action" example:"partner_request.approve"`
+	TargetType string          `json:"target_type" example:"partner_request"`
+	TargetID   string          `json:"target_id" example:"b1c2d3e4-f5a6-4b7c-8d9e-0f1a2b3c4d5e"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"` // состояние объекта до действия
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`  // состояние объекта после действия
+	CreatedAt  time.Time       `json:"created_at" example:"2026-03-30T06:06:47Z"`
+}
+
+// Actor - кто выполняет действие: email из токена или ID API ключа и IP адрес запроса
+type Actor struct {
+	Email    string
+	APIKeyID string
+	IP       string
+}
+
+// Filter - условия выборки записей журнала. Пустые поля не ограничивают выборку
+type Filter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+
+	// Продолжение выгрузки: записи, следующие в порядке выборки за after
+	after *Entry
+}
+
+// Действия, записываемые в журнал. Формат: <тип объекта>.<действие>
+const (
+	ActionPartnerRequestTake        = "partner_request.take"
+	ActionPartnerRequestApprove     = "partner_request.approve"
+	ActionPartnerRequestReject      = "partner_request.reject"
+	ActionPartnerRequestRequestInfo = "partner_request.request_info"
+	ActionPartnerRequestComment     = "partner_request.comment"
+	ActionPartnerRequestAssign      = "partner_request.assign"
+	ActionPartnerRequestRelease     = "partner_request.release"
+	ActionPartnerRequestOverride    = "partner_request.override"
+	ActionAdminCreate               = "admin.create"
+	ActionUserUnlock                = "user.unlock"
+	ActionUserSuspend               = "user.suspend"
+	ActionUserReinstate             = "user.reinstate"
+	ActionCompanySuspend            = "company.suspend"
+	ActionCompanyReinstate          = "company.reinstate"
+	ActionAdminMFAPolicy            = "setting.admin_2fa"
+	ActionServiceCreate             = "service.create"
+	ActionServiceUpdate             = "service.update"
+	ActionServiceArchive            = "service.archive"
+	ActionServiceRestore            = "service.restore"
+	ActionServiceDelete             = "service.delete"
+	ActionServiceReorder            = "service.reorder"
+	ActionCategoryCreate            = "category.create"
+	ActionCategoryUpdate            = "category.update"
+	ActionCategoryDelete            = "category.delete"
+	ActionCategoryReorder           = "category.reorder"
+	ActionCompanyMemberInvite       = "company_member.invite"
+	ActionCompanyMemberRevokeInvite = "company_member.revoke_invitation"
+	ActionCompanyMemberJoin         = "company_member.join"
+	ActionCompanyMemberRole         = "company_member.update_role"
+	ActionCompanyMemberRemove       = "company_member.remove"
+	ActionCompanyMFAPolicy          = "company.mfa_policy"
+	ActionAPIKeyCreate              = "api_key.create"
+	ActionAPIKeyRevoke              = "api_key.revoke"
+)
+
+// Типы объектов действий
+const (
+	TargetPartnerRequest = "partner_request"
+	TargetAdmin          = "admin"
+	TargetUser           = "user"
+	TargetCompany        = "company"
+	TargetSetting        = "setting"
+	TargetService        = "service"
+	TargetCategory       = "category"
+	TargetCompanyMember  = "company_member" // target_id - <ИНН>/<email>
+	TargetInvitation     = "company_invitation"
+	TargetAPIKey         = "api_key"
+)
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	defaultLimit    = 50   // записей на странице по умолчанию
+	maxLimit        = 500  // максимум записей на странице
+	exportBatchSize = 1000 // записей в одном запросе выгрузки
+)
+
+// Recorder записывает действия в журнал. Реализуется AuditLog
+type Recorder interface {
+	// Record записывает действие в транзакции tx, в которой оно выполняется.
+	// Ошибка записи должна откатить транзакцию: действие без записи в журнале не выполняется
+	Record(tx *sql.Tx, actor Actor, action, targetType, targetID string, before, after any) error
+}
+
+// AuditLog содержит бизнес-логику журнала действий
+type AuditLog struct {
+	storage AuditStorage
+}
+
+// NewAuditLog создаёт новый экземпляр AuditLog
+func NewAuditLog(storage AuditStorage) *AuditLog {
+	return &AuditLog{storage: storage}
+}
+
+// Record сохраняет действие со снимками объекта до и после него (nil - снимка нет)
+// в транзакции tx вместе с самим действием
+func (l *AuditLog) Record(tx *sql.Tx, actor Actor, action, targetType, targetID string, before, after any) error {
+	beforeData, err := snapshot(before)
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", action, err)
+	}
+	afterData, err := snapshot(after)
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", action, err)
+	}
+
+	entry := &Entry{
+		Actor:      actor.Email,
+		APIKeyID:   actor.APIKeyID,
+		IP:         actor.IP,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     beforeData,
+		After:      afterData,
+	}
+	if err := l.storage.WithTx(tx).Add(entry); err != nil {
+		return fmt.Errorf("failed to record %s: %w", action, err)
+	}
+	return nil
+}
+
+// List возвращает страницу журнала по фильтру
+func (l *AuditLog) List(filter Filter) ([]Entry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultLimit
+	}
+	filter.Limit = min(filter.Limit, maxLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	entries, err := l.storage.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// Export передаёт в fn все записи журнала по фильтру, начиная с новых. Записи читаются
+// частями по exportBatchSize, поэтому выгрузка не ограничена по размеру и не держит её в памяти.
+// Ошибка fn прекращает выгрузку и возвращается
+func (l *AuditLog) Export(filter Filter, fn func(entry *Entry) error) error {
+	filter.Limit = exportBatchSize
+	filter.Offset = 0
+	filter.after = nil
+
+	for {
+		entries, err := l.storage.List(filter)
+		if err != nil {
+			return fmt.Errorf("failed to export audit log: %w", err)
+		}
+		for i := range entries {
+			if err := fn(&entries[i]); err != nil {
+				return err
+			}
+		}
+		if len(entries) < exportBatchSize {
+			return nil
+		}
+		filter.after = &entries[len(entries)-1]
+	}
+}
+
+// Снимок объекта в JSON. Отсутствующий объект (nil) не сохраняется
+func snapshot(value any) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal snapshot: %w", err)
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	return data, nil
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Журнал в памяти. Записи хранятся в порядке выборки: от новых к старым
+type memoryStorage struct {
+	entries []Entry
+	queries int
+	addErr  error
+	listErr error
+}
+
+func (s *memoryStorage) WithTx(tx *sql.Tx) AuditStorage {
+	return s
+}
+
+func (s *memoryStorage) Add(entry *Entry) error {
+	if s.addErr != nil {
+		return s.addErr
+	}
+	s.entries = append([]Entry{*entry}, s.entries...)
+	return nil
+}
+
+func (s *memoryStorage) List(filter Filter) ([]Entry, error) {
+	s.queries++
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+
+	start := filter.Offset
+	if filter.after != nil {
+		for i, entry := range s.entries {
+			if entry.ID == filter.after.ID {
+				start = i + 1
+			}
+		}
+	}
+	start = min(start, len(s.entries))
+	end := min(start+filter.Limit, len(s.entries))
+	return s.entries[start:end], nil
+}
+
+func newEntries(n int) []Entry {
+	now := time.Now()
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{ID: uuid.New(), Actor: "admin@example.com", Action: ActionAdminCreate, CreatedAt: now.Add(-time.Duration(i) * time.Second)}
+	}
+	return entries
+}
+
+func TestRecord(t *testing.T) {
+	storage := &memoryStorage{}
+	log := NewAuditLog(storage)
+
+	actor := Actor{APIKeyID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", IP: "203.0.113.7"}
+	if err := log.Record(nil, actor, ActionAPIKeyRevoke, TargetAPIKey, "k1", map[string]string{"name": "Касса"}, nil); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	entry := storage.entries[0]
+	if entry.Actor != "" || entry.APIKeyID != actor.APIKeyID || entry.IP != actor.IP || entry.TargetID != "k1" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if string(entry.Before) != `{"name":"Касса"}` || entry.After != nil {
+		t.Errorf("before = %s, after = %s", entry.Before, entry.After)
+	}
+}
+
+// Ошибка записи возвращается, чтобы откатить транзакцию действия
+func TestRecordReturnsErrors(t *testing.T) {
+	errStorage := errors.New("insert failed")
+	log := NewAuditLog(&memoryStorage{addErr: errStorage})
+	if err := log.Record(nil, Actor{}, ActionAdminCreate, TargetAdmin, "a", nil, nil); !errors.Is(err, errStorage) {
+		t.Errorf("Record error = %v, want storage error", err)
+	}
+
+	log = NewAuditLog(&memoryStorage{})
+	if err := log.Record(nil, Actor{}, ActionAdminCreate, TargetAdmin, "a", nil, func() {}); err == nil {
+		t.Error("Record accepted a snapshot that cannot be marshalled")
+	}
+}
+
+// Выгрузка не ограничена по размеру: журнал читается частями по exportBatchSize
+func TestExportPages(t *testing.T) {
+	for _, n := range []int{0, exportBatchSize, 2*exportBatchSize + 3} {
+		storage := &memoryStorage{entries: newEntries(n)}
+		log := NewAuditLog(storage)
+
+		var got []uuid.UUID
+		err := log.Export(Filter{Limit: 10, Offset: 5}, func(entry *Entry) error {
+			got = append(got, entry.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Export(%d): %v", n, err)
+		}
+		if len(got) != n {
+			t.Fatalf("Export(%d) returned %d entries", n, len(got))
+		}
+		for i, id := range got {
+			if id != storage.entries[i].ID {
+				t.Fatalf("Export(%d): entry %d out of order", n, i)
+			}
+		}
+		if want := n/exportBatchSize + 1; storage.queries != want {
+			t.Errorf("Export(%d) made %d queries, want %d", n, storage.queries, want)
+		}
+	}
+}
+
+func TestExportStopsOnError(t *testing.T) {
+	storage := &memoryStorage{entries: newEntries(3)}
+	errWrite := errors.New("client gone")
+
+	calls := 0
+	err := NewAuditLog(storage).Export(Filter{}, func(entry *Entry) error {
+		calls++
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) || calls != 1 {
+		t.Errorf("Export error = %v after %d calls, want write error after 1", err, calls)
+	}
+}
@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"src/internal/db"
+)
+
+// AuditStorage определяет методы для работы с журналом действий. Журнал только пополняется
+type AuditStorage interface {
+	Add(entry *Entry) error
+
+	List(filter Filter) ([]Entry, error)
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) AuditStorage
+}
+
+// PostgresAuditStorage реализует AuditStorage для PostgreSQL
+type PostgresAuditStorage struct {
+	*db.Storage
+}
+
+// NewPostgresAuditStorage создаёт новый экземпляр PostgresAuditStorage
+func NewPostgresAuditStorage(sqlDB *sql.DB) *PostgresAuditStorage {
+	return &PostgresAuditStorage{Storage: db.NewStorage(sqlDB)}
+}
+
+// WithTx возвращает PostgresAuditStorage, работающий внутри транзакции tx
+func (s *PostgresAuditStorage) WithTx(tx *sql.Tx) AuditStorage {
+	return &PostgresAuditStorage{Storage: s.Storage.WithTx(tx)}
+}
+
+// Add добавляет запись в журнал
+func (s *PostgresAuditStorage) Add(entry *Entry) error {
+	query := `INSERT INTO audit_log (actor, api_key_id, ip, action, target_type, target_id, before, after)
+              VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, ''), $4, $5, $6, $7, $8)
+              RETURNING id, created_at`
+
+	return s.DB.QueryRow(query,
+		entry.Actor, entry.APIKeyID, entry.IP, entry.Action, entry.TargetType, entry.TargetID,
+		nullJSON(entry.Before), nullJSON(entry.After),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// List возвращает записи журнала по фильтру, начиная с новых.
+// Для выгрузки вместо OFFSET используется последняя полученная запись (filter.after)
+func (s *PostgresAuditStorage) List(filter Filter) ([]Entry, error) {
+	query := `SELECT id, actor, COALESCE(api_key_id::text, ''), COALESCE(ip, ''), action, target_type, target_id,
+                     before, after, created_at
+              FROM audit_log
+              WHERE ($1 = '' OR actor = $1)
+                AND ($2 = '' OR action = $2)
+                AND ($3 = '' OR target_type = $3)
+                AND ($4 = '' OR target_id = $4)
+                AND ($5::timestamptz IS NULL OR created_at >= $5)
+                AND ($6::timestamptz IS NULL OR created_at < $6)
+                AND ($9::timestamptz IS NULL OR created_at < $9 OR (created_at = $9 AND id > $10))
+              ORDER BY created_at DESC, id
+              LIMIT $7 OFFSET $8`
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if filter.after != nil {
+		afterTime, afterID = &filter.after.CreatedAt, &filter.after.ID
+	}
+
+	rows, err := s.DB.Query(query,
+		filter.Actor, filter.Action, filter.TargetType, filter.TargetID,
+		filter.From, filter.To, filter.Limit, filter.Offset, afterTime, afterID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.APIKeyID, &entry.IP, &entry.Action, &entry.TargetType,
+			&entry.TargetID, &before, &after, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Пустой снимок сохраняется как NULL
+func nullJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Домен адресов обезличенных пользователей, которым передаются заказы удалённых аккаунтов
+const anonymousEmailDomain = "deleted.invalid"
+
+// ChangePassword меняет пароль по текущему. Остальные сессии пользователя завершаются,
+// текущая (currentID) сохраняется
+func (s *AuthManager) ChangePassword(email, currentPassword, newPassword string, currentID uuid.UUID, client ClientInfo) error {
+	user, err := s.checkPassword(email, currentPassword, client, "invalid password on password change")
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := s.userStorage.UpdatePassword(user.Login, hashedPassword); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	tokens, err := s.refreshTokenStorage.DeleteAllByEmail(user.Login, currentID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.revokeAccessTokens(user.Login, tokens)
+
+	s.addSecurityEvent(user.Login, SecurityEventPasswordChanged, client, "")
+	return nil
+}
+
+// RequestEmailChange проверяет пароль и отправляет код подтверждения на новый адрес.
+// Адрес меняется только после подтверждения кода (ConfirmEmailChange)
+func (s *AuthManager) RequestEmailChange(email, newEmail, password string, client ClientInfo) error {
+	if strings.EqualFold(email, newEmail) {
+		return errors.New(ErrSameEmail)
+	}
+
+	if _, err := s.checkPassword(email, password, client, "invalid password on email change"); err != nil {
+		return err
+	}
+
+	existingUser, err := s.userStorage.GetByEmail(newEmail)
+	if err != nil {
+		return fmt.Errorf("failed to check user existence: %w", err)
+	}
+	if existingUser != nil {
+		return errors.New(ErrUserAlreadyExists)
+	}
+
+	code := s.generateVerificationCode()
+	data := &EmailChangeData{
+		Email:     email,
+		NewEmail:  newEmail,
+		CodeHash:  s.hashCode(newEmail, code),
+		ExpiresAt: time.Now().Add(s.config.VerificationTTL),
+	}
+	if err := s.emailChangeStorage.Save(data); err != nil {
+		return fmt.Errorf("failed to save email change: %w", err)
+	}
+
+	if err := s.emailSender.SendEmailChangeCode(newEmail, code); err != nil {
+		s.emailChangeStorage.Delete(email)
+		return fmt.Errorf("failed to queue email change code: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange проверяет код с нового адреса и переносит на него аккаунт.
+// Все сессии и access токены старого адреса отзываются, взамен выдаётся новая пара токенов
+func (s *AuthManager) ConfirmEmailChange(email, code string, client ClientInfo) (*TokenResponse, error) {
+	data, err := s.emailChangeStorage.UseAttempt(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+	if data == nil {
+		return nil, errors.New(ErrEmailChangeNotFound)
+	}
+
+	if data.Attempts > maxCodeAttempts {
+		s.emailChangeStorage.Delete(email)
+		return nil, errors.New(ErrTooManyAttempts)
+	}
+
+	if data.CodeHash != s.hashCode(data.NewEmail, code) {
+		return nil, errors.New(ErrInvalidCode)
+	}
+
+	if err := s.accountStorage.ChangeEmail(email, data.NewEmail); err != nil {
+		if err.Error() == ErrUserAlreadyExists {
+			s.emailChangeStorage.Delete(email)
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to change email: %w", err)
+	}
+	s.emailChangeStorage.Delete(email)
+	// Коды в Redis или памяти не удаляются в транзакции смены адреса
+	s.verificationStorage.Delete(email)
+	s.resetPasswordStorage.Delete(email)
+
+	if err := s.tokenRevoker.Revoke(email); err != nil {
+		log.Printf("auth: failed to revoke access tokens of %s: %v", email, err)
+	}
+
+	log.Printf("auth: email %s changed to %s", email, data.NewEmail)
+	s.addSecurityEvent(data.NewEmail, SecurityEventEmailChanged, client, "changed from "+email)
+
+	user, err := s.userStorage.GetByEmail(data.NewEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New(ErrUserNotFound)
+	}
+
+	deviceName := client.DeviceName
+	if deviceName == "" {
+		deviceName = detectDeviceName(client.UserAgent)
+	}
+	tokens, err := s.generateTokenPair(user, &RefreshTokenData{
+		FamilyID:         uuid.New(),
+		DeviceInfo:       deviceName,
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+		SessionStartedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// DeleteAccount удаляет аккаунт и персональные данные пользователя. Нужен пароль,
+// а при подключённой 2FA - ещё и код. Заказы остаются у компаний без связи с пользователем
+func (s *AuthManager) DeleteAccount(email, password, code string, client ClientInfo) error {
+	user, err := s.checkPassword(email, password, client, "invalid password on account deletion")
+	if err != nil {
+		return err
+	}
+
+	settings, err := s.mfaStorage.Get(user.Login)
+	if err != nil {
+		return fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	if settings != nil && settings.Enabled {
+		if code == "" {
+			return errors.New(ErrInvalidMFACode)
+		}
+		valid, err := s.verifyMFACode(settings, code, true, client)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			s.registerLoginFailure(user.Login, client, "invalid two-factor code on account deletion")
+			return errors.New(ErrInvalidMFACode)
+		}
+	}
+
+	anonymousEmail := "deleted-" + uuid.NewString() + "@" + anonymousEmailDomain
+	if err := s.accountStorage.Delete(user.Login, anonymousEmail); err != nil {
+		if err.Error() == ErrLastCompanyOwner {
+			return err
+		}
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+
+	if err := s.tokenRevoker.Revoke(user.Login); err != nil {
+		log.Printf("auth: failed to revoke access tokens of %s: %v", user.Login, err)
+	}
+
+	log.Printf("auth: account %s deleted, orders moved to %s", user.Login, anonymousEmail)
+	return nil
+}
+
+// Проверка пароля для действий с аккаунтом. Неверный пароль учитывается
+// в защите от перебора так же, как при входе
+func (s *AuthManager) checkPassword(email, password string, client ClientInfo, failureDetails string) (*User, error) {
+	if err := s.checkLoginAllowed(email, client.IP); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userStorage.GetByEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New(ErrUserNotFound)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		s.registerLoginFailure(email, client, failureDetails)
+		return nil, errors.New(ErrInvalidPassword)
+	}
+	return user, nil
+}
@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"database/sql/driver"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"src/migrations"
+)
+
+// Названия столбцов, в которых хранится адрес пользователя
+var emailColumnName = regexp.MustCompile(`^(email|\w+_email|\w+_by|author|assigned_to|actor|recipient)$`)
+
+// Столбцы таблиц, созданных до появления миграций
+var legacyEmailColumns = []string{
+	"all_users.login", "ts_users.email", "orders.users", "partners_users.email",
+	"admin.email", "part_req.user_email", "refresh_tokens.email",
+}
+
+var (
+	createTablePattern = regexp.MustCompile(`(?is)CREATE TABLE IF NOT EXISTS (\w+) \((.*?)\n\);`)
+	columnDefPattern   = regexp.MustCompile(`(?m)^\s+(\w+)\s+\w+`)
+	alterTablePattern  = regexp.MustCompile(`(?is)ALTER TABLE (\w+)(.*?);`)
+	addColumnPattern   = regexp.MustCompile(`(?i)ADD COLUMN IF NOT EXISTS (\w+)`)
+)
+
+// Текст всех миграций и их столбцы вида table.column
+func migrationSchema(t *testing.T) (string, map[string]bool) {
+	t.Helper()
+	files, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all strings.Builder
+	columns := map[string]bool{}
+	for _, name := range files {
+		data, err := fs.ReadFile(migrations.FS, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sql := string(data)
+		all.WriteString(sql)
+
+		for _, table := range createTablePattern.FindAllStringSubmatch(sql, -1) {
+			for _, column := range columnDefPattern.FindAllStringSubmatch(table[2], -1) {
+				switch strings.ToUpper(column[1]) {
+				case "PRIMARY", "FOREIGN", "UNIQUE", "CONSTRAINT", "CHECK":
+					continue
+				}
+				columns[table[1]+"."+column[1]] = true
+			}
+		}
+		for _, alter := range alterTablePattern.FindAllStringSubmatch(sql, -1) {
+			for _, column := range addColumnPattern.FindAllStringSubmatch(alter[2], -1) {
+				columns[alter[1]+"."+column[1]] = true
+			}
+		}
+	}
+	return all.String(), columns
+}
+
+// Каждый столбец с адресом из миграций есть в accountEmailColumns, и каждый столбец списка существует
+func TestAccountEmailColumnsCoverMigrations(t *testing.T) {
+	_, columns := migrationSchema(t)
+	if len(columns) == 0 {
+		t.Fatal("no columns parsed from migrations")
+	}
+	for _, column := range legacyEmailColumns {
+		columns[column] = true
+	}
+
+	registered := map[string]bool{}
+	for _, column := range accountEmailColumns {
+		name := column.Table + "." + column.Column
+		registered[name] = true
+		if !columns[name] {
+			t.Errorf("%s is registered but not found in migrations", name)
+		}
+	}
+	for name := range columns {
+		column := name[strings.Index(name, ".")+1:]
+		if emailColumnName.MatchString(column) && !registered[name] {
+			t.Errorf("%s holds an email but is missing from accountEmailColumns", name)
+		}
+	}
+}
+
+// Запросы, которые выполняет fn с хранилищем на sqlmock
+func captureAccountQueries(t *testing.T, fn func(*PostgresAccountStorage) error) []string {
+	t.Helper()
+	var queries []string
+	// При сопоставлении без порядка sqlmock может сверить один запрос с несколькими ожиданиями
+	matcher := sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+		query := strings.Join(strings.Fields(actual), " ")
+		if len(queries) == 0 || queries[len(queries)-1] != query {
+			queries = append(queries, query)
+		}
+		return nil
+	})
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectBegin()
+	mock.ExpectQuery("").WillReturnRows(sqlmock.NewRows([]string{"last_owner"}).AddRow(false))
+	for i := 0; i < 100; i++ {
+		mock.ExpectExec("").WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	if err := fn(&PostgresAccountStorage{db: sqlDB, cipher: newTestCipher(t, 1)}); err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	return queries
+}
+
+// Проверка, что запрос обрабатывает столбец так, как указано в accountEmailColumns
+func emailColumnHandled(queries []string, schema string, column accountEmailColumn, policy string) bool {
+	table, name := regexp.QuoteMeta(column.Table), regexp.QuoteMeta(column.Column)
+	var pattern *regexp.Regexp
+	switch policy {
+	case emailColumnMove:
+		pattern = regexp.MustCompile(`^(UPDATE ` + table + ` SET ` + name + ` = \$2|INSERT INTO ` + table + ` \(` + name + `\b.*\$2)`)
+	case emailColumnDelete:
+		pattern = regexp.MustCompile(`^DELETE FROM ` + table + `\b.*\b` + name + `\b`)
+	case emailColumnAnonymize:
+		pattern = regexp.MustCompile(`^UPDATE ` + table + ` SET ` + name + ` = \$2`)
+	case emailColumnClear:
+		pattern = regexp.MustCompile(`^UPDATE ` + table + ` SET ` + name + ` = NULL`)
+	case emailColumnRevoke:
+		pattern = regexp.MustCompile(`^INSERT INTO ` + table + ` \(` + name + `\b.*NOW\(\)`)
+	case emailColumnKeep:
+		assigned := regexp.MustCompile(`^(UPDATE|INSERT INTO|DELETE FROM) ` + table + `\b.*\b` + name + `\b`)
+		for _, query := range queries {
+			if assigned.MatchString(query) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+
+	for _, query := range queries {
+		if pattern.MatchString(query) {
+			return true
+		}
+	}
+	// Строки, которые переносятся каскадом по внешнему ключу
+	return policy == emailColumnMove && regexp.MustCompile(`(?s)ALTER TABLE `+table+` ADD CONSTRAINT .*?ON UPDATE CASCADE`).MatchString(schema)
+}
+
+func TestAccountEmailColumns(t *testing.T) {
+	schema, _ := migrationSchema(t)
+	changeQueries := captureAccountQueries(t, func(s *PostgresAccountStorage) error {
+		return s.ChangeEmail("old@example.com", "new@example.com")
+	})
+	deleteQueries := captureAccountQueries(t, func(s *PostgresAccountStorage) error {
+		return s.Delete("old@example.com", "deleted-1@deleted.invalid")
+	})
+
+	for _, column := range accountEmailColumns {
+		if !emailColumnHandled(changeQueries, schema, column, column.OnChange) {
+			t.Errorf("ChangeEmail does not %s %s.%s", column.OnChange, column.Table, column.Column)
+		}
+		if !emailColumnHandled(deleteQueries, schema, column, column.OnDelete) {
+			t.Errorf("Delete does not %s %s.%s", column.OnDelete, column.Table, column.Column)
+		}
+	}
+}
+
+// all_users копируется со всеми столбцами, иначе смена адреса снимает блокировку администратора
+func TestChangeEmailCopiesUserColumns(t *testing.T) {
+	queries := captureAccountQueries(t, func(s *PostgresAccountStorage) error {
+		return s.ChangeEmail("old@example.com", "new@example.com")
+	})
+	_, columns := migrationSchema(t)
+
+	copyUser := queries[0]
+	if !strings.HasPrefix(copyUser, "INSERT INTO all_users") {
+		t.Fatalf("first statement = %q", copyUser)
+	}
+	for name := range columns {
+		if column, ok := strings.CutPrefix(name, "all_users."); ok && !strings.Contains(copyUser, column) {
+			t.Errorf("all_users.%s is not copied", column)
+		}
+	}
+}
+
+func TestChangeEmailToTakenAddress(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO all_users`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = (&PostgresAccountStorage{db: sqlDB}).ChangeEmail("old@example.com", "taken@example.com")
+	if err == nil || err.Error() != ErrUserAlreadyExists {
+		t.Fatalf("ChangeEmail = %v, want %s", err, ErrUserAlreadyExists)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Адрес удалённого пользователя не остаётся в письмах очереди, в том числе в приглашениях других пользователей
+func TestDeleteAccountClearsOutbox(t *testing.T) {
+	queries := captureAccountQueries(t, func(s *PostgresAccountStorage) error {
+		return s.Delete("old@example.com", "deleted-1@deleted.invalid")
+	})
+
+	var deleted, anonymized bool
+	for _, query := range queries {
+		deleted = deleted || strings.HasPrefix(query, "DELETE FROM outbox_messages WHERE channel = 'email' AND recipient = $1")
+		anonymized = anonymized || strings.HasPrefix(query, "UPDATE outbox_messages SET payload = jsonb_set(payload, '{invited_by}', to_jsonb($2::text))") &&
+			strings.Contains(query, "payload->>'invited_by' = $1")
+	}
+	if !deleted {
+		t.Error("messages to the deleted user are kept")
+	}
+	if !anonymized {
+		t.Error("invitations sent by the deleted user keep the address")
+	}
+}
+
+// Запоминает переданный в запрос аргумент
+type capturedArg struct {
+	value string
+}
+
+func (a *capturedArg) Match(v driver.Value) bool {
+	a.value, _ = v.(string)
+	return true
+}
+
+// После смены адреса секрет TOTP расшифровывается с новым адресом и не расшифровывается со старым
+func TestChangeEmailResealsMFASecret(t *testing.T) {
+	cipher := newTestCipher(t, 1)
+	sealed, err := cipher.Seal(testTOTPSecret, "old@example.com")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	resealed := &capturedArg{}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT secret FROM user_mfa WHERE email = $1`)).
+		WithArgs("new@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"secret"}).AddRow(sealed))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_mfa SET secret = $2 WHERE email = $1`)).
+		WithArgs("new@example.com", resealed).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := resealMFASecret(sqlDB, cipher, "old@example.com", "new@example.com"); err != nil {
+		t.Fatalf("resealMFASecret: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	if secret, _, err := cipher.Open(resealed.value, "new@example.com"); err != nil || secret != testTOTPSecret {
+		t.Errorf("Open for new address = %q, %v", secret, err)
+	}
+	if _, _, err := cipher.Open(resealed.value, "old@example.com"); err == nil {
+		t.Error("secret still opens for the old address")
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+type memorySecurityEvents struct {
+	SecurityEventStorage
+	events []SecurityEvent
+}
+
+func (s *memorySecurityEvents) Add(event *SecurityEvent) error {
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func TestHashCodeUsesSecret(t *testing.T) {
+	ta := newTestAuth(t)
+	hash := ta.hashCode("user@example.com", "123456")
+
+	if hash != ta.hashCode("user@example.com", "123456") {
+		t.Fatal("hash is not deterministic")
+	}
+	if hash == hashToken("user@example.com:123456") {
+		t.Error("hash does not depend on the secret")
+	}
+	if hash == ta.hashCode("other@example.com", "123456") {
+		t.Error("hash does not depend on the email")
+	}
+
+	other := newTestAuth(t)
+	other.codeHashSecret = []byte("another-code-hash-secret-0123456789abcdef")
+	if hash == other.hashCode("user@example.com", "123456") {
+		t.Error("hash is the same with a different secret")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	ta := newTestAuth(t)
+	codes, hashes, err := ta.generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("generateRecoveryCodes: %v", err)
+	}
+
+	mfa := &memoryMFA{recoveryCodes: map[string]bool{}}
+	for _, hash := range hashes {
+		mfa.recoveryCodes[hash] = true
+	}
+	events := &memorySecurityEvents{}
+	ta.mfaStorage = mfa
+	ta.securityEventStorage = events
+	settings := &MFASettings{Email: "user@example.com", Secret: "JBSWY3DPEHPK3PXP"}
+
+	cases := []struct {
+		name          string
+		code          string
+		allowRecovery bool
+		want          bool
+	}{
+		{"issued code", codes[0], true, true},
+		{"issued code in upper case without dash", "  " + strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), true, true},
+		{"code used twice", codes[0], true, false},
+		{"unknown code", "ffff-ffff", true, false},
+		{"recovery not allowed", codes[2], false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			used, err := ta.verifyMFACode(settings, tc.code, tc.allowRecovery, ClientInfo{})
+			if err != nil {
+				t.Fatalf("verifyMFACode: %v", err)
+			}
+			if used != tc.want {
+				t.Errorf("verifyMFACode(%q) = %v, want %v", tc.code, used, tc.want)
+			}
+		})
+	}
+
+	if len(events.events) != 2 {
+		t.Errorf("security events = %d, want 2", len(events.events))
+	}
+}
@@ -2,8 +2,18 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	"src/internal/mail"
 	"src/internal/middleware"
 )
 
@@ -41,7 +51,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.auth.Register(req.Email, req.Password)
+	err := h.auth.Register(req.Email, req.Password, mail.MatchLanguage(r.Header.Get("Accept-Language")))
 	if err != nil {
 		switch err.Error() {
 		case ErrUserAlreadyExists:
@@ -83,6 +93,8 @@ func (h *Handler) VerifyCode(w http.ResponseWriter, r *http.Request) {
 		switch err.Error() {
 		case ErrInvalidCode, ErrCodeExpired:
 			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrTooManyAttempts:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -114,11 +126,143 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokens, err := h.auth.Login(req.Email, req.Password)
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	tokens, err := h.auth.Login(req.Email, req.Password, clientInfo(r, req.DeviceName))
 	if err != nil {
 		switch err.Error() {
 		case ErrUserNotFound, ErrInvalidPassword:
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		case ErrAccountSuspended:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case ErrAccountLocked, ErrTooManyLoginAttempts:
+			var blocked *LoginBlockedError
+			if errors.As(err, &blocked) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			}
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case ErrMFARequired, ErrMFASetupRequired:
+			var mfaErr *MFARequiredError
+			if !errors.As(err, &mfaErr) {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(MFAChallengeResponse{
+				Error:     mfaErr.Reason,
+				MFAToken:  mfaErr.Token,
+				ExpiresIn: mfaErr.ExpiresIn,
+			})
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// GetOIDCProviders обрабатывает GET /auth/oidc/providers, возвращает доступных провайдеров входа
+func (h *Handler) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{
+		"providers": h.auth.OIDCProviders(),
+	})
+}
+
+// OIDCAuthorize обрабатывает POST /auth/oidc/{provider}/authorize, начинает вход через провайдера
+func (h *Handler) OIDCAuthorize(w http.ResponseWriter, r *http.Request) {
+	// Тело необязательно
+	var req OIDCAuthorizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	authorization, err := h.auth.StartOIDCLogin(chi.URLParam(r, "provider"), req.DeviceName)
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// OIDCCallback обрабатывает POST /auth/oidc/{provider}/callback, завершает вход кодом от провайдера
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	var req OIDCCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	tokens, err := h.auth.CompleteOIDCLogin(chi.URLParam(r, "provider"), req.Code, req.State, req.DeviceID, clientInfo(r, ""))
+	if err != nil {
+		var mfaErr *MFARequiredError
+		if errors.As(err, &mfaErr) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(MFAChallengeResponse{
+				Error:     mfaErr.Reason,
+				MFAToken:  mfaErr.Token,
+				ExpiresIn: mfaErr.ExpiresIn,
+			})
+			return
+		}
+		writeOIDCError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// VerifyMFALogin обрабатывает POST /auth/login/2fa, второй шаг входа с кодом 2FA
+func (h *Handler) VerifyMFALogin(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	tokens, err := h.auth.VerifyMFALogin(req.MFAToken, req.Code, clientInfo(r, ""))
+	if err != nil {
+		switch err.Error() {
+		case ErrInvalidMFAToken, ErrInvalidMFACode:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case ErrAccountSuspended:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case ErrMFANotStarted:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case ErrTooManyAttempts:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case ErrAccountLocked, ErrTooManyLoginAttempts:
+			var blocked *LoginBlockedError
+			if errors.As(err, &blocked) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			}
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -129,6 +273,36 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tokens)
 }
 
+// SetupMFALogin обрабатывает POST /auth/login/2fa/setup, выдаёт секрет для подключения обязательной 2FA при входе
+func (h *Handler) SetupMFALogin(w http.ResponseWriter, r *http.Request) {
+	var req MFALoginSetupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	setup, err := h.auth.SetupMFALogin(req.MFAToken)
+	if err != nil {
+		switch err.Error() {
+		case ErrInvalidMFAToken:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case ErrMFAAlreadyEnabled:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(setup)
+}
+
 // ForgotPassword обрабатывает POST /auth/forgot-password, отправляет код для восстановления пароля
 func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	var req ForgotPasswordRequest
@@ -142,7 +316,7 @@ func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.auth.ForgotPassword(req.Email)
+	err := h.auth.ForgotPassword(req.Email, mail.MatchLanguage(r.Header.Get("Accept-Language")))
 	if err != nil {
 		switch err.Error() {
 		case ErrUserNotFound:
@@ -178,6 +352,8 @@ func (h *Handler) VerifyResetCode(w http.ResponseWriter, r *http.Request) {
 		switch err.Error() {
 		case ErrInvalidCode, ErrCodeExpired:
 			http.Error(w, err.Error(), http.StatusBadRequest)
+		case ErrTooManyAttempts:
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -203,7 +379,7 @@ func (h *Handler) SetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.auth.SetPassword(req.Email, req.NewPassword)
+	err := h.auth.SetPassword(req.Email, req.NewPassword, clientInfo(r, ""))
 	if err != nil {
 		switch err.Error() {
 		case "reset session not found or expired", "code not verified":
@@ -220,6 +396,13 @@ func (h *Handler) SetPassword(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// JWKS обрабатывает GET /.well-known/jwks.json, отдаёт открытые ключи проверки токенов
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.auth.JWKS())
+}
+
 // Refresh обрабатывает POST /auth/refresh, работает с токенами
 func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -238,11 +421,13 @@ func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokens, err := h.auth.RefreshTokens(req.RefreshToken)
+	tokens, err := h.auth.RefreshTokens(req.RefreshToken, clientInfo(r, ""))
 	if err != nil {
 		switch err.Error() {
-		case ErrInvalidRefreshToken, ErrRefreshTokenExpired, ErrUserNotFound:
+		case ErrInvalidRefreshToken, ErrRefreshTokenExpired, ErrRefreshTokenReused, ErrUserNotFound:
 			http.Error(w, err.Error(), http.StatusUnauthorized)
+		case ErrAccountSuspended:
+			http.Error(w, err.Error(), http.StatusForbidden)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -288,3 +473,541 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 		"message": "Successfully logged out",
 	})
 }
+
+// GetSessions обрабатывает GET /auth/sessions, возвращает активные сессии пользователя
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	email, sessionID, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.auth.GetSessions(email, sessionID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// DeleteSession обрабатывает DELETE /auth/sessions/{id}, завершает сессию на другом устройстве
+func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.auth.DeleteSession(email, id); err != nil {
+		switch err.Error() {
+		case ErrSessionNotFound:
+			http.Error(w, "Session not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutOtherSessions обрабатывает DELETE /auth/sessions, завершает все сессии, кроме текущей
+func (h *Handler) LogoutOtherSessions(w http.ResponseWriter, r *http.Request) {
+	email, sessionID, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Без идентификатора текущей сессии нельзя понять, какую сессию оставить
+	if sessionID == uuid.Nil {
+		http.Error(w, "Token is outdated, refresh it", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.auth.LogoutOtherSessions(email, sessionID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Logged out from all other sessions",
+	})
+}
+
+// GetSecurityEvents обрабатывает GET /auth/security-events, возвращает журнал входов пользователя
+func (h *Handler) GetSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 200 {
+			http.Error(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.auth.GetSecurityEvents(email, limit)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// UnlockAccount обрабатывает POST /admin/users/{email}/unlock, снимает блокировку входа
+func (h *Handler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	email := chi.URLParam(r, "email")
+	if err := h.auth.UnlockAccount(audit.ActorFromRequest(r), email); err != nil {
+		switch err.Error() {
+		case ErrUserNotFound:
+			http.Error(w, "User not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account unlocked",
+		"email":   email,
+	})
+}
+
+// SuspendUser обрабатывает POST /admin/users/{email}/suspend, блокирует пользователя
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	var req SuspendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	email := chi.URLParam(r, "email")
+	if err := h.auth.SuspendUser(audit.ActorFromRequest(r), email, req.Reason); err != nil {
+		writeSuspensionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account suspended",
+		"email":   email,
+	})
+}
+
+// ReinstateUser обрабатывает POST /admin/users/{email}/reinstate, снимает блокировку пользователя
+func (h *Handler) ReinstateUser(w http.ResponseWriter, r *http.Request) {
+	var req ReinstateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	email := chi.URLParam(r, "email")
+	if err := h.auth.ReinstateUser(audit.ActorFromRequest(r), email, req.Comment); err != nil {
+		writeSuspensionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account reinstated",
+		"email":   email,
+	})
+}
+
+// Ответ с ошибкой блокировки пользователя
+func writeSuspensionError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case ErrUserNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case ErrSuspendSelf:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrAlreadySuspended, ErrNotSuspended:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// GetMFAStatus обрабатывает GET /auth/2fa, возвращает состояние 2FA пользователя
+func (h *Handler) GetMFAStatus(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.auth.GetMFAStatus(email)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// SetupMFA обрабатывает POST /auth/2fa/setup, начинает подключение 2FA
+func (h *Handler) SetupMFA(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	setup, err := h.auth.SetupMFA(email)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(setup)
+}
+
+// EnableMFA обрабатывает POST /auth/2fa/enable, подтверждает подключение 2FA кодом из приложения
+func (h *Handler) EnableMFA(w http.ResponseWriter, r *http.Request) {
+	email, req, ok := decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.auth.EnableMFA(email, req.Code, clientInfo(r, ""))
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA обрабатывает POST /auth/2fa/disable, отключает 2FA
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	email, req, ok := decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.auth.DisableMFA(email, req.Code, clientInfo(r, "")); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes обрабатывает POST /auth/2fa/recovery-codes, выдаёт новые коды восстановления
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	email, req, ok := decodeMFACodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.auth.RegenerateRecoveryCodes(email, req.Code, clientInfo(r, ""))
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// GetAdminMFAPolicy обрабатывает GET /admin/security/2fa, возвращает обязательность 2FA для администраторов
+func (h *Handler) GetAdminMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	required, err := h.auth.GetAdminMFARequired()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"required": required})
+}
+
+// SetAdminMFAPolicy обрабатывает PUT /admin/security/2fa, включает или отключает обязательную 2FA для администраторов
+func (h *Handler) SetAdminMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	var req MFAPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	if err := h.auth.SetAdminMFARequired(audit.ActorFromRequest(r), *req.Required); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"required": *req.Required})
+}
+
+// ChangePassword обрабатывает POST /auth/change-password, меняет пароль по текущему
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	email, sessionID, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	if err := h.auth.ChangePassword(email, req.CurrentPassword, req.NewPassword, sessionID, clientInfo(r, "")); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Password changed successfully",
+	})
+}
+
+// ChangeEmail обрабатывает POST /auth/change-email, отправляет код подтверждения на новый адрес
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	if err := h.auth.RequestEmailChange(email, req.NewEmail, req.Password, clientInfo(r, "")); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message":   "Confirmation code sent to new email",
+		"new_email": req.NewEmail,
+	})
+}
+
+// ConfirmEmailChange обрабатывает POST /auth/change-email/confirm, подтверждает новый адрес кодом из письма
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req ConfirmEmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	tokens, err := h.auth.ConfirmEmailChange(email, req.Code, clientInfo(r, ""))
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// DeleteAccount обрабатывает DELETE /auth/account, удаляет аккаунт пользователя
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	if err := h.auth.DeleteAccount(email, req.Password, req.Code, clientInfo(r, "")); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account deleted",
+	})
+}
+
+// Ответ с ошибкой действий с аккаунтом
+func writeAccountError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case ErrInvalidPassword, ErrInvalidMFACode:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case ErrUserNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case ErrSameEmail, ErrInvalidCode:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrEmailChangeNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrUserAlreadyExists, ErrLastCompanyOwner:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case ErrTooManyAttempts:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case ErrAccountLocked, ErrTooManyLoginAttempts:
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Ответ с ошибкой входа через провайдера
+func writeOIDCError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case ErrUnknownOIDCProvider:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case ErrInvalidOIDCState:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case ErrOIDCEmailMissing, ErrOIDCEmailNotVerified:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case ErrUserNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case ErrUserAlreadyExists:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case ErrAccountSuspended:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case ErrOIDCProviderFailed:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Получение email пользователя и запроса с кодом 2FA
+func decodeMFACodeRequest(w http.ResponseWriter, r *http.Request) (string, MFACodeRequest, bool) {
+	var req MFACodeRequest
+
+	email, _, ok := sessionFromRequest(w, r)
+	if !ok {
+		return "", req, false
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", req, false
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return "", req, false
+	}
+	return email, req, true
+}
+
+// Ответ с ошибкой управления 2FA
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch err.Error() {
+	case ErrInvalidMFACode:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case ErrMFAPolicyRequired:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case ErrMFANotStarted, ErrMFANotEnabled, ErrMFAAlreadyEnabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case ErrAccountLocked, ErrTooManyLoginAttempts:
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Получение email и ID текущей сессии из claims access токена.
+// Токены, выданные до появления сессий, не содержат sid - в этом случае возвращается uuid.Nil
+func sessionFromRequest(w http.ResponseWriter, r *http.Request) (string, uuid.UUID, bool) {
+	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	if !ok {
+		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
+		return "", uuid.Nil, false
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		return "", uuid.Nil, false
+	}
+
+	sid, _ := claims["sid"].(string)
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		sessionID = uuid.Nil
+	}
+	return email, sessionID, true
+}
+
+// Данные клиента из запроса
+func clientInfo(r *http.Request, deviceName string) ClientInfo {
+	return ClientInfo{
+		DeviceName: deviceName,
+		UserAgent:  r.UserAgent(),
+		IP:         middleware.ClientIP(r),
+		Lang:       mail.MatchLanguage(r.Header.Get("Accept-Language")),
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	configPkg "src/internal/config"
+)
+
+// Счётчики неудачных попыток входа в памяти
+type memoryLoginAttempts struct {
+	failures map[string]*LoginFailures
+}
+
+func (s *memoryLoginAttempts) Get(key string) (*LoginFailures, error) {
+	return s.failures[key], nil
+}
+
+func (s *memoryLoginAttempts) RegisterFailure(key string, window time.Duration) (*LoginFailures, error) {
+	failures := s.failures[key]
+	if failures == nil {
+		failures = &LoginFailures{Key: key}
+		s.failures[key] = failures
+	}
+	failures.Failures++
+	failures.LastFailureAt = time.Now()
+	copied := *failures
+	return &copied, nil
+}
+
+func (s *memoryLoginAttempts) Lock(key string, until time.Time) error {
+	s.failures[key].LockedUntil = &until
+	return nil
+}
+
+func (s *memoryLoginAttempts) Reset(key string) error {
+	delete(s.failures, key)
+	return nil
+}
+
+type lockoutAuth struct {
+	*testAuth
+	attempts *memoryLoginAttempts
+	events   *memorySecurityEvents
+}
+
+func newLockoutAuth(t *testing.T) *lockoutAuth {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("1A_password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	la := &lockoutAuth{
+		testAuth: newTestAuth(t),
+		attempts: &memoryLoginAttempts{failures: map[string]*LoginFailures{}},
+		events:   &memorySecurityEvents{},
+	}
+	la.users.users["user@example.com"].Password = string(hash)
+	la.loginAttemptStorage = la.attempts
+	la.securityEventStorage = la.events
+	la.loginProtection = configPkg.LoginProtectionConfig{
+		DelayAfter:         100,
+		LockoutThreshold:   3,
+		IPLockoutThreshold: 100,
+		LockoutDuration:    15 * time.Minute,
+		FailureWindow:      time.Hour,
+	}
+	return la
+}
+
+// Ответы для существующего и незарегистрированного адреса не различаются: одинаковые ошибки до и после блокировки
+func TestLoginLockoutSameForUnknownEmail(t *testing.T) {
+	la := newLockoutAuth(t)
+	client := ClientInfo{IP: "203.0.113.7"}
+
+	for i := 1; i <= 4; i++ {
+		_, knownErr := la.Login("user@example.com", "wrong", client)
+		_, unknownErr := la.Login("nobody@example.com", "wrong", client)
+		if knownErr == nil || unknownErr == nil {
+			t.Fatalf("attempt %d: login succeeded", i)
+		}
+
+		var knownBlocked, unknownBlocked *LoginBlockedError
+		isKnownBlocked := errors.As(knownErr, &knownBlocked)
+		isUnknownBlocked := errors.As(unknownErr, &unknownBlocked)
+		if isKnownBlocked != isUnknownBlocked {
+			t.Fatalf("attempt %d: known = %v, unknown = %v", i, knownErr, unknownErr)
+		}
+		if isKnownBlocked {
+			if i <= 3 {
+				t.Fatalf("attempt %d: blocked before threshold", i)
+			}
+			if knownBlocked.Reason != ErrAccountLocked || unknownBlocked.Reason != ErrAccountLocked {
+				t.Errorf("attempt %d: reasons %q and %q", i, knownBlocked.Reason, unknownBlocked.Reason)
+			}
+			if diff := knownBlocked.RetryAfter - unknownBlocked.RetryAfter; diff > time.Second || diff < -time.Second {
+				t.Errorf("attempt %d: retry after %v and %v", i, knownBlocked.RetryAfter, unknownBlocked.RetryAfter)
+			}
+			continue
+		}
+		if i > 3 {
+			t.Fatalf("attempt %d: not blocked after threshold: %v", i, knownErr)
+		}
+		// Обработчик отвечает на обе ошибки одинаково: 401 Invalid credentials
+		if knownErr.Error() != ErrInvalidPassword || unknownErr.Error() != ErrUserNotFound {
+			t.Errorf("attempt %d: known = %v, unknown = %v", i, knownErr, unknownErr)
+		}
+	}
+
+	// Письмо и события только у существующего аккаунта
+	if len(la.email.lockedAlerts) != 1 || la.email.lockedAlerts[0] != "user@example.com" {
+		t.Errorf("locked alerts = %v", la.email.lockedAlerts)
+	}
+	for _, event := range la.events.events {
+		if event.Email != "user@example.com" {
+			t.Errorf("security event for %s", event.Email)
+		}
+	}
+}
+
+// Во время блокировки верный пароль не принимается
+func TestLoginLockedRejectsCorrectPassword(t *testing.T) {
+	la := newLockoutAuth(t)
+	for i := 0; i < 3; i++ {
+		la.Login("user@example.com", "wrong", ClientInfo{})
+	}
+
+	_, err := la.Login("user@example.com", "1A_password", ClientInfo{})
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.Reason != ErrAccountLocked {
+		t.Fatalf("Login = %v, want account locked", err)
+	}
+	if len(la.tokens.tokens) != 0 {
+		t.Error("tokens issued for locked account")
+	}
+}
+
+// Блокировка IP адреса учитывает попытки по любым адресам, включая незарегистрированные
+func TestLoginIPLockout(t *testing.T) {
+	la := newLockoutAuth(t)
+	la.loginProtection.IPLockoutThreshold = 2
+	client := ClientInfo{IP: "203.0.113.7"}
+
+	la.Login("nobody@example.com", "wrong", client)
+	la.Login("other@example.com", "wrong", client)
+
+	_, err := la.Login("user@example.com", "1A_password", client)
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.Reason != ErrTooManyLoginAttempts {
+		t.Fatalf("Login = %v, want too many attempts", err)
+	}
+
+	// С другого адреса вход разрешён
+	if _, err := la.Login("user@example.com", "wrong", ClientInfo{IP: "198.51.100.1"}); err == nil || err.Error() != ErrInvalidPassword {
+		t.Errorf("Login from other ip = %v", err)
+	}
+}
+
+// Заглушка сверяется с той же стоимостью bcrypt, что и пароли пользователей
+func TestDummyPasswordHashCost(t *testing.T) {
+	cost, err := bcrypt.Cost(dummyPasswordHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Errorf("dummy hash cost = %d, want %d", cost, bcrypt.DefaultCost)
+	}
+}
@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"src/internal/audit"
+	"src/internal/totp"
+)
+
+const (
+	// Название сервиса в приложении-аутентификаторе
+	mfaIssuer = "Pioneer"
+
+	// Время на ввод кода второго фактора после проверки пароля
+	mfaChallengeTTL = 5 * time.Minute
+
+	recoveryCodesCount = 10
+)
+
+// Проверка необходимости второго шага входа. Если 2FA подключена или обязательна,
+// создаётся MFAChallenge и возвращается MFARequiredError с его токеном
+func (s *AuthManager) checkMFARequired(email string, client ClientInfo) error {
+	settings, err := s.mfaStorage.Get(email)
+	if err != nil {
+		return fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+
+	enabled := settings != nil && settings.Enabled
+	setup := false
+	if !enabled {
+		required, err := s.mfaStorage.IsRequired(email)
+		if err != nil {
+			return fmt.Errorf("failed to check mfa policy: %w", err)
+		}
+		if !required {
+			return nil
+		}
+		setup = true
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate mfa token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	challenge := &MFAChallenge{
+		TokenHash:  hashToken(token),
+		Email:      email,
+		Setup:      setup,
+		DeviceName: client.DeviceName,
+		ExpiresAt:  time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.mfaChallengeStorage.Save(challenge); err != nil {
+		return fmt.Errorf("failed to save mfa challenge: %w", err)
+	}
+
+	reason := ErrMFARequired
+	if setup {
+		reason = ErrMFASetupRequired
+	}
+	return &MFARequiredError{Reason: reason, Token: token, ExpiresIn: int(mfaChallengeTTL.Seconds())}
+}
+
+// VerifyMFALogin - второй шаг входа: проверка кода из приложения или кода восстановления.
+// Если 2FA подключается при входе (ErrMFASetupRequired), код подтверждает подключение,
+// а в ответе возвращаются коды восстановления
+func (s *AuthManager) VerifyMFALogin(token, code string, client ClientInfo) (*TokenResponse, error) {
+	tokenHash := hashToken(token)
+
+	challenge, err := s.mfaChallengeStorage.UseAttempt(tokenHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa challenge: %w", err)
+	}
+	if challenge == nil {
+		return nil, errors.New(ErrInvalidMFAToken)
+	}
+	if challenge.Attempts > maxCodeAttempts {
+		s.mfaChallengeStorage.Delete(tokenHash)
+		return nil, errors.New(ErrTooManyAttempts)
+	}
+
+	// Блокировка аккаунта действует и на второй шаг
+	if err := s.checkLoginAllowed(challenge.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userStorage.GetByEmail(challenge.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		s.mfaChallengeStorage.Delete(tokenHash)
+		return nil, errors.New(ErrInvalidMFAToken)
+	}
+	if err := checkNotSuspended(user); err != nil {
+		s.mfaChallengeStorage.Delete(tokenHash)
+		return nil, err
+	}
+
+	settings, err := s.mfaStorage.Get(user.Login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	// setup - 2FA подключается при этом входе (секрет получен через SetupMFALogin)
+	setup := settings == nil || !settings.Enabled
+	if setup && !challenge.Setup {
+		// 2FA отключили после проверки пароля, вход нужно начать заново
+		s.mfaChallengeStorage.Delete(tokenHash)
+		return nil, errors.New(ErrInvalidMFAToken)
+	}
+	if settings == nil {
+		return nil, errors.New(ErrMFANotStarted)
+	}
+
+	// При подключении принимается только код из приложения, иначе подключение не подтверждено
+	valid, err := s.verifyMFACode(settings, code, !setup, client)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		// Неверные коды учитываются вместе с неверными паролями: знание пароля не даёт перебирать коды
+		s.registerLoginFailure(user.Login, client, "invalid two-factor code")
+		return nil, errors.New(ErrInvalidMFACode)
+	}
+
+	// Токен одноразовый
+	if err := s.mfaChallengeStorage.Delete(tokenHash); err != nil {
+		return nil, fmt.Errorf("failed to delete mfa challenge: %w", err)
+	}
+
+	var recoveryCodes []string
+	if setup {
+		recoveryCodes, err = s.enableMFA(user.Login, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = challenge.DeviceName
+	}
+	tokens, err := s.completeLogin(user, client)
+	if err != nil {
+		return nil, err
+	}
+	tokens.RecoveryCodes = recoveryCodes
+	return tokens, nil
+}
+
+// SetupMFALogin выдаёт секрет для подключения 2FA при входе, если она обязательна для пользователя
+func (s *AuthManager) SetupMFALogin(token string) (*MFASetupResponse, error) {
+	challenge, err := s.mfaChallengeStorage.Get(hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa challenge: %w", err)
+	}
+	if challenge == nil || !challenge.Setup {
+		return nil, errors.New(ErrInvalidMFAToken)
+	}
+
+	return s.SetupMFA(challenge.Email)
+}
+
+// GetMFAStatus возвращает состояние 2FA пользователя
+func (s *AuthManager) GetMFAStatus(email string) (*MFAStatus, error) {
+	settings, err := s.mfaStorage.Get(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	required, err := s.mfaStorage.IsRequired(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check mfa policy: %w", err)
+	}
+
+	status := &MFAStatus{Required: required}
+	if settings != nil && settings.Enabled {
+		status.Enabled = true
+		status.RecoveryCodesLeft, err = s.mfaStorage.CountRecoveryCodes(email)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// SetupMFA создаёт новый секрет. 2FA включается только после подтверждения кодом (EnableMFA)
+func (s *AuthManager) SetupMFA(email string) (*MFASetupResponse, error) {
+	settings, err := s.mfaStorage.Get(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	if settings != nil && settings.Enabled {
+		return nil, errors.New(ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mfa secret: %w", err)
+	}
+	if err := s.mfaStorage.SaveSecret(email, secret); err != nil {
+		return nil, fmt.Errorf("failed to save mfa secret: %w", err)
+	}
+
+	return &MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURL: totp.URL(mfaIssuer, email, secret),
+	}, nil
+}
+
+// EnableMFA подтверждает подключение 2FA первым кодом из приложения и возвращает коды восстановления
+func (s *AuthManager) EnableMFA(email, code string, client ClientInfo) ([]string, error) {
+	settings, err := s.mfaStorage.Get(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	if settings == nil {
+		return nil, errors.New(ErrMFANotStarted)
+	}
+	if settings.Enabled {
+		return nil, errors.New(ErrMFAAlreadyEnabled)
+	}
+
+	if err := s.checkAccountMFACode(settings, code, false, client, "invalid two-factor code on enabling"); err != nil {
+		return nil, err
+	}
+
+	return s.enableMFA(email, client)
+}
+
+// Включение 2FA после проверки кода
+func (s *AuthManager) enableMFA(email string, client ClientInfo) ([]string, error) {
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaStorage.Enable(email, hashes); err != nil {
+		return nil, fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	s.addSecurityEvent(email, SecurityEventMFAEnabled, client, "")
+	return codes, nil
+}
+
+// DisableMFA отключает 2FA. Нужен действующий код (из приложения или восстановления).
+// Если 2FA обязательна для пользователя, отключить её нельзя
+func (s *AuthManager) DisableMFA(email, code string, client ClientInfo) error {
+	settings, err := s.mfaStorage.Get(email)
+	if err != nil {
+		return fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	if settings == nil || !settings.Enabled {
+		return errors.New(ErrMFANotEnabled)
+	}
+
+	required, err := s.mfaStorage.IsRequired(email)
+	if err != nil {
+		return fmt.Errorf("failed to check mfa policy: %w", err)
+	}
+	if required {
+		return errors.New(ErrMFAPolicyRequired)
+	}
+
+	if err := s.checkAccountMFACode(settings, code, true, client, "invalid two-factor code on disabling"); err != nil {
+		return err
+	}
+
+	if err := s.mfaStorage.Disable(email); err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+
+	s.addSecurityEvent(email, SecurityEventMFADisabled, client, "")
+	return nil
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления новыми. Нужен код из приложения
+func (s *AuthManager) RegenerateRecoveryCodes(email, code string, client ClientInfo) ([]string, error) {
+	settings, err := s.mfaStorage.Get(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa settings: %w", err)
+	}
+	if settings == nil || !settings.Enabled {
+		return nil, errors.New(ErrMFANotEnabled)
+	}
+
+	if err := s.checkAccountMFACode(settings, code, false, client, "invalid two-factor code on recovery codes regeneration"); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaStorage.ReplaceRecoveryCodes(email, hashes); err != nil {
+		return nil, fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// GetAdminMFARequired возвращает, обязательна ли 2FA для администраторов
+func (s *AuthManager) GetAdminMFARequired() (bool, error) {
+	required, err := s.mfaStorage.IsAdminRequired()
+	if err != nil {
+		return false, fmt.Errorf("failed to get admin mfa policy: %w", err)
+	}
+	return required, nil
+}
+
+// SetAdminMFARequired включает или отключает обязательную 2FA для администраторов.
+// Администраторы без 2FA подключат её при следующем входе
+func (s *AuthManager) SetAdminMFARequired(actor audit.Actor, required bool) error {
+	previous, err := s.mfaStorage.IsAdminRequired()
+	if err != nil {
+		return fmt.Errorf("failed to get admin mfa policy: %w", err)
+	}
+
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		if err := s.mfaStorage.WithTx(tx).SetAdminRequired(required, actor.Email); err != nil {
+			return fmt.Errorf("failed to set admin mfa policy: %w", err)
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionAdminMFAPolicy, audit.TargetSetting, settingAdminMFARequired,
+			map[string]bool{"required": previous}, map[string]bool{"required": required})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("auth: admin mfa requirement set to %t by %s", required, actor.Email)
+	return nil
+}
+
+// Проверка кода второго фактора. Код из приложения принимается один раз (по номеру шага),
+// код восстановления - если allowRecovery
+func (s *AuthManager) verifyMFACode(settings *MFASettings, code string, allowRecovery bool, client ClientInfo) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(settings.Secret, code, time.Now()); ok {
+		used, err := s.mfaStorage.UseStep(settings.Email, step)
+		if err != nil {
+			return false, fmt.Errorf("failed to save mfa step: %w", err)
+		}
+		return used, nil
+	}
+
+	if !allowRecovery || len(code) == totp.Digits {
+		return false, nil
+	}
+
+	recoveryCode := normalizeRecoveryCode(code)
+	used, err := s.mfaStorage.UseRecoveryCode(settings.Email, s.hashRecoveryCode(recoveryCode))
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if used {
+		s.addSecurityEvent(settings.Email, SecurityEventRecoveryCode, client, "")
+	}
+	return used, nil
+}
+
+// Проверка кода второго фактора для действий с 2FA. Как и при входе, во время блокировки коды
+// не проверяются, а неверный код учитывается в защите от перебора
+func (s *AuthManager) checkAccountMFACode(settings *MFASettings, code string, allowRecovery bool, client ClientInfo, failureDetails string) error {
+	if err := s.checkLoginAllowed(settings.Email, client.IP); err != nil {
+		return err
+	}
+
+	valid, err := s.verifyMFACode(settings, code, allowRecovery, client)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		s.registerLoginFailure(settings.Email, client, failureDetails)
+		return errors.New(ErrInvalidMFACode)
+	}
+	return nil
+}
+
+// Генерация кодов восстановления вида a1b2-c3d4 и их хешей для хранения
+func (s *AuthManager) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, 4)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := hex.EncodeToString(b)
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = s.hashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// Код восстановления принимается без учёта регистра, пробелов и дефиса
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"src/internal/totp"
+)
+
+// Настройки 2FA одного пользователя в памяти
+type memoryMFA struct {
+	MFAStorage
+	settings      *MFASettings
+	recoveryCodes map[string]bool
+	required      bool
+}
+
+func (s *memoryMFA) Get(email string) (*MFASettings, error) {
+	if s.settings == nil {
+		return nil, nil
+	}
+	settings := *s.settings
+	return &settings, nil
+}
+
+func (s *memoryMFA) Enable(email string, recoveryCodeHashes []string) error {
+	s.settings.Enabled = true
+	return s.ReplaceRecoveryCodes(email, recoveryCodeHashes)
+}
+
+func (s *memoryMFA) Disable(email string) error {
+	s.settings = nil
+	s.recoveryCodes = nil
+	return nil
+}
+
+func (s *memoryMFA) UseStep(email string, step int64) (bool, error) {
+	if s.settings.LastUsedStep != nil && *s.settings.LastUsedStep >= step {
+		return false, nil
+	}
+	s.settings.LastUsedStep = &step
+	return true, nil
+}
+
+func (s *memoryMFA) UseRecoveryCode(email, codeHash string) (bool, error) {
+	if !s.recoveryCodes[codeHash] {
+		return false, nil
+	}
+	delete(s.recoveryCodes, codeHash)
+	return true, nil
+}
+
+func (s *memoryMFA) ReplaceRecoveryCodes(email string, codeHashes []string) error {
+	s.recoveryCodes = map[string]bool{}
+	for _, hash := range codeHashes {
+		s.recoveryCodes[hash] = true
+	}
+	return nil
+}
+
+func (s *memoryMFA) IsRequired(email string) (bool, error) {
+	return s.required, nil
+}
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func currentTOTPCode(t *testing.T) string {
+	t.Helper()
+	code, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// Пользователь с 2FA в состоянии enabled и защитой от перебора с порогом 3
+func newMFAAuth(t *testing.T, enabled bool) (*lockoutAuth, *memoryMFA) {
+	t.Helper()
+	la := newLockoutAuth(t)
+	mfa := &memoryMFA{settings: &MFASettings{Email: "user@example.com", Secret: testTOTPSecret, Enabled: enabled}}
+	la.mfaStorage = mfa
+	return la, mfa
+}
+
+// Неверные коды в действиях с 2FA учитываются в защите от перебора, после блокировки не принимается и верный код
+func TestMFAActionsCountFailures(t *testing.T) {
+	actions := []struct {
+		name    string
+		enabled bool
+		call    func(la *lockoutAuth, code string) error
+	}{
+		{"enable", false, func(la *lockoutAuth, code string) error {
+			_, err := la.EnableMFA("user@example.com", code, ClientInfo{IP: "203.0.113.7"})
+			return err
+		}},
+		{"disable", true, func(la *lockoutAuth, code string) error {
+			return la.DisableMFA("user@example.com", code, ClientInfo{IP: "203.0.113.7"})
+		}},
+		{"regenerate recovery codes", true, func(la *lockoutAuth, code string) error {
+			_, err := la.RegenerateRecoveryCodes("user@example.com", code, ClientInfo{IP: "203.0.113.7"})
+			return err
+		}},
+	}
+	for _, action := range actions {
+		t.Run(action.name, func(t *testing.T) {
+			la, _ := newMFAAuth(t, action.enabled)
+
+			for i := 0; i < 3; i++ {
+				if err := action.call(la, "000000"); err == nil || err.Error() != ErrInvalidMFACode {
+					t.Fatalf("attempt %d: err = %v, want invalid code", i+1, err)
+				}
+			}
+			if failures := la.attempts.failures[accountKey("user@example.com")]; failures == nil || failures.Failures != 3 {
+				t.Fatalf("account failures = %+v", failures)
+			}
+			if len(la.email.lockedAlerts) != 1 {
+				t.Errorf("locked alerts = %v", la.email.lockedAlerts)
+			}
+
+			err := action.call(la, currentTOTPCode(t))
+			var blocked *LoginBlockedError
+			if !errors.As(err, &blocked) || blocked.Reason != ErrAccountLocked {
+				t.Fatalf("with valid code after lockout: err = %v, want account locked", err)
+			}
+		})
+	}
+}
+
+func TestEnableMFA(t *testing.T) {
+	la, mfa := newMFAAuth(t, false)
+
+	codes, err := la.EnableMFA("user@example.com", currentTOTPCode(t), ClientInfo{})
+	if err != nil {
+		t.Fatalf("EnableMFA: %v", err)
+	}
+	if !mfa.settings.Enabled || len(codes) != recoveryCodesCount || len(mfa.recoveryCodes) != recoveryCodesCount {
+		t.Errorf("enabled = %v, codes = %d, stored = %d", mfa.settings.Enabled, len(codes), len(mfa.recoveryCodes))
+	}
+	if _, err := la.EnableMFA("user@example.com", currentTOTPCode(t), ClientInfo{}); err == nil || err.Error() != ErrMFAAlreadyEnabled {
+		t.Errorf("second EnableMFA = %v", err)
+	}
+}
+
+// Код из приложения принимается один раз
+func TestMFACodeReplay(t *testing.T) {
+	la, _ := newMFAAuth(t, true)
+	code := currentTOTPCode(t)
+
+	if _, err := la.RegenerateRecoveryCodes("user@example.com", code, ClientInfo{}); err != nil {
+		t.Fatalf("RegenerateRecoveryCodes: %v", err)
+	}
+	if err := la.DisableMFA("user@example.com", code, ClientInfo{}); err == nil || err.Error() != ErrInvalidMFACode {
+		t.Errorf("DisableMFA with used code = %v", err)
+	}
+}
+
+func TestDisableMFAWithRecoveryCode(t *testing.T) {
+	la, mfa := newMFAAuth(t, true)
+	codes, hashes, err := la.generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfa.ReplaceRecoveryCodes("user@example.com", hashes)
+
+	// Код восстановления не подходит для замены кодов восстановления
+	if _, err := la.RegenerateRecoveryCodes("user@example.com", codes[0], ClientInfo{}); err == nil || err.Error() != ErrInvalidMFACode {
+		t.Fatalf("RegenerateRecoveryCodes with recovery code = %v", err)
+	}
+	if err := la.DisableMFA("user@example.com", codes[0], ClientInfo{}); err != nil {
+		t.Fatalf("DisableMFA: %v", err)
+	}
+	if mfa.settings != nil {
+		t.Error("mfa is still enabled")
+	}
+}
+
+func TestDisableRequiredMFA(t *testing.T) {
+	la, mfa := newMFAAuth(t, true)
+	mfa.required = true
+
+	if err := la.DisableMFA("user@example.com", currentTOTPCode(t), ClientInfo{}); err == nil || err.Error() != ErrMFAPolicyRequired {
+		t.Errorf("DisableMFA = %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Роль
@@ -15,23 +16,199 @@ const (
 type User struct {
 	Login    string `json:"login" db:"login"`
 	Password string `json:"-" db:"password"`
+
+	SuspendedAt      *time.Time `json:"suspended_at,omitempty" db:"suspended_at"` // заблокирован администратором
+	SuspensionReason string     `json:"suspension_reason,omitempty" db:"suspension_reason"`
 }
 
-// Данные регистрации
+// Роль пользователя, записываемая в claims access токена
+type UserRole struct {
+	Role string
+	INN  string // ИНН компании, если пользователь - сотрудник компании (при любой роли)
+
+	CompanyRole string // роль в компании, если пользователь - сотрудник компании
+}
+
+// Данные регистрации. Код хранится только в виде SHA-256
 type RegistrationData struct {
 	Email     string
 	Password  string
-	Code      string
+	CodeHash  string
+	Attempts  int // количество попыток ввода кода, включая текущую
 	ExpiresAt time.Time
 }
 
-// Данные refresh токена
+// Данные refresh токена. Сам токен не хранится, только его SHA-256
 type RefreshTokenData struct {
-	Token      string
+	TokenHash  string
+	FamilyID   uuid.UUID // общий для всех токенов, полученных ротацией после одного входа
 	Email      string
 	ExpiresAt  time.Time
 	LastUsed   time.Time
-	DeviceInfo string
+	RotatedAt  *time.Time // время ротации; повторное предъявление такого токена означает утечку
+	DeviceInfo string     // название устройства, заданное клиентом или определённое по User-Agent
+	UserAgent  string
+	IP         string
+
+	SessionStartedAt time.Time // время входа, с которого началось семейство
+
+	AccessJTI       string // access токен, выданный вместе с refresh токеном
+	AccessExpiresAt time.Time
+}
+
+// Access токены удалённых сессий для отзыва: jti и срок действия
+type AccessTokens map[string]time.Time
+
+// Данные клиента, с которого выполняется вход или обновление токенов
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+	Lang       string // язык писем из Accept-Language, пустой - не определён
+}
+
+// Сессия устройства. Соответствует семейству refresh токенов, ID сессии - ID семейства
+type Session struct {
+	ID         uuid.UUID `json:"id" example:"5f0c2a8e-3b1d-4c6e-9f7a-2d8b1e4c6a90"`
+	DeviceName string    `json:"device_name" example:"Chrome on Windows"`
+	UserAgent  string    `json:"user_agent" example:"Mozilla/5.0 (Windows NT 10.0; Win64; x64) ..."`
+	IP         string    `json:"ip" example:"203.0.113.7"`
+	CreatedAt  time.Time `json:"created_at" example:"2025-01-15T10:00:00Z"`
+	LastUsed   time.Time `json:"last_used" example:"2025-01-16T08:30:00Z"`
+	ExpiresAt  time.Time `json:"expires_at" example:"2025-02-14T08:30:00Z"`
+	Current    bool      `json:"current" example:"true"` // сессия, с которой выполнен запрос
+}
+
+// Счётчик неудачных попыток входа по аккаунту или IP адресу
+type LoginFailures struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Типы событий безопасности
+const (
+	SecurityEventLoginSuccess    = "login_success"
+	SecurityEventLoginFailed     = "login_failed"
+	SecurityEventAccountLocked   = "account_locked"
+	SecurityEventAccountUnlocked = "account_unlocked"
+	SecurityEventMFAEnabled      = "mfa_enabled"
+	SecurityEventMFADisabled     = "mfa_disabled"
+	SecurityEventRecoveryCode    = "recovery_code_used"
+	SecurityEventPasswordChanged = "password_changed"
+	SecurityEventEmailChanged    = "email_changed"
+	SecurityEventOIDCLinked      = "oidc_linked"
+	SecurityEventSuspended       = "account_suspended"
+	SecurityEventReinstated      = "account_reinstated"
+)
+
+// Настройки двухфакторной аутентификации пользователя
+type MFASettings struct {
+	Email        string
+	Secret       string
+	Enabled      bool
+	LastUsedStep *int64
+}
+
+// Второй шаг входа после проверки пароля. Токен хранится только в виде SHA-256
+type MFAChallenge struct {
+	TokenHash  string
+	Email      string
+	Setup      bool // 2FA обязательна, но не подключена: перед входом нужно её подключить
+	DeviceName string
+	Attempts   int
+	ExpiresAt  time.Time
+}
+
+// MFARequiredError - пароль верный, для выдачи токенов нужен код второго фактора.
+// Error() возвращает ErrMFARequired или ErrMFASetupRequired
+type MFARequiredError struct {
+	Reason    string
+	Token     string
+	ExpiresIn int
+}
+
+func (e *MFARequiredError) Error() string {
+	return e.Reason
+}
+
+// Ответ на вход, требующий второго фактора
+type MFAChallengeResponse struct {
+	Error     string `json:"error" example:"mfa_required"`
+	MFAToken  string `json:"mfa_token" example:"9f2c4e7a1b3d5f8a0c6e2b4d7f1a3c5e9b0d2f4a6c8e1b3d5f7a9c0e2b4d6f8a"`
+	ExpiresIn int    `json:"expires_in" example:"300"`
+}
+
+// Состояние 2FA пользователя
+type MFAStatus struct {
+	Enabled           bool `json:"enabled" example:"true"`
+	Required          bool `json:"required" example:"false"` // 2FA обязательна для администраторов или в компании пользователя
+	RecoveryCodesLeft int  `json:"recovery_codes_left" example:"10"`
+}
+
+// Данные для подключения приложения-аутентификатора
+type MFASetupResponse struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	OTPAuthURL string `json:"otpauth_url" example:"otpauth://totp/Pioneer:email@mail.ru?algorithm=SHA1&digits=6&issuer=Pioneer&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+}
+
+// Коды восстановления, показываются один раз
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"a1b2-c3d4,e5f6-a7b8"`
+}
+
+// Запрос с кодом из приложения-аутентификатора (или кодом восстановления, где он допустим)
+type MFACodeRequest struct {
+	Code string `json:"code" example:"123456" validate:"required,min=6,max=16"`
+}
+
+// Запрос на второй шаг входа
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" example:"9f2c4e7a1b3d5f8a0c6e2b4d7f1a3c5e9b0d2f4a6c8e1b3d5f7a9c0e2b4d6f8a" validate:"required,len=64,hexadecimal"`
+	Code     string `json:"code" example:"123456" validate:"required,min=6,max=16"`
+}
+
+// Запрос на подключение 2FA при входе
+type MFALoginSetupRequest struct {
+	MFAToken string `json:"mfa_token" example:"9f2c4e7a1b3d5f8a0c6e2b4d7f1a3c5e9b0d2f4a6c8e1b3d5f7a9c0e2b4d6f8a" validate:"required,len=64,hexadecimal"`
+}
+
+// Обязательность 2FA для администраторов или сотрудников компании
+type MFAPolicyRequest struct {
+	Required *bool `json:"required" example:"true" validate:"required"`
+}
+
+// Блокировка пользователя администратором. Причина отправляется пользователю на почту
+type SuspendRequest struct {
+	Reason string `json:"reason" example:"Многочисленные жалобы на неявку" validate:"required,max=1000"`
+}
+
+// Снятие блокировки. Комментарий (необязательный) отправляется пользователю на почту
+type ReinstateRequest struct {
+	Comment string `json:"comment,omitempty" example:"Блокировка снята после обращения в поддержку" validate:"max=1000"`
+}
+
+// Событие безопасности из журнала пользователя
+type SecurityEvent struct {
+	ID        uuid.UUID `json:"id" example:"8d3c1f0a-6b2e-4a7d-9c5f-1e0b3a8d2c4f"`
+	Email     string    `json:"-"`
+	Type      string    `json:"type" example:"login_failed"`
+	IP        string    `json:"ip" example:"203.0.113.7"`
+	UserAgent string    `json:"user_agent" example:"Mozilla/5.0 (Windows NT 10.0; Win64; x64) ..."`
+	Details   string    `json:"details,omitempty" example:"invalid password"`
+	CreatedAt time.Time `json:"created_at" example:"2025-01-16T08:30:00Z"`
+}
+
+// LoginBlockedError - вход временно запрещён из-за неудачных попыток.
+// Error() возвращает ErrAccountLocked или ErrTooManyLoginAttempts
+type LoginBlockedError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Reason
 }
 
 type RegisterRequest struct {
@@ -49,6 +226,8 @@ type VerifyCodeRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" example:"example@gmail.com" validate:"required,email"`
 	Password string `json:"password" example:"1A_password" validate:"required"`
+	// Необязательное название устройства, по умолчанию определяется по User-Agent
+	DeviceName string `json:"device_name,omitempty" example:"Рабочий ноутбук" validate:"omitempty,max=100"`
 }
 
 // Запрос на восстановление пароля
@@ -68,6 +247,76 @@ type SetNewPasswordRequest struct {
 	NewPassword string `json:"new_password" example:"New_Password123!" validate:"required,min=8,max=24,password"`
 }
 
+// Запрос на смену пароля
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"1A_password" validate:"required"`
+	NewPassword     string `json:"new_password" example:"New_Password123!" validate:"required,min=8,max=24,password"`
+}
+
+// Запрос на смену адреса почты
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" example:"new@gmail.com" validate:"required,email,min=6,max=64"`
+	Password string `json:"password" example:"1A_password" validate:"required"`
+}
+
+// Запрос на подтверждение нового адреса почты
+type ConfirmEmailChangeRequest struct {
+	Code string `json:"code" example:"000000" validate:"required,len=6"`
+}
+
+// Запрос на удаление аккаунта. Code - код 2FA, если она подключена
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"1A_password" validate:"required"`
+	Code     string `json:"code,omitempty" example:"123456" validate:"omitempty,min=6,max=16"`
+}
+
+// Данные смены адреса почты. Код хранится только в виде SHA-256
+type EmailChangeData struct {
+	Email     string
+	NewEmail  string
+	CodeHash  string
+	Attempts  int // количество попыток ввода кода, включая текущую
+	ExpiresAt time.Time
+}
+
+// Запрос на вход через внешнего провайдера
+type OIDCAuthorizeRequest struct {
+	// Необязательное название устройства, по умолчанию определяется по User-Agent
+	DeviceName string `json:"device_name,omitempty" example:"Рабочий ноутбук" validate:"omitempty,max=100"`
+}
+
+// Адрес страницы входа провайдера. state нужно сохранить и передать вместе с кодом в callback
+type OIDCAuthorizeResponse struct {
+	AuthorizationURL string `json:"authorization_url" example:"https://oauth.yandex.ru/authorize?client_id=...&code_challenge=...&code_challenge_method=S256&response_type=code&state=..."`
+	State            string `json:"state" example:"3f7a9c1e5b2d4f6a8c0e2b4d6f8a1c3e5b7d9f0a2c4e6b8d1f3a5c7e9b0d2f4a"`
+	ExpiresIn        int    `json:"expires_in" example:"600"`
+}
+
+// Код авторизации, с которым провайдер вернул пользователя на страницу клиента
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" example:"4/0AbCdEf" validate:"required,max=2048"`
+	State string `json:"state" example:"3f7a9c1e5b2d4f6a8c0e2b4d6f8a1c3e5b7d9f0a2c4e6b8d1f3a5c7e9b0d2f4a" validate:"required,len=64,hexadecimal"`
+	// device_id из адреса возврата VK ID, нужен провайдеру при обмене кода
+	DeviceID string `json:"device_id,omitempty" validate:"omitempty,max=256"`
+}
+
+// Начатый вход через провайдера. state хранится только в виде SHA-256
+type OIDCState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string // PKCE, отправляется провайдеру при обмене кода
+	DeviceName   string
+	ExpiresAt    time.Time
+}
+
+// Пользователь провайдера по данным userinfo
+type OIDCIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
 // Запрос на обновление токена
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"  example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..." validate:"required"`
@@ -79,6 +328,9 @@ type TokenResponse struct {
 	RefreshToken string `json:"refresh_token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
 	TokenType    string `json:"token_type" example:"Bearer"`
 	ExpiresIn    int    `json:"expires_in" example:"900"`
+
+	// Коды восстановления, если 2FA была подключена при этом входе. Показываются один раз
+	RecoveryCodes []string `json:"recovery_codes,omitempty" example:"a1b2-c3d4,e5f6-a7b8"`
 }
 
 // Данные, которые хранятся внутри jwt токена
@@ -90,11 +342,36 @@ type Claims struct {
 
 // Ошибки
 var (
-	ErrUserNotFound        = "user not found"
-	ErrInvalidPassword     = "invalid password"
-	ErrUserAlreadyExists   = "user already exists"
-	ErrInvalidCode         = "invalid verification code"
-	ErrCodeExpired         = "verification code expired"
-	ErrInvalidRefreshToken = "invalid refresh token"
-	ErrRefreshTokenExpired = "refresh token expired"
+	ErrUserNotFound         = "user not found"
+	ErrInvalidPassword      = "invalid password"
+	ErrUserAlreadyExists    = "user already exists"
+	ErrInvalidCode          = "invalid verification code"
+	ErrCodeExpired          = "verification code expired"
+	ErrTooManyAttempts      = "too many attempts, request a new code"
+	ErrInvalidRefreshToken  = "invalid refresh token"
+	ErrRefreshTokenExpired  = "refresh token expired"
+	ErrRefreshTokenReused   = "refresh token reuse detected, session revoked"
+	ErrSessionNotFound      = "session not found"
+	ErrAccountLocked        = "account temporarily locked due to failed login attempts"
+	ErrTooManyLoginAttempts = "too many failed login attempts, try again later"
+	ErrMFARequired          = "mfa_required"
+	ErrMFASetupRequired     = "mfa_setup_required"
+	ErrInvalidMFAToken      = "invalid or expired mfa token"
+	ErrInvalidMFACode       = "invalid two-factor code"
+	ErrMFANotStarted        = "two-factor setup not started"
+	ErrMFAAlreadyEnabled    = "two-factor authentication already enabled"
+	ErrMFANotEnabled        = "two-factor authentication is not enabled"
+	ErrMFAPolicyRequired    = "two-factor authentication is required for your account"
+	ErrSameEmail            = "new email must differ from the current one"
+	ErrEmailChangeNotFound  = "email change not requested or expired"
+	ErrLastCompanyOwner     = "transfer company ownership before deleting the account"
+	ErrUnknownOIDCProvider  = "unknown identity provider"
+	ErrInvalidOIDCState     = "invalid or expired login state"
+	ErrOIDCProviderFailed   = "identity provider request failed"
+	ErrOIDCEmailMissing     = "identity provider did not return an email"
+	ErrOIDCEmailNotVerified = "email is not verified by the identity provider"
+	ErrAccountSuspended     = "account suspended by administrator"
+	ErrAlreadySuspended     = "account is already suspended"
+	ErrNotSuspended         = "account is not suspended"
+	ErrSuspendSelf          = "you cannot suspend your own account"
 )
@@ -0,0 +1,388 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	configPkg "src/internal/config"
+)
+
+const (
+	// Время, за которое пользователь должен вернуться со страницы провайдера
+	oidcStateTTL = 10 * time.Minute
+	// Ограничение времени запросов к провайдеру и размера ответа
+	oidcRequestTimeout = 10 * time.Second
+	oidcMaxResponse    = 1 << 20
+)
+
+// Внешний провайдер входа. Адреса, не заданные в настройках, загружаются из discovery документа
+// при первом обращении и кэшируются
+type oidcProvider struct {
+	config configPkg.OIDCProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	authURL     string
+	tokenURL    string
+	userInfoURL string
+}
+
+func newOIDCProviders(configs []configPkg.OIDCProviderConfig) map[string]*oidcProvider {
+	client := &http.Client{Timeout: oidcRequestTimeout}
+	providers := make(map[string]*oidcProvider, len(configs))
+	for _, config := range configs {
+		providers[config.Name] = &oidcProvider{
+			config:      config,
+			client:      client,
+			authURL:     config.AuthURL,
+			tokenURL:    config.TokenURL,
+			userInfoURL: config.UserInfoURL,
+		}
+	}
+	return providers
+}
+
+// OIDCProviders возвращает имена настроенных провайдеров входа
+func (s *AuthManager) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDCLogin начинает вход через провайдера (authorization code с PKCE):
+// сохраняет state и code_verifier и возвращает адрес страницы входа провайдера
+func (s *AuthManager) StartOIDCLogin(providerName, deviceName string) (*OIDCAuthorizeResponse, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, errors.New(ErrUnknownOIDCProvider)
+	}
+
+	authURL, _, _, err := provider.endpoints()
+	if err != nil {
+		log.Printf("auth: oidc %s: %v", providerName, err)
+		return nil, errors.New(ErrOIDCProviderFailed)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate oidc state: %w", err)
+	}
+	state := hex.EncodeToString(b)
+
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+
+	err = s.oidcStateStorage.Save(&OIDCState{
+		StateHash:    hashToken(state),
+		Provider:     providerName,
+		CodeVerifier: verifier,
+		DeviceName:   deviceName,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save oidc state: %w", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization url: %w", err)
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientID)
+	query.Set("redirect_uri", provider.config.RedirectURL)
+	query.Set("scope", strings.Join(provider.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+
+	return &OIDCAuthorizeResponse{
+		AuthorizationURL: u.String(),
+		State:            state,
+		ExpiresIn:        int(oidcStateTTL.Seconds()),
+	}, nil
+}
+
+// CompleteOIDCLogin завершает вход: обменивает код на токен провайдера, получает данные пользователя
+// и находит привязанный аккаунт, привязывает аккаунт с тем же подтверждённым адресом
+// или регистрирует нового клиента. Дальше вход идёт как по паролю, включая 2FA
+func (s *AuthManager) CompleteOIDCLogin(providerName, code, state, deviceID string, client ClientInfo) (*TokenResponse, error) {
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		return nil, errors.New(ErrUnknownOIDCProvider)
+	}
+
+	loginState, err := s.oidcStateStorage.Use(hashToken(state))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oidc state: %w", err)
+	}
+	if loginState == nil || loginState.Provider != providerName {
+		return nil, errors.New(ErrInvalidOIDCState)
+	}
+
+	identity, err := provider.identity(code, loginState.CodeVerifier, deviceID)
+	if err != nil {
+		log.Printf("auth: oidc %s: %v", providerName, err)
+		return nil, errors.New(ErrOIDCProviderFailed)
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = loginState.DeviceName
+	}
+
+	user, err := s.oidcUser(identity, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkMFARequired(user.Login, client); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// Пользователь, к которому относится аккаунт провайдера. Привязка к существующему аккаунту
+// и регистрация выполняются только для адресов, подтверждённых провайдером
+func (s *AuthManager) oidcUser(identity *OIDCIdentity, client ClientInfo) (*User, error) {
+	email, err := s.oidcIdentityStorage.GetEmail(identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oidc identity: %w", err)
+	}
+	if email != "" {
+		user, err := s.userStorage.GetByEmail(email)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return nil, errors.New(ErrUserNotFound)
+		}
+		return user, nil
+	}
+
+	if identity.Email == "" {
+		return nil, errors.New(ErrOIDCEmailMissing)
+	}
+	if !identity.EmailVerified {
+		return nil, errors.New(ErrOIDCEmailNotVerified)
+	}
+
+	user, err := s.userStorage.GetByEmail(identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user != nil {
+		if err := s.oidcIdentityStorage.Link(identity, user.Login); err != nil {
+			return nil, fmt.Errorf("failed to link oidc identity: %w", err)
+		}
+		log.Printf("auth: %s account linked to %s", identity.Provider, user.Login)
+		s.addSecurityEvent(user.Login, SecurityEventOIDCLinked, client, identity.Provider)
+		return user, nil
+	}
+
+	if err := s.oidcIdentityStorage.Register(identity); err != nil {
+		if err.Error() == ErrUserAlreadyExists {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to register oidc user: %w", err)
+	}
+	log.Printf("auth: client %s registered via %s", identity.Email, identity.Provider)
+
+	user, err = s.userStorage.GetByEmail(identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, errors.New(ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// Адреса страницы входа, обмена кода и данных пользователя
+func (p *oidcProvider) endpoints() (string, string, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.authURL != "" && p.tokenURL != "" && p.userInfoURL != "" {
+		return p.authURL, p.tokenURL, p.userInfoURL, nil
+	}
+
+	var discovery struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	req, err := http.NewRequest(http.MethodGet, p.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", "", "", err
+	}
+	if err := p.do(req, &discovery); err != nil {
+		return "", "", "", fmt.Errorf("discovery: %w", err)
+	}
+
+	if p.authURL == "" {
+		p.authURL = discovery.AuthorizationEndpoint
+	}
+	if p.tokenURL == "" {
+		p.tokenURL = discovery.TokenEndpoint
+	}
+	if p.userInfoURL == "" {
+		p.userInfoURL = discovery.UserInfoEndpoint
+	}
+	if p.authURL == "" || p.tokenURL == "" || p.userInfoURL == "" {
+		return "", "", "", errors.New("discovery document has no authorization, token or userinfo endpoint")
+	}
+	return p.authURL, p.tokenURL, p.userInfoURL, nil
+}
+
+// Обмен кода на access токен провайдера и запрос данных пользователя
+func (p *oidcProvider) identity(code, verifier, deviceID string) (*OIDCIdentity, error) {
+	_, tokenURL, userInfoURL, err := p.endpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	if deviceID != "" {
+		form.Set("device_id", deviceID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("token exchange: no access_token in response")
+	}
+
+	if p.config.UserInfoMethod == configPkg.UserInfoMethodPost {
+		form := url.Values{}
+		form.Set("access_token", token.AccessToken)
+		form.Set("client_id", p.config.ClientID)
+		req, err = http.NewRequest(http.MethodPost, userInfoURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequest(http.MethodGet, userInfoURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	}
+
+	var userInfo map[string]any
+	if err := p.do(req, &userInfo); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+
+	identity := &OIDCIdentity{
+		Provider:      p.config.Name,
+		Subject:       claimString(userInfo, p.config.SubjectClaim),
+		Email:         strings.ToLower(claimString(userInfo, p.config.EmailClaim)),
+		EmailVerified: true,
+	}
+	if p.config.EmailVerifiedClaim != "" {
+		identity.EmailVerified = claimBool(userInfo, p.config.EmailVerifiedClaim)
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("userinfo: no %q claim", p.config.SubjectClaim)
+	}
+	return identity, nil
+}
+
+// Выполнение запроса к провайдеру и разбор JSON ответа
+func (p *oidcProvider) do(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, oidcMaxResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %d: %.200s", req.URL.Host, resp.StatusCode, body)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// Значение поля userinfo по пути через точку (например, user.email)
+func claimValue(data map[string]any, path string) any {
+	var value any = data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func claimString(data map[string]any, path string) string {
+	switch v := claimValue(data, path).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// Некоторые провайдеры передают email_verified строкой
+func claimBool(data map[string]any, path string) bool {
+	switch v := claimValue(data, path).(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	configPkg "src/internal/config"
+)
+
+// Состояния входа через провайдера в памяти
+type memoryOIDCStates struct {
+	states map[string]OIDCState
+}
+
+func (s *memoryOIDCStates) Save(state *OIDCState) error {
+	s.states[state.StateHash] = *state
+	return nil
+}
+
+func (s *memoryOIDCStates) Use(stateHash string) (*OIDCState, error) {
+	state, ok := s.states[stateHash]
+	delete(s.states, stateHash)
+	if !ok || time.Now().After(state.ExpiresAt) {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// Привязки аккаунтов провайдера в памяти, регистрация добавляет пользователя
+type memoryOIDCIdentities struct {
+	users *memoryUsers
+	links map[string]string
+}
+
+func (s *memoryOIDCIdentities) GetEmail(provider, subject string) (string, error) {
+	return s.links[provider+"/"+subject], nil
+}
+
+func (s *memoryOIDCIdentities) Link(identity *OIDCIdentity, email string) error {
+	s.links[identity.Provider+"/"+identity.Subject] = email
+	return nil
+}
+
+func (s *memoryOIDCIdentities) Register(identity *OIDCIdentity) error {
+	if s.users.users[identity.Email] != nil {
+		return errors.New(ErrUserAlreadyExists)
+	}
+	s.users.users[identity.Email] = &User{Login: identity.Email}
+	return s.Link(identity, identity.Email)
+}
+
+// Провайдер для тестов: discovery, обмен кода с проверкой PKCE и userinfo.
+// Код выдаётся вызовом authorize, как после входа пользователя на странице провайдера
+type mockOIDCProvider struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	codes          map[string]string // code -> code_challenge
+	userInfo       map[string]any
+	discoveryCalls int
+	tokenForms     []url.Values
+	userInfoMethod string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	p := &mockOIDCProvider{
+		codes:    map[string]string{},
+		userInfo: map[string]any{"sub": "subject-1", "email": "User@Example.com", "email_verified": true},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.discoveryCalls++
+		p.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"userinfo_endpoint":      p.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.tokenForms = append(p.tokenForms, r.PostForm)
+
+		challenge, ok := p.codes[r.PostForm.Get("code")]
+		delete(p.codes, r.PostForm.Get("code"))
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+			r.PostForm.Get("client_id") != "client-id" || r.PostForm.Get("client_secret") != "client-secret" ||
+			r.PostForm.Get("redirect_uri") != "https://app.example.com/oidc/callback" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "provider-access-token", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			token = r.PostForm.Get("access_token")
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.userInfoMethod = r.Method
+		if token != "provider-access-token" {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(p.userInfo)
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Вход пользователя на странице провайдера: провайдер запоминает code_challenge и выдаёт код
+func (p *mockOIDCProvider) authorize(t *testing.T, authorizationURL string) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization url without PKCE: %s", authorizationURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code = "code-" + query.Get("state")[:8]
+	p.codes[code] = query.Get("code_challenge")
+	return code, query.Get("state")
+}
+
+type oidcAuth struct {
+	*lockoutAuth
+	provider   *mockOIDCProvider
+	states     *memoryOIDCStates
+	identities *memoryOIDCIdentities
+}
+
+func newOIDCAuth(t *testing.T, configure func(config *configPkg.OIDCProviderConfig)) *oidcAuth {
+	t.Helper()
+	oa := &oidcAuth{
+		lockoutAuth: newLockoutAuth(t),
+		provider:    newMockOIDCProvider(t),
+		states:      &memoryOIDCStates{states: map[string]OIDCState{}},
+	}
+	oa.identities = &memoryOIDCIdentities{users: oa.users, links: map[string]string{}}
+
+	config := configPkg.OIDCProviderConfig{
+		Name:               "test",
+		ClientID:           "client-id",
+		ClientSecret:       "client-secret",
+		RedirectURL:        "https://app.example.com/oidc/callback",
+		Scopes:             []string{"openid", "email"},
+		Issuer:             oa.provider.URL,
+		UserInfoMethod:     configPkg.UserInfoMethodGet,
+		SubjectClaim:       "sub",
+		EmailClaim:         "email",
+		EmailVerifiedClaim: "email_verified",
+	}
+	if configure != nil {
+		configure(&config)
+	}
+
+	oa.mfaStorage = &memoryMFA{}
+	oa.oidcStateStorage = oa.states
+	oa.oidcIdentityStorage = oa.identities
+	oa.oidcProviders = newOIDCProviders([]configPkg.OIDCProviderConfig{config})
+	return oa
+}
+
+// Вход через провайдера от начала до выдачи токенов
+func (oa *oidcAuth) login(t *testing.T) (*TokenResponse, error) {
+	t.Helper()
+	start, err := oa.StartOIDCLogin("test", "Phone")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	code, state := oa.provider.authorize(t, start.AuthorizationURL)
+	return oa.CompleteOIDCLogin("test", code, state, "", ClientInfo{IP: "203.0.113.7"})
+}
+
+func TestStartOIDCLogin(t *testing.T) {
+	oa := newOIDCAuth(t, nil)
+
+	start, err := oa.StartOIDCLogin("test", "Phone")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	u, err := url.Parse(start.AuthorizationURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != oa.provider.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %s", got)
+	}
+
+	query := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "client-id",
+		"redirect_uri":          "https://app.example.com/oidc/callback",
+		"scope":                 "openid email",
+		"state":                 start.State,
+		"code_challenge_method": "S256",
+	}
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("%s = %q, want %q", key, query.Get(key), value)
+		}
+	}
+	if query.Get("client_secret") != "" {
+		t.Error("client secret is sent to the browser")
+	}
+
+	// В хранилище только хеш state, verifier соответствует code_challenge и не попадает в адрес
+	saved, ok := oa.states.states[hashToken(start.State)]
+	if !ok {
+		t.Fatal("state is not saved by hash")
+	}
+	sum := sha256.Sum256([]byte(saved.CodeVerifier))
+	if query.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Error("code_challenge does not match the saved verifier")
+	}
+	if strings.Contains(start.AuthorizationURL, saved.CodeVerifier) {
+		t.Error("code verifier is sent to the browser")
+	}
+	if saved.Provider != "test" || saved.DeviceName != "Phone" {
+		t.Errorf("saved state = %+v", saved)
+	}
+
+	if _, err := oa.StartOIDCLogin("unknown", ""); err == nil || err.Error() != ErrUnknownOIDCProvider {
+		t.Errorf("unknown provider err = %v", err)
+	}
+}
+
+func TestCompleteOIDCLoginLinksAccount(t *testing.T) {
+	oa := newOIDCAuth(t, nil)
+
+	tokens, err := oa.login(t)
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+
+	// Провайдер получил verifier, соответствующий challenge, и секрет клиента
+	form := oa.provider.tokenForms[0]
+	if form.Get("code_verifier") == "" || form.Get("client_secret") != "client-secret" {
+		t.Errorf("token request = %v", form)
+	}
+	if oa.provider.userInfoMethod != http.MethodGet {
+		t.Errorf("userinfo method = %s", oa.provider.userInfoMethod)
+	}
+
+	if email := oa.identities.links["test/subject-1"]; email != "user@example.com" {
+		t.Errorf("linked to %q", email)
+	}
+	var linked bool
+	for _, event := range oa.events.events {
+		linked = linked || event.Type == SecurityEventOIDCLinked
+	}
+	if !linked {
+		t.Error("no oidc_linked security event")
+	}
+	for _, token := range oa.tokens.tokens {
+		if token.DeviceInfo != "Phone" {
+			t.Errorf("device = %q, want the name from the start request", token.DeviceInfo)
+		}
+	}
+
+	// Повторный вход находит аккаунт по привязке, discovery загружается один раз
+	if _, err := oa.login(t); err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if oa.provider.discoveryCalls != 1 {
+		t.Errorf("discovery requested %d times", oa.provider.discoveryCalls)
+	}
+}
+
+func TestCompleteOIDCLoginState(t *testing.T) {
+	oa := newOIDCAuth(t, nil)
+
+	start, err := oa.StartOIDCLogin("test", "")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	code, state := oa.provider.authorize(t, start.AuthorizationURL)
+
+	if _, err := oa.CompleteOIDCLogin("test", code, "forged-state", "", ClientInfo{}); err == nil || err.Error() != ErrInvalidOIDCState {
+		t.Errorf("forged state err = %v", err)
+	}
+	if _, err := oa.CompleteOIDCLogin("test", code, state, "", ClientInfo{}); err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+	// state одноразовый
+	if _, err := oa.CompleteOIDCLogin("test", code, state, "", ClientInfo{}); err == nil || err.Error() != ErrInvalidOIDCState {
+		t.Errorf("reused state err = %v", err)
+	}
+
+	// Истёкший state
+	start, err = oa.StartOIDCLogin("test", "")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	code, state = oa.provider.authorize(t, start.AuthorizationURL)
+	expired := oa.states.states[hashToken(state)]
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	oa.states.states[hashToken(state)] = expired
+	if _, err := oa.CompleteOIDCLogin("test", code, state, "", ClientInfo{}); err == nil || err.Error() != ErrInvalidOIDCState {
+		t.Errorf("expired state err = %v", err)
+	}
+}
+
+// Код, перехваченный без verifier, не обменивается: провайдер проверяет PKCE
+func TestCompleteOIDCLoginVerifierMismatch(t *testing.T) {
+	oa := newOIDCAuth(t, nil)
+
+	start, err := oa.StartOIDCLogin("test", "")
+	if err != nil {
+		t.Fatalf("StartOIDCLogin: %v", err)
+	}
+	code, state := oa.provider.authorize(t, start.AuthorizationURL)
+
+	saved := oa.states.states[hashToken(state)]
+	saved.CodeVerifier = "attacker-verifier"
+	oa.states.states[hashToken(state)] = saved
+
+	if _, err := oa.CompleteOIDCLogin("test", code, state, "", ClientInfo{}); err == nil || err.Error() != ErrOIDCProviderFailed {
+		t.Errorf("err = %v, want provider failure", err)
+	}
+	if len(oa.tokens.tokens) != 0 {
+		t.Error("tokens issued")
+	}
+}
+
+func TestCompleteOIDCLoginEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		userInfo map[string]any
+		wantErr  string
+	}{
+		{"not verified", map[string]any{"sub": "s", "email": "new@example.com", "email_verified": false}, ErrOIDCEmailNotVerified},
+		{"verified as string", map[string]any{"sub": "s", "email": "new@example.com", "email_verified": "true"}, ""},
+		{"missing", map[string]any{"sub": "s"}, ErrOIDCEmailMissing},
+		{"no subject", map[string]any{"email": "new@example.com", "email_verified": true}, ErrOIDCProviderFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oa := newOIDCAuth(t, nil)
+			oa.provider.userInfo = tt.userInfo
+
+			_, err := oa.login(t)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %s", err, tt.wantErr)
+				}
+				if oa.users.users["new@example.com"] != nil {
+					t.Error("user registered")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CompleteOIDCLogin: %v", err)
+			}
+			if oa.users.users["new@example.com"] == nil {
+				t.Error("user is not registered")
+			}
+		})
+	}
+}
+
+// Провайдер в стиле VK ID: userinfo запросом POST, вложенные поля и числовой идентификатор
+func TestCompleteOIDCLoginPostUserInfo(t *testing.T) {
+	oa := newOIDCAuth(t, func(config *configPkg.OIDCProviderConfig) {
+		config.UserInfoMethod = configPkg.UserInfoMethodPost
+		config.SubjectClaim = "user.user_id"
+		config.EmailClaim = "user.email"
+		config.EmailVerifiedClaim = ""
+	})
+	oa.provider.userInfo = map[string]any{"user": map[string]any{"user_id": 123456789, "email": "vk@example.com"}}
+
+	if _, err := oa.login(t); err != nil {
+		t.Fatalf("CompleteOIDCLogin: %v", err)
+	}
+	if oa.provider.userInfoMethod != http.MethodPost {
+		t.Errorf("userinfo method = %s", oa.provider.userInfoMethod)
+	}
+	if email := oa.identities.links["test/123456789"]; email != "vk@example.com" {
+		t.Errorf("linked to %q", email)
+	}
+}
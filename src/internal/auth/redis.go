@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Коды хранятся в хешах Redis, срок действия задаётся временем жизни ключа
+const (
+	redisVerificationPrefix = "auth:verification:"
+	redisResetPrefix        = "auth:reset:"
+	redisEmailChangePrefix  = "auth:email-change:"
+)
+
+// Замена кода: прежние данные и счётчик попыток удаляются. ARGV[1] - срок действия (unix ms), далее поля хеша
+var redisSaveCodeScript = redis.NewScript(`
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+redis.call('PEXPIREAT', KEYS[1], ARGV[1])
+return 1`)
+
+// Атомарный учёт попытки ввода кода
+var redisUseAttemptScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return nil
+end
+redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+return redis.call('HGETALL', KEYS[1])`)
+
+var redisMarkVerifiedScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HSET', KEYS[1], 'verified', '1')
+end
+return 1`)
+
+// Поля хеша из ответа скрипта, который возвращает HGETALL. Для отсутствующего ключа возвращает nil без ошибки
+func redisHashFields(reply any, err error) (map[string]string, error) {
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := reply.([]any)
+	if !ok || len(items)%2 != 0 {
+		return nil, errors.New("redis: unexpected reply to HGETALL")
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		key, _ := items[i].(string)
+		value, _ := items[i+1].(string)
+		fields[key] = value
+	}
+	return fields, nil
+}
+
+// Поля хеша по ключу. Для отсутствующего ключа возвращает nil без ошибки
+func redisGetHash(client *redis.Client, key string) (map[string]string, error) {
+	fields, err := client.HGetAll(context.Background(), key).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// RedisVerificationStorage хранит коды подтверждения регистрации в Redis
+type RedisVerificationStorage struct {
+	client *redis.Client
+}
+
+func NewRedisVerificationStorage(client *redis.Client) *RedisVerificationStorage {
+	return &RedisVerificationStorage{client: client}
+}
+
+func (s *RedisVerificationStorage) Save(data *RegistrationData) error {
+	return redisSaveCodeScript.Run(context.Background(), s.client, []string{redisVerificationPrefix + data.Email},
+		data.ExpiresAt.UnixMilli(),
+		"email", data.Email,
+		"password", data.Password,
+		"code_hash", data.CodeHash,
+		"attempts", 0,
+		"expires_at", data.ExpiresAt.UnixMilli(),
+	).Err()
+}
+
+func (s *RedisVerificationStorage) GetByEmail(email string) (*RegistrationData, error) {
+	fields, err := redisGetHash(s.client, redisVerificationPrefix+email)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return parseRegistrationData(fields)
+}
+
+func (s *RedisVerificationStorage) UseAttempt(email string) (*RegistrationData, error) {
+	fields, err := redisHashFields(redisUseAttemptScript.Run(context.Background(), s.client, []string{redisVerificationPrefix + email}).Result())
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return parseRegistrationData(fields)
+}
+
+func (s *RedisVerificationStorage) Delete(email string) error {
+	return s.client.Del(context.Background(), redisVerificationPrefix+email).Err()
+}
+
+func parseRegistrationData(fields map[string]string) (*RegistrationData, error) {
+	attempts, err := strconv.Atoi(fields["attempts"])
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := strconv.ParseInt(fields["expires_at"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistrationData{
+		Email:     fields["email"],
+		Password:  fields["password"],
+		CodeHash:  fields["code_hash"],
+		Attempts:  attempts,
+		ExpiresAt: time.UnixMilli(expiresAt),
+	}, nil
+}
+
+// RedisResetPasswordStorage хранит коды сброса пароля в Redis
+type RedisResetPasswordStorage struct {
+	client *redis.Client
+}
+
+func NewRedisResetPasswordStorage(client *redis.Client) *RedisResetPasswordStorage {
+	return &RedisResetPasswordStorage{client: client}
+}
+
+func (s *RedisResetPasswordStorage) Save(data *ResetPasswordData) error {
+	return redisSaveCodeScript.Run(context.Background(), s.client, []string{redisResetPrefix + data.Email},
+		data.ExpiresAt.UnixMilli(),
+		"email", data.Email,
+		"code_hash", data.CodeHash,
+		"attempts", 0,
+		"verified", data.Verified,
+		"expires_at", data.ExpiresAt.UnixMilli(),
+	).Err()
+}
+
+func (s *RedisResetPasswordStorage) GetByEmail(email string) (*ResetPasswordData, error) {
+	fields, err := redisGetHash(s.client, redisResetPrefix+email)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return parseResetPasswordData(fields)
+}
+
+func (s *RedisResetPasswordStorage) UseAttempt(email string) (*ResetPasswordData, error) {
+	fields, err := redisHashFields(redisUseAttemptScript.Run(context.Background(), s.client, []string{redisResetPrefix + email}).Result())
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return parseResetPasswordData(fields)
+}
+
+func (s *RedisResetPasswordStorage) Delete(email string) error {
+	return s.client.Del(context.Background(), redisResetPrefix+email).Err()
+}
+
+func (s *RedisResetPasswordStorage) MarkVerified(email string) error {
+	return redisMarkVerifiedScript.Run(context.Background(), s.client, []string{redisResetPrefix + email}).Err()
+}
+
+func parseResetPasswordData(fields map[string]string) (*ResetPasswordData, error) {
+	attempts, err := strconv.Atoi(fields["attempts"])
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := strconv.ParseInt(fields["expires_at"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResetPasswordData{
+		Email:     fields["email"],
+		CodeHash:  fields["code_hash"],
+		Attempts:  attempts,
+		ExpiresAt: time.UnixMilli(expiresAt),
+		Verified:  fields["verified"] == "1",
+	}, nil
+}
+
+// RedisEmailChangeStorage хранит коды смены адреса почты в Redis
+type RedisEmailChangeStorage struct {
+	client *redis.Client
+}
+
+func NewRedisEmailChangeStorage(client *redis.Client) *RedisEmailChangeStorage {
+	return &RedisEmailChangeStorage{client: client}
+}
+
+func (s *RedisEmailChangeStorage) Save(data *EmailChangeData) error {
+	return redisSaveCodeScript.Run(context.Background(), s.client, []string{redisEmailChangePrefix + data.Email},
+		data.ExpiresAt.UnixMilli(),
+		"email", data.Email,
+		"new_email", data.NewEmail,
+		"code_hash", data.CodeHash,
+		"attempts", 0,
+		"expires_at", data.ExpiresAt.UnixMilli(),
+	).Err()
+}
+
+func (s *RedisEmailChangeStorage) UseAttempt(email string) (*EmailChangeData, error) {
+	fields, err := redisHashFields(redisUseAttemptScript.Run(context.Background(), s.client, []string{redisEmailChangePrefix + email}).Result())
+	if err != nil || fields == nil {
+		return nil, err
+	}
+
+	attempts, err := strconv.Atoi(fields["attempts"])
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := strconv.ParseInt(fields["expires_at"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailChangeData{
+		Email:     fields["email"],
+		NewEmail:  fields["new_email"],
+		CodeHash:  fields["code_hash"],
+		Attempts:  attempts,
+		ExpiresAt: time.UnixMilli(expiresAt),
+	}, nil
+}
+
+func (s *RedisEmailChangeStorage) Delete(email string) error {
+	return s.client.Del(context.Background(), redisEmailChangePrefix+email).Err()
+}
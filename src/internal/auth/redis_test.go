@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestRedisVerificationStorage(t *testing.T) {
+	server, client := newTestRedis(t)
+	storage := NewRedisVerificationStorage(client)
+	expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Millisecond)
+
+	if err := storage.Save(&RegistrationData{Email: "user@example.com", Password: "hash", CodeHash: "code", ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := storage.GetByEmail("user@example.com")
+	if err != nil || data == nil {
+		t.Fatalf("GetByEmail = %v, %v", data, err)
+	}
+	if data.Password != "hash" || data.CodeHash != "code" || data.Attempts != 0 || !data.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("GetByEmail = %+v", data)
+	}
+	if ttl := server.TTL(redisVerificationPrefix + "user@example.com"); ttl <= 0 || ttl > 10*time.Minute {
+		t.Errorf("ttl = %v", ttl)
+	}
+
+	for want := 1; want <= 2; want++ {
+		data, err = storage.UseAttempt("user@example.com")
+		if err != nil || data == nil {
+			t.Fatalf("UseAttempt = %v, %v", data, err)
+		}
+		if data.Attempts != want {
+			t.Errorf("attempts = %d, want %d", data.Attempts, want)
+		}
+	}
+
+	// Новый код сбрасывает счётчик попыток
+	if err := storage.Save(&RegistrationData{Email: "user@example.com", Password: "hash", CodeHash: "new", ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err = storage.UseAttempt("user@example.com")
+	if err != nil || data == nil || data.Attempts != 1 || data.CodeHash != "new" {
+		t.Fatalf("UseAttempt after new code = %+v, %v", data, err)
+	}
+
+	if err := storage.Delete("user@example.com"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if data, err := storage.GetByEmail("user@example.com"); err != nil || data != nil {
+		t.Errorf("GetByEmail after delete = %v, %v", data, err)
+	}
+	if data, err := storage.UseAttempt("user@example.com"); err != nil || data != nil {
+		t.Errorf("UseAttempt after delete = %v, %v", data, err)
+	}
+}
+
+func TestRedisCodeExpires(t *testing.T) {
+	server, client := newTestRedis(t)
+	storage := NewRedisVerificationStorage(client)
+
+	if err := storage.Save(&RegistrationData{Email: "user@example.com", CodeHash: "code", ExpiresAt: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	server.FastForward(2 * time.Minute)
+
+	if data, err := storage.UseAttempt("user@example.com"); err != nil || data != nil {
+		t.Errorf("UseAttempt after expiry = %v, %v", data, err)
+	}
+}
+
+func TestRedisResetPasswordStorage(t *testing.T) {
+	_, client := newTestRedis(t)
+	storage := NewRedisResetPasswordStorage(client)
+	expiresAt := time.Now().Add(10 * time.Minute)
+
+	if err := storage.Save(&ResetPasswordData{Email: "user@example.com", CodeHash: "code", ExpiresAt: expiresAt}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := storage.UseAttempt("user@example.com")
+	if err != nil || data == nil || data.Attempts != 1 || data.Verified {
+		t.Fatalf("UseAttempt = %+v, %v", data, err)
+	}
+
+	if err := storage.MarkVerified("user@example.com"); err != nil {
+		t.Fatalf("MarkVerified: %v", err)
+	}
+	data, err = storage.GetByEmail("user@example.com")
+	if err != nil || data == nil || !data.Verified || data.Attempts != 1 {
+		t.Fatalf("GetByEmail after MarkVerified = %+v, %v", data, err)
+	}
+
+	// Для отсутствующего кода MarkVerified не создаёт ключ
+	if err := storage.MarkVerified("other@example.com"); err != nil {
+		t.Fatalf("MarkVerified: %v", err)
+	}
+	if data, err := storage.GetByEmail("other@example.com"); err != nil || data != nil {
+		t.Errorf("GetByEmail for unknown email = %v, %v", data, err)
+	}
+}
+
+func TestRedisEmailChangeStorage(t *testing.T) {
+	_, client := newTestRedis(t)
+	storage := NewRedisEmailChangeStorage(client)
+
+	if err := storage.Save(&EmailChangeData{Email: "user@example.com", NewEmail: "new@example.com", CodeHash: "code", ExpiresAt: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := storage.UseAttempt("user@example.com")
+	if err != nil || data == nil {
+		t.Fatalf("UseAttempt = %v, %v", data, err)
+	}
+	if data.NewEmail != "new@example.com" || data.CodeHash != "code" || data.Attempts != 1 {
+		t.Errorf("UseAttempt = %+v", data)
+	}
+
+	if err := storage.Delete("user@example.com"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if data, err := storage.UseAttempt("user@example.com"); err != nil || data != nil {
+		t.Errorf("UseAttempt after delete = %v, %v", data, err)
+	}
+}
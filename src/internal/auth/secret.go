@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Префикс зашифрованного значения. Значения без него записаны до появления шифрования
+const sealedSecretPrefix = "v1:"
+
+// SecretCipher шифрует секреты, которые нужны в открытом виде для проверки (секреты TOTP),
+// ключом сервера (AES-256-GCM), чтобы утечка БД не раскрывала их. Секрет привязан к владельцу:
+// его адрес передаётся как дополнительные данные GCM, и секрет, скопированный в строку другого
+// пользователя, не расшифровывается
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+func NewSecretCipher(key []byte) (*SecretCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Seal шифрует секрет пользователя owner. Результат - v1:<base64(nonce || ciphertext)>
+func (c *SecretCipher) Seal(secret, owner string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(secret), []byte(owner))
+	return sealedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open расшифровывает секрет пользователя owner. Второе значение - true, если секрет хранился
+// в открытом виде (записан до появления шифрования) и его нужно зашифровать
+func (c *SecretCipher) Open(value, owner string) (string, bool, error) {
+	encoded, ok := strings.CutPrefix(value, sealedSecretPrefix)
+	if !ok {
+		return value, true, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", false, errors.New("invalid sealed secret")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	secret, err := c.aead.Open(nil, nonce, ciphertext, []byte(owner))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(secret), false, nil
+}
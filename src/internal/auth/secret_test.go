@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newTestCipher(t *testing.T, fill byte) *SecretCipher {
+	t.Helper()
+	cipher, err := NewSecretCipher(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatalf("NewSecretCipher: %v", err)
+	}
+	return cipher
+}
+
+func TestSecretCipher(t *testing.T) {
+	cipher := newTestCipher(t, 1)
+
+	sealed, err := cipher.Seal(testTOTPSecret, "user@example.com")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !strings.HasPrefix(sealed, sealedSecretPrefix) || strings.Contains(sealed, testTOTPSecret) {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if other, _ := cipher.Seal(testTOTPSecret, "user@example.com"); other == sealed {
+		t.Error("sealing is deterministic")
+	}
+
+	secret, plaintext, err := cipher.Open(sealed, "user@example.com")
+	if err != nil || plaintext || secret != testTOTPSecret {
+		t.Fatalf("Open = %q, %v, %v", secret, plaintext, err)
+	}
+
+	// Секрет, записанный до шифрования
+	secret, plaintext, err = cipher.Open(testTOTPSecret, "user@example.com")
+	if err != nil || !plaintext || secret != testTOTPSecret {
+		t.Errorf("Open plaintext = %q, %v, %v", secret, plaintext, err)
+	}
+
+	if _, _, err := newTestCipher(t, 2).Open(sealed, "user@example.com"); err == nil {
+		t.Error("opened with another key")
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, _, err := cipher.Open(tampered, "user@example.com"); err == nil {
+		t.Error("opened tampered value")
+	}
+	// Секрет, скопированный в строку другого пользователя, не расшифровывается
+	if _, _, err := cipher.Open(sealed, "attacker@example.com"); err == nil {
+		t.Error("opened secret of another user")
+	}
+}
+
+// Секрет сохраняется зашифрованным, открытый секрет шифруется при чтении
+func TestPostgresMFAStorageEncryptsSecret(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+	cipher := newTestCipher(t, 1)
+	storage := NewPostgresMFAStorage(sqlDB, cipher)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO user_mfa (email, secret)`)).
+		WithArgs("user@example.com", sealedSecretArg{cipher}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT email, secret, enabled, last_used_step FROM user_mfa`)).
+		WithArgs("user@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "secret", "enabled", "last_used_step"}).
+			AddRow("user@example.com", testTOTPSecret, true, nil))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_mfa SET secret = $3 WHERE email = $1 AND secret = $2`)).
+		WithArgs("user@example.com", testTOTPSecret, sealedSecretArg{cipher}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := storage.SaveSecret("user@example.com", testTOTPSecret); err != nil {
+		t.Fatalf("SaveSecret: %v", err)
+	}
+	settings, err := storage.Get("user@example.com")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if settings.Secret != testTOTPSecret {
+		t.Errorf("secret = %q", settings.Secret)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Аргумент запроса - секрет, зашифрованный cipher
+type sealedSecretArg struct {
+	cipher *SecretCipher
+}
+
+func (a sealedSecretArg) Match(value driver.Value) bool {
+	sealed, ok := value.(string)
+	if !ok {
+		return false
+	}
+	secret, plaintext, err := a.cipher.Open(sealed, "user@example.com")
+	return err == nil && !plaintext && secret == testTOTPSecret
+}
@@ -1,24 +1,44 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"src/internal/audit"
 	configPkg "src/internal/config"
+	"src/internal/db"
+	"src/internal/jwtkeys"
+	"src/internal/mail"
 )
 
+// Количество попыток ввода одного кода подтверждения. После исчерпания код удаляется
+const maxCodeAttempts = 5
+
 type Config struct {
-	JWTSecretKey    string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	VerificationTTL time.Duration
 }
 
+// TokenRevoker отзывает все выданные access токены пользователя или отдельные токены по jti
+type TokenRevoker interface {
+	Revoke(email string) error
+	RevokeTokens(tokens map[string]time.Time) error
+}
+
 // Содержит бизнес-логику для работы с авторизацией
 type AuthManager struct {
 	userStorage          UserStorage
@@ -26,8 +46,23 @@ type AuthManager struct {
 	verificationStorage  VerificationStorage
 	resetPasswordStorage ResetPasswordStorage
 	tsUserStorage        TSUserStorage
+	loginAttemptStorage  LoginAttemptStorage
+	securityEventStorage SecurityEventStorage
+	mfaStorage           MFAStorage
+	mfaChallengeStorage  MFAChallengeStorage
+	accountStorage       AccountStorage
+	emailChangeStorage   EmailChangeStorage
+	oidcStateStorage     OIDCStateStorage
+	oidcIdentityStorage  OIDCIdentityStorage
+	oidcProviders        map[string]*oidcProvider
+	tokenRevoker         TokenRevoker
+	signingKeys          *jwtkeys.KeySet
 	emailSender          configPkg.EmailSender
+	auditLog             audit.Recorder
 	config               Config
+	loginProtection      configPkg.LoginProtectionConfig
+	codeHashSecret       []byte
+	uow                  db.UnitOfWork
 }
 
 // Создает новый экземпляр сервиса
@@ -37,8 +72,23 @@ func NewAuthManager(
 	verificationStorage VerificationStorage,
 	resetPasswordStorage ResetPasswordStorage,
 	tsUserStorage TSUserStorage,
+	loginAttemptStorage LoginAttemptStorage,
+	securityEventStorage SecurityEventStorage,
+	mfaStorage MFAStorage,
+	mfaChallengeStorage MFAChallengeStorage,
+	accountStorage AccountStorage,
+	emailChangeStorage EmailChangeStorage,
+	oidcStateStorage OIDCStateStorage,
+	oidcIdentityStorage OIDCIdentityStorage,
+	oidcProviders []configPkg.OIDCProviderConfig,
+	tokenRevoker TokenRevoker,
+	signingKeys *jwtkeys.KeySet,
 	emailSender configPkg.EmailSender,
+	auditLog audit.Recorder,
 	config Config,
+	loginProtection configPkg.LoginProtectionConfig,
+	codeHashSecret []byte,
+	uow db.UnitOfWork,
 ) *AuthManager {
 	return &AuthManager{
 		userStorage:          userStorage,
@@ -46,13 +96,28 @@ func NewAuthManager(
 		verificationStorage:  verificationStorage,
 		resetPasswordStorage: resetPasswordStorage,
 		tsUserStorage:        tsUserStorage,
+		loginAttemptStorage:  loginAttemptStorage,
+		securityEventStorage: securityEventStorage,
+		mfaStorage:           mfaStorage,
+		mfaChallengeStorage:  mfaChallengeStorage,
+		accountStorage:       accountStorage,
+		emailChangeStorage:   emailChangeStorage,
+		oidcStateStorage:     oidcStateStorage,
+		oidcIdentityStorage:  oidcIdentityStorage,
+		oidcProviders:        newOIDCProviders(oidcProviders),
+		tokenRevoker:         tokenRevoker,
+		signingKeys:          signingKeys,
 		emailSender:          emailSender,
+		auditLog:             auditLog,
 		config:               config,
+		loginProtection:      loginProtection,
+		codeHashSecret:       codeHashSecret,
+		uow:                  uow,
 	}
 }
 
-// Регистрация, отправка кода подтверждения
-func (s *AuthManager) Register(email, password string) error {
+// Регистрация, отправка кода подтверждения на языке lang (пустой - язык по умолчанию)
+func (s *AuthManager) Register(email, password, lang string) error {
 	// Проверка существования пользователя
 	existingUser, err := s.userStorage.GetByEmail(email)
 	if err != nil {
@@ -60,7 +125,7 @@ func (s *AuthManager) Register(email, password string) error {
 	}
 
 	if existingUser != nil {
-		return fmt.Errorf(ErrUserAlreadyExists)
+		return errors.New(ErrUserAlreadyExists)
 	}
 
 	// Хеширование пароля
@@ -76,7 +141,7 @@ func (s *AuthManager) Register(email, password string) error {
 	data := &RegistrationData{
 		Email:     email,
 		Password:  hashedPassword,
-		Code:      code,
+		CodeHash:  s.hashCode(email, code),
 		ExpiresAt: time.Now().Add(s.config.VerificationTTL),
 	}
 
@@ -84,10 +149,10 @@ func (s *AuthManager) Register(email, password string) error {
 		return fmt.Errorf("failed to save verification data: %w", err)
 	}
 
-	// Отправление кода
-	if err := s.emailSender.SendVerificationCode(email, code); err != nil {
+	// Постановка письма с кодом в очередь outbox, доставка выполняется фоновым обработчиком
+	if err := s.emailSender.WithLang(lang).SendVerificationCode(email, code); err != nil {
 		s.verificationStorage.Delete(email)
-		return fmt.Errorf("failed to send verification code: %w", err)
+		return fmt.Errorf("failed to queue verification code: %w", err)
 	}
 
 	return nil
@@ -95,24 +160,30 @@ func (s *AuthManager) Register(email, password string) error {
 
 // Верификация кода, окончательная регистрация (добавление пользователя в БД)
 func (s *AuthManager) VerifyCode(email, code string) error {
-	// Получение данных из БД
-	data, err := s.verificationStorage.GetByEmail(email)
+	// Получение данных из хранилища с учётом попытки
+	data, err := s.verificationStorage.UseAttempt(email)
 	if err != nil {
 		return fmt.Errorf("failed to get verification data: %w", err)
 	}
 	if data == nil {
-		return fmt.Errorf(ErrInvalidCode)
+		return errors.New(ErrInvalidCode)
+	}
+
+	// Ограничение перебора кода
+	if data.Attempts > maxCodeAttempts {
+		s.verificationStorage.Delete(email)
+		return errors.New(ErrTooManyAttempts)
 	}
 
 	// Проверка срока действия кода
 	if time.Now().After(data.ExpiresAt) {
 		s.verificationStorage.Delete(email)
-		return fmt.Errorf(ErrCodeExpired)
+		return errors.New(ErrCodeExpired)
 	}
 
 	// Проверка кода
-	if data.Code != code {
-		return fmt.Errorf(ErrInvalidCode)
+	if data.CodeHash != s.hashCode(email, code) {
+		return errors.New(ErrInvalidCode)
 	}
 
 	// Создание пользователя
@@ -136,24 +207,106 @@ func (s *AuthManager) VerifyCode(email, code string) error {
 	return nil
 }
 
-// Вход в систему
-func (s *AuthManager) Login(email, password string) (*TokenResponse, error) {
+// CreateVerifiedUser регистрирует пользователя без отправки кода подтверждения в транзакции tx.
+// Используется, когда владение email уже подтверждено другим способом (например, кодом приглашения в компанию),
+// и выполняется в одной транзакции с действием, которое это подтверждает
+func (s *AuthManager) CreateVerifiedUser(tx *sql.Tx, email, password string) error {
+	userStorage := s.userStorage.WithTx(tx)
+	existingUser, err := userStorage.GetByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to check user existence: %w", err)
+	}
+	if existingUser != nil {
+		return errors.New(ErrUserAlreadyExists)
+	}
+
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := userStorage.Create(&User{Login: email, Password: hashedPassword}); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Создание пользователя в ts_users
+	if err := s.tsUserStorage.WithTx(tx).Create(email); err != nil {
+		return fmt.Errorf("failed to create ts_user record: %w", err)
+	}
+
+	return nil
+}
+
+// Вход в систему. client - данные устройства, с которого выполняется вход
+func (s *AuthManager) Login(email, password string, client ClientInfo) (*TokenResponse, error) {
+	// Проверка блокировок до проверки пароля, чтобы во время блокировки перебор был бесполезен
+	if err := s.checkLoginAllowed(email, client.IP); err != nil {
+		return nil, err
+	}
+
 	// Получение пользователя
 	user, err := s.userStorage.GetByEmail(email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf(ErrUserNotFound)
+		// Пароль сверяется с заглушкой, чтобы время ответа не выдавало, что аккаунта нет
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		s.registerUnknownLoginFailure(email, client)
+		return nil, errors.New(ErrUserNotFound)
 	}
 
 	// Проверка пароля
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, fmt.Errorf(ErrInvalidPassword)
+		s.registerLoginFailure(email, client, "invalid password")
+		return nil, errors.New(ErrInvalidPassword)
+	}
+
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
 	}
 
-	// Генерирация токенов
-	tokens, err := s.generateTokenPair(user)
+	// При подключённой или обязательной 2FA токены выдаются только после второго шага
+	if err := s.checkMFARequired(user.Login, client); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// Завершение входа после проверки всех факторов: сброс счётчика неудач и выдача токенов новой сессии
+func (s *AuthManager) completeLogin(user *User, client ClientInfo) (*TokenResponse, error) {
+	email := user.Login
+
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+
+	// Успешный вход сбрасывает счётчик аккаунта; счётчик IP сбрасывается только по истечении периода
+	if err := s.loginAttemptStorage.Reset(accountKey(email)); err != nil {
+		log.Printf("auth: failed to reset login failures for %s: %v", email, err)
+	}
+	s.addSecurityEvent(email, SecurityEventLoginSuccess, client, "")
+
+	// Язык, с которым пользователь входит, используется для писем, отправляемых без запроса (блокировки, заявки)
+	if client.Lang != "" {
+		if err := s.userStorage.SetLocale(email, client.Lang); err != nil {
+			log.Printf("auth: failed to save locale of %s: %v", email, err)
+		}
+	}
+
+	// Генерирация токенов. Каждый вход начинает новое семейство refresh токенов (новую сессию)
+	deviceName := client.DeviceName
+	if deviceName == "" {
+		deviceName = detectDeviceName(client.UserAgent)
+	}
+	tokens, err := s.generateTokenPair(user, &RefreshTokenData{
+		FamilyID:         uuid.New(),
+		DeviceInfo:       deviceName,
+		UserAgent:        client.UserAgent,
+		IP:               client.IP,
+		SessionStartedAt: time.Now(),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
@@ -161,55 +314,446 @@ func (s *AuthManager) Login(email, password string) (*TokenResponse, error) {
 	return tokens, nil
 }
 
-// Обновление токенов
-func (s *AuthManager) RefreshTokens(refreshToken string) (*TokenResponse, error) {
-	tokenData, err := s.refreshTokenStorage.GetByToken(refreshToken)
+// Проверка, разрешена ли попытка входа для аккаунта и IP адреса
+func (s *AuthManager) checkLoginAllowed(email, ip string) error {
+	keys := []string{accountKey(email)}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+
+	now := time.Now()
+	for i, key := range keys {
+		failures, err := s.loginAttemptStorage.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to get login failures: %w", err)
+		}
+		if failures == nil {
+			continue
+		}
+
+		// Действующая блокировка. Блокировка аккаунта сообщается явно, блокировка IP - как частые попытки
+		if failures.LockedUntil != nil && now.Before(*failures.LockedUntil) {
+			reason := ErrTooManyLoginAttempts
+			if i == 0 {
+				reason = ErrAccountLocked
+			}
+			return &LoginBlockedError{Reason: reason, RetryAfter: failures.LockedUntil.Sub(now)}
+		}
+
+		// Прогрессивная задержка между попытками внутри периода учёта
+		if now.Sub(failures.LastFailureAt) > s.loginProtection.FailureWindow {
+			continue
+		}
+		next := failures.LastFailureAt.Add(s.loginDelay(failures.Failures))
+		if now.Before(next) {
+			return &LoginBlockedError{Reason: ErrTooManyLoginAttempts, RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// Задержка перед следующей попыткой после failures неудач подряд
+func (s *AuthManager) loginDelay(failures int) time.Duration {
+	cfg := s.loginProtection
+	if failures < cfg.DelayAfter || cfg.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := cfg.BaseDelay
+	for i := cfg.DelayAfter; i < failures && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, cfg.MaxDelay)
+}
+
+// Учёт неудачной попытки входа (неверный пароль или код 2FA). Ошибки хранилища только логируются,
+// чтобы не раскрывать их в ответе на вход
+func (s *AuthManager) registerLoginFailure(email string, client ClientInfo, details string) {
+	s.addSecurityEvent(email, SecurityEventLoginFailed, client, details)
+
+	failures, lockedUntil, locked := s.countLoginFailure(email, client)
+	if !locked {
+		return
+	}
+
+	s.addSecurityEvent(email, SecurityEventAccountLocked, client, fmt.Sprintf("locked until %s", lockedUntil.UTC().Format(time.RFC3339)))
+
+	err := s.emailSender.SendAccountLocked(email, mail.AccountLockedData{
+		Attempts:    failures,
+		IP:          client.IP,
+		LockedUntil: lockedUntil.UTC(),
+	})
+	if err != nil {
+		log.Printf("auth: failed to queue account locked email for %s: %v", email, err)
+	}
+}
+
+// Учёт неудачной попытки входа в несуществующий аккаунт. Счётчик и блокировка ведутся так же,
+// как для существующего, чтобы ответы не выдавали, зарегистрирован ли адрес. Событий и писем нет
+func (s *AuthManager) registerUnknownLoginFailure(email string, client ClientInfo) {
+	s.countLoginFailure(email, client)
+}
+
+// Увеличение счётчиков неудач аккаунта и IP адреса с блокировкой при достижении порога.
+// Возвращает число неудач аккаунта и срок блокировки, если эта попытка заблокировала аккаунт
+func (s *AuthManager) countLoginFailure(email string, client ClientInfo) (int, time.Time, bool) {
+	cfg := s.loginProtection
+	now := time.Now()
+
+	if client.IP != "" {
+		failures, err := s.loginAttemptStorage.RegisterFailure(ipKey(client.IP), cfg.FailureWindow)
+		if err != nil {
+			log.Printf("auth: failed to register login failure for ip %s: %v", client.IP, err)
+		} else if cfg.IPLockoutThreshold > 0 && failures.Failures == cfg.IPLockoutThreshold {
+			log.Printf("auth: ip %s locked after %d failed login attempts", client.IP, failures.Failures)
+			if err := s.loginAttemptStorage.Lock(ipKey(client.IP), now.Add(cfg.LockoutDuration)); err != nil {
+				log.Printf("auth: failed to lock ip %s: %v", client.IP, err)
+			}
+		}
+	}
+
+	failures, err := s.loginAttemptStorage.RegisterFailure(accountKey(email), cfg.FailureWindow)
+	if err != nil {
+		log.Printf("auth: failed to register login failure for %s: %v", email, err)
+		return 0, time.Time{}, false
+	}
+
+	// Счётчик увеличивается атомарно, поэтому ровно одна попытка достигает порога
+	if cfg.LockoutThreshold <= 0 || failures.Failures != cfg.LockoutThreshold {
+		return failures.Failures, time.Time{}, false
+	}
+
+	lockedUntil := now.Add(cfg.LockoutDuration)
+	if err := s.loginAttemptStorage.Lock(accountKey(email), lockedUntil); err != nil {
+		log.Printf("auth: failed to lock account %s: %v", email, err)
+		return failures.Failures, time.Time{}, false
+	}
+
+	log.Printf("auth: account %s locked until %s after %d failed login attempts", email, lockedUntil.Format(time.RFC3339), failures.Failures)
+	return failures.Failures, lockedUntil, true
+}
+
+// UnlockAccount снимает блокировку входа и сбрасывает счётчик неудачных попыток. Вызывается администратором
+func (s *AuthManager) UnlockAccount(actor audit.Actor, email string) error {
+	user, err := s.userStorage.GetByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return errors.New(ErrUserNotFound)
+	}
+
+	// Счётчик может храниться вне БД, поэтому запись в журнал добавляется первой:
+	// если сбросить счётчик не удалось, транзакция с ней откатывается
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		if err := s.auditLog.Record(tx, actor, audit.ActionUserUnlock, audit.TargetUser, email, nil, nil); err != nil {
+			return err
+		}
+		if err := s.loginAttemptStorage.Reset(accountKey(email)); err != nil {
+			return fmt.Errorf("failed to reset login failures: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("auth: account %s unlocked by %s", email, actor.Email)
+	s.addSecurityEvent(email, SecurityEventAccountUnlocked, ClientInfo{}, "unlocked by administrator")
+	return nil
+}
+
+// SuspendUser блокирует пользователя по решению администратора: вход и обновление токенов запрещаются,
+// сессии завершаются, выданные access токены отзываются. Причина отправляется пользователю на почту
+func (s *AuthManager) SuspendUser(actor audit.Actor, email, reason string) error {
+	if strings.EqualFold(actor.Email, email) {
+		return errors.New(ErrSuspendSelf)
+	}
+
+	user, err := s.userStorage.GetByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return errors.New(ErrUserNotFound)
+	}
+
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		suspended, err := s.userStorage.WithTx(tx).Suspend(user.Login, reason)
+		if err != nil {
+			return fmt.Errorf("failed to suspend user: %w", err)
+		}
+		if !suspended {
+			return errors.New(ErrAlreadySuspended)
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionUserSuspend, audit.TargetUser, user.Login,
+			map[string]any{"suspended": false}, map[string]any{"suspended": true, "reason": reason})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Сессии удаляются, поэтому refresh токены перестают действовать и после снятия блокировки
+	tokens, err := s.refreshTokenStorage.DeleteAllByEmail(user.Login, uuid.Nil)
+	if err != nil {
+		log.Printf("auth: failed to revoke sessions of suspended %s: %v", user.Login, err)
+	}
+	s.revokeAccessTokens(user.Login, tokens)
+	if err := s.tokenRevoker.Revoke(user.Login); err != nil {
+		log.Printf("auth: failed to revoke tokens of suspended %s: %v", user.Login, err)
+	}
+
+	log.Printf("auth: account %s suspended by %s", user.Login, actor.Email)
+	s.addSecurityEvent(user.Login, SecurityEventSuspended, ClientInfo{}, reason)
+
+	if err := s.emailSender.SendAccountSuspended(user.Login, mail.SuspensionData{Reason: reason, At: time.Now()}); err != nil {
+		log.Printf("auth: failed to queue suspension email for %s: %v", user.Login, err)
+	}
+	return nil
+}
+
+// ReinstateUser снимает блокировку пользователя. comment (необязательный) отправляется пользователю на почту
+func (s *AuthManager) ReinstateUser(actor audit.Actor, email, comment string) error {
+	user, err := s.userStorage.GetByEmail(email)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return errors.New(ErrUserNotFound)
+	}
+
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		reinstated, err := s.userStorage.WithTx(tx).Reinstate(user.Login)
+		if err != nil {
+			return fmt.Errorf("failed to reinstate user: %w", err)
+		}
+		if !reinstated {
+			return errors.New(ErrNotSuspended)
+		}
+		return s.auditLog.Record(tx, actor, audit.ActionUserReinstate, audit.TargetUser, user.Login,
+			map[string]any{"suspended": true, "reason": user.SuspensionReason}, map[string]any{"suspended": false})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("auth: account %s reinstated by %s", user.Login, actor.Email)
+	s.addSecurityEvent(user.Login, SecurityEventReinstated, ClientInfo{}, comment)
+
+	if err := s.emailSender.SendAccountReinstated(user.Login, mail.SuspensionData{Reason: comment, At: time.Now()}); err != nil {
+		log.Printf("auth: failed to queue reinstatement email for %s: %v", user.Login, err)
+	}
+	return nil
+}
+
+// Проверка, что аккаунт не заблокирован администратором
+func checkNotSuspended(user *User) error {
+	if user.SuspendedAt != nil {
+		return errors.New(ErrAccountSuspended)
+	}
+	return nil
+}
+
+// GetSecurityEvents возвращает последние события безопасности пользователя
+func (s *AuthManager) GetSecurityEvents(email string, limit int) ([]SecurityEvent, error) {
+	events, err := s.securityEventStorage.GetByEmail(email, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get security events: %w", err)
+	}
+	return events, nil
+}
+
+// Запись события в журнал безопасности. Ошибка записи не прерывает вход
+func (s *AuthManager) addSecurityEvent(email, eventType string, client ClientInfo, details string) {
+	event := &SecurityEvent{
+		Email:     email,
+		Type:      eventType,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Details:   details,
+	}
+	if err := s.securityEventStorage.Add(event); err != nil {
+		log.Printf("auth: failed to add security event %s for %s: %v", eventType, email, err)
+	}
+}
+
+// Ключи счётчиков неудачных попыток входа
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Обновление токенов с ротацией: предъявленный refresh токен становится недействительным,
+// новый принадлежит тому же семейству. Повторное предъявление уже использованного токена
+// означает, что он утёк, поэтому отзывается всё семейство, а пользователь получает письмо.
+// User-Agent и IP сессии обновляются данными client, название устройства сохраняется
+func (s *AuthManager) RefreshTokens(refreshToken string, client ClientInfo) (*TokenResponse, error) {
+	tokenHash := hashToken(refreshToken)
+
+	tokenData, err := s.refreshTokenStorage.GetByToken(tokenHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get refresh token: %w", err)
 	}
 	if tokenData == nil {
-		return nil, fmt.Errorf(ErrInvalidRefreshToken)
+		return nil, errors.New(ErrInvalidRefreshToken)
+	}
+
+	// Токен уже был обменян на новый
+	if tokenData.RotatedAt != nil {
+		s.revokeReusedFamily(tokenData)
+		return nil, errors.New(ErrRefreshTokenReused)
 	}
 
 	// Получение срока действия
 	if time.Now().After(tokenData.ExpiresAt) {
-		s.refreshTokenStorage.Delete(refreshToken)
-		return nil, fmt.Errorf(ErrRefreshTokenExpired)
+		s.refreshTokenStorage.DeleteFamily(tokenData.FamilyID)
+		return nil, errors.New(ErrRefreshTokenExpired)
 	}
 
 	// Получения пользователя
 	user, err := s.userStorage.GetByEmail(tokenData.Email)
 	if err != nil || user == nil {
-		s.refreshTokenStorage.Delete(refreshToken)
-		return nil, fmt.Errorf(ErrUserNotFound)
+		s.refreshTokenStorage.DeleteFamily(tokenData.FamilyID)
+		return nil, errors.New(ErrUserNotFound)
+	}
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
 	}
 
-	// Удаление старого токена
-	s.refreshTokenStorage.Delete(refreshToken)
-
-	// Генерация нового
-	tokens, err := s.generateTokenPair(user)
+	// Ротация старого токена и сохранение нового в одной транзакции: при ошибке сохранения
+	// старый токен остаётся действительным, и сессия не теряется.
+	// Если токен успел обменять параллельный запрос, это тоже повторное использование
+	var tokens *TokenResponse
+	rotated := false
+	err = s.uow.Do(func(tx *sql.Tx) error {
+		storage := s.refreshTokenStorage.WithTx(tx)
+		var err error
+		rotated, err = storage.MarkRotated(tokenHash)
+		if err != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		if !rotated {
+			return nil
+		}
+
+		tokens, err = s.issueTokenPair(storage, user, &RefreshTokenData{
+			FamilyID:         tokenData.FamilyID,
+			DeviceInfo:       tokenData.DeviceInfo,
+			UserAgent:        client.UserAgent,
+			IP:               client.IP,
+			SessionStartedAt: tokenData.SessionStartedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate tokens: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate tokens: %w", err)
+		return nil, err
+	}
+	if !rotated {
+		s.revokeReusedFamily(tokenData)
+		return nil, errors.New(ErrRefreshTokenReused)
 	}
 
 	return tokens, nil
 }
 
-// Выход из системы
+// Отзыв семейства токенов при повторном использовании и предупреждение пользователя
+func (s *AuthManager) revokeReusedFamily(tokenData *RefreshTokenData) {
+	log.Printf("auth: refresh token reuse detected for %s, revoking family %s", tokenData.Email, tokenData.FamilyID)
+
+	tokens, err := s.refreshTokenStorage.DeleteFamily(tokenData.FamilyID)
+	if err != nil {
+		log.Printf("auth: failed to revoke refresh token family %s: %v", tokenData.FamilyID, err)
+	}
+	s.revokeAccessTokens(tokenData.Email, tokens)
+
+	err = s.emailSender.SendTokenReuseAlert(tokenData.Email, mail.TokenReuseData{
+		DetectedAt: time.Now().UTC(),
+		DeviceInfo: tokenData.DeviceInfo,
+	})
+	if err != nil {
+		log.Printf("auth: failed to queue token reuse alert for %s: %v", tokenData.Email, err)
+	}
+}
+
+// Выход из системы: отзываются все токены семейства, к которому относится refresh токен,
+// и access токены, выданные вместе с ними
 func (s *AuthManager) Logout(refreshToken string) error {
-	return s.refreshTokenStorage.Delete(refreshToken)
+	tokenData, err := s.refreshTokenStorage.GetByToken(hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if tokenData == nil {
+		return nil
+	}
+
+	tokens, err := s.refreshTokenStorage.DeleteFamily(tokenData.FamilyID)
+	if err != nil {
+		return err
+	}
+	s.revokeAccessTokens(tokenData.Email, tokens)
+	return nil
+}
+
+// GetSessions возвращает активные сессии пользователя. currentID - сессия, с которой выполнен запрос
+func (s *AuthManager) GetSessions(email string, currentID uuid.UUID) ([]Session, error) {
+	sessions, err := s.refreshTokenStorage.GetSessions(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// DeleteSession завершает сессию пользователя: отзываются все refresh токены семейства
+// и выданные вместе с ними access токены
+func (s *AuthManager) DeleteSession(email string, id uuid.UUID) error {
+	deleted, tokens, err := s.refreshTokenStorage.DeleteSession(email, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if !deleted {
+		return errors.New(ErrSessionNotFound)
+	}
+	s.revokeAccessTokens(email, tokens)
+	return nil
+}
+
+// LogoutOtherSessions завершает все сессии пользователя, кроме текущей
+func (s *AuthManager) LogoutOtherSessions(email string, currentID uuid.UUID) error {
+	tokens, err := s.refreshTokenStorage.DeleteAllByEmail(email, currentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	s.revokeAccessTokens(email, tokens)
+	return nil
+}
+
+// Отзыв access токенов завершённых сессий. Сессии уже удалены, поэтому ошибка только логируется:
+// токены перестанут действовать по истечении срока
+func (s *AuthManager) revokeAccessTokens(email string, tokens AccessTokens) {
+	if err := s.tokenRevoker.RevokeTokens(tokens); err != nil {
+		log.Printf("auth: failed to revoke access tokens of %s: %v", email, err)
+	}
 }
 
-// ForgotPassword - отправка кода для восстановления пароля
-func (s *AuthManager) ForgotPassword(email string) error {
+// ForgotPassword - отправка кода для восстановления пароля на языке lang (пустой - сохранённый язык пользователя)
+func (s *AuthManager) ForgotPassword(email, lang string) error {
 	// Проверка существования пользователя
 	user, err := s.userStorage.GetByEmail(email)
 	if err != nil {
 		return fmt.Errorf("failed to check user existence: %w", err)
 	}
 	if user == nil {
-		return fmt.Errorf(ErrUserNotFound)
+		return errors.New(ErrUserNotFound)
 	}
 
 	// Генерация кода
@@ -218,7 +762,7 @@ func (s *AuthManager) ForgotPassword(email string) error {
 	// Сохранение данных для восстановления пароля
 	data := &ResetPasswordData{
 		Email:     email,
-		Code:      code,
+		CodeHash:  s.hashCode(email, code),
 		ExpiresAt: time.Now().Add(s.config.VerificationTTL),
 		Verified:  false,
 	}
@@ -227,10 +771,10 @@ func (s *AuthManager) ForgotPassword(email string) error {
 		return fmt.Errorf("failed to save reset code: %w", err)
 	}
 
-	// Отправка кода на email
-	if err := s.emailSender.SendVerificationResetCode(email, code); err != nil {
+	// Постановка письма с кодом в очередь outbox
+	if err := s.emailSender.WithLang(lang).SendVerificationResetCode(email, code); err != nil {
 		s.resetPasswordStorage.Delete(email)
-		return fmt.Errorf("failed to send reset code: %w", err)
+		return fmt.Errorf("failed to queue reset code: %w", err)
 	}
 
 	return nil
@@ -238,24 +782,30 @@ func (s *AuthManager) ForgotPassword(email string) error {
 
 // VerifyResetCode: подтверждение кода
 func (s *AuthManager) VerifyResetCode(email, code string) error {
-	// Получение данных из хранилища
-	data, err := s.resetPasswordStorage.GetByEmail(email)
+	// Получение данных из хранилища с учётом попытки
+	data, err := s.resetPasswordStorage.UseAttempt(email)
 	if err != nil {
 		return fmt.Errorf("failed to get reset data: %w", err)
 	}
 	if data == nil {
-		return fmt.Errorf(ErrInvalidCode)
+		return errors.New(ErrInvalidCode)
+	}
+
+	// Ограничение перебора кода
+	if data.Attempts > maxCodeAttempts {
+		s.resetPasswordStorage.Delete(email)
+		return errors.New(ErrTooManyAttempts)
 	}
 
 	// Проверка срока действия кода
 	if time.Now().After(data.ExpiresAt) {
 		s.resetPasswordStorage.Delete(email)
-		return fmt.Errorf(ErrCodeExpired)
+		return errors.New(ErrCodeExpired)
 	}
 
 	// Проверка кода
-	if data.Code != code {
-		return fmt.Errorf(ErrInvalidCode)
+	if data.CodeHash != s.hashCode(email, code) {
+		return errors.New(ErrInvalidCode)
 	}
 
 	if err := s.resetPasswordStorage.MarkVerified(email); err != nil {
@@ -265,8 +815,9 @@ func (s *AuthManager) VerifyResetCode(email, code string) error {
 	return nil
 }
 
-// SetNewPassword: установка нового пароля
-func (s *AuthManager) SetPassword(email, newPassword string) error {
+// SetPassword: установка нового пароля после подтверждения кода сброса.
+// Пароль могли украсть, поэтому завершаются все сессии и отзываются выданные access токены
+func (s *AuthManager) SetPassword(email, newPassword string, client ClientInfo) error {
 	// Проверка, что код был подтверждён
 	data, err := s.resetPasswordStorage.GetByEmail(email)
 	if err != nil {
@@ -293,46 +844,75 @@ func (s *AuthManager) SetPassword(email, newPassword string) error {
 	// Удаление временных данных
 	s.resetPasswordStorage.Delete(email)
 
+	tokens, err := s.refreshTokenStorage.DeleteAllByEmail(email, uuid.Nil)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	s.revokeAccessTokens(email, tokens)
+
+	s.addSecurityEvent(email, SecurityEventPasswordChanged, client, "")
 	return nil
 }
 
-// Создание пары токенов
-func (s *AuthManager) generateTokenPair(user *User) (*TokenResponse, error) {
-	// Access token
+// Создание пары токенов. session содержит семейство, к которому относится новый refresh токен,
+// и данные устройства; остальные поля заполняются здесь
+func (s *AuthManager) generateTokenPair(user *User, session *RefreshTokenData) (*TokenResponse, error) {
+	return s.issueTokenPair(s.refreshTokenStorage, user, session)
+}
+
+// Создание пары токенов с сохранением refresh токена в storage (например, в транзакции ротации)
+func (s *AuthManager) issueTokenPair(storage RefreshTokenStorage, user *User, session *RefreshTokenData) (*TokenResponse, error) {
+	// Роль определяется при каждой выдаче токенов, поэтому после отзыва
+	// достаточно обновить пару через /auth/refresh
+	role, err := s.userStorage.GetRole(user.Login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user role: %w", err)
+	}
+
+	// Access token. jti нужен для отзыва токена до истечения срока
+	now := time.Now()
+	session.AccessJTI = uuid.NewString()
+	session.AccessExpiresAt = now.Add(s.config.AccessTokenTTL)
 	accessClaims := jwt.MapClaims{
 		"email": user.Login,
 		"type":  "access",
-		"exp":   time.Now().Add(s.config.AccessTokenTTL).Unix(),
+		"role":  role.Role,
+		"sid":   session.FamilyID.String(),
+		"jti":   session.AccessJTI,
+		"iat":   now.Unix(),
+		"exp":   session.AccessExpiresAt.Unix(),
+	}
+	if role.INN != "" {
+		accessClaims["inn"] = role.INN
+		accessClaims["company_role"] = role.CompanyRole
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(s.config.JWTSecretKey))
+	accessTokenString, err := s.signingKeys.Sign(accessClaims)
 	if err != nil {
 		return nil, err
 	}
 
 	// Refresh token
+	// jti делает токен уникальным, даже если два токена выданы в одну секунду
 	refreshClaims := jwt.MapClaims{
 		"email": user.Login,
 		"type":  "refresh",
+		"jti":   uuid.NewString(),
 		"exp":   time.Now().Add(s.config.RefreshTokenTTL).Unix(),
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(s.config.JWTSecretKey))
+	refreshTokenString, err := s.signingKeys.Sign(refreshClaims)
 	if err != nil {
 		return nil, err
 	}
 
 	// Сохранение refresh token в БД
-	refreshData := &RefreshTokenData{
-		Token:     refreshTokenString,
-		Email:     user.Login,
-		ExpiresAt: time.Now().Add(s.config.RefreshTokenTTL),
-		LastUsed:  time.Now(),
-	}
+	session.TokenHash = hashToken(refreshTokenString)
+	session.Email = user.Login
+	session.ExpiresAt = time.Now().Add(s.config.RefreshTokenTTL)
+	session.LastUsed = time.Now()
 
-	if err := s.refreshTokenStorage.Save(refreshData); err != nil {
+	if err := storage.Save(session); err != nil {
 		return nil, fmt.Errorf("failed to save refresh token: %w", err)
 	}
 
@@ -344,6 +924,78 @@ func (s *AuthManager) generateTokenPair(user *User) (*TokenResponse, error) {
 	}, nil
 }
 
+// JWKS возвращает открытые ключи, которыми можно проверить выданные access токены
+func (s *AuthManager) JWKS() jwtkeys.JWKS {
+	return s.signingKeys.JWKS()
+}
+
+// Хеш refresh токена для хранения в БД
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Название устройства по User-Agent в виде "Браузер on ОС"
+func detectDeviceName(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp/", "Android app"},
+		{"CFNetwork/", "iOS app"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	system := ""
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, o.token) {
+			system = o.name
+			break
+		}
+	}
+
+	if system == "" {
+		return browser
+	}
+	return browser + " on " + system
+}
+
+// Хеш кода подтверждения: HMAC-SHA256 с ключом сервера (CODE_HASH_SECRET), поэтому короткий код
+// нельзя подобрать по хешу из утёкшей БД. Email входит в хеш, чтобы одинаковые коды разных пользователей не совпадали
+func (s *AuthManager) hashCode(email, code string) string {
+	mac := hmac.New(sha256.New, s.codeHashSecret)
+	mac.Write([]byte(email + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Хеш кода восстановления 2FA. Email в хеш не входит, чтобы коды сохранялись при смене адреса:
+// коды ищутся только среди кодов пользователя, поэтому совпадение кодов разных пользователей не мешает
+func (s *AuthManager) hashRecoveryCode(code string) string {
+	mac := hmac.New(sha256.New, s.codeHashSecret)
+	mac.Write([]byte("recovery:" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // Генерация кода
 func (s *AuthManager) generateVerificationCode() string {
 	b := make([]byte, 3)
@@ -351,6 +1003,12 @@ func (s *AuthManager) generateVerificationCode() string {
 	return hex.EncodeToString(b)[:6]
 }
 
+// Хеш-заглушка для сверки пароля при входе в несуществующий аккаунт. Стоимость та же, что у паролей пользователей
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
 // Хеширование пароля
 func HashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -0,0 +1,356 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	configPkg "src/internal/config"
+	"src/internal/jwtkeys"
+	"src/internal/mail"
+	"src/internal/rbac"
+)
+
+// Пользователи в памяти
+type memoryUsers struct {
+	UserStorage
+	users map[string]*User
+}
+
+func (s *memoryUsers) GetByEmail(email string) (*User, error) {
+	return s.users[email], nil
+}
+
+func (s *memoryUsers) GetRole(email string) (*UserRole, error) {
+	return &UserRole{Role: rbac.RoleClient}, nil
+}
+
+func (s *memoryUsers) UpdatePassword(email, hashedPassword string) error {
+	s.users[email].Password = hashedPassword
+	return nil
+}
+
+func (s *memoryUsers) WithTx(tx *sql.Tx) UserStorage {
+	return s
+}
+
+// Коды сброса пароля в памяти
+type memoryResetPassword struct {
+	ResetPasswordStorage
+	data map[string]*ResetPasswordData
+}
+
+func (s *memoryResetPassword) GetByEmail(email string) (*ResetPasswordData, error) {
+	return s.data[email], nil
+}
+
+func (s *memoryResetPassword) Delete(email string) error {
+	delete(s.data, email)
+	return nil
+}
+
+// Refresh токены в памяти
+type memoryRefreshTokens struct {
+	RefreshTokenStorage
+	tokens  map[string]RefreshTokenData
+	saveErr error
+}
+
+func (s *memoryRefreshTokens) Save(token *RefreshTokenData) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.tokens[token.TokenHash] = *token
+	return nil
+}
+
+func (s *memoryRefreshTokens) GetByToken(tokenHash string) (*RefreshTokenData, error) {
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (s *memoryRefreshTokens) MarkRotated(tokenHash string) (bool, error) {
+	token, ok := s.tokens[tokenHash]
+	if !ok || token.RotatedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.RotatedAt = &now
+	s.tokens[tokenHash] = token
+	return true, nil
+}
+
+func (s *memoryRefreshTokens) DeleteFamily(familyID uuid.UUID) (AccessTokens, error) {
+	tokens := AccessTokens{}
+	for hash, token := range s.tokens {
+		if token.FamilyID == familyID {
+			tokens[token.AccessJTI] = token.AccessExpiresAt
+			delete(s.tokens, hash)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *memoryRefreshTokens) DeleteAllByEmail(email string, except uuid.UUID) (AccessTokens, error) {
+	tokens := AccessTokens{}
+	for hash, token := range s.tokens {
+		if token.FamilyID != except {
+			tokens[token.AccessJTI] = token.AccessExpiresAt
+			delete(s.tokens, hash)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *memoryRefreshTokens) WithTx(tx *sql.Tx) RefreshTokenStorage {
+	return s
+}
+
+// Транзакция над хранилищем refresh токенов в памяти: при ошибке fn изменения отменяются
+type memoryUnitOfWork struct {
+	tokens *memoryRefreshTokens
+}
+
+func (u *memoryUnitOfWork) Do(fn func(tx *sql.Tx) error) error {
+	snapshot := maps.Clone(u.tokens.tokens)
+	if err := fn(nil); err != nil {
+		u.tokens.tokens = snapshot
+		return err
+	}
+	return nil
+}
+
+type recordingTokenRevoker struct {
+	revoked AccessTokens
+}
+
+func (r *recordingTokenRevoker) Revoke(email string) error {
+	return nil
+}
+
+func (r *recordingTokenRevoker) RevokeTokens(tokens map[string]time.Time) error {
+	maps.Copy(r.revoked, tokens)
+	return nil
+}
+
+// Письма, поставленные в очередь
+type recordingEmailSender struct {
+	configPkg.EmailSender
+	reuseAlerts    []string
+	lockedAlerts   []string
+	lockedDetails  []mail.AccountLockedData
+	suspensions    []string
+	reinstatements []string
+}
+
+func (s *recordingEmailSender) SendTokenReuseAlert(toEmail string, data mail.TokenReuseData) error {
+	s.reuseAlerts = append(s.reuseAlerts, toEmail)
+	return nil
+}
+
+func (s *recordingEmailSender) SendAccountLocked(toEmail string, data mail.AccountLockedData) error {
+	s.lockedAlerts = append(s.lockedAlerts, toEmail)
+	s.lockedDetails = append(s.lockedDetails, data)
+	return nil
+}
+
+func (s *recordingEmailSender) WithLang(lang string) configPkg.EmailSender {
+	return s
+}
+
+type noAudit struct{}
+
+func (noAudit) Record(tx *sql.Tx, actor audit.Actor, action, targetType, targetID string, before, after any) error {
+	return nil
+}
+
+type testAuth struct {
+	*AuthManager
+	users   *memoryUsers
+	tokens  *memoryRefreshTokens
+	revoker *recordingTokenRevoker
+	email   *recordingEmailSender
+}
+
+func newTestAuth(t *testing.T) *testAuth {
+	t.Helper()
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keys, err := jwtkeys.NewKeySet(signer)
+	if err != nil {
+		t.Fatalf("key set: %v", err)
+	}
+
+	ta := &testAuth{
+		users:   &memoryUsers{users: map[string]*User{"user@example.com": {Login: "user@example.com"}}},
+		tokens:  &memoryRefreshTokens{tokens: map[string]RefreshTokenData{}},
+		revoker: &recordingTokenRevoker{revoked: AccessTokens{}},
+		email:   &recordingEmailSender{},
+	}
+	ta.AuthManager = &AuthManager{
+		userStorage:         ta.users,
+		refreshTokenStorage: ta.tokens,
+		tokenRevoker:        ta.revoker,
+		signingKeys:         keys,
+		emailSender:         ta.email,
+		auditLog:            noAudit{},
+		config:              Config{AccessTokenTTL: 15 * time.Minute, RefreshTokenTTL: 24 * time.Hour},
+		codeHashSecret:      []byte("test-code-hash-secret-0123456789abcdef"),
+		uow:                 &memoryUnitOfWork{tokens: ta.tokens},
+	}
+	return ta
+}
+
+// Вход: первая пара токенов нового семейства
+func (ta *testAuth) login(t *testing.T) *TokenResponse {
+	t.Helper()
+	tokens, err := ta.generateTokenPair(ta.users.users["user@example.com"], &RefreshTokenData{
+		FamilyID:         uuid.New(),
+		DeviceInfo:       "Firefox on Linux",
+		SessionStartedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("generateTokenPair: %v", err)
+	}
+	return tokens
+}
+
+func TestRefreshTokensRotates(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+
+	second, err := ta.RefreshTokens(first.RefreshToken, ClientInfo{UserAgent: "curl", IP: "203.0.113.7"})
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	old := ta.tokens.tokens[hashToken(first.RefreshToken)]
+	current := ta.tokens.tokens[hashToken(second.RefreshToken)]
+	if old.RotatedAt == nil {
+		t.Error("old token is not marked rotated")
+	}
+	if current.FamilyID != old.FamilyID || current.DeviceInfo != old.DeviceInfo || current.IP != "203.0.113.7" {
+		t.Errorf("new token = %+v, old = %+v", current, old)
+	}
+
+	// Новый токен тоже обменивается
+	if _, err := ta.RefreshTokens(second.RefreshToken, ClientInfo{}); err != nil {
+		t.Fatalf("RefreshTokens with rotated token: %v", err)
+	}
+}
+
+// Повторное предъявление обменянного токена отзывает всё семейство и предупреждает пользователя
+func TestRefreshTokensReuseRevokesFamily(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+	second, err := ta.RefreshTokens(first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	accessJTI := ta.tokens.tokens[hashToken(second.RefreshToken)].AccessJTI
+
+	_, err = ta.RefreshTokens(first.RefreshToken, ClientInfo{})
+	if err == nil || err.Error() != ErrRefreshTokenReused {
+		t.Fatalf("reuse error = %v, want %q", err, ErrRefreshTokenReused)
+	}
+	if len(ta.tokens.tokens) != 0 {
+		t.Errorf("family not revoked, %d tokens left", len(ta.tokens.tokens))
+	}
+	if _, ok := ta.revoker.revoked[accessJTI]; !ok {
+		t.Errorf("access token %s of the family was not revoked", accessJTI)
+	}
+	if len(ta.email.reuseAlerts) != 1 {
+		t.Errorf("reuse alerts = %v", ta.email.reuseAlerts)
+	}
+
+	// Токен, выданный злоумышленнику или владельцу после утечки, тоже недействителен
+	if _, err := ta.RefreshTokens(second.RefreshToken, ClientInfo{}); err == nil || err.Error() != ErrInvalidRefreshToken {
+		t.Errorf("refresh after revocation error = %v, want %q", err, ErrInvalidRefreshToken)
+	}
+}
+
+// Если новый токен не сохранился, ротация отменяется и старый токен остаётся действительным
+func TestRefreshTokensRollsBackRotation(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+
+	ta.tokens.saveErr = errors.New("connection reset")
+	if _, err := ta.RefreshTokens(first.RefreshToken, ClientInfo{}); err == nil {
+		t.Fatal("RefreshTokens succeeded with failing storage")
+	}
+	if ta.tokens.tokens[hashToken(first.RefreshToken)].RotatedAt != nil {
+		t.Fatal("old token stayed rotated after failed save")
+	}
+
+	ta.tokens.saveErr = nil
+	if _, err := ta.RefreshTokens(first.RefreshToken, ClientInfo{}); err != nil {
+		t.Fatalf("RefreshTokens retry: %v", err)
+	}
+	if len(ta.email.reuseAlerts) != 0 {
+		t.Errorf("retry was treated as reuse: %v", ta.email.reuseAlerts)
+	}
+}
+
+func TestRefreshTokensExpired(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+
+	hash := hashToken(first.RefreshToken)
+	token := ta.tokens.tokens[hash]
+	token.ExpiresAt = time.Now().Add(-time.Minute)
+	ta.tokens.tokens[hash] = token
+
+	if _, err := ta.RefreshTokens(first.RefreshToken, ClientInfo{}); err == nil || err.Error() != ErrRefreshTokenExpired {
+		t.Fatalf("error = %v, want %q", err, ErrRefreshTokenExpired)
+	}
+	if len(ta.tokens.tokens) != 0 {
+		t.Error("expired family was not deleted")
+	}
+}
+
+// Сброс пароля завершает все сессии и отзывает выданные access токены
+func TestSetPasswordRevokesSessions(t *testing.T) {
+	ta := newTestAuth(t)
+	events := &memorySecurityEvents{}
+	ta.securityEventStorage = events
+	ta.resetPasswordStorage = &memoryResetPassword{data: map[string]*ResetPasswordData{
+		"user@example.com": {Email: "user@example.com", Verified: true},
+	}}
+	tokens := ta.login(t)
+	accessJTI := ta.tokens.tokens[hashToken(tokens.RefreshToken)].AccessJTI
+
+	if err := ta.SetPassword("user@example.com", "N3w_password", ClientInfo{IP: "203.0.113.7"}); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if ta.users.users["user@example.com"].Password == "" {
+		t.Error("password is not updated")
+	}
+	if len(ta.tokens.tokens) != 0 {
+		t.Errorf("sessions left = %d, want 0", len(ta.tokens.tokens))
+	}
+	if _, ok := ta.revoker.revoked[accessJTI]; !ok {
+		t.Errorf("access token %s is not revoked", accessJTI)
+	}
+	if len(events.events) != 1 || events.events[0].Type != SecurityEventPasswordChanged || events.events[0].IP != "203.0.113.7" {
+		t.Errorf("security events = %+v", events.events)
+	}
+
+	if _, err := ta.RefreshTokens(tokens.RefreshToken, ClientInfo{}); err == nil {
+		t.Error("refresh token issued before the reset still works")
+	}
+}
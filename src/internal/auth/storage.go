@@ -3,8 +3,15 @@ package auth
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+
+	"src/internal/db"
+	"src/internal/rbac"
 )
 
 // Интерфейс для работы с пользователями
@@ -12,37 +19,144 @@ type UserStorage interface {
 	GetByEmail(email string) (*User, error)
 	Create(user *User) error
 	UpdatePassword(email, password string) error
+	GetRole(email string) (*UserRole, error)
+	// Suspend блокирует пользователя, Reinstate снимает блокировку.
+	// Возвращают false, если пользователь уже в нужном состоянии
+	Suspend(email, reason string) (bool, error)
+	Reinstate(email string) (bool, error)
+	// SetLocale сохраняет язык писем пользователя
+	SetLocale(email, locale string) error
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) UserStorage
 }
 
-// Интерфейс для работы с refresh токенами
+// Интерфейс для работы с refresh токенами. Токены передаются в виде хеша
 type RefreshTokenStorage interface {
 	Save(token *RefreshTokenData) error
-	GetByToken(token string) (*RefreshTokenData, error)
-	Delete(token string) error
-	DeleteAllByEmail(email string) error
+	GetByToken(tokenHash string) (*RefreshTokenData, error)
+	MarkRotated(tokenHash string) (bool, error)
+	DeleteFamily(familyID uuid.UUID) (AccessTokens, error)
+	DeleteAllByEmail(email string, except uuid.UUID) (AccessTokens, error)
+	GetSessions(email string) ([]Session, error)
+	DeleteSession(email string, familyID uuid.UUID) (bool, AccessTokens, error)
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) RefreshTokenStorage
+}
+
+// Интерфейс для работы со счётчиками неудачных попыток входа.
+// RegisterFailure атомарно увеличивает счётчик; счётчик, последняя неудача которого старше window,
+// или с истёкшей блокировкой начинается заново
+type LoginAttemptStorage interface {
+	Get(key string) (*LoginFailures, error)
+	RegisterFailure(key string, window time.Duration) (*LoginFailures, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// Интерфейс для работы с журналом событий безопасности
+type SecurityEventStorage interface {
+	Add(event *SecurityEvent) error
+	GetByEmail(email string, limit int) ([]SecurityEvent, error)
+}
+
+// Интерфейс для работы с двухфакторной аутентификацией
+type MFAStorage interface {
+	Get(email string) (*MFASettings, error)
+	// SaveSecret начинает подключение 2FA; не изменяет уже подключённую
+	SaveSecret(email, secret string) error
+	// Enable подключает 2FA и заменяет коды восстановления
+	Enable(email string, recoveryCodeHashes []string) error
+	Disable(email string) error
+	// UseStep отмечает использование кода шага step. Возвращает false, если код этого или более позднего шага уже использован
+	UseStep(email string, step int64) (bool, error)
+	UseRecoveryCode(email, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(email string, codeHashes []string) error
+	CountRecoveryCodes(email string) (int, error)
+	// IsRequired проверяет, обязательна ли 2FA: для администраторов по настройке admin_mfa_required,
+	// для сотрудников компании - по настройке компании
+	IsRequired(email string) (bool, error)
+	SetAdminRequired(required bool, updatedBy string) error
+	IsAdminRequired() (bool, error)
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) MFAStorage
 }
 
-// Интерфейс для работы с кодами подтверждения
+// Интерфейс для работы со вторым шагом входа
+type MFAChallengeStorage interface {
+	Save(challenge *MFAChallenge) error
+	Get(tokenHash string) (*MFAChallenge, error)
+	UseAttempt(tokenHash string) (*MFAChallenge, error)
+	Delete(tokenHash string) error
+}
+
+// Интерфейс для работы с начатыми входами через внешних провайдеров.
+// Use возвращает и удаляет state, поэтому он одноразовый
+type OIDCStateStorage interface {
+	Save(state *OIDCState) error
+	Use(stateHash string) (*OIDCState, error)
+}
+
+// Интерфейс для работы с привязками аккаунтов внешних провайдеров к пользователям.
+// Register создаёт клиента с привязкой; если адрес занят, возвращает ErrUserAlreadyExists
+type OIDCIdentityStorage interface {
+	GetEmail(provider, subject string) (string, error)
+	Link(identity *OIDCIdentity, email string) error
+	Register(identity *OIDCIdentity) error
+}
+
+// Интерфейс для работы с кодами подтверждения.
+// Save заменяет прежний код и сбрасывает счётчик попыток
 type VerificationStorage interface {
 	Save(data *RegistrationData) error
 	GetByEmail(email string) (*RegistrationData, error)
+	UseAttempt(email string) (*RegistrationData, error)
+	Delete(email string) error
+}
+
+// Интерфейс для смены адреса почты и удаления аккаунта. Операции затрагивают
+// несколько таблиц и выполняются в одной транзакции
+type AccountStorage interface {
+	// ChangeEmail переносит аккаунт и ссылки на него (компания, заказы, 2FA, журнал) на новый адрес.
+	// Сессии старого адреса удаляются. Если адрес занят, возвращает ErrUserAlreadyExists
+	ChangeEmail(email, newEmail string) error
+	// Delete удаляет аккаунт и персональные данные. Заказы переносятся на обезличенного
+	// пользователя anonymousEmail. Последнего владельца компании удалить нельзя (ErrLastCompanyOwner)
+	Delete(email, anonymousEmail string) error
+}
+
+// Интерфейс для работы с кодами смены адреса почты.
+// Save заменяет прежний запрос и сбрасывает счётчик попыток
+type EmailChangeStorage interface {
+	Save(data *EmailChangeData) error
+	UseAttempt(email string) (*EmailChangeData, error)
 	Delete(email string) error
 }
 
 // TSUserStorage интерфейс для работы с таблицей ts_users
 type TSUserStorage interface {
 	Create(email string) error
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) TSUserStorage
 }
 
 // PostgresTSUserStorage реализация для PostgreSQL
 type PostgresTSUserStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresTSUserStorage(db *sql.DB) *PostgresTSUserStorage {
 	return &PostgresTSUserStorage{db: db}
 }
 
+// WithTx возвращает TSUserStorage, работающий внутри транзакции tx
+func (s *PostgresTSUserStorage) WithTx(tx *sql.Tx) TSUserStorage {
+	return &PostgresTSUserStorage{db: tx}
+}
+
 func (s *PostgresTSUserStorage) Create(email string) error {
 	query := `INSERT INTO ts_users (email) VALUES ($1)`
 
@@ -53,20 +167,27 @@ func (s *PostgresTSUserStorage) Create(email string) error {
 
 // PostgresUserStorage реализация для PostgreSQL
 type PostgresUserStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresUserStorage(db *sql.DB) *PostgresUserStorage {
 	return &PostgresUserStorage{db: db}
 }
 
+// WithTx возвращает UserStorage, работающий внутри транзакции tx
+func (s *PostgresUserStorage) WithTx(tx *sql.Tx) UserStorage {
+	return &PostgresUserStorage{db: tx}
+}
+
 func (s *PostgresUserStorage) GetByEmail(email string) (*User, error) {
 	var user User
-	query := `SELECT login, password FROM all_users WHERE login = $1`
+	query := `SELECT login, password, suspended_at, COALESCE(suspension_reason, '') FROM all_users WHERE login = $1`
 
 	err := s.db.QueryRow(query, email).Scan(
 		&user.Login,
 		&user.Password,
+		&user.SuspendedAt,
+		&user.SuspensionReason,
 	)
 
 	if err != nil {
@@ -97,9 +218,362 @@ func (s *PostgresUserStorage) UpdatePassword(email, password string) error {
 	return err
 }
 
+// Блокировка пользователя администратором
+func (s *PostgresUserStorage) Suspend(email, reason string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE all_users SET suspended_at = NOW(), suspension_reason = $2
+                              WHERE login = $1 AND suspended_at IS NULL`, email, reason)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Снятие блокировки пользователя
+func (s *PostgresUserStorage) Reinstate(email string) (bool, error) {
+	result, err := s.db.Exec(`UPDATE all_users SET suspended_at = NULL, suspension_reason = NULL
+                              WHERE login = $1 AND suspended_at IS NOT NULL`, email)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+// Сохранение языка писем пользователя
+func (s *PostgresUserStorage) SetLocale(email, locale string) error {
+	_, err := s.db.Exec(`UPDATE all_users SET locale = $2 WHERE login = $1 AND locale IS DISTINCT FROM $2`, email, locale)
+	return err
+}
+
+// GetRole определяет роль пользователя: admin, если он есть в таблице admin,
+// partner, если он сотрудник компании, иначе client. Компания и роль в ней заполняются
+// для любого сотрудника компании, в том числе для администратора
+func (s *PostgresUserStorage) GetRole(email string) (*UserRole, error) {
+	var isAdmin bool
+	var inn, companyRole string
+	query := `
+		SELECT EXISTS(SELECT 1 FROM admin WHERE email = $1),
+		       COALESCE((SELECT inn FROM partners_users WHERE email = $1), ''),
+		       COALESCE((SELECT role FROM partners_users WHERE email = $1), '')
+	`
+
+	if err := s.db.QueryRow(query, email).Scan(&isAdmin, &inn, &companyRole); err != nil {
+		return nil, err
+	}
+
+	role := &UserRole{Role: rbac.RoleClient, INN: inn, CompanyRole: companyRole}
+	switch {
+	case isAdmin:
+		role.Role = rbac.RoleAdmin
+	case inn != "":
+		role.Role = rbac.RolePartner
+	}
+	return role, nil
+}
+
+// PostgresAccountStorage реализация AccountStorage для PostgreSQL.
+// cipher нужен, чтобы при смене адреса перешифровать секрет TOTP, привязанный к адресу
+type PostgresAccountStorage struct {
+	db     *sql.DB
+	cipher *SecretCipher
+}
+
+func NewPostgresAccountStorage(db *sql.DB, cipher *SecretCipher) *PostgresAccountStorage {
+	return &PostgresAccountStorage{db: db, cipher: cipher}
+}
+
+// Что происходит со столбцом, где хранится адрес пользователя, при смене адреса и удалении аккаунта
+const (
+	emailColumnMove      = "move"      // значение заменяется новым адресом
+	emailColumnDelete    = "delete"    // строки удаляются
+	emailColumnAnonymize = "anonymize" // значение заменяется обезличенным адресом
+	emailColumnClear     = "clear"     // значение обнуляется
+	emailColumnRevoke    = "revoke"    // отметка отзыва токенов: при удалении обновляется
+	emailColumnKeep      = "keep"      // история на момент события, не меняется
+)
+
+// Столбец с адресом пользователя
+type accountEmailColumn struct {
+	Table    string
+	Column   string
+	OnChange string
+	OnDelete string
+}
+
+// Все столбцы с адресом пользователя. Новый столбец с адресом нужно добавить сюда и обработать
+// в ChangeEmail и Delete: TestAccountEmailColumns сверяет список с миграциями и запросами
+var accountEmailColumns = []accountEmailColumn{
+	{"all_users", "login", emailColumnMove, emailColumnDelete},
+	{"ts_users", "email", emailColumnMove, emailColumnDelete},
+	{"orders", "users", emailColumnMove, emailColumnAnonymize},
+	{"partners_users", "email", emailColumnMove, emailColumnDelete},
+	{"admin", "email", emailColumnMove, emailColumnDelete},
+	{"part_req", "user_email", emailColumnMove, emailColumnDelete},
+	{"part_req", "assigned_to", emailColumnMove, emailColumnClear},
+	{"part_req_comments", "author", emailColumnMove, emailColumnAnonymize},
+	{"company_invitations", "email", emailColumnMove, emailColumnDelete},
+	{"company_invitations", "invited_by", emailColumnMove, emailColumnAnonymize},
+	{"company_api_keys", "created_by", emailColumnMove, emailColumnAnonymize},
+	{"role_revocations", "email", emailColumnMove, emailColumnRevoke},
+	{"refresh_tokens", "email", emailColumnDelete, emailColumnDelete},
+	// Коды, отправленные на старый адрес, после смены адреса не действуют
+	{"verification_codes", "email", emailColumnDelete, emailColumnDelete},
+	{"password_reset_codes", "email", emailColumnDelete, emailColumnDelete},
+	{"email_changes", "email", emailColumnDelete, emailColumnDelete},
+	// Чужая незавершённая смена адреса на этот адрес не подтвердится: адрес занят
+	{"email_changes", "new_email", emailColumnKeep, emailColumnKeep},
+	{"login_failures", "key", emailColumnMove, emailColumnDelete},
+	{"security_events", "email", emailColumnMove, emailColumnDelete},
+	{"security_settings", "updated_by", emailColumnMove, emailColumnClear},
+	{"user_mfa", "email", emailColumnMove, emailColumnDelete},
+	// Переносятся каскадом от user_mfa, кроме привязанных к адресу старых кодов
+	{"mfa_recovery_codes", "email", emailColumnMove, emailColumnDelete},
+	{"mfa_challenges", "email", emailColumnDelete, emailColumnDelete},
+	{"oidc_identities", "email", emailColumnMove, emailColumnDelete},
+	// Адрес у провайдера, а не у нас
+	{"oidc_identities", "provider_email", emailColumnKeep, emailColumnKeep},
+	// Журнал администраторов только дополняется (триггер audit_log_append_only)
+	{"audit_log", "actor", emailColumnKeep, emailColumnKeep},
+	// Письма уходят на адрес, действовавший при постановке в очередь. При удалении аккаунта
+	// письма пользователю удаляются, а его адрес в приглашениях других пользователей обезличивается
+	{"outbox_messages", "recipient", emailColumnKeep, emailColumnDelete},
+}
+
+// ChangeEmail копирует записи all_users и ts_users под новым адресом, переносит ссылки
+// и удаляет старые записи, поэтому внешние ключи без ON UPDATE CASCADE не мешают переносу.
+// Обработка каждого столбца с адресом описана в accountEmailColumns
+func (s *PostgresAccountStorage) ChangeEmail(email, newEmail string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO all_users (login, password, suspended_at, suspension_reason, locale)
+                         SELECT $2, password, suspended_at, suspension_reason, locale FROM all_users WHERE login = $1
+                         ON CONFLICT (login) DO NOTHING`, email, newEmail)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New(ErrUserAlreadyExists)
+	}
+
+	queries := []string{
+		`INSERT INTO ts_users (email, city) SELECT $2, city FROM ts_users WHERE email = $1`,
+		`UPDATE orders SET users = $2 WHERE users = $1`,
+		`DELETE FROM ts_users WHERE email = $1`,
+		`UPDATE partners_users SET email = $2 WHERE email = $1`,
+		`UPDATE admin SET email = $2 WHERE email = $1`,
+		`UPDATE part_req SET user_email = $2 WHERE user_email = $1`,
+		`UPDATE part_req SET assigned_to = $2 WHERE assigned_to = $1`,
+		`UPDATE part_req_comments SET author = $2 WHERE author = $1`,
+		// Ожидающее приглашение на старый адрес заменяется ожидающим приглашением той же компании на новый
+		`DELETE FROM company_invitations i
+         WHERE i.email = $1 AND i.status = 'pending' AND EXISTS(
+             SELECT 1 FROM company_invitations n
+             WHERE n.email = $2 AND n.company_inn = i.company_inn AND n.status = 'pending')`,
+		`UPDATE company_invitations SET email = $2 WHERE email = $1`,
+		`UPDATE company_invitations SET invited_by = $2 WHERE invited_by = $1`,
+		`UPDATE company_api_keys SET created_by = $2 WHERE created_by = $1`,
+		// Отметка отзыва копируется, старая остаётся до истечения выданных на старый адрес токенов
+		`INSERT INTO role_revocations (email, revoked_at)
+         SELECT $2, revoked_at FROM role_revocations WHERE email = $1
+         ON CONFLICT (email) DO UPDATE SET revoked_at = GREATEST(role_revocations.revoked_at, EXCLUDED.revoked_at)`,
+		// Счётчик неудачных входов переносится, чтобы смена адреса не снимала блокировку
+		`INSERT INTO login_failures (key, failures, last_failure_at, locked_until)
+         SELECT 'account:' || lower($2), failures, last_failure_at, locked_until
+         FROM login_failures WHERE key = 'account:' || lower($1)
+         ON CONFLICT (key) DO UPDATE
+         SET failures        = GREATEST(login_failures.failures, EXCLUDED.failures),
+             last_failure_at = GREATEST(login_failures.last_failure_at, EXCLUDED.last_failure_at),
+             locked_until    = GREATEST(login_failures.locked_until, EXCLUDED.locked_until)`,
+		`DELETE FROM login_failures WHERE key = 'account:' || lower($1)`,
+		`UPDATE security_events SET email = $2 WHERE email = $1`,
+		`UPDATE security_settings SET updated_by = $2 WHERE updated_by = $1`,
+		// Коды восстановления, хешированные вместе со старым адресом, с новым не совпадут.
+		// Остальные переносятся вместе с user_mfa (ON UPDATE CASCADE)
+		`DELETE FROM mfa_recovery_codes WHERE email = $1 AND email_bound`,
+		`UPDATE user_mfa SET email = $2 WHERE email = $1`,
+		`UPDATE oidc_identities SET email = $2 WHERE email = $1`,
+		`DELETE FROM refresh_tokens WHERE email = $1`,
+		`DELETE FROM mfa_challenges WHERE email = $1`,
+		`DELETE FROM verification_codes WHERE email = $1`,
+		`DELETE FROM password_reset_codes WHERE email = $1`,
+		`DELETE FROM email_changes WHERE email = $1`,
+		`DELETE FROM all_users WHERE login = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, email, newEmail); err != nil {
+			return err
+		}
+	}
+
+	if err := resealMFASecret(tx, s.cipher, email, newEmail); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Секрет TOTP зашифрован с адресом владельца (SecretCipher), поэтому после переноса user_mfa
+// на новый адрес он шифруется заново. Секрет в открытом виде тоже шифруется
+func resealMFASecret(q db.Querier, cipher *SecretCipher, email, newEmail string) error {
+	var stored string
+	err := q.QueryRow(`SELECT secret FROM user_mfa WHERE email = $1`, newEmail).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	secret, _, err := cipher.Open(stored, email)
+	if err != nil {
+		return err
+	}
+	sealed, err := cipher.Seal(secret, newEmail)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt mfa secret: %w", err)
+	}
+	_, err = q.Exec(`UPDATE user_mfa SET secret = $2 WHERE email = $1`, newEmail, sealed)
+	return err
+}
+
+func (s *PostgresAccountStorage) Delete(email, anonymousEmail string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка владельцев компании, чтобы два владельца не удалили аккаунты одновременно
+	var lastOwner bool
+	query := `WITH owners AS (
+                  SELECT o.inn, o.email FROM partners_users o
+                  WHERE o.role = 'owner' AND o.inn IN (SELECT inn FROM partners_users WHERE email = $1)
+                  FOR UPDATE
+              )
+              SELECT EXISTS(SELECT 1 FROM owners WHERE email = $1)
+                 AND NOT EXISTS(SELECT 1 FROM owners WHERE email <> $1)`
+	if err := tx.QueryRow(query, email).Scan(&lastOwner); err != nil {
+		return err
+	}
+	if lastOwner {
+		return errors.New(ErrLastCompanyOwner)
+	}
+
+	// Заказы остаются в истории компаний, но без связи с пользователем.
+	// Обезличенный пользователь не может войти: пустой хеш пароля не проходит проверку
+	queries := []string{
+		`INSERT INTO all_users (login, password)
+         SELECT $2, '' WHERE EXISTS(SELECT 1 FROM orders WHERE users = $1)`,
+		`INSERT INTO ts_users (email)
+         SELECT $2 WHERE EXISTS(SELECT 1 FROM orders WHERE users = $1)`,
+		`UPDATE orders SET users = $2 WHERE users = $1`,
+		`UPDATE company_api_keys SET created_by = $2 WHERE created_by = $1`,
+		`UPDATE company_invitations SET invited_by = $2 WHERE invited_by = $1`,
+		// Комментарии к чужим заявкам остаются в переписке, комментарии к своим удаляются вместе с заявками
+		`UPDATE part_req_comments SET author = $2 WHERE author = $1`,
+		`UPDATE outbox_messages SET payload = jsonb_set(payload, '{invited_by}', to_jsonb($2::text))
+         WHERE kind = 'company_invitation' AND payload->>'invited_by' = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, email, anonymousEmail); err != nil {
+			return err
+		}
+	}
+
+	queries = []string{
+		// Заявки, которые рассматривал пользователь, возвращаются в общую очередь
+		`UPDATE part_req SET assigned_to = NULL, assigned_at = NULL WHERE assigned_to = $1`,
+		`UPDATE security_settings SET updated_by = NULL WHERE updated_by = $1`,
+		// Выданные токены отклоняются сразу после удаления на всех экземплярах
+		`INSERT INTO role_revocations (email, revoked_at) VALUES ($1, NOW())
+         ON CONFLICT (email) DO UPDATE SET revoked_at = GREATEST(role_revocations.revoked_at, EXCLUDED.revoked_at)`,
+		`DELETE FROM partners_users WHERE email = $1`,
+		`DELETE FROM admin WHERE email = $1`,
+		`DELETE FROM part_req WHERE user_email = $1`,
+		`DELETE FROM company_invitations WHERE email = $1`,
+		`DELETE FROM mfa_recovery_codes WHERE email = $1`,
+		`DELETE FROM user_mfa WHERE email = $1`,
+		`DELETE FROM mfa_challenges WHERE email = $1`,
+		`DELETE FROM security_events WHERE email = $1`,
+		`DELETE FROM oidc_identities WHERE email = $1`,
+		`DELETE FROM login_failures WHERE key = 'account:' || lower($1)`,
+		`DELETE FROM refresh_tokens WHERE email = $1`,
+		`DELETE FROM verification_codes WHERE email = $1`,
+		`DELETE FROM password_reset_codes WHERE email = $1`,
+		`DELETE FROM email_changes WHERE email = $1`,
+		`DELETE FROM outbox_messages WHERE channel = 'email' AND recipient = $1`,
+		`DELETE FROM ts_users WHERE email = $1`,
+		`DELETE FROM all_users WHERE login = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, email); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PostgresEmailChangeStorage хранит коды смены адреса почты в PostgreSQL
+type PostgresEmailChangeStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresEmailChangeStorage(db *sql.DB) *PostgresEmailChangeStorage {
+	return &PostgresEmailChangeStorage{db: db}
+}
+
+func (s *PostgresEmailChangeStorage) Save(data *EmailChangeData) error {
+	query := `WITH expired AS (
+                  DELETE FROM email_changes WHERE expires_at <= NOW() AND email <> $1
+              )
+              INSERT INTO email_changes (email, new_email, code_hash, attempts, expires_at)
+              VALUES ($1, $2, $3, 0, $4)
+              ON CONFLICT (email) DO UPDATE
+              SET new_email  = EXCLUDED.new_email,
+                  code_hash  = EXCLUDED.code_hash,
+                  attempts   = 0,
+                  expires_at = EXCLUDED.expires_at,
+                  created_at = NOW()`
+
+	_, err := s.db.Exec(query, data.Email, data.NewEmail, data.CodeHash, data.ExpiresAt)
+	return err
+}
+
+func (s *PostgresEmailChangeStorage) UseAttempt(email string) (*EmailChangeData, error) {
+	query := `UPDATE email_changes
+              SET attempts = attempts + 1
+              WHERE email = $1 AND expires_at > NOW()
+              RETURNING email, new_email, code_hash, attempts, expires_at`
+
+	var data EmailChangeData
+	err := s.db.QueryRow(query, email).Scan(&data.Email, &data.NewEmail, &data.CodeHash, &data.Attempts, &data.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (s *PostgresEmailChangeStorage) Delete(email string) error {
+	_, err := s.db.Exec(`DELETE FROM email_changes WHERE email = $1`, email)
+	return err
+}
+
 // PostgresRefreshTokenStorage реализация для refresh токенов
 type PostgresRefreshTokenStorage struct {
-	db *sql.DB
+	db db.Querier
 }
 
 func NewPostgresRefreshTokenStorage(db *sql.DB) *PostgresRefreshTokenStorage {
@@ -108,28 +582,45 @@ func NewPostgresRefreshTokenStorage(db *sql.DB) *PostgresRefreshTokenStorage {
 	return storage
 }
 
+// WithTx возвращает RefreshTokenStorage, работающий внутри транзакции tx
+func (s *PostgresRefreshTokenStorage) WithTx(tx *sql.Tx) RefreshTokenStorage {
+	return &PostgresRefreshTokenStorage{db: tx}
+}
+
 func (s *PostgresRefreshTokenStorage) Save(token *RefreshTokenData) error {
-	query := `INSERT INTO refresh_tokens (token, email, expires_at, last_used) 
-              VALUES ($1, $2, $3, $4)`
+	query := `INSERT INTO refresh_tokens (token_hash, family_id, email, expires_at, last_used, device_info, user_agent, ip, session_started_at, access_jti, access_expires_at) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
 	_, err := s.db.Exec(query,
-		token.Token,
+		token.TokenHash,
+		token.FamilyID,
 		token.Email,
 		token.ExpiresAt,
 		time.Now(),
+		token.DeviceInfo,
+		token.UserAgent,
+		token.IP,
+		token.SessionStartedAt,
+		token.AccessJTI,
+		token.AccessExpiresAt,
 	)
 
 	return err
 }
 
-func (s *PostgresRefreshTokenStorage) GetByToken(token string) (*RefreshTokenData, error) {
+// GetByToken возвращает токен по хешу, в том числе уже прошедший ротацию.
+// Если токен не найден, возвращает nil
+func (s *PostgresRefreshTokenStorage) GetByToken(tokenHash string) (*RefreshTokenData, error) {
 	var data RefreshTokenData
-	query := `UPDATE refresh_tokens 
-              SET last_used = CURRENT_TIMESTAMP 
-              WHERE token = $1 
-              RETURNING token, email, expires_at, last_used`
-
-	err := s.db.QueryRow(query, token).Scan(&data.Token, &data.Email, &data.ExpiresAt, &data.LastUsed)
+	query := `SELECT token_hash, family_id, email, expires_at, last_used, rotated_at,
+                     COALESCE(device_info, ''), COALESCE(user_agent, ''), COALESCE(ip, ''), session_started_at
+              FROM refresh_tokens
+              WHERE token_hash = $1`
+
+	err := s.db.QueryRow(query, tokenHash).Scan(
+		&data.TokenHash, &data.FamilyID, &data.Email, &data.ExpiresAt, &data.LastUsed, &data.RotatedAt,
+		&data.DeviceInfo, &data.UserAgent, &data.IP, &data.SessionStartedAt,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -140,16 +631,112 @@ func (s *PostgresRefreshTokenStorage) GetByToken(token string) (*RefreshTokenDat
 	return &data, nil
 }
 
-func (s *PostgresRefreshTokenStorage) Delete(token string) error {
-	query := `DELETE FROM refresh_tokens WHERE token = $1`
-	_, err := s.db.Exec(query, token)
-	return err
+// MarkRotated отмечает токен использованным. Возвращает false, если токен уже прошёл ротацию
+// (в том числе параллельным запросом) - это признак повторного использования
+func (s *PostgresRefreshTokenStorage) MarkRotated(tokenHash string) (bool, error) {
+	query := `UPDATE refresh_tokens
+              SET rotated_at = CURRENT_TIMESTAMP, last_used = CURRENT_TIMESTAMP
+              WHERE token_hash = $1 AND rotated_at IS NULL`
+
+	res, err := s.db.Exec(query, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
-func (s *PostgresRefreshTokenStorage) DeleteAllByEmail(email string) error {
-	query := `DELETE FROM refresh_tokens WHERE email = $1`
-	_, err := s.db.Exec(query, email)
-	return err
+// DeleteFamily удаляет все токены, полученные ротацией после одного входа,
+// и возвращает ещё действующие access токены, выданные вместе с ними
+func (s *PostgresRefreshTokenStorage) DeleteFamily(familyID uuid.UUID) (AccessTokens, error) {
+	query := `DELETE FROM refresh_tokens WHERE family_id = $1
+              RETURNING access_jti, access_expires_at`
+
+	_, tokens, err := s.deleteReturning(query, familyID)
+	return tokens, err
+}
+
+// DeleteAllByEmail удаляет все токены пользователя, кроме семейства except.
+// uuid.Nil в except означает удаление всех сессий
+func (s *PostgresRefreshTokenStorage) DeleteAllByEmail(email string, except uuid.UUID) (AccessTokens, error) {
+	query := `DELETE FROM refresh_tokens WHERE email = $1 AND family_id <> $2
+              RETURNING access_jti, access_expires_at`
+
+	_, tokens, err := s.deleteReturning(query, email, except)
+	return tokens, err
+}
+
+// Удаление refresh токенов с возвратом количества удалённых строк и действующих access токенов.
+// У токенов, выданных до появления access_jti, access токен неизвестен
+func (s *PostgresRefreshTokenStorage) deleteReturning(query string, args ...any) (int, AccessTokens, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	deleted := 0
+	tokens := AccessTokens{}
+	now := time.Now()
+	for rows.Next() {
+		var jti sql.NullString
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return 0, nil, err
+		}
+		deleted++
+		if jti.Valid && expiresAt.Valid && expiresAt.Time.After(now) {
+			tokens[jti.String] = expiresAt.Time
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return deleted, tokens, nil
+}
+
+// GetSessions возвращает активные сессии пользователя: по одному действующему
+// (ещё не прошедшему ротацию) токену на семейство
+func (s *PostgresRefreshTokenStorage) GetSessions(email string) ([]Session, error) {
+	query := `SELECT family_id, COALESCE(device_info, ''), COALESCE(user_agent, ''), COALESCE(ip, ''),
+                     session_started_at, last_used, expires_at
+              FROM refresh_tokens
+              WHERE email = $1 AND rotated_at IS NULL AND expires_at > NOW()
+              ORDER BY last_used DESC`
+
+	rows, err := s.db.Query(query, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(
+			&session.ID, &session.DeviceName, &session.UserAgent, &session.IP,
+			&session.CreatedAt, &session.LastUsed, &session.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession удаляет семейство токенов пользователя. Возвращает false, если сессия не найдена
+func (s *PostgresRefreshTokenStorage) DeleteSession(email string, familyID uuid.UUID) (bool, AccessTokens, error) {
+	query := `DELETE FROM refresh_tokens WHERE email = $1 AND family_id = $2
+              RETURNING access_jti, access_expires_at`
+
+	deleted, tokens, err := s.deleteReturning(query, email, familyID)
+	if err != nil {
+		return false, nil, err
+	}
+	return deleted > 0, tokens, nil
 }
 
 // Запуск очистки просроченных токенов каждый час
@@ -170,53 +757,549 @@ func (s *PostgresRefreshTokenStorage) CleanExpired() error {
 	return err
 }
 
-// Хранение кодов верификации в памяти
-type MemoryVerificationStorage struct {
-	mu       sync.RWMutex
-	codes    map[string]*RegistrationData
-	attempts map[string][]time.Time
+// PostgresVerificationStorage хранит коды подтверждения регистрации в PostgreSQL.
+// Просроченные коды не возвращаются запросами и удаляются при сохранении новых,
+// запись того же email перезаписывается через ON CONFLICT
+type PostgresVerificationStorage struct {
+	db *sql.DB
 }
 
-func NewMemoryVerificationStorage() *MemoryVerificationStorage {
-	storage := &MemoryVerificationStorage{
-		codes:    make(map[string]*RegistrationData),
-		attempts: make(map[string][]time.Time),
+func NewPostgresVerificationStorage(db *sql.DB) *PostgresVerificationStorage {
+	return &PostgresVerificationStorage{db: db}
+}
+
+func (s *PostgresVerificationStorage) Save(data *RegistrationData) error {
+	query := `WITH expired AS (
+                  DELETE FROM verification_codes WHERE expires_at <= NOW() AND email <> $1
+              )
+              INSERT INTO verification_codes (email, password_hash, code_hash, attempts, expires_at)
+              VALUES ($1, $2, $3, 0, $4)
+              ON CONFLICT (email) DO UPDATE
+              SET password_hash = EXCLUDED.password_hash,
+                  code_hash     = EXCLUDED.code_hash,
+                  attempts      = 0,
+                  expires_at    = EXCLUDED.expires_at,
+                  created_at    = NOW()`
+
+	_, err := s.db.Exec(query, data.Email, data.Password, data.CodeHash, data.ExpiresAt)
+	return err
+}
+
+func (s *PostgresVerificationStorage) GetByEmail(email string) (*RegistrationData, error) {
+	query := `SELECT email, password_hash, code_hash, attempts, expires_at
+              FROM verification_codes
+              WHERE email = $1 AND expires_at > NOW()`
+
+	return scanRegistrationData(s.db.QueryRow(query, email))
+}
+
+// UseAttempt атомарно увеличивает счётчик попыток, поэтому параллельные запросы
+// не позволяют проверить больше кодов, чем разрешено
+func (s *PostgresVerificationStorage) UseAttempt(email string) (*RegistrationData, error) {
+	query := `UPDATE verification_codes
+              SET attempts = attempts + 1
+              WHERE email = $1 AND expires_at > NOW()
+              RETURNING email, password_hash, code_hash, attempts, expires_at`
+
+	return scanRegistrationData(s.db.QueryRow(query, email))
+}
+
+func (s *PostgresVerificationStorage) Delete(email string) error {
+	_, err := s.db.Exec(`DELETE FROM verification_codes WHERE email = $1`, email)
+	return err
+}
+
+func scanRegistrationData(row *sql.Row) (*RegistrationData, error) {
+	var data RegistrationData
+	err := row.Scan(&data.Email, &data.Password, &data.CodeHash, &data.Attempts, &data.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
 	}
-	// Запуск очистки просроченных кодов
+	return &data, nil
+}
+
+// PostgresLoginAttemptStorage реализация счётчиков неудачных попыток входа для PostgreSQL
+type PostgresLoginAttemptStorage struct {
+	db        *sql.DB
+	retention time.Duration
+}
+
+// retention - время, после которого счётчик без действующей блокировки удаляется
+func NewPostgresLoginAttemptStorage(db *sql.DB, retention time.Duration) *PostgresLoginAttemptStorage {
+	storage := &PostgresLoginAttemptStorage{db: db, retention: retention}
 	go storage.cleanupLoop()
 	return storage
 }
 
-// Проверка на возможность зарегистрироваться
-func (s *MemoryVerificationStorage) CanRegister(email string) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *PostgresLoginAttemptStorage) Get(key string) (*LoginFailures, error) {
+	query := `SELECT key, failures, last_failure_at, locked_until FROM login_failures WHERE key = $1`
+	return scanLoginFailures(s.db.QueryRow(query, key))
+}
 
-	now := time.Now()
+func (s *PostgresLoginAttemptStorage) RegisterFailure(key string, window time.Duration) (*LoginFailures, error) {
+	query := `INSERT INTO login_failures (key, failures, last_failure_at)
+              VALUES ($1, 1, NOW())
+              ON CONFLICT (key) DO UPDATE
+              SET failures = CASE
+                      WHEN login_failures.last_failure_at < NOW() - make_interval(secs => $2)
+                        OR login_failures.locked_until <= NOW()
+                      THEN 1
+                      ELSE login_failures.failures + 1
+                  END,
+                  locked_until = CASE
+                      WHEN login_failures.locked_until <= NOW() THEN NULL
+                      ELSE login_failures.locked_until
+                  END,
+                  last_failure_at = NOW()
+              RETURNING key, failures, last_failure_at, locked_until`
+
+	return scanLoginFailures(s.db.QueryRow(query, key, window.Seconds()))
+}
 
-	// Получение список попыток для этого email
-	attempts, exists := s.attempts[email]
-	if !exists {
-		s.attempts[email] = []time.Time{now}
-		return true
+func (s *PostgresLoginAttemptStorage) Lock(key string, until time.Time) error {
+	_, err := s.db.Exec(`UPDATE login_failures SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+func (s *PostgresLoginAttemptStorage) Reset(key string) error {
+	_, err := s.db.Exec(`DELETE FROM login_failures WHERE key = $1`, key)
+	return err
+}
+
+// Запуск очистки устаревших счётчиков каждый час
+func (s *PostgresLoginAttemptStorage) cleanupLoop() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, _ = s.db.Exec(`DELETE FROM login_failures
+                          WHERE last_failure_at < NOW() - make_interval(secs => $1)
+                            AND (locked_until IS NULL OR locked_until < NOW())`, s.retention.Seconds())
+	}
+}
+
+func scanLoginFailures(row *sql.Row) (*LoginFailures, error) {
+	var data LoginFailures
+	err := row.Scan(&data.Key, &data.Failures, &data.LastFailureAt, &data.LockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Срок хранения событий безопасности
+const securityEventRetention = 90 * 24 * time.Hour
+
+// PostgresSecurityEventStorage реализация журнала событий безопасности для PostgreSQL
+type PostgresSecurityEventStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresSecurityEventStorage(db *sql.DB) *PostgresSecurityEventStorage {
+	storage := &PostgresSecurityEventStorage{db: db}
+	go storage.cleanupLoop()
+	return storage
+}
+
+func (s *PostgresSecurityEventStorage) Add(event *SecurityEvent) error {
+	query := `INSERT INTO security_events (email, type, ip, user_agent, details)
+              VALUES ($1, $2, $3, $4, $5)
+              RETURNING id, created_at`
+
+	return s.db.QueryRow(query, event.Email, event.Type, event.IP, event.UserAgent, event.Details).
+		Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetByEmail возвращает последние события пользователя, начиная с новых
+func (s *PostgresSecurityEventStorage) GetByEmail(email string, limit int) ([]SecurityEvent, error) {
+	query := `SELECT id, email, type, COALESCE(ip, ''), COALESCE(user_agent, ''), COALESCE(details, ''), created_at
+              FROM security_events
+              WHERE email = $1
+              ORDER BY created_at DESC
+              LIMIT $2`
+
+	rows, err := s.db.Query(query, email, limit)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Удаление попыток старше 1 минуты
-	var recent []time.Time
-	for _, t := range attempts {
-		if now.Sub(t) < time.Minute {
-			recent = append(recent, t)
+	events := []SecurityEvent{}
+	for rows.Next() {
+		var event SecurityEvent
+		if err := rows.Scan(&event.ID, &event.Email, &event.Type, &event.IP, &event.UserAgent, &event.Details, &event.CreatedAt); err != nil {
+			return nil, err
 		}
+		events = append(events, event)
 	}
+	return events, rows.Err()
+}
+
+// Запуск удаления старых событий раз в сутки
+func (s *PostgresSecurityEventStorage) cleanupLoop() {
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
 
-	if len(recent) >= 3 { // максимум 3 попытки в минуту
-		return false
+	for range ticker.C {
+		_, _ = s.db.Exec(`DELETE FROM security_events WHERE created_at < $1`, time.Now().Add(-securityEventRetention))
 	}
+}
+
+// Ключ настройки обязательной 2FA для администраторов
+const settingAdminMFARequired = "admin_mfa_required"
+
+// PostgresMFAStorage реализация хранилища 2FA для PostgreSQL.
+// Секрет TOTP хранится зашифрованным ключом сервера (MFA_SECRET_KEY) и привязан к адресу пользователя
+type PostgresMFAStorage struct {
+	db     db.Querier
+	cipher *SecretCipher
+}
+
+func NewPostgresMFAStorage(db *sql.DB, cipher *SecretCipher) *PostgresMFAStorage {
+	return &PostgresMFAStorage{db: db, cipher: cipher}
+}
 
-	// Добавление текущей попытки
-	recent = append(recent, now)
-	s.attempts[email] = recent
-	return true
+// WithTx возвращает PostgresMFAStorage, работающий внутри транзакции tx
+func (s *PostgresMFAStorage) WithTx(tx *sql.Tx) MFAStorage {
+	return &PostgresMFAStorage{db: tx, cipher: s.cipher}
+}
+
+func (s *PostgresMFAStorage) Get(email string) (*MFASettings, error) {
+	var settings MFASettings
+	var stored string
+	query := `SELECT email, secret, enabled, last_used_step FROM user_mfa WHERE email = $1`
+
+	err := s.db.QueryRow(query, email).Scan(&settings.Email, &stored, &settings.Enabled, &settings.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	secret, plaintext, err := s.cipher.Open(stored, settings.Email)
+	if err != nil {
+		return nil, err
+	}
+	settings.Secret = secret
+
+	// Секреты, записанные до появления шифрования, шифруются при первом чтении
+	if plaintext {
+		if err := s.sealStoredSecret(settings.Email, stored); err != nil {
+			return nil, fmt.Errorf("failed to encrypt mfa secret: %w", err)
+		}
+	}
+	return &settings, nil
+}
+
+func (s *PostgresMFAStorage) sealStoredSecret(email, secret string) error {
+	sealed, err := s.cipher.Seal(secret, email)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE user_mfa SET secret = $3 WHERE email = $1 AND secret = $2`, email, secret, sealed)
+	return err
+}
+
+func (s *PostgresMFAStorage) SaveSecret(email, secret string) error {
+	sealed, err := s.cipher.Seal(secret, email)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt mfa secret: %w", err)
+	}
+
+	query := `INSERT INTO user_mfa (email, secret) VALUES ($1, $2)
+              ON CONFLICT (email) DO UPDATE
+              SET secret = EXCLUDED.secret, last_used_step = NULL, created_at = NOW()
+              WHERE NOT user_mfa.enabled`
+
+	_, err = s.db.Exec(query, email, sealed)
+	return err
+}
+
+// Enable подключает 2FA и записывает коды восстановления одним запросом
+func (s *PostgresMFAStorage) Enable(email string, recoveryCodeHashes []string) error {
+	query := `WITH enabled AS (
+                  UPDATE user_mfa SET enabled = TRUE, enabled_at = NOW()
+                  WHERE email = $1
+                  RETURNING email
+              ), removed AS (
+                  DELETE FROM mfa_recovery_codes WHERE email IN (SELECT email FROM enabled)
+              )
+              INSERT INTO mfa_recovery_codes (email, code_hash, email_bound)
+              SELECT enabled.email, code_hash, FALSE FROM enabled, unnest($2::text[]) AS code_hash`
+
+	_, err := s.db.Exec(query, email, recoveryCodeHashes)
+	return err
+}
+
+func (s *PostgresMFAStorage) Disable(email string) error {
+	_, err := s.db.Exec(`DELETE FROM user_mfa WHERE email = $1`, email)
+	return err
+}
+
+func (s *PostgresMFAStorage) UseStep(email string, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $2
+              WHERE email = $1 AND (last_used_step IS NULL OR last_used_step < $2)`
+
+	res, err := s.db.Exec(query, email, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *PostgresMFAStorage) UseRecoveryCode(email, codeHash string) (bool, error) {
+	query := `UPDATE mfa_recovery_codes SET used_at = NOW()
+              WHERE email = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	res, err := s.db.Exec(query, email, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (s *PostgresMFAStorage) ReplaceRecoveryCodes(email string, codeHashes []string) error {
+	query := `WITH removed AS (
+                  DELETE FROM mfa_recovery_codes WHERE email = $1
+              )
+              INSERT INTO mfa_recovery_codes (email, code_hash, email_bound)
+              SELECT $1, unnest($2::text[]), FALSE`
+
+	_, err := s.db.Exec(query, email, codeHashes)
+	return err
+}
+
+func (s *PostgresMFAStorage) CountRecoveryCodes(email string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE email = $1 AND used_at IS NULL`
+	err := s.db.QueryRow(query, email).Scan(&count)
+	return count, err
+}
+
+func (s *PostgresMFAStorage) IsRequired(email string) (bool, error) {
+	var required bool
+	query := `
+		SELECT (EXISTS(SELECT 1 FROM admin WHERE email = $1)
+		        AND COALESCE((SELECT value FROM security_settings WHERE key = $2), 'false') = 'true')
+		    OR EXISTS(SELECT 1 FROM partners_users pu
+		              JOIN companies c ON c.inn = pu.inn
+		              WHERE pu.email = $1 AND c.mfa_required)
+	`
+
+	err := s.db.QueryRow(query, email, settingAdminMFARequired).Scan(&required)
+	return required, err
+}
+
+func (s *PostgresMFAStorage) SetAdminRequired(required bool, updatedBy string) error {
+	query := `INSERT INTO security_settings (key, value, updated_by) VALUES ($1, $2, $3)
+              ON CONFLICT (key) DO UPDATE
+              SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()`
+
+	_, err := s.db.Exec(query, settingAdminMFARequired, strconv.FormatBool(required), updatedBy)
+	return err
+}
+
+func (s *PostgresMFAStorage) IsAdminRequired() (bool, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM security_settings WHERE key = $1`, settingAdminMFARequired).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// PostgresMFAChallengeStorage реализация хранилища второго шага входа для PostgreSQL
+type PostgresMFAChallengeStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresMFAChallengeStorage(db *sql.DB) *PostgresMFAChallengeStorage {
+	return &PostgresMFAChallengeStorage{db: db}
+}
+
+// Save сохраняет шаг входа и удаляет просроченные
+func (s *PostgresMFAChallengeStorage) Save(challenge *MFAChallenge) error {
+	query := `WITH expired AS (
+                  DELETE FROM mfa_challenges WHERE expires_at <= NOW()
+              )
+              INSERT INTO mfa_challenges (token_hash, email, setup, device_name, expires_at)
+              VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.Exec(query, challenge.TokenHash, challenge.Email, challenge.Setup, challenge.DeviceName, challenge.ExpiresAt)
+	return err
+}
+
+func (s *PostgresMFAChallengeStorage) Get(tokenHash string) (*MFAChallenge, error) {
+	query := `SELECT token_hash, email, setup, COALESCE(device_name, ''), attempts, expires_at
+              FROM mfa_challenges
+              WHERE token_hash = $1 AND expires_at > NOW()`
+
+	return scanMFAChallenge(s.db.QueryRow(query, tokenHash))
+}
+
+// UseAttempt атомарно увеличивает счётчик попыток ввода кода
+func (s *PostgresMFAChallengeStorage) UseAttempt(tokenHash string) (*MFAChallenge, error) {
+	query := `UPDATE mfa_challenges SET attempts = attempts + 1
+              WHERE token_hash = $1 AND expires_at > NOW()
+              RETURNING token_hash, email, setup, COALESCE(device_name, ''), attempts, expires_at`
+
+	return scanMFAChallenge(s.db.QueryRow(query, tokenHash))
+}
+
+func (s *PostgresMFAChallengeStorage) Delete(tokenHash string) error {
+	_, err := s.db.Exec(`DELETE FROM mfa_challenges WHERE token_hash = $1`, tokenHash)
+	return err
+}
+
+func scanMFAChallenge(row *sql.Row) (*MFAChallenge, error) {
+	var challenge MFAChallenge
+	err := row.Scan(&challenge.TokenHash, &challenge.Email, &challenge.Setup, &challenge.DeviceName, &challenge.Attempts, &challenge.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// PostgresOIDCStateStorage реализация OIDCStateStorage для PostgreSQL
+type PostgresOIDCStateStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresOIDCStateStorage(db *sql.DB) *PostgresOIDCStateStorage {
+	return &PostgresOIDCStateStorage{db: db}
+}
+
+// Save сохраняет начатый вход и удаляет просроченные
+func (s *PostgresOIDCStateStorage) Save(state *OIDCState) error {
+	query := `WITH expired AS (
+                  DELETE FROM oidc_states WHERE expires_at <= NOW()
+              )
+              INSERT INTO oidc_states (state_hash, provider, code_verifier, device_name, expires_at)
+              VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := s.db.Exec(query, state.StateHash, state.Provider, state.CodeVerifier, state.DeviceName, state.ExpiresAt)
+	return err
+}
+
+func (s *PostgresOIDCStateStorage) Use(stateHash string) (*OIDCState, error) {
+	query := `DELETE FROM oidc_states
+              WHERE state_hash = $1
+              RETURNING state_hash, provider, code_verifier, COALESCE(device_name, ''), expires_at`
+
+	var state OIDCState
+	err := s.db.QueryRow(query, stateHash).Scan(&state.StateHash, &state.Provider, &state.CodeVerifier, &state.DeviceName, &state.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if time.Now().After(state.ExpiresAt) {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// PostgresOIDCIdentityStorage реализация OIDCIdentityStorage для PostgreSQL
+type PostgresOIDCIdentityStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresOIDCIdentityStorage(db *sql.DB) *PostgresOIDCIdentityStorage {
+	return &PostgresOIDCIdentityStorage{db: db}
+}
+
+// GetEmail возвращает пользователя, к которому привязан аккаунт провайдера, или пустую строку
+func (s *PostgresOIDCIdentityStorage) GetEmail(provider, subject string) (string, error) {
+	var email string
+	err := s.db.QueryRow(`SELECT email FROM oidc_identities WHERE provider = $1 AND subject = $2`, provider, subject).Scan(&email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+	return email, nil
+}
+
+func (s *PostgresOIDCIdentityStorage) Link(identity *OIDCIdentity, email string) error {
+	query := `INSERT INTO oidc_identities (provider, subject, email, provider_email)
+              VALUES ($1, $2, $3, $4)
+              ON CONFLICT (provider, subject) DO NOTHING`
+
+	_, err := s.db.Exec(query, identity.Provider, identity.Subject, email, identity.Email)
+	return err
+}
+
+// Register создаёт клиента без пароля (войти по паролю можно после его сброса через /auth/forgot-password)
+// и привязывает к нему аккаунт провайдера
+func (s *PostgresOIDCIdentityStorage) Register(identity *OIDCIdentity) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO all_users (login, password) VALUES ($1, '')
+                         ON CONFLICT (login) DO NOTHING`, identity.Email)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.New(ErrUserAlreadyExists)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO ts_users (email) VALUES ($1)`, identity.Email); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO oidc_identities (provider, subject, email, provider_email)
+              VALUES ($1, $2, $3, $3)`
+	if _, err := tx.Exec(query, identity.Provider, identity.Subject, identity.Email); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Хранение кодов верификации в памяти
+type MemoryVerificationStorage struct {
+	mu    sync.RWMutex
+	codes map[string]*RegistrationData
+}
+
+func NewMemoryVerificationStorage() *MemoryVerificationStorage {
+	storage := &MemoryVerificationStorage{
+		codes: make(map[string]*RegistrationData),
+	}
+	// Запуск очистки просроченных кодов
+	go storage.cleanupLoop()
+	return storage
 }
 
 // Сохранение кодов верификации
@@ -246,6 +1329,21 @@ func (s *MemoryVerificationStorage) GetByEmail(email string) (*RegistrationData,
 	return data, nil
 }
 
+// Учёт попытки ввода кода. Возвращает данные с увеличенным счётчиком или nil, если кода нет
+func (s *MemoryVerificationStorage) UseAttempt(email string) (*RegistrationData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.codes[email]
+	if !exists || time.Now().After(data.ExpiresAt) {
+		return nil, nil
+	}
+
+	data.Attempts++
+	result := *data
+	return &result, nil
+}
+
 // Удаление данных верификации
 func (s *MemoryVerificationStorage) Delete(email string) error {
 	s.mu.Lock()
@@ -261,17 +1359,9 @@ func (s *MemoryVerificationStorage) cleanup() {
 	defer s.mu.Unlock()
 
 	now := time.Now()
-	for email, attempts := range s.attempts {
-		var recent []time.Time
-		for _, t := range attempts {
-			if now.Sub(t) < time.Minute {
-				recent = append(recent, t)
-			}
-		}
-		if len(recent) == 0 {
-			delete(s.attempts, email)
-		} else {
-			s.attempts[email] = recent
+	for email, data := range s.codes {
+		if now.After(data.ExpiresAt) {
+			delete(s.codes, email)
 		}
 	}
 }
@@ -286,10 +1376,11 @@ func (s *MemoryVerificationStorage) cleanupLoop() {
 	}
 }
 
-// ResetPasswordData данные для сброса пароля
+// ResetPasswordData данные для сброса пароля. Код хранится только в виде SHA-256
 type ResetPasswordData struct {
 	Email     string
-	Code      string
+	CodeHash  string
+	Attempts  int // количество попыток ввода кода, включая текущую
 	ExpiresAt time.Time
 	Verified  bool
 }
@@ -298,10 +1389,77 @@ type ResetPasswordData struct {
 type ResetPasswordStorage interface {
 	Save(data *ResetPasswordData) error
 	GetByEmail(email string) (*ResetPasswordData, error)
+	UseAttempt(email string) (*ResetPasswordData, error)
 	Delete(email string) error
 	MarkVerified(email string) error
 }
 
+// PostgresResetPasswordStorage хранит коды сброса пароля в PostgreSQL
+type PostgresResetPasswordStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresResetPasswordStorage(db *sql.DB) *PostgresResetPasswordStorage {
+	return &PostgresResetPasswordStorage{db: db}
+}
+
+func (s *PostgresResetPasswordStorage) Save(data *ResetPasswordData) error {
+	query := `WITH expired AS (
+                  DELETE FROM password_reset_codes WHERE expires_at <= NOW() AND email <> $1
+              )
+              INSERT INTO password_reset_codes (email, code_hash, attempts, verified, expires_at)
+              VALUES ($1, $2, 0, FALSE, $3)
+              ON CONFLICT (email) DO UPDATE
+              SET code_hash  = EXCLUDED.code_hash,
+                  attempts   = 0,
+                  verified   = FALSE,
+                  expires_at = EXCLUDED.expires_at,
+                  created_at = NOW()`
+
+	_, err := s.db.Exec(query, data.Email, data.CodeHash, data.ExpiresAt)
+	return err
+}
+
+func (s *PostgresResetPasswordStorage) GetByEmail(email string) (*ResetPasswordData, error) {
+	query := `SELECT email, code_hash, attempts, verified, expires_at
+              FROM password_reset_codes
+              WHERE email = $1 AND expires_at > NOW()`
+
+	return scanResetPasswordData(s.db.QueryRow(query, email))
+}
+
+func (s *PostgresResetPasswordStorage) UseAttempt(email string) (*ResetPasswordData, error) {
+	query := `UPDATE password_reset_codes
+              SET attempts = attempts + 1
+              WHERE email = $1 AND expires_at > NOW()
+              RETURNING email, code_hash, attempts, verified, expires_at`
+
+	return scanResetPasswordData(s.db.QueryRow(query, email))
+}
+
+func (s *PostgresResetPasswordStorage) Delete(email string) error {
+	_, err := s.db.Exec(`DELETE FROM password_reset_codes WHERE email = $1`, email)
+	return err
+}
+
+func (s *PostgresResetPasswordStorage) MarkVerified(email string) error {
+	query := `UPDATE password_reset_codes SET verified = TRUE WHERE email = $1 AND expires_at > NOW()`
+	_, err := s.db.Exec(query, email)
+	return err
+}
+
+func scanResetPasswordData(row *sql.Row) (*ResetPasswordData, error) {
+	var data ResetPasswordData
+	err := row.Scan(&data.Email, &data.CodeHash, &data.Attempts, &data.Verified, &data.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &data, nil
+}
+
 // MemoryResetPasswordStorage хранит данные для сброса пароля в памяти
 type MemoryResetPasswordStorage struct {
 	mu    sync.RWMutex
@@ -336,6 +1494,18 @@ func (s *MemoryResetPasswordStorage) GetByEmail(email string) (*ResetPasswordDat
 	return data, nil
 }
 
+func (s *MemoryResetPasswordStorage) UseAttempt(email string) (*ResetPasswordData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.codes[email]
+	if !exists || time.Now().After(data.ExpiresAt) {
+		return nil, nil
+	}
+	data.Attempts++
+	result := *data
+	return &result, nil
+}
+
 func (s *MemoryResetPasswordStorage) Delete(email string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -371,3 +1541,57 @@ func (s *MemoryResetPasswordStorage) cleanupLoop() {
 		s.cleanup()
 	}
 }
+
+// MemoryEmailChangeStorage хранит коды смены адреса почты в памяти
+type MemoryEmailChangeStorage struct {
+	mu    sync.Mutex
+	codes map[string]*EmailChangeData
+}
+
+func NewMemoryEmailChangeStorage() *MemoryEmailChangeStorage {
+	storage := &MemoryEmailChangeStorage{
+		codes: make(map[string]*EmailChangeData),
+	}
+	go storage.cleanupLoop()
+	return storage
+}
+
+func (s *MemoryEmailChangeStorage) Save(data *EmailChangeData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.codes[data.Email] = data
+	return nil
+}
+
+func (s *MemoryEmailChangeStorage) UseAttempt(email string) (*EmailChangeData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, exists := s.codes[email]
+	if !exists || time.Now().After(data.ExpiresAt) {
+		return nil, nil
+	}
+	data.Attempts++
+	result := *data
+	return &result, nil
+}
+
+func (s *MemoryEmailChangeStorage) Delete(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.codes, email)
+	return nil
+}
+
+func (s *MemoryEmailChangeStorage) cleanupLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for email, data := range s.codes {
+			if now.After(data.ExpiresAt) {
+				delete(s.codes, email)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
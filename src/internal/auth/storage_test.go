@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"src/internal/rbac"
+)
+
+func TestGetRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		isAdmin     bool
+		inn         string
+		companyRole string
+		want        UserRole
+	}{
+		{"client", false, "", "", UserRole{Role: rbac.RoleClient}},
+		{"partner", false, "7700000000", rbac.CompanyRoleManager, UserRole{Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleManager}},
+		{"admin", true, "", "", UserRole{Role: rbac.RoleAdmin}},
+		// Администратор-сотрудник компании сохраняет членство в компании
+		{"admin member", true, "7700000000", rbac.CompanyRoleOwner, UserRole{Role: rbac.RoleAdmin, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock: %v", err)
+			}
+			defer sqlDB.Close()
+
+			mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM admin WHERE email = \$1\)`).
+				WithArgs("user@example.com").
+				WillReturnRows(sqlmock.NewRows([]string{"is_admin", "inn", "role"}).AddRow(tt.isAdmin, tt.inn, tt.companyRole))
+
+			got, err := NewPostgresUserStorage(sqlDB).GetRole("user@example.com")
+			if err != nil {
+				t.Fatalf("GetRole: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("GetRole = %+v, want %+v", *got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
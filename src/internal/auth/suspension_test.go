@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"src/internal/audit"
+	"src/internal/mail"
+)
+
+func (s *memoryUsers) Suspend(email, reason string) (bool, error) {
+	user := s.users[email]
+	if user == nil || user.SuspendedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	user.SuspendedAt = &now
+	user.SuspensionReason = reason
+	return true, nil
+}
+
+func (s *memoryUsers) Reinstate(email string) (bool, error) {
+	user := s.users[email]
+	if user == nil || user.SuspendedAt == nil {
+		return false, nil
+	}
+	user.SuspendedAt = nil
+	user.SuspensionReason = ""
+	return true, nil
+}
+
+func (s *recordingEmailSender) SendAccountSuspended(toEmail string, data mail.SuspensionData) error {
+	s.suspensions = append(s.suspensions, toEmail)
+	return nil
+}
+
+func (s *recordingEmailSender) SendAccountReinstated(toEmail string, data mail.SuspensionData) error {
+	s.reinstatements = append(s.reinstatements, toEmail)
+	return nil
+}
+
+// Блокировка завершает сессии и отзывает access токены, снятие блокировки снова разрешает вход
+func TestSuspendAndReinstateUser(t *testing.T) {
+	ta := newTestAuth(t)
+	ta.securityEventStorage = &memorySecurityEvents{}
+	admin := audit.Actor{Email: "admin@example.com"}
+	tokens := ta.login(t)
+	accessJTI := ta.tokens.tokens[hashToken(tokens.RefreshToken)].AccessJTI
+
+	if err := ta.SuspendUser(admin, "user@example.com", "fraud"); err != nil {
+		t.Fatalf("SuspendUser: %v", err)
+	}
+	user := ta.users.users["user@example.com"]
+	if user.SuspendedAt == nil || user.SuspensionReason != "fraud" {
+		t.Fatalf("user after suspension = %+v", user)
+	}
+	if len(ta.tokens.tokens) != 0 {
+		t.Errorf("sessions left = %d, want 0", len(ta.tokens.tokens))
+	}
+	if _, ok := ta.revoker.revoked[accessJTI]; !ok {
+		t.Errorf("access token %s is not revoked", accessJTI)
+	}
+	if len(ta.email.suspensions) != 1 {
+		t.Errorf("suspension emails = %v", ta.email.suspensions)
+	}
+	if err := checkNotSuspended(user); err == nil || err.Error() != ErrAccountSuspended {
+		t.Errorf("checkNotSuspended = %v, want %q", err, ErrAccountSuspended)
+	}
+
+	if err := ta.SuspendUser(admin, "user@example.com", "fraud"); err == nil || err.Error() != ErrAlreadySuspended {
+		t.Errorf("second SuspendUser error = %v, want %q", err, ErrAlreadySuspended)
+	}
+
+	if err := ta.ReinstateUser(admin, "user@example.com", ""); err != nil {
+		t.Fatalf("ReinstateUser: %v", err)
+	}
+	if user.SuspendedAt != nil || checkNotSuspended(user) != nil {
+		t.Errorf("user after reinstatement = %+v", user)
+	}
+	if len(ta.email.reinstatements) != 1 {
+		t.Errorf("reinstatement emails = %v", ta.email.reinstatements)
+	}
+	if err := ta.ReinstateUser(admin, "user@example.com", ""); err == nil || err.Error() != ErrNotSuspended {
+		t.Errorf("second ReinstateUser error = %v, want %q", err, ErrNotSuspended)
+	}
+}
+
+func TestSuspendSelf(t *testing.T) {
+	ta := newTestAuth(t)
+	if err := ta.SuspendUser(audit.Actor{Email: "User@example.com"}, "user@example.com", ""); err == nil || err.Error() != ErrSuspendSelf {
+		t.Fatalf("SuspendUser error = %v, want %q", err, ErrSuspendSelf)
+	}
+	if ta.users.users["user@example.com"].SuspendedAt != nil {
+		t.Error("user suspended their own account")
+	}
+}
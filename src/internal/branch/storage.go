@@ -104,7 +104,7 @@ func (s *PostgresBranchStorage) GetBranchByCityServ(city string, serviceID strin
 	FROM branches b
 	JOIN branch_services bs ON b.id = bs.branch
 	JOIN companies cmp ON b.inn_company = cmp.inn
-	WHERE b.city = $1 AND bs.service = $2
+	WHERE b.city = $1 AND bs.service = $2 AND cmp.suspended_at IS NULL
 `, city, serviceID)
 
 	if err != nil {
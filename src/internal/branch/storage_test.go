@@ -0,0 +1,35 @@
+package branch
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// Филиалы приостановленных компаний не попадают в поиск
+func TestGetBranchByCityServHidesSuspendedCompanies(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer sqlDB.Close()
+
+	branchServID, branchID := uuid.New(), uuid.New()
+	serviceID := uuid.New().String()
+	mock.ExpectQuery(`(?s)FROM branches b.*WHERE b\.city = \$1 AND bs\.service = \$2 AND cmp\.suspended_at IS NULL`).
+		WithArgs("Москва", serviceID).
+		WillReturnRows(sqlmock.NewRows([]string{"branch_service_id", "id", "address", "org_short_name"}).
+			AddRow(branchServID, branchID, "ул. Тверская, д. 1", "ООО Ромашка"))
+
+	branches, err := NewPostgresBranchStorage(sqlDB).GetBranchByCityServ("Москва", serviceID)
+	if err != nil {
+		t.Fatalf("GetBranchByCityServ: %v", err)
+	}
+	if len(branches) != 1 || branches[0].BranchServId != branchServID || branches[0].CompanyName != "ООО Ромашка" {
+		t.Errorf("branches = %+v", branches)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,24 +3,35 @@ package company
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"src/internal/audit"
+	"src/internal/events"
 	"src/internal/middleware"
+	"src/internal/rbac"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
 // Handler обрабатывает HTTP-запросы для компаний
 type Handler struct {
-	company *CompanyManager
+	company   *CompanyManager
+	bus       *events.Bus
+	heartbeat time.Duration
 }
 
 // NewHandler создаёт новый экземпляр Handler.
-func NewHandler(company *CompanyManager) *Handler {
-	return &Handler{company: company}
+func NewHandler(company *CompanyManager, bus *events.Bus) *Handler {
+	return &Handler{company: company, bus: bus, heartbeat: streamHeartbeat}
 }
 
+// Интервал отправки комментария-пинга, чтобы прокси не закрывали простаивающее соединение.
+// С каждым пингом перепроверяется доступ подписчика к потоку
+const streamHeartbeat = 25 * time.Second
+
 // GetCompanies обрабатывает GET /companies.
 func (h *Handler) GetCompanies(w http.ResponseWriter, r *http.Request) {
 	companies, err := h.company.GetAllCompanies()
@@ -69,25 +80,22 @@ func (h *Handler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
 // GetCompany обрабатывает GET /company.
 func (h *Handler) GetCompany(w http.ResponseWriter, r *http.Request) {
 
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	// Получение email из claims (из тела токена)
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	company, err := h.company.GetCompany(email)
+	company, err := h.company.GetCompany(principal)
 	if err != nil {
 		if errors.Is(err, ErrUserNotPartner) {
 			http.Error(w, "User does not have a company", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, ErrCompanyPermissionDenied) {
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, ErrCompanyNotFound) {
 			http.Error(w, "Company not found", http.StatusNotFound)
 			return
@@ -143,25 +151,23 @@ func (h *Handler) CreateCompany(w http.ResponseWriter, r *http.Request) {
 // Обрабатывает Get /company/branches
 func (h *Handler) GetBranchesByUser(w http.ResponseWriter, r *http.Request) {
 
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	branches, err := h.company.GetBranchesByEmail(email)
+	branches, err := h.company.GetBranchesByEmail(principal)
 	if err != nil {
 		// Если пользователь не является партнёром
 		if errors.Is(err, ErrUserNotPartner) {
 			http.Error(w, "User does not have a company", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, ErrCompanyPermissionDenied) {
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, ErrBranchesNotFound) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
@@ -182,15 +188,9 @@ func (h *Handler) GetBranchesByUser(w http.ResponseWriter, r *http.Request) {
 
 // Обрабатывает Get /company/branches/{branch_id}
 func (h *Handler) GetBrancesByIdUser(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -207,12 +207,16 @@ func (h *Handler) GetBrancesByIdUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	branch, err := h.company.GetBranchByIdEmail(branchID, email)
+	branch, err := h.company.GetBranchByIdEmail(branchID, principal)
 	if err != nil {
 		if errors.Is(err, ErrUserNotPartner) {
 			http.Error(w, "User does not have a company", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, ErrCompanyPermissionDenied) {
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, ErrBranchNotInCompany) {
 			http.Error(w, "User does not have access to the branch", http.StatusForbidden)
 			return
@@ -238,15 +242,9 @@ func (h *Handler) GetBrancesByIdUser(w http.ResponseWriter, r *http.Request) {
 // обрабатывает get /company/branch/service/{branch_serv_id}
 func (h *Handler) GetServDetailsByBranchServId(w http.ResponseWriter, r *http.Request) {
 
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 	// Извлекаем branchServID из пути
@@ -258,12 +256,14 @@ func (h *Handler) GetServDetailsByBranchServId(w http.ResponseWriter, r *http.Re
 	}
 
 	// Вызываем бизнес-логику
-	details, err := h.company.GetServDetailsByBranchServId(branchServID, email)
+	details, err := h.company.GetServDetailsByBranchServId(branchServID, principal)
 	if err != nil {
 		// Обработка известных ошибок
 		switch {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "user is not a partner", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
 		case errors.Is(err, ErrBranchesNotFound):
 			http.Error(w, "branch service not available", http.StatusForbidden)
 		case errors.Is(err, ErrBranchServNotFound):
@@ -295,22 +295,15 @@ func (h *Handler) GetServDetailsByBranchServId(w http.ResponseWriter, r *http.Re
 	}
 }
 
-// Обрабатывает Post /company/users
-func (h *Handler) AddNewUserToCompany(w http.ResponseWriter, r *http.Request) {
-	// Получение claims
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+// InviteUser обрабатывает POST /company/invitations (и POST /company/users для совместимости).
+// Пользователь присоединяется к компании только после принятия приглашения из письма
+func (h *Handler) InviteUser(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Извлечение email из claims
-	userEmail, ok := claims["email"].(string)
-	if !ok || userEmail == "" {
-		http.Error(w, "Invalid token: email not found", http.StatusUnauthorized)
-		return
-	}
-
 	var req AddUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -322,40 +315,316 @@ func (h *Handler) AddNewUserToCompany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Добавление пользователя
-	err := h.company.AddUserToCompany(userEmail, req.Email)
+	invitation, err := h.company.InviteUser(principal, audit.ActorFromRequest(r), req.Email, req.Role)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "User does not have a company", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
 		case errors.Is(err, ErrCompanyNotFound):
 			http.Error(w, "Company not found", http.StatusNotFound)
+		case errors.Is(err, ErrInvalidCompanyRole):
+			http.Error(w, ErrInvalidCompanyRole.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrUserAlreadyPartner):
+			http.Error(w, ErrUserAlreadyPartner.Error(), http.StatusConflict)
 		default:
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invitation)
+}
+
+// GetInvitations обрабатывает GET /company/invitations
+func (h *Handler) GetInvitations(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	invitations, err := h.company.GetPendingInvitations(principal)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	if invitations == nil {
+		invitations = []*Invitation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// RevokeInvitation обрабатывает DELETE /company/invitations/{id}
+func (h *Handler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid invitation id format: must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.company.RevokeInvitation(principal, audit.ActorFromRequest(r), id); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetInvitation обрабатывает GET /invitations/{token}
+func (h *Handler) GetInvitation(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	info, err := h.company.GetInvitation(token)
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// AcceptInvitation обрабатывает POST /invitations/accept
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	invitation, err := h.company.AcceptInvitation(audit.ActorFromRequest(r), req.Token, req.Password)
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "User added to company successfully",
-		"email":   req.Email,
+		"message": "Invitation accepted",
+		"email":   invitation.Email,
+		"inn":     invitation.CompanyINN,
+		"role":    invitation.Role,
 	})
 }
 
-// Обрабатывает Post /company/branch
-func (h *Handler) AddNewBranchToCompany(w http.ResponseWriter, r *http.Request) {
-	// Получение claims
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+// DeclineInvitation обрабатывает POST /invitations/decline
+func (h *Handler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	var req DeclineInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	if err := h.company.DeclineInvitation(req.Token); err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Invitation declined",
+	})
+}
+
+// Ошибки ответа на приглашение
+func writeInvitationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvitationNotFound):
+		http.Error(w, ErrInvitationNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvitationExpired):
+		http.Error(w, ErrInvitationExpired.Error(), http.StatusGone)
+	case errors.Is(err, ErrInvitationNotPending), errors.Is(err, ErrUserAlreadyPartner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrPasswordRequired):
+		http.Error(w, ErrPasswordRequired.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// GetCompanyUsers обрабатывает GET /company/users
+func (h *Handler) GetCompanyUsers(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	members, err := h.company.GetCompanyUsers(principal)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotPartner):
+			http.Error(w, "User does not have a company", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if members == nil {
+		members = []*CompanyMember{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateCompanyUserRole обрабатывает PUT /company/users/{email}/role
+func (h *Handler) UpdateCompanyUserRole(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	memberEmail := chi.URLParam(r, "email")
+	if memberEmail == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	member, err := h.company.UpdateUserRole(principal, audit.ActorFromRequest(r), memberEmail, req.Role)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(member)
+}
+
+// RemoveCompanyUser обрабатывает DELETE /company/users/{email}
+func (h *Handler) RemoveCompanyUser(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	memberEmail := chi.URLParam(r, "email")
+	if memberEmail == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.company.RemoveUserFromCompany(principal, audit.ActorFromRequest(r), memberEmail); err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMFAPolicy обрабатывает GET /company/security/2fa, возвращает обязательность 2FA для сотрудников
+func (h *Handler) GetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	policy, err := h.company.GetMFAPolicy(principal)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// SetMFAPolicy обрабатывает PUT /company/security/2fa, включает или отключает обязательную 2FA для сотрудников
+func (h *Handler) SetMFAPolicy(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Извлечение email из claims
-	userEmail, ok := claims["email"].(string)
-	if !ok || userEmail == "" {
-		http.Error(w, "Invalid token: email not found", http.StatusUnauthorized)
+	var req UpdateMFAPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	policy, err := h.company.SetMFAPolicy(principal, audit.ActorFromRequest(r), *req.Required)
+	if err != nil {
+		writeMemberError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// Ошибки изменения состава сотрудников компании
+func writeMemberError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotPartner):
+		http.Error(w, "User does not have a company", http.StatusForbidden)
+	case errors.Is(err, ErrCompanyPermissionDenied):
+		http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrInvalidCompanyRole):
+		http.Error(w, ErrInvalidCompanyRole.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrMemberNotFound):
+		http.Error(w, ErrMemberNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrLastOwner):
+		http.Error(w, ErrLastOwner.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInvitationNotFound):
+		http.Error(w, ErrInvitationNotFound.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrCompanyNotFound):
+		http.Error(w, ErrCompanyNotFound.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Обрабатывает Post /company/branch
+func (h *Handler) AddNewBranchToCompany(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -371,11 +640,13 @@ func (h *Handler) AddNewBranchToCompany(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Добавление филиала
-	err := h.company.AddBranchToCompany(userEmail, req.City, req.Address, req.OpenTime, req.CloseTime)
+	err := h.company.AddBranchToCompany(principal, req.City, req.Address, req.OpenTime, req.CloseTime)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "User does not have a company", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
 		case errors.Is(err, ErrCompanyNotFound):
 			http.Error(w, "Company not found", http.StatusNotFound)
 		default:
@@ -396,20 +667,12 @@ func (h *Handler) AddNewBranchToCompany(w http.ResponseWriter, r *http.Request)
 
 // AddServiceToBranch обрабатывает POST /company/branch/service
 func (h *Handler) AddServiceToBranch(w http.ResponseWriter, r *http.Request) {
-	// Получение claims
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Извлечение email из claims
-	userEmail, ok := claims["email"].(string)
-	if !ok || userEmail == "" {
-		http.Error(w, "Invalid token: email not found", http.StatusUnauthorized)
-		return
-	}
-
 	var req AddServiceToBranch
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -422,11 +685,13 @@ func (h *Handler) AddServiceToBranch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Добавление услуги в филиал
-	err := h.company.AddServiceToBranch(userEmail, req.BranchID, req.ServiceID)
+	err := h.company.AddServiceToBranch(principal, req.BranchID, req.ServiceID)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "User does not have a company", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
 		case errors.Is(err, ErrCompanyNotFound):
 			http.Error(w, "Company not found", http.StatusNotFound)
 		case errors.Is(err, ErrBranchNotInCompany):
@@ -449,26 +714,23 @@ func (h *Handler) AddServiceToBranch(w http.ResponseWriter, r *http.Request) {
 
 // GetCompanyOrders обрабатывает GET /company/orders
 func (h *Handler) GetCompanyOrders(w http.ResponseWriter, r *http.Request) {
-	// Получаем claims из контекста
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	orders, err := h.company.GetCompanyOrders(email)
+	orders, err := h.company.GetCompanyOrders(principal)
 	if err != nil {
 		// Обрабатываем известные ошибки
 		if errors.Is(err, ErrUserNotPartner) {
 			http.Error(w, "user is not a partner", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, ErrCompanyPermissionDenied) {
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, ErrBranchNotFound) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
@@ -498,15 +760,9 @@ func (h *Handler) GetCompanyOrders(w http.ResponseWriter, r *http.Request) {
 // Параметры query: orderID (UUID), status (approve|reject)
 func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -528,7 +784,7 @@ func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedOrder, err := h.company.UpdateOrderStatus(email, orderID, statusStr)
+	updatedOrder, err := h.company.UpdateOrderStatus(principal, orderID, statusStr)
 	if err != nil {
 
 		switch {
@@ -538,6 +794,9 @@ func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "user is not a partner", http.StatusForbidden)
 
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+
 		case errors.Is(err, ErrOrderNotAvailable):
 			http.Error(w, ErrOrderNotAvailable.Error(), http.StatusForbidden)
 
@@ -565,15 +824,9 @@ func (h *Handler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 
 // AddServDetail обрабатывает POST /company/branch/service/detail
 func (h *Handler) AddServDetail(w http.ResponseWriter, r *http.Request) {
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -608,11 +861,13 @@ func (h *Handler) AddServDetail(w http.ResponseWriter, r *http.Request) {
 		Price:  price,
 	}
 
-	createdDetail, err := h.company.AddServiceDetail(req.BranchServID, email, details, prices)
+	createdDetail, err := h.company.AddServiceDetail(req.BranchServID, principal, details, prices)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "user is not a partner", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
 		case errors.Is(err, ErrBranchServNotFound):
 			http.Error(w, "branch service not available", http.StatusForbidden)
 		case errors.Is(err, ErrBranchNotFound):
@@ -639,15 +894,9 @@ func (h *Handler) AddServDetail(w http.ResponseWriter, r *http.Request) {
 // DeleteServDetail обрабатывает DELETE /company/branch/service/detail/{branchServID}
 func (h *Handler) DeleteServDetail(w http.ResponseWriter, r *http.Request) {
 
-	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	principal, ok := middleware.PrincipalFromContext(r.Context())
 	if !ok {
-		http.Error(w, "unauthorized: missing user claims", http.StatusUnauthorized)
-		return
-	}
-
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
-		http.Error(w, "unauthorized: email not found in token", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -669,11 +918,13 @@ func (h *Handler) DeleteServDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedDetails, err := h.company.DeleteServiceDetail(branchServID, email, detailName)
+	updatedDetails, err := h.company.DeleteServiceDetail(branchServID, principal, detailName)
 	if err != nil {
 		switch {
 		case errors.Is(err, ErrUserNotPartner):
 			http.Error(w, "user is not a partner", http.StatusForbidden)
+		case errors.Is(err, ErrCompanyPermissionDenied):
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
 		case errors.Is(err, ErrBranchServNotFound):
 			http.Error(w, "branch service not available", http.StatusForbidden)
 		case errors.Is(err, ErrBranchNotFound):
@@ -694,3 +945,135 @@ func (h *Handler) DeleteServDetail(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to encode response", http.StatusInternalServerError)
 	}
 }
+
+// StreamOrders обрабатывает GET /company/orders/stream?branch_id=<uuid>.
+// Отправляет события заказов компании пользователя в формате Server-Sent Events.
+// При переподключении с заголовком Last-Event-ID досылает пропущенные события
+func (h *Handler) StreamOrders(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Необязательный фильтр по филиалу
+	branchID := uuid.Nil
+	if branchStr := r.URL.Query().Get("branch_id"); branchStr != "" {
+		var err error
+		branchID, err = uuid.Parse(branchStr)
+		if err != nil {
+			http.Error(w, "invalid branch id format: must be UUID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var lastEventID uint64
+	if lastStr := r.Header.Get("Last-Event-ID"); lastStr != "" {
+		var err error
+		lastEventID, err = strconv.ParseUint(lastStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Last-Event-ID must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	inn, err := h.company.OrderStreamScope(principal, branchID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotPartner) {
+			http.Error(w, "User does not have a company", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, ErrCompanyPermissionDenied) {
+			http.Error(w, ErrCompanyPermissionDenied.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, ErrBranchNotInCompany) {
+			http.Error(w, "User does not have access to the branch", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Поток живёт дольше обычного запроса - снимаем таймаут записи, если он задан сервером
+	rc.SetWriteDeadline(time.Time{})
+
+	sub, missed := h.bus.Subscribe(inn, branchID, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, evt := range missed {
+		if err := writeSSE(w, evt); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	// Поток закрывается, когда истекает токен: клиент переподключится с новым
+	credentials, _ := middleware.CredentialsFromContext(r.Context())
+	var expired <-chan time.Time
+	if !credentials.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(credentials.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-expired:
+			return
+		case evt, ok := <-sub.C:
+			if !ok {
+				// Подписка закрыта шиной - клиент переподключится с Last-Event-ID
+				return
+			}
+			if err := writeSSE(w, evt); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if !h.streamAllowed(credentials, principal, branchID, inn) {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Повторная проверка доступа к открытому потоку: учётные данные могли перестать действовать,
+// а пользователь - покинуть компанию
+func (h *Handler) streamAllowed(credentials middleware.Credentials, principal rbac.Principal, branchID uuid.UUID, inn string) bool {
+	if credentials.Check != nil && credentials.Check() != nil {
+		return false
+	}
+	scope, err := h.company.OrderStreamScope(principal, branchID)
+	return err == nil && scope == inn
+}
+
+// Запись события в формате SSE
+func writeSSE(w http.ResponseWriter, evt *events.Event) error {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.Type, data)
+	return err
+}
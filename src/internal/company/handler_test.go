@@ -0,0 +1,73 @@
+package company
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"src/internal/events"
+	"src/internal/middleware"
+	"src/internal/rbac"
+)
+
+// Запускает поток заказов оператора компании и возвращает канал,
+// который закрывается, когда обработчик завершает поток
+func startStream(t *testing.T, credentials middleware.Credentials) <-chan struct{} {
+	t.Helper()
+	h := &Handler{company: &CompanyManager{}, bus: events.NewBus(), heartbeat: 10 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	ctx = middleware.WithPrincipal(ctx, rbac.Principal{
+		Email:       "operator@example.com",
+		Role:        rbac.RolePartner,
+		INN:         "7700000000",
+		CompanyRole: rbac.CompanyRoleOperator,
+	})
+	ctx = middleware.WithCredentials(ctx, credentials)
+	r := httptest.NewRequest(http.MethodGet, "/company/orders/stream", nil).WithContext(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.StreamOrders(httptest.NewRecorder(), r)
+	}()
+	return done
+}
+
+func waitClosed(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream is still open")
+	}
+}
+
+// Поток закрывается в момент истечения токена
+func TestStreamOrdersClosesOnExpiry(t *testing.T) {
+	done := startStream(t, middleware.Credentials{ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	waitClosed(t, done)
+}
+
+// Отозванный токен (например, после исключения из компании) закрывает поток на ближайшем пинге
+func TestStreamOrdersClosesOnRevocation(t *testing.T) {
+	var revoked atomic.Bool
+	done := startStream(t, middleware.Credentials{Check: func() error {
+		if revoked.Load() {
+			return middleware.ErrCredentialsRevoked
+		}
+		return nil
+	}})
+
+	select {
+	case <-done:
+		t.Fatal("stream closed while the token is valid")
+	case <-time.After(50 * time.Millisecond):
+	}
+	revoked.Store(true)
+	waitClosed(t, done)
+}
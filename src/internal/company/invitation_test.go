@@ -0,0 +1,137 @@
+package company
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	"src/internal/auth"
+	"src/internal/db"
+)
+
+// Хранилище с одним ожидающим приглашением
+type invitationStorage struct {
+	CompanyStorage
+	invitation *Invitation
+	acceptErr  error
+	accepted   bool
+}
+
+func (s *invitationStorage) WithTx(tx *sql.Tx) CompanyStorage {
+	return s
+}
+
+func (s *invitationStorage) GetInvitationByTokenHash(tokenHash string) (*Invitation, error) {
+	return s.invitation, nil
+}
+
+func (s *invitationStorage) AcceptInvitation(id uuid.UUID) error {
+	if s.acceptErr != nil {
+		return s.acceptErr
+	}
+	s.accepted = true
+	return nil
+}
+
+type noUsers struct {
+	UserStorage
+}
+
+func (noUsers) GetByEmail(email string) (*auth.User, error) {
+	return nil, nil
+}
+
+type recordingRegistrar struct {
+	tx    *sql.Tx
+	email string
+}
+
+func (r *recordingRegistrar) CreateVerifiedUser(tx *sql.Tx, email, password string) error {
+	r.tx, r.email = tx, email
+	return nil
+}
+
+func newInvitationManager(t *testing.T, acceptErr error) (*CompanyManager, *invitationStorage, *recordingRegistrar, *recordingRevoker, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	storage := &invitationStorage{
+		invitation: &Invitation{ID: uuid.New(), CompanyINN: "7700000000", Email: "new@example.com", Status: InvitationPending},
+		acceptErr:  acceptErr,
+	}
+	registrar := &recordingRegistrar{}
+	revoker := &recordingRevoker{}
+	m := &CompanyManager{storage: storage, userStorage: noUsers{}, registrar: registrar, roleRevoker: revoker, auditLog: &recordingAudit{}, uow: db.NewTxManager(sqlDB)}
+	return m, storage, registrar, revoker, mock
+}
+
+func TestAcceptInvitationRegistersInTransaction(t *testing.T) {
+	m, storage, registrar, revoker, mock := newInvitationManager(t, nil)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	invitation, err := m.AcceptInvitation(audit.Actor{IP: "203.0.113.7"}, "token", "password123")
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if invitation.Status != InvitationAccepted || !storage.accepted {
+		t.Errorf("invitation = %+v, accepted = %v", invitation, storage.accepted)
+	}
+	if registrar.tx == nil || registrar.email != "new@example.com" {
+		t.Errorf("user registered outside the transaction: %+v", registrar)
+	}
+	if len(revoker.revoked) != 1 {
+		t.Errorf("revoked = %v", revoker.revoked)
+	}
+	// Вступление записывается от имени приглашённого
+	entries := m.auditLog.(*recordingAudit).entries
+	if len(entries) != 1 || entries[0].Action != audit.ActionCompanyMemberJoin || entries[0].Actor != "new@example.com" ||
+		entries[0].IP != "203.0.113.7" || entries[0].TargetID != "7700000000/new@example.com" {
+		t.Errorf("audit entries = %+v", entries)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Если приглашение принять не удалось, регистрация откатывается вместе с ним
+func TestAcceptInvitationRollsBackRegistration(t *testing.T) {
+	m, _, registrar, revoker, mock := newInvitationManager(t, ErrInvitationNotPending)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	if _, err := m.AcceptInvitation(audit.Actor{IP: "203.0.113.7"}, "token", "password123"); !errors.Is(err, ErrInvitationNotPending) {
+		t.Fatalf("AcceptInvitation error = %v, want ErrInvitationNotPending", err)
+	}
+	if registrar.tx == nil {
+		t.Error("user was not registered in the transaction")
+	}
+	if len(revoker.revoked) != 0 {
+		t.Errorf("revoked = %v, want none", revoker.revoked)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAcceptInvitationRequiresPassword(t *testing.T) {
+	m, _, registrar, _, mock := newInvitationManager(t, nil)
+
+	if _, err := m.AcceptInvitation(audit.Actor{IP: "203.0.113.7"}, "token", ""); !errors.Is(err, ErrPasswordRequired) {
+		t.Fatalf("AcceptInvitation error = %v, want ErrPasswordRequired", err)
+	}
+	if registrar.email != "" {
+		t.Error("user registered without password")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
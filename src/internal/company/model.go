@@ -44,12 +44,21 @@ type CompanyResponse struct {
 type IsPartnersUsers struct {
 	IsPartner bool
 	Inn       string
+	Role      string
+	Scopes    []string // права API ключа, если запрос выполняется с ключом
 }
 
-// PartnersUsers используется для передачи email и inn - если есть
+// PartnersUsers используется для передачи email, inn и роли в компании - если есть
 type PartnersUsers struct {
 	Email string
 	Inn   string
+	Role  string
+}
+
+// CompanyMember - сотрудник компании, используется в GET /company/users
+type CompanyMember struct {
+	Email string `json:"email" example:"manager@mail.ru"`
+	Role  string `json:"role" example:"manager" enums:"owner,manager,operator"`
 }
 
 // Соответсвует таблице branches, используется в get /company/branches
@@ -86,9 +95,72 @@ type BranchServ struct {
 	ServiceDetails []ServUpdateResponse `json:"service_detalis"`
 }
 
-// AddUserRequest - запрос на добавление нового пользователя
+// AddUserRequest - запрос на приглашение нового пользователя.
+// Если роль не указана, пользователь приглашается оператором
 type AddUserRequest struct {
 	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role,omitempty" example:"operator" validate:"omitempty,oneof=owner manager operator"`
+}
+
+// UpdateUserRoleRequest - запрос на изменение роли сотрудника
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" example:"manager" validate:"required,oneof=owner manager operator"`
+}
+
+// MFAPolicy - обязательность двухфакторной аутентификации для сотрудников компании
+type MFAPolicy struct {
+	Required bool `json:"required" example:"true"`
+}
+
+// UpdateMFAPolicyRequest - запрос на изменение обязательности 2FA
+type UpdateMFAPolicyRequest struct {
+	Required *bool `json:"required" example:"true" validate:"required"`
+}
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"  // ожидает ответа
+	InvitationAccepted InvitationStatus = "accepted" // принято
+	InvitationDeclined InvitationStatus = "declined" // отклонено приглашённым
+	InvitationRevoked  InvitationStatus = "revoked"  // отозвано компанией
+	InvitationExpired  InvitationStatus = "expired"  // истёк срок (в БД остаётся pending)
+)
+
+// Соответствует таблице company_invitations
+type Invitation struct {
+	ID          uuid.UUID        `json:"id" example:"8c1f7e4a-3b2d-4e6f-9a1c-5d7b9e2f4a6c"`
+	CompanyINN  string           `json:"inn" example:"234567890123"`
+	Email       string           `json:"email" example:"newuser@example.com"`
+	Role        string           `json:"role" example:"operator"`
+	InvitedBy   string           `json:"invited_by" example:"owner@example.com"`
+	Status      InvitationStatus `json:"status" example:"pending"`
+	CreatedAt   time.Time        `json:"created_at" example:"2026-03-30T06:06:47.181805Z"`
+	ExpiresAt   time.Time        `json:"expires_at" example:"2026-04-06T06:06:47.181805Z"`
+	RespondedAt *time.Time       `json:"responded_at,omitempty"`
+}
+
+// Приглашение, каким его видит приглашённый пользователь (GET /invitations/{token})
+type InvitationInfo struct {
+	Email       string           `json:"email" example:"newuser@example.com"`
+	CompanyName string           `json:"company_name" example:"Технопром"`
+	Role        string           `json:"role" example:"operator"`
+	InvitedBy   string           `json:"invited_by" example:"owner@example.com"`
+	Status      InvitationStatus `json:"status" example:"pending"`
+	ExpiresAt   time.Time        `json:"expires_at" example:"2026-04-06T06:06:47.181805Z"`
+	Registered  bool             `json:"registered" example:"false"`
+}
+
+// Запрос на принятие приглашения.
+// Пароль обязателен, если пользователь с email приглашения ещё не зарегистрирован
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required,len=64,hexadecimal"`
+	Password string `json:"password,omitempty" example:"1A_password" validate:"omitempty,min=8,max=24,password"`
+}
+
+// Запрос на отклонение приглашения
+type DeclineInvitationRequest struct {
+	Token string `json:"token" validate:"required,len=64,hexadecimal"`
 }
 
 // AddBranchRequest - запрос на добавление нового филиала
@@ -1,15 +1,27 @@
 package company
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"regexp"
 	"slices"
+	"src/internal/audit"
 	"src/internal/city"
+	configPkg "src/internal/config"
+	"src/internal/db"
+	"src/internal/events"
+	"src/internal/mail"
+	"src/internal/rbac"
 	"src/internal/timeparsing"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -28,20 +40,62 @@ var (
 	ErrBranchServDetailAlreadyExists = errors.New("detail for this service in the branch already exists")
 	ErrInvalidDuration               = errors.New("invalid duration")
 	ErrDetailNotFound                = errors.New("detail in branch service not found")
+	ErrCompanyPermissionDenied       = errors.New("not enough rights in the company")
+	ErrInvalidCompanyRole            = errors.New("company role must be owner, manager or operator")
+	ErrInvitationExpired             = errors.New("invitation has expired")
+	ErrPasswordRequired              = errors.New("password is required to register the invited user")
 )
 
 var hyphenSpaces = regexp.MustCompile(`\s*-\s*`)
 
 // CompanyManager содержит бизнес-логику для работы с компаниями.
 type CompanyManager struct {
-	storage     CompanyStorage
-	userStorage UserStorage
+	storage          CompanyStorage
+	userStorage      UserStorage
+	registrar        UserRegistrar
+	emailSender      configPkg.TxEmailSender
+	publisher        EventPublisher
+	roleRevoker      RoleRevoker
+	invitationConfig configPkg.InvitationConfig
+	auditLog         audit.Recorder
+	uow              db.UnitOfWork
 }
 
 // NewCompanyManager создаёт новый экземпляр CompanyManager.
-func NewCompanyManager(storage CompanyStorage, userStorage UserStorage) *CompanyManager {
+func NewCompanyManager(storage CompanyStorage, userStorage UserStorage, registrar UserRegistrar, emailSender configPkg.TxEmailSender, publisher EventPublisher, roleRevoker RoleRevoker, invitationConfig configPkg.InvitationConfig, auditLog audit.Recorder, uow db.UnitOfWork) *CompanyManager {
 	return &CompanyManager{storage: storage,
-		userStorage: userStorage}
+		userStorage:      userStorage,
+		registrar:        registrar,
+		emailSender:      emailSender,
+		publisher:        publisher,
+		roleRevoker:      roleRevoker,
+		invitationConfig: invitationConfig,
+		auditLog:         auditLog,
+		uow:              uow}
+}
+
+// UserRegistrar регистрирует пользователя, владение email которого уже подтверждено
+type UserRegistrar interface {
+	CreateVerifiedUser(tx *sql.Tx, email, password string) error
+}
+
+// RoleRevoker отзывает access токены пользователя после изменения его роли
+type RoleRevoker interface {
+	Revoke(email string) error
+}
+
+// EventPublisher публикует события заказов компании во внутреннюю шину (events.Bus)
+type EventPublisher interface {
+	Publish(companyInn string, branchID uuid.UUID, eventType string, data any) error
+
+	// PublishTx публикует событие изменения в транзакции tx. Возвращённую функцию вызывают после commit
+	PublishTx(tx *sql.Tx, companyInn string, branchID uuid.UUID, eventType string, data any) (func(), error)
+}
+
+// OrderStatusChangedEvent - данные события изменения статуса заказа
+type OrderStatusChangedEvent struct {
+	Order          *CompanyOrder `json:"order"`
+	PreviousStatus OrderStatus   `json:"previous_status"`
 }
 
 // GetAllCompanies - возвращает список всех компаний
@@ -55,17 +109,29 @@ func (m *CompanyManager) GetAllCompanies() ([]Company, error) {
 
 // DeleteCompany удаляет компанию  по инн
 // Если компания не найдена, возвращает ошибку ErrCompanyNotFound.
+// Токены сотрудников с ИНН компании в claims отзываются
 func (m *CompanyManager) DeleteCompany(inn string) error {
-	return m.storage.Delete(inn)
+	members, err := m.storage.GetMembers(inn)
+	if err != nil {
+		return fmt.Errorf("get company members: %w", err)
+	}
+
+	if err := m.storage.Delete(inn); err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		m.revokeRole(member.Email)
+	}
+	return nil
 }
 
 // GetCompany возвращает компанию  по инн
 // Если компания не найдена, возвращает ошибку ErrCompanyNotFound.
-func (m *CompanyManager) GetCompany(email string) (*Company, error) {
-	userIsPartner, err := m.UserIsPartner(email)
-
-	if userIsPartner.IsPartner != true {
-		return nil, ErrUserNotPartner
+func (m *CompanyManager) GetCompany(principal rbac.Principal) (*Company, error) {
+	userIsPartner, err := m.requirePermission(principal, rbac.PermCompanyView)
+	if err != nil {
+		return nil, err
 	}
 
 	company, err := m.storage.GetCompanyByInn(userIsPartner.Inn)
@@ -114,14 +180,11 @@ func (m *CompanyManager) CreateCompany(company Company) (*Company, error) {
 }
 
 // GetBranchesByEmail - получение филлиалов компании инн которой получаем по email
-func (m *CompanyManager) GetBranchesByEmail(email string) ([]*CompanyBranch, error) {
-	userIsPartner, err := m.UserIsPartner(email)
+func (m *CompanyManager) GetBranchesByEmail(principal rbac.Principal) ([]*CompanyBranch, error) {
+	userIsPartner, err := m.requirePermission(principal, rbac.PermCompanyView)
 	if err != nil {
 		return nil, err
 	}
-	if userIsPartner.IsPartner != true {
-		return nil, ErrUserNotPartner
-	}
 
 	branches, err := m.storage.GetBranchesByInn(userIsPartner.Inn)
 
@@ -132,11 +195,11 @@ func (m *CompanyManager) GetBranchesByEmail(email string) ([]*CompanyBranch, err
 }
 
 // получение филиала компании по id и email пользователя принадлежащего компании
-func (m *CompanyManager) GetBranchByIdEmail(branch_id uuid.UUID, email string) (CompanyBranchWithServ, error) {
+func (m *CompanyManager) GetBranchByIdEmail(branch_id uuid.UUID, principal rbac.Principal) (CompanyBranchWithServ, error) {
 
-	isPartner, err := m.UserIsPartner(email)
-	if isPartner.IsPartner != true {
-		return CompanyBranchWithServ{}, ErrUserNotPartner
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyView)
+	if err != nil {
+		return CompanyBranchWithServ{}, err
 	}
 
 	branch, err := m.storage.GetBranchByID(branch_id)
@@ -172,14 +235,11 @@ func (m *CompanyManager) GetBranchByIdEmail(branch_id uuid.UUID, email string) (
 // Возвращаемые ошибки:  ErrUserNotPartner, ErrBranchesNotFound
 // ErrBranchServNotFound, ErrBranchServNotAvailable
 // ErrBranchServNotAvailable, ErrServiceDetailsInvalid
-func (m *CompanyManager) GetServDetailsByBranchServId(branchServID uuid.UUID, email string) ([]*ServUpdateResponse, error) {
-	isPartner, err := m.UserIsPartner(email)
+func (m *CompanyManager) GetServDetailsByBranchServId(branchServID uuid.UUID, principal rbac.Principal) ([]*ServUpdateResponse, error) {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyView)
 	if err != nil {
 		return nil, err
 	}
-	if isPartner.IsPartner != true {
-		return nil, ErrUserNotPartner
-	}
 
 	branchServ, err := m.storage.GetBranchServByID(branchServID)
 	if err != nil {
@@ -218,14 +278,11 @@ func (m *CompanyManager) GetServDetailsByBranchServId(branchServID uuid.UUID, em
 }
 
 // GetCompanyOrders возвращает заказы по всем филиалам компании партнёра
-func (m *CompanyManager) GetCompanyOrders(email string) ([]*CompanyBranchOrderResponse, error) {
-	// Проверяем, является ли пользователь партнёром
-	isPartner, err := m.UserIsPartner(email)
+func (m *CompanyManager) GetCompanyOrders(principal rbac.Principal) ([]*CompanyBranchOrderResponse, error) {
+	// Проверяем, является ли пользователь сотрудником с доступом к заказам
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyOrders)
 	if err != nil {
-		return nil, fmt.Errorf("check partner status: %w", err)
-	}
-	if !isPartner.IsPartner {
-		return nil, ErrUserNotPartner
+		return nil, err
 	}
 
 	// Получаем все филиалы компании по ИНН
@@ -266,85 +323,411 @@ func (m *CompanyManager) GetCompanyOrders(email string) ([]*CompanyBranchOrderRe
 	return result, nil
 }
 
-// Проверка что у пользователя есть организация
-// Если у пользователя организация есть вернётся IsParnersUsers{IsPartner: true, Inn: "строка с инн"
-// Если у пользователя организация нет вернётся IsParnersUsers{IsPartner: false, Inn: ничего
-func (m *CompanyManager) UserIsPartner(email string) (IsPartnersUsers, error) {
-	partUser, err := m.storage.GetPartUserByEmail(email)
+// Проверка, что пользователь - сотрудник компании и его роль в компании даёт разрешение.
+// Компания и роль берутся из claims inn и company_role без запроса к БД: при изменении состава
+// или ролей сотрудников токены отзываются (revokeRole), и новый токен выдаётся через /auth/refresh.
+// Для API ключа разрешение должно давать одно из его прав (ключ проверяется APIKeyMiddleware)
+// Возвращаемые ошибки: ErrUserNotPartner, ErrCompanyPermissionDenied
+func (m *CompanyManager) requirePermission(principal rbac.Principal, permission string) (IsPartnersUsers, error) {
+	if principal.INN == "" {
+		return IsPartnersUsers{}, ErrUserNotPartner
+	}
+	if !principal.HasPermission(permission) {
+		return IsPartnersUsers{}, ErrCompanyPermissionDenied
+	}
+	return IsPartnersUsers{IsPartner: true, Inn: principal.INN, Role: principal.CompanyRole, Scopes: principal.Scopes}, nil
+}
+
+// InviteUser приглашает пользователя в компанию с ролью role.
+// Если роль не указана, пользователь приглашается оператором.
+// Приглашённый получает письмо с кодом и присоединяется к компании только после принятия приглашения.
+// Повторное приглашение того же email отзывает предыдущее. Приглашение записывается в журнал действий
+func (m *CompanyManager) InviteUser(principal rbac.Principal, actor audit.Actor, inviteeEmail, role string) (*Invitation, error) {
+	if role == "" {
+		role = rbac.CompanyRoleOperator
+	}
+	if !rbac.IsCompanyRole(role) {
+		return nil, ErrInvalidCompanyRole
+	}
+
+	// Проверка, что приглашающий пользователь - владелец компании
+	userIsPartner, err := m.requirePermission(principal, rbac.PermCompanyMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	company, err := m.storage.GetCompanyByInn(userIsPartner.Inn)
+	if err != nil || company == nil {
+		return nil, ErrCompanyNotFound
+	}
+
+	// Проверка, есть ли пользователь в компании
+	existingPartner, err := m.storage.GetPartUserByEmail(inviteeEmail)
 	if err != nil {
-		return IsPartnersUsers{IsPartner: false}, fmt.Errorf("failed to check if user is partner: %w", err)
+		return nil, fmt.Errorf("failed to check if user is already partner: %w", err)
+	}
+	if existingPartner.Email != "" {
+		return nil, ErrUserAlreadyPartner
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return nil, err
 	}
 
-	if partUser.Email == "" {
-		return IsPartnersUsers{IsPartner: false}, nil
+	invitation := &Invitation{
+		CompanyINN: userIsPartner.Inn,
+		Email:      inviteeEmail,
+		Role:       role,
+		InvitedBy:  principal.Email,
+		ExpiresAt:  time.Now().Add(m.invitationConfig.TTL).UTC(),
 	}
 
-	return IsPartnersUsers{
-		IsPartner: true,
-		Inn:       partUser.Inn,
-	}, nil
+	// Приглашение и письмо с ним сохраняются в одной транзакции: без письма приглашение бесполезно
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		storage := m.storage.WithTx(tx)
+		if err := storage.RevokePendingInvitations(userIsPartner.Inn, inviteeEmail); err != nil {
+			return err
+		}
+		if err := storage.CreateInvitation(invitation, hashInvitationToken(token)); err != nil {
+			return err
+		}
+
+		err := m.emailSender.WithTx(tx).SendCompanyInvitation(inviteeEmail, mail.InvitationData{
+			CompanyName: companyName(company),
+			Role:        role,
+			InvitedBy:   principal.Email,
+			Token:       token,
+			Link:        m.invitationLink(token),
+			ExpiresAt:   invitation.ExpiresAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to queue invitation email: %w", err)
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCompanyMemberInvite, audit.TargetInvitation, invitation.ID.String(), nil, invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invitation, nil
 }
 
-// AddUserToCompany добавляет нового пользователя в компанию
-func (m *CompanyManager) AddUserToCompany(userEmail, newUserEmail string) error {
-	// Проверка, что добавляющий пользователь есть в компании
-	userIsPartner, err := m.UserIsPartner(userEmail)
+// GetPendingInvitations возвращает действующие приглашения компании пользователя
+func (m *CompanyManager) GetPendingInvitations(principal rbac.Principal) ([]*Invitation, error) {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyMembers)
 	if err != nil {
-		return fmt.Errorf("failed to check user email: %w", err)
+		return nil, err
 	}
-	if !userIsPartner.IsPartner {
-		return ErrUserNotPartner
+	return m.storage.GetPendingInvitations(isPartner.Inn)
+}
+
+// RevokeInvitation отзывает приглашение компании пользователя.
+// Возвращаемые ошибки: ErrUserNotPartner, ErrCompanyPermissionDenied, ErrInvitationNotFound
+func (m *CompanyManager) RevokeInvitation(principal rbac.Principal, actor audit.Actor, id uuid.UUID) error {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyMembers)
+	if err != nil {
+		return err
 	}
+	return m.uow.Do(func(tx *sql.Tx) error {
+		storage := m.storage.WithTx(tx)
+		// В журнале остаётся, кого и с какой ролью приглашали
+		invitation, err := storage.GetInvitationForUpdate(isPartner.Inn, id)
+		if err != nil {
+			return err
+		}
+		if err := storage.RevokeInvitation(isPartner.Inn, id); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCompanyMemberRevokeInvite, audit.TargetInvitation, id.String(), invitation, nil)
+	})
+}
 
-	inn := userIsPartner.Inn
+// GetInvitation возвращает приглашение по коду из письма.
+// Доступно без авторизации: код известен только владельцу почтового ящика
+func (m *CompanyManager) GetInvitation(token string) (*InvitationInfo, error) {
+	invitation, err := m.storage.GetInvitationByTokenHash(hashInvitationToken(token))
+	if err != nil {
+		return nil, err
+	}
 
-	// Проверка, что компания с ИНН существует и пользователь находится в компании
-	if userIsPartner.Inn != inn {
-		return errors.New("user does not have access to this company")
+	info := &InvitationInfo{
+		Email:       invitation.Email,
+		CompanyName: invitation.CompanyINN,
+		Role:        invitation.Role,
+		InvitedBy:   invitation.InvitedBy,
+		Status:      invitation.Status,
+		ExpiresAt:   invitation.ExpiresAt,
 	}
 
-	company, err := m.storage.GetCompanyByInn(inn)
+	company, err := m.storage.GetCompanyByInn(invitation.CompanyINN)
+	if err == nil && company != nil {
+		info.CompanyName = companyName(company)
+	}
+
+	user, err := m.userStorage.GetByEmail(invitation.Email)
 	if err != nil {
-		return ErrCompanyNotFound
+		return nil, fmt.Errorf("failed to check user: %w", err)
 	}
-	if company == nil {
-		return ErrCompanyNotFound
+	info.Registered = user != nil
+
+	return info, nil
+}
+
+// AcceptInvitation принимает приглашение по коду из письма.
+// Если пользователь с email приглашения не зарегистрирован, он регистрируется с паролем password:
+// код из письма подтверждает владение email так же, как код регистрации.
+// Возвращаемые ошибки: ErrInvitationNotFound, ErrInvitationExpired, ErrInvitationNotPending,
+// ErrPasswordRequired, ErrUserAlreadyPartner.
+// В журнал действий вступление записывается от имени приглашённого: actor содержит только IP запроса
+func (m *CompanyManager) AcceptInvitation(actor audit.Actor, token, password string) (*Invitation, error) {
+	invitation, err := m.pendingInvitation(token)
+	if err != nil {
+		return nil, err
 	}
 
-	// Проверка, существует ли новый пользователь в системе
-	user, err := m.userStorage.GetByEmail(newUserEmail)
+	user, err := m.userStorage.GetByEmail(invitation.Email)
 	if err != nil {
-		return fmt.Errorf("failed to check user: %w", err)
+		return nil, fmt.Errorf("failed to check user: %w", err)
 	}
-	if user == nil {
-		return errors.New("user not found")
+	if user == nil && password == "" {
+		return nil, ErrPasswordRequired
 	}
 
-	// Проверка, есть ли пользователь в компании
-	existingPartner, err := m.storage.GetPartUserByEmail(newUserEmail)
+	// Регистрация и принятие приглашения в одной транзакции: если приглашение уже принято
+	// или пользователь уже в компании, аккаунт не остаётся созданным без членства
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		if user == nil {
+			if err := m.registrar.CreateVerifiedUser(tx, invitation.Email, password); err != nil {
+				return fmt.Errorf("failed to register invited user: %w", err)
+			}
+		}
+		if err := m.storage.WithTx(tx).AcceptInvitation(invitation.ID); err != nil {
+			return err
+		}
+		actor.Email = invitation.Email
+		return m.auditLog.Record(tx, actor, audit.ActionCompanyMemberJoin, audit.TargetCompanyMember,
+			memberTargetID(invitation.CompanyINN, invitation.Email), nil, CompanyMember{Email: invitation.Email, Role: invitation.Role})
+	})
 	if err != nil {
-		return fmt.Errorf("failed to check if user is already partner: %w", err)
+		return nil, err
 	}
-	if existingPartner.Email != "" {
-		return errors.New("user is already a partner")
+
+	// Пользователь стал партнёром - токен с ролью client нужно обновить
+	m.revokeRole(invitation.Email)
+
+	invitation.Status = InvitationAccepted
+	return invitation, nil
+}
+
+// DeclineInvitation отклоняет приглашение по коду из письма.
+// Возвращаемые ошибки: ErrInvitationNotFound, ErrInvitationExpired, ErrInvitationNotPending
+func (m *CompanyManager) DeclineInvitation(token string) error {
+	invitation, err := m.pendingInvitation(token)
+	if err != nil {
+		return err
+	}
+	return m.storage.DeclineInvitation(invitation.ID)
+}
+
+// Поиск приглашения, ожидающего ответа
+func (m *CompanyManager) pendingInvitation(token string) (*Invitation, error) {
+	invitation, err := m.storage.GetInvitationByTokenHash(hashInvitationToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	switch invitation.Status {
+	case InvitationPending:
+		return invitation, nil
+	case InvitationExpired:
+		return nil, ErrInvitationExpired
+	default:
+		return nil, ErrInvitationNotPending
+	}
+}
+
+// Ссылка на страницу принятия приглашения, если она настроена
+func (m *CompanyManager) invitationLink(token string) string {
+	if m.invitationConfig.AcceptURL == "" {
+		return ""
+	}
+
+	link, err := url.Parse(m.invitationConfig.AcceptURL)
+	if err != nil {
+		log.Printf("company: invalid INVITATION_URL: %v", err)
+		return ""
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// Генерация кода приглашения (32 случайных байта в hex)
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate invitation token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// В БД хранится только хеш кода приглашения
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Название компании для писем
+func companyName(company *Company) string {
+	if company.OrgShortName != nil && *company.OrgShortName != "" {
+		return *company.OrgShortName
+	}
+	if company.OrgName != nil && *company.OrgName != "" {
+		return *company.OrgName
+	}
+	return company.INN
+}
+
+// GetCompanyUsers возвращает сотрудников компании пользователя с их ролями
+func (m *CompanyManager) GetCompanyUsers(principal rbac.Principal) ([]*CompanyMember, error) {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyView)
+	if err != nil {
+		return nil, err
+	}
+	return m.storage.GetMembers(isPartner.Inn)
+}
+
+// UpdateUserRole меняет роль сотрудника компании. Доступно только владельцам.
+// Возвращаемые ошибки: ErrUserNotPartner, ErrCompanyPermissionDenied, ErrInvalidCompanyRole,
+// ErrMemberNotFound, ErrLastOwner
+func (m *CompanyManager) UpdateUserRole(principal rbac.Principal, actor audit.Actor, memberEmail, role string) (*CompanyMember, error) {
+	if !rbac.IsCompanyRole(role) {
+		return nil, ErrInvalidCompanyRole
+	}
+
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	member := &CompanyMember{Email: memberEmail, Role: role}
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		storage := m.storage.WithTx(tx)
+		previous, err := storage.GetMemberForUpdate(isPartner.Inn, memberEmail)
+		if err != nil {
+			return err
+		}
+		if err := storage.UpdateMemberRole(isPartner.Inn, memberEmail, role); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCompanyMemberRole, audit.TargetCompanyMember,
+			memberTargetID(isPartner.Inn, memberEmail), previous, member)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Роль в компании записана в токене сотрудника
+	m.revokeRole(memberEmail)
+
+	return member, nil
+}
+
+// GetMFAPolicy возвращает, обязательна ли 2FA для сотрудников компании пользователя
+func (m *CompanyManager) GetMFAPolicy(principal rbac.Principal) (*MFAPolicy, error) {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyView)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := m.storage.AddUserToPartners(newUserEmail, inn); err != nil {
-		return fmt.Errorf("failed to add user to partners: %w", err)
+	required, err := m.storage.GetMFARequired(isPartner.Inn)
+	if err != nil {
+		return nil, err
+	}
+	return &MFAPolicy{Required: required}, nil
+}
+
+// SetMFAPolicy включает или отключает обязательную 2FA для сотрудников компании.
+// Сотрудники без 2FA подключат её при следующем входе.
+// Возвращаемые ошибки: ErrUserNotPartner, ErrCompanyPermissionDenied
+func (m *CompanyManager) SetMFAPolicy(principal rbac.Principal, actor audit.Actor, required bool) (*MFAPolicy, error) {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyMembers)
+	if err != nil {
+		return nil, err
 	}
 
+	policy := &MFAPolicy{Required: required}
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		if err := m.storage.WithTx(tx).SetMFARequired(isPartner.Inn, required); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCompanyMFAPolicy, audit.TargetCompany, isPartner.Inn, nil, policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("company: mfa requirement for %s set to %t by %s", isPartner.Inn, required, principal.Email)
+	return policy, nil
+}
+
+// RemoveUserFromCompany удаляет сотрудника из компании.
+// Владельцы могут удалить любого сотрудника, остальные - только себя.
+// Последнего владельца удалить нельзя.
+// Возвращаемые ошибки: ErrUserNotPartner, ErrCompanyPermissionDenied, ErrMemberNotFound, ErrLastOwner
+func (m *CompanyManager) RemoveUserFromCompany(principal rbac.Principal, actor audit.Actor, memberEmail string) error {
+	permission := rbac.PermCompanyMembers
+	if strings.EqualFold(principal.Email, memberEmail) {
+		permission = rbac.PermCompanyView
+	}
+
+	isPartner, err := m.requirePermission(principal, permission)
+	if err != nil {
+		return err
+	}
+
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		storage := m.storage.WithTx(tx)
+		removed, err := storage.GetMemberForUpdate(isPartner.Inn, memberEmail)
+		if err != nil {
+			return err
+		}
+		if err := storage.RemoveMember(isPartner.Inn, memberEmail); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCompanyMemberRemove, audit.TargetCompanyMember,
+			memberTargetID(isPartner.Inn, memberEmail), removed, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Пользователь больше не партнёр - токен с ролью partner нужно обновить
+	m.revokeRole(memberEmail)
+
 	return nil
 }
 
+// Объект журнала действий для сотрудника компании: <ИНН>/<email>
+func memberTargetID(inn, email string) string {
+	return inn + "/" + email
+}
+
+// Отзыв access токенов после изменения роли. Ошибка не отменяет изменение
+func (m *CompanyManager) revokeRole(email string) {
+	if err := m.roleRevoker.Revoke(email); err != nil {
+		log.Printf("company: failed to revoke tokens of %s after role change: %v", email, err)
+	}
+}
+
 // AddBranchToCompany добавляет новый филиал в компанию
-func (m *CompanyManager) AddBranchToCompany(userEmail, cityName, address string, open_time, close_time timeparsing.TimeOnly) error {
-	// Проверка, что добавляющий пользователь есть в компании
-	userIsPartner, err := m.UserIsPartner(userEmail)
+func (m *CompanyManager) AddBranchToCompany(principal rbac.Principal, cityName, address string, open_time, close_time timeparsing.TimeOnly) error {
+	// Проверка, что добавляющий пользователь есть в компании и может управлять филиалами
+	userIsPartner, err := m.requirePermission(principal, rbac.PermCompanyManage)
 	if err != nil {
-		return fmt.Errorf("failed to check user email: %w", err)
-	}
-	if !userIsPartner.IsPartner {
-		return ErrUserNotPartner
+		return err
 	}
 
 	inn := userIsPartner.Inn
@@ -396,14 +779,11 @@ func (m *CompanyManager) AddBranchToCompany(userEmail, cityName, address string,
 }
 
 // AddServiceToBranch добавляет новую услугу в филиал
-func (m *CompanyManager) AddServiceToBranch(userEmail string, branch_id, service_id uuid.UUID) error {
-	// Проверка, что добавляющий пользователь есть в компании
-	userIsPartner, err := m.UserIsPartner(userEmail)
+func (m *CompanyManager) AddServiceToBranch(principal rbac.Principal, branch_id, service_id uuid.UUID) error {
+	// Проверка, что добавляющий пользователь есть в компании и может управлять услугами
+	userIsPartner, err := m.requirePermission(principal, rbac.PermCompanyManage)
 	if err != nil {
-		return fmt.Errorf("failed to check user email: %w", err)
-	}
-	if !userIsPartner.IsPartner {
-		return ErrUserNotPartner
+		return err
 	}
 
 	inn := userIsPartner.Inn
@@ -465,7 +845,7 @@ func (m *CompanyManager) AddServiceToBranch(userEmail string, branch_id, service
 }
 
 // обновляет статус заказа со стороны организации с проверками доступа
-func (m *CompanyManager) UpdateOrderStatus(email string, orderId uuid.UUID, statusStr string) (*CompanyOrder, error) {
+func (m *CompanyManager) UpdateOrderStatus(principal rbac.Principal, orderId uuid.UUID, statusStr string) (*CompanyOrder, error) {
 	var status OrderStatus
 
 	if statusStr != "approve" && statusStr != "reject" {
@@ -480,16 +860,12 @@ func (m *CompanyManager) UpdateOrderStatus(email string, orderId uuid.UUID, stat
 		status = OrderStatusReject
 	}
 
-	isPartner, err := m.UserIsPartner(email)
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyOrders)
 	if err != nil {
 		return nil, err
 	}
 
-	if isPartner.IsPartner != true {
-		return nil, ErrUserNotPartner
-	}
-
-	companyOrders, err := m.GetCompanyOrders(email)
+	companyOrders, err := m.GetCompanyOrders(principal)
 
 	if err != nil {
 		return nil, err
@@ -497,12 +873,14 @@ func (m *CompanyManager) UpdateOrderStatus(email string, orderId uuid.UUID, stat
 
 	// Поиск нужного заказа и сбор ID
 	var targetOrder *CompanyOrder
+	var targetBranch uuid.UUID
 	var allOrderIDs []uuid.UUID
 	for _, branch := range companyOrders {
 		for _, order := range branch.Orders {
 			allOrderIDs = append(allOrderIDs, order.ID)
 			if order.ID == orderId {
 				targetOrder = order
+				targetBranch = branch.BranchID
 			}
 		}
 	}
@@ -529,24 +907,85 @@ func (m *CompanyManager) UpdateOrderStatus(email string, orderId uuid.UUID, stat
 		return nil, ErrUpdateStatus
 	}
 
-	updatedOrder, err := m.storage.UpdateOrderStatus(orderId, status)
+	// Статус и события для вебхуков сохраняются в одной транзакции
+	var updatedOrder *CompanyOrder
+	var notify []func()
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		updatedOrder, err = m.storage.WithTx(tx).UpdateOrderStatus(orderId, status)
+		if err != nil {
+			return err
+		}
+		notify, err = m.publishStatusChanged(tx, isPartner.Inn, targetBranch, updatedOrder, currentStatus)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	for _, n := range notify {
+		n()
+	}
+
 	return updatedOrder, nil
 }
 
-// Добавляет деталь услуги из филиала по названию и длительности
-func (m *CompanyManager) AddServiceDetail(branchServID uuid.UUID, email string, getDetail ServDetails, getPrices ServPrice) ([]*ServUpdateResponse, error) {
+// Публикация событий об изменении статуса заказа в транзакции изменения.
+// Отклонение уже подтверждённого заказа дополнительно публикуется как отмена.
+// Возвращает функции отправки событий подписчикам после commit
+func (m *CompanyManager) publishStatusChanged(tx *sql.Tx, inn string, branchID uuid.UUID, order *CompanyOrder, previous OrderStatus) ([]func(), error) {
+	if m.publisher == nil {
+		return nil, nil
+	}
+
+	eventTypes := []string{events.OrderStatusChanged}
+	if previous == OrderStatusApprove && order.Status == OrderStatusReject {
+		eventTypes = append(eventTypes, events.OrderCancelled)
+	}
 
-	isPartner, err := m.UserIsPartner(email)
+	event := OrderStatusChangedEvent{Order: order, PreviousStatus: previous}
+	notify := make([]func(), 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		n, err := m.publisher.PublishTx(tx, inn, branchID, eventType, event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to publish %s: %w", eventType, err)
+		}
+		notify = append(notify, n)
+	}
+	return notify, nil
+}
+
+// OrderStreamScope возвращает ИНН компании пользователя для подписки на поток заказов.
+// Если указан branchID, проверяет, что филиал принадлежит компании
+// Возвращаемые ошибки: ErrUserNotPartner, ErrCompanyPermissionDenied, ErrBranchNotInCompany
+func (m *CompanyManager) OrderStreamScope(principal rbac.Principal, branchID uuid.UUID) (string, error) {
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyOrders)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	if isPartner.IsPartner != true {
-		return nil, ErrUserNotPartner
+	if branchID != uuid.Nil {
+		branch, err := m.storage.GetBranchByID(branchID)
+		if errors.Is(err, ErrBranchNotFound) {
+			return "", ErrBranchNotInCompany
+		}
+		if err != nil {
+			return "", err
+		}
+		if branch.Inn != isPartner.Inn {
+			return "", ErrBranchNotInCompany
+		}
+	}
+
+	return isPartner.Inn, nil
+}
+
+// Добавляет деталь услуги из филиала по названию и длительности
+func (m *CompanyManager) AddServiceDetail(branchServID uuid.UUID, principal rbac.Principal, getDetail ServDetails, getPrices ServPrice) ([]*ServUpdateResponse, error) {
+
+	// Цены меняют только владельцы и менеджеры
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyPrices)
+	if err != nil {
+		return nil, err
 	}
 
 	branchServ, err := m.storage.GetBranchServByID(branchServID)
@@ -619,17 +1058,14 @@ func (m *CompanyManager) AddServiceDetail(branchServID uuid.UUID, email string,
 }
 
 // DeleteServiceDetail удаляет деталь услуги из филиала по названию.
-func (m *CompanyManager) DeleteServiceDetail(branchServID uuid.UUID, email string, nameDetail string) ([]*ServUpdateResponse, error) {
+func (m *CompanyManager) DeleteServiceDetail(branchServID uuid.UUID, principal rbac.Principal, nameDetail string) ([]*ServUpdateResponse, error) {
 
-	isPartner, err := m.UserIsPartner(email)
+	// Цены меняют только владельцы и менеджеры
+	isPartner, err := m.requirePermission(principal, rbac.PermCompanyPrices)
 	if err != nil {
 		return nil, err
 	}
 
-	if isPartner.IsPartner != true {
-		return nil, ErrUserNotPartner
-	}
-
 	branchServ, err := m.storage.GetBranchServByID(branchServID)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,260 @@
+package company
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+	"src/internal/rbac"
+)
+
+// Хранилище, в котором реализованы только методы управления сотрудниками;
+// остальные методы не должны вызываться
+type memberStorage struct {
+	CompanyStorage
+	roles       map[string]string
+	removed     []string
+	invitations map[uuid.UUID]*Invitation
+}
+
+func (s *memberStorage) WithTx(tx *sql.Tx) CompanyStorage {
+	return s
+}
+
+func (s *memberStorage) GetPartUserByEmail(email string) (PartnersUsers, error) {
+	panic("membership must be taken from the token claims")
+}
+
+func (s *memberStorage) GetMemberForUpdate(inn, email string) (*CompanyMember, error) {
+	role, ok := s.roles[strings.ToLower(email)]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	return &CompanyMember{Email: email, Role: role}, nil
+}
+
+func (s *memberStorage) UpdateMemberRole(inn, email, role string) error {
+	if _, ok := s.roles[email]; !ok {
+		return ErrMemberNotFound
+	}
+	s.roles[email] = role
+	return nil
+}
+
+func (s *memberStorage) RemoveMember(inn, email string) error {
+	s.removed = append(s.removed, email)
+	return nil
+}
+
+func (s *memberStorage) GetInvitationForUpdate(inn string, id uuid.UUID) (*Invitation, error) {
+	invitation, ok := s.invitations[id]
+	if !ok || invitation.CompanyINN != inn {
+		return nil, ErrInvitationNotFound
+	}
+	copied := *invitation
+	return &copied, nil
+}
+
+func (s *memberStorage) RevokeInvitation(inn string, id uuid.UUID) error {
+	s.invitations[id].Status = "revoked"
+	return nil
+}
+
+type recordingRevoker struct {
+	revoked []string
+}
+
+func (r *recordingRevoker) Revoke(email string) error {
+	r.revoked = append(r.revoked, email)
+	return nil
+}
+
+// Журнал действий в памяти. err - ошибка записи, которая должна отменить действие
+type recordingAudit struct {
+	entries []audit.Entry
+	err     error
+}
+
+func (a *recordingAudit) Record(tx *sql.Tx, actor audit.Actor, action, targetType, targetID string, before, after any) error {
+	if a.err != nil {
+		return a.err
+	}
+	entry := audit.Entry{Actor: actor.Email, APIKeyID: actor.APIKeyID, IP: actor.IP,
+		Action: action, TargetType: targetType, TargetID: targetID}
+	if before != nil {
+		entry.Before, _ = json.Marshal(before)
+	}
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+// Выполняет fn без транзакции
+type directUnitOfWork struct{}
+
+func (directUnitOfWork) Do(fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+func newMemberManager() (*CompanyManager, *memberStorage, *recordingRevoker) {
+	storage := &memberStorage{
+		roles: map[string]string{
+			"operator@example.com": rbac.CompanyRoleOperator,
+			"manager@example.com":  rbac.CompanyRoleManager,
+		},
+		invitations: map[uuid.UUID]*Invitation{},
+	}
+	revoker := &recordingRevoker{}
+	m := &CompanyManager{storage: storage, roleRevoker: revoker, auditLog: &recordingAudit{}, uow: directUnitOfWork{}}
+	return m, storage, revoker
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  rbac.Principal
+		permission string
+		wantErr    error
+	}{
+		{"not a member", rbac.Principal{Email: "c@example.com", Role: rbac.RoleClient}, rbac.PermCompanyView, ErrUserNotPartner},
+		{"owner", rbac.Principal{Email: "o@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}, rbac.PermCompanyMembers, nil},
+		{"operator", rbac.Principal{Email: "op@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOperator}, rbac.PermCompanyMembers, ErrCompanyPermissionDenied},
+		{"admin member", rbac.Principal{Email: "a@example.com", Role: rbac.RoleAdmin, INN: "7700000000", CompanyRole: rbac.CompanyRoleManager}, rbac.PermCompanyPrices, nil},
+	}
+
+	m, _, _ := newMemberManager()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := m.requirePermission(tt.principal, tt.permission)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("requirePermission error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Inn != tt.principal.INN {
+				t.Errorf("Inn = %q, want %q", got.Inn, tt.principal.INN)
+			}
+		})
+	}
+}
+
+func TestMemberChangesRevokeTokens(t *testing.T) {
+	owner := rbac.Principal{Email: "owner@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+
+	m, storage, revoker := newMemberManager()
+	if _, err := m.UpdateUserRole(owner, audit.Actor{}, "operator@example.com", rbac.CompanyRoleManager); err != nil {
+		t.Fatalf("UpdateUserRole: %v", err)
+	}
+	if storage.roles["operator@example.com"] != rbac.CompanyRoleManager {
+		t.Errorf("role = %q, want manager", storage.roles["operator@example.com"])
+	}
+	if err := m.RemoveUserFromCompany(owner, audit.Actor{}, "operator@example.com"); err != nil {
+		t.Fatalf("RemoveUserFromCompany: %v", err)
+	}
+
+	// Роль в компании хранится в claims, поэтому каждое изменение отзывает токены сотрудника
+	if len(revoker.revoked) != 2 || revoker.revoked[0] != "operator@example.com" || revoker.revoked[1] != "operator@example.com" {
+		t.Errorf("revoked = %v, want operator twice", revoker.revoked)
+	}
+}
+
+func TestMemberChangesRequireOwner(t *testing.T) {
+	manager := rbac.Principal{Email: "manager@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleManager}
+
+	m, storage, revoker := newMemberManager()
+	if _, err := m.UpdateUserRole(manager, audit.Actor{}, "operator@example.com", rbac.CompanyRoleOwner); !errors.Is(err, ErrCompanyPermissionDenied) {
+		t.Fatalf("UpdateUserRole error = %v, want ErrCompanyPermissionDenied", err)
+	}
+	if err := m.RemoveUserFromCompany(manager, audit.Actor{}, "operator@example.com"); !errors.Is(err, ErrCompanyPermissionDenied) {
+		t.Fatalf("RemoveUserFromCompany error = %v, want ErrCompanyPermissionDenied", err)
+	}
+	// Себя может удалить любой сотрудник
+	if err := m.RemoveUserFromCompany(manager, audit.Actor{}, "Manager@example.com"); err != nil {
+		t.Fatalf("RemoveUserFromCompany self: %v", err)
+	}
+	if len(storage.removed) != 1 || len(revoker.revoked) != 1 {
+		t.Errorf("removed = %v, revoked = %v", storage.removed, revoker.revoked)
+	}
+}
+
+func TestMemberChangesAreAudited(t *testing.T) {
+	owner := rbac.Principal{Email: "owner@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+	actor := audit.Actor{Email: owner.Email, IP: "203.0.113.7"}
+
+	m, _, _ := newMemberManager()
+	log := m.auditLog.(*recordingAudit)
+	if _, err := m.UpdateUserRole(owner, actor, "operator@example.com", rbac.CompanyRoleManager); err != nil {
+		t.Fatalf("UpdateUserRole: %v", err)
+	}
+	if err := m.RemoveUserFromCompany(owner, actor, "operator@example.com"); err != nil {
+		t.Fatalf("RemoveUserFromCompany: %v", err)
+	}
+
+	want := []string{audit.ActionCompanyMemberRole, audit.ActionCompanyMemberRemove}
+	if len(log.entries) != len(want) {
+		t.Fatalf("entries = %+v, want %v", log.entries, want)
+	}
+	for i, entry := range log.entries {
+		if entry.Action != want[i] || entry.Actor != actor.Email || entry.IP != actor.IP ||
+			entry.TargetType != audit.TargetCompanyMember || entry.TargetID != "7700000000/operator@example.com" {
+			t.Errorf("entry %d = %+v", i, entry)
+		}
+	}
+	// Прежняя роль сохраняется в снимке до изменения
+	for i, wantRole := range []string{rbac.CompanyRoleOperator, rbac.CompanyRoleManager} {
+		var before CompanyMember
+		if err := json.Unmarshal(log.entries[i].Before, &before); err != nil || before.Role != wantRole {
+			t.Errorf("entry %d before = %s, want role %s", i, log.entries[i].Before, wantRole)
+		}
+	}
+}
+
+func TestRevokeInvitationIsAudited(t *testing.T) {
+	owner := rbac.Principal{Email: "owner@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+	id := uuid.New()
+
+	m, storage, _ := newMemberManager()
+	storage.invitations[id] = &Invitation{ID: id, CompanyINN: owner.INN, Email: "new@example.com", Role: rbac.CompanyRoleOperator, Status: "pending"}
+	log := m.auditLog.(*recordingAudit)
+	if err := m.RevokeInvitation(owner, audit.Actor{Email: owner.Email}, id); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+
+	if len(log.entries) != 1 || log.entries[0].Action != audit.ActionCompanyMemberRevokeInvite || log.entries[0].TargetID != id.String() {
+		t.Fatalf("entries = %+v", log.entries)
+	}
+	var before Invitation
+	if err := json.Unmarshal(log.entries[0].Before, &before); err != nil {
+		t.Fatalf("before = %s: %v", log.entries[0].Before, err)
+	}
+	if before.Email != "new@example.com" || before.Role != rbac.CompanyRoleOperator {
+		t.Errorf("before = %+v", before)
+	}
+
+	// Приглашение другой компании не найдено
+	other := owner
+	other.INN = "7711111111"
+	if err := m.RevokeInvitation(other, audit.Actor{}, id); !errors.Is(err, ErrInvitationNotFound) {
+		t.Errorf("RevokeInvitation of other company error = %v, want ErrInvitationNotFound", err)
+	}
+}
+
+// Без записи в журнале действие не выполняется: ошибка журнала возвращается, токены не отзываются
+func TestMemberChangeFailsWithoutAudit(t *testing.T) {
+	owner := rbac.Principal{Email: "owner@example.com", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+	errAudit := errors.New("audit unavailable")
+
+	m, _, revoker := newMemberManager()
+	m.auditLog = &recordingAudit{err: errAudit}
+	if _, err := m.UpdateUserRole(owner, audit.Actor{}, "operator@example.com", rbac.CompanyRoleManager); !errors.Is(err, errAudit) {
+		t.Fatalf("UpdateUserRole error = %v, want audit error", err)
+	}
+	if err := m.RemoveUserFromCompany(owner, audit.Actor{}, "operator@example.com"); !errors.Is(err, errAudit) {
+		t.Fatalf("RemoveUserFromCompany error = %v, want audit error", err)
+	}
+	if len(revoker.revoked) != 0 {
+		t.Errorf("revoked = %v, want none", revoker.revoked)
+	}
+}
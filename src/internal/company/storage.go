@@ -22,6 +22,11 @@ var (
 	ErrBranchesNotFound     = errors.New("company has no branches")
 	ErrBranchServNotFound   = errors.New("service by branch not found")
 	ErrOrderNotFound        = errors.New("order not found")
+	ErrMemberNotFound       = errors.New("user is not a member of the company")
+	ErrLastOwner            = errors.New("the last owner cannot be removed or demoted")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationNotPending = errors.New("invitation is no longer pending")
+	ErrUserAlreadyPartner   = errors.New("user is already a partner")
 )
 
 // UserStorage интерфейс для проверки существования пользователя
@@ -56,7 +61,33 @@ type CompanyStorage interface {
 
 	GetBranchServByID(branchServID uuid.UUID) (*BranchServ, error)
 
-	AddUserToPartners(email, inn string) error
+	GetMembers(inn string) ([]*CompanyMember, error)
+
+	GetMemberForUpdate(inn, email string) (*CompanyMember, error)
+
+	UpdateMemberRole(inn, email, role string) error
+
+	GetMFARequired(inn string) (bool, error)
+
+	SetMFARequired(inn string, required bool) error
+
+	RemoveMember(inn, email string) error
+
+	CreateInvitation(invitation *Invitation, tokenHash string) error
+
+	GetInvitationByTokenHash(tokenHash string) (*Invitation, error)
+
+	GetPendingInvitations(inn string) ([]*Invitation, error)
+
+	GetInvitationForUpdate(inn string, id uuid.UUID) (*Invitation, error)
+
+	RevokeInvitation(inn string, id uuid.UUID) error
+
+	RevokePendingInvitations(inn, email string) error
+
+	DeclineInvitation(id uuid.UUID) error
+
+	AcceptInvitation(id uuid.UUID) error
 
 	AddNewBranchToCompany(city, address, inn_company string, open_time, close_time timeparsing.TimeOnly) error
 
@@ -75,6 +106,9 @@ type CompanyStorage interface {
 	GetServiceDetailsAndPrice(branchServID uuid.UUID) ([]*ServDetails, []*ServPrice, error)
 
 	UpdateServiceDetails(branchServID uuid.UUID, details json.RawMessage, price json.RawMessage) error
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) CompanyStorage
 }
 
 // PostgresCompanyStorage реализует CompanyStorage для PostgreSQL.
@@ -87,6 +121,11 @@ func NewPostgresCompanyStorage(sqlDB *sql.DB) *PostgresCompanyStorage {
 	return &PostgresCompanyStorage{Storage: db.NewStorage(sqlDB)}
 }
 
+// WithTx возвращает PostgresCompanyStorage, работающий внутри транзакции tx
+func (s *PostgresCompanyStorage) WithTx(tx *sql.Tx) CompanyStorage {
+	return &PostgresCompanyStorage{Storage: s.Storage.WithTx(tx)}
+}
+
 // UpdateServiceDetails обновляет JSONB-поле service_detalis для записи branch_services.
 // Если запись не найдена, возвращает ErrBranchServNotFound.
 func (s *PostgresCompanyStorage) UpdateServiceDetails(branchServID uuid.UUID, details json.RawMessage, price json.RawMessage) error {
@@ -112,6 +151,7 @@ func (s *PostgresCompanyStorage) UpdateServiceDetails(branchServID uuid.UUID, de
 }
 
 // GetServiceDetails возвращает детали услуги по идентификатору записи branch_services.
+// В транзакции строка блокируется до её завершения
 func (s *PostgresCompanyStorage) GetServiceDetailsAndPrice(branchServID uuid.UUID) ([]*ServDetails, []*ServPrice, error) {
 	var detailsRaw, priceRaw []byte
 
@@ -119,6 +159,7 @@ func (s *PostgresCompanyStorage) GetServiceDetailsAndPrice(branchServID uuid.UUI
         SELECT service_detalis, price
         FROM branch_services
         WHERE id = $1
+        FOR UPDATE
     `, branchServID).Scan(&detailsRaw, &priceRaw)
 
 	if err != nil {
@@ -473,12 +514,12 @@ func (s *PostgresCompanyStorage) GetBranchesByInn(inn string) ([]*CompanyBranch,
 func (s *PostgresCompanyStorage) GetPartUserByEmail(email string) (PartnersUsers, error) {
 	var psrtnerUser PartnersUsers
 	row := s.DB.QueryRow(`
-        SELECT email, inn
+        SELECT email, inn, role
         FROM partners_users
         WHERE email = $1
     `, email)
 
-	err := row.Scan(&psrtnerUser.Email, &psrtnerUser.Inn)
+	err := row.Scan(&psrtnerUser.Email, &psrtnerUser.Inn, &psrtnerUser.Role)
 	if err != nil {
 		// Если запись не найдена, возвращаем пустую структуру без ошибки.
 		if errors.Is(err, sql.ErrNoRows) {
@@ -591,19 +632,320 @@ func (s *PostgresCompanyStorage) Create(company Company) (*Company, error) {
 	return &company, nil
 }
 
-// Добавление нового пользователя в компанию
-func (s *PostgresCompanyStorage) AddUserToPartners(email, inn string) error {
-	query := `INSERT INTO partners_users (email, inn) VALUES ($1, $2)`
+// GetMembers возвращает сотрудников компании, сначала владельцев
+func (s *PostgresCompanyStorage) GetMembers(inn string) ([]*CompanyMember, error) {
+	rows, err := s.DB.Query(`
+		SELECT email, role
+		FROM partners_users
+		WHERE inn = $1
+		ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'manager' THEN 1 ELSE 2 END, email
+	`, inn)
+	if err != nil {
+		return nil, fmt.Errorf("query company members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*CompanyMember
+	for rows.Next() {
+		var member CompanyMember
+		if err := rows.Scan(&member.Email, &member.Role); err != nil {
+			return nil, fmt.Errorf("scan company member: %w", err)
+		}
+		members = append(members, &member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return members, nil
+}
+
+// GetMemberForUpdate возвращает сотрудника компании и блокирует его строку до конца транзакции.
+// Вместе с ним блокируются владельцы компании в том же порядке, что и при смене роли или удалении,
+// поэтому параллельные изменения сотрудников не блокируют друг друга крест-накрест.
+// Если сотрудника нет, возвращает ErrMemberNotFound
+func (s *PostgresCompanyStorage) GetMemberForUpdate(inn, email string) (*CompanyMember, error) {
+	rows, err := s.DB.Query(`
+		SELECT email, role FROM partners_users
+		WHERE inn = $1 AND (email = $2 OR role = 'owner')
+		ORDER BY email
+		FOR UPDATE
+	`, inn, email)
+	if err != nil {
+		return nil, fmt.Errorf("lock company member: %w", err)
+	}
+	defer rows.Close()
+
+	var member *CompanyMember
+	for rows.Next() {
+		var row CompanyMember
+		if err := rows.Scan(&row.Email, &row.Role); err != nil {
+			return nil, fmt.Errorf("scan company member: %w", err)
+		}
+		if row.Email == email {
+			member = &row
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+	return member, nil
+}
+
+// UpdateMemberRole меняет роль сотрудника компании.
+// Владельцы компании блокируются на время запроса, поэтому два владельца не могут
+// одновременно понизить друг друга.
+// Возвращает ErrMemberNotFound или ErrLastOwner
+func (s *PostgresCompanyStorage) UpdateMemberRole(inn, email, role string) error {
+	res, err := s.DB.Exec(`
+		WITH owners AS (
+			SELECT email FROM partners_users WHERE inn = $1 AND role = 'owner' FOR UPDATE
+		)
+		UPDATE partners_users SET role = $3
+		WHERE inn = $1 AND email = $2
+		  AND (role <> 'owner' OR $3 = 'owner' OR (SELECT COUNT(*) FROM owners) > 1)
+	`, inn, email, role)
+	if err != nil {
+		return fmt.Errorf("update member role: %w", err)
+	}
+	return s.checkMemberChanged(res, inn, email)
+}
+
+// GetMFARequired возвращает, обязательна ли 2FA для сотрудников компании
+func (s *PostgresCompanyStorage) GetMFARequired(inn string) (bool, error) {
+	var required bool
+	err := s.DB.QueryRow(`SELECT mfa_required FROM companies WHERE inn = $1`, inn).Scan(&required)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrCompanyNotFound
+		}
+		return false, fmt.Errorf("get company mfa policy: %w", err)
+	}
+	return required, nil
+}
+
+// SetMFARequired включает или отключает обязательную 2FA для сотрудников компании
+func (s *PostgresCompanyStorage) SetMFARequired(inn string, required bool) error {
+	res, err := s.DB.Exec(`UPDATE companies SET mfa_required = $2 WHERE inn = $1`, inn, required)
+	if err != nil {
+		return fmt.Errorf("update company mfa policy: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrCompanyNotFound
+	}
+	return nil
+}
+
+// RemoveMember удаляет сотрудника из компании.
+// Возвращает ErrMemberNotFound или ErrLastOwner
+func (s *PostgresCompanyStorage) RemoveMember(inn, email string) error {
+	res, err := s.DB.Exec(`
+		WITH owners AS (
+			SELECT email FROM partners_users WHERE inn = $1 AND role = 'owner' FOR UPDATE
+		)
+		DELETE FROM partners_users
+		WHERE inn = $1 AND email = $2
+		  AND (role <> 'owner' OR (SELECT COUNT(*) FROM owners) > 1)
+	`, inn, email)
+	if err != nil {
+		return fmt.Errorf("delete member: %w", err)
+	}
+	return s.checkMemberChanged(res, inn, email)
+}
+
+// Если запрос не изменил строку, определяет причину: сотрудника нет или он последний владелец
+func (s *PostgresCompanyStorage) checkMemberChanged(res sql.Result, inn, email string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = s.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM partners_users WHERE inn = $1 AND email = $2)`, inn, email).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check member: %w", err)
+	}
+	if !exists {
+		return ErrMemberNotFound
+	}
+	return ErrLastOwner
+}
+
+// Статус приглашения с учётом срока действия
+const invitationColumns = `
+	id, company_inn, email, role, invited_by,
+	CASE WHEN status = 'pending' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+	created_at, expires_at, responded_at
+`
+
+// Сканирование строки company_invitations
+func scanInvitation(row interface{ Scan(dest ...any) error }) (*Invitation, error) {
+	var invitation Invitation
+	err := row.Scan(&invitation.ID, &invitation.CompanyINN, &invitation.Email, &invitation.Role, &invitation.InvitedBy,
+		&invitation.Status, &invitation.CreatedAt, &invitation.ExpiresAt, &invitation.RespondedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
 
-	_, err := s.DB.Exec(query, email, inn)
+// CreateInvitation сохраняет новое приглашение, заполняя ID, статус и время создания
+func (s *PostgresCompanyStorage) CreateInvitation(invitation *Invitation, tokenHash string) error {
+	err := s.DB.QueryRow(`
+		INSERT INTO company_invitations (company_inn, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at
+	`, invitation.CompanyINN, invitation.Email, invitation.Role, tokenHash, invitation.InvitedBy, invitation.ExpiresAt).
+		Scan(&invitation.ID, &invitation.Status, &invitation.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert invitation: %w", err)
+	}
+	return nil
+}
+
+// GetInvitationByTokenHash возвращает приглашение по хешу кода.
+// Если приглашение не найдено, возвращает ErrInvitationNotFound
+func (s *PostgresCompanyStorage) GetInvitationByTokenHash(tokenHash string) (*Invitation, error) {
+	row := s.DB.QueryRow(`SELECT `+invitationColumns+` FROM company_invitations WHERE token_hash = $1`, tokenHash)
+	invitation, err := scanInvitation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+// GetPendingInvitations возвращает действующие приглашения компании, начиная с новых
+func (s *PostgresCompanyStorage) GetPendingInvitations(inn string) ([]*Invitation, error) {
+	rows, err := s.DB.Query(`
+		SELECT `+invitationColumns+`
+		FROM company_invitations
+		WHERE company_inn = $1 AND status = 'pending' AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`, inn)
+	if err != nil {
+		return nil, fmt.Errorf("query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []*Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return invitations, nil
+}
+
+// GetInvitationForUpdate возвращает приглашение компании и блокирует его строку до конца транзакции.
+// Если приглашения нет, возвращает ErrInvitationNotFound
+func (s *PostgresCompanyStorage) GetInvitationForUpdate(inn string, id uuid.UUID) (*Invitation, error) {
+	row := s.DB.QueryRow(`SELECT `+invitationColumns+` FROM company_invitations WHERE id = $1 AND company_inn = $2 FOR UPDATE`, id, inn)
+	invitation, err := scanInvitation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+// RevokeInvitation отзывает ожидающее приглашение компании.
+// Если такого приглашения нет, возвращает ErrInvitationNotFound
+func (s *PostgresCompanyStorage) RevokeInvitation(inn string, id uuid.UUID) error {
+	res, err := s.DB.Exec(`
+		UPDATE company_invitations SET status = 'revoked', responded_at = NOW()
+		WHERE id = $1 AND company_inn = $2 AND status = 'pending'
+	`, id, inn)
+	if err != nil {
+		return fmt.Errorf("revoke invitation: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// RevokePendingInvitations отзывает ожидающие приглашения email в компанию (перед повторным приглашением)
+func (s *PostgresCompanyStorage) RevokePendingInvitations(inn, email string) error {
+	_, err := s.DB.Exec(`
+		UPDATE company_invitations SET status = 'revoked', responded_at = NOW()
+		WHERE company_inn = $1 AND email = $2 AND status = 'pending'
+	`, inn, email)
+	if err != nil {
+		return fmt.Errorf("revoke pending invitations: %w", err)
+	}
+	return nil
+}
+
+// DeclineInvitation отмечает приглашение отклонённым.
+// Если приглашение уже не ожидает ответа или истекло, возвращает ErrInvitationNotPending
+func (s *PostgresCompanyStorage) DeclineInvitation(id uuid.UUID) error {
+	res, err := s.DB.Exec(`
+		UPDATE company_invitations SET status = 'declined', responded_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND expires_at > NOW()
+	`, id)
+	if err != nil {
+		return fmt.Errorf("decline invitation: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrInvitationNotPending
+	}
+	return nil
+}
+
+// AcceptInvitation отмечает приглашение принятым и добавляет пользователя в компанию одним запросом,
+// поэтому приглашение нельзя принять дважды, а при ошибке добавления оно остаётся ожидающим.
+// Возвращает ErrInvitationNotPending или ErrUserAlreadyPartner
+func (s *PostgresCompanyStorage) AcceptInvitation(id uuid.UUID) error {
+	res, err := s.DB.Exec(`
+		WITH accepted AS (
+			UPDATE company_invitations SET status = 'accepted', responded_at = NOW()
+			WHERE id = $1 AND status = 'pending' AND expires_at > NOW()
+			RETURNING email, company_inn, role
+		)
+		INSERT INTO partners_users (email, inn, role)
+		SELECT email, company_inn, role FROM accepted
+	`, id)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-			return fmt.Errorf("user already exists in partners_users")
+			return ErrUserAlreadyPartner
 		}
-		return fmt.Errorf("failed to add user to partners: %w", err)
+		return fmt.Errorf("accept invitation: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if affected == 0 {
+		return ErrInvitationNotPending
 	}
-
 	return nil
 }
 
@@ -632,10 +974,11 @@ func (s *PostgresCompanyStorage) CheckBranchAddressExists(inn_company, address,
 	return exists, nil
 }
 
-// GetServiceByID получает услугу по ID
+// GetServiceByID получает услугу по ID. Архивная услуга не возвращается:
+// её нельзя добавить в филиал
 func (s *PostgresCompanyStorage) GetServiceByID(id uuid.UUID) (*Service, error) {
 	var service Service
-	query := `SELECT id, name FROM services WHERE id = $1`
+	query := `SELECT id, name FROM services WHERE id = $1 AND archived_at IS NULL`
 
 	err := s.DB.QueryRow(query, id).Scan(&service.ID, &service.Name)
 	if err != nil {
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Хранилища кодов подтверждения регистрации и сброса пароля
+const (
+	CodeStoragePostgres = "postgres" // таблицы verification_codes и password_reset_codes
+	CodeStorageRedis    = "redis"    // ключи auth:verification:* и auth:reset:*, требуется REDIS_URL
+	CodeStorageMemory   = "memory"   // память процесса, только для одного экземпляра при разработке
+)
+
+// Минимальная длина CODE_HASH_SECRET
+const minCodeHashSecretLength = 32
+
+// CodeStorageConfig - настройки хранения кодов подтверждения
+type CodeStorageConfig struct {
+	Backend string
+
+	// Ключ HMAC, которым хешируются коды подтверждения и коды восстановления 2FA.
+	// Без ключа короткие коды из утёкшей БД или Redis подбираются перебором
+	HashSecret []byte
+}
+
+// LoadCodeStorageConfig загружает настройки из env.
+// CODE_STORAGE - postgres (по умолчанию), redis или memory.
+// CODE_HASH_SECRET - ключ хеширования кодов, не короче 32 символов, одинаковый на всех экземплярах.
+// Обязателен, кроме APP_ENV=development: там без него создаётся случайный ключ,
+// и коды, выданные до перезапуска, перестают подходить
+func LoadCodeStorageConfig() (*CodeStorageConfig, error) {
+	cfg := &CodeStorageConfig{Backend: os.Getenv("CODE_STORAGE")}
+	if cfg.Backend == "" {
+		cfg.Backend = CodeStoragePostgres
+	}
+
+	switch cfg.Backend {
+	case CodeStoragePostgres, CodeStorageMemory:
+	case CodeStorageRedis:
+		if os.Getenv("REDIS_URL") == "" {
+			return nil, fmt.Errorf("REDIS_URL is required for CODE_STORAGE=redis")
+		}
+	default:
+		return nil, fmt.Errorf("CODE_STORAGE must be postgres, redis or memory, got %q", cfg.Backend)
+	}
+
+	secret := os.Getenv("CODE_HASH_SECRET")
+	switch {
+	case len(secret) >= minCodeHashSecretLength:
+		cfg.HashSecret = []byte(secret)
+	case secret != "":
+		return nil, fmt.Errorf("CODE_HASH_SECRET must be at least %d characters", minCodeHashSecretLength)
+	case IsProduction():
+		return nil, fmt.Errorf("CODE_HASH_SECRET is required outside %s", EnvDevelopment)
+	default:
+		cfg.HashSecret = make([]byte, minCodeHashSecretLength)
+		if _, err := rand.Read(cfg.HashSecret); err != nil {
+			return nil, fmt.Errorf("generate code hash secret: %w", err)
+		}
+		log.Println("WARNING: CODE_HASH_SECRET is not set, using a random key; codes issued before restart will not be accepted")
+	}
+
+	return cfg, nil
+}
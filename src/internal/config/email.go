@@ -1,301 +1,235 @@
 package config
 
 import (
+	"database/sql"
 	"fmt"
-	"net/smtp"
 	"os"
 	"time"
+
+	"src/internal/mail"
+)
+
+// Способы доставки писем
+const (
+	EmailTransportSMTP   = "smtp"   // SMTP-сервер
+	EmailTransportFile   = "file"   // .eml файлы в каталоге
+	EmailTransportMemory = "memory" // почтовый ящик в памяти, с DEV_MAILBOX=true доступен на /dev/mailbox
 )
 
-// Отправка писем через SMTP
-type SMTPEmailService struct {
-	host     string
-	port     string
-	username string
-	password string
-	from     string
+// Количество писем, хранимых почтовым ящиком в памяти
+const mailboxLimit = 200
+
+// EmailConfig - настройки отправки писем
+type EmailConfig struct {
+	Transport string
+	From      string
+	SMTP      mail.SMTPConfig
+	FileDir   string
+
+	// DevMailbox открывает почтовый ящик в памяти на /dev/mailbox без авторизации.
+	// Только для разработки: вместе с APP_ENV=production сервер не запускается
+	DevMailbox bool
 }
 
-// Создание нового экземпляра SMTPEmailService
-func NewSMTPEmailService(host, port, username, password, from string) *SMTPEmailService {
-	return &SMTPEmailService{
-		host:     host,
-		port:     port,
-		username: username,
-		password: password,
-		from:     from,
+// LoadEmailConfig загружает настройки отправки писем из env.
+// EMAIL_TRANSPORT - smtp (по умолчанию), file или memory;
+// SMTP_SECURITY - auto (по умолчанию), starttls, tls или none;
+// EMAIL_FILE_DIR - каталог для .eml файлов (по умолчанию ./mail);
+// DEV_MAILBOX=true - открыть /dev/mailbox (только EMAIL_TRANSPORT=memory и APP_ENV=development)
+func LoadEmailConfig() (*EmailConfig, error) {
+	cfg := &EmailConfig{
+		Transport: os.Getenv("EMAIL_TRANSPORT"),
+		From:      os.Getenv("SMTP_FROM"),
+		SMTP: mail.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USER"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			Security: os.Getenv("SMTP_SECURITY"),
+		},
+		FileDir:    os.Getenv("EMAIL_FILE_DIR"),
+		DevMailbox: os.Getenv("DEV_MAILBOX") == "true",
+	}
+
+	if cfg.Transport == "" {
+		cfg.Transport = EmailTransportSMTP
+	}
+	if cfg.FileDir == "" {
+		cfg.FileDir = "mail"
+	}
+	if cfg.From == "" {
+		cfg.From = "Pioneer <no-reply@localhost>"
+	}
+
+	timeout, err := parseDurationEnv("SMTP_TIMEOUT", 15*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SMTP.Timeout = timeout
+
+	switch cfg.Transport {
+	case EmailTransportSMTP:
+		if cfg.SMTP.Host == "" || cfg.SMTP.Port == "" {
+			return nil, fmt.Errorf("SMTP_HOST and SMTP_PORT are required for EMAIL_TRANSPORT=smtp")
+		}
+	case EmailTransportFile, EmailTransportMemory:
+	default:
+		return nil, fmt.Errorf("EMAIL_TRANSPORT must be smtp, file or memory, got %q", cfg.Transport)
 	}
+
+	// Письма в памяти теряются при перезапуске, а /dev/mailbox открыт всем - в production это недопустимо
+	if IsProduction() {
+		if cfg.Transport == EmailTransportMemory {
+			return nil, fmt.Errorf("EMAIL_TRANSPORT=memory is not allowed in production, set APP_ENV=development for local runs")
+		}
+		if cfg.DevMailbox {
+			return nil, fmt.Errorf("DEV_MAILBOX must not be enabled in production, set APP_ENV=development for local runs")
+		}
+	}
+	if cfg.DevMailbox && cfg.Transport != EmailTransportMemory {
+		return nil, fmt.Errorf("DEV_MAILBOX requires EMAIL_TRANSPORT=memory")
+	}
+
+	return cfg, nil
 }
 
-// Создает и настраивает SMTP сервис из env
-func ConnectSMTP() (*SMTPEmailService, error) {
-	host := os.Getenv("SMTP_HOST")
-	port := os.Getenv("SMTP_PORT")
-	username := os.Getenv("SMTP_USER")
-	password := os.Getenv("SMTP_PASSWORD")
-	from := os.Getenv("SMTP_FROM")
-
-	return &SMTPEmailService{
-		host:     host,
-		port:     port,
-		username: username,
-		password: password,
-		from:     from,
-	}, nil
+// NewEmailTransport создаёт способ доставки писем по конфигурации.
+// Для memory дополнительно возвращает почтовый ящик, иначе nil
+func NewEmailTransport(cfg *EmailConfig) (mail.Transport, *mail.Mailbox, error) {
+	switch cfg.Transport {
+	case EmailTransportFile:
+		transport, err := mail.NewFileTransport(cfg.FileDir)
+		return transport, nil, err
+	case EmailTransportMemory:
+		mailbox := mail.NewMailbox(mailboxLimit)
+		return mailbox, mailbox, nil
+	default:
+		transport, err := mail.NewSMTPTransport(cfg.SMTP)
+		return transport, nil, err
+	}
+}
+
+// Загружает шаблоны писем.
+// EMAIL_TEMPLATES_DIR - каталог с шаблонами вместо встроенных (позволяет менять тексты без пересборки),
+// EMAIL_LANG - язык писем по умолчанию (ru или en)
+func LoadMailRenderer() (*mail.Renderer, error) {
+	templates := mail.DefaultTemplates()
+	if dir := os.Getenv("EMAIL_TEMPLATES_DIR"); dir != "" {
+		templates = os.DirFS(dir)
+	}
+
+	lang := os.Getenv("EMAIL_LANG")
+	if lang == "" {
+		lang = mail.LangRU
+	}
+
+	return mail.NewRenderer(templates, lang)
 }
 
 // Интерфейс для отправки email
 type EmailSender interface {
 	SendVerificationCode(toEmail, code string) error
 	SendVerificationResetCode(toEmail, code string) error
+	SendCompanyInvitation(toEmail string, data mail.InvitationData) error
+	SendTokenReuseAlert(toEmail string, data mail.TokenReuseData) error
+	SendAccountLocked(toEmail string, data mail.AccountLockedData) error
+	SendEmailChangeCode(toEmail, code string) error
+	SendAccountSuspended(toEmail string, data mail.SuspensionData) error
+	SendAccountReinstated(toEmail string, data mail.SuspensionData) error
+	SendPartnerRequestStatus(toEmail string, data mail.PartnerRequestData) error
+
+	// WithLang возвращает EmailSender, формирующий письма на языке lang.
+	// Пустой lang - язык, сохранённый у получателя, или язык по умолчанию
+	WithLang(lang string) EmailSender
 }
 
-// Отправление кода подтверждения для регистрации
-func (s *SMTPEmailService) SendVerificationCode(toEmail, code string) error {
-	subject := "Код подтверждения регистрации"
-
-	// Тело письма
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-  <head>
-    <meta charset="UTF-8">
-  </head>
-
-  <body style="margin:0; padding:0; background:#0f1c1e; font-family:Arial, sans-serif; color:white;">
-
-    <table width="100%%" cellpadding="0" cellspacing="0" style="padding:40px 0;">
-      <tr>
-        <td align="center">
-
-          <table width="520" cellpadding="0" cellspacing="0" style="background:#1c2a2c; border-radius:16px; overflow:hidden;">
-
-            <tr>
-              <td style="padding:30px; text-align:center; background:rgb(22,71,71);">
-                <div style="font-size:26px; font-weight:bold; letter-spacing:2px;">
-                  PIONEER
-                </div>
-              </td>
-            </tr>
-
-            <tr>
-              <td style="padding:35px 40px; text-align:left;">
-
-                <h2 style="margin-top:0; color:white;">
-                  Код подтверждения
-                </h2>
-
-                <p style="color:#cfd8dc; line-height:1.6;">
-                  Чтобы завершить регистрацию, используйте следующий код подтверждения:
-                </p>
-
-                <div style="
-                  font-size:40px;
-                  font-weight:bold;
-                  letter-spacing:8px;
-                  margin:30px 0;
-                  padding:18px 25px;
-                  background:rgb(22,71,71);
-                  border-radius:10px;
-                  text-align:center;
-                ">
-                  %s
-                </div>
-
-                <p style="color:#cfd8dc;">
-                  Код действителен в течение <b>10 минут</b>.
-                </p>
-
-                <p style="color:#cfd8dc; margin-top:30px;">
-                  С уважением,<br>
-                  <b>Pioneer</b>
-                </p>
-
-              </td>
-            </tr>
-
-            <tr>
-              <td style="padding:20px; text-align:center; font-size:12px; color:#9ea7aa; background:#182426;">
-                Если вы не регистрировались, просто проигнорируйте это письмо.
-              </td>
-            </tr>
-
-            <tr>
-              <td style="padding:20px; text-align:center; font-size:12px; color:#9ea7aa;">
-                © %d Pioneer
-              </td>
-            </tr>
-
-          </table>
-
-        </td>
-      </tr>
-    </table>
-
-  </body>
-</html>
-`, code, time.Now().Year())
-
-	// Заголовки
-	headers := make(map[string]string)
-	headers["From"] = s.from
-	headers["To"] = toEmail
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
-
-	// Сборка сообщения
-	message := ""
-	for k, v := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
-	}
-	message += "\r\n" + body
+// TxEmailSender ставит письма в очередь в транзакции вызывающего кода:
+// письмо уходит, только если изменения, о которых оно сообщает, зафиксированы
+type TxEmailSender interface {
+	EmailSender
+	WithTx(tx *sql.Tx) EmailSender
+}
 
-	// Аутентификация
-	auth := smtp.PlainAuth("", s.username, s.password, s.host)
+// EmailService формирует письма по шаблонам и передаёт их выбранному способу доставки
+type EmailService struct {
+	from      string
+	lang      string
+	renderer  *mail.Renderer
+	transport mail.Transport
+}
 
-	// Адрес сервера
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
+// Создание нового экземпляра EmailService
+func NewEmailService(from string, renderer *mail.Renderer, transport mail.Transport) *EmailService {
+	return &EmailService{
+		from:      from,
+		renderer:  renderer,
+		transport: transport,
+	}
+}
 
-	// Отправление с таймаутом
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- smtp.SendMail(addr, auth, s.from, []string{toEmail}, []byte(message))
-	}()
+// WithLang возвращает EmailService, формирующий письма на языке lang
+func (s *EmailService) WithLang(lang string) EmailSender {
+	copy := *s
+	copy.lang = lang
+	return &copy
+}
 
-	// Ожидание ответа (15 секунд)
-	select {
-	case err := <-errChan:
-		if err != nil {
-			return fmt.Errorf("ошибка отправки email: %w", err)
-		}
-		return nil
-	case <-time.After(15 * time.Second):
-		return fmt.Errorf("таймаут отправки email")
-	}
+// Отправление кода подтверждения для регистрации
+func (s *EmailService) SendVerificationCode(toEmail, code string) error {
+	return s.send(toEmail, mail.TemplateVerificationCode, mail.CodeData{Code: code})
 }
 
 // Отправление кода подтверждения для восстановления пароля
-func (s *SMTPEmailService) SendVerificationResetCode(toEmail, code string) error {
-	subject := "Код подтверждения для восстановления пароля"
-
-	// Тело письма
-	body := fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-  <head>
-    <meta charset="UTF-8">
-  </head>
-
-  <body style="margin:0; padding:0; background:#0f1c1e; font-family:Arial, sans-serif; color:white;">
-
-    <table width="100%%" cellpadding="0" cellspacing="0" style="padding:40px 0;">
-      <tr>
-        <td align="center">
-
-          <table width="520" cellpadding="0" cellspacing="0" style="background:#1c2a2c; border-radius:16px; overflow:hidden;">
-
-            <tr>
-              <td style="padding:30px; text-align:center; background:rgb(22,71,71);">
-                <div style="font-size:26px; font-weight:bold; letter-spacing:2px;">
-                  PIONEER
-                </div>
-              </td>
-            </tr>
-
-            <tr>
-              <td style="padding:35px 40px; text-align:left;">
-
-                <h2 style="margin-top:0; color:white;">
-                  Восстановление пароля
-                </h2>
-
-                <p style="color:#cfd8dc; line-height:1.6;">
-                  Для восстановления пароля используйте следующий код подтверждения:
-                </p>
-
-                <div style="
-                  font-size:40px;
-                  font-weight:bold;
-                  letter-spacing:8px;
-                  margin:30px 0;
-                  padding:18px 25px;
-                  background:rgb(22,71,71);
-                  border-radius:10px;
-                  text-align:center;
-                ">
-                  %s
-                </div>
-
-                <p style="color:#cfd8dc;">
-                  Код действителен в течение <b>10 минут</b>.
-                </p>
-
-                <p style="color:#cfd8dc; margin-top:30px;">
-                  С уважением,<br>
-                  <b>Pioneer</b>
-                </p>
-
-              </td>
-            </tr>
-
-            <tr>
-              <td style="padding:20px; text-align:center; font-size:12px; color:#9ea7aa; background:#182426;">
-                Если вы не запрашивали восстановление пароля, просто проигнорируйте это письмо.
-              </td>
-            </tr>
-
-            <tr>
-              <td style="padding:20px; text-align:center; font-size:12px; color:#9ea7aa;">
-                © %d Pioneer
-              </td>
-            </tr>
-
-          </table>
-
-        </td>
-      </tr>
-    </table>
-
-  </body>
-</html>
-`, code, time.Now().Year())
-
-	// Заголовки
-	headers := make(map[string]string)
-	headers["From"] = s.from
-	headers["To"] = toEmail
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
-
-	// Сборка сообщения
-	message := ""
-	for k, v := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
-	}
-	message += "\r\n" + body
+func (s *EmailService) SendVerificationResetCode(toEmail, code string) error {
+	return s.send(toEmail, mail.TemplateResetCode, mail.CodeData{Code: code})
+}
+
+// Отправление приглашения в компанию
+func (s *EmailService) SendCompanyInvitation(toEmail string, data mail.InvitationData) error {
+	return s.send(toEmail, mail.TemplateInvitation, data)
+}
 
-	// Аутентификация
-	auth := smtp.PlainAuth("", s.username, s.password, s.host)
+// Отправление предупреждения о повторном использовании refresh токена
+func (s *EmailService) SendTokenReuseAlert(toEmail string, data mail.TokenReuseData) error {
+	return s.send(toEmail, mail.TemplateTokenReuse, data)
+}
 
-	// Адрес сервера
-	addr := fmt.Sprintf("%s:%s", s.host, s.port)
+// Отправление уведомления о временной блокировке входа
+func (s *EmailService) SendAccountLocked(toEmail string, data mail.AccountLockedData) error {
+	return s.send(toEmail, mail.TemplateAccountLocked, data)
+}
 
-	// Отправление с таймаутом
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- smtp.SendMail(addr, auth, s.from, []string{toEmail}, []byte(message))
-	}()
+// Отправление кода подтверждения нового адреса почты
+func (s *EmailService) SendEmailChangeCode(toEmail, code string) error {
+	return s.send(toEmail, mail.TemplateEmailChangeCode, mail.CodeData{Code: code})
+}
 
-	// Ожидание ответа (15 секунд)
-	select {
-	case err := <-errChan:
-		if err != nil {
-			return fmt.Errorf("ошибка отправки email: %w", err)
-		}
-		return nil
-	case <-time.After(15 * time.Second):
-		return fmt.Errorf("таймаут отправки email")
+// Отправление уведомления о приостановке аккаунта или компании
+func (s *EmailService) SendAccountSuspended(toEmail string, data mail.SuspensionData) error {
+	return s.send(toEmail, mail.TemplateSuspended, data)
+}
+
+// Отправление уведомления о возобновлении аккаунта или компании
+func (s *EmailService) SendAccountReinstated(toEmail string, data mail.SuspensionData) error {
+	return s.send(toEmail, mail.TemplateReinstated, data)
+}
+
+// Отправление уведомления о смене статуса заявки на подключение организации
+func (s *EmailService) SendPartnerRequestStatus(toEmail string, data mail.PartnerRequestData) error {
+	return s.send(toEmail, mail.TemplatePartnerRequest, data)
+}
+
+// Формирование письма по шаблону и отправка
+func (s *EmailService) send(toEmail, templateName string, data any) error {
+	msg, err := s.renderer.Render(templateName, s.lang, data)
+	if err != nil {
+		return fmt.Errorf("ошибка формирования письма: %w", err)
+	}
+	msg.From = s.from
+	msg.To = toEmail
+
+	if err := s.transport.Send(msg); err != nil {
+		return fmt.Errorf("ошибка отправки email: %w", err)
 	}
+	return nil
 }
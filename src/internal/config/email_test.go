@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+
+	"src/internal/mail"
+)
+
+func TestEmailServiceWithLang(t *testing.T) {
+	renderer, err := mail.NewRenderer(mail.DefaultTemplates(), mail.LangRU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lang        string
+		wantSubject string
+	}{
+		{"", "Код подтверждения регистрации"},
+		{mail.LangEN, "Your registration code"},
+	}
+	for _, tt := range tests {
+		mailbox := mail.NewMailbox(10)
+		service := NewEmailService("noreply@pioneer.ru", renderer, mailbox)
+
+		if err := service.WithLang(tt.lang).SendVerificationCode("user@mail.ru", "A1B2C3"); err != nil {
+			t.Fatalf("SendVerificationCode(%q): %v", tt.lang, err)
+		}
+		entries := mailbox.List("user@mail.ru")
+		if len(entries) != 1 || entries[0].Subject != tt.wantSubject {
+			t.Errorf("lang %q: got %+v, want subject %q", tt.lang, entries, tt.wantSubject)
+		}
+	}
+}
+
+func TestLoadEmailConfigDevMailbox(t *testing.T) {
+	tests := []struct {
+		name       string
+		appEnv     string
+		transport  string
+		devMailbox string
+		wantErr    bool
+		wantOpen   bool
+	}{
+		{name: "development mailbox", appEnv: EnvDevelopment, transport: EmailTransportMemory, devMailbox: "true", wantOpen: true},
+		{name: "memory transport without flag", appEnv: EnvDevelopment, transport: EmailTransportMemory},
+		{name: "mailbox needs memory transport", appEnv: EnvDevelopment, transport: EmailTransportFile, devMailbox: "true", wantErr: true},
+		{name: "mailbox in production", appEnv: EnvProduction, transport: EmailTransportMemory, devMailbox: "true", wantErr: true},
+		{name: "memory transport in production", appEnv: EnvProduction, transport: EmailTransportMemory, wantErr: true},
+		{name: "production by default", transport: EmailTransportMemory, devMailbox: "true", wantErr: true},
+		{name: "file transport in production", appEnv: EnvProduction, transport: EmailTransportFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("EMAIL_TRANSPORT", tt.transport)
+			t.Setenv("DEV_MAILBOX", tt.devMailbox)
+
+			cfg, err := LoadEmailConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadEmailConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.DevMailbox != tt.wantOpen {
+				t.Errorf("DevMailbox = %v, want %v", cfg.DevMailbox, tt.wantOpen)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package config
+
+import "os"
+
+// Окружения запуска (APP_ENV)
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// AppEnv возвращает окружение запуска из APP_ENV. По умолчанию production:
+// отладочные возможности включаются только явным APP_ENV=development
+func AppEnv() string {
+	if os.Getenv("APP_ENV") == EnvDevelopment {
+		return EnvDevelopment
+	}
+	return EnvProduction
+}
+
+// IsProduction сообщает, что сервер запущен в production
+func IsProduction() bool {
+	return AppEnv() == EnvProduction
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Настройки приглашений в компанию
+type InvitationConfig struct {
+	TTL time.Duration
+
+	// Адрес страницы принятия приглашения, к нему добавляется параметр token.
+	// Если не задан, в письме передаётся только код приглашения
+	AcceptURL string
+}
+
+// Загрузка конфигурации приглашений из env
+func LoadInvitationConfig() (*InvitationConfig, error) {
+	ttl, err := parseDurationEnv("INVITATION_TTL", 7*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid INVITATION_TTL: %w", err)
+	}
+
+	return &InvitationConfig{
+		TTL:       ttl,
+		AcceptURL: os.Getenv("INVITATION_URL"),
+	}, nil
+}
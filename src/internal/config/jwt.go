@@ -1,23 +1,29 @@
 package config
 
 import (
+	"crypto"
 	"fmt"
+	"log"
 	"os"
+	"strings"
 	"time"
+
+	"src/internal/jwtkeys"
 )
 
 // Настройки для JWT
 type JWTConfig struct {
-	SecretKey       string
+	Keys            *jwtkeys.KeySet
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	VerificationTTL time.Duration
+
+	// Период синхронизации отозванных токенов между экземплярами приложения
+	RevocationSyncInterval time.Duration
 }
 
 // Загрузка конфигурации JWT из env
 func LoadJWTConfig() (*JWTConfig, error) {
-	secretKey := os.Getenv("JWT_SECRET")
-
 	accessTTL, err := parseDurationEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
 	if err != nil {
 		return nil, fmt.Errorf("invalid ACCESS_TOKEN_TTL: %w", err)
@@ -33,14 +39,94 @@ func LoadJWTConfig() (*JWTConfig, error) {
 		return nil, fmt.Errorf("invalid VERIFICATION_TTL: %w", err)
 	}
 
+	revocationSync, err := parseDurationEnv("REVOCATION_SYNC_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REVOCATION_SYNC_INTERVAL: %w", err)
+	}
+
+	keys, err := loadJWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
 	return &JWTConfig{
-		SecretKey:       secretKey,
-		AccessTokenTTL:  accessTTL,
-		RefreshTokenTTL: refreshTTL,
-		VerificationTTL: verificationTTL,
+		Keys:                   keys,
+		AccessTokenTTL:         accessTTL,
+		RefreshTokenTTL:        refreshTTL,
+		VerificationTTL:        verificationTTL,
+		RevocationSyncInterval: revocationSync,
 	}, nil
 }
 
+// Ключи подписи токенов. JWT_PRIVATE_KEY_FILE - закрытый ключ RSA (RS256) или Ed25519 (EdDSA) в PEM,
+// которым подписываются новые токены. JWT_PUBLIC_KEY_FILES - открытые ключи через запятую,
+// которые принимаются при проверке наравне с текущим (предыдущий и следующий ключ при ротации).
+// JWT_SECRET - общий секрет HS256 прежней схемы: им только проверяются токены без kid до момента
+// JWT_SECRET_ACCEPT_UNTIL (RFC 3339). Срок задаётся явно, чтобы перезапуск не продлевал переход
+func loadJWTKeys() (*jwtkeys.KeySet, error) {
+	secret := os.Getenv("JWT_SECRET")
+	privateKeyFile := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if privateKeyFile == "" {
+		if secret != "" {
+			return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required: tokens are no longer signed with JWT_SECRET")
+		}
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required")
+	}
+
+	var secretUntil time.Time
+	if secret != "" {
+		value := os.Getenv("JWT_SECRET_ACCEPT_UNTIL")
+		if value == "" {
+			return nil, fmt.Errorf("JWT_SECRET_ACCEPT_UNTIL is required with JWT_SECRET")
+		}
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_SECRET_ACCEPT_UNTIL: %w", err)
+		}
+		secretUntil = until
+	}
+
+	data, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT_PRIVATE_KEY_FILE: %w", err)
+	}
+	signer, err := jwtkeys.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_PRIVATE_KEY_FILE: %w", err)
+	}
+
+	var publicKeys []crypto.PublicKey
+	for _, file := range strings.Split(os.Getenv("JWT_PUBLIC_KEY_FILES"), ",") {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_PUBLIC_KEY_FILES: %w", err)
+		}
+		publicKey, err := jwtkeys.ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s in JWT_PUBLIC_KEY_FILES: %w", file, err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	keys, err := jwtkeys.NewKeySet(signer, publicKeys...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt keys: %w", err)
+	}
+	if secret != "" {
+		keys.AcceptSecret([]byte(secret), secretUntil)
+		if time.Now().After(secretUntil) {
+			log.Printf("WARNING: JWT_SECRET_ACCEPT_UNTIL %s has passed, HS256 tokens are rejected; remove JWT_SECRET", secretUntil.Format(time.RFC3339))
+		} else {
+			log.Printf("WARNING: JWT_SECRET is set, HS256 tokens are accepted until %s, remove JWT_SECRET after that", secretUntil.Format(time.RFC3339))
+		}
+	}
+	return keys, nil
+}
+
 // Функция для корректного переноса длительности из env
 func parseDurationEnv(key string, defaultValue time.Duration) (time.Duration, error) {
 	value := os.Getenv(key)
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"src/internal/jwtkeys"
+)
+
+func writeKeyFiles(t *testing.T) (privateFile, publicFile string) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshal private key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	privateFile = filepath.Join(dir, "jwt_private.pem")
+	publicFile = filepath.Join(dir, "jwt_public.pem")
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		t.Fatalf("write private key: %v", err)
+	}
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+	return privateFile, publicFile
+}
+
+func setJWTEnv(t *testing.T, privateFile, publicFiles, secret, secretUntil string) {
+	t.Helper()
+	t.Setenv("JWT_PRIVATE_KEY_FILE", privateFile)
+	t.Setenv("JWT_PUBLIC_KEY_FILES", publicFiles)
+	t.Setenv("JWT_SECRET", secret)
+	t.Setenv("JWT_SECRET_ACCEPT_UNTIL", secretUntil)
+}
+
+// Токен прежней схемы: HS256 без kid
+func legacyToken(t *testing.T, secret string) string {
+	t.Helper()
+	claims := jwt.MapClaims{"type": "access", "exp": time.Now().Add(time.Minute).Unix()}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func verify(keys *jwtkeys.KeySet, token string) error {
+	_, err := jwt.Parse(token, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
+	return err
+}
+
+func TestLoadJWTConfigRequiresKey(t *testing.T) {
+	setJWTEnv(t, "", "", "", "")
+	if _, err := LoadJWTConfig(); err == nil {
+		t.Fatal("expected error without JWT_PRIVATE_KEY_FILE and JWT_SECRET")
+	}
+
+	setJWTEnv(t, filepath.Join(t.TempDir(), "missing.pem"), "", "", "")
+	if _, err := LoadJWTConfig(); err == nil {
+		t.Fatal("expected error for missing key file")
+	}
+}
+
+func TestLoadJWTConfigPrivateKey(t *testing.T) {
+	privateFile, publicFile := writeKeyFiles(t)
+	_, nextPublicFile := writeKeyFiles(t)
+	setJWTEnv(t, privateFile, publicFile+", "+nextPublicFile, "", "")
+
+	cfg, err := LoadJWTConfig()
+	if err != nil {
+		t.Fatalf("LoadJWTConfig: %v", err)
+	}
+	if cfg.Keys.SigningKeyID() == "" {
+		t.Fatal("signing key id is empty")
+	}
+	// Открытый ключ текущего ключа подписи не дублируется
+	if n := len(cfg.Keys.JWKS().Keys); n != 2 {
+		t.Errorf("jwks has %d keys, want 2", n)
+	}
+	if err := verify(cfg.Keys, legacyToken(t, "legacy-secret")); err == nil {
+		t.Error("HS256 token accepted without JWT_SECRET")
+	}
+}
+
+// Без закрытого ключа приложение не запускается: токены общим секретом больше не подписываются
+func TestLoadJWTConfigSecretOnly(t *testing.T) {
+	setJWTEnv(t, "", "", "legacy-secret", time.Now().Add(time.Hour).Format(time.RFC3339))
+	if _, err := LoadJWTConfig(); err == nil {
+		t.Fatal("expected error with JWT_SECRET and without JWT_PRIVATE_KEY_FILE")
+	}
+}
+
+// Токены JWT_SECRET принимаются до JWT_SECRET_ACCEPT_UNTIL, а не в течение срока от запуска
+func TestLoadJWTConfigSecretTransition(t *testing.T) {
+	privateFile, _ := writeKeyFiles(t)
+
+	t.Run("within transition", func(t *testing.T) {
+		setJWTEnv(t, privateFile, "", "legacy-secret", time.Now().Add(time.Hour).Format(time.RFC3339))
+
+		cfg, err := LoadJWTConfig()
+		if err != nil {
+			t.Fatalf("LoadJWTConfig: %v", err)
+		}
+		if cfg.Keys.SigningKeyID() == "" {
+			t.Error("tokens are not signed with the private key")
+		}
+		if n := len(cfg.Keys.JWKS().Keys); n != 1 {
+			t.Errorf("jwks has %d keys, want 1", n)
+		}
+		if err := verify(cfg.Keys, legacyToken(t, "legacy-secret")); err != nil {
+			t.Errorf("legacy token rejected during transition: %v", err)
+		}
+	})
+
+	t.Run("after transition", func(t *testing.T) {
+		setJWTEnv(t, privateFile, "", "legacy-secret", time.Now().Add(-time.Minute).Format(time.RFC3339))
+
+		cfg, err := LoadJWTConfig()
+		if err != nil {
+			t.Fatalf("LoadJWTConfig: %v", err)
+		}
+		if err := verify(cfg.Keys, legacyToken(t, "legacy-secret")); err == nil {
+			t.Error("legacy token accepted after JWT_SECRET_ACCEPT_UNTIL")
+		}
+	})
+
+	// Долгий REFRESH_TOKEN_TTL не продлевает переход
+	t.Run("refresh ttl is ignored", func(t *testing.T) {
+		setJWTEnv(t, privateFile, "", "legacy-secret", time.Now().Add(-time.Minute).Format(time.RFC3339))
+		t.Setenv("REFRESH_TOKEN_TTL", "720h")
+
+		cfg, err := LoadJWTConfig()
+		if err != nil {
+			t.Fatalf("LoadJWTConfig: %v", err)
+		}
+		if err := verify(cfg.Keys, legacyToken(t, "legacy-secret")); err == nil {
+			t.Error("legacy token accepted after JWT_SECRET_ACCEPT_UNTIL")
+		}
+	})
+
+	t.Run("accept until is required", func(t *testing.T) {
+		setJWTEnv(t, privateFile, "", "legacy-secret", "")
+		if _, err := LoadJWTConfig(); err == nil {
+			t.Error("expected error without JWT_SECRET_ACCEPT_UNTIL")
+		}
+
+		setJWTEnv(t, privateFile, "", "legacy-secret", "next week")
+		if _, err := LoadJWTConfig(); err == nil {
+			t.Error("expected error for invalid JWT_SECRET_ACCEPT_UNTIL")
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Настройки защиты входа от перебора паролей
+type LoginProtectionConfig struct {
+	// Количество неудачных попыток без задержки. Далее перед каждой попыткой нужно
+	// подождать BaseDelay, удваивающийся с каждой неудачей, но не больше MaxDelay
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+
+	// Количество неудачных попыток подряд, после которого вход в аккаунт блокируется на LockoutDuration
+	LockoutThreshold int
+	// Количество неудачных попыток с одного IP адреса (по любым аккаунтам), после которого он блокируется
+	IPLockoutThreshold int
+	LockoutDuration    time.Duration
+
+	// Неудачные попытки старше этого периода не учитываются
+	FailureWindow time.Duration
+}
+
+// Загрузка конфигурации защиты входа из env
+func LoadLoginProtectionConfig() (*LoginProtectionConfig, error) {
+	delayAfter, err := parseIntEnv("LOGIN_DELAY_AFTER", 3)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_DELAY_AFTER: %w", err)
+	}
+
+	baseDelay, err := parseDurationEnv("LOGIN_BASE_DELAY", time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_BASE_DELAY: %w", err)
+	}
+
+	maxDelay, err := parseDurationEnv("LOGIN_MAX_DELAY", time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_MAX_DELAY: %w", err)
+	}
+
+	lockoutThreshold, err := parseIntEnv("LOGIN_LOCKOUT_THRESHOLD", 10)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_THRESHOLD: %w", err)
+	}
+
+	ipLockoutThreshold, err := parseIntEnv("LOGIN_IP_LOCKOUT_THRESHOLD", 50)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_IP_LOCKOUT_THRESHOLD: %w", err)
+	}
+
+	lockoutDuration, err := parseDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_DURATION: %w", err)
+	}
+
+	failureWindow, err := parseDurationEnv("LOGIN_FAILURE_WINDOW", time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_FAILURE_WINDOW: %w", err)
+	}
+
+	return &LoginProtectionConfig{
+		DelayAfter:         delayAfter,
+		BaseDelay:          baseDelay,
+		MaxDelay:           maxDelay,
+		LockoutThreshold:   lockoutThreshold,
+		IPLockoutThreshold: ipLockoutThreshold,
+		LockoutDuration:    lockoutDuration,
+		FailureWindow:      failureWindow,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Длина ключа шифрования секретов 2FA (AES-256)
+const mfaSecretKeyLength = 32
+
+// MFAConfig - настройки хранения секретов 2FA
+type MFAConfig struct {
+	// Ключ AES-256-GCM, которым секреты TOTP шифруются в таблице user_mfa
+	SecretKey []byte
+}
+
+// LoadMFAConfig загружает настройки из env.
+// MFA_SECRET_KEY - 32 байта в hex (openssl rand -hex 32), одинаковый на всех экземплярах.
+// Обязателен, кроме APP_ENV=development: там без него создаётся случайный ключ,
+// и 2FA, подключённая до перезапуска, перестаёт работать
+func LoadMFAConfig() (*MFAConfig, error) {
+	value := os.Getenv("MFA_SECRET_KEY")
+	if value != "" {
+		key, err := hex.DecodeString(value)
+		if err != nil || len(key) != mfaSecretKeyLength {
+			return nil, fmt.Errorf("MFA_SECRET_KEY must be %d bytes in hex", mfaSecretKeyLength)
+		}
+		return &MFAConfig{SecretKey: key}, nil
+	}
+
+	if IsProduction() {
+		return nil, fmt.Errorf("MFA_SECRET_KEY is required outside %s", EnvDevelopment)
+	}
+
+	key := make([]byte, mfaSecretKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate mfa secret key: %w", err)
+	}
+	log.Println("WARNING: MFA_SECRET_KEY is not set, using a random key; two-factor authentication enabled before restart will not work")
+	return &MFAConfig{SecretKey: key}, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMFAConfig(t *testing.T) {
+	key := strings.Repeat("ab", mfaSecretKeyLength)
+
+	tests := []struct {
+		name    string
+		appEnv  string
+		key     string
+		wantErr bool
+	}{
+		{"key set", "", key, false},
+		{"not hex", EnvDevelopment, strings.Repeat("zz", mfaSecretKeyLength), true},
+		{"short key", EnvDevelopment, "abcd", true},
+		{"no key in production", "", "", true},
+		{"no key in development", EnvDevelopment, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("MFA_SECRET_KEY", tt.key)
+
+			cfg, err := LoadMFAConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadMFAConfig: %v", err)
+			}
+			if len(cfg.SecretKey) != mfaSecretKeyLength {
+				t.Errorf("key length = %d", len(cfg.SecretKey))
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Способы запроса данных пользователя у провайдера
+const (
+	UserInfoMethodGet  = "GET"  // access токен в заголовке Authorization: Bearer (OpenID Connect)
+	UserInfoMethodPost = "POST" // access_token и client_id в теле формы (VK ID)
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// OIDCProviderConfig - настройки внешнего провайдера входа (OpenID Connect или OAuth 2.0)
+type OIDCProviderConfig struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // страница клиента, на которую провайдер возвращает code и state
+	Scopes       []string
+
+	// Если задан Issuer, незаданные адреса берутся из {Issuer}/.well-known/openid-configuration
+	Issuer         string
+	AuthURL        string
+	TokenURL       string
+	UserInfoURL    string
+	UserInfoMethod string
+
+	// Пути к полям в ответе userinfo, вложенные поля через точку (например, user.email).
+	// Пустой EmailVerifiedClaim означает, что провайдер выдаёт только подтверждённые адреса
+	SubjectClaim       string
+	EmailClaim         string
+	EmailVerifiedClaim string
+}
+
+// LoadOIDCConfig загружает провайдеров из env. OIDC_PROVIDERS - имена через запятую,
+// настройки каждого - переменные OIDC_<ИМЯ>_*. Без OIDC_PROVIDERS вход через провайдеров отключён
+func LoadOIDCConfig() ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid provider name %q in OIDC_PROVIDERS", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate provider %q in OIDC_PROVIDERS", name)
+		}
+		seen[name] = true
+
+		provider, err := loadOIDCProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, *provider)
+	}
+
+	return providers, nil
+}
+
+func loadOIDCProvider(name string) (*OIDCProviderConfig, error) {
+	prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+	env := func(key, defaultValue string) string {
+		if value := strings.TrimSpace(os.Getenv(prefix + key)); value != "" {
+			return value
+		}
+		return defaultValue
+	}
+
+	// Пустое значение (а не отсутствие переменной) отключает проверку подтверждения адреса
+	emailVerifiedClaim, ok := os.LookupEnv(prefix + "EMAIL_VERIFIED_CLAIM")
+	if !ok {
+		emailVerifiedClaim = "email_verified"
+	}
+
+	provider := &OIDCProviderConfig{
+		Name:               name,
+		ClientID:           env("CLIENT_ID", ""),
+		ClientSecret:       env("CLIENT_SECRET", ""),
+		RedirectURL:        env("REDIRECT_URL", ""),
+		Scopes:             strings.Fields(strings.ReplaceAll(env("SCOPES", "openid email"), ",", " ")),
+		Issuer:             strings.TrimSuffix(env("ISSUER", ""), "/"),
+		AuthURL:            env("AUTH_URL", ""),
+		TokenURL:           env("TOKEN_URL", ""),
+		UserInfoURL:        env("USERINFO_URL", ""),
+		UserInfoMethod:     strings.ToUpper(env("USERINFO_METHOD", UserInfoMethodGet)),
+		SubjectClaim:       env("SUBJECT_CLAIM", "sub"),
+		EmailClaim:         env("EMAIL_CLAIM", "email"),
+		EmailVerifiedClaim: strings.TrimSpace(emailVerifiedClaim),
+	}
+
+	if provider.ClientID == "" {
+		return nil, fmt.Errorf("%sCLIENT_ID is required", prefix)
+	}
+	if provider.RedirectURL == "" {
+		return nil, fmt.Errorf("%sREDIRECT_URL is required", prefix)
+	}
+	if provider.Issuer == "" && (provider.AuthURL == "" || provider.TokenURL == "" || provider.UserInfoURL == "") {
+		return nil, fmt.Errorf("%sISSUER or %sAUTH_URL, %sTOKEN_URL and %sUSERINFO_URL are required", prefix, prefix, prefix, prefix)
+	}
+	if provider.UserInfoMethod != UserInfoMethodGet && provider.UserInfoMethod != UserInfoMethodPost {
+		return nil, fmt.Errorf("%sUSERINFO_METHOD must be GET or POST, got %q", prefix, provider.UserInfoMethod)
+	}
+
+	for key, value := range map[string]string{
+		"REDIRECT_URL": provider.RedirectURL,
+		"ISSUER":       provider.Issuer,
+		"AUTH_URL":     provider.AuthURL,
+		"TOKEN_URL":    provider.TokenURL,
+		"USERINFO_URL": provider.UserInfoURL,
+	} {
+		if value == "" {
+			continue
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("%s%s must be an absolute http(s) url", prefix, key)
+		}
+	}
+
+	return provider, nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Настройки для обработчика исходящих сообщений (outbox)
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	LockTimeout  time.Duration
+
+	// Сколько хранить доставленные и недоставленные сообщения
+	SentRetention time.Duration
+	DeadRetention time.Duration
+}
+
+// Загрузка конфигурации outbox из env
+func LoadOutboxConfig() (*OutboxConfig, error) {
+	pollInterval, err := parseDurationEnv("OUTBOX_POLL_INTERVAL", 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL: %w", err)
+	}
+
+	batchSize, err := parseIntEnv("OUTBOX_BATCH_SIZE", 20)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_BATCH_SIZE: %w", err)
+	}
+
+	maxAttempts, err := parseIntEnv("OUTBOX_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_MAX_ATTEMPTS: %w", err)
+	}
+
+	baseBackoff, err := parseDurationEnv("OUTBOX_BASE_BACKOFF", 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_BASE_BACKOFF: %w", err)
+	}
+
+	maxBackoff, err := parseDurationEnv("OUTBOX_MAX_BACKOFF", time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_MAX_BACKOFF: %w", err)
+	}
+
+	lockTimeout, err := parseDurationEnv("OUTBOX_LOCK_TIMEOUT", 5*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_LOCK_TIMEOUT: %w", err)
+	}
+
+	sentRetention, err := parseDurationEnv("OUTBOX_SENT_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_SENT_RETENTION: %w", err)
+	}
+
+	deadRetention, err := parseDurationEnv("OUTBOX_DEAD_RETENTION", 30*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OUTBOX_DEAD_RETENTION: %w", err)
+	}
+
+	return &OutboxConfig{
+		PollInterval: pollInterval,
+		BatchSize:    batchSize,
+		MaxAttempts:  maxAttempts,
+		BaseBackoff:  baseBackoff,
+		MaxBackoff:   maxBackoff,
+		LockTimeout:  lockTimeout,
+
+		SentRetention: sentRetention,
+		DeadRetention: deadRetention,
+	}, nil
+}
+
+// Функция для корректного переноса целого числа из env
+func parseIntEnv(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Настройки рассмотрения заявок на подключение организаций
+type PartnerRequestConfig struct {
+	// Срок, за который администратор должен принять решение по заявке
+	// с момента последней смены её статуса
+	SLA time.Duration
+}
+
+// Загрузка конфигурации рассмотрения заявок из env
+func LoadPartnerRequestConfig() (*PartnerRequestConfig, error) {
+	sla, err := parseDurationEnv("PARTNER_REQUEST_SLA", 48*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PARTNER_REQUEST_SLA: %w", err)
+	}
+	if sla <= 0 {
+		return nil, fmt.Errorf("PARTNER_REQUEST_SLA must be positive")
+	}
+
+	return &PartnerRequestConfig{SLA: sla}, nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// Хранилища состояния ограничений частоты запросов
+const (
+	RateLimitStorageMemory   = "memory"   // память процесса, ограничения считаются отдельно на каждом экземпляре, только для разработки
+	RateLimitStoragePostgres = "postgres" // таблица rate_limits
+	RateLimitStorageRedis    = "redis"    // ключи ratelimit:*, требуется REDIS_URL
+	RateLimitStorageOff      = "off"      // ограничения отключены
+)
+
+// RateLimitConfig - настройки ограничения частоты запросов.
+// Сами ограничения задаются для групп маршрутов в router.New
+type RateLimitConfig struct {
+	Storage string
+
+	// Пропускать ли запросы, когда хранилище недоступно. Иначе они отклоняются с 503
+	FailOpen bool
+}
+
+// LoadRateLimitConfig загружает настройки из env.
+// RATE_LIMIT_STORAGE - postgres, redis, memory или off. По умолчанию redis, если задан REDIS_URL, иначе postgres:
+// ограничения общие для всех экземпляров.
+// RATE_LIMIT_FAIL_MODE - open (по умолчанию, запросы пропускаются) или closed (отклоняются) при недоступности хранилища
+func LoadRateLimitConfig() (*RateLimitConfig, error) {
+	cfg := &RateLimitConfig{Storage: os.Getenv("RATE_LIMIT_STORAGE")}
+	if cfg.Storage == "" {
+		cfg.Storage = RateLimitStoragePostgres
+		if os.Getenv("REDIS_URL") != "" {
+			cfg.Storage = RateLimitStorageRedis
+		}
+	}
+
+	switch mode := os.Getenv("RATE_LIMIT_FAIL_MODE"); mode {
+	case "", "open":
+		cfg.FailOpen = true
+	case "closed":
+	default:
+		return nil, fmt.Errorf("RATE_LIMIT_FAIL_MODE must be open or closed, got %q", mode)
+	}
+
+	switch cfg.Storage {
+	case RateLimitStorageMemory, RateLimitStoragePostgres, RateLimitStorageOff:
+	case RateLimitStorageRedis:
+		if os.Getenv("REDIS_URL") == "" {
+			return nil, fmt.Errorf("REDIS_URL is required for RATE_LIMIT_STORAGE=redis")
+		}
+	default:
+		return nil, fmt.Errorf("RATE_LIMIT_STORAGE must be memory, postgres, redis or off, got %q", cfg.Storage)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import "testing"
+
+func TestLoadRateLimitConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		storage      string
+		redisURL     string
+		failMode     string
+		wantStorage  string
+		wantFailOpen bool
+		wantErr      bool
+	}{
+		{"defaults to postgres", "", "", "", RateLimitStoragePostgres, true, false},
+		{"defaults to redis with REDIS_URL", "", "redis://localhost:6379/0", "", RateLimitStorageRedis, true, false},
+		{"explicit memory", RateLimitStorageMemory, "", "", RateLimitStorageMemory, true, false},
+		{"fail closed", "", "", "closed", RateLimitStoragePostgres, false, false},
+		{"unknown fail mode", "", "", "sometimes", "", false, true},
+		{"redis without REDIS_URL", RateLimitStorageRedis, "", "", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RATE_LIMIT_STORAGE", tt.storage)
+			t.Setenv("REDIS_URL", tt.redisURL)
+			t.Setenv("RATE_LIMIT_FAIL_MODE", tt.failMode)
+
+			cfg, err := LoadRateLimitConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRateLimitConfig: %v", err)
+			}
+			if cfg.Storage != tt.wantStorage || cfg.FailOpen != tt.wantFailOpen {
+				t.Errorf("config = %+v", cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Ключ pg_advisory_xact_lock: несколько экземпляров, запущенных одновременно,
+// применяют миграции по очереди
+const migrationLockKey = 7302918450
+
+// Migrate применяет ещё не применённые миграции *.sql из files в порядке имён.
+// Каждая миграция выполняется в своей транзакции вместе с записью в schema_migrations,
+// поэтому ошибка в миграции не оставляет схему в промежуточном состоянии.
+// Возвращает имена применённых миграций
+func Migrate(db *sql.DB, files fs.FS) ([]string, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    VARCHAR(255) PRIMARY KEY,
+			applied_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	var applied []string
+	for _, name := range names {
+		version := strings.TrimSuffix(path.Base(name), ".sql")
+		script, err := fs.ReadFile(files, name)
+		if err != nil {
+			return applied, fmt.Errorf("read migration %s: %w", name, err)
+		}
+
+		done, err := applyMigration(db, version, script)
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			log.Printf("db: migration %s applied", version)
+			applied = append(applied, version)
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration применяет миграцию version в отдельной транзакции,
+// если она ещё не записана в schema_migrations
+func applyMigration(db *sql.DB, version string, script []byte) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin migration %s: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+		return false, fmt.Errorf("lock migrations: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check migration %s: %w", version, err)
+	}
+	if exists {
+		return false, tx.Commit()
+	}
+
+	if _, err := tx.Exec(string(script)); err != nil {
+		return false, fmt.Errorf("apply migration %s: %w", version, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return false, fmt.Errorf("record migration %s: %w", version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit migration %s: %w", version, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"regexp"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMigrate(t *testing.T) {
+	files := fstest.MapFS{
+		"002_second.sql": {Data: []byte("CREATE TABLE second (id INT)")},
+		"001_first.sql":  {Data: []byte("CREATE TABLE first (id INT)")},
+		"readme.txt":     {Data: []byte("not a migration")},
+	}
+
+	tests := []struct {
+		name        string
+		applied     map[string]bool
+		failVersion string
+		wantApplied []string
+		wantErr     bool
+	}{
+		{name: "empty database", wantApplied: []string{"001_first", "002_second"}},
+		{name: "only new migrations", applied: map[string]bool{"001_first": true}, wantApplied: []string{"002_second"}},
+		{name: "nothing to apply", applied: map[string]bool{"001_first": true, "002_second": true}},
+		{name: "failed migration is rolled back", failVersion: "002_second", wantApplied: []string{"001_first"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sqlDB.Close()
+
+			mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+			// Миграции применяются в порядке имён, каждая в своей транзакции
+			for _, version := range []string{"001_first", "002_second"} {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(`SELECT EXISTS`).WithArgs(version).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.applied[version]))
+				if tt.applied[version] {
+					mock.ExpectCommit()
+					continue
+				}
+
+				script := "CREATE TABLE " + version[4:]
+				if version == tt.failVersion {
+					mock.ExpectExec(regexp.QuoteMeta(script)).WillReturnError(errors.New("syntax error"))
+					mock.ExpectRollback()
+					break
+				}
+				mock.ExpectExec(regexp.QuoteMeta(script)).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(version).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			applied, err := Migrate(sqlDB, files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(applied) != len(tt.wantApplied) {
+				t.Fatalf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+			for i := range applied {
+				if applied[i] != tt.wantApplied[i] {
+					t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+				}
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMigrationFilesAreOrdered(t *testing.T) {
+	// Номера миграций в src/migrations не должны повторяться: порядок применения - порядок имён
+	names, err := filesIn("../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]string{}
+	for _, name := range names {
+		number := name[:3]
+		if prev, ok := seen[number]; ok {
+			t.Errorf("migrations %s and %s share number %s", prev, name, number)
+		}
+		seen[number] = name
+	}
+	if len(names) == 0 {
+		t.Fatal("no migrations found")
+	}
+}
+
+// Имена миграций *.sql в каталоге dir
+func filesIn(dir string) ([]string, error) {
+	return fs.Glob(os.DirFS(dir), "*.sql")
+}
@@ -11,12 +11,28 @@ import (
 )
 
 type Storage struct {
-	DB *sql.DB
+	// Пул соединений или транзакция, если storage получен через WithTx
+	DB Querier
+
+	conn *sql.DB
 }
 
 // Содание структуры которая испоотзуется в других storage
 func NewStorage(db *sql.DB) *Storage {
-	return &Storage{DB: db}
+	return &Storage{DB: db, conn: db}
+}
+
+// WithTx возвращает Storage, выполняющий запросы внутри транзакции tx
+func (s *Storage) WithTx(tx *sql.Tx) *Storage {
+	return &Storage{DB: tx, conn: s.conn}
+}
+
+// InTx выполняет fn в транзакции. Если Storage уже работает в транзакции, fn выполняется в ней же
+func (s *Storage) InTx(fn func(tx *sql.Tx) error) error {
+	if tx, ok := s.DB.(*sql.Tx); ok {
+		return fn(tx)
+	}
+	return NewTxManager(s.conn).Do(fn)
 }
 
 // Connect устанавливает соединение с БД и возвращает объект *sql.DB.
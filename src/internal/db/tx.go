@@ -0,0 +1,50 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Querier - общий интерфейс *sql.DB и *sql.Tx, через который storage выполняют запросы.
+// Один и тот же storage работает и вне транзакции, и внутри неё (см. WithTx)
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// UnitOfWork выполняет несколько операций storage атомарно.
+// Storage участвуют в транзакции через свой метод WithTx(tx)
+type UnitOfWork interface {
+	// Do выполняет fn в транзакции: commit, если fn вернула nil, иначе rollback
+	Do(fn func(tx *sql.Tx) error) error
+}
+
+// TxManager реализует UnitOfWork поверх пула соединений
+type TxManager struct {
+	db *sql.DB
+}
+
+// NewTxManager создаёт новый экземпляр TxManager
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{db: db}
+}
+
+// Do выполняет fn в транзакции. Транзакция откатывается, если fn вернула ошибку или паниковала
+func (m *TxManager) Do(fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	// После Commit откат ничего не делает
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
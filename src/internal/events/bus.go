@@ -0,0 +1,213 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Сколько последних событий хранится для досылки по Last-Event-ID
+	historySize = 1000
+	// Размер буфера канала подписчика. Переполненный подписчик отключается
+	// и при переподключении получает пропущенные события из истории
+	subscriberBuffer = 64
+)
+
+// Listener синхронно обрабатывает каждое опубликованное событие (например, вебхуки)
+type Listener interface {
+	HandleEvent(evt *Event) error
+}
+
+// TxListener записывает событие в транзакции, в которой меняются данные (например, ставит вебхуки в outbox).
+// Такой обработчик вызывается PublishTx до commit, и событие не теряется при сбое после него
+type TxListener interface {
+	HandleEventTx(tx *sql.Tx, evt *Event) error
+}
+
+// Bus - внутрипроцессная шина событий.
+// Пакеты order и company публикуют в неё события заказов, а SSE-поток и вебхуки их получают
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []*Event
+	subscribers map[*Subscription]struct{}
+	listeners   []Listener
+}
+
+// NewBus создаёт новый экземпляр Bus.
+// Нумерация событий начинается с текущего времени в микросекундах, чтобы ID,
+// полученные клиентом до перезапуска сервера, не совпадали с новыми
+func NewBus() *Bus {
+	return &Bus{
+		lastID:      uint64(time.Now().UnixMicro()),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// AddListener регистрирует обработчик всех событий шины
+func (b *Bus) AddListener(l Listener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, l)
+}
+
+// Publish публикует событие компании. branchID может быть uuid.Nil, если событие не относится к филиалу
+func (b *Bus) Publish(companyInn string, branchID uuid.UUID, eventType string, data any) error {
+	evt, err := newEvent(companyInn, branchID, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range b.dispatch(evt) {
+		if err := l.HandleEvent(evt); err != nil {
+			log.Printf("events: listener failed for event %d (%s): %v", evt.ID, evt.Type, err)
+		}
+	}
+	return nil
+}
+
+// PublishTx публикует событие изменения, выполняемого в транзакции tx.
+// Обработчики TxListener записывают событие в tx, их ошибка откатывает транзакцию.
+// Подписчики и остальные обработчики получают событие через возвращённую функцию,
+// которую вызывают после commit: до него изменения ещё не видны
+func (b *Bus) PublishTx(tx *sql.Tx, companyInn string, branchID uuid.UUID, eventType string, data any) (func(), error) {
+	evt, err := newEvent(companyInn, branchID, eventType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	listeners := b.listeners
+	b.mu.Unlock()
+
+	for _, l := range listeners {
+		if txl, ok := l.(TxListener); ok {
+			if err := txl.HandleEventTx(tx, evt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return func() {
+		for _, l := range b.dispatch(evt) {
+			if _, ok := l.(TxListener); ok {
+				continue
+			}
+			if err := l.HandleEvent(evt); err != nil {
+				log.Printf("events: listener failed for event %d (%s): %v", evt.ID, evt.Type, err)
+			}
+		}
+	}, nil
+}
+
+// Создание события без ID: ID выдаётся при отправке подписчикам
+func newEvent(companyInn string, branchID uuid.UUID, eventType string, data any) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event data: %w", err)
+	}
+	return &Event{
+		Type:       eventType,
+		CompanyINN: companyInn,
+		BranchID:   branchID,
+		CreatedAt:  time.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
+
+// Присвоение ID, запись в историю и отправка подписчикам.
+// Возвращает обработчики, которые нужно вызвать вне блокировки
+func (b *Bus) dispatch(evt *Event) []Listener {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	evt.ID = b.lastID
+
+	b.history = append(b.history, evt)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.matches(evt) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+			// Подписчик не успевает читать - отключаем его
+			log.Printf("events: subscriber for company %s is too slow, disconnecting", sub.companyInn)
+			b.remove(sub)
+		}
+	}
+	return b.listeners
+}
+
+// Subscribe подписывает на события компании (и филиала, если branchID не uuid.Nil).
+// Возвращает подписку и события из истории с ID больше lastEventID, которые нужно отправить первыми
+func (b *Bus) Subscribe(companyInn string, branchID uuid.UUID, lastEventID uint64) (*Subscription, []*Event) {
+	sub := &Subscription{
+		bus:        b,
+		ch:         make(chan *Event, subscriberBuffer),
+		companyInn: companyInn,
+		branchID:   branchID,
+	}
+	sub.C = sub.ch
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []*Event
+	if lastEventID > 0 {
+		for _, evt := range b.history {
+			if evt.ID > lastEventID && sub.matches(evt) {
+				missed = append(missed, evt)
+			}
+		}
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub, missed
+}
+
+// Удаление подписчика. Вызывается под b.mu
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
+
+// Subscription - подписка на события компании.
+// Канал C закрывается при отписке или отключении медленного подписчика
+type Subscription struct {
+	C <-chan *Event
+
+	bus        *Bus
+	ch         chan *Event
+	companyInn string
+	branchID   uuid.UUID
+}
+
+// Close отменяет подписку
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
+
+// Проверка, относится ли событие к подписке
+func (s *Subscription) matches(evt *Event) bool {
+	if evt.CompanyINN != s.companyInn {
+		return false
+	}
+	return s.branchID == uuid.Nil || evt.BranchID == s.branchID
+}
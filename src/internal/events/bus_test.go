@@ -0,0 +1,81 @@
+package events
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type recordingListener struct {
+	handled []*Event
+	txErr   error
+	inTx    []*Event
+}
+
+func (l *recordingListener) HandleEvent(evt *Event) error {
+	l.handled = append(l.handled, evt)
+	return nil
+}
+
+type recordingTxListener struct {
+	recordingListener
+}
+
+func (l *recordingTxListener) HandleEventTx(tx *sql.Tx, evt *Event) error {
+	if l.txErr != nil {
+		return l.txErr
+	}
+	l.inTx = append(l.inTx, evt)
+	return nil
+}
+
+func TestPublishTx(t *testing.T) {
+	tests := []struct {
+		name      string
+		txErr     error
+		wantErr   bool
+		wantInTx  int
+		wantAfter int
+	}{
+		{name: "event is stored in transaction and sent after commit", wantInTx: 1, wantAfter: 1},
+		{name: "listener error aborts the transaction", txErr: errors.New("outbox is down"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := NewBus()
+			txListener := &recordingTxListener{recordingListener{txErr: tt.txErr}}
+			plain := &recordingListener{}
+			bus.AddListener(txListener)
+			bus.AddListener(plain)
+			sub, _ := bus.Subscribe("7700000000", uuid.Nil, 0)
+			defer sub.Close()
+
+			notify, err := bus.PublishTx(nil, "7700000000", uuid.Nil, OrderCreated, map[string]string{"id": "1"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PublishTx error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(txListener.inTx) != tt.wantInTx {
+				t.Errorf("tx listener got %d events, want %d", len(txListener.inTx), tt.wantInTx)
+			}
+			// До commit подписчики ничего не получают
+			if len(sub.C) != 0 || len(plain.handled) != 0 {
+				t.Fatal("event delivered before commit")
+			}
+			if err != nil {
+				return
+			}
+
+			notify()
+			if len(sub.C) != tt.wantAfter || len(plain.handled) != tt.wantAfter {
+				t.Errorf("after commit: subscriber got %d, listener got %d, want %d", len(sub.C), len(plain.handled), tt.wantAfter)
+			}
+			// Транзакционный обработчик не получает событие повторно
+			if len(txListener.handled) != 0 {
+				t.Errorf("tx listener handled event twice")
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий заказов
+const (
+	OrderCreated       = "order.created"        // создан заказ
+	OrderStatusChanged = "order.status_changed" // изменён статус заказа
+	OrderCancelled     = "order.cancelled"      // заказ отменён
+)
+
+// Event - событие внутренней шины.
+// ID монотонно возрастает и используется как id SSE-сообщения (Last-Event-ID)
+type Event struct {
+	ID         uint64          `json:"id" example:"1774850807181805"`
+	Type       string          `json:"type" example:"order.created"`
+	CompanyINN string          `json:"inn" example:"123456789012"`
+	BranchID   uuid.UUID       `json:"branch_id" example:"9eebb3b9-5b35-4007-9d4f-2f4141786b45"`
+	CreatedAt  time.Time       `json:"created_at" example:"2026-03-30T06:06:47.181805Z"`
+	Data       json.RawMessage `json:"data" swaggertype:"object"`
+}
@@ -0,0 +1,291 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Минимальный размер RSA ключа в битах
+const minRSABits = 2048
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key type: RSA or Ed25519 expected")
+	ErrUnknownKeyID   = errors.New("unknown key id")
+)
+
+// Key - открытый ключ проверки подписи
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+}
+
+// KeySet подписывает токены текущим ключом и проверяет подпись любым из известных ключей.
+// Для ротации без простоя новый открытый ключ сначала добавляется в проверочные на всех экземплярах,
+// затем им начинают подписывать, а старый удаляется после истечения выданных им токенов
+type KeySet struct {
+	signer  crypto.Signer
+	signing *Key
+	keys    []*Key
+	byID    map[string]*Key
+
+	// Общий секрет HS256 прежней схемы подписи. Им только проверяются токены без kid до secretUntil
+	secret      []byte
+	secretUntil time.Time
+}
+
+// NewKeySet создаёт набор из ключа подписи и дополнительных ключей проверки
+func NewKeySet(signer crypto.Signer, verification ...crypto.PublicKey) (*KeySet, error) {
+	signing, err := newKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{
+		signer:  signer,
+		signing: signing,
+		keys:    []*Key{signing},
+		byID:    map[string]*Key{signing.ID: signing},
+	}
+	for _, public := range verification {
+		key, err := newKey(public)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := set.byID[key.ID]; ok {
+			continue
+		}
+		set.keys = append(set.keys, key)
+		set.byID[key.ID] = key
+	}
+	return set, nil
+}
+
+// AcceptSecret разрешает до until принимать токены без kid, подписанные общим секретом HS256.
+// Нужен на время перехода с секрета на ключи, пока не истекут выданные раньше токены
+func (s *KeySet) AcceptSecret(secret []byte, until time.Time) {
+	s.secret = secret
+	s.secretUntil = until
+}
+
+func newKey(public crypto.PublicKey) (*Key, error) {
+	var method jwt.SigningMethod
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits, got %d", minRSABits, k.N.BitLen())
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, ErrUnsupportedKey
+	}
+
+	id, err := Thumbprint(public)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: id, Method: method, Public: public}, nil
+}
+
+// SigningKeyID возвращает kid текущего ключа подписи
+func (s *KeySet) SigningKeyID() string {
+	return s.signing.ID
+}
+
+// Sign подписывает claims текущим ключом и указывает его kid в заголовке
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	token.Header["kid"] = s.signing.ID
+	return token.SignedString(s.signer)
+}
+
+// Keyfunc выбирает ключ проверки по kid из заголовка токена. Используется в jwt.Parse
+func (s *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && s.secret != nil {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, jwt.ErrTokenSignatureInvalid
+		}
+		if time.Now().After(s.secretUntil) {
+			return nil, ErrUnknownKeyID
+		}
+		return s.secret, nil
+	}
+	key, ok := s.byID[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.Public, nil
+}
+
+// Methods возвращает алгоритмы известных ключей для jwt.WithValidMethods
+func (s *KeySet) Methods() []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, key := range s.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	if s.secret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	return methods
+}
+
+// JWK - открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`
+	Use string `json:"use" example:"sig"`
+	Alg string `json:"alg" example:"RS256"`
+	Kid string `json:"kid" example:"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty" example:"AQAB"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS - набор открытых ключей для /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает все ключи проверки, начиная с текущего ключа подписи. Общий секрет не публикуется
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		jwk := publicJWK(key.Public)
+		jwk.Use = "sig"
+		jwk.Alg = key.Method.Alg()
+		jwk.Kid = key.ID
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// Обязательные параметры открытого ключа без use, alg и kid
+func publicJWK(public crypto.PublicKey) JWK {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}
+	}
+	return JWK{}
+}
+
+// Thumbprint вычисляет отпечаток ключа по RFC 7638, он используется как kid.
+// Отпечаток зависит только от ключа, поэтому у всех экземпляров приложения kid совпадает
+func Thumbprint(public crypto.PublicKey) (string, error) {
+	jwk := publicJWK(public)
+
+	// Обязательные поля в лексикографическом порядке
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", ErrUnsupportedKey
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// ParsePrivateKeyPEM разбирает закрытый ключ RSA или Ed25519 в формате PKCS#8 ("PRIVATE KEY")
+// или PKCS#1 ("RSA PRIVATE KEY")
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// ParsePublicKeyPEM разбирает открытый ключ RSA или Ed25519 в формате PKIX ("PUBLIC KEY").
+// Закрытый ключ тоже принимается - из него берётся открытая часть
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if block.Type != "PUBLIC KEY" {
+		signer, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, ErrUnsupportedKey
+}
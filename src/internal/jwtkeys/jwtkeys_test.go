@@ -0,0 +1,301 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newEd25519(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func newKeySet(t *testing.T, signer crypto.Signer, verification ...crypto.PublicKey) *KeySet {
+	t.Helper()
+	keys, err := NewKeySet(signer, verification...)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return keys
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"email": "user@example.com", "type": "access", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func parse(keys *KeySet, token string) error {
+	_, err := jwt.Parse(token, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
+	return err
+}
+
+func tokenHeader(t *testing.T, token string) map[string]any {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("parse unverified: %v", err)
+	}
+	return parsed.Header
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		alg    string
+	}{
+		{"ed25519", newEd25519(t), "EdDSA"},
+		{"rsa", rsaKey, "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newKeySet(t, tt.signer)
+			token, err := keys.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			header := tokenHeader(t, token)
+			if header["alg"] != tt.alg {
+				t.Errorf("alg = %v, want %s", header["alg"], tt.alg)
+			}
+			if header["kid"] != keys.SigningKeyID() {
+				t.Errorf("kid = %v, want %s", header["kid"], keys.SigningKeyID())
+			}
+			if err := parse(keys, token); err != nil {
+				t.Errorf("token rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestSmallRSAKeyRejected(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	if _, err := NewKeySet(key); err == nil {
+		t.Fatal("1024-bit key accepted")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldSigner := newEd25519(t)
+	newSigner := newEd25519(t)
+
+	oldKeys := newKeySet(t, oldSigner)
+	oldToken, err := oldKeys.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	// Шаг 1: новый ключ добавлен в проверочные, подпись старым
+	step1 := newKeySet(t, oldSigner, newSigner.Public())
+	// Шаг 2: подпись новым, старый остаётся в проверочных
+	step2 := newKeySet(t, newSigner, oldSigner.Public())
+	newToken, err := step2.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	// Шаг 3: старый ключ удалён
+	step3 := newKeySet(t, newSigner)
+
+	if err := parse(step1, newToken); err != nil {
+		t.Errorf("step 1 rejects token of the next key: %v", err)
+	}
+	if err := parse(step2, oldToken); err != nil {
+		t.Errorf("step 2 rejects token of the previous key: %v", err)
+	}
+	if err := parse(step3, oldToken); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("step 3 err = %v, want ErrUnknownKeyID", err)
+	}
+	if err := parse(step3, newToken); err != nil {
+		t.Errorf("step 3 rejects token of the current key: %v", err)
+	}
+
+	jwks := step2.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("jwks has %d keys, want 2", len(jwks.Keys))
+	}
+	if jwks.Keys[0].Kid != step2.SigningKeyID() {
+		t.Errorf("first jwk kid = %s, want signing key %s", jwks.Keys[0].Kid, step2.SigningKeyID())
+	}
+	if jwks.Keys[1].Kid != oldKeys.SigningKeyID() {
+		t.Errorf("second jwk kid = %s, want previous key %s", jwks.Keys[1].Kid, oldKeys.SigningKeyID())
+	}
+}
+
+func TestDuplicateVerificationKey(t *testing.T) {
+	signer := newEd25519(t)
+	keys := newKeySet(t, signer, signer.Public(), signer.Public())
+	if n := len(keys.JWKS().Keys); n != 1 {
+		t.Errorf("jwks has %d keys, want 1", n)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	signer := newEd25519(t)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	keys := newKeySet(t, signer, rsaKey.Public())
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("jwks has %d keys, want 2", len(jwks.Keys))
+	}
+
+	ed := jwks.Keys[0]
+	if ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || ed.Use != "sig" {
+		t.Errorf("ed25519 jwk = %+v", ed)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	if err != nil || !ed25519.PublicKey(x).Equal(signer.Public()) {
+		t.Errorf("ed25519 jwk x does not match the key")
+	}
+
+	rs := jwks.Keys[1]
+	if rs.Kty != "RSA" || rs.Alg != "RS256" || rs.Use != "sig" || rs.E != "AQAB" {
+		t.Errorf("rsa jwk = %+v", rs)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(rs.N)
+	if err != nil || new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 {
+		t.Errorf("rsa jwk n does not match the key")
+	}
+
+	// Закрытые параметры не публикуются
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var raw struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, key := range raw.Keys {
+		if _, ok := key["d"]; ok {
+			t.Errorf("jwk contains private key: %v", key)
+		}
+	}
+}
+
+// Пример из RFC 7638, раздел 3.1
+func TestThumbprintRFC7638(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatalf("decode n: %v", err)
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+
+	got, err := Thumbprint(key)
+	if err != nil {
+		t.Fatalf("Thumbprint: %v", err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("thumbprint = %s, want %s", got, want)
+	}
+}
+
+func TestAcceptSecret(t *testing.T) {
+	// Токен прежней схемы: HS256 без kid
+	secret := []byte("legacy-secret")
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString(secret)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	signer := newEd25519(t)
+
+	t.Run("within transition", func(t *testing.T) {
+		keys := newKeySet(t, signer)
+		keys.AcceptSecret(secret, time.Now().Add(time.Hour))
+
+		if err := parse(keys, legacyToken); err != nil {
+			t.Errorf("legacy token rejected: %v", err)
+		}
+		token, err := keys.Sign(testClaims())
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		if alg := tokenHeader(t, token)["alg"]; alg != "EdDSA" {
+			t.Errorf("new token alg = %v, want EdDSA", alg)
+		}
+		if n := len(keys.JWKS().Keys); n != 1 {
+			t.Errorf("jwks has %d keys, want 1", n)
+		}
+	})
+
+	t.Run("after transition", func(t *testing.T) {
+		keys := newKeySet(t, signer)
+		keys.AcceptSecret(secret, time.Now().Add(-time.Second))
+
+		if err := parse(keys, legacyToken); !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("err = %v, want ErrUnknownKeyID", err)
+		}
+	})
+
+	t.Run("without secret", func(t *testing.T) {
+		keys := newKeySet(t, signer)
+		if err := parse(keys, legacyToken); err == nil {
+			t.Error("legacy token accepted without JWT_SECRET")
+		}
+	})
+
+	t.Run("other secret", func(t *testing.T) {
+		keys := newKeySet(t, signer)
+		keys.AcceptSecret([]byte("other-secret"), time.Now().Add(time.Hour))
+		if err := parse(keys, legacyToken); err == nil {
+			t.Error("token signed with another secret accepted")
+		}
+	})
+
+	t.Run("hs256 with kid", func(t *testing.T) {
+		keys := newKeySet(t, signer)
+		keys.AcceptSecret(secret, time.Now().Add(time.Hour))
+
+		// Подмена алгоритма: HS256 с kid асимметричного ключа не проверяется секретом
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		token.Header["kid"] = keys.SigningKeyID()
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		if err := parse(keys, signed); err == nil {
+			t.Error("HS256 token with kid accepted")
+		}
+	})
+
+	t.Run("asymmetric without kid", func(t *testing.T) {
+		keys := newKeySet(t, signer)
+		keys.AcceptSecret(secret, time.Now().Add(time.Hour))
+
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims()).SignedString(signer)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		if err := parse(keys, signed); err == nil {
+			t.Error("EdDSA token without kid accepted")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Handler обрабатывает HTTP-запросы предпросмотра шаблонов писем
+type Handler struct {
+	renderer *Renderer
+}
+
+// NewHandler создаёт новый экземпляр Handler
+func NewHandler(renderer *Renderer) *Handler {
+	return &Handler{renderer: renderer}
+}
+
+// TemplatesResponse - список шаблонов и языков
+type TemplatesResponse struct {
+	Templates   []string `json:"templates" example:"reset_code,verification_code"`
+	Languages   []string `json:"languages" example:"ru,en"`
+	DefaultLang string   `json:"default_lang" example:"ru"`
+}
+
+// GetTemplates обрабатывает GET /admin/email/templates
+func (h *Handler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TemplatesResponse{
+		Templates:   h.renderer.Names(),
+		Languages:   Languages,
+		DefaultLang: h.renderer.DefaultLang(),
+	})
+}
+
+// Preview обрабатывает GET /admin/email/preview/{name}?lang=ru&format=html.
+// Формирует письмо с примером данных; format: html (по умолчанию), text или json
+func (h *Handler) Preview(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	lang := r.URL.Query().Get("lang")
+
+	msg, err := h.renderer.Render(name, lang, Sample(name))
+	if err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			http.Error(w, "Template not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrUnknownLang) {
+			http.Error(w, "lang must be ru or en", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(msg.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(msg.Text))
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(msg)
+	default:
+		http.Error(w, "format must be html, text or json", http.StatusBadRequest)
+	}
+}
+
+// MailboxHandler обрабатывает HTTP-запросы к почтовому ящику в памяти (только для разработки)
+type MailboxHandler struct {
+	mailbox *Mailbox
+}
+
+// NewMailboxHandler создаёт новый экземпляр MailboxHandler
+func NewMailboxHandler(mailbox *Mailbox) *MailboxHandler {
+	return &MailboxHandler{mailbox: mailbox}
+}
+
+// GetMessages обрабатывает GET /dev/mailbox?to=<email>, возвращает письма начиная с последнего
+func (h *MailboxHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.mailbox.List(r.URL.Query().Get("to")))
+}
+
+// GetMessage обрабатывает GET /dev/mailbox/{id}?format=json.
+// format: json (по умолчанию), html или text
+func (h *MailboxHandler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := h.mailbox.Get(id)
+	if err != nil {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(entry.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(entry.Text))
+	default:
+		http.Error(w, "format must be json, html or text", http.StatusBadRequest)
+	}
+}
+
+// Clear обрабатывает DELETE /dev/mailbox, удаляет все письма
+func (h *MailboxHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	h.mailbox.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
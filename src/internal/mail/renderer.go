@@ -0,0 +1,292 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Шаблоны писем по умолчанию, встроенные в бинарник.
+// Структура: layout.html, layout.txt - общий макет; <язык>/<шаблон>.html и .txt - содержимое.
+// Каждый шаблон содержимого определяет блоки subject, content и footer
+//
+//go:embed templates
+var embedded embed.FS
+
+// Шаблоны писем
+const (
+	TemplateVerificationCode = "verification_code"
+	TemplateResetCode        = "reset_code"
+	TemplateInvitation       = "company_invitation"
+	TemplateTokenReuse       = "refresh_token_reuse"
+	TemplateAccountLocked    = "account_locked"
+	TemplateEmailChangeCode  = "email_change_code"
+	TemplateSuspended        = "account_suspended"
+	TemplateReinstated       = "account_reinstated"
+	TemplatePartnerRequest   = "partner_request_status"
+)
+
+// Поддерживаемые языки
+const (
+	LangRU = "ru"
+	LangEN = "en"
+)
+
+// Languages - языки, для которых есть шаблоны
+var Languages = []string{LangRU, LangEN}
+
+// MatchLanguage выбирает язык писем по заголовку Accept-Language ("en-US,en;q=0.9,ru;q=0.8").
+// Возвращает поддерживаемый язык с наибольшим весом или пустую строку, если подходящего нет
+func MatchLanguage(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !slices.Contains(Languages, base) {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = base, q
+		}
+	}
+	return best
+}
+
+var (
+	ErrTemplateNotFound = errors.New("email template not found")
+	ErrUnknownLang      = errors.New("unsupported email language")
+)
+
+// CodeData - данные шаблонов с кодом подтверждения
+type CodeData struct {
+	Code string
+}
+
+// InvitationData - данные письма с приглашением в компанию
+type InvitationData struct {
+	CompanyName string    `json:"company_name"`
+	Role        string    `json:"role"`
+	InvitedBy   string    `json:"invited_by"`
+	Token       string    `json:"token"`
+	Link        string    `json:"link,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// TokenReuseData - данные письма о повторном использовании refresh токена
+type TokenReuseData struct {
+	DetectedAt time.Time `json:"detected_at"`
+	DeviceInfo string    `json:"device_info,omitempty"`
+}
+
+// AccountLockedData - данные письма о временной блокировке входа
+type AccountLockedData struct {
+	Attempts    int       `json:"attempts"`
+	IP          string    `json:"ip,omitempty"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// SuspensionData - данные писем о приостановке и возобновлении аккаунта или компании.
+// Пустой CompanyName - письмо об аккаунте пользователя
+type SuspensionData struct {
+	CompanyName string    `json:"company_name,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// PartnerRequestData - данные письма о смене статуса заявки на подключение организации.
+// Reason - причина отклонения или список недостающих сведений
+type PartnerRequestData struct {
+	OrgShortName string `json:"org_short_name"`
+	Status       string `json:"status"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Данные, передаваемые в шаблон: общие поля макета и данные конкретного письма
+type view struct {
+	Lang string
+	Year int
+	Data any
+}
+
+// Пара шаблонов одного письма на одном языке
+type templateSet struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// Renderer формирует письма из html/template и text/template шаблонов
+type Renderer struct {
+	defaultLang string
+	names       []string
+	templates   map[string]map[string]*templateSet // язык -> имя шаблона -> шаблоны
+}
+
+// DefaultTemplates возвращает встроенные шаблоны писем
+func DefaultTemplates() fs.FS {
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// NewRenderer разбирает все шаблоны из fsys. Ошибка в любом шаблоне возвращается сразу,
+// чтобы сломанный шаблон обнаруживался при старте, а не при отправке письма
+func NewRenderer(fsys fs.FS, defaultLang string) (*Renderer, error) {
+	if !slices.Contains(Languages, defaultLang) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLang, defaultLang)
+	}
+
+	r := &Renderer{
+		defaultLang: defaultLang,
+		templates:   make(map[string]map[string]*templateSet),
+	}
+
+	for _, lang := range Languages {
+		files, err := fs.Glob(fsys, lang+"/*.html")
+		if err != nil {
+			return nil, fmt.Errorf("list %s templates: %w", lang, err)
+		}
+
+		r.templates[lang] = make(map[string]*templateSet)
+		for _, file := range files {
+			name := strings.TrimSuffix(path.Base(file), ".html")
+
+			html, err := htmltemplate.ParseFS(fsys, "layout.html", file)
+			if err != nil {
+				return nil, fmt.Errorf("parse template %s: %w", file, err)
+			}
+
+			text, err := texttemplate.ParseFS(fsys, "layout.txt", lang+"/"+name+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("parse template %s/%s.txt: %w", lang, name, err)
+			}
+
+			r.templates[lang][name] = &templateSet{html: html, text: text}
+			if !slices.Contains(r.names, name) {
+				r.names = append(r.names, name)
+			}
+		}
+	}
+
+	if _, ok := r.templates[defaultLang][TemplateVerificationCode]; !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrTemplateNotFound, defaultLang, TemplateVerificationCode)
+	}
+	if _, ok := r.templates[defaultLang][TemplateResetCode]; !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrTemplateNotFound, defaultLang, TemplateResetCode)
+	}
+
+	slices.Sort(r.names)
+	return r, nil
+}
+
+// Names возвращает имена всех шаблонов
+func (r *Renderer) Names() []string {
+	return r.names
+}
+
+// DefaultLang возвращает язык писем по умолчанию
+func (r *Renderer) DefaultLang() string {
+	return r.defaultLang
+}
+
+// Render формирует письмо по шаблону name на языке lang.
+// Пустой lang означает язык по умолчанию; если шаблона на нужном языке нет, используется язык по умолчанию
+func (r *Renderer) Render(name, lang string, data any) (*Message, error) {
+	if lang == "" {
+		lang = r.defaultLang
+	}
+	if !slices.Contains(Languages, lang) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLang, lang)
+	}
+
+	set, ok := r.templates[lang][name]
+	if !ok {
+		set, ok = r.templates[r.defaultLang][name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+		}
+		lang = r.defaultLang
+	}
+
+	v := view{Lang: lang, Year: time.Now().Year(), Data: data}
+
+	var subject, text bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, "subject", v); err != nil {
+		return nil, fmt.Errorf("render subject %s/%s: %w", lang, name, err)
+	}
+	if err := set.text.Execute(&text, v); err != nil {
+		return nil, fmt.Errorf("render text %s/%s: %w", lang, name, err)
+	}
+
+	var html bytes.Buffer
+	if err := set.html.Execute(&html, v); err != nil {
+		return nil, fmt.Errorf("render html %s/%s: %w", lang, name, err)
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+// Sample возвращает пример данных для предпросмотра шаблона
+func Sample(name string) any {
+	switch name {
+	case TemplateVerificationCode, TemplateResetCode, TemplateEmailChangeCode:
+		return CodeData{Code: "A1B2C3"}
+	case TemplateInvitation:
+		return InvitationData{
+			CompanyName: "Технопром",
+			Role:        "manager",
+			InvitedBy:   "owner@mail.ru",
+			Token:       "3f9c2a7e5b1d4c8a9e6f0b2d7a4c1e8f3b5d9a2c6e0f4b8d1a7c3e9f5b2d6a0c",
+			Link:        "https://example.com/invitations?token=3f9c2a7e",
+			ExpiresAt:   time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC),
+		}
+	case TemplateTokenReuse:
+		return TokenReuseData{
+			DetectedAt: time.Date(2026, 3, 30, 6, 6, 0, 0, time.UTC),
+			DeviceInfo: "iPhone, Safari",
+		}
+	case TemplateAccountLocked:
+		return AccountLockedData{
+			Attempts:    10,
+			IP:          "203.0.113.7",
+			LockedUntil: time.Date(2026, 3, 30, 6, 21, 0, 0, time.UTC),
+		}
+	case TemplateSuspended, TemplateReinstated:
+		return SuspensionData{
+			CompanyName: "Технопром",
+			Reason:      "Многочисленные жалобы клиентов на отменённые заказы",
+			At:          time.Date(2026, 3, 30, 6, 6, 0, 0, time.UTC),
+		}
+	case TemplatePartnerRequest:
+		return PartnerRequestData{
+			OrgShortName: "Ромашка",
+			Status:       "needs_info",
+			Reason:       "Приложите скан свидетельства о постановке на учёт",
+		}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,54 @@
+package mail
+
+import "testing"
+
+func TestMatchLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"en", LangEN},
+		{"en-US,en;q=0.9,ru;q=0.8", LangEN},
+		{"ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7", LangRU},
+		{"de-DE,de;q=0.9,en;q=0.5", LangEN},
+		{"fr;q=1.0, RU;q=0.3", LangRU},
+		{"de, fr", ""},
+		{"en;q=0.2, ru;q=0.7", LangRU},
+		{"en;q=abc", ""},
+	}
+	for _, tt := range tests {
+		if got := MatchLanguage(tt.header); got != tt.want {
+			t.Errorf("MatchLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLanguage(t *testing.T) {
+	renderer, err := NewRenderer(DefaultTemplates(), LangRU)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lang        string
+		wantSubject string
+	}{
+		{"", "Код подтверждения регистрации"},
+		{LangRU, "Код подтверждения регистрации"},
+		{LangEN, "Your registration code"},
+	}
+	for _, tt := range tests {
+		msg, err := renderer.Render(TemplateVerificationCode, tt.lang, CodeData{Code: "A1B2C3"})
+		if err != nil {
+			t.Fatalf("Render(%q): %v", tt.lang, err)
+		}
+		if msg.Subject != tt.wantSubject {
+			t.Errorf("Render(%q) subject = %q, want %q", tt.lang, msg.Subject, tt.wantSubject)
+		}
+	}
+
+	if _, err := renderer.Render(TemplateVerificationCode, "de", CodeData{}); err == nil {
+		t.Error("Render with unsupported language must fail")
+	}
+}
@@ -0,0 +1,260 @@
+package mail
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Transport доставляет готовое письмо получателю
+type Transport interface {
+	Send(msg *Message) error
+}
+
+// Режимы защиты SMTP-соединения
+const (
+	SecurityAuto     = "auto"     // STARTTLS, если сервер его поддерживает
+	SecurityStartTLS = "starttls" // обязательный STARTTLS
+	SecurityTLS      = "tls"      // TLS с момента подключения (обычно порт 465)
+	SecurityNone     = "none"     // без шифрования (только для локальных серверов)
+)
+
+var (
+	ErrInvalidSecurity    = errors.New("smtp security must be auto, starttls, tls or none")
+	ErrStartTLSMissing    = errors.New("smtp server does not support STARTTLS")
+	ErrMailboxMsgNotFound = errors.New("mailbox message not found")
+)
+
+// SMTPConfig - параметры подключения к SMTP-серверу
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Security string
+	Timeout  time.Duration
+}
+
+// SMTPTransport отправляет письма через SMTP.
+// Соединение устанавливается на каждое письмо, поэтому недоступность сервера
+// при старте не мешает запуску приложения - письма дождутся его в outbox
+type SMTPTransport struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPTransport создаёт новый экземпляр SMTPTransport
+func NewSMTPTransport(cfg SMTPConfig) (*SMTPTransport, error) {
+	switch cfg.Security {
+	case "":
+		cfg.Security = SecurityAuto
+	case SecurityAuto, SecurityStartTLS, SecurityTLS, SecurityNone:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSecurity, cfg.Security)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15 * time.Second
+	}
+	return &SMTPTransport{cfg: cfg}, nil
+}
+
+// Send отправляет письмо
+func (t *SMTPTransport) Send(msg *Message) error {
+	client, err := t.connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(addressOf(msg.From)); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := client.Rcpt(addressOf(msg.To)); err != nil {
+		return fmt.Errorf("smtp RCPT TO: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return fmt.Errorf("smtp write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp close message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// Ping проверяет, что SMTP-сервер доступен и принимает учётные данные
+func (t *SMTPTransport) Ping() error {
+	client, err := t.connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Quit()
+}
+
+// Подключение, включение шифрования и аутентификация
+func (t *SMTPTransport) connect() (*smtp.Client, error) {
+	addr := net.JoinHostPort(t.cfg.Host, t.cfg.Port)
+	tlsConfig := &tls.Config{ServerName: t.cfg.Host}
+	dialer := &net.Dialer{Timeout: t.cfg.Timeout}
+
+	var conn net.Conn
+	var err error
+	if t.cfg.Security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp connect %s: %w", addr, err)
+	}
+	// Ограничение общего времени сеанса
+	conn.SetDeadline(time.Now().Add(t.cfg.Timeout))
+
+	client, err := smtp.NewClient(conn, t.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake: %w", err)
+	}
+
+	if t.cfg.Security == SecurityAuto || t.cfg.Security == SecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("smtp STARTTLS: %w", err)
+			}
+		} else if t.cfg.Security == SecurityStartTLS {
+			client.Close()
+			return nil, ErrStartTLSMissing
+		}
+	}
+
+	if t.cfg.Username != "" {
+		auth := smtp.PlainAuth("", t.cfg.Username, t.cfg.Password, t.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// Адрес из значения вида "Имя <email>"
+func addressOf(value string) string {
+	if start := strings.LastIndex(value, "<"); start >= 0 {
+		if end := strings.LastIndex(value, ">"); end > start {
+			return value[start+1 : end]
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// FileTransport сохраняет письма в каталог в виде .eml файлов
+type FileTransport struct {
+	dir string
+}
+
+// NewFileTransport создаёт новый экземпляр FileTransport, создавая каталог при необходимости
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create mail dir %s: %w", dir, err)
+	}
+	return &FileTransport{dir: dir}, nil
+}
+
+// Send записывает письмо в файл <время>-<id>.eml
+func (t *FileTransport) Send(msg *Message) error {
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), uuid.New())
+	path := filepath.Join(t.dir, name)
+	if err := os.WriteFile(path, msg.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("write mail file %s: %w", path, err)
+	}
+	return nil
+}
+
+// MailboxEntry - письмо, сохранённое в Mailbox
+type MailboxEntry struct {
+	ID         uuid.UUID `json:"id" example:"0b5a3a3e-6a1b-4b7e-9a55-2f0d3f8b1c11"`
+	ReceivedAt time.Time `json:"received_at" example:"2026-03-30T06:06:47.181805Z"`
+	Message
+}
+
+// Mailbox хранит письма в памяти (для локальной разработки и тестов).
+// Хранится не больше limit последних писем
+type Mailbox struct {
+	mu       sync.Mutex
+	limit    int
+	messages []*MailboxEntry
+}
+
+// NewMailbox создаёт новый экземпляр Mailbox
+func NewMailbox(limit int) *Mailbox {
+	return &Mailbox{limit: limit}
+}
+
+// Send сохраняет письмо в памяти
+func (b *Mailbox) Send(msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.messages = append(b.messages, &MailboxEntry{
+		ID:         uuid.New(),
+		ReceivedAt: time.Now().UTC(),
+		Message:    *msg,
+	})
+	if len(b.messages) > b.limit {
+		b.messages = b.messages[len(b.messages)-b.limit:]
+	}
+	return nil
+}
+
+// List возвращает письма, начиная с последнего. Если to не пустой, только письма этому получателю
+func (b *Mailbox) List(to string) []*MailboxEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]*MailboxEntry, 0, len(b.messages))
+	for i := len(b.messages) - 1; i >= 0; i-- {
+		entry := b.messages[i]
+		if to != "" && !strings.EqualFold(addressOf(entry.To), to) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// Get возвращает письмо по ID.
+// Если письмо не найдено, возвращает ErrMailboxMsgNotFound
+func (b *Mailbox) Get(id uuid.UUID) (*MailboxEntry, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, entry := range b.messages {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return nil, ErrMailboxMsgNotFound
+}
+
+// Clear удаляет все письма
+func (b *Mailbox) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = nil
+}
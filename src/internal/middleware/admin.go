@@ -4,43 +4,26 @@ import (
 	"context"
 	"net/http"
 
-	"github.com/golang-jwt/jwt/v5"
+	"src/internal/rbac"
 )
 
-// Интерфейс для проверки админа
-type AdminChecker interface {
-	IsAdmin(email string) (bool, error)
-}
-
-type AdminMiddleware struct {
-	adminChecker AdminChecker
-}
+// AdminMiddleware проверяет роль администратора по claims access токена, без обращения к БД.
+// Актуальность роли обеспечивается отзывом токенов при её изменении
+type AdminMiddleware struct{}
 
-func NewAdminMiddleware(adminChecker AdminChecker) *AdminMiddleware {
-	return &AdminMiddleware{adminChecker: adminChecker}
+func NewAdminMiddleware() *AdminMiddleware {
+	return &AdminMiddleware{}
 }
 
 func (m *AdminMiddleware) RequireAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		claims, ok := r.Context().Value("user").(jwt.MapClaims)
+		principal, ok := PrincipalFromContext(r.Context())
 		if !ok {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		email, ok := claims["email"].(string)
-		if !ok || email == "" {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		isAdmin, err := m.adminChecker.IsAdmin(email)
-		if err != nil {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-
-		if !isAdmin {
+		if principal.Role != rbac.RoleAdmin {
 			http.Error(w, "Forbidden: admin access required", http.StatusForbidden)
 			return
 		}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"src/internal/rbac"
+)
+
+// APIKeyAuthenticator проверяет API ключ компании. Для неизвестного ключа возвращает nil без ошибки,
+// для ключа приостановленной компании - rbac.ErrCompanySuspended
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key, ip string) (*rbac.Principal, error)
+}
+
+// APIKeyMiddleware принимает API ключ компании из заголовка X-API-Key,
+// а запросы без него передаёт в AuthMiddleware (Bearer токен)
+type APIKeyMiddleware struct {
+	keys   APIKeyAuthenticator
+	bearer *AuthMiddleware
+}
+
+func NewAPIKeyMiddleware(keys APIKeyAuthenticator, bearer *AuthMiddleware) *APIKeyMiddleware {
+	return &APIKeyMiddleware{keys: keys, bearer: bearer}
+}
+
+// Проверка API ключа или access token
+func (m *APIKeyMiddleware) Authenticate(next http.Handler) http.Handler {
+	bearer := m.bearer.Authenticate(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			bearer.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := m.keys.AuthenticateAPIKey(key, ClientIP(r))
+		if errors.Is(err, rbac.ErrCompanySuspended) {
+			http.Error(w, "Company is suspended", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Printf("api key: failed to authenticate: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if principal == nil {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		// У ключа нет claims access токена: обработчики получают его через PrincipalFromContext
+		ctx := WithPrincipal(r.Context(), *principal)
+		ctx = WithCredentials(ctx, m.keyCredentials(key, ClientIP(r)))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Учётные данные API ключа: у ключа нет срока действия, он действует, пока его не отозвали
+func (m *APIKeyMiddleware) keyCredentials(key, ip string) Credentials {
+	return Credentials{Check: func() error {
+		principal, err := m.keys.AuthenticateAPIKey(key, ip)
+		if err != nil {
+			return err
+		}
+		if principal == nil {
+			return ErrCredentialsRevoked
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP возвращает IP адрес клиента из RemoteAddr.
+// За обратным прокси адрес клиента подставляет chi RealIP (включается через TRUST_PROXY=true)
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -2,19 +2,33 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"src/internal/jwtkeys"
+	"src/internal/rbac"
 )
 
-// Middleware для проверки JWT токена
+// RevocationChecker проверяет, отозваны ли токены пользователя (например, после смены роли)
+// или отдельный токен по jti (после выхода или завершения сессии)
+type RevocationChecker interface {
+	IsRevoked(email string, issuedAt time.Time) bool
+	IsTokenRevoked(jti string) bool
+}
+
+// Middleware для проверки JWT токена. Подпись проверяется ключом из keys по kid токена
 type AuthMiddleware struct {
-	secretKey string
+	keys        *jwtkeys.KeySet
+	revocations RevocationChecker
 }
 
-func NewAuthMiddleware(secretKey string) *AuthMiddleware {
-	return &AuthMiddleware{secretKey: secretKey}
+func NewAuthMiddleware(keys *jwtkeys.KeySet, revocations RevocationChecker) *AuthMiddleware {
+	return &AuthMiddleware{keys: keys, revocations: revocations}
 }
 
 // Проверка access token
@@ -37,12 +51,7 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 		tokenString := parts[1]
 
 		// Парсинг токена
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(m.secretKey), nil
-		})
+		token, err := jwt.Parse(tokenString, m.keys.Keyfunc, jwt.WithValidMethods(m.keys.Methods()))
 
 		if err != nil || !token.Valid {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
@@ -61,8 +70,140 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		// Добавление информации о пользователе в контекст
+		// Проверка отзыва: после смены роли токен нужно обновить через /auth/refresh
+		email, _ := claims["email"].(string)
+		issuedAt, err := claims.GetIssuedAt()
+		if err != nil || issuedAt == nil {
+			http.Error(w, "Token is outdated, refresh it", http.StatusUnauthorized)
+			return
+		}
+		if m.revocations.IsRevoked(email, issuedAt.Time) {
+			http.Error(w, "Token revoked, refresh it", http.StatusUnauthorized)
+			return
+		}
+
+		// Токены без jti выданы до появления отзыва по jti и истекают в пределах ACCESS_TOKEN_TTL
+		if jti, _ := claims["jti"].(string); jti != "" && m.revocations.IsTokenRevoked(jti) {
+			http.Error(w, "Token revoked", http.StatusUnauthorized)
+			return
+		}
+
+		// Добавление информации о пользователе в контекст: claims для обработчиков,
+		// которые читают их напрямую, и пользователь для RequirePermission
 		ctx := context.WithValue(r.Context(), "user", claims)
+		ctx = WithCredentials(ctx, m.tokenCredentials(claims, email, issuedAt.Time))
+		if principal, ok := principalFromClaims(claims); ok {
+			ctx = WithPrincipal(ctx, principal)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Ключ контекста для пользователя, от имени которого выполняется запрос
+type principalKey struct{}
+
+// WithPrincipal добавляет в контекст пользователя, от имени которого выполняется запрос
+func WithPrincipal(ctx context.Context, principal rbac.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext возвращает пользователя access токена (Authenticate) или API ключ компании (APIKeyMiddleware)
+func PrincipalFromContext(ctx context.Context) (rbac.Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(rbac.Principal)
+	return principal, ok
+}
+
+// Пользователь из claims access токена. Токены без роли выданы до появления ролей и должны быть обновлены
+func principalFromClaims(claims jwt.MapClaims) (rbac.Principal, bool) {
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+	inn, _ := claims["inn"].(string)
+	companyRole, _ := claims["company_role"].(string)
+	if email == "" || role == "" {
+		return rbac.Principal{}, false
+	}
+	return rbac.Principal{Email: email, Role: role, INN: inn, CompanyRole: companyRole}, true
+}
+
+// RequirePermission пропускает запрос, только если у пользователя есть разрешение
+// (разрешения в компании - по роли в компании, см. rbac.Principal.HasPermission).
+// Запрос с API ключом пропускается, только если у ключа есть одно из прав scopes,
+// без scopes маршрут недоступен по API ключу. Используется после Authenticate
+func RequirePermission(permission string, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, "Token is outdated, refresh it", http.StatusUnauthorized)
+				return
+			}
+
+			if !principal.HasPermission(permission) {
+				http.Error(w, "Forbidden: missing permission "+permission, http.StatusForbidden)
+				return
+			}
+
+			if principal.IsAPIKey() && !slices.ContainsFunc(scopes, func(scope string) bool {
+				return slices.Contains(principal.Scopes, scope)
+			}) {
+				if len(scopes) == 0 {
+					http.Error(w, "Forbidden: not available with API key", http.StatusForbidden)
+					return
+				}
+				http.Error(w, "Forbidden: API key missing scope "+strings.Join(scopes, " or "), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var (
+	// ErrCredentialsExpired - срок действия токена истёк, пока запрос выполнялся
+	ErrCredentialsExpired = errors.New("credentials expired")
+	// ErrCredentialsRevoked - токен отозван, пока запрос выполнялся
+	ErrCredentialsRevoked = errors.New("credentials revoked")
+)
+
+// Credentials - срок действия учётных данных запроса и их повторная проверка.
+// Долгие запросы (поток событий) перепроверяют их, пока соединение открыто
+type Credentials struct {
+	ExpiresAt time.Time    // нулевое значение - без срока действия
+	Check     func() error // повторяет проверки, выполненные при аутентификации запроса
+}
+
+// Ключ контекста для учётных данных запроса
+type credentialsKey struct{}
+
+// WithCredentials добавляет в контекст учётные данные запроса
+func WithCredentials(ctx context.Context, credentials Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, credentials)
+}
+
+// CredentialsFromContext возвращает учётные данные запроса (Authenticate)
+func CredentialsFromContext(ctx context.Context) (Credentials, bool) {
+	credentials, ok := ctx.Value(credentialsKey{}).(Credentials)
+	return credentials, ok
+}
+
+// Учётные данные access токена: токен действует до exp, если не отозван
+func (m *AuthMiddleware) tokenCredentials(claims jwt.MapClaims, email string, issuedAt time.Time) Credentials {
+	var expiresAt time.Time
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+	return Credentials{
+		ExpiresAt: expiresAt,
+		Check: func() error {
+			if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+				return ErrCredentialsExpired
+			}
+			jti, _ := claims["jti"].(string)
+			if m.revocations.IsRevoked(email, issuedAt) || jti != "" && m.revocations.IsTokenRevoked(jti) {
+				return ErrCredentialsRevoked
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"src/internal/jwtkeys"
+	"src/internal/rbac"
+)
+
+func TestPrincipalFromClaims(t *testing.T) {
+	claims := jwt.MapClaims{"email": "a@example.com", "role": rbac.RoleAdmin, "inn": "7700000000", "company_role": rbac.CompanyRoleOwner}
+	principal, ok := principalFromClaims(claims)
+	want := rbac.Principal{Email: "a@example.com", Role: rbac.RoleAdmin, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+	if !ok || principal.Email != want.Email || principal.Role != want.Role || principal.INN != want.INN || principal.CompanyRole != want.CompanyRole || principal.IsAPIKey() {
+		t.Errorf("principalFromClaims = %+v, %v, want %+v", principal, ok, want)
+	}
+
+	// Access токен не может выдать себя за API ключ
+	claims["api_key_id"] = "k1"
+	claims["scopes"] = []string{rbac.ScopeOrdersRead}
+	if principal, _ := principalFromClaims(claims); principal.IsAPIKey() || principal.Scopes != nil {
+		t.Errorf("principal from token claims has api key fields: %+v", principal)
+	}
+
+	if _, ok := principalFromClaims(jwt.MapClaims{"email": "a@example.com"}); ok {
+		t.Error("claims without role accepted")
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	member := func(role, companyRole string) *rbac.Principal {
+		return &rbac.Principal{Email: "user@example.com", Role: role, INN: "7700000000", CompanyRole: companyRole}
+	}
+	apiKey := &rbac.Principal{Role: rbac.RolePartner, INN: "7700000000", APIKeyID: "k1", Scopes: []string{rbac.ScopeOrdersRead}}
+
+	tests := []struct {
+		name       string
+		principal  *rbac.Principal
+		permission string
+		scopes     []string
+		want       int
+	}{
+		{"no principal", nil, rbac.PermCompanyView, nil, http.StatusUnauthorized},
+		{"client", &rbac.Principal{Email: "c@example.com", Role: rbac.RoleClient}, rbac.PermCompanyView, nil, http.StatusForbidden},
+		{"partner operator", member(rbac.RolePartner, rbac.CompanyRoleOperator), rbac.PermCompanyOrders, nil, http.StatusOK},
+		{"partner operator prices", member(rbac.RolePartner, rbac.CompanyRoleOperator), rbac.PermCompanyPrices, nil, http.StatusForbidden},
+		{"admin member", member(rbac.RoleAdmin, rbac.CompanyRoleOwner), rbac.PermCompanyMembers, nil, http.StatusOK},
+		{"admin not member", &rbac.Principal{Email: "a@example.com", Role: rbac.RoleAdmin}, rbac.PermCompanyView, nil, http.StatusForbidden},
+		{"api key route scope", apiKey, rbac.PermCompanyOrders, []string{rbac.ScopeOrdersRead}, http.StatusOK},
+		{"api key route without scopes", apiKey, rbac.PermCompanyOrders, nil, http.StatusForbidden},
+		{"api key client route", apiKey, rbac.PermOrdersOwn, nil, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequirePermission(tt.permission, tt.scopes...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/company", nil)
+			if tt.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), *tt.principal))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// Отзывы по пользователю и по jti. revoked - момент смены роли
+type fakeRevocations struct {
+	revoked time.Time
+	tokens  map[string]bool
+}
+
+func (f *fakeRevocations) IsRevoked(email string, issuedAt time.Time) bool {
+	return issuedAt.Before(f.revoked)
+}
+
+func (f *fakeRevocations) IsTokenRevoked(jti string) bool {
+	return f.tokens[jti]
+}
+
+// Учётные данные запроса перепроверяются: токен, отозванный после начала запроса, перестаёт действовать
+func TestAuthenticateCredentials(t *testing.T) {
+	revocations := &fakeRevocations{}
+	_, signer, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keys, err := jwtkeys.NewKeySet(signer)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	m := NewAuthMiddleware(keys, revocations)
+	now := time.Now()
+	token, err := keys.Sign(jwt.MapClaims{
+		"email": "p@example.com",
+		"type":  "access",
+		"role":  rbac.RolePartner,
+		"jti":   "0b6f9d2e-3a47-4e58-b1c9-7e2a5d8f6c31",
+		"iat":   now.Add(-time.Minute).Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+	})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	var credentials Credentials
+	handler := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credentials, _ = CredentialsFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/company/orders/stream", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if credentials.Check == nil || credentials.ExpiresAt.Unix() != now.Add(time.Minute).Unix() {
+		t.Fatalf("credentials = %+v", credentials)
+	}
+	if err := credentials.Check(); err != nil {
+		t.Fatalf("Check = %v", err)
+	}
+	revocations.tokens = map[string]bool{"0b6f9d2e-3a47-4e58-b1c9-7e2a5d8f6c31": true}
+	if err := credentials.Check(); !errors.Is(err, ErrCredentialsRevoked) {
+		t.Errorf("Check after session end = %v, want ErrCredentialsRevoked", err)
+	}
+	revocations.tokens = nil
+	revocations.revoked = now
+	if err := credentials.Check(); !errors.Is(err, ErrCredentialsRevoked) {
+		t.Errorf("Check after role change = %v, want ErrCredentialsRevoked", err)
+	}
+}
+
+// API ключи в памяти. revoked - ключ отозван
+type fakeAPIKeys struct {
+	revoked bool
+}
+
+func (f *fakeAPIKeys) AuthenticateAPIKey(key, ip string) (*rbac.Principal, error) {
+	if f.revoked {
+		return nil, nil
+	}
+	return &rbac.Principal{Role: rbac.RolePartner, INN: "7700000000", APIKeyID: "k1"}, nil
+}
+
+// Ключ, отозванный после начала запроса, перестаёт действовать
+func TestAPIKeyCredentials(t *testing.T) {
+	keys := &fakeAPIKeys{}
+	m := NewAPIKeyMiddleware(keys, &AuthMiddleware{})
+
+	var credentials Credentials
+	handler := m.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credentials, _ = CredentialsFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/company/orders/stream", nil)
+	req.Header.Set(APIKeyHeader, "key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if credentials.Check == nil || !credentials.ExpiresAt.IsZero() {
+		t.Fatalf("credentials = %+v", credentials)
+	}
+	if err := credentials.Check(); err != nil {
+		t.Fatalf("Check = %v", err)
+	}
+	keys.revoked = true
+	if err := credentials.Check(); !errors.Is(err, ErrCredentialsRevoked) {
+		t.Errorf("Check after revocation = %v, want ErrCredentialsRevoked", err)
+	}
+}
@@ -0,0 +1,318 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Заголовок с API ключом компании
+const APIKeyHeader = "X-API-Key"
+
+// RateLimit - ограничение по алгоритму token bucket: Requests запросов за Per
+// с возможным всплеском до Burst запросов (по умолчанию Burst = Requests)
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// Скорость пополнения в токенах в секунду
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// Время, за которое пустой bucket заполняется полностью; после него состояние можно не хранить
+func (l RateLimit) ttl() time.Duration {
+	return time.Duration(l.burst() / l.rate() * float64(time.Second))
+}
+
+// RateLimitStore хранит состояние bucket'ов. Take забирает один токен и возвращает,
+// разрешён ли запрос и через сколько появится следующий токен
+type RateLimitStore interface {
+	Take(key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// KeyFunc определяет, по какому признаку считается ограничение
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP - ограничение по IP адресу клиента
+func KeyByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// KeyByPrincipal - ограничение по проверенному пользователю из access токена или по ID API ключа компании.
+// Используется после Authenticate; без них ограничение считается по IP
+func KeyByPrincipal(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		if principal.IsAPIKey() {
+			return "apikey:" + principal.APIKeyID
+		}
+		return "user:" + principal.Email
+	}
+	return KeyByIP(r)
+}
+
+// Сколько байт тела читает KeyByRequestEmail; у запросов с адресом почты тело небольшое
+const maxRequestEmailBody = 64 << 10
+
+// KeyByRequestEmail - ограничение по адресу почты из поля email JSON тела запроса, чтобы письма
+// на один адрес нельзя было отправлять с разных IP. Тело остаётся доступным обработчику.
+// Без адреса в теле ограничение считается как в KeyByPrincipal
+func KeyByRequestEmail(r *http.Request) string {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestEmailBody))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return KeyByPrincipal(r)
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil || strings.TrimSpace(req.Email) == "" {
+		return KeyByPrincipal(r)
+	}
+	return "email:" + strings.ToLower(strings.TrimSpace(req.Email))
+}
+
+// Прочитанное начало тела и его остаток с закрытием исходного тела
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// RateLimiter создаёт middleware ограничения частоты запросов
+type RateLimiter struct {
+	store    RateLimitStore
+	failOpen bool
+}
+
+// NewRateLimiter создаёт новый экземпляр RateLimiter. С nil хранилищем ограничения отключены.
+// failOpen - пропускать ли запросы при недоступности хранилища; иначе они отклоняются с 503
+func NewRateLimiter(store RateLimitStore, failOpen bool) *RateLimiter {
+	return &RateLimiter{store: store, failOpen: failOpen}
+}
+
+// Limit возвращает middleware с ограничением limit. name отделяет bucket'ы разных групп маршрутов,
+// поэтому один и тот же клиент расходует лимит каждой группы независимо
+func (l *RateLimiter) Limit(name string, limit RateLimit, keyFunc KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l.store == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := l.store.Take("ratelimit:"+name+":"+keyFunc(r), limit)
+			if err != nil {
+				if l.failOpen {
+					log.Printf("ratelimit: %s: store unavailable, request allowed: %v", name, err)
+					next.ServeHTTP(w, r)
+					return
+				}
+				log.Printf("ratelimit: %s: store unavailable, request rejected: %v", name, err)
+				http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Время ожидания следующего токена при текущем остатке tokens
+func retryAfter(tokens float64, limit RateLimit) time.Duration {
+	return time.Duration((1 - tokens) / limit.rate() * float64(time.Second))
+}
+
+// MemoryRateLimitStore хранит bucket'ы в памяти процесса.
+// Подходит только для одного экземпляра приложения
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	store := &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+	go store.cleanupLoop()
+	return store
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: limit.burst(), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = min(limit.burst(), b.tokens+now.Sub(b.updatedAt).Seconds()*limit.rate())
+	b.updatedAt = now
+	b.expiresAt = now.Add(limit.ttl())
+
+	if b.tokens < 1 {
+		return false, retryAfter(b.tokens, limit), nil
+	}
+	b.tokens--
+	return true, 0, nil
+}
+
+// Удаление полностью восстановившихся bucket'ов каждую минуту
+func (s *MemoryRateLimitStore) cleanupLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		s.mu.Lock()
+		for key, b := range s.buckets {
+			if now.After(b.expiresAt) {
+				delete(s.buckets, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// PostgresRateLimitStore хранит bucket'ы в таблице rate_limits, общей для всех экземпляров.
+// Каждый запрос - одна атомарная запись, поэтому подходит для умеренной нагрузки
+type PostgresRateLimitStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRateLimitStore(db *sql.DB) *PostgresRateLimitStore {
+	store := &PostgresRateLimitStore{db: db}
+	go store.cleanupLoop()
+	return store
+}
+
+func (s *PostgresRateLimitStore) Take(key string, limit RateLimit) (bool, time.Duration, error) {
+	// $2 - ёмкость, $3 - скорость пополнения в секунду, $4 - время хранения в секундах
+	query := `INSERT INTO rate_limits AS rl (key, tokens, allowed, updated_at, expires_at)
+              VALUES ($1, $2::float8 - 1, TRUE, NOW(), NOW() + make_interval(secs => $4))
+              ON CONFLICT (key) DO UPDATE
+              SET tokens = CASE
+                      WHEN LEAST($2::float8, rl.tokens + EXTRACT(EPOCH FROM NOW() - rl.updated_at) * $3::float8) >= 1
+                      THEN LEAST($2::float8, rl.tokens + EXTRACT(EPOCH FROM NOW() - rl.updated_at) * $3::float8) - 1
+                      ELSE LEAST($2::float8, rl.tokens + EXTRACT(EPOCH FROM NOW() - rl.updated_at) * $3::float8)
+                  END,
+                  allowed = LEAST($2::float8, rl.tokens + EXTRACT(EPOCH FROM NOW() - rl.updated_at) * $3::float8) >= 1,
+                  updated_at = NOW(),
+                  expires_at = NOW() + make_interval(secs => $4)
+              RETURNING allowed, tokens`
+
+	var allowed bool
+	var tokens float64
+	err := s.db.QueryRow(query, key, limit.burst(), limit.rate(), limit.ttl().Seconds()).Scan(&allowed, &tokens)
+	if err != nil {
+		return false, 0, err
+	}
+	if !allowed {
+		return false, retryAfter(tokens, limit), nil
+	}
+	return true, 0, nil
+}
+
+// Удаление полностью восстановившихся bucket'ов каждый час
+func (s *PostgresRateLimitStore) cleanupLoop() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, _ = s.db.Exec(`DELETE FROM rate_limits WHERE expires_at < NOW()`)
+	}
+}
+
+// Token bucket на стороне Redis. Время берётся с сервера, чтобы расхождение часов
+// экземпляров приложения не влияло на ограничение. ARGV: ёмкость, скорость в секунду
+var redisTakeTokenScript = redis.NewScript(`
+if redis.replicate_commands then redis.replicate_commands() end
+local burst = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return {allowed, tostring(tokens)}`)
+
+var errInvalidRedisReply = errors.New("redis: unexpected reply to rate limit script")
+
+// RedisRateLimitStore хранит bucket'ы в Redis, общем для всех экземпляров
+type RedisRateLimitStore struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimitStore(client *redis.Client) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client}
+}
+
+func (s *RedisRateLimitStore) Take(key string, limit RateLimit) (bool, time.Duration, error) {
+	items, err := redisTakeTokenScript.Run(context.Background(), s.client, []string{key},
+		strconv.FormatFloat(limit.burst(), 'f', -1, 64),
+		strconv.FormatFloat(limit.rate(), 'f', -1, 64),
+	).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if len(items) != 2 {
+		return false, 0, errInvalidRedisReply
+	}
+	allowed, ok := items[0].(int64)
+	if !ok {
+		return false, 0, errInvalidRedisReply
+	}
+	tokensValue, _ := items[1].(string)
+	tokens, err := strconv.ParseFloat(tokensValue, 64)
+	if err != nil {
+		return false, 0, errInvalidRedisReply
+	}
+
+	if allowed == 0 {
+		return false, retryAfter(tokens, limit), nil
+	}
+	return true, 0, nil
+}
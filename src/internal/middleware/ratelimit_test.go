@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"src/internal/rbac"
+)
+
+func TestRedisRateLimitStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := NewRedisRateLimitStore(client)
+	limit := RateLimit{Requests: 2, Per: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take("ratelimit:test", limit)
+		if err != nil || !allowed {
+			t.Fatalf("Take #%d = %v, %v", i+1, allowed, err)
+		}
+	}
+
+	allowed, retry, err := store.Take("ratelimit:test", limit)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if allowed {
+		t.Fatal("request over the limit is allowed")
+	}
+	if retry <= 0 || retry > 30*time.Second {
+		t.Errorf("retry after = %v", retry)
+	}
+
+	// Другой ключ считается отдельно
+	if allowed, _, err := store.Take("ratelimit:other", limit); err != nil || !allowed {
+		t.Errorf("Take for other key = %v, %v", allowed, err)
+	}
+	if ttl := server.TTL("ratelimit:test"); ttl <= 0 {
+		t.Errorf("bucket ttl = %v", ttl)
+	}
+}
+
+func TestKeyByPrincipal(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *rbac.Principal
+		apiKey    string
+		want      string
+	}{
+		{"user", &rbac.Principal{Email: "user@example.com"}, "", "user:user@example.com"},
+		{"api key", &rbac.Principal{APIKeyID: "key-1", INN: "7700000000"}, "pk_live_secret", "apikey:key-1"},
+		// Непроверенный заголовок не влияет на ключ: без principal считается IP
+		{"unauthenticated with api key header", nil, "pk_forged", "ip:203.0.113.7"},
+		{"unauthenticated", nil, "", "ip:203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/company", nil)
+			req.RemoteAddr = "203.0.113.7:51234"
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), *tt.principal))
+			}
+			if got := KeyByPrincipal(req); got != tt.want {
+				t.Errorf("KeyByPrincipal = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyByRequestEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"email", `{"email":" User@Example.com ","password":"1A_password"}`, "email:user@example.com"},
+		{"no email", `{"new_email":"new@example.com"}`, "ip:203.0.113.7"},
+		{"invalid json", `{"email":`, "ip:203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(tt.body))
+			req.RemoteAddr = "203.0.113.7:51234"
+
+			if got := KeyByRequestEmail(req); got != tt.want {
+				t.Errorf("KeyByRequestEmail = %q, want %q", got, tt.want)
+			}
+			// Обработчик получает тело целиком
+			body, err := io.ReadAll(req.Body)
+			if err != nil || string(body) != tt.body {
+				t.Errorf("body after key = %q, %v", body, err)
+			}
+		})
+	}
+}
+
+// Ограничение по адресу почты действует независимо от IP
+func TestLimitByRequestEmail(t *testing.T) {
+	limiter := NewRateLimiter(NewMemoryRateLimitStore(), true)
+	handler := limiter.Limit("auth-email-address", RateLimit{Requests: 2, Per: time.Hour}, KeyByRequestEmail)(okHandler())
+
+	statuses := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/auth/forgot-password", strings.NewReader(`{"email":"user@example.com"}`))
+		req.RemoteAddr = fmt.Sprintf("203.0.113.%d:51234", i+1)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		statuses = append(statuses, rec.Code)
+	}
+	if statuses[0] != http.StatusOK || statuses[1] != http.StatusOK || statuses[2] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v", statuses)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(key string, limit RateLimit) (bool, time.Duration, error) {
+	return false, 0, errors.New("connection refused")
+}
+
+func TestLimitStoreUnavailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOpen bool
+		want     int
+	}{
+		{"fail open", true, http.StatusOK},
+		{"fail closed", false, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(failingRateLimitStore{}, tt.failOpen)
+			handler := limiter.Limit("global", RateLimit{Requests: 1, Per: time.Minute}, KeyByIP)(okHandler())
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -68,6 +68,8 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, ErrBranchServiceNotFound.Error(), http.StatusBadRequest)
 		case errors.Is(err, ErrDetailNotAvailable):
 			http.Error(w, ErrDetailNotAvailable.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrCompanySuspended):
+			http.Error(w, ErrCompanySuspended.Error(), http.StatusForbidden)
 		default:
 
 			http.Error(w, "internal server error", http.StatusInternalServerError)
@@ -1,6 +1,7 @@
 package order
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,6 +10,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"src/internal/db"
+	"src/internal/events"
 )
 
 var (
@@ -27,14 +31,24 @@ var (
 	ErrStartMomemtNotAvailable = errors.New("start moment is not available for the requested details")
 )
 
+// EventPublisher публикует события заказов компании во внутреннюю шину (events.Bus)
+type EventPublisher interface {
+	Publish(companyInn string, branchID uuid.UUID, eventType string, data any) error
+
+	// PublishTx публикует событие изменения в транзакции tx. Возвращённую функцию вызывают после commit
+	PublishTx(tx *sql.Tx, companyInn string, branchID uuid.UUID, eventType string, data any) (func(), error)
+}
+
 // содержит бизнес-логику для работы с услугами.
 type OrderManager struct {
-	storage OrderStorage
+	storage   OrderStorage
+	uow       db.UnitOfWork
+	publisher EventPublisher
 }
 
 // создаёт новый экземпляр OrderManager.
-func NewOrderManager(storage OrderStorage) *OrderManager {
-	return &OrderManager{storage: storage}
+func NewOrderManager(storage OrderStorage, publisher EventPublisher, uow db.UnitOfWork) *OrderManager {
+	return &OrderManager{storage: storage, publisher: publisher, uow: uow}
 }
 
 // Create создаёт новый заказ после проверки доступности выбранного времени.
@@ -69,6 +83,15 @@ func (m *OrderManager) Create(email string, req CreateOrderRequest) (*Order, err
 		return nil, ErrTimeInFuture
 	}
 
+	// Приостановленная компания новых заказов не принимает
+	suspended, err := m.storage.IsCompanySuspendedByBranchServ(req.ServiceByBranch)
+	if err != nil {
+		return nil, err
+	}
+	if suspended {
+		return nil, ErrCompanySuspended
+	}
+
 	detailsDB, priceDB, err := m.storage.GetDetailsByBranchServ(req.ServiceByBranch)
 	if err != nil {
 		return nil, err
@@ -149,24 +172,6 @@ func (m *OrderManager) Create(email string, req CreateOrderRequest) (*Order, err
 		return nil, fmt.Errorf("failed to get branch for service: %w", err)
 	}
 
-	// Проверка доступен ли слот запрошенной длительности
-	slots, err := m.GetFreeTimeForDay(branchID, req.StartMoment, totalMinutes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check free time: %w", err)
-	}
-
-	startUTC := req.StartMoment.UTC()
-	valid := false
-	for _, slot := range slots {
-		if slot.UTC().Equal(startUTC) {
-			valid = true
-			break
-		}
-	}
-	if !valid {
-		return nil, ErrStartMomemtNotAvailable
-	}
-
 	endTime := req.StartMoment.Add(time.Duration(totalMinutes) * time.Minute)
 
 	orderDetailsJSON, err := json.Marshal(detailsReq)
@@ -188,25 +193,89 @@ func (m *OrderManager) Create(email string, req CreateOrderRequest) (*Order, err
 		Price:           orderPriceJSON,
 		Sum:             totalPrice,
 	}
-	orderRes, err := m.storage.Create(order)
+
+	// Проверка слота и создание заказа выполняются в одной транзакции под блокировкой филиала,
+	// иначе два параллельных заказа могут занять одно время
+	var orderRes *Order
+	var notify func()
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		storage := m.storage.WithTx(tx)
+		if err := storage.LockBranch(branchID); err != nil {
+			return err
+		}
+
+		// Проверка доступен ли слот запрошенной длительности
+		slots, err := freeTimeForDay(storage, branchID, req.StartMoment, totalMinutes)
+		if err != nil {
+			return fmt.Errorf("failed to check free time: %w", err)
+		}
+
+		startUTC := req.StartMoment.UTC()
+		valid := false
+		for _, slot := range slots {
+			if slot.UTC().Equal(startUTC) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return ErrStartMomemtNotAvailable
+		}
+
+		orderRes, err = storage.Create(order)
+		if err != nil {
+			return err
+		}
+
+		notify, err = m.publishCreated(tx, storage, orderRes, branchID)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	if notify != nil {
+		notify()
+	}
+
 	return orderRes, err
 }
 
+// Публикация события о новом заказе в транзакции создания заказа: вебхуки ставятся в outbox
+// вместе с заказом. Возвращает функцию отправки события подписчикам после commit
+func (m *OrderManager) publishCreated(tx *sql.Tx, storage OrderStorage, order *Order, branchID uuid.UUID) (func(), error) {
+	if m.publisher == nil {
+		return nil, nil
+	}
+
+	inn, err := storage.GetCompanyInnByBranchServ(order.ServiceByBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve company for event: %w", err)
+	}
+
+	notify, err := m.publisher.PublishTx(tx, inn, branchID, events.OrderCreated, order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish %s: %w", events.OrderCreated, err)
+	}
+	return notify, nil
+}
+
 // GetFreeTimeForWeek возвращает свободные слоты с шагом 15 минут
 // для указанного филиала на день
 func (m *OrderManager) GetFreeTimeForDay(branchID uuid.UUID, day time.Time, duration int) ([]time.Time, error) {
-	openClose, err := m.storage.GetOpenCloseTime(branchID)
+	return freeTimeForDay(m.storage, branchID, day, duration)
+}
+
+// Свободные слоты филиала на день по данным storage (в транзакции - с учётом её изменений)
+func freeTimeForDay(storage OrderStorage, branchID uuid.UUID, day time.Time, duration int) ([]time.Time, error) {
+	openClose, err := storage.GetOpenCloseTime(branchID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get open/close time: %w", err)
 	}
 
 	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
 
-	busy, err := m.storage.GetBisyTimeByDate(branchID, dayStart)
+	busy, err := storage.GetBisyTimeByDate(branchID, dayStart)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get busy time for %s: %w", dayStart.Format("2006-01-02"), err)
 	}
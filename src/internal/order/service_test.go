@@ -0,0 +1,34 @@
+package order
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Хранилище, в котором реализована только проверка компании;
+// остальные методы не должны вызываться
+type suspendedStorage struct {
+	OrderStorage
+	suspended bool
+}
+
+func (s *suspendedStorage) IsCompanySuspendedByBranchServ(branchServID uuid.UUID) (bool, error) {
+	return s.suspended, nil
+}
+
+// Приостановленная компания новых заказов не принимает: заказ отклоняется до расчёта стоимости
+func TestCreateOrderCompanySuspended(t *testing.T) {
+	m := NewOrderManager(&suspendedStorage{suspended: true}, nil, nil)
+
+	_, err := m.Create("client@example.com", CreateOrderRequest{
+		ServiceByBranch: uuid.New(),
+		StartMoment:     time.Now().Add(24 * time.Hour),
+		OrderDetails:    []DetailOnly{{Detail: "Мойка"}},
+	})
+	if !errors.Is(err, ErrCompanySuspended) {
+		t.Fatalf("Create error = %v, want ErrCompanySuspended", err)
+	}
+}
@@ -24,7 +24,17 @@ type OrderStorage interface {
 
 	GetBranchIDByBranchServ(branchServID uuid.UUID) (uuid.UUID, error)
 
+	GetCompanyInnByBranchServ(branchServID uuid.UUID) (string, error)
+
+	IsCompanySuspendedByBranchServ(branchServID uuid.UUID) (bool, error)
+
 	GetDetailsByBranchServ(branchServID uuid.UUID) ([]*ServiceDuration, []*ServPrice, error)
+
+	// LockBranch блокирует филиал до конца транзакции, чтобы заказы в него создавались по очереди
+	LockBranch(branchID uuid.UUID) error
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) OrderStorage
 	//GetFullAllOrders() ([]*FullOrder, error)
 	//GetByCompany(inn string) ([]*FullOrder, error)
 }
@@ -33,6 +43,7 @@ var (
 	ErrBranchServiceNotFound = errors.New("branch service not found")
 	ErrOrdersNotFound        = errors.New("orders not found")
 	ErrBranchNotFound        = errors.New("branch not found")
+	ErrCompanySuspended      = errors.New("company is suspended and does not accept new orders")
 )
 
 // реализует OrderStorage для PostgreSQL.
@@ -44,6 +55,24 @@ func NewPostrgesOrderStorage(sqlDB *sql.DB) *PostgresOrderStorage {
 	return &PostgresOrderStorage{Storage: db.NewStorage(sqlDB)}
 }
 
+// WithTx возвращает PostgresOrderStorage, работающий внутри транзакции tx
+func (s *PostgresOrderStorage) WithTx(tx *sql.Tx) OrderStorage {
+	return &PostgresOrderStorage{Storage: s.Storage.WithTx(tx)}
+}
+
+// LockBranch блокирует строку филиала (SELECT ... FOR UPDATE). Вне транзакции блокировка сразу снимается
+func (s *PostgresOrderStorage) LockBranch(branchID uuid.UUID) error {
+	var id uuid.UUID
+	err := s.DB.QueryRow(`SELECT id FROM branches WHERE id = $1 FOR UPDATE`, branchID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBranchNotFound
+		}
+		return fmt.Errorf("lock branch: %w", err)
+	}
+	return nil
+}
+
 // полуение деталей услуги и их стоимости в виде  []*ServiceDuration и  []*ServPrice
 func (s *PostgresOrderStorage) GetDetailsByBranchServ(branchServID uuid.UUID) ([]*ServiceDuration, []*ServPrice, error) {
 	var detailsJSON json.RawMessage
@@ -225,6 +254,43 @@ func (s *PostgresOrderStorage) GetBranchIDByBranchServ(branchServID uuid.UUID) (
 	return branchID, nil
 }
 
+// GetCompanyInnByBranchServ возвращает ИНН компании, которой принадлежит услуга филиала.
+func (s *PostgresOrderStorage) GetCompanyInnByBranchServ(branchServID uuid.UUID) (string, error) {
+	var inn string
+	err := s.DB.QueryRow(`
+        SELECT b.inn_company
+        FROM branch_services bs
+        JOIN branches b ON bs.branch = b.id
+        WHERE bs.id = $1
+    `, branchServID).Scan(&inn)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("branch_service with id %s not found", branchServID)
+		}
+		return "", fmt.Errorf("failed to query company inn: %w", err)
+	}
+	return inn, nil
+}
+
+// IsCompanySuspendedByBranchServ проверяет, приостановлена ли компания, которой принадлежит услуга филиала.
+func (s *PostgresOrderStorage) IsCompanySuspendedByBranchServ(branchServID uuid.UUID) (bool, error) {
+	var suspended bool
+	err := s.DB.QueryRow(`
+        SELECT cmp.suspended_at IS NOT NULL
+        FROM branch_services bs
+        JOIN branches b ON bs.branch = b.id
+        JOIN companies cmp ON b.inn_company = cmp.inn
+        WHERE bs.id = $1
+    `, branchServID).Scan(&suspended)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrBranchServiceNotFound
+		}
+		return false, fmt.Errorf("failed to query company status: %w", err)
+	}
+	return suspended, nil
+}
+
 // Выводит полную информацию о заказе для определённого клиента
 func (s *PostgresOrderStorage) GetByClient(email string) ([]*FullOrder, error) {
 	rows, err := s.DB.Query(`
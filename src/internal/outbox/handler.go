@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Ограничения на размер выборки GET /admin/outbox
+const (
+	defaultLimit = 100
+	maxLimit     = 500
+)
+
+// Handler обрабатывает HTTP-запросы для просмотра и повтора исходящих сообщений
+type Handler struct {
+	outbox *OutboxManager
+}
+
+// NewHandler создаёт новый экземпляр Handler
+func NewHandler(outbox *OutboxManager) *Handler {
+	return &Handler{outbox: outbox}
+}
+
+// GetMessages обрабатывает GET /admin/outbox?status=dead&limit=100, возвращает сообщения по статусу
+func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	limit := defaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxLimit), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	messages, err := h.outbox.GetMessages(status, limit)
+	if err != nil {
+		if errors.Is(err, ErrInvalidStatus) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// GetMessage обрабатывает GET /admin/outbox/{id}
+func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := h.outbox.GetMessage(id)
+	if err != nil {
+		if errors.Is(err, ErrMessageNotFound) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(msg)
+}
+
+// ReplayMessage обрабатывает POST /admin/outbox/{id}/replay, возвращает сообщение в очередь
+func (h *Handler) ReplayMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.outbox.Replay(id); err != nil {
+		if errors.Is(err, ErrMessageNotFound) {
+			http.Error(w, "Failed message not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Message queued for redelivery",
+		"id":      id.String(),
+		"status":  StatusPending,
+	})
+}
@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Каналы доставки сообщений
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Виды сообщений
+const (
+	KindVerificationCode = "verification_code"
+	KindResetCode        = "reset_code"
+	KindInvitation       = "company_invitation"
+	KindTokenReuse       = "refresh_token_reuse"
+	KindAccountLocked    = "account_locked"
+	KindEmailChangeCode  = "email_change_code"
+	KindSuspended        = "account_suspended"
+	KindReinstated       = "account_reinstated"
+	KindPartnerRequest   = "partner_request_status"
+)
+
+// Статусы сообщений
+const (
+	StatusPending    = "pending"    // ожидает отправки
+	StatusProcessing = "processing" // взято обработчиком
+	StatusSent       = "sent"       // доставлено
+	StatusDead       = "dead"       // исчерпаны попытки доставки
+)
+
+// Message соответствует таблице outbox_messages
+type Message struct {
+	ID            uuid.UUID       `json:"id" example:"0b5a3a3e-6a1b-4b7e-9a55-2f0d3f8b1c11"`
+	Channel       string          `json:"channel" example:"email"`
+	Kind          string          `json:"kind" example:"verification_code"`
+	Recipient     string          `json:"recipient" example:"email@mail.ru"`
+	Locale        string          `json:"locale,omitempty" example:"en"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Status        string          `json:"status" example:"dead"`
+	Attempts      int             `json:"attempts" example:"8"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" example:"2026-03-30T06:06:47.181805Z"`
+	LastError     *string         `json:"last_error,omitempty" example:"ошибка отправки email: dial tcp: i/o timeout"`
+	CreatedAt     time.Time       `json:"created_at" example:"2026-03-30T06:06:47.181805Z"`
+	SentAt        *time.Time      `json:"sent_at,omitempty" example:"2026-03-30T06:07:27.657019Z"`
+}
+
+// CodePayload - содержимое сообщений с кодом подтверждения
+type CodePayload struct {
+	Code string `json:"code"`
+}
+
+// Поля payload с секретами (коды, токены, ссылка приглашения с токеном).
+// После доставки удаляются из БД, в ответах администраторам не показываются
+var secretPayloadFields = []string{"code", "token", "link"}
+
+// Возвращает payload без секретных полей
+func redactPayload(payload json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	for _, key := range secretPayloadFields {
+		delete(fields, key)
+	}
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return redacted
+}
@@ -0,0 +1,341 @@
+package outbox
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	configPkg "src/internal/config"
+	"src/internal/mail"
+)
+
+var (
+	ErrUnknownChannel = errors.New("no deliverer registered for channel")
+	ErrUnknownKind    = errors.New("unknown message kind")
+	ErrInvalidStatus  = errors.New("status must be pending, processing, sent or dead")
+
+	// ErrPermanent оборачивается Deliverer'ом, если повторная доставка не имеет смысла
+	// (например, получатель удалён). Такое сообщение сразу уходит в dead
+	ErrPermanent = errors.New("permanent delivery failure")
+)
+
+// Интервал удаления старых сообщений
+const cleanupInterval = time.Hour
+
+// Deliverer доставляет сообщения одного канала (email, в будущем sms, push и т.д.)
+type Deliverer interface {
+	Deliver(msg *Message) error
+}
+
+// OutboxManager содержит бизнес-логику доставки исходящих сообщений
+type OutboxManager struct {
+	storage    OutboxStorage
+	deliverers map[string]Deliverer
+	config     configPkg.OutboxConfig
+}
+
+// NewOutboxManager создаёт новый экземпляр OutboxManager
+func NewOutboxManager(storage OutboxStorage, config configPkg.OutboxConfig) *OutboxManager {
+	return &OutboxManager{
+		storage:    storage,
+		deliverers: make(map[string]Deliverer),
+		config:     config,
+	}
+}
+
+// RegisterDeliverer регистрирует способ доставки для канала
+func (m *OutboxManager) RegisterDeliverer(channel string, deliverer Deliverer) {
+	m.deliverers[channel] = deliverer
+}
+
+// Start запускает фоновую обработку очереди
+func (m *OutboxManager) Start() {
+	go m.processLoop()
+}
+
+// Обработка очереди с интервалом PollInterval и периодическая очистка старых сообщений
+func (m *OutboxManager) processLoop() {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.ProcessBatch(); err != nil {
+				log.Printf("outbox: process batch: %v", err)
+			}
+		case <-cleanup.C:
+			if err := m.Cleanup(); err != nil {
+				log.Printf("outbox: cleanup: %v", err)
+			}
+		}
+	}
+}
+
+// Cleanup удаляет доставленные и недоставленные сообщения старше сроков хранения
+func (m *OutboxManager) Cleanup() error {
+	now := time.Now()
+	deleted, err := m.storage.DeleteExpired(now.Add(-m.config.SentRetention), now.Add(-m.config.DeadRetention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("outbox: deleted %d expired messages", deleted)
+	}
+	return nil
+}
+
+// ProcessBatch забирает пачку готовых к отправке сообщений и доставляет их
+func (m *OutboxManager) ProcessBatch() error {
+	messages, err := m.storage.ClaimDue(m.config.BatchSize, m.config.LockTimeout)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		m.deliver(msg)
+	}
+	return nil
+}
+
+// Доставка одного сообщения и фиксация результата
+func (m *OutboxManager) deliver(msg *Message) {
+	deliverer, ok := m.deliverers[msg.Channel]
+	var err error
+	if !ok {
+		err = fmt.Errorf("%w: %w: %s", ErrPermanent, ErrUnknownChannel, msg.Channel)
+	} else {
+		err = deliverer.Deliver(msg)
+	}
+
+	if err == nil {
+		if err := m.storage.MarkSent(msg.ID); err != nil {
+			log.Printf("outbox: message %s delivered but not marked: %v", msg.ID, err)
+		}
+		return
+	}
+
+	attempts := msg.Attempts + 1
+	if attempts >= m.config.MaxAttempts || errors.Is(err, ErrPermanent) {
+		log.Printf("outbox: message %s moved to dead letter after %d attempts: %v", msg.ID, attempts, err)
+		if err := m.storage.MarkDead(msg.ID, err.Error()); err != nil {
+			log.Printf("outbox: mark message %s dead: %v", msg.ID, err)
+		}
+		return
+	}
+
+	next := time.Now().Add(m.backoff(attempts))
+	log.Printf("outbox: message %s attempt %d failed, retry at %s: %v", msg.ID, attempts, next.Format(time.RFC3339), err)
+	if err := m.storage.MarkFailed(msg.ID, err.Error(), next); err != nil {
+		log.Printf("outbox: mark message %s failed: %v", msg.ID, err)
+	}
+}
+
+// Экспоненциальная задержка: BaseBackoff * 2^(attempts-1), не больше MaxBackoff
+func (m *OutboxManager) backoff(attempts int) time.Duration {
+	delay := m.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= m.config.MaxBackoff {
+			return m.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// GetMessages возвращает не более limit сообщений с указанным статусом (по умолчанию dead).
+// Секреты из payload не возвращаются
+func (m *OutboxManager) GetMessages(status string, limit int) ([]*Message, error) {
+	if status == "" {
+		status = StatusDead
+	}
+	switch status {
+	case StatusPending, StatusProcessing, StatusSent, StatusDead:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	messages, err := m.storage.GetByStatus(status, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range messages {
+		msg.Payload = redactPayload(msg.Payload)
+	}
+	return messages, nil
+}
+
+// GetMessage возвращает сообщение по ID без секретов в payload
+func (m *OutboxManager) GetMessage(id uuid.UUID) (*Message, error) {
+	msg, err := m.storage.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	msg.Payload = redactPayload(msg.Payload)
+	return msg, nil
+}
+
+// Replay возвращает недоставленное сообщение в очередь
+func (m *OutboxManager) Replay(id uuid.UUID) error {
+	return m.storage.Replay(id)
+}
+
+// EmailQueue реализует config.EmailSender, записывая письма в outbox вместо отправки.
+// Письма отправляет OutboxManager через зарегистрированный EmailDeliverer
+type EmailQueue struct {
+	storage OutboxStorage
+	exec    Execer
+	lang    string
+}
+
+// NewEmailQueue создаёт новый экземпляр EmailQueue
+func NewEmailQueue(storage OutboxStorage) *EmailQueue {
+	return &EmailQueue{storage: storage}
+}
+
+// WithTx возвращает EmailQueue, пишущий в outbox внутри транзакции tx
+func (q *EmailQueue) WithTx(tx *sql.Tx) configPkg.EmailSender {
+	return &EmailQueue{storage: q.storage, exec: tx, lang: q.lang}
+}
+
+// WithLang возвращает EmailQueue, ставящий письма на языке lang.
+// Без языка письмо формируется на языке, сохранённом у получателя
+func (q *EmailQueue) WithLang(lang string) configPkg.EmailSender {
+	return &EmailQueue{storage: q.storage, exec: q.exec, lang: lang}
+}
+
+// SendVerificationCode ставит в очередь письмо с кодом подтверждения регистрации
+func (q *EmailQueue) SendVerificationCode(toEmail, code string) error {
+	return q.enqueueCode(KindVerificationCode, toEmail, code)
+}
+
+// SendVerificationResetCode ставит в очередь письмо с кодом восстановления пароля
+func (q *EmailQueue) SendVerificationResetCode(toEmail, code string) error {
+	return q.enqueueCode(KindResetCode, toEmail, code)
+}
+
+// SendCompanyInvitation ставит в очередь письмо с приглашением в компанию
+func (q *EmailQueue) SendCompanyInvitation(toEmail string, data mail.InvitationData) error {
+	return q.enqueue(KindInvitation, toEmail, data)
+}
+
+// SendTokenReuseAlert ставит в очередь предупреждение о повторном использовании refresh токена
+func (q *EmailQueue) SendTokenReuseAlert(toEmail string, data mail.TokenReuseData) error {
+	return q.enqueue(KindTokenReuse, toEmail, data)
+}
+
+// SendAccountLocked ставит в очередь уведомление о временной блокировке входа
+func (q *EmailQueue) SendAccountLocked(toEmail string, data mail.AccountLockedData) error {
+	return q.enqueue(KindAccountLocked, toEmail, data)
+}
+
+// SendEmailChangeCode ставит в очередь письмо с кодом подтверждения нового адреса почты
+func (q *EmailQueue) SendEmailChangeCode(toEmail, code string) error {
+	return q.enqueueCode(KindEmailChangeCode, toEmail, code)
+}
+
+// SendAccountSuspended ставит в очередь уведомление о приостановке аккаунта или компании
+func (q *EmailQueue) SendAccountSuspended(toEmail string, data mail.SuspensionData) error {
+	return q.enqueue(KindSuspended, toEmail, data)
+}
+
+// SendAccountReinstated ставит в очередь уведомление о возобновлении аккаунта или компании
+func (q *EmailQueue) SendAccountReinstated(toEmail string, data mail.SuspensionData) error {
+	return q.enqueue(KindReinstated, toEmail, data)
+}
+
+// SendPartnerRequestStatus ставит в очередь уведомление о смене статуса заявки на подключение организации
+func (q *EmailQueue) SendPartnerRequestStatus(toEmail string, data mail.PartnerRequestData) error {
+	return q.enqueue(KindPartnerRequest, toEmail, data)
+}
+
+// Запись письма с кодом в outbox
+func (q *EmailQueue) enqueueCode(kind, toEmail, code string) error {
+	return q.enqueue(kind, toEmail, CodePayload{Code: code})
+}
+
+// Запись письма в outbox
+func (q *EmailQueue) enqueue(kind, toEmail string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal outbox payload: %w", err)
+	}
+
+	return q.storage.Enqueue(q.exec, &Message{
+		Channel:   ChannelEmail,
+		Kind:      kind,
+		Recipient: toEmail,
+		Locale:    q.lang,
+		Payload:   payload,
+	})
+}
+
+// EmailDeliverer доставляет сообщения канала email через EmailSender
+type EmailDeliverer struct {
+	sender configPkg.EmailSender
+}
+
+// NewEmailDeliverer создаёт новый экземпляр EmailDeliverer
+func NewEmailDeliverer(sender configPkg.EmailSender) *EmailDeliverer {
+	return &EmailDeliverer{sender: sender}
+}
+
+// Deliver отправляет письмо в зависимости от вида сообщения на языке получателя
+func (d *EmailDeliverer) Deliver(msg *Message) error {
+	sender := d.sender.WithLang(msg.Locale)
+	switch msg.Kind {
+	case KindVerificationCode, KindResetCode, KindEmailChangeCode:
+		var payload CodePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: unmarshal outbox payload: %w", ErrPermanent, err)
+		}
+		switch msg.Kind {
+		case KindVerificationCode:
+			return sender.SendVerificationCode(msg.Recipient, payload.Code)
+		case KindEmailChangeCode:
+			return sender.SendEmailChangeCode(msg.Recipient, payload.Code)
+		}
+		return sender.SendVerificationResetCode(msg.Recipient, payload.Code)
+	case KindInvitation:
+		var payload mail.InvitationData
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: unmarshal outbox payload: %w", ErrPermanent, err)
+		}
+		return sender.SendCompanyInvitation(msg.Recipient, payload)
+	case KindTokenReuse:
+		var payload mail.TokenReuseData
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: unmarshal outbox payload: %w", ErrPermanent, err)
+		}
+		return sender.SendTokenReuseAlert(msg.Recipient, payload)
+	case KindAccountLocked:
+		var payload mail.AccountLockedData
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: unmarshal outbox payload: %w", ErrPermanent, err)
+		}
+		return sender.SendAccountLocked(msg.Recipient, payload)
+	case KindSuspended, KindReinstated:
+		var payload mail.SuspensionData
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: unmarshal outbox payload: %w", ErrPermanent, err)
+		}
+		if msg.Kind == KindSuspended {
+			return sender.SendAccountSuspended(msg.Recipient, payload)
+		}
+		return sender.SendAccountReinstated(msg.Recipient, payload)
+	case KindPartnerRequest:
+		var payload mail.PartnerRequestData
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: unmarshal outbox payload: %w", ErrPermanent, err)
+		}
+		return sender.SendPartnerRequestStatus(msg.Recipient, payload)
+	default:
+		return fmt.Errorf("%w: %w: %s", ErrPermanent, ErrUnknownKind, msg.Kind)
+	}
+}
@@ -0,0 +1,218 @@
+package outbox
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	configPkg "src/internal/config"
+)
+
+// Хранилище в памяти: фиксирует, чем закончилась обработка каждого сообщения
+type fakeStorage struct {
+	OutboxStorage
+
+	due    []*Message
+	sent   []uuid.UUID
+	dead   map[uuid.UUID]string
+	failed map[uuid.UUID]time.Time
+
+	byStatus []*Message
+}
+
+func newFakeStorage(due ...*Message) *fakeStorage {
+	return &fakeStorage{due: due, dead: map[uuid.UUID]string{}, failed: map[uuid.UUID]time.Time{}}
+}
+
+func (s *fakeStorage) ClaimDue(limit int, lockTimeout time.Duration) ([]*Message, error) {
+	if len(s.due) > limit {
+		return s.due[:limit], nil
+	}
+	return s.due, nil
+}
+
+func (s *fakeStorage) MarkSent(id uuid.UUID) error {
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+func (s *fakeStorage) MarkFailed(id uuid.UUID, lastError string, nextAttemptAt time.Time) error {
+	s.failed[id] = nextAttemptAt
+	return nil
+}
+
+func (s *fakeStorage) MarkDead(id uuid.UUID, lastError string) error {
+	s.dead[id] = lastError
+	return nil
+}
+
+func (s *fakeStorage) GetByStatus(status string, limit int) ([]*Message, error) {
+	return s.byStatus, nil
+}
+
+type delivererFunc func(msg *Message) error
+
+func (f delivererFunc) Deliver(msg *Message) error { return f(msg) }
+
+func testConfig() configPkg.OutboxConfig {
+	return configPkg.OutboxConfig{
+		BatchSize:   10,
+		MaxAttempts: 3,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  5 * time.Minute,
+		LockTimeout: time.Minute,
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	m := NewOutboxManager(nil, testConfig())
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{20, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := m.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	errTemporary := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		channel   string
+		attempts  int
+		err       error
+		wantSent  bool
+		wantDead  string
+		wantRetry time.Duration
+	}{
+		{name: "delivered", channel: ChannelEmail, wantSent: true},
+		{name: "first failure is retried", channel: ChannelEmail, err: errTemporary, wantRetry: 30 * time.Second},
+		{name: "retry delay grows", channel: ChannelEmail, attempts: 1, err: errTemporary, wantRetry: time.Minute},
+		{name: "attempts exhausted", channel: ChannelEmail, attempts: 2, err: errTemporary, wantDead: "connection refused"},
+		{name: "permanent failure", channel: ChannelEmail, err: ErrPermanent, wantDead: ErrPermanent.Error()},
+		{name: "unknown channel", channel: "pigeon", wantDead: ErrUnknownChannel.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &Message{ID: uuid.New(), Channel: tt.channel, Attempts: tt.attempts}
+			storage := newFakeStorage(msg)
+			m := NewOutboxManager(storage, testConfig())
+			m.RegisterDeliverer(ChannelEmail, delivererFunc(func(*Message) error { return tt.err }))
+
+			start := time.Now()
+			if err := m.ProcessBatch(); err != nil {
+				t.Fatalf("ProcessBatch: %v", err)
+			}
+
+			if sent := len(storage.sent) == 1; sent != tt.wantSent {
+				t.Errorf("sent = %v, want %v", sent, tt.wantSent)
+			}
+
+			lastError, dead := storage.dead[msg.ID]
+			if dead != (tt.wantDead != "") {
+				t.Errorf("dead = %v, want %v", dead, tt.wantDead != "")
+			}
+			if dead && !strings.Contains(lastError, tt.wantDead) {
+				t.Errorf("dead error = %q, want it to contain %q", lastError, tt.wantDead)
+			}
+
+			next, retried := storage.failed[msg.ID]
+			if retried != (tt.wantRetry != 0) {
+				t.Fatalf("retried = %v, want %v", retried, tt.wantRetry != 0)
+			}
+			if retried {
+				delay := next.Sub(start)
+				if delay < tt.wantRetry || delay > tt.wantRetry+time.Second {
+					t.Errorf("next attempt in %s, want %s", delay, tt.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessBatchRespectsBatchSize(t *testing.T) {
+	storage := newFakeStorage(&Message{ID: uuid.New(), Channel: ChannelEmail}, &Message{ID: uuid.New(), Channel: ChannelEmail})
+	config := testConfig()
+	config.BatchSize = 1
+	m := NewOutboxManager(storage, config)
+	m.RegisterDeliverer(ChannelEmail, delivererFunc(func(*Message) error { return nil }))
+
+	if err := m.ProcessBatch(); err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+	if len(storage.sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(storage.sent))
+	}
+}
+
+func TestGetMessagesHidesSecrets(t *testing.T) {
+	storage := newFakeStorage()
+	storage.byStatus = []*Message{
+		{ID: uuid.New(), Kind: KindVerificationCode, Payload: []byte(`{"code":"123456"}`), Status: StatusDead},
+		{ID: uuid.New(), Kind: KindInvitation, Payload: []byte(`{"company_name":"ООО Ромашка","token":"secret-token","link":"https://app/invite?token=secret-token"}`), Status: StatusDead},
+	}
+	m := NewOutboxManager(storage, testConfig())
+
+	messages, err := m.GetMessages(StatusDead, 10)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("GetMessages returned %d messages, want 2", len(messages))
+	}
+	if strings.Contains(string(messages[0].Payload), "123456") {
+		t.Errorf("GetMessages = %s, want payload without code", messages[0].Payload)
+	}
+	if payload := string(messages[1].Payload); strings.Contains(payload, "secret-token") || !strings.Contains(payload, "company_name") {
+		t.Errorf("GetMessages = %s, want payload without token and link", payload)
+	}
+}
+
+// EmailSender, запоминающий язык и получателя отправленного письма
+type langRecorder struct {
+	configPkg.EmailSender
+
+	lang *string
+	to   *string
+}
+
+func (r langRecorder) WithLang(lang string) configPkg.EmailSender {
+	*r.lang = lang
+	return r
+}
+
+func (r langRecorder) SendVerificationCode(toEmail, code string) error {
+	*r.to = toEmail
+	return nil
+}
+
+func TestEmailDelivererUsesMessageLocale(t *testing.T) {
+	for _, locale := range []string{"", "en", "ru"} {
+		var lang, to string
+		deliverer := NewEmailDeliverer(langRecorder{lang: &lang, to: &to})
+
+		msg := &Message{Channel: ChannelEmail, Kind: KindVerificationCode, Recipient: "user@mail.ru", Locale: locale, Payload: []byte(`{"code":"A1B2C3"}`)}
+		if err := deliverer.Deliver(msg); err != nil {
+			t.Fatalf("Deliver: %v", err)
+		}
+		if lang != locale || to != "user@mail.ru" {
+			t.Errorf("locale %q: sent to %q in %q", locale, to, lang)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package outbox
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"src/internal/db"
+)
+
+// ошибки которые возвращает outbox/storage
+var (
+	ErrMessageNotFound = errors.New("outbox message not found")
+)
+
+// Execer - общий интерфейс *sql.DB и *sql.Tx.
+// Позволяет записывать сообщение в той же транзакции, что и бизнес-изменение
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// OutboxStorage определяет методы для работы с таблицей outbox_messages
+type OutboxStorage interface {
+	Enqueue(exec Execer, msg *Message) error
+
+	ClaimDue(limit int, lockTimeout time.Duration) ([]*Message, error)
+
+	MarkSent(id uuid.UUID) error
+
+	MarkFailed(id uuid.UUID, lastError string, nextAttemptAt time.Time) error
+
+	MarkDead(id uuid.UUID, lastError string) error
+
+	GetByID(id uuid.UUID) (*Message, error)
+
+	GetByStatus(status string, limit int) ([]*Message, error)
+
+	Replay(id uuid.UUID) error
+
+	DeleteExpired(sentBefore, deadBefore time.Time) (int64, error)
+}
+
+// PostgresOutboxStorage реализует OutboxStorage для PostgreSQL
+type PostgresOutboxStorage struct {
+	*db.Storage
+}
+
+// NewPostgresOutboxStorage создаёт новый экземпляр PostgresOutboxStorage
+func NewPostgresOutboxStorage(sqlDB *sql.DB) *PostgresOutboxStorage {
+	return &PostgresOutboxStorage{Storage: db.NewStorage(sqlDB)}
+}
+
+// Enqueue записывает сообщение в outbox.
+// Если exec == nil, запись выполняется вне транзакции.
+// Письму без явного языка назначается язык, сохранённый у получателя
+func (s *PostgresOutboxStorage) Enqueue(exec Execer, msg *Message) error {
+	if exec == nil {
+		exec = s.DB
+	}
+
+	_, err := exec.Exec(`
+		INSERT INTO outbox_messages (channel, kind, recipient, locale, payload, status, attempts, next_attempt_at)
+		VALUES ($1, $2, $3, COALESCE(NULLIF($4, ''), (SELECT locale FROM all_users WHERE login = $3 AND $1 = 'email'), ''), $5, $6, 0, NOW())
+	`, msg.Channel, msg.Kind, msg.Recipient, msg.Locale, []byte(msg.Payload), StatusPending)
+	if err != nil {
+		return fmt.Errorf("insert outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDue забирает в обработку сообщения, время отправки которых наступило.
+// Сообщения, зависшие в processing дольше lockTimeout, забираются повторно.
+// FOR UPDATE SKIP LOCKED позволяет нескольким экземплярам работать параллельно
+func (s *PostgresOutboxStorage) ClaimDue(limit int, lockTimeout time.Duration) ([]*Message, error) {
+	rows, err := s.DB.Query(`
+		UPDATE outbox_messages
+		SET status = $1, locked_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox_messages
+			WHERE (status = $3 AND next_attempt_at <= NOW())
+			   OR (status = $1 AND locked_until < NOW())
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, channel, kind, recipient, locale, payload, status, attempts, next_attempt_at, last_error, created_at, sent_at
+	`, StatusProcessing, lockTimeout.Seconds(), StatusPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// MarkSent отмечает сообщение доставленным и удаляет секреты из payload
+func (s *PostgresOutboxStorage) MarkSent(id uuid.UUID) error {
+	_, err := s.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = $1, attempts = attempts + 1, sent_at = NOW(), locked_until = NULL, last_error = NULL,
+			payload = payload - $3::text[]
+		WHERE id = $2
+	`, StatusSent, id, secretPayloadFields)
+	if err != nil {
+		return fmt.Errorf("mark outbox message sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed возвращает сообщение в очередь с новым временем попытки
+func (s *PostgresOutboxStorage) MarkFailed(id uuid.UUID, lastError string, nextAttemptAt time.Time) error {
+	_, err := s.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_error = $3, locked_until = NULL
+		WHERE id = $4
+	`, StatusPending, nextAttemptAt, lastError, id)
+	if err != nil {
+		return fmt.Errorf("mark outbox message failed: %w", err)
+	}
+	return nil
+}
+
+// MarkDead переводит сообщение в dead-letter после исчерпания попыток
+func (s *PostgresOutboxStorage) MarkDead(id uuid.UUID, lastError string) error {
+	_, err := s.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = $1, attempts = attempts + 1, last_error = $2, locked_until = NULL
+		WHERE id = $3
+	`, StatusDead, lastError, id)
+	if err != nil {
+		return fmt.Errorf("mark outbox message dead: %w", err)
+	}
+	return nil
+}
+
+// GetByID возвращает сообщение по ID.
+// Если сообщение не найдено, возвращает ErrMessageNotFound
+func (s *PostgresOutboxStorage) GetByID(id uuid.UUID) (*Message, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, channel, kind, recipient, locale, payload, status, attempts, next_attempt_at, last_error, created_at, sent_at
+		FROM outbox_messages
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query outbox message: %w", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrMessageNotFound
+	}
+	return messages[0], nil
+}
+
+// GetByStatus возвращает не более limit сообщений с указанным статусом, новые первыми
+func (s *PostgresOutboxStorage) GetByStatus(status string, limit int) ([]*Message, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, channel, kind, recipient, locale, payload, status, attempts, next_attempt_at, last_error, created_at, sent_at
+		FROM outbox_messages
+		WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query outbox messages by status: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// Replay возвращает недоставленное сообщение в очередь со сбросом счётчика попыток.
+// Если сообщение не найдено или не в статусе dead, возвращает ErrMessageNotFound
+func (s *PostgresOutboxStorage) Replay(id uuid.UUID) error {
+	result, err := s.DB.Exec(`
+		UPDATE outbox_messages
+		SET status = $1, attempts = 0, next_attempt_at = NOW(), locked_until = NULL
+		WHERE id = $2 AND status = $3
+	`, StatusPending, id, StatusDead)
+	if err != nil {
+		return fmt.Errorf("replay outbox message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// DeleteExpired удаляет доставленные сообщения, отправленные раньше sentBefore,
+// и недоставленные, созданные раньше deadBefore. Возвращает число удалённых строк
+func (s *PostgresOutboxStorage) DeleteExpired(sentBefore, deadBefore time.Time) (int64, error) {
+	result, err := s.DB.Exec(`
+		DELETE FROM outbox_messages
+		WHERE (status = $1 AND sent_at < $2) OR (status = $3 AND created_at < $4)
+	`, StatusSent, sentBefore, StatusDead, deadBefore)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired outbox messages: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("get rows affected: %w", err)
+	}
+	return rowsAffected, nil
+}
+
+// Сканирование строк outbox_messages
+func scanMessages(rows *sql.Rows) ([]*Message, error) {
+	var messages []*Message
+	for rows.Next() {
+		var msg Message
+		var payload []byte
+		err := rows.Scan(
+			&msg.ID,
+			&msg.Channel,
+			&msg.Kind,
+			&msg.Recipient,
+			&msg.Locale,
+			&payload,
+			&msg.Status,
+			&msg.Attempts,
+			&msg.NextAttemptAt,
+			&msg.LastError,
+			&msg.CreatedAt,
+			&msg.SentAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan outbox message: %w", err)
+		}
+		msg.Payload = payload
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,207 @@
+package outbox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+
+	"src/internal/mail"
+)
+
+var messageColumns = []string{"id", "channel", "kind", "recipient", "locale", "payload", "status", "attempts", "next_attempt_at", "last_error", "created_at", "sent_at"}
+
+func newMockStorage(t *testing.T) (*PostgresOutboxStorage, *sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.ValueConverterOption(arrayConverter{}))
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return NewPostgresOutboxStorage(sqlDB), sqlDB, mock
+}
+
+func TestClaimDue(t *testing.T) {
+	storage, _, mock := newMockStorage(t)
+
+	id := uuid.New()
+	now := time.Now()
+	// Забираются pending с наступившим временем и processing с истёкшей блокировкой,
+	// строки, заблокированные другим экземпляром, пропускаются
+	mock.ExpectQuery(`(?s)UPDATE outbox_messages\s+SET status = \$1, locked_until = NOW\(\) \+ make_interval\(secs => \$2\).*`+
+		regexp.QuoteMeta(`(status = $3 AND next_attempt_at <= NOW())`)+`.*`+
+		regexp.QuoteMeta(`(status = $1 AND locked_until < NOW())`)+`.*LIMIT \$4\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs(StatusProcessing, float64(60), StatusPending, 5).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(id.String(), ChannelEmail, KindVerificationCode, "user@mail.ru", "en", []byte(`{"code":"123456"}`), StatusProcessing, 1, now, nil, now, nil))
+
+	messages, err := storage.ClaimDue(5, time.Minute)
+	if err != nil {
+		t.Fatalf("ClaimDue: %v", err)
+	}
+	if len(messages) != 1 || messages[0].ID != id || messages[0].Attempts != 1 || messages[0].Locale != "en" {
+		t.Fatalf("ClaimDue = %+v, want message %s with 1 attempt", messages, id)
+	}
+
+	var payload CodePayload
+	if err := json.Unmarshal(messages[0].Payload, &payload); err != nil || payload.Code != "123456" {
+		t.Errorf("payload = %s, want code 123456", messages[0].Payload)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarkResult(t *testing.T) {
+	id := uuid.New()
+	next := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		call  func(s *PostgresOutboxStorage) error
+	}{
+		{
+			name:  "sent",
+			query: `payload = payload - $3::text[]`,
+			args:  []any{StatusSent, id, []string{"code", "token", "link"}},
+			call:  func(s *PostgresOutboxStorage) error { return s.MarkSent(id) },
+		},
+		{
+			name:  "failed is returned to the queue",
+			query: `SET status = $1, attempts = attempts + 1, next_attempt_at = $2`,
+			args:  []any{StatusPending, next, "timeout", id},
+			call:  func(s *PostgresOutboxStorage) error { return s.MarkFailed(id, "timeout", next) },
+		},
+		{
+			name:  "dead",
+			query: `SET status = $1, attempts = attempts + 1, last_error = $2`,
+			args:  []any{StatusDead, "timeout", id},
+			call:  func(s *PostgresOutboxStorage) error { return s.MarkDead(id, "timeout") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, _, mock := newMockStorage(t)
+			args := make([]driver.Value, len(tt.args))
+			for i, a := range tt.args {
+				args[i] = equalArg{a}
+			}
+			mock.ExpectExec(regexp.QuoteMeta(tt.query)).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 1))
+
+			if err := tt.call(storage); err != nil {
+				t.Fatal(err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGetByStatusLimit(t *testing.T) {
+	storage, _, mock := newMockStorage(t)
+
+	mock.ExpectQuery(`(?s)WHERE status = \$1\s+ORDER BY created_at DESC\s+LIMIT \$2`).
+		WithArgs(StatusDead, 100).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+
+	if _, err := storage.GetByStatus(StatusDead, 100); err != nil {
+		t.Fatalf("GetByStatus: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	storage, _, mock := newMockStorage(t)
+
+	sentBefore := time.Now().Add(-24 * time.Hour)
+	deadBefore := time.Now().Add(-30 * 24 * time.Hour)
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM outbox_messages
+		WHERE (status = $1 AND sent_at < $2) OR (status = $3 AND created_at < $4)`)).
+		WithArgs(StatusSent, equalArg{sentBefore}, StatusDead, equalArg{deadBefore}).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	deleted, err := storage.DeleteExpired(sentBefore, deadBefore)
+	if err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("deleted = %d, want 3", deleted)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmailQueueWithTx(t *testing.T) {
+	storage, sqlDB, mock := newMockStorage(t)
+
+	// Письмо пишется в транзакции вызывающего кода и откатывается вместе с ней
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO outbox_messages`)).
+		WithArgs(ChannelEmail, KindInvitation, "user@mail.ru", "en", sqlmock.AnyArg(), StatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := NewEmailQueue(storage)
+	if err := queue.WithTx(tx).WithLang("en").SendCompanyInvitation("user@mail.ru", mail.InvitationData{CompanyName: "ООО Пионер"}); err != nil {
+		t.Fatalf("SendCompanyInvitation: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// Пропускает []string как есть, как это делает драйвер pgx
+type arrayConverter struct{}
+
+func (arrayConverter) ConvertValue(v any) (driver.Value, error) {
+	if arr, ok := v.([]string); ok {
+		return arr, nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+// Сравнение аргумента запроса с time.Time и прочими значениями
+type equalArg struct{ want any }
+
+func (a equalArg) Match(v driver.Value) bool {
+	if want, ok := a.want.([]string); ok {
+		got, ok := v.([]string)
+		return ok && slices.Equal(got, want)
+	}
+	if want, ok := a.want.(time.Time); ok {
+		got, ok := v.(time.Time)
+		return ok && got.Equal(want)
+	}
+	if want, ok := a.want.(uuid.UUID); ok {
+		switch got := v.(type) {
+		case string:
+			return got == want.String()
+		case []byte:
+			return string(got) == want.String()
+		case uuid.UUID:
+			return got == want
+		}
+		return false
+	}
+	return v == a.want
+}
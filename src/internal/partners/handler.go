@@ -2,6 +2,7 @@ package partners
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"src/internal/middleware"
@@ -19,7 +20,8 @@ func NewHandler(service *PartnersManager) *Handler {
 	return &Handler{partners: service}
 }
 
-// CreatePartnerRequest обрабатывает POST /partner/request, создает заявку для организации
+// CreatePartnerRequest обрабатывает POST /partner/request, создает заявку для организации.
+// Если администратор запросил по заявке сведения, повторно отправляет исправленную заявку
 func (h *Handler) CreatePartnerRequest(w http.ResponseWriter, r *http.Request) {
 	// Получение claims
 	claims, ok := r.Context().Value("user").(jwt.MapClaims)
@@ -46,12 +48,21 @@ func (h *Handler) CreatePartnerRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.partners.CreatePartnerRequest(userEmail, &req)
+	resubmitted, err := h.partners.CreatePartnerRequest(userEmail, &req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if resubmitted {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Partner request resubmitted",
+			"status":  "pending",
+		})
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Partner request created successfully",
@@ -83,3 +94,77 @@ func (h *Handler) GetRequestStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(req)
 }
+
+// GetComments обрабатывает GET /partner/request/comments, возвращает переписку по заявке пользователя
+func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
+	userEmail, ok := emailFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := h.partners.GetComments(userEmail)
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comments)
+}
+
+// AddComment обрабатывает POST /partner/request/comments, добавляет сообщение в переписку по заявке
+func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
+	userEmail, ok := emailFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	comment, err := h.partners.AddComment(userEmail, req.Text)
+	if err != nil {
+		writeCommentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// Получение email пользователя из claims
+func emailFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := r.Context().Value("user").(jwt.MapClaims)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+
+	userEmail, ok := claims["email"].(string)
+	if !ok || userEmail == "" {
+		http.Error(w, "Invalid token: email not found", http.StatusUnauthorized)
+		return "", false
+	}
+	return userEmail, true
+}
+
+// Ошибки переписки по заявке в HTTP ответ
+func writeCommentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrRequestClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -1,11 +1,18 @@
 package partners
 
-import "time"
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // PartnerRequest - заявка на регистрацию организации
 type PartnerRequest struct {
-	Status    string `json:"status" db:"status"`
-	UserEmail string `json:"user_email" db:"user_email"`
+	ID           uuid.UUID `json:"id" db:"id" example:"b1c2d3e4-f5a6-4b7c-8d9e-0f1a2b3c4d5e"`
+	Status       string    `json:"status" db:"status" example:"needs_info"` // new | pending | needs_info | approved | rejected
+	StatusReason string    `json:"status_reason,omitempty" db:"status_reason" example:"Не указан КПП обособленного подразделения"`
+	UserEmail    string    `json:"user_email" db:"user_email"`
 
 	INN          string `json:"inn" db:"inn" validate:"required,inn"`
 	KPP          string `json:"kpp" db:"kpp" validate:"required,kpp"`
@@ -52,6 +59,24 @@ type PartnerRequestRequest struct {
 	Info       string `json:"info,omitempty" db:"info" example:"Дополнительная информация" validate:"omitempty"`
 }
 
+// Comment - сообщение в переписке администратора и заявителя по заявке
+type Comment struct {
+	ID         uuid.UUID `json:"id" example:"5b7e2c1a-9d3f-4e8a-b6c0-1f2a3b4c5d6e"`
+	RequestID  uuid.UUID `json:"request_id" example:"b1c2d3e4-f5a6-4b7c-8d9e-0f1a2b3c4d5e"`
+	Author     string    `json:"author" example:"ivan@example.com"`
+	AuthorRole string    `json:"author_role" example:"applicant"` // admin | applicant
+	Text       string    `json:"text" example:"КПП добавлен, заявка отправлена повторно"`
+	CreatedAt  time.Time `json:"created_at" example:"2026-03-30T06:06:47Z"`
+}
+
+// CommentRequest - новое сообщение в переписке по заявке
+type CommentRequest struct {
+	Text string `json:"text" example:"КПП добавлен, заявка отправлена повторно" validate:"required,max=2000"`
+}
+
+// CommentAuthorApplicant - автор сообщения в переписке по заявке - заявитель
+const CommentAuthorApplicant = "applicant"
+
 // IsPartnerUsers используется для проверки есть ли у пользователся организация
 type IsPartnersUsers struct {
 	IsPartner bool
@@ -64,6 +89,12 @@ type PartnersUsers struct {
 	Inn   string
 }
 
+// Ошибки переписки по заявке
+var (
+	ErrRequestNotFound = errors.New("partner request not found")
+	ErrRequestClosed   = errors.New("partner request is already approved or rejected")
+)
+
 // Ошибки
 var (
 	ErrUserNotFound        = "user not found"
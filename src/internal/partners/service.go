@@ -2,13 +2,14 @@ package partners
 
 import (
 	"fmt"
+	"log"
 	"time"
 
 	configPkg "src/internal/config"
+	"src/internal/mail"
 )
 
 type Config struct {
-	JWTSecretKey    string
 	AccessTokenTTL  time.Duration
 	RefreshTokenTTL time.Duration
 	VerificationTTL time.Duration
@@ -40,38 +41,37 @@ func NewPartnersManager(
 	}
 }
 
-// Создание заявки партнера (доступно любому авторизованному пользователю)
-func (s *PartnersManager) CreatePartnerRequest(userEmail string, req *PartnerRequestRequest) error {
+// Создание заявки партнера (доступно любому авторизованному пользователю).
+// Если администратор запросил по заявке сведения (needs_info), заявка исправляется и отправляется
+// повторно, тогда resubmitted = true
+func (s *PartnersManager) CreatePartnerRequest(userEmail string, req *PartnerRequestRequest) (resubmitted bool, err error) {
 	// Проверка на существование пользователя
 	user, err := s.userStorage.GetByEmail(userEmail)
 	if err != nil {
-		return fmt.Errorf("failed to get user: %w", err)
+		return false, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return fmt.Errorf("user not found")
+		return false, fmt.Errorf("user not found")
 	}
 
-	isPartner, err := s.UserIsPartner(userEmail)
+	current, err := s.partnerRequestStorage.GetByUserEmail(userEmail)
 	if err != nil {
-		return fmt.Errorf("failed to check if user is partner: %w", err)
+		return false, fmt.Errorf("failed to get request: %w", err)
 	}
-	if isPartner.IsPartner {
-		return fmt.Errorf("user is already a partner in a company or has an active request")
+	if current != nil && current.Status == "needs_info" {
+		return true, s.resubmitPartnerRequest(current, req)
 	}
 
-	// Проверка на наличие заявки с таким же ИНН
-	existing, _ := s.companyStorage.GetByINN(req.INN)
-	if existing != nil {
-		return fmt.Errorf("company with this INN already exists")
-	}
-
-	// Проверка на существование заявки с таким ИНН
-	hasActive, err := s.partnerRequestStorage.HasActiveRequestByINN(req.INN)
+	isPartner, err := s.UserIsPartner(userEmail)
 	if err != nil {
-		return fmt.Errorf("failed to check existing request: %w", err)
+		return false, fmt.Errorf("failed to check if user is partner: %w", err)
 	}
-	if hasActive {
-		return fmt.Errorf("an active request with this INN already exists")
+	if isPartner.IsPartner {
+		return false, fmt.Errorf("user is already a partner in a company or has an active request")
+	}
+
+	if err := s.checkINNAvailable(req.INN); err != nil {
+		return false, err
 	}
 
 	// Создание заявки
@@ -92,12 +92,110 @@ func (s *PartnersManager) CreatePartnerRequest(userEmail string, req *PartnerReq
 	}
 
 	if err := s.partnerRequestStorage.Create(partnerReq); err != nil {
-		return fmt.Errorf("failed to create partner request: %w", err)
+		return false, fmt.Errorf("failed to create partner request: %w", err)
+	}
+
+	s.notifyStatus(partnerReq)
+	return false, nil
+}
+
+// Повторная отправка заявки с исправленными данными (needs_info -> pending)
+func (s *PartnersManager) resubmitPartnerRequest(current *PartnerRequest, req *PartnerRequestRequest) error {
+	// ИНН изменён - проверки как при создании заявки
+	if req.INN != current.INN {
+		if err := s.checkINNAvailable(req.INN); err != nil {
+			return err
+		}
+	}
+
+	partnerReq := &PartnerRequest{
+		ID:           current.ID,
+		Status:       "pending",
+		UserEmail:    current.UserEmail,
+		INN:          req.INN,
+		KPP:          req.KPP,
+		OGRN:         req.OGRN,
+		OrgName:      req.OrgName,
+		OrgShortName: req.OrgShortName,
+		Name:         req.Name,
+		Surname:      req.Surname,
+		Patronymic:   req.Patronymic,
+		Email:        req.Email,
+		Phone:        req.Phone,
+		Info:         req.Info,
+	}
+
+	resubmitted, err := s.partnerRequestStorage.Resubmit(partnerReq)
+	if err != nil {
+		return fmt.Errorf("failed to resubmit partner request: %w", err)
+	}
+	if !resubmitted {
+		return fmt.Errorf("request is no longer awaiting information")
 	}
 
+	s.notifyStatus(partnerReq)
 	return nil
 }
 
+// Проверка, что компании и активной заявки с таким ИНН нет
+func (s *PartnersManager) checkINNAvailable(inn string) error {
+	// Проверка на наличие компании с таким же ИНН
+	existing, _ := s.companyStorage.GetByINN(inn)
+	if existing != nil {
+		return fmt.Errorf("company with this INN already exists")
+	}
+
+	// Проверка на существование заявки с таким ИНН
+	hasActive, err := s.partnerRequestStorage.HasActiveRequestByINN(inn)
+	if err != nil {
+		return fmt.Errorf("failed to check existing request: %w", err)
+	}
+	if hasActive {
+		return fmt.Errorf("an active request with this INN already exists")
+	}
+	return nil
+}
+
+// Письмо заявителю о статусе заявки. Заявка уже сохранена, поэтому ошибка только логируется
+func (s *PartnersManager) notifyStatus(req *PartnerRequest) {
+	data := mail.PartnerRequestData{OrgShortName: req.OrgShortName, Status: req.Status}
+	if err := s.emailSender.SendPartnerRequestStatus(req.UserEmail, data); err != nil {
+		log.Printf("partners: failed to queue partner request status email for %s: %v", req.UserEmail, err)
+	}
+}
+
+// Получение переписки по последней заявке пользователя
+func (s *PartnersManager) GetComments(email string) ([]Comment, error) {
+	req, err := s.partnerRequestStorage.GetByUserEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+	if req == nil {
+		return nil, ErrRequestNotFound
+	}
+	return s.partnerRequestStorage.GetComments(req.ID)
+}
+
+// Добавление сообщения заявителя в переписку по последней заявке. По закрытой заявке писать нельзя
+func (s *PartnersManager) AddComment(email, text string) (*Comment, error) {
+	req, err := s.partnerRequestStorage.GetByUserEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get request: %w", err)
+	}
+	if req == nil {
+		return nil, ErrRequestNotFound
+	}
+	if req.Status == "approved" || req.Status == "rejected" {
+		return nil, ErrRequestClosed
+	}
+
+	comment := &Comment{RequestID: req.ID, Author: email, AuthorRole: CommentAuthorApplicant, Text: text}
+	if err := s.partnerRequestStorage.AddComment(comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
 // Получение статуса заявки по ИНН
 func (s *PartnersManager) GetRequestStatus(inn string) (*PartnerRequest, error) {
 	// Получение заявки по INN
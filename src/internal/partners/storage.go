@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"src/internal/auth"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // Интерфейс для работы с пользователями
@@ -29,6 +31,11 @@ type PartnerRequestStorage interface {
 	GetPartUserByEmail(email string) (PartnersUsers, error)
 	Delete(inn string) error
 	HasActiveRequestByINN(inn string) (bool, error)
+	// Resubmit сохраняет исправленную заявку и возвращает её на рассмотрение (needs_info -> pending).
+	// Возвращает false, если заявка уже не ожидает сведений
+	Resubmit(req *PartnerRequest) (bool, error)
+	AddComment(comment *Comment) error
+	GetComments(requestID uuid.UUID) ([]Comment, error)
 }
 
 // PartnersUsersStorage интерфейс для работы с таблицей partners_users
@@ -66,12 +73,12 @@ func (s *PostgresPartnerRequestStorage) Create(req *PartnerRequest) error {
 // Получение информации из заявки по ИНН
 func (s *PostgresPartnerRequestStorage) GetByINN(inn string) (*PartnerRequest, error) {
 	var req PartnerRequest
-	query := `SELECT status, user_email, inn, kpp, ogrn, org_name, org_short_name,
+	query := `SELECT id, status, COALESCE(status_reason, ''), user_email, inn, kpp, ogrn, org_name, org_short_name,
                      name, surname, patronymic, email, phone_number, info, created_at, last_used 
               FROM part_req WHERE inn = $1`
 
 	err := s.db.QueryRow(query, inn).Scan(
-		&req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
+		&req.ID, &req.Status, &req.StatusReason, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
 		&req.OrgName, &req.OrgShortName,
 		&req.Name, &req.Surname, &req.Patronymic,
 		&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
@@ -86,6 +93,64 @@ func (s *PostgresPartnerRequestStorage) GetByINN(inn string) (*PartnerRequest, e
 	return &req, nil
 }
 
+// Повторная отправка заявки после запроса сведений
+func (s *PostgresPartnerRequestStorage) Resubmit(req *PartnerRequest) (bool, error) {
+	query := `
+        UPDATE part_req SET
+            inn = $2, kpp = $3, ogrn = $4, org_name = $5, org_short_name = $6,
+            name = $7, surname = $8, patronymic = $9, email = $10, phone_number = $11, info = $12,
+            status = 'pending', status_reason = NULL, last_used = NOW()
+        WHERE id = $1 AND status = 'needs_info'
+    `
+
+	result, err := s.db.Exec(
+		query,
+		req.ID, req.INN, req.KPP, req.OGRN, req.OrgName, req.OrgShortName,
+		req.Name, req.Surname, req.Patronymic, req.Email, req.Phone, req.Info,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// Добавление сообщения в переписку по заявке
+func (s *PostgresPartnerRequestStorage) AddComment(comment *Comment) error {
+	query := `INSERT INTO part_req_comments (request_id, author, author_role, text)
+              VALUES ($1, $2, $3, $4)
+              RETURNING id, created_at`
+
+	err := s.db.QueryRow(query, comment.RequestID, comment.Author, comment.AuthorRole, comment.Text).
+		Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add comment: %w", err)
+	}
+	return nil
+}
+
+// Получение переписки по заявке, начиная со старых сообщений
+func (s *PostgresPartnerRequestStorage) GetComments(requestID uuid.UUID) ([]Comment, error) {
+	rows, err := s.db.Query(`SELECT id, request_id, author, author_role, text, created_at
+                              FROM part_req_comments WHERE request_id = $1
+                              ORDER BY created_at`, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.RequestID, &c.Author, &c.AuthorRole, &c.Text, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
 // Удаление заявки по ИНН (для отката)
 func (s *PostgresPartnerRequestStorage) Delete(inn string) error {
 	query := `DELETE FROM part_req WHERE inn = $1`
@@ -143,12 +208,12 @@ func (s *PostgresCompanyStorage) Exists(inn string) (bool, error) {
 // GetByUserEmail - получение заявки по email пользователя
 func (s *PostgresPartnerRequestStorage) GetByUserEmail(email string) (*PartnerRequest, error) {
 	var req PartnerRequest
-	query := `SELECT status, user_email, inn, kpp, ogrn, org_name, org_short_name,
+	query := `SELECT id, status, COALESCE(status_reason, ''), user_email, inn, kpp, ogrn, org_name, org_short_name,
                      name, surname, patronymic, email, phone_number, info, created_at, last_used  
               FROM part_req WHERE user_email = $1 ORDER BY created_at DESC LIMIT 1`
 
 	err := s.db.QueryRow(query, email).Scan(
-		&req.Status, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
+		&req.ID, &req.Status, &req.StatusReason, &req.UserEmail, &req.INN, &req.KPP, &req.OGRN,
 		&req.OrgName, &req.OrgShortName,
 		&req.Name, &req.Surname, &req.Patronymic,
 		&req.Email, &req.Phone, &req.Info, &req.CreatedAt, &req.LastUsed,
@@ -0,0 +1,197 @@
+package rbac
+
+import (
+	"errors"
+	"slices"
+)
+
+// Роли пользователей. Роль определяется при выдаче токена и записывается в claims.
+// Членство в компании от роли не зависит: администратор тоже может быть сотрудником компании
+const (
+	RoleClient  = "client"  // клиент
+	RolePartner = "partner" // сотрудник компании-партнёра без роли администратора
+	RoleAdmin   = "admin"   // администратор
+)
+
+// Разрешения, проверяемые middleware RequirePermission
+const (
+	PermOrdersOwn       = "orders:own"       // создание и просмотр своих заказов, поиск филиалов
+	PermProfile         = "profile:manage"   // профиль клиента (город)
+	PermPartnerRequest  = "partner:request"  // подача заявки на партнёрство
+	PermCompanyView     = "company:view"     // просмотр компании, филиалов и услуг
+	PermCompanyManage   = "company:manage"   // добавление филиалов и услуг
+	PermCompanyPrices   = "company:prices"   // изменение деталей услуг и цен
+	PermCompanyOrders   = "company:orders"   // просмотр и изменение статусов заказов компании
+	PermCompanyMembers  = "company:members"  // управление сотрудниками компании и их ролями
+	PermCompanyWebhooks = "company:webhooks" // управление вебхуками компании
+	PermCompanyAPIKeys  = "company:api_keys" // управление API ключами компании
+	PermAdminPartners   = "admin:partners"   // рассмотрение заявок на партнёрство
+	PermAdminUsers      = "admin:users"      // управление администраторами
+	PermAdminSystem     = "admin:system"     // outbox, шаблоны писем
+	PermAdminCatalog    = "admin:catalog"    // каталог услуг и категорий
+	PermAdminAudit      = "admin:audit"      // журнал действий администраторов
+)
+
+// Разрешения клиента есть у всех ролей
+var clientPermissions = []string{
+	PermOrdersOwn,
+	PermProfile,
+	PermPartnerRequest,
+}
+
+// Разрешения ролей. Разрешения в компании даёт не роль, а роль в компании (см. Principal.HasPermission)
+var rolePermissions = map[string][]string{
+	RoleClient:  clientPermissions,
+	RolePartner: clientPermissions,
+	RoleAdmin: append(slices.Clone(clientPermissions),
+		PermAdminPartners,
+		PermAdminUsers,
+		PermAdminSystem,
+		PermAdminCatalog,
+		PermAdminAudit,
+	),
+}
+
+// Permissions возвращает разрешения роли. Для неизвестной роли возвращает nil
+func Permissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// HasPermission проверяет, есть ли у роли разрешение
+func HasPermission(role, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// Роли сотрудников внутри компании-партнёра. Роль в компании определяет, какие действия
+// разрешены на маршрутах /company, независимо от глобальной роли
+const (
+	CompanyRoleOwner    = "owner"    // владелец: все действия, включая управление сотрудниками
+	CompanyRoleManager  = "manager"  // менеджер: филиалы, услуги, цены, заказы, вебхуки
+	CompanyRoleOperator = "operator" // оператор: просмотр и статусы заказов
+)
+
+// Разрешения ролей внутри компании
+var companyRolePermissions = map[string][]string{
+	CompanyRoleOwner: {
+		PermCompanyView,
+		PermCompanyManage,
+		PermCompanyPrices,
+		PermCompanyOrders,
+		PermCompanyMembers,
+		PermCompanyWebhooks,
+		PermCompanyAPIKeys,
+	},
+	CompanyRoleManager: {
+		PermCompanyView,
+		PermCompanyManage,
+		PermCompanyPrices,
+		PermCompanyOrders,
+		PermCompanyWebhooks,
+		PermCompanyAPIKeys,
+	},
+	CompanyRoleOperator: {
+		PermCompanyView,
+		PermCompanyOrders,
+	},
+}
+
+// IsCompanyRole проверяет, что роль в компании существует
+func IsCompanyRole(role string) bool {
+	_, ok := companyRolePermissions[role]
+	return ok
+}
+
+// HasCompanyPermission проверяет, есть ли у роли в компании разрешение
+func HasCompanyPermission(companyRole, permission string) bool {
+	return slices.Contains(companyRolePermissions[companyRole], permission)
+}
+
+// IsCompanyPermission проверяет, что разрешение относится к действиям внутри компании
+func IsCompanyPermission(permission string) bool {
+	return slices.Contains(companyRolePermissions[CompanyRoleOwner], permission)
+}
+
+// Права API ключа компании. Ключ работает с ролью partner, но получает
+// только разрешения своих scopes и только на маршрутах, где эти scopes указаны
+const (
+	ScopeOrdersRead    = "orders:read"    // просмотр заказов компании
+	ScopeOrdersWrite   = "orders:write"   // изменение статусов заказов
+	ScopeCatalogManage = "catalog:manage" // филиалы, услуги, детали и цены
+)
+
+// APIKeyScopes - все права, которые можно выдать API ключу
+var APIKeyScopes = []string{
+	ScopeOrdersRead,
+	ScopeOrdersWrite,
+	ScopeCatalogManage,
+}
+
+// Разрешения в компании, которые дают права API ключа
+var scopePermissions = map[string][]string{
+	ScopeOrdersRead: {
+		PermCompanyView,
+		PermCompanyOrders,
+	},
+	ScopeOrdersWrite: {
+		PermCompanyView,
+		PermCompanyOrders,
+	},
+	ScopeCatalogManage: {
+		PermCompanyView,
+		PermCompanyManage,
+		PermCompanyPrices,
+	},
+}
+
+// IsAPIKeyScope проверяет, что право API ключа существует
+func IsAPIKeyScope(scope string) bool {
+	_, ok := scopePermissions[scope]
+	return ok
+}
+
+// HasScopePermission проверяет, даёт ли хотя бы одно из прав API ключа разрешение в компании
+func HasScopePermission(scopes []string, permission string) bool {
+	for _, scope := range scopes {
+		if slices.Contains(scopePermissions[scope], permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrCompanySuspended - компания приостановлена администратором, её API ключи не принимаются
+var ErrCompanySuspended = errors.New("company is suspended")
+
+// Principal - пользователь, от имени которого выполняется запрос (из claims access токена
+// или API ключа компании)
+type Principal struct {
+	Email string // пусто для API ключа
+	Role  string
+	INN   string // ИНН компании, если пользователь - сотрудник компании (при любой роли)
+
+	CompanyRole string // роль в компании, если пользователь - сотрудник компании
+
+	APIKeyID string   // ID ключа, только для запросов с API ключом
+	Scopes   []string // права ключа, только для запросов с API ключом
+}
+
+// IsAPIKey проверяет, что запрос выполняется с API ключом компании
+func (p Principal) IsAPIKey() bool {
+	return p.APIKeyID != ""
+}
+
+// HasPermission проверяет разрешение пользователя. Разрешения в компании даёт членство
+// в компании (ИНН и роль в компании из claims) или права API ключа, независимо от глобальной роли,
+// поэтому администратор-сотрудник компании работает с /company так же, как партнёр
+func (p Principal) HasPermission(permission string) bool {
+	if !IsCompanyPermission(permission) {
+		return HasPermission(p.Role, permission)
+	}
+	if p.INN == "" {
+		return false
+	}
+	if p.IsAPIKey() {
+		return HasScopePermission(p.Scopes, permission)
+	}
+	return HasCompanyPermission(p.CompanyRole, permission)
+}
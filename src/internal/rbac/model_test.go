@@ -0,0 +1,44 @@
+package rbac
+
+import "testing"
+
+func TestPrincipalHasPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  Principal
+		permission string
+		want       bool
+	}{
+		{"client orders", Principal{Role: RoleClient}, PermOrdersOwn, true},
+		{"client company", Principal{Role: RoleClient}, PermCompanyView, false},
+		{"partner without company", Principal{Role: RolePartner}, PermCompanyView, false},
+		{"owner members", Principal{Role: RolePartner, INN: "7700000000", CompanyRole: CompanyRoleOwner}, PermCompanyMembers, true},
+		{"operator orders", Principal{Role: RolePartner, INN: "7700000000", CompanyRole: CompanyRoleOperator}, PermCompanyOrders, true},
+		{"operator prices", Principal{Role: RolePartner, INN: "7700000000", CompanyRole: CompanyRoleOperator}, PermCompanyPrices, false},
+		{"admin without company", Principal{Role: RoleAdmin}, PermCompanyView, false},
+		{"admin member", Principal{Role: RoleAdmin, INN: "7700000000", CompanyRole: CompanyRoleManager}, PermCompanyManage, true},
+		{"admin member keeps admin permissions", Principal{Role: RoleAdmin, INN: "7700000000", CompanyRole: CompanyRoleOperator}, PermAdminPartners, true},
+		{"partner admin permission", Principal{Role: RolePartner, INN: "7700000000", CompanyRole: CompanyRoleOwner}, PermAdminPartners, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.HasPermission(tt.permission); got != tt.want {
+				t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCompanyPermission(t *testing.T) {
+	for _, perm := range []string{PermCompanyView, PermCompanyManage, PermCompanyPrices, PermCompanyOrders, PermCompanyMembers, PermCompanyWebhooks} {
+		if !IsCompanyPermission(perm) {
+			t.Errorf("IsCompanyPermission(%q) = false", perm)
+		}
+	}
+	for _, perm := range []string{PermOrdersOwn, PermAdminPartners} {
+		if IsCompanyPermission(perm) {
+			t.Errorf("IsCompanyPermission(%q) = true", perm)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package rbac
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevocationStorage хранит моменты изменения ролей пользователей и отозванные access токены.
+// Access токены, выданные до момента изменения роли, считаются отозванными.
+// Отдельные токены задаются jti и сроком действия: map[jti]expiresAt
+type RevocationStorage interface {
+	Revoke(email string, at time.Time) error
+	GetSince(since time.Time) (map[string]time.Time, error)
+	DeleteBefore(before time.Time) error
+	RevokeTokens(tokens map[string]time.Time) error
+	RevokeUserTokens(email string) (map[string]time.Time, error)
+	GetRevokedTokens() (map[string]time.Time, error)
+	DeleteExpiredTokens() error
+}
+
+// PostgresRevocationStorage реализует RevocationStorage для PostgreSQL
+type PostgresRevocationStorage struct {
+	db *sql.DB
+}
+
+// NewPostgresRevocationStorage создаёт новый экземпляр PostgresRevocationStorage
+func NewPostgresRevocationStorage(db *sql.DB) *PostgresRevocationStorage {
+	return &PostgresRevocationStorage{db: db}
+}
+
+// Revoke записывает момент изменения роли пользователя
+func (s *PostgresRevocationStorage) Revoke(email string, at time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO role_revocations (email, revoked_at)
+		VALUES ($1, $2)
+		ON CONFLICT (email) DO UPDATE SET revoked_at = GREATEST(role_revocations.revoked_at, EXCLUDED.revoked_at)
+	`, email, at)
+	if err != nil {
+		return fmt.Errorf("insert role revocation: %w", err)
+	}
+	return nil
+}
+
+// GetSince возвращает отзывы, сделанные после since
+func (s *PostgresRevocationStorage) GetSince(since time.Time) (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT email, revoked_at FROM role_revocations WHERE revoked_at > $1`, since)
+	if err != nil {
+		return nil, fmt.Errorf("query role revocations: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]time.Time)
+	for rows.Next() {
+		var email string
+		var at time.Time
+		if err := rows.Scan(&email, &at); err != nil {
+			return nil, fmt.Errorf("scan role revocation: %w", err)
+		}
+		result[email] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteBefore удаляет отзывы, которые уже не влияют ни на один действующий токен
+func (s *PostgresRevocationStorage) DeleteBefore(before time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM role_revocations WHERE revoked_at < $1`, before)
+	return err
+}
+
+// RevokeTokens добавляет access токены в список отозванных
+func (s *PostgresRevocationStorage) RevokeTokens(tokens map[string]time.Time) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	jtis := make([]string, 0, len(tokens))
+	expiresAt := make([]time.Time, 0, len(tokens))
+	for jti, at := range tokens {
+		jtis = append(jtis, jti)
+		expiresAt = append(expiresAt, at)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO token_revocations (jti, expires_at)
+		SELECT * FROM unnest($1::uuid[], $2::timestamptz[])
+		ON CONFLICT (jti) DO NOTHING
+	`, jtis, expiresAt)
+	if err != nil {
+		return fmt.Errorf("insert token revocations: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserTokens отзывает действующие access токены всех сессий пользователя.
+// jti каждого выданного access токена записывается вместе с refresh токеном в refresh_tokens
+func (s *PostgresRevocationStorage) RevokeUserTokens(email string) (map[string]time.Time, error) {
+	rows, err := s.db.Query(`
+		INSERT INTO token_revocations (jti, expires_at)
+		SELECT access_jti, access_expires_at
+		FROM refresh_tokens
+		WHERE email = $1 AND access_jti IS NOT NULL AND access_expires_at > NOW()
+		ON CONFLICT (jti) DO NOTHING
+		RETURNING jti, expires_at
+	`, email)
+	if err != nil {
+		return nil, fmt.Errorf("revoke user tokens: %w", err)
+	}
+	return scanRevokedTokens(rows)
+}
+
+// GetRevokedTokens возвращает отозванные токены, срок действия которых ещё не истёк
+func (s *PostgresRevocationStorage) GetRevokedTokens() (map[string]time.Time, error) {
+	rows, err := s.db.Query(`SELECT jti, expires_at FROM token_revocations WHERE expires_at > NOW()`)
+	if err != nil {
+		return nil, fmt.Errorf("query token revocations: %w", err)
+	}
+	return scanRevokedTokens(rows)
+}
+
+// DeleteExpiredTokens удаляет отзывы токенов с истёкшим сроком действия
+func (s *PostgresRevocationStorage) DeleteExpiredTokens() error {
+	_, err := s.db.Exec(`DELETE FROM token_revocations WHERE expires_at < NOW()`)
+	return err
+}
+
+func scanRevokedTokens(rows *sql.Rows) (map[string]time.Time, error) {
+	defer rows.Close()
+
+	result := make(map[string]time.Time)
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return nil, fmt.Errorf("scan token revocation: %w", err)
+		}
+		result[jti] = expiresAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return result, nil
+}
+
+// Revocations отзывает access токены: все токены пользователя при изменении его роли
+// и отдельные токены по jti при завершении сессий.
+// Проверка выполняется по кэшу в памяти, который периодически синхронизируется
+// с хранилищем, чтобы отзыв на одном экземпляре приложения применялся и на остальных
+type Revocations struct {
+	storage   RevocationStorage
+	tokenTTL  time.Duration
+	syncEvery time.Duration
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	tokens  map[string]time.Time
+}
+
+// NewRevocations создаёт новый экземпляр Revocations.
+// tokenTTL - время жизни access токена: более старые отзывы не хранятся
+func NewRevocations(storage RevocationStorage, tokenTTL, syncEvery time.Duration) *Revocations {
+	return &Revocations{
+		storage:   storage,
+		tokenTTL:  tokenTTL,
+		syncEvery: syncEvery,
+		revoked:   make(map[string]time.Time),
+		tokens:    make(map[string]time.Time),
+	}
+}
+
+// Start загружает отзывы из хранилища и запускает фоновую синхронизацию
+func (r *Revocations) Start() {
+	if err := r.sync(); err != nil {
+		log.Printf("rbac: initial revocation sync failed: %v", err)
+	}
+	go r.syncLoop()
+}
+
+// Revoke отзывает все access токены пользователя, выданные до текущего момента.
+// Вызывается при изменении роли; новый токен с актуальной ролью выдаётся через /auth/refresh.
+// Токены сессий отзываются ещё и по jti, так как iat хранится с точностью до секунды
+func (r *Revocations) Revoke(email string) error {
+	now := time.Now()
+	if err := r.storage.Revoke(email, now); err != nil {
+		return err
+	}
+
+	tokens, err := r.storage.RevokeUserTokens(email)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.revoked[email] = now
+	for jti, expiresAt := range tokens {
+		r.tokens[jti] = expiresAt
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// RevokeTokens отзывает access токены по jti. tokens - jti и срок действия токена
+func (r *Revocations) RevokeTokens(tokens map[string]time.Time) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	if err := r.storage.RevokeTokens(tokens); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	for jti, expiresAt := range tokens {
+		r.tokens[jti] = expiresAt
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// IsRevoked проверяет, отозван ли токен пользователя, выданный в issuedAt
+func (r *Revocations) IsRevoked(email string, issuedAt time.Time) bool {
+	r.mu.RLock()
+	at, ok := r.revoked[email]
+	r.mu.RUnlock()
+
+	// iat хранится с точностью до секунды
+	return ok && issuedAt.Before(at.Truncate(time.Second))
+}
+
+// IsTokenRevoked проверяет, отозван ли access токен с идентификатором jti
+func (r *Revocations) IsTokenRevoked(jti string) bool {
+	r.mu.RLock()
+	_, ok := r.tokens[jti]
+	r.mu.RUnlock()
+	return ok
+}
+
+// Периодическая синхронизация с хранилищем
+func (r *Revocations) syncLoop() {
+	ticker := time.NewTicker(r.syncEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := r.sync(); err != nil {
+			log.Printf("rbac: revocation sync failed: %v", err)
+		}
+	}
+}
+
+// Загрузка актуальных отзывов и удаление устаревших
+func (r *Revocations) sync() error {
+	since := time.Now().Add(-r.tokenTTL)
+
+	revoked, err := r.storage.GetSince(since)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := r.storage.GetRevokedTokens()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	for email, at := range r.revoked {
+		if at.After(since) && at.After(revoked[email]) {
+			revoked[email] = at
+		}
+	}
+	r.revoked = revoked
+	for jti, expiresAt := range r.tokens {
+		if expiresAt.After(now) {
+			tokens[jti] = expiresAt
+		}
+	}
+	r.tokens = tokens
+	r.mu.Unlock()
+
+	if err := r.storage.DeleteBefore(since); err != nil {
+		return err
+	}
+	return r.storage.DeleteExpiredTokens()
+}
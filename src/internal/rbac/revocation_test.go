@@ -0,0 +1,173 @@
+package rbac
+
+import (
+	"maps"
+	"testing"
+	"time"
+)
+
+// Отзывы в памяти, общие для нескольких экземпляров Revocations.
+// userTokens - access токены сессий пользователей, как в refresh_tokens
+type memoryRevocations struct {
+	roles      map[string]time.Time
+	tokens     map[string]time.Time
+	userTokens map[string]map[string]time.Time
+}
+
+func newMemoryRevocations() *memoryRevocations {
+	return &memoryRevocations{
+		roles:      map[string]time.Time{},
+		tokens:     map[string]time.Time{},
+		userTokens: map[string]map[string]time.Time{},
+	}
+}
+
+func (s *memoryRevocations) Revoke(email string, at time.Time) error {
+	if at.After(s.roles[email]) {
+		s.roles[email] = at
+	}
+	return nil
+}
+
+func (s *memoryRevocations) GetSince(since time.Time) (map[string]time.Time, error) {
+	result := map[string]time.Time{}
+	for email, at := range s.roles {
+		if at.After(since) {
+			result[email] = at
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryRevocations) DeleteBefore(before time.Time) error {
+	for email, at := range s.roles {
+		if at.Before(before) {
+			delete(s.roles, email)
+		}
+	}
+	return nil
+}
+
+func (s *memoryRevocations) RevokeTokens(tokens map[string]time.Time) error {
+	maps.Copy(s.tokens, tokens)
+	return nil
+}
+
+func (s *memoryRevocations) RevokeUserTokens(email string) (map[string]time.Time, error) {
+	result := map[string]time.Time{}
+	for jti, expiresAt := range s.userTokens[email] {
+		if expiresAt.After(time.Now()) {
+			result[jti] = expiresAt
+		}
+	}
+	maps.Copy(s.tokens, result)
+	return result, nil
+}
+
+func (s *memoryRevocations) GetRevokedTokens() (map[string]time.Time, error) {
+	result := map[string]time.Time{}
+	for jti, expiresAt := range s.tokens {
+		if expiresAt.After(time.Now()) {
+			result[jti] = expiresAt
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryRevocations) DeleteExpiredTokens() error {
+	for jti, expiresAt := range s.tokens {
+		if expiresAt.Before(time.Now()) {
+			delete(s.tokens, jti)
+		}
+	}
+	return nil
+}
+
+const (
+	firstJTI  = "5f0c7a52-8d3e-4c1b-9a8e-2d9b1f6e4a10"
+	secondJTI = "0b6f9d2e-3a47-4e58-b1c9-7e2a5d8f6c31"
+)
+
+// Токен, отозванный на одном экземпляре, отклоняется и на другом после синхронизации
+func TestRevokeTokens(t *testing.T) {
+	storage := newMemoryRevocations()
+	first := NewRevocations(storage, 15*time.Minute, time.Minute)
+	second := NewRevocations(storage, 15*time.Minute, time.Minute)
+
+	if err := first.RevokeTokens(map[string]time.Time{firstJTI: time.Now().Add(10 * time.Minute)}); err != nil {
+		t.Fatalf("RevokeTokens: %v", err)
+	}
+	if !first.IsTokenRevoked(firstJTI) {
+		t.Error("revoked token is accepted")
+	}
+	if first.IsTokenRevoked(secondJTI) {
+		t.Error("token that was not revoked is rejected")
+	}
+
+	if second.IsTokenRevoked(firstJTI) {
+		t.Fatal("token is revoked on the second instance before sync")
+	}
+	if err := second.sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if !second.IsTokenRevoked(firstJTI) {
+		t.Error("revoked token is accepted by the second instance after sync")
+	}
+}
+
+// Отзыв хранится только до истечения срока токена: после него токен отклоняется по exp
+func TestRevokedTokenExpires(t *testing.T) {
+	storage := newMemoryRevocations()
+	r := NewRevocations(storage, 15*time.Minute, time.Minute)
+
+	err := r.RevokeTokens(map[string]time.Time{
+		firstJTI:  time.Now().Add(-time.Second),
+		secondJTI: time.Now().Add(10 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("RevokeTokens: %v", err)
+	}
+	if err := r.sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	if r.IsTokenRevoked(firstJTI) {
+		t.Error("expired revocation is kept in the cache")
+	}
+	if _, ok := storage.tokens[firstJTI]; ok {
+		t.Error("expired revocation is kept in the storage")
+	}
+	if !r.IsTokenRevoked(secondJTI) {
+		t.Error("revocation of a valid token is lost on sync")
+	}
+}
+
+// При смене роли отзываются токены, выданные раньше, и токены сессий пользователя по jti
+func TestRevokeUser(t *testing.T) {
+	storage := newMemoryRevocations()
+	storage.userTokens["user@example.com"] = map[string]time.Time{
+		secondJTI: time.Now().Add(10 * time.Minute),
+		firstJTI:  time.Now().Add(-time.Minute),
+	}
+	r := NewRevocations(storage, 15*time.Minute, time.Minute)
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	if err := r.Revoke("user@example.com"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if !r.IsRevoked("user@example.com", issuedAt) {
+		t.Error("token issued before the role change is accepted")
+	}
+	if r.IsRevoked("user@example.com", time.Now().Add(time.Second)) {
+		t.Error("token issued after the role change is rejected")
+	}
+	if r.IsRevoked("other@example.com", issuedAt) {
+		t.Error("token of another user is rejected")
+	}
+	if !r.IsTokenRevoked(secondJTI) {
+		t.Error("session token is not revoked by jti")
+	}
+	if r.IsTokenRevoked(firstJTI) {
+		t.Error("expired session token is revoked")
+	}
+}
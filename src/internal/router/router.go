@@ -2,39 +2,51 @@ package router
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"src/internal/admin"
+	"src/internal/apikey"
+	"src/internal/audit"
 	"src/internal/auth"
 	"src/internal/branch"
 	"src/internal/client"
 	"src/internal/company"
+	"src/internal/mail"
 	"src/internal/middleware"
 	"src/internal/order"
+	"src/internal/outbox"
 	"src/internal/partners"
+	"src/internal/rbac"
 	"src/internal/service"
+	"src/internal/webhook"
 )
 
-func New(authMiddleware *middleware.AuthMiddleware, adminMiddleware *middleware.AdminMiddleware, serviceHandler *service.Handler, companyHandler *company.Handler, clientHandler *client.Handler, orderHandler *order.Handler, branchHandler *branch.Handler, authHandler *auth.Handler, adminHandler *admin.Handler, partnersHandler *partners.Handler) http.Handler {
+func New(authMiddleware *middleware.AuthMiddleware, apiKeyMiddleware *middleware.APIKeyMiddleware, adminMiddleware *middleware.AdminMiddleware, rateLimiter *middleware.RateLimiter, serviceHandler *service.Handler, companyHandler *company.Handler, clientHandler *client.Handler, orderHandler *order.Handler, branchHandler *branch.Handler, authHandler *auth.Handler, adminHandler *admin.Handler, partnersHandler *partners.Handler, outboxHandler *outbox.Handler, webhookHandler *webhook.Handler, apiKeyHandler *apikey.Handler, auditHandler *audit.Handler, mailHandler *mail.Handler, mailboxHandler *mail.MailboxHandler) http.Handler {
 	r := chi.NewRouter()
 
 	// Глобальные middleware для всех запросов
 	r.Use(chimiddleware.Logger)    // логирование запросов
 	r.Use(chimiddleware.Recoverer) // восстановление после паник
 
+	// Общее ограничение частоты запросов с одного IP адреса.
+	// Ограничения групп ниже действуют дополнительно к нему
+	r.Use(rateLimiter.Limit("global", middleware.RateLimit{Requests: 600, Per: time.Minute}, middleware.KeyByIP))
+
 	// Swagger UI — доступен по /swagger/index.html
 	r.Get("/swagger/*", httpSwagger.Handler(
 		httpSwagger.InstanceName("swagger"),
 	))
 
+	// Открытые ключи проверки access токенов для сервисов партнёров
+	r.Get("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Маршруты для работы с услугами
+	// Дерево каталога услуг. Каталог меняют администраторы (/admin/services)
 	r.Route("/services", func(r chi.Router) {
-		//r.Post("/", serviceHandler.CreateService)
-		//r.Delete("/{id}", serviceHandler.DeleteService)
-
 		//Защищённые маршруты
 		r.With(authMiddleware.Authenticate).Get("/", serviceHandler.GetServices)
 	})
@@ -44,45 +56,128 @@ func New(authMiddleware *middleware.AuthMiddleware, adminMiddleware *middleware.
 		//r.Delete("/{inn}", companyHandler.DeleteCompany)
 		//r.Get("/order/{inn}", orderHandler.GetCompanyOrders)
 
-		//Защищёные маршруты
-		r.With(authMiddleware.Authenticate).Get("/", companyHandler.GetCompany)
-		r.With(authMiddleware.Authenticate).Get("/branches", companyHandler.GetBranchesByUser)
-		r.With(authMiddleware.Authenticate).Get("/branches/{branch_id}", companyHandler.GetBrancesByIdUser)
-		r.With(authMiddleware.Authenticate).Get("/branch/service/{branchServID}", companyHandler.GetServDetailsByBranchServId)
-		r.With(authMiddleware.Authenticate).Post("/users", companyHandler.AddNewUserToCompany)
-		r.With(authMiddleware.Authenticate).Post("/branch", companyHandler.AddNewBranchToCompany)
-		r.With(authMiddleware.Authenticate).Post("/branch/service", companyHandler.AddServiceToBranch)
-		r.With(authMiddleware.Authenticate).Get("/orders", companyHandler.GetCompanyOrders)
-		r.With(authMiddleware.Authenticate).Put("/order/status", companyHandler.UpdateOrderStatus)
-		r.With(authMiddleware.Authenticate).Post("/branch/service/detail", companyHandler.AddServDetail)
-		r.With(authMiddleware.Authenticate).Delete("/branch/service/detail/{branchServID}", companyHandler.DeleteServDetail)
+		//Защищёные маршруты. Разрешения в компании даёт роль в компании (owner, manager, operator)
+		// из claims inn и company_role, глобальная роль (client, partner, admin) на них не влияет.
+		// Кроме Bearer токена принимается API ключ компании (X-API-Key): ключу доступны
+		// только маршруты, где указаны его права (scopes)
+		r.Use(apiKeyMiddleware.Authenticate)
+		r.Use(rateLimiter.Limit("company", middleware.RateLimit{Requests: 300, Per: time.Minute}, middleware.KeyByPrincipal))
+
+		r.With(middleware.RequirePermission(rbac.PermCompanyView, rbac.APIKeyScopes...)).Get("/", companyHandler.GetCompany)
+		r.With(middleware.RequirePermission(rbac.PermCompanyView, rbac.APIKeyScopes...)).Get("/branches", companyHandler.GetBranchesByUser)
+		r.With(middleware.RequirePermission(rbac.PermCompanyView, rbac.APIKeyScopes...)).Get("/branches/{branch_id}", companyHandler.GetBrancesByIdUser)
+		r.With(middleware.RequirePermission(rbac.PermCompanyView, rbac.APIKeyScopes...)).Get("/branch/service/{branchServID}", companyHandler.GetServDetailsByBranchServId)
+		r.With(middleware.RequirePermission(rbac.PermCompanyView)).Get("/users", companyHandler.GetCompanyUsers)
+		// POST /company/users оставлен для совместимости и тоже отправляет приглашение
+		r.With(middleware.RequirePermission(rbac.PermCompanyMembers)).Post("/users", companyHandler.InviteUser)
+		r.With(middleware.RequirePermission(rbac.PermCompanyMembers)).Put("/users/{email}/role", companyHandler.UpdateCompanyUserRole)
+		// Сотрудник может удалить себя сам, поэтому роль в компании проверяется в CompanyManager
+		r.With(middleware.RequirePermission(rbac.PermCompanyView)).Delete("/users/{email}", companyHandler.RemoveCompanyUser)
+		r.With(middleware.RequirePermission(rbac.PermCompanyMembers)).Post("/invitations", companyHandler.InviteUser)
+		r.With(middleware.RequirePermission(rbac.PermCompanyMembers)).Get("/invitations", companyHandler.GetInvitations)
+		r.With(middleware.RequirePermission(rbac.PermCompanyMembers)).Delete("/invitations/{id}", companyHandler.RevokeInvitation)
+		r.With(middleware.RequirePermission(rbac.PermCompanyView)).Get("/security/2fa", companyHandler.GetMFAPolicy)
+		r.With(middleware.RequirePermission(rbac.PermCompanyMembers)).Put("/security/2fa", companyHandler.SetMFAPolicy)
+		r.With(middleware.RequirePermission(rbac.PermCompanyManage, rbac.ScopeCatalogManage)).Post("/branch", companyHandler.AddNewBranchToCompany)
+		r.With(middleware.RequirePermission(rbac.PermCompanyManage, rbac.ScopeCatalogManage)).Post("/branch/service", companyHandler.AddServiceToBranch)
+		r.With(middleware.RequirePermission(rbac.PermCompanyOrders, rbac.ScopeOrdersRead)).Get("/orders", companyHandler.GetCompanyOrders)
+		r.With(middleware.RequirePermission(rbac.PermCompanyOrders, rbac.ScopeOrdersRead)).Get("/orders/stream", companyHandler.StreamOrders)
+		r.With(middleware.RequirePermission(rbac.PermCompanyOrders, rbac.ScopeOrdersWrite)).Put("/order/status", companyHandler.UpdateOrderStatus)
+		r.With(middleware.RequirePermission(rbac.PermCompanyPrices, rbac.ScopeCatalogManage)).Post("/branch/service/detail", companyHandler.AddServDetail)
+		r.With(middleware.RequirePermission(rbac.PermCompanyPrices, rbac.ScopeCatalogManage)).Delete("/branch/service/detail/{branchServID}", companyHandler.DeleteServDetail)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(rbac.PermCompanyWebhooks))
+			r.Post("/webhooks", webhookHandler.CreateWebhook)
+			r.Get("/webhooks", webhookHandler.GetWebhooks)
+			r.Delete("/webhooks/{id}", webhookHandler.DeleteWebhook)
+			r.Get("/webhooks/{id}/deliveries", webhookHandler.GetDeliveries)
+			r.Post("/webhooks/{id}/test", webhookHandler.SendTestEvent)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(rbac.PermCompanyAPIKeys))
+			r.Post("/api-keys", apiKeyHandler.CreateAPIKey)
+			r.Get("/api-keys", apiKeyHandler.GetAPIKeys)
+			r.Delete("/api-keys/{id}", apiKeyHandler.RevokeAPIKey)
+		})
+	})
+
+	// Ответ на приглашение в компанию по коду из письма. Авторизация не нужна:
+	// приглашённый может быть ещё не зарегистрирован
+	r.Route("/invitations", func(r chi.Router) {
+		r.Use(rateLimiter.Limit("invitations", middleware.RateLimit{Requests: 30, Per: time.Minute}, middleware.KeyByIP))
+		r.Get("/{token}", companyHandler.GetInvitation)
+		r.Post("/accept", companyHandler.AcceptInvitation)
+		r.Post("/decline", companyHandler.DeclineInvitation)
 	})
 
 	r.Route("/client", func(r chi.Router) {
 		//r.Post("/", clientHandler.CreateClient)
 
 		//Защищённые маршруты
-		r.With(authMiddleware.Authenticate).Put("/city", clientHandler.UpdateCity)
-		r.With(authMiddleware.Authenticate).Get("/city", clientHandler.GetCity)
-		r.With(authMiddleware.Authenticate).Get("/orders", orderHandler.GetClientOrders)
+		r.With(authMiddleware.Authenticate, middleware.RequirePermission(rbac.PermProfile)).Put("/city", clientHandler.UpdateCity)
+		r.With(authMiddleware.Authenticate, middleware.RequirePermission(rbac.PermProfile)).Get("/city", clientHandler.GetCity)
+		r.With(authMiddleware.Authenticate, middleware.RequirePermission(rbac.PermOrdersOwn)).Get("/orders", orderHandler.GetClientOrders)
 	})
 
 	r.Route("/order", func(r chi.Router) {
 		//r.Get("/", orderHandler.GetFullAllOrders)
 
 		//Защищённые маршруты
-		r.With(authMiddleware.Authenticate).Post("/", orderHandler.CreateOrder)
+		r.With(
+			authMiddleware.Authenticate,
+			rateLimiter.Limit("order-create", middleware.RateLimit{Requests: 10, Per: time.Minute}, middleware.KeyByPrincipal),
+			middleware.RequirePermission(rbac.PermOrdersOwn),
+		).Post("/", orderHandler.CreateOrder)
 	})
 
 	r.Route("/auth", func(r chi.Router) {
-		r.Post("/register", authHandler.Register)
+		r.Use(rateLimiter.Limit("auth", middleware.RateLimit{Requests: 30, Per: time.Minute}, middleware.KeyByIP))
+
+		// Запросы, отправляющие письма: не больше 3 в минуту с одного IP адреса и не больше 10 в час
+		// на один адрес почты (для смены адреса - на пользователя), чтобы нельзя было засыпать письмами чужой ящик
+		sendsEmail := chi.Chain(
+			rateLimiter.Limit("auth-email", middleware.RateLimit{Requests: 3, Per: time.Minute}, middleware.KeyByIP),
+			rateLimiter.Limit("auth-email-address", middleware.RateLimit{Requests: 10, Per: time.Hour, Burst: 3}, middleware.KeyByRequestEmail),
+		).Handler
+
+		r.With(sendsEmail).Post("/register", authHandler.Register)
 		r.Post("/verify", authHandler.VerifyCode)
 		r.Post("/login", authHandler.Login)
+		r.Post("/login/2fa", authHandler.VerifyMFALogin)
+		r.Post("/login/2fa/setup", authHandler.SetupMFALogin)
 		r.Post("/refresh", authHandler.Refresh)
 		r.Post("/logout", authHandler.Logout)
-		r.Post("/forgot-password", authHandler.ForgotPassword)
+		r.With(sendsEmail).Post("/forgot-password", authHandler.ForgotPassword)
 		r.Post("/verify-reset-code", authHandler.VerifyResetCode)
 		r.Post("/set-password", authHandler.SetPassword)
+
+		// Вход через внешних провайдеров
+		r.Get("/oidc/providers", authHandler.GetOIDCProviders)
+		r.Post("/oidc/{provider}/authorize", authHandler.OIDCAuthorize)
+		r.Post("/oidc/{provider}/callback", authHandler.OIDCCallback)
+
+		// Сессии устройств
+		r.With(authMiddleware.Authenticate).Get("/sessions", authHandler.GetSessions)
+		r.With(authMiddleware.Authenticate).Delete("/sessions", authHandler.LogoutOtherSessions)
+		r.With(authMiddleware.Authenticate).Delete("/sessions/{id}", authHandler.DeleteSession)
+
+		// Журнал входов
+		r.With(authMiddleware.Authenticate).Get("/security-events", authHandler.GetSecurityEvents)
+
+		// Двухфакторная аутентификация
+		r.With(authMiddleware.Authenticate).Get("/2fa", authHandler.GetMFAStatus)
+		r.With(authMiddleware.Authenticate).Post("/2fa/setup", authHandler.SetupMFA)
+		r.With(authMiddleware.Authenticate).Post("/2fa/enable", authHandler.EnableMFA)
+		r.With(authMiddleware.Authenticate).Post("/2fa/disable", authHandler.DisableMFA)
+		r.With(authMiddleware.Authenticate).Post("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+		// Управление аккаунтом
+		r.With(authMiddleware.Authenticate).Post("/change-password", authHandler.ChangePassword)
+		r.With(authMiddleware.Authenticate, sendsEmail).Post("/change-email", authHandler.ChangeEmail)
+		r.With(authMiddleware.Authenticate).Post("/change-email/confirm", authHandler.ConfirmEmailChange)
+		r.With(authMiddleware.Authenticate).Delete("/account", authHandler.DeleteAccount)
 	})
 
 	r.Route("/branch", func(r chi.Router) {
@@ -97,27 +192,94 @@ func New(authMiddleware *middleware.AuthMiddleware, adminMiddleware *middleware.
 	r.Route("/partner", func(r chi.Router) {
 		// Защищенные маршруты
 		r.Use(authMiddleware.Authenticate)
+		r.Use(rateLimiter.Limit("partner", middleware.RateLimit{Requests: 30, Per: time.Minute}, middleware.KeyByPrincipal))
+		r.Use(middleware.RequirePermission(rbac.PermPartnerRequest))
 		r.Post("/request", partnersHandler.CreatePartnerRequest)
 		r.Get("/request", partnersHandler.GetRequestStatus)
+		r.Get("/request/comments", partnersHandler.GetComments)
+		r.Post("/request/comments", partnersHandler.AddComment)
 	})
 
 	r.Route("/admin", func(r chi.Router) {
 		// Защищенные маршруты + проверка на админа
 		r.Use(authMiddleware.Authenticate)
 		r.Use(adminMiddleware.RequireAdmin)
+		r.Use(rateLimiter.Limit("admin", middleware.RateLimit{Requests: 300, Per: time.Minute}, middleware.KeyByPrincipal))
+
+		r.With(middleware.RequirePermission(rbac.PermAdminUsers)).Post("/create-admin", adminHandler.CreateAdmin)
+		r.With(middleware.RequirePermission(rbac.PermAdminUsers)).Post("/users/{email}/unlock", authHandler.UnlockAccount)
+		r.With(middleware.RequirePermission(rbac.PermAdminUsers)).Post("/users/{email}/suspend", authHandler.SuspendUser)
+		r.With(middleware.RequirePermission(rbac.PermAdminUsers)).Post("/users/{email}/reinstate", authHandler.ReinstateUser)
+		r.With(middleware.RequirePermission(rbac.PermAdminUsers)).Get("/security/2fa", authHandler.GetAdminMFAPolicy)
+		r.With(middleware.RequirePermission(rbac.PermAdminUsers)).Put("/security/2fa", authHandler.SetAdminMFAPolicy)
 
-		r.Post("/create-admin", adminHandler.CreateAdmin)
-
-		r.Get("/partner-requests/", adminHandler.GetAllRequests)
-		r.Get("/partner-requests/new", adminHandler.GetNewRequests)
-		r.Get("/partner-requests/pending", adminHandler.GetPendingRequests)
-		r.Get("/partner-requests/approved", adminHandler.GetApprovedRequests)
-		r.Get("/partner-requests/rejected", adminHandler.GetRejectedRequests)
-		r.Get("/partner-requests/{id}", adminHandler.GetRequest)
-		r.Post("/partner-requests/take", adminHandler.TakeRequestToWork)
-		r.Post("/partner-requests/approve", adminHandler.ApprovePartnerRequest)
-		r.Post("/partner-requests/reject", adminHandler.RejectPartnerRequest)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(rbac.PermAdminPartners))
+			r.Get("/partner-requests/", adminHandler.GetAllRequests)
+			r.Get("/partner-requests/new", adminHandler.GetNewRequests)
+			r.Get("/partner-requests/pending", adminHandler.GetPendingRequests)
+			r.Get("/partner-requests/needs-info", adminHandler.GetNeedsInfoRequests)
+			r.Get("/partner-requests/approved", adminHandler.GetApprovedRequests)
+			r.Get("/partner-requests/rejected", adminHandler.GetRejectedRequests)
+			r.Get("/partner-requests/my", adminHandler.GetMyRequests)
+			r.Get("/partner-requests/{id}", adminHandler.GetRequest)
+			r.Get("/partner-requests/{id}/comments", adminHandler.GetComments)
+			r.Post("/partner-requests/{id}/comments", adminHandler.AddComment)
+			r.Post("/partner-requests/take", adminHandler.TakeRequestToWork)
+			r.Post("/partner-requests/assign", adminHandler.AssignRequest)
+			r.Post("/partner-requests/release", adminHandler.ReleaseRequest)
+			r.Post("/partner-requests/approve", adminHandler.ApprovePartnerRequest)
+			r.Post("/partner-requests/reject", adminHandler.RejectPartnerRequest)
+			r.Post("/partner-requests/request-info", adminHandler.RequestMoreInfo)
+
+			// Приостановка и возобновление работы компаний
+			r.Post("/companies/{inn}/suspend", adminHandler.SuspendCompany)
+			r.Post("/companies/{inn}/reinstate", adminHandler.ReinstateCompany)
+			r.Get("/companies/{inn}/orders/upcoming", adminHandler.GetUpcomingOrders)
+		})
+
+		// Каталог услуг и категорий
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(rbac.PermAdminCatalog))
+			r.Get("/services", serviceHandler.GetAdminCatalog)
+			r.Post("/services", serviceHandler.CreateService)
+			r.Put("/services/order", serviceHandler.ReorderServices)
+			r.Put("/services/{id}", serviceHandler.UpdateService)
+			r.Post("/services/{id}/archive", serviceHandler.ArchiveService)
+			r.Post("/services/{id}/restore", serviceHandler.RestoreService)
+			r.Delete("/services/{id}", serviceHandler.DeleteService)
+			r.Post("/service-categories", serviceHandler.CreateCategory)
+			r.Put("/service-categories/order", serviceHandler.ReorderCategories)
+			r.Put("/service-categories/{id}", serviceHandler.UpdateCategory)
+			r.Delete("/service-categories/{id}", serviceHandler.DeleteCategory)
+		})
+
+		// Журнал действий администраторов
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(rbac.PermAdminAudit))
+			r.Get("/audit", auditHandler.GetEntries)
+			r.Get("/audit/export", auditHandler.Export)
+		})
+
+		// Исходящие сообщения: просмотр и повторная отправка недоставленных
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(rbac.PermAdminSystem))
+			r.Get("/outbox", outboxHandler.GetMessages)
+			r.Get("/outbox/{id}", outboxHandler.GetMessage)
+			r.Post("/outbox/{id}/replay", outboxHandler.ReplayMessage)
+			r.Get("/email/templates", mailHandler.GetTemplates)
+			r.Get("/email/preview/{name}", mailHandler.Preview)
+		})
 	})
 
+	// Почтовый ящик в памяти - только для разработки (APP_ENV=development, EMAIL_TRANSPORT=memory, DEV_MAILBOX=true)
+	if mailboxHandler != nil {
+		r.Route("/dev/mailbox", func(r chi.Router) {
+			r.Get("/", mailboxHandler.GetMessages)
+			r.Get("/{id}", mailboxHandler.GetMessage)
+			r.Delete("/", mailboxHandler.Clear)
+		})
+	}
+
 	return r
 }
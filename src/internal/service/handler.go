@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"src/internal/audit"
+	"src/internal/middleware"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -19,75 +21,234 @@ func NewHandler(service *ServiceManager) *Handler {
 	return &Handler{service: service}
 }
 
-// GetServices обрабатывает GET /services.
+// GetServices обрабатывает GET /services, возвращает дерево каталога
+// без архивных услуг и пустых категорий
 func (h *Handler) GetServices(w http.ResponseWriter, r *http.Request) {
-	services, err := h.service.GetAllServices()
+	catalog, err := h.service.GetCatalog(false)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Преобразуем []Service в []ServiceResponse, если нужно скрыть поля
-	resp := make([]ServiceResponse, len(services))
-	for i, s := range services {
-		resp[i] = ServiceResponse{ID: s.ID, Name: s.Name}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(catalog)
+}
+
+// GetAdminCatalog обрабатывает GET /admin/services, возвращает дерево каталога
+// с архивными услугами, пустыми категориями и количеством филиалов у каждой услуги
+func (h *Handler) GetAdminCatalog(w http.ResponseWriter, r *http.Request) {
+	catalog, err := h.service.GetCatalog(true)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(catalog)
+}
+
+// Общая обработка ошибок ServiceManager
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrEmptyName), errors.Is(err, ErrInvalidOrder), errors.Is(err, ErrCategoryCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrServiceNotFound):
+		http.Error(w, "Service not found", http.StatusNotFound)
+	case errors.Is(err, ErrCategoryNotFound):
+		http.Error(w, "Category not found", http.StatusNotFound)
+	case errors.Is(err, ErrServiceAlreadyExists):
+		http.Error(w, "Service with this name already exists", http.StatusConflict)
+	case errors.Is(err, ErrCategoryExists), errors.Is(err, ErrCategoryNotEmpty), errors.Is(err, ErrServiceInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Получение ID из URL
+func idFromRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// Разбор и проверка тела запроса
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return false
+	}
+	return true
 }
 
 // Создаёт новую услугу - если услуга с таким именем уже есть отдаёт ошибку
 // Service with this name already exists
 func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
 	var req CreateServiceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	service, err := h.service.CreateService(req.Name)
+	service, err := h.service.CreateService(audit.ActorFromRequest(r), req)
 	if err != nil {
-		if errors.Is(err, ErrEmptyName) {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if errors.Is(err, ErrServiceAlreadyExists) {
-			http.Error(w, "Service with this name already exists", http.StatusConflict)
-			return
-		}
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	resp := ServiceResponse{
-		ID:   service.ID,
-		Name: service.Name,
-	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(newServiceResponse(service, true))
+}
+
+// UpdateService обрабатывает PUT /admin/services/{id}: переименование, перенос в другую категорию,
+// описание и иконка
+func (h *Handler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateServiceRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	service, err := h.service.UpdateService(audit.ActorFromRequest(r), id, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newServiceResponse(service, true))
+}
+
+// ArchiveService обрабатывает POST /admin/services/{id}/archive
+func (h *Handler) ArchiveService(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// RestoreService обрабатывает POST /admin/services/{id}/restore
+func (h *Handler) RestoreService(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *Handler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	id, ok := idFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.ArchiveService(audit.ActorFromRequest(r), id, archived); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // При успешном удалении возвращает статус 204 No Content.
-// Если услуга не найдена, возвращает 404 Not Found.
+// Если услуга не найдена, возвращает 404 Not Found, если её оказывают филиалы - 409 Conflict.
 func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request) {
-	// Извлечение ID из URL (предполагается использование chi)
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
+	id, ok := idFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteService(audit.ActorFromRequest(r), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderServices обрабатывает PUT /admin/services/order
+func (h *Handler) ReorderServices(w http.ResponseWriter, r *http.Request) {
+	var req ReorderRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.service.ReorderServices(audit.ActorFromRequest(r), req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateCategory обрабатывает POST /admin/service-categories
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req CreateCategoryRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	category, err := h.service.CreateCategory(audit.ActorFromRequest(r), req)
 	if err != nil {
-		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	err = h.service.DeleteService(id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newCategoryResponse(category))
+}
+
+// UpdateCategory обрабатывает PUT /admin/service-categories/{id}
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateCategoryRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	category, err := h.service.UpdateCategory(audit.ActorFromRequest(r), id, req)
 	if err != nil {
-		if errors.Is(err, ErrServiceNotFound) {
-			http.Error(w, "Service not found", http.StatusNotFound)
-			return
-		}
-		// Внутренняя ошибка сервера
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newCategoryResponse(category))
+}
+
+// DeleteCategory обрабатывает DELETE /admin/service-categories/{id}. Удаляется только пустая категория
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := idFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteCategory(audit.ActorFromRequest(r), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderCategories обрабатывает PUT /admin/service-categories/order
+func (h *Handler) ReorderCategories(w http.ResponseWriter, r *http.Request) {
+	var req ReorderRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.service.ReorderCategories(audit.ActorFromRequest(r), req); err != nil {
+		writeError(w, err)
 		return
 	}
 
@@ -1,25 +1,107 @@
 package service
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
 // Service представляет данные общей услуги
 // Соответствует таблице services в базе данных
 type Service struct {
-	ID   uuid.UUID `json:"id"`
-	Name string    `json:"name"`
+	ID          uuid.UUID
+	Name        string
+	CategoryID  *uuid.UUID
+	Description string
+	Icon        string
+	Position    int
+	ArchivedAt  *time.Time
+	Branches    int // количество филиалов, оказывающих услугу
+}
+
+// Category - категория каталога услуг, соответствует таблице service_categories
+type Category struct {
+	ID          uuid.UUID
+	ParentID    *uuid.UUID
+	Name        string
+	Description string
+	Icon        string
+	Position    int
 }
 
 // CreateServiceRequest описывает тело запроса для создания новой услуги.
-// Используется в эндпоинте POST /services.
+// Используется в эндпоинтах POST /admin/services и PUT /admin/services/{id}.
+// Без category_id услуга находится в корне каталога
 type CreateServiceRequest struct {
-	Name string `json:"name"`
+	Name        string     `json:"name" example:"Автомойка" validate:"required,max=100"`
+	CategoryID  *uuid.UUID `json:"category_id,omitempty" example:"5b9d7c1a-2e4f-4a6b-8c0d-1e2f3a4b5c6d"`
+	Description string     `json:"description,omitempty" example:"Мойка кузова и салона" validate:"max=1000"`
+	Icon        string     `json:"icon,omitempty" example:"car-wash" validate:"max=255"`
+}
+
+// CreateCategoryRequest - тело запроса POST /admin/service-categories и PUT /admin/service-categories/{id}.
+// Без parent_id категория находится в корне каталога
+type CreateCategoryRequest struct {
+	Name        string     `json:"name" example:"Уход за автомобилем" validate:"required,max=100"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty" example:"5b9d7c1a-2e4f-4a6b-8c0d-1e2f3a4b5c6d"`
+	Description string     `json:"description,omitempty" example:"Мойка, полировка, химчистка" validate:"max=1000"`
+	Icon        string     `json:"icon,omitempty" example:"sparkles" validate:"max=255"`
+}
+
+// ReorderRequest - новый порядок услуг или подкатегорий одной категории.
+// ids должен содержать все элементы категории, без parent_id - корня каталога
+type ReorderRequest struct {
+	ParentID *uuid.UUID  `json:"parent_id,omitempty" example:"5b9d7c1a-2e4f-4a6b-8c0d-1e2f3a4b5c6d"`
+	IDs      []uuid.UUID `json:"ids" validate:"required,min=1"`
 }
 
 // ServiceResponse представляет данные услуги, возвращаемые клиенту.
 // Используется в ответах на GET /services
 type ServiceResponse struct {
-	ID   uuid.UUID `json:"id" example:"83817fd0-ffd0-478b-b1ae-b082e8581830" format:"uuid"`
-	Name string    `json:"name" example:"Aвтомойка"`
+	ID          uuid.UUID `json:"id" example:"83817fd0-ffd0-478b-b1ae-b082e8581830" format:"uuid"`
+	Name        string    `json:"name" example:"Aвтомойка"`
+	Description string    `json:"description,omitempty" example:"Мойка кузова и салона"`
+	Icon        string    `json:"icon,omitempty" example:"car-wash"`
+
+	// Только для администратора
+	CategoryID *uuid.UUID `json:"category_id,omitempty" example:"5b9d7c1a-2e4f-4a6b-8c0d-1e2f3a4b5c6d" format:"uuid"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty" example:"2026-03-30T06:06:47.181805Z"`
+	Branches   *int       `json:"branches,omitempty" example:"12"`
+}
+
+// CategoryResponse - данные категории каталога
+type CategoryResponse struct {
+	ID          uuid.UUID  `json:"id" example:"5b9d7c1a-2e4f-4a6b-8c0d-1e2f3a4b5c6d" format:"uuid"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty" example:"0e4c2a1b-7d3f-4b5a-9c8e-6f1d2a3b4c5e" format:"uuid"`
+	Name        string     `json:"name" example:"Уход за автомобилем"`
+	Description string     `json:"description,omitempty" example:"Мойка, полировка, химчистка"`
+	Icon        string     `json:"icon,omitempty" example:"sparkles"`
+}
+
+// CategoryNode - категория с подкатегориями и услугами в заданном порядке
+type CategoryNode struct {
+	CategoryResponse
+	Categories []*CategoryNode    `json:"categories"`
+	Services   []*ServiceResponse `json:"services"`
+}
+
+// CatalogResponse - дерево каталога услуг. services - услуги без категории
+type CatalogResponse struct {
+	Categories []*CategoryNode    `json:"categories"`
+	Services   []*ServiceResponse `json:"services"`
+}
+
+func newServiceResponse(s *Service, admin bool) *ServiceResponse {
+	resp := &ServiceResponse{ID: s.ID, Name: s.Name, Description: s.Description, Icon: s.Icon}
+	if admin {
+		branches := s.Branches
+		resp.CategoryID = s.CategoryID
+		resp.ArchivedAt = s.ArchivedAt
+		resp.Branches = &branches
+	}
+	return resp
+}
+
+func newCategoryResponse(c *Category) CategoryResponse {
+	return CategoryResponse{ID: c.ID, ParentID: c.ParentID, Name: c.Name, Description: c.Description, Icon: c.Icon}
 }
@@ -1,8 +1,14 @@
 package service
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+
+	"src/internal/audit"
+	"src/internal/db"
 
 	"github.com/google/uuid"
 )
@@ -10,19 +16,24 @@ import (
 var (
 	// ErrEmptyName возвращается, если название услуги пустое.
 	ErrEmptyName = errors.New("service name cannot be empty")
+	// ErrCategoryCycle возвращается при попытке перенести категорию в саму себя или в свою подкатегорию
+	ErrCategoryCycle = errors.New("category cannot be moved into itself or its subcategory")
 )
 
 // ServiceManager содержит бизнес-логику для работы с услугами.
+// Изменения каталога сохраняются в одной транзакции с записью в журнал действий
 type ServiceManager struct {
-	storage ServiceStorage
+	storage  ServiceStorage
+	auditLog audit.Recorder
+	uow      db.UnitOfWork
 }
 
 // NewServiceManager создаёт новый экземпляр ServiceManager.
-func NewServiceManager(storage ServiceStorage) *ServiceManager {
-	return &ServiceManager{storage: storage}
+func NewServiceManager(storage ServiceStorage, auditLog audit.Recorder, uow db.UnitOfWork) *ServiceManager {
+	return &ServiceManager{storage: storage, auditLog: auditLog, uow: uow}
 }
 
-// GetAllServices возвращает список всех услуг.
+// GetAllServices возвращает список всех услуг, включая архивные.
 func (m *ServiceManager) GetAllServices() ([]Service, error) {
 	services, err := m.storage.GetAll()
 	if err != nil {
@@ -31,16 +42,328 @@ func (m *ServiceManager) GetAllServices() ([]Service, error) {
 	return services, nil
 }
 
-// CreateService создаёт новую услугу.
-func (m *ServiceManager) CreateService(name string) (*Service, error) {
+// GetCatalog возвращает дерево каталога. Для клиентов (admin = false) архивные услуги
+// и категории без действующих услуг не показываются
+func (m *ServiceManager) GetCatalog(admin bool) (*CatalogResponse, error) {
+	categories, err := m.storage.GetCategories()
+	if err != nil {
+		return nil, fmt.Errorf("get service categories: %w", err)
+	}
+	services, err := m.GetAllServices()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uuid.UUID]*CategoryNode, len(categories))
+	for _, c := range categories {
+		nodes[c.ID] = &CategoryNode{
+			CategoryResponse: newCategoryResponse(&c),
+			Categories:       []*CategoryNode{},
+			Services:         []*ServiceResponse{},
+		}
+		if !admin {
+			nodes[c.ID].ParentID = nil
+		}
+	}
+
+	// Категории и услуги уже отсортированы по position, поэтому порядок сохраняется
+	catalog := &CatalogResponse{Categories: []*CategoryNode{}, Services: []*ServiceResponse{}}
+	for _, c := range categories {
+		if parent, ok := nodes[derefID(c.ParentID)]; ok {
+			parent.Categories = append(parent.Categories, nodes[c.ID])
+		} else {
+			catalog.Categories = append(catalog.Categories, nodes[c.ID])
+		}
+	}
+	for _, s := range services {
+		if s.ArchivedAt != nil && !admin {
+			continue
+		}
+		if category, ok := nodes[derefID(s.CategoryID)]; ok {
+			category.Services = append(category.Services, newServiceResponse(&s, admin))
+		} else {
+			catalog.Services = append(catalog.Services, newServiceResponse(&s, admin))
+		}
+	}
+
+	if !admin {
+		catalog.Categories = pruneEmpty(catalog.Categories)
+	}
+	return catalog, nil
+}
+
+// Удаление категорий без услуг во всём поддереве
+func pruneEmpty(categories []*CategoryNode) []*CategoryNode {
+	result := []*CategoryNode{}
+	for _, c := range categories {
+		c.Categories = pruneEmpty(c.Categories)
+		if len(c.Categories) > 0 || len(c.Services) > 0 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func derefID(id *uuid.UUID) uuid.UUID {
+	if id == nil {
+		return uuid.Nil
+	}
+	return *id
+}
+
+// CreateService создаёт новую услугу в конце категории.
+func (m *ServiceManager) CreateService(actor audit.Actor, req CreateServiceRequest) (*Service, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+
+	var created *Service
+	err := m.uow.Do(func(tx *sql.Tx) error {
+		var err error
+		created, err = m.storage.WithTx(tx).Create(&Service{
+			Name:        name,
+			CategoryID:  req.CategoryID,
+			Description: strings.TrimSpace(req.Description),
+			Icon:        strings.TrimSpace(req.Icon),
+		})
+		if err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionServiceCreate, audit.TargetService, created.ID.String(),
+			nil, newServiceResponse(created, true))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("service %s (%s) created", created.ID, created.Name)
+	return created, nil
+}
+
+// UpdateService переименовывает услугу, меняет её категорию, описание и иконку
+func (m *ServiceManager) UpdateService(actor audit.Actor, id uuid.UUID, req CreateServiceRequest) (*Service, error) {
+	name := strings.TrimSpace(req.Name)
 	if name == "" {
 		return nil, ErrEmptyName
 	}
-	return m.storage.Create(name)
+
+	before, err := m.getService(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated *Service
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		updated, err = m.storage.WithTx(tx).Update(&Service{
+			ID:          id,
+			Name:        name,
+			CategoryID:  req.CategoryID,
+			Description: strings.TrimSpace(req.Description),
+			Icon:        strings.TrimSpace(req.Icon),
+			Branches:    before.Branches,
+		})
+		if err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionServiceUpdate, audit.TargetService, id.String(),
+			newServiceResponse(before, true), newServiceResponse(updated, true))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// ArchiveService переносит услугу в архив или возвращает из него. Архивная услуга остаётся
+// у филиалов, которые её оказывают, но скрыта из каталога и не добавляется в новые филиалы
+func (m *ServiceManager) ArchiveService(actor audit.Actor, id uuid.UUID, archived bool) error {
+	before, err := m.getService(id)
+	if err != nil {
+		return err
+	}
+
+	action := audit.ActionServiceArchive
+	if !archived {
+		action = audit.ActionServiceRestore
+	}
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		if err := m.storage.WithTx(tx).SetArchived(id, archived); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, action, audit.TargetService, id.String(),
+			map[string]bool{"archived": before.ArchivedAt != nil}, map[string]bool{"archived": archived})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("service %s archived: %t", id, archived)
+	return nil
 }
 
 // DeleteService удаляет услугу по идентификатору.
-// Если услуга не найдена, возвращает ошибку ErrServiceNotFound.
-func (m *ServiceManager) DeleteService(id uuid.UUID) error {
-	return m.storage.Delete(id)
+// Если услуга не найдена, возвращает ошибку ErrServiceNotFound,
+// если её оказывают филиалы - ErrServiceInUse
+func (m *ServiceManager) DeleteService(actor audit.Actor, id uuid.UUID) error {
+	before, err := m.getService(id)
+	if err != nil {
+		return err
+	}
+
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		if err := m.storage.WithTx(tx).Delete(id); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionServiceDelete, audit.TargetService, id.String(),
+			newServiceResponse(before, true), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("service %s deleted", id)
+	return nil
+}
+
+// ReorderServices задаёт порядок услуг категории
+func (m *ServiceManager) ReorderServices(actor audit.Actor, req ReorderRequest) error {
+	return m.uow.Do(func(tx *sql.Tx) error {
+		if err := m.storage.WithTx(tx).ReorderServices(req.ParentID, req.IDs); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionServiceReorder, audit.TargetCategory, derefID(req.ParentID).String(), nil, req)
+	})
+}
+
+// Услуга для снимка в журнале действий. Если услуги нет, возвращает ErrServiceNotFound
+func (m *ServiceManager) getService(id uuid.UUID) (*Service, error) {
+	service, err := m.storage.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if service == nil {
+		return nil, fmt.Errorf("get service %v: %w", id, ErrServiceNotFound)
+	}
+	return service, nil
+}
+
+// CreateCategory создаёт категорию в конце родительской категории
+func (m *ServiceManager) CreateCategory(actor audit.Actor, req CreateCategoryRequest) (*Category, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+
+	var created *Category
+	err := m.uow.Do(func(tx *sql.Tx) error {
+		var err error
+		created, err = m.storage.WithTx(tx).CreateCategory(&Category{
+			ParentID:    req.ParentID,
+			Name:        name,
+			Description: strings.TrimSpace(req.Description),
+			Icon:        strings.TrimSpace(req.Icon),
+		})
+		if err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCategoryCreate, audit.TargetCategory, created.ID.String(),
+			nil, newCategoryResponse(created))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateCategory меняет категорию. Категорию нельзя перенести в неё саму или в её подкатегорию
+func (m *ServiceManager) UpdateCategory(actor audit.Actor, id uuid.UUID, req CreateCategoryRequest) (*Category, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrEmptyName
+	}
+
+	before, err := m.getCategory(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentID != nil {
+		categories, err := m.storage.GetCategories()
+		if err != nil {
+			return nil, fmt.Errorf("get service categories: %w", err)
+		}
+		parents := make(map[uuid.UUID]*uuid.UUID, len(categories))
+		for _, c := range categories {
+			parents[c.ID] = c.ParentID
+		}
+
+		// Подъём от нового родителя к корню не должен пройти через переносимую категорию
+		for parent := req.ParentID; parent != nil; parent = parents[*parent] {
+			if *parent == id {
+				return nil, ErrCategoryCycle
+			}
+			if _, ok := parents[*parent]; !ok {
+				return nil, ErrCategoryNotFound
+			}
+		}
+	}
+
+	var updated *Category
+	err = m.uow.Do(func(tx *sql.Tx) error {
+		updated, err = m.storage.WithTx(tx).UpdateCategory(&Category{
+			ID:          id,
+			ParentID:    req.ParentID,
+			Name:        name,
+			Description: strings.TrimSpace(req.Description),
+			Icon:        strings.TrimSpace(req.Icon),
+		})
+		if err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCategoryUpdate, audit.TargetCategory, id.String(),
+			newCategoryResponse(before), newCategoryResponse(updated))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteCategory удаляет пустую категорию
+func (m *ServiceManager) DeleteCategory(actor audit.Actor, id uuid.UUID) error {
+	before, err := m.getCategory(id)
+	if err != nil {
+		return err
+	}
+
+	return m.uow.Do(func(tx *sql.Tx) error {
+		if err := m.storage.WithTx(tx).DeleteCategory(id); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCategoryDelete, audit.TargetCategory, id.String(),
+			newCategoryResponse(before), nil)
+	})
+}
+
+// ReorderCategories задаёт порядок подкатегорий категории
+func (m *ServiceManager) ReorderCategories(actor audit.Actor, req ReorderRequest) error {
+	return m.uow.Do(func(tx *sql.Tx) error {
+		if err := m.storage.WithTx(tx).ReorderCategories(req.ParentID, req.IDs); err != nil {
+			return err
+		}
+		return m.auditLog.Record(tx, actor, audit.ActionCategoryReorder, audit.TargetCategory, derefID(req.ParentID).String(), nil, req)
+	})
+}
+
+// Категория для снимка в журнале действий. Если категории нет, возвращает ErrCategoryNotFound
+func (m *ServiceManager) getCategory(id uuid.UUID) (*Category, error) {
+	category, err := m.storage.GetCategory(id)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, fmt.Errorf("get service category %v: %w", id, ErrCategoryNotFound)
+	}
+	return category, nil
 }
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"src/internal/audit"
+)
+
+// Хранилище каталога в памяти
+type memoryStorage struct {
+	ServiceStorage
+	categories []Category
+	created    []*Service
+}
+
+func (s *memoryStorage) GetCategories() ([]Category, error) {
+	return s.categories, nil
+}
+
+func (s *memoryStorage) GetCategory(id uuid.UUID) (*Category, error) {
+	for i := range s.categories {
+		if s.categories[i].ID == id {
+			return &s.categories[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStorage) Create(service *Service) (*Service, error) {
+	created := *service
+	created.ID = uuid.New()
+	s.created = append(s.created, &created)
+	return &created, nil
+}
+
+func (s *memoryStorage) UpdateCategory(category *Category) (*Category, error) {
+	return category, nil
+}
+
+func (s *memoryStorage) WithTx(tx *sql.Tx) ServiceStorage {
+	return s
+}
+
+type noAudit struct{}
+
+func (noAudit) Record(tx *sql.Tx, actor audit.Actor, action, targetType, targetID string, before, after any) error {
+	return nil
+}
+
+// Выполняет fn без транзакции
+type directUnitOfWork struct{}
+
+func (directUnitOfWork) Do(fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+func newTestManager(storage *memoryStorage) *ServiceManager {
+	return NewServiceManager(storage, noAudit{}, directUnitOfWork{})
+}
+
+func TestCreateServiceValidation(t *testing.T) {
+	storage := &memoryStorage{}
+	m := newTestManager(storage)
+
+	if _, err := m.CreateService(audit.Actor{}, CreateServiceRequest{Name: "   "}); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("empty name error = %v, want ErrEmptyName", err)
+	}
+	if len(storage.created) != 0 {
+		t.Fatalf("created = %d services, want 0", len(storage.created))
+	}
+
+	created, err := m.CreateService(audit.Actor{}, CreateServiceRequest{Name: " Автомойка ", Description: " Мойка кузова ", Icon: " car-wash "})
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	if created.Name != "Автомойка" || created.Description != "Мойка кузова" || created.Icon != "car-wash" {
+		t.Errorf("created = %+v", created)
+	}
+}
+
+// Категорию нельзя перенести в неё саму, в её подкатегорию или в несуществующую категорию
+func TestUpdateCategoryParent(t *testing.T) {
+	root, child, grandchild, other := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	m := newTestManager(&memoryStorage{categories: []Category{
+		{ID: root},
+		{ID: child, ParentID: &root},
+		{ID: grandchild, ParentID: &child},
+		{ID: other},
+	}})
+	unknown := uuid.New()
+
+	tests := []struct {
+		name    string
+		parent  *uuid.UUID
+		wantErr error
+	}{
+		{"itself", &root, ErrCategoryCycle},
+		{"subcategory", &grandchild, ErrCategoryCycle},
+		{"unknown parent", &unknown, ErrCategoryNotFound},
+		{"other category", &other, nil},
+		{"top level", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.UpdateCategory(audit.Actor{}, root, CreateCategoryRequest{Name: "Уход", ParentID: tt.parent})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateCategory error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := m.UpdateCategory(audit.Actor{}, root, CreateCategoryRequest{Name: " "}); !errors.Is(err, ErrEmptyName) {
+		t.Errorf("empty name error = %v, want ErrEmptyName", err)
+	}
+}
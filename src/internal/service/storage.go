@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -16,15 +17,43 @@ var (
 	// ErrServiceNotFound возвращается, когда услуга с указанным ID не найдена.
 	ErrServiceNotFound      = errors.New("service not found")
 	ErrServiceAlreadyExists = errors.New("service already exists")
+	ErrServiceInUse         = errors.New("service is offered by branches, archive it instead")
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrCategoryExists       = errors.New("category with this name already exists in the parent category")
+	ErrCategoryNotEmpty     = errors.New("category has subcategories or services")
+	ErrInvalidOrder         = errors.New("ids must list every item of the category exactly once")
 )
 
 // ServiceStorage определяет методы для работы с услугами в базе данных.
 type ServiceStorage interface {
 	GetAll() ([]Service, error)
 
-	Create(name string) (*Service, error)
+	GetByID(id uuid.UUID) (*Service, error)
+
+	Create(service *Service) (*Service, error)
+
+	Update(service *Service) (*Service, error)
+
+	SetArchived(id uuid.UUID, archived bool) error
 
 	Delete(id uuid.UUID) error
+
+	ReorderServices(categoryID *uuid.UUID, ids []uuid.UUID) error
+
+	GetCategories() ([]Category, error)
+
+	GetCategory(id uuid.UUID) (*Category, error)
+
+	CreateCategory(category *Category) (*Category, error)
+
+	UpdateCategory(category *Category) (*Category, error)
+
+	DeleteCategory(id uuid.UUID) error
+
+	ReorderCategories(parentID *uuid.UUID, ids []uuid.UUID) error
+
+	// WithTx возвращает storage, выполняющий запросы внутри транзакции tx
+	WithTx(tx *sql.Tx) ServiceStorage
 }
 
 // PostgresServiceStorage реализует ServiceStorage для PostgreSQL.
@@ -37,9 +66,19 @@ func NewPostgresServiceStorage(sqlDB *sql.DB) *PostgresServiceStorage {
 	return &PostgresServiceStorage{Storage: db.NewStorage(sqlDB)}
 }
 
-// GetAll возвращает список всех услуг.
+// WithTx возвращает PostgresServiceStorage, работающий внутри транзакции tx
+func (s *PostgresServiceStorage) WithTx(tx *sql.Tx) ServiceStorage {
+	return &PostgresServiceStorage{Storage: s.Storage.WithTx(tx)}
+}
+
+// GetAll возвращает список всех услуг, включая архивные, с количеством филиалов
 func (s *PostgresServiceStorage) GetAll() ([]Service, error) {
-	rows, err := s.DB.Query(`SELECT id, name FROM services ORDER BY name`)
+	rows, err := s.DB.Query(`
+		SELECT s.id, s.name, s.category_id, s.description, s.icon, s.position, s.archived_at,
+		       (SELECT COUNT(*) FROM branch_services bs WHERE bs.service = s.id)
+		FROM services s
+		ORDER BY s.position, s.name
+	`)
 	if err != nil {
 		return nil, fmt.Errorf("query all services: %w", err)
 	}
@@ -48,7 +87,8 @@ func (s *PostgresServiceStorage) GetAll() ([]Service, error) {
 	var services []Service
 	for rows.Next() {
 		var svc Service
-		if err := rows.Scan(&svc.ID, &svc.Name); err != nil {
+		if err := rows.Scan(&svc.ID, &svc.Name, &svc.CategoryID, &svc.Description, &svc.Icon,
+			&svc.Position, &svc.ArchivedAt, &svc.Branches); err != nil {
 			return nil, fmt.Errorf("scan service: %w", err)
 		}
 		services = append(services, svc)
@@ -59,37 +99,295 @@ func (s *PostgresServiceStorage) GetAll() ([]Service, error) {
 	return services, nil
 }
 
-// Create - используется для создания новой услуги
-func (s *PostgresServiceStorage) Create(name string) (*Service, error) {
-	var service Service
+// GetByID возвращает услугу, включая архивную, с количеством филиалов. Если услуги нет, возвращает nil
+func (s *PostgresServiceStorage) GetByID(id uuid.UUID) (*Service, error) {
+	var svc Service
+	err := s.DB.QueryRow(`
+		SELECT s.id, s.name, s.category_id, s.description, s.icon, s.position, s.archived_at,
+		       (SELECT COUNT(*) FROM branch_services bs WHERE bs.service = s.id)
+		FROM services s
+		WHERE s.id = $1
+	`, id).Scan(&svc.ID, &svc.Name, &svc.CategoryID, &svc.Description, &svc.Icon,
+		&svc.Position, &svc.ArchivedAt, &svc.Branches)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get service %v: %w", id, err)
+	}
+	return &svc, nil
+}
+
+// Create - используется для создания новой услуги. Услуга добавляется в конец категории
+func (s *PostgresServiceStorage) Create(service *Service) (*Service, error) {
+	created := *service
+	err := s.DB.QueryRow(`
+        INSERT INTO services (name, category_id, description, icon, position)
+        VALUES ($1, $2, $3, $4,
+                (SELECT COALESCE(MAX(position), 0) + 1 FROM services WHERE category_id IS NOT DISTINCT FROM $2))
+        RETURNING id, position
+    `, service.Name, service.CategoryID, service.Description, service.Icon).Scan(&created.ID, &created.Position)
+	if err != nil {
+		return nil, fmt.Errorf("create service: %w", serviceError(err))
+	}
+	return &created, nil
+}
+
+// Update меняет название, категорию, описание и иконку услуги.
+// При переносе в другую категорию услуга добавляется в её конец
+func (s *PostgresServiceStorage) Update(service *Service) (*Service, error) {
+	updated := *service
 	err := s.DB.QueryRow(`
-        INSERT INTO services (name) 
-        VALUES ($1) 
-        RETURNING id, name
-    `, name).Scan(&service.ID, &service.Name)
+        UPDATE services
+        SET name = $2, description = $4, icon = $5,
+            position = CASE WHEN category_id IS NOT DISTINCT FROM $3 THEN position
+                            ELSE (SELECT COALESCE(MAX(position), 0) + 1 FROM services WHERE category_id IS NOT DISTINCT FROM $3) END,
+            category_id = $3
+        WHERE id = $1
+        RETURNING position, archived_at
+    `, service.ID, service.Name, service.CategoryID, service.Description, service.Icon).Scan(&updated.Position, &updated.ArchivedAt)
 	if err != nil {
-		var pqErr *pgconn.PgError
-		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
-			return nil, fmt.Errorf("create service: %w", ErrServiceAlreadyExists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("update service %v: %w", service.ID, ErrServiceNotFound)
 		}
-		return nil, fmt.Errorf("create service: %w", err)
+		return nil, fmt.Errorf("update service %v: %w", service.ID, serviceError(err))
 	}
-	return &service, nil
+	return &updated, nil
 }
 
-// // Delete удаляет услугу по идентификатору.
-// // Если услуга не найдена, возвращает ошибку ErrServiceNotFound.
+// SetArchived переносит услугу в архив или возвращает из него
+func (s *PostgresServiceStorage) SetArchived(id uuid.UUID, archived bool) error {
+	result, err := s.DB.Exec(`
+        UPDATE services
+        SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, NOW()) END
+        WHERE id = $1
+    `, id, archived)
+	if err != nil {
+		return fmt.Errorf("archive service %v: %w", id, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected for archive %v: %w", id, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("archive service %v: %w", id, ErrServiceNotFound)
+	}
+	return nil
+}
+
+// Delete удаляет услугу по идентификатору.
+// Если услуга не найдена, возвращает ошибку ErrServiceNotFound,
+// если её оказывают филиалы - ErrServiceInUse
 func (s *PostgresServiceStorage) Delete(id uuid.UUID) error {
-	result, err := s.DB.Exec(`DELETE FROM services WHERE id = $1`, id)
+	return s.InTx(func(tx *sql.Tx) error {
+		// Строка услуги блокируется до конца транзакции: добавление услуги в филиал проверяет
+		// внешний ключ на services и ждёт, поэтому между проверкой и удалением услуга не появится в филиале
+		var inUse bool
+		err := tx.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM branch_services WHERE service = s.id)
+			FROM services s
+			WHERE s.id = $1
+			FOR UPDATE OF s
+		`, id).Scan(&inUse)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("delete service %v: %w", id, ErrServiceNotFound)
+			}
+			return fmt.Errorf("check service %v usage: %w", id, err)
+		}
+		if inUse {
+			return fmt.Errorf("delete service %v: %w", id, ErrServiceInUse)
+		}
+
+		if _, err := tx.Exec(`DELETE FROM services WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("delete service %v: %w", id, serviceError(err))
+		}
+		return nil
+	})
+}
+
+// ReorderServices задаёт порядок услуг категории
+func (s *PostgresServiceStorage) ReorderServices(categoryID *uuid.UUID, ids []uuid.UUID) error {
+	return s.reorder(`services`, `category_id`, categoryID, ids)
+}
+
+// GetCategories возвращает все категории
+func (s *PostgresServiceStorage) GetCategories() ([]Category, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, parent_id, name, description, icon, position
+		FROM service_categories
+		ORDER BY position, name
+	`)
 	if err != nil {
-		return fmt.Errorf("delete service %v: %w", id, err)
+		return nil, fmt.Errorf("query service categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Name, &c.Description, &c.Icon, &c.Position); err != nil {
+			return nil, fmt.Errorf("scan service category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return categories, nil
+}
+
+// GetCategory возвращает категорию. Если категории нет, возвращает nil
+func (s *PostgresServiceStorage) GetCategory(id uuid.UUID) (*Category, error) {
+	var c Category
+	err := s.DB.QueryRow(`
+		SELECT id, parent_id, name, description, icon, position
+		FROM service_categories
+		WHERE id = $1
+	`, id).Scan(&c.ID, &c.ParentID, &c.Name, &c.Description, &c.Icon, &c.Position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get service category %v: %w", id, err)
+	}
+	return &c, nil
+}
+
+// CreateCategory создаёт категорию в конце родительской категории
+func (s *PostgresServiceStorage) CreateCategory(category *Category) (*Category, error) {
+	created := *category
+	err := s.DB.QueryRow(`
+        INSERT INTO service_categories (parent_id, name, description, icon, position)
+        VALUES ($1, $2, $3, $4,
+                (SELECT COALESCE(MAX(position), 0) + 1 FROM service_categories WHERE parent_id IS NOT DISTINCT FROM $1))
+        RETURNING id, position
+    `, category.ParentID, category.Name, category.Description, category.Icon).Scan(&created.ID, &created.Position)
+	if err != nil {
+		return nil, fmt.Errorf("create service category: %w", categoryError(err))
+	}
+	return &created, nil
+}
+
+// UpdateCategory меняет название, родителя, описание и иконку категории.
+// Проверка на циклы выполняется в ServiceManager
+func (s *PostgresServiceStorage) UpdateCategory(category *Category) (*Category, error) {
+	updated := *category
+	err := s.DB.QueryRow(`
+        UPDATE service_categories
+        SET name = $3, description = $4, icon = $5,
+            position = CASE WHEN parent_id IS NOT DISTINCT FROM $2 THEN position
+                            ELSE (SELECT COALESCE(MAX(position), 0) + 1 FROM service_categories WHERE parent_id IS NOT DISTINCT FROM $2) END,
+            parent_id = $2
+        WHERE id = $1
+        RETURNING position
+    `, category.ID, category.ParentID, category.Name, category.Description, category.Icon).Scan(&updated.Position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("update service category %v: %w", category.ID, ErrCategoryNotFound)
+		}
+		return nil, fmt.Errorf("update service category %v: %w", category.ID, categoryError(err))
+	}
+	return &updated, nil
+}
+
+// DeleteCategory удаляет пустую категорию.
+// Если в категории есть подкатегории или услуги (в том числе архивные), возвращает ErrCategoryNotEmpty
+func (s *PostgresServiceStorage) DeleteCategory(id uuid.UUID) error {
+	result, err := s.DB.Exec(`DELETE FROM service_categories WHERE id = $1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("delete service category %v: %w", id, ErrCategoryNotEmpty)
+		}
+		return fmt.Errorf("delete service category %v: %w", id, err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("get rows affected for delete %v: %w", id, err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("delete service %v: %w", id, ErrServiceNotFound)
+		return fmt.Errorf("delete service category %v: %w", id, ErrCategoryNotFound)
 	}
 	return nil
 }
+
+// ReorderCategories задаёт порядок подкатегорий категории
+func (s *PostgresServiceStorage) ReorderCategories(parentID *uuid.UUID, ids []uuid.UUID) error {
+	return s.reorder(`service_categories`, `parent_id`, parentID, ids)
+}
+
+// Новый порядок элементов родителя. ids должен совпадать с набором элементов родителя,
+// иначе возвращается ErrInvalidOrder. Строки блокируются, чтобы параллельное добавление не потерялось
+func (s *PostgresServiceStorage) reorder(table, parentColumn string, parentID *uuid.UUID, ids []uuid.UUID) error {
+	return s.InTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT id FROM `+table+` WHERE `+parentColumn+` IS NOT DISTINCT FROM $1 FOR UPDATE`, parentID)
+		if err != nil {
+			return fmt.Errorf("query %s: %w", table, err)
+		}
+		var current []uuid.UUID
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan %s id: %w", table, err)
+			}
+			current = append(current, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows iteration: %w", err)
+		}
+
+		if len(current) != len(ids) {
+			return ErrInvalidOrder
+		}
+		for i, id := range ids {
+			if !slices.Contains(current, id) || slices.Contains(ids[:i], id) {
+				return ErrInvalidOrder
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE `+table+` t SET position = o.position
+			FROM unnest($1::uuid[]) WITH ORDINALITY AS o(id, position)
+			WHERE t.id = o.id
+		`, ids)
+		if err != nil {
+			return fmt.Errorf("reorder %s: %w", table, err)
+		}
+
+		return nil
+	})
+}
+
+// Ошибки ограничений таблицы services
+func serviceError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return ErrServiceAlreadyExists
+		case "23503":
+			if pgErr.ConstraintName == "services_category_id_fkey" {
+				return ErrCategoryNotFound
+			}
+			return ErrServiceInUse
+		}
+	}
+	return err
+}
+
+// Ошибки ограничений таблицы service_categories
+func categoryError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return ErrCategoryExists
+		case "23503":
+			return ErrCategoryNotFound
+		}
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func newMockStorage(t *testing.T) (*PostgresServiceStorage, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return NewPostgresServiceStorage(sqlDB), mock
+}
+
+// Проверка использования и удаление выполняются в одной транзакции под блокировкой услуги
+func TestDeleteService(t *testing.T) {
+	lockQuery := `(?s)SELECT EXISTS\(SELECT 1 FROM branch_services WHERE service = s\.id\)\s+FROM services s\s+WHERE s\.id = \$1\s+FOR UPDATE OF s`
+
+	tests := []struct {
+		name    string
+		found   bool
+		inUse   bool
+		wantErr error
+	}{
+		{"deleted", true, false, nil},
+		{"in use", true, true, ErrServiceInUse},
+		{"not found", false, false, ErrServiceNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, mock := newMockStorage(t)
+			id := uuid.New()
+
+			mock.ExpectBegin()
+			rows := sqlmock.NewRows([]string{"exists"})
+			if tt.found {
+				rows.AddRow(tt.inUse)
+			}
+			mock.ExpectQuery(lockQuery).WithArgs(id).WillReturnRows(rows)
+			if tt.wantErr == nil {
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM services WHERE id = $1`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			err := storage.Delete(id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Delete error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
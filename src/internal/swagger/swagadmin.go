@@ -1,6 +1,9 @@
 package swagger
 
-import "src/internal/admin"
+import (
+	"src/internal/admin"
+	"src/internal/auth"
+)
 
 // getAllPartnerRequests возвращает список всех заявок на регистрацию организаций
 // @Summary      Получить все заявки партнёров
@@ -50,6 +53,21 @@ func getPendingPartnerRequests() {
 	var _ = admin.PartnerRequest{}
 }
 
+// getNeedsInfoPartnerRequests возвращает список заявок в статусе "needs_info"
+// @Summary      Получить заявки, ожидающие сведений
+// @Description  Возвращает список заявок со статусом "needs_info": администратор запросил у заявителя недостающие сведения.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   admin.PartnerRequest  "Список заявок (если нет, возвращается null)"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      500  {string}  string  "Failed to get requests"
+// @Router       /admin/partner-requests/needs-info [get]
+func getNeedsInfoPartnerRequests() {
+	var _ = admin.PartnerRequest{}
+}
+
 // getApprovedPartnerRequests возвращает список принятых заявок
 // @Summary      Получить принятые заявки партнёров
 // @Description  Возвращает список заявок со статусом "approved" (принятые). Доступно только для администраторов.
@@ -82,27 +100,80 @@ func getRejectedPartnerRequests() {
 	var _ = admin.PartnerRequest{}
 }
 
-// takeRequestToWork переводит заявку в статус "в работе"
+// takeRequestToWork назначает заявку текущему администратору
 // @Summary      Взять заявку в работу
-// @Description  Администратор переводит заявку из статуса "new" в "pending". Требуется указать INN организации.
+// @Description  Назначает незакрытую заявку текущему администратору. Новая заявка переходит из статуса "new" в "pending". Заявку, назначенную другому администратору, взять нельзя - её можно передать через POST /admin/partner-requests/assign.
 // @Tags         admin
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      admin.ApprovePartnerRequest  true  "INN организации"
-// @Success      200      {object}  map[string]string  "message: Request taken to work, inn: ..., status: pending"
-// @Failure      400      {string}  string  "Invalid request body | validation error | request with inn ... not found | request cannot be taken to work: current status is ..."
+// @Param        request  body      admin.ApprovePartnerRequest  true  "ID заявки (override не используется)"
+// @Success      200      {object}  map[string]string  "message: Request taken to work, id: ..., assigned_to: ..."
+// @Failure      400      {string}  string  "Invalid request body | validation error | request with id ... not found | request cannot be taken to work: current status is ..."
 // @Failure      401      {string}  string  "Unauthorized"
 // @Failure      403      {string}  string  "Forbidden: admin access required"
-// @Failure      500      {string}  string  "Internal server error"
+// @Failure      409      {string}  string  "partner request is assigned to another admin"
 // @Router       /admin/partner-requests/take [post]
 func takeRequestToWork() {
 	var _ = admin.ApprovePartnerRequest{}
 }
 
+// assignPartnerRequest передаёт заявку другому администратору
+// @Summary      Передать заявку
+// @Description  Назначает заявку указанному администратору. Передать заявку может администратор, которому она назначена, любой администратор, если заявка никому не назначена, или другой администратор с override: true и причиной override_reason, она записывается в журнал действий. Новая заявка при назначении переходит в "pending".
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      admin.AssignRequest  true  "ID заявки и email администратора"
+// @Success      200      {object}  map[string]string  "message: Request assigned, id: ..., assigned_to: ..."
+// @Failure      400      {string}  string  "Invalid request body | validation error | assignee is not an admin"
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Forbidden: admin access required | only the assigned admin can process this partner request, take it to work or set override with override_reason"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request is already approved or rejected | partner request is assigned to another admin"
+// @Router       /admin/partner-requests/assign [post]
+func assignPartnerRequest() {
+	var _ = admin.AssignRequest{}
+}
+
+// releasePartnerRequest снимает назначение заявки
+// @Summary      Вернуть заявку в очередь
+// @Description  Снимает назначение заявки, статус не меняется. Доступно администратору, которому назначена заявка, или другому администратору с override: true и причиной override_reason, она записывается в журнал действий.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      admin.ReleaseRequest  true  "ID заявки"
+// @Success      200      {object}  map[string]string  "message: Request released, id: ..."
+// @Failure      400      {string}  string  "Invalid request body | validation error"
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Forbidden: admin access required | only the assigned admin can process this partner request, take it to work or set override with override_reason"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request is already approved or rejected | partner request is not assigned | partner request is assigned to another admin"
+// @Router       /admin/partner-requests/release [post]
+func releasePartnerRequest() {
+	var _ = admin.ReleaseRequest{}
+}
+
+// getMyPartnerRequests возвращает заявки, назначенные текущему администратору
+// @Summary      Мои заявки
+// @Description  Возвращает незакрытые заявки (new, pending, needs_info), назначенные текущему администратору. Для заявок, ожидающих решения, заполняется sla.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   admin.PartnerRequest  "Список заявок (если нет, возвращается null)"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      500  {string}  string  "Failed to get requests"
+// @Router       /admin/partner-requests/my [get]
+func getMyPartnerRequests() {
+	var _ = admin.PartnerRequest{}
+}
+
 // approvePartnerRequest одобряет заявку партнёра
 // @Summary      Одобрить заявку партнёра
-// @Description  Администратор одобряет заявку (статус "pending" -> "approved"). Создаётся компания и связывается с пользователем.
+// @Description  Администратор одобряет заявку (статус "pending" -> "approved"). Создаётся компания и связывается с пользователем. Одобрить может только администратор, которому назначена заявка, либо другой администратор с override: true и причиной override_reason, она записывается в журнал действий.
 // @Tags         admin
 // @Accept       json
 // @Produce      json
@@ -111,8 +182,9 @@ func takeRequestToWork() {
 // @Success      200      {object}  map[string]string  "message: Partner request approved"
 // @Failure      400      {string}  string  "Invalid request body | validation error | request with inn ... not found | request already processed | failed to create company | failed to create partner user record | failed to update request status"
 // @Failure      401      {string}  string  "Unauthorized"
-// @Failure      403      {string}  string  "Forbidden: admin access required"
-// @Failure      500      {string}  string  "Internal server error"
+// @Failure      403      {string}  string  "Forbidden: admin access required | only the assigned admin can process this partner request, take it to work or set override with override_reason"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request was changed by another admin, reload it"
 // @Router       /admin/partner-requests/approve [post]
 func approvePartnerRequest() {
 	var _ = admin.ApprovePartnerRequest{}
@@ -120,20 +192,79 @@ func approvePartnerRequest() {
 
 // rejectPartnerRequest отклоняет заявку партнёра
 // @Summary      Отклонить заявку партнёра
-// @Description  Администратор отклоняет заявку (статус "pending" -> "rejected"). Требуется указать INN организации.
+// @Description  Администратор отклоняет заявку (статус "pending" или "needs_info" -> "rejected") с указанием причины. Причина отправляется заявителю на почту. Отклонить может только администратор, которому назначена заявка, либо другой администратор с override: true и причиной override_reason, она записывается в журнал действий.
 // @Tags         admin
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      admin.ApprovePartnerRequest  true  "INN организации"
+// @Param        request  body      admin.RejectPartnerRequest  true  "ID заявки и причина отклонения"
 // @Success      200      {object}  map[string]string  "message: Request rejected, inn: ..., status: rejected"
-// @Failure      400      {string}  string  "Invalid request body | validation error | request with inn ... not found | request cannot be rejected: current status is ..."
+// @Failure      400      {string}  string  "Invalid request body | validation error | request with id ... not found | request cannot be rejected: current status is ..."
 // @Failure      401      {string}  string  "Unauthorized"
-// @Failure      403      {string}  string  "Forbidden: admin access required"
-// @Failure      500      {string}  string  "Internal server error"
+// @Failure      403      {string}  string  "Forbidden: admin access required | only the assigned admin can process this partner request, take it to work or set override with override_reason"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request was changed by another admin, reload it"
 // @Router       /admin/partner-requests/reject [post]
 func rejectPartnerRequest() {
-	var _ = admin.ApprovePartnerRequest{}
+	var _ = admin.RejectPartnerRequest{}
+}
+
+// requestPartnerInfo запрашивает у заявителя недостающие сведения
+// @Summary      Запросить сведения у заявителя
+// @Description  Переводит заявку из "pending" в "needs_info". Сообщение добавляется в переписку по заявке и отправляется заявителю на почту. Заявитель исправляет заявку и отправляет её повторно через POST /partner/request. Доступно администратору, которому назначена заявка, либо другому администратору с override: true и причиной override_reason, она записывается в журнал действий.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      admin.RequestInfoRequest  true  "ID заявки и список недостающих сведений"
+// @Success      200      {object}  map[string]string  "message: Additional information requested, id: ..., status: needs_info"
+// @Failure      400      {string}  string  "Invalid request body | validation error | request with id ... not found | cannot request information: current status is ..."
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Forbidden: admin access required | only the assigned admin can process this partner request, take it to work or set override with override_reason"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request was changed by another admin, reload it"
+// @Router       /admin/partner-requests/request-info [post]
+func requestPartnerInfo() {
+	var _ = admin.RequestInfoRequest{}
+}
+
+// getPartnerRequestComments возвращает переписку по заявке
+// @Summary      Переписка по заявке
+// @Description  Возвращает сообщения администраторов и заявителя по заявке, начиная со старых.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id   path      string  true  "UUID заявки"  format(uuid)
+// @Success      200  {array}   admin.Comment
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      404  {string}  string  "partner request not found"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/partner-requests/{id}/comments [get]
+func getPartnerRequestComments() {
+	var _ = admin.Comment{}
+}
+
+// addPartnerRequestComment добавляет сообщение администратора в переписку по заявке
+// @Summary      Написать заявителю
+// @Description  Добавляет сообщение администратора в переписку по заявке. По одобренной или отклонённой заявке писать нельзя.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path      string               true  "UUID заявки"  format(uuid)
+// @Param        request  body      admin.CommentRequest true  "Текст сообщения"
+// @Success      201      {object}  admin.Comment
+// @Failure      400      {string}  string  "ID must be UUID | Invalid request body"
+// @Failure      401      {string}  string  "Unauthorized"
+// @Failure      403      {string}  string  "Forbidden: admin access required"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request is already approved or rejected"
+// @Failure      500      {string}  string  "Internal server error"
+// @Router       /admin/partner-requests/{id}/comments [post]
+func addPartnerRequestComment() {
+	var _ = admin.CommentRequest{}
 }
 
 type CreateAdminRequest struct {
@@ -167,6 +298,133 @@ func CreateAdmin() {
 	var _ = CreateAdminResponse{}
 }
 
+type resUnlockAccount struct {
+	Message string `json:"message" example:"Account unlocked"`
+	Email   string `json:"email" example:"user@example.com"`
+}
+
+// UnlockAccount снимает блокировку входа
+// @Summary      Разблокировать вход
+// @Description  Снимает блокировку входа после неудачных попыток и сбрасывает счётчик аккаунта.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        email path string true "Email пользователя"
+// @Success      200 {object} resUnlockAccount "Блокировка снята"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:users"
+// @Failure      404 {string} string "User not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/users/{email}/unlock [post]
+func UnlockAccount() {
+	var _ = resUnlockAccount{}
+}
+
+type resSuspendAccount struct {
+	Message string `json:"message" example:"Account suspended"`
+	Email   string `json:"email" example:"user@example.com"`
+}
+
+// SuspendAccount блокирует пользователя
+// @Summary      Заблокировать пользователя
+// @Description  Блокирует пользователя: завершает все его сессии, отзывает выданные access токены, запрещает вход и обновление токенов. Причина записывается и отправляется пользователю на почту.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        email path string true "Email пользователя"
+// @Param        input body auth.SuspendRequest true "Причина блокировки"
+// @Success      200 {object} resSuspendAccount "Пользователь заблокирован"
+// @Failure      400 {string} string "you cannot suspend your own account"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:users"
+// @Failure      404 {string} string "User not found"
+// @Failure      409 {string} string "account is already suspended"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/users/{email}/suspend [post]
+func SuspendAccount() {
+	var _ = resSuspendAccount{}
+	var _ = auth.SuspendRequest{}
+}
+
+// ReinstateAccount снимает блокировку пользователя
+// @Summary      Разблокировать пользователя
+// @Description  Снимает блокировку, наложенную администратором. Комментарий (необязательный) отправляется пользователю на почту.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        email path string true "Email пользователя"
+// @Param        input body auth.ReinstateRequest true "Комментарий"
+// @Success      200 {object} resSuspendAccount "Блокировка снята"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:users"
+// @Failure      404 {string} string "User not found"
+// @Failure      409 {string} string "account is not suspended"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/users/{email}/reinstate [post]
+func ReinstateAccount() {
+	var _ = auth.ReinstateRequest{}
+}
+
+// SuspendCompany приостанавливает работу компании
+// @Summary      Приостановить компанию
+// @Description  Приостанавливает работу компании: её филиалы не показываются в поиске, новые заказы и запросы по API ключам не принимаются. Уже оформленные заказы остаются в силе и возвращаются в ответе. Причина записывается и отправляется сотрудникам компании на почту.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        inn path string true "ИНН компании"
+// @Param        input body admin.SuspendRequest true "Причина приостановки"
+// @Success      200 {object} admin.SuspendCompanyResponse "Компания приостановлена"
+// @Failure      400 {string} string "Invalid request body"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:partners"
+// @Failure      404 {string} string "Company not found"
+// @Failure      409 {string} string "company is already suspended"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/companies/{inn}/suspend [post]
+func SuspendCompany() {
+	var _ = admin.SuspendCompanyResponse{}
+}
+
+// ReinstateCompany возобновляет работу компании
+// @Summary      Возобновить работу компании
+// @Description  Снимает приостановку компании. Комментарий (необязательный) отправляется сотрудникам компании на почту.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        inn path string true "ИНН компании"
+// @Param        input body admin.ReinstateRequest true "Комментарий"
+// @Success      200 {object} admin.Company "Работа компании возобновлена"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:partners"
+// @Failure      404 {string} string "Company not found"
+// @Failure      409 {string} string "company is not suspended"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/companies/{inn}/reinstate [post]
+func ReinstateCompany() {
+	var _ = admin.ReinstateRequest{}
+}
+
+// GetUpcomingOrders возвращает предстоящие заказы компании
+// @Summary      Предстоящие заказы компании
+// @Description  Возвращает заказы компании, которые ещё не начались (кроме отклонённых), по времени начала. Нужен для обработки заказов приостановленной компании.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        inn path string true "ИНН компании"
+// @Success      200 {array} admin.UpcomingOrder
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:partners"
+// @Failure      404 {string} string "Company not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/companies/{inn}/orders/upcoming [get]
+func GetUpcomingOrders() {
+	var _ = admin.UpcomingOrder{}
+}
+
 // GetById возвращает заявку на регистрацию организации по её ID
 // @Summary      Получить заявку по ID
 // @Description  Возвращает заявку на регистрацию организации по указанному UUID. Доступно только для администраторов.
@@ -185,3 +443,39 @@ func CreateAdmin() {
 func GetById() {
 	var _ = admin.PartnerRequest{}
 }
+
+type resAdminMFAPolicy struct {
+	Required bool `json:"required" example:"true"`
+}
+
+// GetAdminMFAPolicy возвращает обязательность 2FA для администраторов
+// @Summary      Обязательная 2FA администраторов
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} resAdminMFAPolicy
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:users"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/security/2fa [get]
+func GetAdminMFAPolicy() {
+	var _ = resAdminMFAPolicy{}
+}
+
+// SetAdminMFAPolicy включает или отключает обязательную 2FA для администраторов
+// @Summary      Изменить обязательность 2FA администраторов
+// @Description  Администраторы без 2FA подключат её при следующем входе (ответ mfa_setup_required).
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.MFAPolicyRequest true "Обязательность 2FA"
+// @Success      200 {object} resAdminMFAPolicy
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:users"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/security/2fa [put]
+func SetAdminMFAPolicy() {
+	var _ = auth.MFAPolicyRequest{}
+}
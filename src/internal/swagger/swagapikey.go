@@ -0,0 +1,50 @@
+package swagger
+
+import "src/internal/apikey"
+
+// createAPIKey создаёт API ключ компании
+// @Summary      Создать API ключ
+// @Description  Создаёт ключ для интеграций (кассы, CRM). Ключ передаётся в заголовке X-API-Key вместо Bearer токена и возвращается только один раз, хранится только его хеш.
+// @Description  Права: orders:read - просмотр заказов, orders:write - изменение статусов, catalog:manage - филиалы, услуги, детали и цены. Не больше 20 ключей на компанию.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body apikey.CreateAPIKeyRequest true "Название и права ключа"
+// @Success      201  {object}  apikey.CreateAPIKeyResponse
+// @Failure      400  {string}  string  "Invalid request body | validation error | unknown api key scope | at least one api key scope is required"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Failure      409  {string}  string  "api key limit reached, revoke unused keys"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /company/api-keys [post]
+func createAPIKey() {
+	var _ = apikey.CreateAPIKeyRequest{}
+}
+
+// getAPIKeys возвращает API ключи компании
+// @Summary      Получить API ключи компании
+// @Description  Ключи без секрета: начало ключа, права, автор, время и IP адрес последнего использования.
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   apikey.APIKey
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Router       /company/api-keys [get]
+func getAPIKeys() {
+	var _ = apikey.APIKey{}
+}
+
+// revokeAPIKey отзывает API ключ компании
+// @Summary      Отозвать API ключ
+// @Description  Запросы с ключом отклоняются сразу после отзыва.
+// @Tags         company
+// @Security     BearerAuth
+// @Param        id path string true "UUID ключа" format(uuid)
+// @Success      204  "No Content"
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Failure      404  {string}  string  "API key not found"
+// @Router       /company/api-keys/{id} [delete]
+func revokeAPIKey() {}
@@ -0,0 +1,51 @@
+package swagger
+
+import "src/internal/audit"
+
+// getAuditLog возвращает записи журнала действий администраторов
+// @Summary      Журнал действий администраторов
+// @Description  Возвращает записи журнала (кто, что и над чем сделал, состояние до и после, IP), начиная с новых. Журнал только пополняется.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        actor        query string false "Email администратора"
+// @Param        action       query string false "Действие, например partner_request.approve"
+// @Param        target_type  query string false "Тип объекта: partner_request | admin | user | setting | service | category"
+// @Param        target_id    query string false "Идентификатор объекта"
+// @Param        from         query string false "Начало периода (RFC 3339), включительно" format(date-time)
+// @Param        to           query string false "Конец периода (RFC 3339), не включительно" format(date-time)
+// @Param        limit        query int    false "Записей на странице, 1-500 (по умолчанию 50)"
+// @Param        offset       query int    false "Смещение"
+// @Success      200  {array}   audit.Entry
+// @Failure      400  {string}  string  "from must be in RFC 3339 format"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/audit [get]
+func getAuditLog() {
+	var _ = audit.Entry{}
+}
+
+// exportAuditLog выгружает журнал действий файлом
+// @Summary      Выгрузка журнала действий
+// @Description  Выгружает записи журнала по тем же фильтрам, что и GET /admin/audit, без постраничного вывода и ограничения количества записей: файл передаётся по мере чтения журнала. CSV содержит снимки до и после в виде JSON, значения, похожие на формулы, экранируются апострофом.
+// @Tags         admin
+// @Produce      text/csv
+// @Produce      json
+// @Security     BearerAuth
+// @Param        format       query string false "csv (по умолчанию) | json"
+// @Param        actor        query string false "Email администратора"
+// @Param        action       query string false "Действие"
+// @Param        target_type  query string false "Тип объекта"
+// @Param        target_id    query string false "Идентификатор объекта"
+// @Param        from         query string false "Начало периода (RFC 3339), включительно" format(date-time)
+// @Param        to           query string false "Конец периода (RFC 3339), не включительно" format(date-time)
+// @Success      200  {file}    file  "Файл audit-<время>.csv или .json"
+// @Failure      400  {string}  string  "format must be csv or json"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /admin/audit/export [get]
+func exportAuditLog() {
+	var _ = audit.Entry{}
+}
@@ -2,6 +2,7 @@ package swagger
 
 import (
 	"src/internal/auth"
+	"src/internal/jwtkeys"
 )
 
 type RegisterResponse struct {
@@ -53,7 +54,9 @@ type TokenResponse struct {
 
 // Login обрабатывает POST /auth/login, выполняет вход в аккаунт
 // @Summary      Вход пользователя
-// @Description  Аутентифицирует пользователя по email и паролю, возвращает access и refresh токены
+// @Description  Аутентифицирует пользователя по email и паролю, возвращает access и refresh токены.
+// @Description  Если у пользователя подключена 2FA, возвращается 403 с error=mfa_required и mfa_token для POST /auth/login/2fa.
+// @Description  Если 2FA обязательна, но не подключена - error=mfa_setup_required: секрет выдаёт POST /auth/login/2fa/setup.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -61,11 +64,53 @@ type TokenResponse struct {
 // @Success      200  {object}  TokenResponse  "Токены доступа и обновления"
 // @Failure      400  {string}  string  "Invalid request body or missing email/password"
 // @Failure      401  {string}  string  "Invalid credentials"
+// @Failure      403  {object}  auth.MFAChallengeResponse  "Нужен второй фактор"
 // @Failure      405  {string}  string  "Method not allowed"
+// @Failure      429  {string}  string  "account temporarily locked due to failed login attempts | too many failed login attempts, try again later"
 // @Failure      500  {string}  string  "Internal server error"
 // @Router       /auth/login [post]
 func postAuthLogin() {
 	var _ = auth.LoginRequest{}
+	var _ = auth.MFAChallengeResponse{}
+}
+
+// VerifyMFALogin - второй шаг входа
+// @Summary      Вход: код 2FA
+// @Description  Обменивает mfa_token из ответа /auth/login и код из приложения-аутентификатора на токены.
+// @Description  Вместо кода из приложения можно ввести одноразовый код восстановления (кроме подключения 2FA при входе).
+// @Description  Если 2FA подключалась при этом входе, в ответе есть recovery_codes - они показываются один раз.
+// @Description  На один mfa_token даётся 5 попыток, неверные коды учитываются в блокировке входа.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body auth.MFALoginRequest true "Токен второго шага и код"
+// @Success      200  {object}  auth.TokenResponse  "Токены доступа и обновления"
+// @Failure      400  {string}  string  "Invalid request body | validation error"
+// @Failure      401  {string}  string  "invalid or expired mfa token | invalid two-factor code"
+// @Failure      409  {string}  string  "two-factor setup not started"
+// @Failure      429  {string}  string  "too many attempts | account temporarily locked due to failed login attempts"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /auth/login/2fa [post]
+func postAuthLoginMFA() {
+	var _ = auth.MFALoginRequest{}
+}
+
+// SetupMFALogin - подключение обязательной 2FA при входе
+// @Summary      Вход: подключение 2FA
+// @Description  Для ответа mfa_setup_required: выдаёт секрет и otpauth:// адрес для QR кода.
+// @Description  Подключение подтверждается кодом из приложения через POST /auth/login/2fa.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body auth.MFALoginSetupRequest true "Токен второго шага"
+// @Success      200  {object}  auth.MFASetupResponse  "Секрет для приложения-аутентификатора"
+// @Failure      400  {string}  string  "Invalid request body | validation error"
+// @Failure      401  {string}  string  "invalid or expired mfa token"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /auth/login/2fa/setup [post]
+func postAuthLoginMFASetup() {
+	var _ = auth.MFALoginSetupRequest{}
+	var _ = auth.MFASetupResponse{}
 }
 
 type LogoutRequest struct {
@@ -78,7 +123,7 @@ type LogoutResponse struct {
 
 // postAuthLogout Post /auth/logout
 // @Summary      Выход пользователя
-// @Description  Инвалидирует refresh token, завершая сессию пользователя.
+// @Description  Инвалидирует refresh token и выданные вместе с ним access токены, завершая сессию пользователя.
 // @Tags 		 auth
 // @Accept       json
 // @Produce      json
@@ -157,6 +202,7 @@ type resSetPassword struct {
 // SetPassword устанавливает новый пароль после подтверждения кода
 // @Summary      Установка нового пароля
 // @Description  Устанавливает новый пароль для пользователя после успешного подтверждения кода.
+// @Description  Все сессии пользователя завершаются, выданные access токены отзываются.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -168,3 +214,289 @@ type resSetPassword struct {
 func SetPassword() {
 	var _ = auth.SetNewPasswordRequest{}
 }
+
+type resLogoutOtherSessions struct {
+	Message string `json:"message" example:"Logged out from all other sessions"`
+}
+
+// GetSessions возвращает активные сессии пользователя
+// @Summary      Список сессий
+// @Description  Возвращает активные сессии (устройства) пользователя. Текущая сессия отмечена current: true.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {array}  auth.Session "Активные сессии"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/sessions [get]
+func getAuthSessions() {
+	var _ = auth.Session{}
+}
+
+// DeleteSession завершает сессию на другом устройстве
+// @Summary      Завершение сессии
+// @Description  Отзывает refresh токены сессии и выданные вместе с ними access токены.
+// @Tags         auth
+// @Security     BearerAuth
+// @Param        id path string true "ID сессии"
+// @Success      204 "Сессия завершена"
+// @Failure      400 {string} string "ID must be UUID"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      404 {string} string "Session not found"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/sessions/{id} [delete]
+func deleteAuthSession() {}
+
+// LogoutOtherSessions завершает все сессии, кроме текущей
+// @Summary      Выход на других устройствах
+// @Description  Отзывает refresh и access токены всех сессий пользователя, кроме текущей.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} resLogoutOtherSessions "Сессии завершены"
+// @Failure      401 {string} string "Unauthorized | Token is outdated, refresh it"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/sessions [delete]
+func deleteAuthSessions() {
+	var _ = resLogoutOtherSessions{}
+}
+
+// GetSecurityEvents возвращает журнал входов пользователя
+// @Summary      Журнал событий безопасности
+// @Description  Возвращает последние успешные и неудачные входы, блокировки и разблокировки аккаунта.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit query int false "Количество событий (1-200, по умолчанию 50)"
+// @Success      200 {array}  auth.SecurityEvent "События, начиная с новых"
+// @Failure      400 {string} string "limit must be between 1 and 200"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/security-events [get]
+func getAuthSecurityEvents() {
+	var _ = auth.SecurityEvent{}
+}
+
+// GetMFAStatus возвращает состояние 2FA
+// @Summary      Состояние 2FA
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} auth.MFAStatus
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/2fa [get]
+func getAuthMFA() {
+	var _ = auth.MFAStatus{}
+}
+
+// SetupMFA начинает подключение 2FA
+// @Summary      Начать подключение 2FA
+// @Description  Создаёт новый секрет. Клиент показывает otpauth_url в виде QR кода (или секрет для ручного ввода),
+// @Description  после чего подключение подтверждается первым кодом через POST /auth/2fa/enable.
+// @Tags         auth
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} auth.MFASetupResponse
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      409 {string} string "two-factor authentication already enabled"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/2fa/setup [post]
+func postAuthMFASetup() {
+	var _ = auth.MFASetupResponse{}
+}
+
+// EnableMFA подтверждает подключение 2FA
+// @Summary      Подключить 2FA
+// @Description  Проверяет код из приложения и включает 2FA. Возвращает 10 одноразовых кодов восстановления - они показываются один раз.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.MFACodeRequest true "Код из приложения"
+// @Success      200 {object} auth.RecoveryCodesResponse
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      401 {string} string "Unauthorized | invalid two-factor code"
+// @Failure      409 {string} string "two-factor setup not started | two-factor authentication already enabled"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/2fa/enable [post]
+func postAuthMFAEnable() {
+	var _ = auth.MFACodeRequest{}
+	var _ = auth.RecoveryCodesResponse{}
+}
+
+// DisableMFA отключает 2FA
+// @Summary      Отключить 2FA
+// @Description  Нужен код из приложения или код восстановления. Если 2FA обязательна для пользователя, отключить её нельзя.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.MFACodeRequest true "Код из приложения или код восстановления"
+// @Success      200 {object} map[string]string
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      401 {string} string "Unauthorized | invalid two-factor code"
+// @Failure      403 {string} string "two-factor authentication is required for your account"
+// @Failure      409 {string} string "two-factor authentication is not enabled"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/2fa/disable [post]
+func postAuthMFADisable() {}
+
+// RegenerateRecoveryCodes выдаёт новые коды восстановления
+// @Summary      Новые коды восстановления
+// @Description  Заменяет все коды восстановления новыми. Нужен код из приложения.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.MFACodeRequest true "Код из приложения"
+// @Success      200 {object} auth.RecoveryCodesResponse
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      401 {string} string "Unauthorized | invalid two-factor code"
+// @Failure      409 {string} string "two-factor authentication is not enabled"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/2fa/recovery-codes [post]
+func postAuthMFARecoveryCodes() {}
+
+// ChangePassword меняет пароль
+// @Summary      Смена пароля
+// @Description  Меняет пароль по текущему. Остальные сессии пользователя завершаются, текущая сохраняется.
+// @Description  Неверный текущий пароль учитывается в защите входа от перебора.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.ChangePasswordRequest true "Текущий и новый пароль"
+// @Success      200 {object} map[string]string
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      401 {string} string "Unauthorized | invalid password"
+// @Failure      429 {string} string "account temporarily locked due to failed login attempts | too many failed login attempts, try again later"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/change-password [post]
+func postAuthChangePassword() {
+	var _ = auth.ChangePasswordRequest{}
+}
+
+// ChangeEmail начинает смену адреса почты
+// @Summary      Смена адреса почты
+// @Description  Проверяет пароль и отправляет код подтверждения на новый адрес. Адрес меняется после POST /auth/change-email/confirm.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.ChangeEmailRequest true "Новый адрес и пароль"
+// @Success      200 {object} map[string]string
+// @Failure      400 {string} string "Invalid request body | validation error | new email must differ from the current one"
+// @Failure      401 {string} string "Unauthorized | invalid password"
+// @Failure      409 {string} string "user already exists"
+// @Failure      429 {string} string "Too many requests"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/change-email [post]
+func postAuthChangeEmail() {
+	var _ = auth.ChangeEmailRequest{}
+}
+
+// ConfirmEmailChange подтверждает новый адрес почты
+// @Summary      Подтверждение нового адреса почты
+// @Description  Проверяет код из письма и переносит аккаунт на новый адрес вместе с компанией, заказами и настройками 2FA.
+// @Description  Все сессии и access токены старого адреса отзываются, в ответе - новая пара токенов.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.ConfirmEmailChangeRequest true "Код из письма"
+// @Success      200 {object} auth.TokenResponse
+// @Failure      400 {string} string "Invalid request body | validation error | invalid verification code"
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      404 {string} string "email change not requested or expired"
+// @Failure      409 {string} string "user already exists"
+// @Failure      429 {string} string "too many attempts, request a new code"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/change-email/confirm [post]
+func postAuthChangeEmailConfirm() {
+	var _ = auth.ConfirmEmailChangeRequest{}
+}
+
+// DeleteAccount удаляет аккаунт
+// @Summary      Удаление аккаунта
+// @Description  Удаляет аккаунт и персональные данные. Нужен пароль, при подключённой 2FA - ещё код из приложения или код восстановления.
+// @Description  Заказы остаются в истории компаний без связи с пользователем, все токены отзываются.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body auth.DeleteAccountRequest true "Пароль и код 2FA"
+// @Success      200 {object} map[string]string
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      401 {string} string "Unauthorized | invalid password | invalid two-factor code"
+// @Failure      409 {string} string "transfer company ownership before deleting the account"
+// @Failure      429 {string} string "account temporarily locked due to failed login attempts | too many failed login attempts, try again later"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/account [delete]
+func deleteAuthAccount() {
+	var _ = auth.DeleteAccountRequest{}
+}
+
+// getJWKS отдаёт открытые ключи проверки токенов
+// @Summary      Открытые ключи проверки токенов (JWKS)
+// @Description  Ключи RS256/EdDSA в формате RFC 7517. Первый ключ - текущий ключ подписи, остальные принимаются при проверке во время ротации.
+// @Description  Нужный ключ выбирается по kid из заголовка токена. Ответ можно кэшировать на 5 минут.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} jwtkeys.JWKS
+// @Router       /.well-known/jwks.json [get]
+func getJWKS() {
+	var _ = jwtkeys.JWKS{}
+}
+
+// getAuthOIDCProviders возвращает провайдеров входа
+// @Summary      Провайдеры входа
+// @Description  Имена настроенных внешних провайдеров (OIDC_PROVIDERS) для кнопок "Войти через ...". Пустой список - вход через провайдеров отключён.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} map[string][]string
+// @Router       /auth/oidc/providers [get]
+func getAuthOIDCProviders() {}
+
+// postAuthOIDCAuthorize начинает вход через провайдера
+// @Summary      Вход через провайдера: адрес авторизации
+// @Description  Возвращает адрес страницы входа провайдера (authorization code flow с PKCE) и state.
+// @Description  Клиент открывает authorization_url, провайдер возвращает пользователя на настроенный REDIRECT_URL с code и state. state действует 10 минут и используется один раз.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        provider path string true "Имя провайдера" example(yandex)
+// @Param        request body auth.OIDCAuthorizeRequest false "Название устройства"
+// @Success      200 {object} auth.OIDCAuthorizeResponse
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      404 {string} string "unknown identity provider"
+// @Failure      502 {string} string "identity provider request failed"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/oidc/{provider}/authorize [post]
+func postAuthOIDCAuthorize() {
+	var _ = auth.OIDCAuthorizeRequest{}
+	var _ = auth.OIDCAuthorizeResponse{}
+}
+
+// postAuthOIDCCallback завершает вход через провайдера
+// @Summary      Вход через провайдера: обмен кода на токены
+// @Description  Обменивает code и state с адреса возврата на токены. Пользователь находится по привязке к провайдеру,
+// @Description  иначе по подтверждённому провайдером email (аккаунт привязывается), иначе регистрируется новый пользователь.
+// @Description  Если у пользователя подключена или обязательна 2FA, возвращается 403 как у /auth/login.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        provider path string true "Имя провайдера" example(yandex)
+// @Param        request body auth.OIDCCallbackRequest true "Код и state"
+// @Success      200 {object} auth.TokenResponse
+// @Failure      400 {string} string "Invalid request body | validation error | invalid or expired login state"
+// @Failure      403 {object} auth.MFAChallengeResponse "Нужен второй фактор"
+// @Failure      404 {string} string "unknown identity provider | User not found"
+// @Failure      409 {string} string "user already exists"
+// @Failure      422 {string} string "identity provider did not return an email | email is not verified by the identity provider"
+// @Failure      502 {string} string "identity provider request failed"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /auth/oidc/{provider}/callback [post]
+func postAuthOIDCCallback() {
+	var _ = auth.OIDCCallbackRequest{}
+}
@@ -0,0 +1,172 @@
+package swagger
+
+import "src/internal/service"
+
+// getAdminCatalog возвращает каталог услуг для администратора
+// @Summary      Каталог услуг (администратор)
+// @Description  Дерево каталога с архивными услугами, пустыми категориями, parent_id, category_id и количеством филиалов, оказывающих услугу.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} service.CatalogResponse
+// @Failure      401 {string} string "Unauthorized"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/services [get]
+func getAdminCatalog() {}
+
+// createService создаёт услугу
+// @Summary      Создать услугу
+// @Description  Услуга добавляется в конец категории (без category_id - в корень каталога).
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body service.CreateServiceRequest true "Название, категория, описание и иконка"
+// @Success      201 {object} service.ServiceResponse
+// @Failure      400 {string} string "Invalid request body | validation error | service name cannot be empty"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Category not found"
+// @Failure      409 {string} string "Service with this name already exists"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/services [post]
+func createService() {
+	var _ = service.CreateServiceRequest{}
+}
+
+// updateService изменяет услугу
+// @Summary      Изменить услугу
+// @Description  Переименование, перенос в другую категорию (в её конец), описание и иконка. Поля заменяются целиком.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "UUID услуги" format(uuid)
+// @Param        request body service.CreateServiceRequest true "Название, категория, описание и иконка"
+// @Success      200 {object} service.ServiceResponse
+// @Failure      400 {string} string "ID must be UUID | Invalid request body | validation error"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Service not found | Category not found"
+// @Failure      409 {string} string "Service with this name already exists"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/services/{id} [put]
+func updateService() {}
+
+// archiveService переносит услугу в архив
+// @Summary      Архивировать услугу
+// @Description  Архивная услуга скрыта из GET /services и не добавляется в филиалы, но остаётся у филиалов, которые её уже оказывают.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id path string true "UUID услуги" format(uuid)
+// @Success      204 "No Content"
+// @Failure      400 {string} string "ID must be UUID"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Service not found"
+// @Router       /admin/services/{id}/archive [post]
+func archiveService() {}
+
+// restoreService возвращает услугу из архива
+// @Summary      Вернуть услугу из архива
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id path string true "UUID услуги" format(uuid)
+// @Success      204 "No Content"
+// @Failure      400 {string} string "ID must be UUID"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Service not found"
+// @Router       /admin/services/{id}/restore [post]
+func restoreService() {}
+
+// deleteService удаляет услугу
+// @Summary      Удалить услугу
+// @Description  Услугу, которую оказывает хотя бы один филиал, удалить нельзя - её можно архивировать.
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id path string true "UUID услуги" format(uuid)
+// @Success      204 "No Content"
+// @Failure      400 {string} string "ID must be UUID"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Service not found"
+// @Failure      409 {string} string "service is offered by branches, archive it instead"
+// @Router       /admin/services/{id} [delete]
+func deleteService() {}
+
+// reorderServices задаёт порядок услуг категории
+// @Summary      Порядок услуг
+// @Description  ids - все услуги категории parent_id (без parent_id - услуги без категории) в новом порядке, включая архивные.
+// @Tags         admin
+// @Accept       json
+// @Security     BearerAuth
+// @Param        request body service.ReorderRequest true "Категория и порядок услуг"
+// @Success      204 "No Content"
+// @Failure      400 {string} string "Invalid request body | validation error | ids must list every item of the category exactly once"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Router       /admin/services/order [put]
+func reorderServices() {
+	var _ = service.ReorderRequest{}
+}
+
+// createServiceCategory создаёт категорию
+// @Summary      Создать категорию услуг
+// @Description  Категория добавляется в конец родительской (без parent_id - в корень каталога). Названия уникальны внутри родителя.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body service.CreateCategoryRequest true "Название, родитель, описание и иконка"
+// @Success      201 {object} service.CategoryResponse
+// @Failure      400 {string} string "Invalid request body | validation error"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Category not found"
+// @Failure      409 {string} string "category with this name already exists in the parent category"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/service-categories [post]
+func createServiceCategory() {
+	var _ = service.CreateCategoryRequest{}
+	var _ = service.CategoryResponse{}
+}
+
+// updateServiceCategory изменяет категорию
+// @Summary      Изменить категорию услуг
+// @Description  Переименование, перенос к другому родителю (в его конец), описание и иконка. Категорию нельзя перенести в неё саму или в её подкатегорию.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "UUID категории" format(uuid)
+// @Param        request body service.CreateCategoryRequest true "Название, родитель, описание и иконка"
+// @Success      200 {object} service.CategoryResponse
+// @Failure      400 {string} string "ID must be UUID | Invalid request body | validation error | category cannot be moved into itself or its subcategory"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Category not found"
+// @Failure      409 {string} string "category with this name already exists in the parent category"
+// @Failure      500 {string} string "Internal server error"
+// @Router       /admin/service-categories/{id} [put]
+func updateServiceCategory() {}
+
+// deleteServiceCategory удаляет пустую категорию
+// @Summary      Удалить категорию услуг
+// @Description  Удаляется только категория без подкатегорий и услуг (в том числе архивных).
+// @Tags         admin
+// @Security     BearerAuth
+// @Param        id path string true "UUID категории" format(uuid)
+// @Success      204 "No Content"
+// @Failure      400 {string} string "ID must be UUID"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Failure      404 {string} string "Category not found"
+// @Failure      409 {string} string "category has subcategories or services"
+// @Router       /admin/service-categories/{id} [delete]
+func deleteServiceCategory() {}
+
+// reorderServiceCategories задаёт порядок подкатегорий
+// @Summary      Порядок категорий услуг
+// @Description  ids - все подкатегории категории parent_id (без parent_id - корневые категории) в новом порядке.
+// @Tags         admin
+// @Accept       json
+// @Security     BearerAuth
+// @Param        request body service.ReorderRequest true "Родитель и порядок категорий"
+// @Success      204 "No Content"
+// @Failure      400 {string} string "Invalid request body | validation error | ids must list every item of the category exactly once"
+// @Failure      403 {string} string "Forbidden: missing permission admin:catalog"
+// @Router       /admin/service-categories/order [put]
+func reorderServiceCategories() {}
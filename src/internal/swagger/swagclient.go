@@ -23,19 +23,20 @@ func getClientOrders() {
 	var _ = order.ClientOrderResponseTZ{}
 }
 
-// GetServices - возвращает список сервисов
-// @Summary Получить список услуг
-// @Description Возвращает список общих услуг и их ID
+// GetServices - возвращает дерево каталога услуг
+// @Summary Получить каталог услуг
+// @Description Возвращает дерево категорий с услугами в заданном администратором порядке. services в корне - услуги без категории.
+// @Description Архивные услуги и категории без услуг не показываются
 // @Tags  services
 // @Security     BearerAuth
 // @Produce      json
-// @Success      200  {array} service.ServiceResponse
+// @Success      200  {object} service.CatalogResponse
 // @Failure      401  {string}  string
 // @Failure      500  {string}  string  "Internal server error"
 // @Router       /services [get]
 func getServices() {
 	// фиктивное использование, чтобы избежать ошибки "imported and not used"
-	var _ = service.ServiceResponse{}
+	var _ = service.CatalogResponse{}
 }
 
 // GetClientCity
@@ -2,6 +2,7 @@ package swagger
 
 import (
 	"src/internal/company"
+	"src/internal/events"
 	"src/internal/partners"
 	"src/internal/timeparsing"
 )
@@ -13,6 +14,7 @@ import (
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Success      200  {object}  company.CompanyResponse  "Данные компании"
 // @Failure      401  {string}  string  "Unauthorized: missing or invalid token"
 // @Failure      403  {string}  string  "User does not have a company"
@@ -30,6 +32,7 @@ func getCompany() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Success      200  {array}   company.CompanyBranch  "Список филиалов (если нет филиалов, возвращается null)"
 // @Failure      401  {string}  string                 "Unauthorized: missing or invalid token"
 // @Failure      403  {string}  string                 "User does not have a company"
@@ -46,6 +49,7 @@ func getCompanyBranches() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        branch_id  path      string  true  "UUID филиала"  example(9eebb3b9-5b35-4007-9d4f-2f4141786b45)
 // @Success      200        {object}  company.CompanyBranchWithServ  "Филиал с услугами (если не найден, возвращается null)"
 // @Failure      400        {string}  string  "missing branch id или invalid branch id format: must be UUID"
@@ -65,6 +69,7 @@ func getCompanyBranchesBranchID() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        branchServID  path      string  true  "UUID записи услуги филиала"  example(6fdd2352-ffc4-4140-b54c-67657f841c1c)
 // @Success      200  {object}  company.ServUpdateResponse  "Детали услуги (если данные отсутствуют, возвращается null)"
 // @Failure      400  {string}  string  "invalid branch service ID или invalid service details format"
@@ -83,6 +88,7 @@ func getCompanyBranchServiceBranchserv_id() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Success      200  {array}   company.CompanyBranchOrderResponse  "Список филиалов с заказами (если данных нет, возвращается null)"
 // @Failure      401  {string}  string  "unauthorized: missing user claims или email not found in token"
 // @Failure      403  {string}  string  "user is not a partner"
@@ -94,6 +100,24 @@ func getCompanyOrders() {
 	var _ = company.ServDetails{}
 }
 
+// streamCompanyOrders отправляет события заказов компании через SSE
+// @Summary      Поток событий заказов компании
+// @Description  Server-Sent Events с событиями order.created, order.status_changed, order.cancelled для компании пользователя. Поддерживает переподключение через заголовок Last-Event-ID. Поток закрывается по истечении токена или при потере доступа к компании.
+// @Tags         company
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Security     APIKeyAuth
+// @Param        branch_id      query   string  false  "UUID филиала для фильтрации" format(uuid)
+// @Param        Last-Event-ID  header  string  false  "ID последнего полученного события"
+// @Success      200  {object}  events.Event  "Поток событий"
+// @Failure      400  {string}  string  "invalid branch id format: must be UUID"
+// @Failure      401  {string}  string  "unauthorized: missing user claims или email not found in token"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Router       /company/orders/stream [get]
+func streamCompanyOrders() {
+	var _ = events.Event{}
+}
+
 // updateOrderStatus обновляет статус заказа (подтверждение/отклонение)
 // @Summary      Обновить статус заказа
 // @Description  Позволяет партнёру подтвердить (approve) или отклонить (reject) заказ, связанный с его компанией. Доступно только для авторизованных партнёров.
@@ -101,6 +125,7 @@ func getCompanyOrders() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        orderID  query      string  true  "UUID заказа"  example(e77fd339-9478-4375-82c1-215936a68b8a)
 // @Param        status   query      string  true  "Новый статус: approve или reject"  example(approve)
 // @Success      200      {object}   company.CompanyOrder  "Обновлённый заказ"
@@ -113,31 +138,172 @@ func updateOrderStatus() {
 	var _ = company.CompanyOrder{}
 }
 
-// AddUserResponse содержит ответ после успешного добавления пользователя в компанию
-type AddUserResponse struct {
-	Message string `json:"message" example:"User added to company successfully"`
-	Email   string `json:"email" example:"newuser@example.com"`
-}
-
-// addNewUserToCompany добавляет нового пользователя в компанию
-// @Summary      Добавить пользователя в компанию
-// @Description  Позволяет партнёру добавить нового пользователя в свою компанию. Новый пользователь должен существовать в системе и ещё не быть партнёром.
+// inviteUser приглашает пользователя в компанию
+// @Summary      Пригласить пользователя в компанию
+// @Description  Позволяет владельцу компании пригласить пользователя с ролью owner, manager или operator (по умолчанию operator). Приглашённый получает письмо с кодом и присоединяется к компании только после принятия приглашения. Повторное приглашение того же email отзывает предыдущее. POST /company/users работает так же и оставлен для совместимости.
 // @Tags         company
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        request  body      company.AddUserRequest  true  "Email нового пользователя"
-// @Success      201      {object}  AddUserResponse  "Пользователь успешно добавлен"
-// @Failure      400      {string}  string  "Invalid request body | validation error | user not found | user is already a partner"
+// @Param        request  body      company.AddUserRequest  true  "Email и роль приглашаемого пользователя"
+// @Success      201      {object}  company.Invitation  "Приглашение отправлено"
+// @Failure      400      {string}  string  "Invalid request body | validation error"
 // @Failure      401      {string}  string  "Unauthorized | Invalid token: email not found"
-// @Failure      403      {string}  string  "User does not have a company"
+// @Failure      403      {string}  string  "User does not have a company | not enough rights in the company"
 // @Failure      404      {string}  string  "Company not found"
-// @Router       /company/users [post]
-func addNewUserToCompany() {
+// @Failure      409      {string}  string  "user is already a partner"
+// @Router       /company/invitations [post]
+func inviteUser() {
 	var _ = company.AddUserRequest{}
-	var _ = AddUserResponse{}
+	var _ = company.Invitation{}
+}
+
+// getInvitations возвращает ожидающие приглашения компании
+// @Summary      Ожидающие приглашения
+// @Description  Действующие приглашения компании, на которые ещё не ответили. Доступно владельцам.
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   company.Invitation
+// @Failure      401  {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Router       /company/invitations [get]
+func getInvitations() {}
+
+// revokeInvitation отзывает приглашение
+// @Summary      Отозвать приглашение
+// @Tags         company
+// @Security     BearerAuth
+// @Param        id   path      string  true  "ID приглашения"
+// @Success      204  "Приглашение отозвано"
+// @Failure      400  {string}  string  "invalid invitation id format: must be UUID"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Failure      404  {string}  string  "invitation not found"
+// @Router       /company/invitations/{id} [delete]
+func revokeInvitation() {}
+
+// getMFAPolicy возвращает обязательность 2FA для сотрудников
+// @Summary      Обязательная 2FA сотрудников
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  company.MFAPolicy
+// @Failure      401  {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Router       /company/security/2fa [get]
+func getMFAPolicy() {}
+
+// setMFAPolicy включает или отключает обязательную 2FA для сотрудников
+// @Summary      Изменить обязательность 2FA сотрудников
+// @Description  Доступно владельцу компании. Сотрудники без 2FA подключат её при следующем входе.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      company.UpdateMFAPolicyRequest  true  "Обязательность 2FA"
+// @Success      200  {object}  company.MFAPolicy
+// @Failure      400  {string}  string  "Invalid request body | validation error"
+// @Failure      401  {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      403  {string}  string  "User does not have a company | not enough rights in the company"
+// @Router       /company/security/2fa [put]
+func setMFAPolicy() {
+	var _ = company.UpdateMFAPolicyRequest{}
+}
+
+// getInvitation возвращает приглашение по коду из письма
+// @Summary      Приглашение по коду
+// @Description  Доступно без авторизации. registered=false означает, что при принятии нужно указать пароль для регистрации.
+// @Tags         invitations
+// @Produce      json
+// @Param        token  path      string  true  "Код приглашения из письма"
+// @Success      200    {object}  company.InvitationInfo
+// @Failure      404    {string}  string  "invitation not found"
+// @Router       /invitations/{token} [get]
+func getInvitation() {
+	var _ = company.InvitationInfo{}
+}
+
+// acceptInvitation принимает приглашение
+// @Summary      Принять приглашение
+// @Description  Добавляет пользователя в компанию. Если пользователь не зарегистрирован, он регистрируется с указанным паролем. После принятия нужно войти заново или обновить токены через /auth/refresh.
+// @Tags         invitations
+// @Accept       json
+// @Produce      json
+// @Param        request  body      company.AcceptInvitationRequest  true  "Код приглашения и пароль"
+// @Success      200      {object}  map[string]string
+// @Failure      400      {string}  string  "validation error | password is required to register the invited user"
+// @Failure      404      {string}  string  "invitation not found"
+// @Failure      409      {string}  string  "invitation is no longer pending | user is already a partner"
+// @Failure      410      {string}  string  "invitation has expired"
+// @Router       /invitations/accept [post]
+func acceptInvitation() {
+	var _ = company.AcceptInvitationRequest{}
 }
 
+// declineInvitation отклоняет приглашение
+// @Summary      Отклонить приглашение
+// @Tags         invitations
+// @Accept       json
+// @Produce      json
+// @Param        request  body      company.DeclineInvitationRequest  true  "Код приглашения"
+// @Success      200      {object}  map[string]string
+// @Failure      404      {string}  string  "invitation not found"
+// @Failure      409      {string}  string  "invitation is no longer pending"
+// @Failure      410      {string}  string  "invitation has expired"
+// @Router       /invitations/decline [post]
+func declineInvitation() {
+	var _ = company.DeclineInvitationRequest{}
+}
+
+// getCompanyUsers возвращает сотрудников компании
+// @Summary      Сотрудники компании
+// @Description  Возвращает сотрудников компании пользователя с их ролями: сначала владельцы, затем менеджеры и операторы.
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   company.CompanyMember
+// @Failure      401  {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /company/users [get]
+func getCompanyUsers() {
+	var _ = company.CompanyMember{}
+}
+
+// updateCompanyUserRole меняет роль сотрудника компании
+// @Summary      Изменить роль сотрудника
+// @Description  Доступно только владельцам компании. Последнего владельца нельзя понизить. Ранее выданные access токены сотрудника отзываются.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        email    path      string                        true  "Email сотрудника"
+// @Param        request  body      company.UpdateUserRoleRequest  true  "Новая роль"
+// @Success      200      {object}  company.CompanyMember
+// @Failure      400      {string}  string  "Invalid request body | validation error"
+// @Failure      401      {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      403      {string}  string  "User does not have a company | not enough rights in the company"
+// @Failure      404      {string}  string  "user is not a member of the company"
+// @Failure      409      {string}  string  "the last owner cannot be removed or demoted"
+// @Router       /company/users/{email}/role [put]
+func updateCompanyUserRole() {
+	var _ = company.UpdateUserRoleRequest{}
+}
+
+// removeCompanyUser удаляет сотрудника из компании
+// @Summary      Удалить сотрудника
+// @Description  Владелец может удалить любого сотрудника, остальные сотрудники - только себя. Последнего владельца удалить нельзя.
+// @Tags         company
+// @Security     BearerAuth
+// @Param        email  path      string  true  "Email сотрудника"
+// @Success      204    "Сотрудник удалён"
+// @Failure      401    {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      403    {string}  string  "User does not have a company | not enough rights in the company"
+// @Failure      404    {string}  string  "user is not a member of the company"
+// @Failure      409    {string}  string  "the last owner cannot be removed or demoted"
+// @Router       /company/users/{email} [delete]
+func removeCompanyUser() {}
+
 type AddBranchResponse struct {
 	Message   string               `json:"message" example:"Branch added to company successfully"`
 	City      string               `json:"city" example:"Москва"`
@@ -153,6 +319,7 @@ type AddBranchResponse struct {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        request  body      company.AddBranchRequest  true  "Данные нового филиала"
 // @Success      201      {object}  AddBranchResponse  "Филиал успешно добавлен"
 // @Failure      400      {string}  string  "Invalid request body | validation error | empty city | invalid city | branch with this address already exists for this company"
@@ -172,6 +339,7 @@ func addNewBranchToCompany() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        request  body      company.AddServDetailRequest  true  "Деталь для добавления"
 // @Success      201      {array}   company.ServUpdateResponse  "Обновлённый список деталей услуги"
 // @Failure      400      {string}  string  "invalid request body | validation error | invalid duration"
@@ -192,6 +360,7 @@ func addServDetail() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        branchServID  path      string  true  "UUID записи услуги филиала"  example(6fdd2352-ffc4-4140-b54c-67657f841c1c)
 // @Param        detail        query     string  true  "Название детали для удаления"  example(Мойка салона)
 // @Success      200           {array}   company.ServUpdateResponse "Обновлённый список деталей услуги"
@@ -222,12 +391,13 @@ func getPartnerRequestStatus() {
 
 // createPartnerRequest создаёт заявку на регистрацию организации
 // @Summary      Создать заявку партнёра
-// @Description  Позволяет авторизованному пользователю подать заявку на регистрацию организации. Проверяет, что компания с таким ИНН ещё не существует в системе.
+// @Description  Позволяет авторизованному пользователю подать заявку на регистрацию организации. Проверяет, что компания с таким ИНН ещё не существует в системе. Если администратор запросил по заявке сведения (статус "needs_info"), заявка заменяется исправленной и возвращается на рассмотрение ("pending"), ответ 200.
 // @Tags         partners
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        request  body      partners.PartnerRequest true  "Данные заявки"
+// @Success      200      {object}  map[string]string  "message: Partner request resubmitted, status: pending"
 // @Success      201      {object}  map[string]string  "message: Partner request created successfully"
 // @Failure      400      {string}  string  "Invalid request body | validation error | user not found | company with this INN already exists"
 // @Failure      401      {string}  string  "Unauthorized | Invalid token: email not found"
@@ -236,6 +406,40 @@ func createPartnerRequest() {
 	var _ = partners.PartnerRequest{}
 }
 
+// getPartnerRequestCommentsOwn возвращает переписку по заявке пользователя
+// @Summary      Переписка по своей заявке
+// @Description  Возвращает сообщения администраторов и заявителя по последней заявке текущего пользователя, начиная со старых.
+// @Tags         partners
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   partners.Comment
+// @Failure      401  {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      404  {string}  string  "partner request not found"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /partner/request/comments [get]
+func getPartnerRequestCommentsOwn() {
+	var _ = partners.Comment{}
+}
+
+// addPartnerRequestCommentOwn добавляет сообщение заявителя в переписку
+// @Summary      Написать администратору
+// @Description  Добавляет сообщение в переписку по последней заявке текущего пользователя. По одобренной или отклонённой заявке писать нельзя.
+// @Tags         partners
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body      partners.CommentRequest true  "Текст сообщения"
+// @Success      201      {object}  partners.Comment
+// @Failure      400      {string}  string  "Invalid request body"
+// @Failure      401      {string}  string  "Unauthorized | Invalid token: email not found"
+// @Failure      404      {string}  string  "partner request not found"
+// @Failure      409      {string}  string  "partner request is already approved or rejected"
+// @Failure      500      {string}  string  "Internal server error"
+// @Router       /partner/request/comments [post]
+func addPartnerRequestCommentOwn() {
+	var _ = partners.CommentRequest{}
+}
+
 // addServiceToBranch добавляет услугу в филиал
 // @Summary      Добавить услугу в филиал
 // @Description  Позволяет партнёру добавить существующую услугу (по ID) в указанный филиал своей компании.
@@ -243,6 +447,7 @@ func createPartnerRequest() {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     APIKeyAuth
 // @Param        request  body      company.AddServiceToBranch  true  "Данные для добавления услуги в филиал"
 // @Success      201      {object}  map[string]interface{}  "message: Service added to branch successfully, branch_id: ..., service_id: ..."
 // @Failure      400      {string}  string  "Invalid request body | validation error | service already exists in this branch"
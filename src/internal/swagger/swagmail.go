@@ -0,0 +1,35 @@
+package swagger
+
+import "src/internal/mail"
+
+// getEmailTemplates возвращает список шаблонов писем
+// @Summary      Получить шаблоны писем
+// @Description  Возвращает имена шаблонов, поддерживаемые языки и язык по умолчанию. Доступно только для администраторов.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  mail.TemplatesResponse
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Router       /admin/email/templates [get]
+func getEmailTemplates() {
+	var _ = mail.TemplatesResponse{}
+}
+
+// previewEmail формирует письмо с примером данных
+// @Summary      Предпросмотр письма
+// @Description  Формирует письмо по шаблону с примером данных. Доступно только для администраторов.
+// @Tags         admin
+// @Produce      html,plain,json
+// @Security     BearerAuth
+// @Param        name    path   string  true   "Имя шаблона"  example(verification_code)
+// @Param        lang    query  string  false  "ru | en (по умолчанию EMAIL_LANG)"
+// @Param        format  query  string  false  "html | text | json (по умолчанию html)"
+// @Success      200  {object}  mail.Message  "При format=json; иначе HTML или текст письма"
+// @Failure      400  {string}  string  "lang must be ru or en"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      404  {string}  string  "Template not found"
+// @Router       /admin/email/preview/{name} [get]
+func previewEmail() {
+	var _ = mail.Message{}
+}
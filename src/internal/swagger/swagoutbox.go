@@ -0,0 +1,50 @@
+package swagger
+
+import "src/internal/outbox"
+
+// getOutboxMessages возвращает исходящие сообщения по статусу
+// @Summary      Получить исходящие сообщения
+// @Description  Возвращает сообщения outbox с указанным статусом (по умолчанию dead), без секретов в payload. Доступно только для администраторов.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        status query string false "pending | processing | sent | dead"
+// @Param        limit query int false "Количество сообщений (1-500, по умолчанию 100)"
+// @Success      200  {array}   outbox.Message  "Список сообщений (если нет, возвращается null)"
+// @Failure      400  {string}  string  "status must be pending, processing, sent or dead / limit must be between 1 and 500"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      500  {string}  string  "Failed to get messages"
+// @Router       /admin/outbox [get]
+func getOutboxMessages() {
+	var _ = outbox.Message{}
+}
+
+// getOutboxMessage возвращает исходящее сообщение по ID
+// @Summary      Получить исходящее сообщение
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "UUID сообщения" format(uuid)
+// @Success      200  {object}  outbox.Message
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      404  {string}  string  "Message not found"
+// @Router       /admin/outbox/{id} [get]
+func getOutboxMessage() {
+	var _ = outbox.Message{}
+}
+
+// replayOutboxMessage возвращает недоставленное сообщение в очередь
+// @Summary      Повторить доставку сообщения
+// @Description  Сбрасывает счётчик попыток у сообщения в статусе dead и возвращает его в очередь.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "UUID сообщения" format(uuid)
+// @Success      202  {object}  map[string]string
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      403  {string}  string  "Forbidden: admin access required"
+// @Failure      404  {string}  string  "Failed message not found"
+// @Router       /admin/outbox/{id}/replay [post]
+func replayOutboxMessage() {}
@@ -0,0 +1,76 @@
+package swagger
+
+import "src/internal/webhook"
+
+// createWebhook регистрирует вебхук компании
+// @Summary      Зарегистрировать вебхук
+// @Description  Регистрирует URL для получения событий компании. Запросы подписываются заголовком X-Pioneer-Signature (HMAC-SHA256). Секрет возвращается только один раз.
+// @Tags         company
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body webhook.CreateWebhookRequest true "URL и список событий"
+// @Success      201  {object}  webhook.CreateWebhookResponse
+// @Failure      400  {string}  string  "unknown event type"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Failure      500  {string}  string  "Internal server error"
+// @Router       /company/webhooks [post]
+func createWebhook() {
+	var _ = webhook.CreateWebhookRequest{}
+}
+
+// getWebhooks возвращает вебхуки компании
+// @Summary      Получить вебхуки компании
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {array}   webhook.Webhook  "Список вебхуков (если нет, возвращается null)"
+// @Failure      401  {string}  string  "Unauthorized"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Router       /company/webhooks [get]
+func getWebhooks() {
+	var _ = webhook.Webhook{}
+}
+
+// deleteWebhook удаляет вебхук компании
+// @Summary      Удалить вебхук
+// @Tags         company
+// @Security     BearerAuth
+// @Param        id path string true "UUID вебхука" format(uuid)
+// @Success      204  "No Content"
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Failure      404  {string}  string  "Webhook not found"
+// @Router       /company/webhooks/{id} [delete]
+func deleteWebhook() {}
+
+// getWebhookDeliveries возвращает журнал доставки вебхука
+// @Summary      Журнал доставки вебхука
+// @Description  Возвращает последние 100 попыток доставки.
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "UUID вебхука" format(uuid)
+// @Success      200  {array}   webhook.Delivery
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Failure      404  {string}  string  "Webhook not found"
+// @Router       /company/webhooks/{id}/deliveries [get]
+func getWebhookDeliveries() {
+	var _ = webhook.Delivery{}
+}
+
+// sendWebhookTestEvent отправляет тестовое событие
+// @Summary      Отправить тестовое событие
+// @Description  Синхронно отправляет событие webhook.test и возвращает результат доставки.
+// @Tags         company
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "UUID вебхука" format(uuid)
+// @Success      200  {object}  webhook.Delivery
+// @Failure      400  {string}  string  "ID must be UUID"
+// @Failure      403  {string}  string  "User does not have a company"
+// @Failure      404  {string}  string  "Webhook not found"
+// @Router       /company/webhooks/{id}/test [post]
+func sendWebhookTestEvent() {}
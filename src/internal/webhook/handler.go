@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"src/internal/middleware"
+	"src/internal/rbac"
+)
+
+// Handler обрабатывает HTTP-запросы для вебхуков компании
+type Handler struct {
+	webhook *WebhookManager
+}
+
+// NewHandler создаёт новый экземпляр Handler
+func NewHandler(webhook *WebhookManager) *Handler {
+	return &Handler{webhook: webhook}
+}
+
+// Получение пользователя, от имени которого выполняется запрос
+func principalFromRequest(w http.ResponseWriter, r *http.Request) (rbac.Principal, bool) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return rbac.Principal{}, false
+	}
+	return principal, true
+}
+
+// Общая обработка ошибок WebhookManager
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotPartner):
+		http.Error(w, "User does not have a company", http.StatusForbidden)
+	case errors.Is(err, ErrPermissionDenied):
+		http.Error(w, ErrPermissionDenied.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrWebhookNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidEventType), errors.Is(err, ErrInvalidURL), errors.Is(err, ErrForbiddenAddress):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// CreateWebhook обрабатывает POST /company/webhooks.
+// Секрет для проверки подписи возвращается только в этом ответе
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ValidateStruct(req); err != nil {
+		middleware.SendValidationError(w, err)
+		return
+	}
+
+	resp, err := h.webhook.CreateWebhook(principal, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetWebhooks обрабатывает GET /company/webhooks
+func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.webhook.GetWebhooks(principal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// DeleteWebhook обрабатывает DELETE /company/webhooks/{id}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.webhook.DeleteWebhook(principal, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries обрабатывает GET /company/webhooks/{id}/deliveries
+func (h *Handler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.webhook.GetDeliveries(principal, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// SendTestEvent обрабатывает POST /company/webhooks/{id}/test.
+// Отправляет тестовое событие и возвращает запись журнала доставки
+func (h *Handler) SendTestEvent(w http.ResponseWriter, r *http.Request) {
+	principal, ok := principalFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "ID must be UUID", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.webhook.SendTestEvent(principal, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий, на которые можно подписаться
+const (
+	EventOrderCreated       = "order.created"        // создан заказ
+	EventOrderStatusChanged = "order.status_changed" // изменён статус заказа
+	EventOrderCancelled     = "order.cancelled"      // заказ отменён
+	EventTest               = "webhook.test"         // тестовое событие
+)
+
+// EventTypes - список событий, доступных для подписки
+var EventTypes = []string{
+	EventOrderCreated,
+	EventOrderStatusChanged,
+	EventOrderCancelled,
+}
+
+// Заголовки запроса доставки
+const (
+	HeaderSignature = "X-Pioneer-Signature"
+	HeaderEvent     = "X-Pioneer-Event"
+	HeaderDelivery  = "X-Pioneer-Delivery"
+)
+
+// Webhook соответствует таблице webhooks
+type Webhook struct {
+	ID         uuid.UUID `json:"id" example:"4f1e8c9a-2b7d-4a61-9a0e-3c5d6e7f8a9b"`
+	CompanyINN string    `json:"inn" example:"123456789012"`
+	URL        string    `json:"url" example:"https://crm.example.com/pioneer/hook"`
+	Events     []string  `json:"events" example:"order.created,order.status_changed"`
+	Secret     string    `json:"-"`
+	IsActive   bool      `json:"is_active" example:"true"`
+	CreatedAt  time.Time `json:"created_at" example:"2026-03-30T06:06:47.181805Z"`
+}
+
+// CreateWebhookRequest - запрос на регистрацию вебхука
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" example:"https://crm.example.com/pioneer/hook" validate:"required,url,max=2048"`
+	Events []string `json:"events" example:"order.created,order.status_changed" validate:"required,min=1,dive,required"`
+}
+
+// CreateWebhookResponse - ответ на регистрацию вебхука.
+// Секрет для проверки подписи показывается только один раз
+type CreateWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret" example:"whsec_6b1f0c..."`
+}
+
+// Event - тело запроса, отправляемого на URL вебхука
+type Event struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	CompanyINN string          `json:"inn"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Data       json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// Delivery соответствует таблице webhook_deliveries - журнал попыток доставки
+type Delivery struct {
+	ID         uuid.UUID  `json:"id" example:"9c0d1e2f-3a4b-5c6d-7e8f-9a0b1c2d3e4f"`
+	WebhookID  uuid.UUID  `json:"webhook_id" example:"4f1e8c9a-2b7d-4a61-9a0e-3c5d6e7f8a9b"`
+	MessageID  *uuid.UUID `json:"message_id,omitempty" example:"0b5a3a3e-6a1b-4b7e-9a55-2f0d3f8b1c11"`
+	EventID    uuid.UUID  `json:"event_id" example:"1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"`
+	EventType  string     `json:"event_type" example:"order.created"`
+	Attempt    int        `json:"attempt" example:"1"`
+	StatusCode *int       `json:"status_code,omitempty" example:"200"`
+	Error      *string    `json:"error,omitempty" example:"context deadline exceeded"`
+	DurationMs int64      `json:"duration_ms" example:"132"`
+	CreatedAt  time.Time  `json:"created_at" example:"2026-03-30T06:06:47.181805Z"`
+}
@@ -0,0 +1,354 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"src/internal/events"
+	"src/internal/outbox"
+	"src/internal/rbac"
+)
+
+var (
+	ErrUserNotPartner   = errors.New("the user does not have a company")
+	ErrPermissionDenied = errors.New("not enough rights in the company")
+	ErrInvalidEventType = errors.New("unknown event type")
+	ErrInvalidURL       = errors.New("webhook url must be an absolute http or https url")
+	ErrUnexpectedStatus = errors.New("webhook endpoint returned non-2xx status")
+)
+
+// Количество записей журнала доставки, отдаваемых за один запрос
+const deliveriesLimit = 100
+
+// WebhookManager содержит бизнес-логику регистрации вебхуков и доставки событий.
+// Реализует outbox.Deliverer для канала outbox.ChannelWebhook
+type WebhookManager struct {
+	storage       WebhookStorage
+	outboxStorage outbox.OutboxStorage
+	client        *http.Client
+}
+
+// NewWebhookManager создаёт новый экземпляр WebhookManager
+func NewWebhookManager(storage WebhookStorage, outboxStorage outbox.OutboxStorage) *WebhookManager {
+	return &WebhookManager{
+		storage:       storage,
+		outboxStorage: outboxStorage,
+		client:        newDeliveryClient(),
+	}
+}
+
+// Получение ИНН компании пользователя с проверкой, что его роль позволяет управлять вебхуками
+func (m *WebhookManager) companyInn(principal rbac.Principal) (string, error) {
+	if principal.INN == "" {
+		return "", ErrUserNotPartner
+	}
+	if !principal.HasPermission(rbac.PermCompanyWebhooks) {
+		return "", ErrPermissionDenied
+	}
+	return principal.INN, nil
+}
+
+// CreateWebhook регистрирует новый вебхук компании пользователя
+func (m *WebhookManager) CreateWebhook(principal rbac.Principal, req CreateWebhookRequest) (*CreateWebhookResponse, error) {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	// Явный внутренний адрес отклоняется сразу, имена хостов проверяются при каждом соединении
+	if addr, err := netip.ParseAddr(parsed.Hostname()); err == nil && IsForbiddenIP(addr) {
+		return nil, ErrForbiddenAddress
+	}
+	if strings.EqualFold(parsed.Hostname(), "localhost") {
+		return nil, ErrForbiddenAddress
+	}
+
+	// Удаление дублей и проверка типов событий
+	var events []string
+	for _, e := range req.Events {
+		if !slices.Contains(EventTypes, e) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEventType, e)
+		}
+		if !slices.Contains(events, e) {
+			events = append(events, e)
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate webhook secret: %w", err)
+	}
+
+	created, err := m.storage.Create(&Webhook{
+		CompanyINN: inn,
+		URL:        req.URL,
+		Events:     events,
+		Secret:     secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateWebhookResponse{Webhook: *created, Secret: secret}, nil
+}
+
+// GetWebhooks возвращает вебхуки компании пользователя
+func (m *WebhookManager) GetWebhooks(principal rbac.Principal) ([]*Webhook, error) {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return nil, err
+	}
+	return m.storage.GetByCompany(inn)
+}
+
+// DeleteWebhook удаляет вебхук компании пользователя
+func (m *WebhookManager) DeleteWebhook(principal rbac.Principal, id uuid.UUID) error {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return err
+	}
+	return m.storage.Delete(id, inn)
+}
+
+// GetDeliveries возвращает журнал доставки вебхука
+func (m *WebhookManager) GetDeliveries(principal rbac.Principal, id uuid.UUID) ([]*Delivery, error) {
+	if _, err := m.companyWebhook(principal, id); err != nil {
+		return nil, err
+	}
+	return m.storage.GetDeliveries(id, deliveriesLimit)
+}
+
+// SendTestEvent синхронно отправляет тестовое событие и возвращает результат доставки
+func (m *WebhookManager) SendTestEvent(principal rbac.Principal, id uuid.UUID) (*Delivery, error) {
+	webhook, err := m.companyWebhook(principal, id)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := newEvent(webhook.CompanyINN, EventTest, map[string]string{
+		"message": "Тестовое событие Pioneer",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, _ := m.send(webhook, event, nil, 1)
+	return delivery, nil
+}
+
+// Получение вебхука с проверкой, что он принадлежит компании пользователя
+func (m *WebhookManager) companyWebhook(principal rbac.Principal, id uuid.UUID) (*Webhook, error) {
+	inn, err := m.companyInn(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := m.storage.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.CompanyINN != inn {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// Publish ставит событие в очередь доставки для всех вебхуков компании, подписанных на него
+func (m *WebhookManager) Publish(companyInn, eventType string, data any) error {
+	return m.publish(nil, companyInn, eventType, data)
+}
+
+// Постановка события в очередь. Если exec == nil, запись выполняется вне транзакции
+func (m *WebhookManager) publish(exec outbox.Execer, companyInn, eventType string, data any) error {
+	webhooks, err := m.storage.GetSubscribed(companyInn, eventType)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	event, err := newEvent(companyInn, eventType, data)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal webhook event: %w", err)
+	}
+
+	for _, webhook := range webhooks {
+		err := m.outboxStorage.Enqueue(exec, &outbox.Message{
+			Channel:   outbox.ChannelWebhook,
+			Kind:      eventType,
+			Recipient: webhook.ID.String(),
+			Payload:   payload,
+		})
+		if err != nil {
+			return fmt.Errorf("enqueue webhook %s: %w", webhook.ID, err)
+		}
+	}
+	return nil
+}
+
+// HandleEvent получает события из шины events.Bus и ставит в очередь те, на которые можно подписаться
+func (m *WebhookManager) HandleEvent(evt *events.Event) error {
+	if !slices.Contains(EventTypes, evt.Type) {
+		return nil
+	}
+	return m.Publish(evt.CompanyINN, evt.Type, evt.Data)
+}
+
+// HandleEventTx ставит событие в очередь в транзакции, в которой меняются данные:
+// вебхук отправляется, только если изменение зафиксировано
+func (m *WebhookManager) HandleEventTx(tx *sql.Tx, evt *events.Event) error {
+	if !slices.Contains(EventTypes, evt.Type) {
+		return nil
+	}
+	return m.publish(tx, evt.CompanyINN, evt.Type, evt.Data)
+}
+
+// Deliver доставляет событие из outbox на URL вебхука
+func (m *WebhookManager) Deliver(msg *outbox.Message) error {
+	webhookID, err := uuid.Parse(msg.Recipient)
+	if err != nil {
+		return fmt.Errorf("%w: invalid webhook id %q", outbox.ErrPermanent, msg.Recipient)
+	}
+
+	webhook, err := m.storage.GetByID(webhookID)
+	if err != nil {
+		if errors.Is(err, ErrWebhookNotFound) {
+			return fmt.Errorf("%w: %w", outbox.ErrPermanent, err)
+		}
+		return err
+	}
+	if !webhook.IsActive {
+		return fmt.Errorf("%w: webhook %s is disabled", outbox.ErrPermanent, webhook.ID)
+	}
+
+	var event Event
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		return fmt.Errorf("%w: unmarshal webhook event: %w", outbox.ErrPermanent, err)
+	}
+
+	messageID := msg.ID
+	_, err = m.send(webhook, &event, &messageID, msg.Attempts+1)
+	return err
+}
+
+// Отправка события с подписью и записью результата в журнал доставки
+func (m *WebhookManager) send(webhook *Webhook, event *Event, messageID *uuid.UUID, attempt int) (*Delivery, error) {
+	delivery := &Delivery{
+		WebhookID: webhook.ID,
+		MessageID: messageID,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Attempt:   attempt,
+	}
+
+	sendErr := m.post(webhook, event, delivery)
+
+	if sendErr != nil {
+		errText := sendErr.Error()
+		delivery.Error = &errText
+	}
+
+	logged, err := m.storage.CreateDelivery(delivery)
+	if err != nil {
+		return delivery, fmt.Errorf("log webhook delivery: %w", err)
+	}
+	return logged, sendErr
+}
+
+// HTTP-запрос к вебхуку. Заполняет код ответа и длительность в delivery
+func (m *WebhookManager) post(webhook *Webhook, event *Event, delivery *Delivery) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal webhook event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Pioneer-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, event.Type)
+	req.Header.Set(HeaderDelivery, event.ID.String())
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := m.client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		return fmt.Errorf("send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	statusCode := resp.StatusCode
+	delivery.StatusCode = &statusCode
+	if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, statusCode)
+	}
+	return nil
+}
+
+// Sign формирует значение заголовка X-Pioneer-Signature: t=<unix>,v1=<hex(HMAC-SHA256(secret, "<unix>.<body>"))>.
+// Получатель повторяет вычисление и сравнивает подписи, отклоняя слишком старые t
+func Sign(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Создание события с сериализованными данными
+func newEvent(companyInn, eventType string, data any) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook event data: %w", err)
+	}
+	return &Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		CompanyINN: companyInn,
+		CreatedAt:  time.Now().UTC(),
+		Data:       raw,
+	}, nil
+}
+
+// Генерация секрета для подписи
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"src/internal/outbox"
+	"src/internal/rbac"
+)
+
+// Хранилище в памяти для проверки доставки
+type fakeStorage struct {
+	WebhookStorage
+
+	webhooks   map[uuid.UUID]*Webhook
+	deliveries []*Delivery
+}
+
+func (s *fakeStorage) GetByID(id uuid.UUID) (*Webhook, error) {
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *fakeStorage) CreateDelivery(delivery *Delivery) (*Delivery, error) {
+	s.deliveries = append(s.deliveries, delivery)
+	return delivery, nil
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	got := Sign("whsec_test", 1700000000, body)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		ts     int64
+		body   string
+	}{
+		{"other secret", "whsec_other", 1700000000, `{"id":"1"}`},
+		{"other timestamp", "whsec_test", 1700000001, `{"id":"1"}`},
+		{"other body", "whsec_test", 1700000000, `{"id":"2"}`},
+	}
+	for _, tt := range tests {
+		if Sign(tt.secret, tt.ts, []byte(tt.body)) == got {
+			t.Errorf("%s: signature must change", tt.name)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		active        bool
+		recipient     string
+		wantErr       error
+		wantPermanent bool
+		wantLogged    bool
+	}{
+		{name: "delivered", status: http.StatusNoContent, active: true, wantLogged: true},
+		{name: "non-2xx is retried", status: http.StatusBadGateway, active: true, wantErr: ErrUnexpectedStatus, wantLogged: true},
+		{name: "disabled webhook", status: http.StatusOK, wantPermanent: true},
+		{name: "deleted webhook", status: http.StatusOK, active: true, recipient: uuid.NewString(), wantPermanent: true},
+		{name: "invalid recipient", status: http.StatusOK, active: true, recipient: "not-a-uuid", wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHeaders http.Header
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotHeaders = r.Header
+				gotBody, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			webhook := &Webhook{ID: uuid.New(), CompanyINN: "7700000000", URL: server.URL, Secret: "whsec_test", IsActive: tt.active}
+			storage := &fakeStorage{webhooks: map[uuid.UUID]*Webhook{webhook.ID: webhook}}
+			m := NewWebhookManager(storage, nil)
+			// httptest слушает loopback, поэтому проверка адресов здесь отключена
+			m.client = server.Client()
+
+			event, err := newEvent(webhook.CompanyINN, EventOrderCreated, map[string]string{"id": "42"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			payload, _ := json.Marshal(event)
+			recipient := webhook.ID.String()
+			if tt.recipient != "" {
+				recipient = tt.recipient
+			}
+			msg := &outbox.Message{ID: uuid.New(), Channel: outbox.ChannelWebhook, Kind: event.Type, Recipient: recipient, Payload: payload, Attempts: 2}
+
+			err = m.Deliver(msg)
+			if tt.wantPermanent {
+				if !errors.Is(err, outbox.ErrPermanent) {
+					t.Fatalf("Deliver error = %v, want permanent", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Deliver error = %v, want %v", err, tt.wantErr)
+			}
+
+			// Подпись вычисляется по точному телу запроса и отметке времени из заголовка
+			signature := gotHeaders.Get(HeaderSignature)
+			ts := strings.TrimPrefix(strings.SplitN(signature, ",", 2)[0], "t=")
+			timestamp, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				t.Fatalf("invalid signature timestamp %q", signature)
+			}
+			if signature != Sign(webhook.Secret, timestamp, gotBody) {
+				t.Errorf("signature %s does not match body", signature)
+			}
+			if gotHeaders.Get(HeaderEvent) != EventOrderCreated || gotHeaders.Get(HeaderDelivery) != event.ID.String() {
+				t.Errorf("headers = %v", gotHeaders)
+			}
+
+			if got := len(storage.deliveries) == 1; got != tt.wantLogged {
+				t.Fatalf("delivery logged = %v, want %v", got, tt.wantLogged)
+			}
+			delivery := storage.deliveries[0]
+			if delivery.Attempt != 3 || *delivery.MessageID != msg.ID || *delivery.StatusCode != tt.status {
+				t.Errorf("delivery = %+v", delivery)
+			}
+		})
+	}
+}
+
+func TestIsForbiddenIP(t *testing.T) {
+	tests := []struct {
+		addr      string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := IsForbiddenIP(netip.MustParseAddr(tt.addr)); got != tt.forbidden {
+			t.Errorf("IsForbiddenIP(%s) = %v, want %v", tt.addr, got, tt.forbidden)
+		}
+	}
+}
+
+func TestDeliveryClientRejectsInternalAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached loopback server")
+	}))
+	defer server.Close()
+
+	// Имя хоста разрешается в 127.0.0.1 - соединение отклоняется после разрешения DNS
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, err := newDeliveryClient().Get(url)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Get error = %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestCreateWebhookRejectsInternalURL(t *testing.T) {
+	m := NewWebhookManager(nil, nil)
+	owner := rbac.Principal{Email: "owner@mail.ru", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOwner}
+	for _, url := range []string{"http://127.0.0.1/hook", "http://localhost:8080/hook", "http://[::1]/hook", "http://169.254.169.254/latest"} {
+		_, err := m.CreateWebhook(owner, CreateWebhookRequest{URL: url, Events: []string{EventOrderCreated}})
+		if !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CreateWebhook(%s) error = %v, want ErrForbiddenAddress", url, err)
+		}
+	}
+}
+
+func TestWebhookManagementRequiresPermission(t *testing.T) {
+	m := NewWebhookManager(nil, nil)
+	tests := []struct {
+		name      string
+		principal rbac.Principal
+		wantErr   error
+	}{
+		{"not a member", rbac.Principal{Email: "c@mail.ru", Role: rbac.RoleClient}, ErrUserNotPartner},
+		{"operator", rbac.Principal{Email: "op@mail.ru", Role: rbac.RolePartner, INN: "7700000000", CompanyRole: rbac.CompanyRoleOperator}, ErrPermissionDenied},
+		{"api key", rbac.Principal{Role: rbac.RolePartner, INN: "7700000000", APIKeyID: "k1", Scopes: rbac.APIKeyScopes}, ErrPermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.GetWebhooks(tt.principal); !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetWebhooks error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
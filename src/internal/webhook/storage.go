@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"src/internal/db"
+)
+
+// ошибки которые возвращает webhook/storage
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// WebhookStorage определяет методы для работы с вебхуками и журналом доставки
+type WebhookStorage interface {
+	Create(webhook *Webhook) (*Webhook, error)
+
+	GetByID(id uuid.UUID) (*Webhook, error)
+
+	GetByCompany(inn string) ([]*Webhook, error)
+
+	GetSubscribed(inn, eventType string) ([]*Webhook, error)
+
+	Delete(id uuid.UUID, inn string) error
+
+	CreateDelivery(delivery *Delivery) (*Delivery, error)
+
+	GetDeliveries(webhookID uuid.UUID, limit int) ([]*Delivery, error)
+}
+
+// PostgresWebhookStorage реализует WebhookStorage для PostgreSQL
+type PostgresWebhookStorage struct {
+	*db.Storage
+}
+
+// NewPostgresWebhookStorage создаёт новый экземпляр PostgresWebhookStorage
+func NewPostgresWebhookStorage(sqlDB *sql.DB) *PostgresWebhookStorage {
+	return &PostgresWebhookStorage{Storage: db.NewStorage(sqlDB)}
+}
+
+// Create сохраняет новый вебхук
+func (s *PostgresWebhookStorage) Create(webhook *Webhook) (*Webhook, error) {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook events: %w", err)
+	}
+
+	created := *webhook
+	err = s.DB.QueryRow(`
+		INSERT INTO webhooks (company_inn, url, events, secret, is_active)
+		VALUES ($1, $2, $3, $4, TRUE)
+		RETURNING id, is_active, created_at
+	`, webhook.CompanyINN, webhook.URL, events, webhook.Secret).Scan(&created.ID, &created.IsActive, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert webhook: %w", err)
+	}
+	return &created, nil
+}
+
+// GetByID возвращает вебхук по ID.
+// Если вебхук не найден, возвращает ErrWebhookNotFound
+func (s *PostgresWebhookStorage) GetByID(id uuid.UUID) (*Webhook, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, company_inn, url, events, secret, is_active, created_at
+		FROM webhooks
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks, err := scanWebhooks(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, ErrWebhookNotFound
+	}
+	return webhooks[0], nil
+}
+
+// GetByCompany возвращает все вебхуки компании
+func (s *PostgresWebhookStorage) GetByCompany(inn string) ([]*Webhook, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, company_inn, url, events, secret, is_active, created_at
+		FROM webhooks
+		WHERE company_inn = $1
+		ORDER BY created_at
+	`, inn)
+	if err != nil {
+		return nil, fmt.Errorf("query webhooks by company %s: %w", inn, err)
+	}
+	defer rows.Close()
+
+	return scanWebhooks(rows)
+}
+
+// GetSubscribed возвращает активные вебхуки компании, подписанные на событие
+func (s *PostgresWebhookStorage) GetSubscribed(inn, eventType string) ([]*Webhook, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, company_inn, url, events, secret, is_active, created_at
+		FROM webhooks
+		WHERE company_inn = $1 AND is_active AND events @> jsonb_build_array($2::text)
+	`, inn, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("query subscribed webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhooks(rows)
+}
+
+// Delete удаляет вебхук компании.
+// Если вебхук не найден или принадлежит другой компании, возвращает ErrWebhookNotFound
+func (s *PostgresWebhookStorage) Delete(id uuid.UUID, inn string) error {
+	result, err := s.DB.Exec(`DELETE FROM webhooks WHERE id = $1 AND company_inn = $2`, id, inn)
+	if err != nil {
+		return fmt.Errorf("delete webhook %v: %w", id, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// CreateDelivery записывает попытку доставки в журнал
+func (s *PostgresWebhookStorage) CreateDelivery(delivery *Delivery) (*Delivery, error) {
+	created := *delivery
+	err := s.DB.QueryRow(`
+		INSERT INTO webhook_deliveries (webhook_id, message_id, event_id, event_type, attempt, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`, delivery.WebhookID, delivery.MessageID, delivery.EventID, delivery.EventType, delivery.Attempt,
+		delivery.StatusCode, delivery.Error, delivery.DurationMs).Scan(&created.ID, &created.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	return &created, nil
+}
+
+// GetDeliveries возвращает последние попытки доставки вебхука
+func (s *PostgresWebhookStorage) GetDeliveries(webhookID uuid.UUID, limit int) ([]*Delivery, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, webhook_id, message_id, event_id, event_type, attempt, status_code, error, duration_ms, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		var d Delivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.MessageID, &d.EventID, &d.EventType, &d.Attempt,
+			&d.StatusCode, &d.Error, &d.DurationMs, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Сканирование строк webhooks
+func scanWebhooks(rows *sql.Rows) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	for rows.Next() {
+		var w Webhook
+		var eventsRaw []byte
+		err := rows.Scan(&w.ID, &w.CompanyINN, &w.URL, &eventsRaw, &w.Secret, &w.IsActive, &w.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook: %w", err)
+		}
+		if err := json.Unmarshal(eventsRaw, &w.Events); err != nil {
+			return nil, fmt.Errorf("unmarshal webhook events: %w", err)
+		}
+		webhooks = append(webhooks, &w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+	return webhooks, nil
+}
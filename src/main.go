@@ -1,29 +1,43 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
 
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
 	_ "src/docs"
 	"src/internal/admin"
+	"src/internal/apikey"
+	"src/internal/audit"
 	"src/internal/auth"
 	"src/internal/branch"
 	"src/internal/client"
 	"src/internal/company"
 	configPkg "src/internal/config"
 	"src/internal/db"
+	"src/internal/events"
+	"src/internal/mail"
 	"src/internal/middleware"
 	"src/internal/order"
+	"src/internal/outbox"
 	"src/internal/partners"
+	"src/internal/rbac"
 	"src/internal/router"
 	"src/internal/service"
+	"src/internal/webhook"
+	"src/migrations"
 )
 
 func main() {
+	migrateOnly := flag.Bool("migrate", false, "apply database migrations and exit")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, relying on system environment")
 	}
@@ -31,60 +45,242 @@ func main() {
 	// Загрузка порта из env
 	port := os.Getenv("SERVER_PORT")
 
+	//Подключение к бд
+	database, err := db.Connect()
+	if err != nil {
+		log.Fatal("Could not connect to database: %w", err)
+
+	}
+	defer database.Close()
+
+	// Миграции применяются командой -migrate (шаг деплоя) или при запуске, если DB_AUTO_MIGRATE=true
+	if *migrateOnly || os.Getenv("DB_AUTO_MIGRATE") == "true" {
+		applied, err := db.Migrate(database, migrations.FS)
+		if err != nil {
+			log.Fatal("Failed to apply migrations: ", err)
+		}
+		log.Printf("Migrations applied: %d", len(applied))
+		if *migrateOnly {
+			return
+		}
+	}
+
 	// Загрузка конфигурации для jwt токенов
 	jwt, err := configPkg.LoadJWTConfig()
 	if err != nil {
 		log.Fatal("Failed to load config:", err)
 	}
 
-	//Подключение к бд
-	database, err := db.Connect()
+	// Шаблоны писем
+	mailRenderer, err := configPkg.LoadMailRenderer()
 	if err != nil {
-		log.Fatal("Could not connect to database: %w", err)
+		log.Fatal("Failed to load email templates:", err)
+	}
+	mailHandler := mail.NewHandler(mailRenderer)
 
+	// Способ доставки писем (smtp, file или memory) выбирается через EMAIL_TRANSPORT
+	emailConfig, err := configPkg.LoadEmailConfig()
+	if err != nil {
+		log.Fatal("Failed to load email config:", err)
+	}
+	emailTransport, mailbox, err := configPkg.NewEmailTransport(emailConfig)
+	if err != nil {
+		log.Fatal("Failed to create email transport:", err)
+	}
+	// Недоступный SMTP не мешает запуску: письма остаются в outbox до восстановления связи
+	if smtpTransport, ok := emailTransport.(*mail.SMTPTransport); ok {
+		go func() {
+			if err := smtpTransport.Ping(); err != nil {
+				log.Printf("Warning: SMTP server is unreachable, emails will be retried: %v", err)
+			}
+		}()
+	}
+	emailService := configPkg.NewEmailService(emailConfig.From, mailRenderer, emailTransport)
+
+	// Почтовый ящик в памяти открыт на /dev/mailbox только при EMAIL_TRANSPORT=memory и DEV_MAILBOX=true
+	var mailboxHandler *mail.MailboxHandler
+	if mailbox != nil && emailConfig.DevMailbox {
+		log.Println("Warning: emails are stored in memory and available without authentication at /dev/mailbox")
+		mailboxHandler = mail.NewMailboxHandler(mailbox)
 	}
-	defer database.Close()
 
-	// Подключение к сервису с email
-	emailService, err := configPkg.ConnectSMTP()
+	// Очередь исходящих сообщений: письма пишутся в outbox и отправляются фоновым обработчиком
+	outboxConfig, err := configPkg.LoadOutboxConfig()
 	if err != nil {
-		log.Fatal("Failed to connect to SMTP:", err)
+		log.Fatal("Failed to load outbox config:", err)
 	}
 
+	outboxStorage := outbox.NewPostgresOutboxStorage(database)
+	outboxManager := outbox.NewOutboxManager(outboxStorage, *outboxConfig)
+	outboxManager.RegisterDeliverer(outbox.ChannelEmail, outbox.NewEmailDeliverer(emailService))
+	outboxHandler := outbox.NewHandler(outboxManager)
+
+	// Вебхуки партнёров: события заказов доставляются через outbox
+	webhookStorage := webhook.NewPostgresWebhookStorage(database)
+	webhookManager := webhook.NewWebhookManager(webhookStorage, outboxStorage)
+	outboxManager.RegisterDeliverer(outbox.ChannelWebhook, webhookManager)
+	webhookHandler := webhook.NewHandler(webhookManager)
+
+	// Транзакции для операций, затрагивающих несколько storage
+	txManager := db.NewTxManager(database)
+
+	// Журнал действий администраторов и управления доступом к компаниям
+	auditStorage := audit.NewPostgresAuditStorage(database)
+	auditLog := audit.NewAuditLog(auditStorage)
+	auditHandler := audit.NewHandler(auditLog)
+
+	// API ключи компаний для интеграций партнёров
+	apiKeyStorage := apikey.NewPostgresAPIKeyStorage(database)
+	apiKeyManager := apikey.NewAPIKeyManager(apiKeyStorage, auditLog, txManager)
+	apiKeyHandler := apikey.NewHandler(apiKeyManager)
+
+	// Шина событий заказов: order и company публикуют, SSE-поток и вебхуки получают
+	eventBus := events.NewBus()
+	eventBus.AddListener(webhookManager)
+
+	outboxManager.Start()
+
+	emailQueue := outbox.NewEmailQueue(outboxStorage)
+
+	// Отзыв access токенов при изменении роли пользователя
+	revocations := rbac.NewRevocations(rbac.NewPostgresRevocationStorage(database), jwt.AccessTokenTTL, jwt.RevocationSyncInterval)
+	revocations.Start()
+
 	// Конфигурация токенов
 	authConfig := auth.Config{
-		JWTSecretKey:    jwt.SecretKey,
 		AccessTokenTTL:  jwt.AccessTokenTTL,
 		RefreshTokenTTL: jwt.RefreshTokenTTL,
 		VerificationTTL: jwt.VerificationTTL,
 	}
 
+	// Redis необязателен: если REDIS_URL не задан, общие данные экземпляров хранятся в PostgreSQL
+	redisClient, err := configPkg.NewRedisClient()
+	if err != nil {
+		log.Fatal("Failed to create redis client:", err)
+	}
+	if redisClient != nil {
+		defer redisClient.Close()
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			log.Fatal("Could not connect to redis:", err)
+		}
+	}
+
+	// Хранилище кодов подтверждения и сброса пароля выбирается через CODE_STORAGE
+	codeStorageConfig, err := configPkg.LoadCodeStorageConfig()
+	if err != nil {
+		log.Fatal("Failed to load code storage config:", err)
+	}
+
+	// Ограничение частоты запросов, хранилище выбирается через RATE_LIMIT_STORAGE
+	rateLimitConfig, err := configPkg.LoadRateLimitConfig()
+	if err != nil {
+		log.Fatal("Failed to load rate limit config:", err)
+	}
+
+	var rateLimitStore middleware.RateLimitStore
+	switch rateLimitConfig.Storage {
+	case configPkg.RateLimitStorageRedis:
+		rateLimitStore = middleware.NewRedisRateLimitStore(redisClient)
+	case configPkg.RateLimitStoragePostgres:
+		rateLimitStore = middleware.NewPostgresRateLimitStore(database)
+	case configPkg.RateLimitStorageMemory:
+		rateLimitStore = middleware.NewMemoryRateLimitStore()
+	}
+	if rateLimitStore != nil {
+		if rateLimitConfig.FailOpen {
+			log.Printf("Rate limit storage: %s, requests are allowed when it is unavailable (RATE_LIMIT_FAIL_MODE=open)", rateLimitConfig.Storage)
+		} else {
+			log.Printf("Rate limit storage: %s, requests are rejected when it is unavailable (RATE_LIMIT_FAIL_MODE=closed)", rateLimitConfig.Storage)
+		}
+	}
+	if rateLimitConfig.Storage == configPkg.RateLimitStorageMemory {
+		log.Println("WARNING: RATE_LIMIT_STORAGE=memory, limits are counted separately on each instance")
+	}
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore, rateLimitConfig.FailOpen)
+
+	// Защита входа от перебора паролей
+	loginProtectionConfig, err := configPkg.LoadLoginProtectionConfig()
+	if err != nil {
+		log.Fatal("Failed to load login protection config:", err)
+	}
+
+	// Ключ шифрования секретов 2FA (MFA_SECRET_KEY)
+	mfaConfig, err := configPkg.LoadMFAConfig()
+	if err != nil {
+		log.Fatal("Failed to load mfa config:", err)
+	}
+	mfaSecretCipher, err := auth.NewSecretCipher(mfaConfig.SecretKey)
+	if err != nil {
+		log.Fatal("Failed to create mfa secret cipher:", err)
+	}
+
+	// Вход через внешних провайдеров (OIDC_PROVIDERS)
+	oidcProviders, err := configPkg.LoadOIDCConfig()
+	if err != nil {
+		log.Fatal("Failed to load oidc config:", err)
+	}
+
+	// Приглашения в компанию
+	invitationConfig, err := configPkg.LoadInvitationConfig()
+	if err != nil {
+		log.Fatal("Failed to load invitation config:", err)
+	}
+
+	// Рассмотрение заявок партнёров
+	partnerRequestConfig, err := configPkg.LoadPartnerRequestConfig()
+	if err != nil {
+		log.Fatal("Failed to load partner request config:", err)
+	}
+
 	// Запуск обработчиков из пакета auth
 	userStorage := auth.NewPostgresUserStorage(database)
 	tsUserStorage := auth.NewPostgresTSUserStorage(database)
 	refreshTokenStorage := auth.NewPostgresRefreshTokenStorage(database)
-	verificationStorage := auth.NewMemoryVerificationStorage()
-	resetPasswordStorage := auth.NewMemoryResetPasswordStorage()
+	loginAttemptStorage := auth.NewPostgresLoginAttemptStorage(database, loginProtectionConfig.FailureWindow)
+	securityEventStorage := auth.NewPostgresSecurityEventStorage(database)
+	mfaStorage := auth.NewPostgresMFAStorage(database, mfaSecretCipher)
+	mfaChallengeStorage := auth.NewPostgresMFAChallengeStorage(database)
+	accountStorage := auth.NewPostgresAccountStorage(database, mfaSecretCipher)
+	oidcStateStorage := auth.NewPostgresOIDCStateStorage(database)
+	oidcIdentityStorage := auth.NewPostgresOIDCIdentityStorage(database)
 
-	authService := auth.NewAuthManager(userStorage, refreshTokenStorage, verificationStorage, resetPasswordStorage, tsUserStorage, emailService, authConfig)
+	var verificationStorage auth.VerificationStorage
+	var resetPasswordStorage auth.ResetPasswordStorage
+	var emailChangeStorage auth.EmailChangeStorage
+	switch codeStorageConfig.Backend {
+	case configPkg.CodeStorageRedis:
+		verificationStorage = auth.NewRedisVerificationStorage(redisClient)
+		resetPasswordStorage = auth.NewRedisResetPasswordStorage(redisClient)
+		emailChangeStorage = auth.NewRedisEmailChangeStorage(redisClient)
+	case configPkg.CodeStorageMemory:
+		verificationStorage = auth.NewMemoryVerificationStorage()
+		resetPasswordStorage = auth.NewMemoryResetPasswordStorage()
+		emailChangeStorage = auth.NewMemoryEmailChangeStorage()
+	default:
+		verificationStorage = auth.NewPostgresVerificationStorage(database)
+		resetPasswordStorage = auth.NewPostgresResetPasswordStorage(database)
+		emailChangeStorage = auth.NewPostgresEmailChangeStorage(database)
+	}
+
+	authService := auth.NewAuthManager(userStorage, refreshTokenStorage, verificationStorage, resetPasswordStorage, tsUserStorage, loginAttemptStorage, securityEventStorage, mfaStorage, mfaChallengeStorage, accountStorage, emailChangeStorage, oidcStateStorage, oidcIdentityStorage, oidcProviders, revocations, jwt.Keys, emailQueue, auditLog, authConfig, *loginProtectionConfig, codeStorageConfig.HashSecret, txManager)
 	authHandler := auth.NewHandler(authService)
 
 	//Запуск обработчиков из пакета servise
 	serviceStorage := service.NewPostgresServiceStorage(database)
-	serviceManager := service.NewServiceManager(serviceStorage)
+	serviceManager := service.NewServiceManager(serviceStorage, auditLog, txManager)
 	serviceHandler := service.NewHandler(serviceManager)
 
 	//Запуск обработчиков из пакета company
 	companyStorage := company.NewPostgresCompanyStorage(database)
-	companyManager := company.NewCompanyManager(companyStorage, userStorage)
-	companyHandler := company.NewHandler(companyManager)
+	companyManager := company.NewCompanyManager(companyStorage, userStorage, authService, emailQueue, eventBus, revocations, *invitationConfig, auditLog, txManager)
+	companyHandler := company.NewHandler(companyManager, eventBus)
 
 	clientStorage := client.NewPostgresClientStorage(database)
 	clientManager := client.NewClientManager(clientStorage)
 	clientHandler := client.NewHandler(clientManager)
 
 	orderStorage := order.NewPostrgesOrderStorage(database)
-	orderManager := order.NewOrderManager(orderStorage)
+	orderManager := order.NewOrderManager(orderStorage, eventBus, txManager)
 	orderHandler := order.NewHandler(orderManager)
 
 	branchStorage := branch.NewPostgresBranchStorage(database)
@@ -97,23 +293,31 @@ func main() {
 	companyStorageFromAdmin := admin.NewPostgresCompanyStorage(database)
 	partnersUsersStorage := admin.NewPostgresPartnersUsersStorage(database)
 
-	adminManager := admin.NewAdminManager(userStorage, partnerRequestStorage, companyStorageFromAdmin, partnersUsersStorage, adminStorage, emailService, admin.Config(authConfig))
+	adminManager := admin.NewAdminManager(userStorage, partnerRequestStorage, companyStorageFromAdmin, partnersUsersStorage, adminStorage, emailQueue, revocations, auditLog, admin.Config(authConfig), *partnerRequestConfig, txManager)
 	adminHandler := admin.NewHandler(adminManager)
 
 	// Запуск обработчиков из пакета /partners
 	partnerRequestStorageFromPartners := partners.NewPostgresPartnerRequestStorage(database)
 	companyStorageFromPartners := partners.NewPostgresCompanyStorage(database)
 
-	partnersManager := partners.NewPartnersManager(userStorage, partnerRequestStorageFromPartners, companyStorageFromPartners, emailService, partners.Config(authConfig))
+	partnersManager := partners.NewPartnersManager(userStorage, partnerRequestStorageFromPartners, companyStorageFromPartners, emailQueue, partners.Config(authConfig))
 	partnersHandler := partners.NewHandler(partnersManager)
 
-	authMiddleware := middleware.NewAuthMiddleware(jwt.SecretKey)
-	adminMiddleware := middleware.NewAdminMiddleware(adminManager)
+	authMiddleware := middleware.NewAuthMiddleware(jwt.Keys, revocations)
+	apiKeyMiddleware := middleware.NewAPIKeyMiddleware(apiKeyManager, authMiddleware)
+	adminMiddleware := middleware.NewAdminMiddleware()
 	//Пути - src/internal/router/router.go
-	router := router.New(authMiddleware, adminMiddleware, serviceHandler, companyHandler, clientHandler, orderHandler, branchHandler, authHandler, adminHandler, partnersHandler)
+	router := router.New(authMiddleware, apiKeyMiddleware, adminMiddleware, rateLimiter, serviceHandler, companyHandler, clientHandler, orderHandler, branchHandler, authHandler, adminHandler, partnersHandler, outboxHandler, webhookHandler, apiKeyHandler, auditHandler, mailHandler, mailboxHandler)
+
+	// За обратным прокси IP клиента берётся из X-Forwarded-For / X-Real-IP.
+	// Без прокси заголовки не учитываются, иначе клиент может подменить свой адрес
+	var handler http.Handler = router
+	if os.Getenv("TRUST_PROXY") == "true" {
+		handler = chimiddleware.RealIP(handler)
+	}
 
 	// Запуск сервера
 	log.Printf("Сервер запущен на http://localhost:%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	log.Fatal(http.ListenAndServe(":"+port, handler))
 
 }
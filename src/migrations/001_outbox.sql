@@ -0,0 +1,18 @@
+-- Очередь исходящих сообщений (transactional outbox)
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    channel         VARCHAR(32)  NOT NULL,
+    kind            VARCHAR(64)  NOT NULL,
+    recipient       VARCHAR(255) NOT NULL,
+    payload         JSONB        NOT NULL DEFAULT '{}'::jsonb,
+    status          VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    attempts        INTEGER      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    locked_until    TIMESTAMPTZ,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_messages_due_idx
+    ON outbox_messages (status, next_attempt_at);
@@ -0,0 +1,30 @@
+-- Вебхуки партнёров
+CREATE TABLE IF NOT EXISTS webhooks (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_inn VARCHAR(12)   NOT NULL REFERENCES companies (inn) ON DELETE CASCADE,
+    url         VARCHAR(2048) NOT NULL,
+    events      JSONB         NOT NULL DEFAULT '[]'::jsonb,
+    secret      VARCHAR(128)  NOT NULL,
+    is_active   BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_company_idx
+    ON webhooks (company_inn);
+
+-- Журнал попыток доставки вебхуков
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id  UUID        NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    message_id  UUID,
+    event_id    UUID        NOT NULL,
+    event_type  VARCHAR(64) NOT NULL,
+    attempt     INTEGER     NOT NULL,
+    status_code INTEGER,
+    error       TEXT,
+    duration_ms BIGINT      NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx
+    ON webhook_deliveries (webhook_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- Моменты изменения ролей: access токены, выданные раньше, отклоняются
+CREATE TABLE IF NOT EXISTS role_revocations (
+    email      VARCHAR(255) PRIMARY KEY,
+    revoked_at TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS role_revocations_revoked_at_idx
+    ON role_revocations (revoked_at);
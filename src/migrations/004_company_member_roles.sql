@@ -0,0 +1,16 @@
+-- Роли сотрудников компании: owner, manager, operator.
+-- Существующие сотрудники становятся владельцами, чтобы не потерять доступ
+ALTER TABLE partners_users
+    ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'owner';
+
+ALTER TABLE partners_users
+    ALTER COLUMN role SET DEFAULT 'operator';
+
+ALTER TABLE partners_users
+    DROP CONSTRAINT IF EXISTS partners_users_role_check;
+
+ALTER TABLE partners_users
+    ADD CONSTRAINT partners_users_role_check CHECK (role IN ('owner', 'manager', 'operator'));
+
+CREATE INDEX IF NOT EXISTS partners_users_inn_role_idx
+    ON partners_users (inn, role);
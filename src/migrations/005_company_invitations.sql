@@ -0,0 +1,19 @@
+-- Приглашения в компанию. Хранится только SHA-256 от кода приглашения
+CREATE TABLE IF NOT EXISTS company_invitations (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_inn  VARCHAR(12)  NOT NULL REFERENCES companies (inn) ON DELETE CASCADE,
+    email        VARCHAR(255) NOT NULL,
+    role         VARCHAR(16)  NOT NULL CHECK (role IN ('owner', 'manager', 'operator')),
+    token_hash   CHAR(64)     NOT NULL UNIQUE,
+    invited_by   VARCHAR(255) NOT NULL,
+    status       VARCHAR(16)  NOT NULL DEFAULT 'pending'
+                 CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ  NOT NULL,
+    responded_at TIMESTAMPTZ
+);
+
+-- Не больше одного ожидающего приглашения на email в компании
+CREATE UNIQUE INDEX IF NOT EXISTS company_invitations_pending_idx
+    ON company_invitations (company_inn, email)
+    WHERE status = 'pending';
@@ -0,0 +1,24 @@
+-- Refresh токены хранятся в виде SHA-256 и объединяются в семейства (один вход - одно семейство).
+-- Использованные токены остаются до истечения срока с отметкой rotated_at для обнаружения повторного использования
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash CHAR(64);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS family_id UUID;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS rotated_at TIMESTAMPTZ;
+
+-- Перенос выданных токенов: каждый становится отдельным семейством
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    family_id  = gen_random_uuid()
+WHERE token_hash IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token;
+
+CREATE UNIQUE INDEX IF NOT EXISTS refresh_tokens_token_hash_idx
+    ON refresh_tokens (token_hash);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx
+    ON refresh_tokens (family_id);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_email_idx
+    ON refresh_tokens (email);
@@ -0,0 +1,14 @@
+-- Сессии устройств: семейство refresh токенов соответствует одному входу с устройства.
+-- Данные клиента записываются при входе и обновляются при каждой ротации
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS device_info VARCHAR(100);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS ip VARCHAR(45);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_started_at TIMESTAMPTZ;
+
+-- Для уже выданных токенов момент входа неизвестен, используется время последнего обращения
+UPDATE refresh_tokens
+SET session_started_at = COALESCE(last_used, NOW())
+WHERE session_started_at IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN session_started_at SET DEFAULT NOW();
+ALTER TABLE refresh_tokens ALTER COLUMN session_started_at SET NOT NULL;
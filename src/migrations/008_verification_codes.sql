@@ -0,0 +1,25 @@
+-- Коды подтверждения регистрации и сброса пароля (CODE_STORAGE=postgres).
+-- Хранится только SHA-256 от кода, attempts ограничивает перебор одного кода
+CREATE TABLE IF NOT EXISTS verification_codes (
+    email         VARCHAR(255) PRIMARY KEY,
+    password_hash TEXT         NOT NULL,
+    code_hash     CHAR(64)     NOT NULL,
+    attempts      INT          NOT NULL DEFAULT 0,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS verification_codes_expires_idx
+    ON verification_codes (expires_at);
+
+CREATE TABLE IF NOT EXISTS password_reset_codes (
+    email      VARCHAR(255) PRIMARY KEY,
+    code_hash  CHAR(64)     NOT NULL,
+    attempts   INT          NOT NULL DEFAULT 0,
+    verified   BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_codes_expires_idx
+    ON password_reset_codes (expires_at);
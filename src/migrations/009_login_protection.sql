@@ -0,0 +1,21 @@
+-- Счётчики неудачных попыток входа. key - "account:<email>" или "ip:<адрес>"
+CREATE TABLE IF NOT EXISTS login_failures (
+    key             VARCHAR(320) PRIMARY KEY,
+    failures        INT          NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    locked_until    TIMESTAMPTZ
+);
+
+-- Журнал событий безопасности, доступный пользователю
+CREATE TABLE IF NOT EXISTS security_events (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email      VARCHAR(255) NOT NULL,
+    type       VARCHAR(32)  NOT NULL,
+    ip         VARCHAR(45),
+    user_agent TEXT,
+    details    TEXT,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS security_events_email_idx
+    ON security_events (email, created_at DESC);
@@ -0,0 +1,12 @@
+-- Состояние ограничений частоты запросов (RATE_LIMIT_STORAGE=postgres), алгоритм token bucket.
+-- allowed - результат последнего запроса, expires_at - момент полного восстановления bucket'а
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key        VARCHAR(255)     PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    allowed    BOOLEAN          NOT NULL,
+    updated_at TIMESTAMPTZ      NOT NULL,
+    expires_at TIMESTAMPTZ      NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_expires_idx
+    ON rate_limits (expires_at);
@@ -0,0 +1,44 @@
+-- Двухфакторная аутентификация (TOTP). Секрет сохраняется при начале подключения,
+-- enabled становится TRUE после подтверждения первым кодом. last_used_step защищает от повторного ввода кода
+CREATE TABLE IF NOT EXISTS user_mfa (
+    email          VARCHAR(255) PRIMARY KEY,
+    secret         VARCHAR(64)  NOT NULL,
+    enabled        BOOLEAN      NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT,
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    enabled_at     TIMESTAMPTZ
+);
+
+-- Одноразовые коды восстановления, хранится только SHA-256
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    email      VARCHAR(255) NOT NULL REFERENCES user_mfa (email) ON DELETE CASCADE,
+    code_hash  CHAR(64)     NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (email, code_hash)
+);
+
+-- Второй шаг входа: выдаётся после проверки пароля, обменивается на токены после проверки кода.
+-- setup - пользователь обязан подключить 2FA перед входом
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+    token_hash  CHAR(64)     PRIMARY KEY,
+    email       VARCHAR(255) NOT NULL,
+    setup       BOOLEAN      NOT NULL DEFAULT FALSE,
+    device_name VARCHAR(100),
+    attempts    INT          NOT NULL DEFAULT 0,
+    expires_at  TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS mfa_challenges_expires_idx
+    ON mfa_challenges (expires_at);
+
+-- Общие настройки безопасности, например обязательная 2FA для администраторов (admin_mfa_required)
+CREATE TABLE IF NOT EXISTS security_settings (
+    key        VARCHAR(64)  PRIMARY KEY,
+    value      TEXT         NOT NULL,
+    updated_by VARCHAR(255),
+    updated_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- Владелец компании может сделать 2FA обязательной для сотрудников
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,18 @@
+-- Смена адреса почты: новый адрес подтверждается кодом (CODE_STORAGE=postgres).
+-- Хранится только SHA-256 от кода, attempts ограничивает перебор
+CREATE TABLE IF NOT EXISTS email_changes (
+    email      VARCHAR(255) PRIMARY KEY,
+    new_email  VARCHAR(255) NOT NULL,
+    code_hash  CHAR(64)     NOT NULL,
+    attempts   INT          NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS email_changes_expires_idx
+    ON email_changes (expires_at);
+
+-- Коды восстановления 2FA переносятся вместе с адресом почты
+ALTER TABLE mfa_recovery_codes DROP CONSTRAINT IF EXISTS mfa_recovery_codes_email_fkey;
+ALTER TABLE mfa_recovery_codes ADD CONSTRAINT mfa_recovery_codes_email_fkey
+    FOREIGN KEY (email) REFERENCES user_mfa (email) ON UPDATE CASCADE ON DELETE CASCADE;
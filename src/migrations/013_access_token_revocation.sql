@@ -0,0 +1,16 @@
+-- Access токен, выданный вместе с refresh токеном. По jti отзываются access токены
+-- завершённых сессий и всех сессий пользователя при смене роли
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS access_jti UUID;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS access_expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_email_access_idx
+    ON refresh_tokens (email, access_expires_at);
+
+-- Отозванные access токены. Запись нужна только до истечения срока токена
+CREATE TABLE IF NOT EXISTS token_revocations (
+    jti        UUID        PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS token_revocations_expires_at_idx
+    ON token_revocations (expires_at);
@@ -0,0 +1,26 @@
+-- Начатые входы через внешних провайдеров (OpenID Connect / OAuth 2.0).
+-- state хранится только в виде SHA-256 и удаляется при использовании
+CREATE TABLE IF NOT EXISTS oidc_states (
+    state_hash    CHAR(64)     PRIMARY KEY,
+    provider      VARCHAR(32)  NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    device_name   VARCHAR(100),
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at    TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS oidc_states_expires_at_idx
+    ON oidc_states (expires_at);
+
+-- Привязки аккаунтов провайдеров к пользователям. provider_email - адрес у провайдера на момент привязки
+CREATE TABLE IF NOT EXISTS oidc_identities (
+    provider       VARCHAR(32)  NOT NULL,
+    subject        VARCHAR(255) NOT NULL,
+    email          VARCHAR(255) NOT NULL,
+    provider_email VARCHAR(255),
+    created_at     TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS oidc_identities_email_idx
+    ON oidc_identities (email);
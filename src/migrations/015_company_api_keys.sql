@@ -0,0 +1,17 @@
+-- API ключи компаний для интеграций (кассы, CRM). Ключ показывается один раз,
+-- хранится только SHA-256. Права ключа ограничены scopes
+CREATE TABLE IF NOT EXISTS company_api_keys (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_inn  VARCHAR(12)  NOT NULL REFERENCES companies (inn) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    prefix       VARCHAR(16)  NOT NULL,
+    key_hash     VARCHAR(64)  NOT NULL UNIQUE,
+    scopes       JSONB        NOT NULL DEFAULT '[]'::jsonb,
+    created_by   VARCHAR(255) NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    last_used_ip VARCHAR(45)
+);
+
+CREATE INDEX IF NOT EXISTS company_api_keys_company_idx
+    ON company_api_keys (company_inn, created_at);
@@ -0,0 +1,26 @@
+-- Дерево категорий каталога услуг
+CREATE TABLE IF NOT EXISTS service_categories (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    parent_id   UUID         REFERENCES service_categories (id) ON DELETE RESTRICT,
+    name        VARCHAR(100) NOT NULL,
+    description TEXT         NOT NULL DEFAULT '',
+    icon        VARCHAR(255) NOT NULL DEFAULT '',
+    position    INTEGER      NOT NULL DEFAULT 0,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- Названия категорий уникальны внутри родительской категории
+CREATE UNIQUE INDEX IF NOT EXISTS service_categories_parent_name_idx
+    ON service_categories (COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), lower(name));
+
+-- Услуги получают категорию, описание, иконку и порядок внутри категории.
+-- Архивная услуга не показывается клиентам и не добавляется в филиалы,
+-- но остаётся у филиалов, которые её уже оказывают
+ALTER TABLE services ADD COLUMN IF NOT EXISTS category_id UUID REFERENCES service_categories (id) ON DELETE RESTRICT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS icon VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE services ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS services_category_idx
+    ON services (category_id, position);
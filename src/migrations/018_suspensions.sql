@@ -0,0 +1,9 @@
+-- Блокировка пользователей администратором: вход и обновление токенов запрещены
+ALTER TABLE all_users
+    ADD COLUMN IF NOT EXISTS suspended_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS suspension_reason TEXT;
+
+-- Приостановка компаний: филиалы скрыты из поиска, новые заказы и запросы по API ключам не принимаются
+ALTER TABLE companies
+    ADD COLUMN IF NOT EXISTS suspended_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS suspension_reason TEXT;
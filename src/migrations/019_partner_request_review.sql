@@ -0,0 +1,15 @@
+-- Рассмотрение заявок на подключение: причина отклонения или запроса сведений (статус needs_info)
+ALTER TABLE part_req
+    ADD COLUMN IF NOT EXISTS status_reason TEXT;
+
+-- Переписка администратора и заявителя по заявке
+CREATE TABLE IF NOT EXISTS part_req_comments (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    request_id  UUID         NOT NULL REFERENCES part_req(id) ON DELETE CASCADE,
+    author      VARCHAR(255) NOT NULL,
+    author_role VARCHAR(16)  NOT NULL, -- admin | applicant
+    text        TEXT         NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS part_req_comments_request_idx ON part_req_comments (request_id, created_at);
@@ -0,0 +1,6 @@
+-- Назначение заявок партнёров администраторам: кто рассматривает заявку и с какого момента
+ALTER TABLE part_req
+    ADD COLUMN IF NOT EXISTS assigned_to VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS part_req_assigned_to_idx ON part_req (assigned_to, status);
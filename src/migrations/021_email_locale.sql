@@ -0,0 +1,4 @@
+-- Язык писем пользователя (последний язык интерфейса при входе) и язык письма в outbox
+ALTER TABLE all_users ADD COLUMN IF NOT EXISTS locale VARCHAR(8);
+
+ALTER TABLE outbox_messages ADD COLUMN IF NOT EXISTS locale VARCHAR(8) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Секрет TOTP хранится зашифрованным ключом сервера (v1:<base64>), зашифрованное значение длиннее исходного.
+-- Секреты, записанные ранее в открытом виде, шифруются приложением при первом чтении
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE VARCHAR(255);
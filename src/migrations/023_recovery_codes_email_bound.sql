@@ -0,0 +1,4 @@
+-- Коды восстановления, выданные до этой миграции, хешировались вместе с адресом почты
+-- и после его смены не подходят. Новые коды от адреса не зависят
+ALTER TABLE mfa_recovery_codes ADD COLUMN IF NOT EXISTS email_bound BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE mfa_recovery_codes ALTER COLUMN email_bound SET DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Действия, выполненные API ключом компании: у такого автора нет email, записывается ID ключа.
+-- Ключи удаляются при отзыве, поэтому внешнего ключа на company_api_keys нет
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS api_key_id UUID;
@@ -0,0 +1,10 @@
+// Package migrations содержит SQL-миграции схемы, встроенные в бинарный файл.
+// Применяются db.Migrate при запуске сервера или командой backend-pioneer -migrate
+package migrations
+
+import "embed"
+
+// FS - файлы миграций вида NNN_name.sql. Применяются в порядке имён
+//
+//go:embed *.sql
+var FS embed.FS